@@ -0,0 +1,133 @@
+// Package accountmeta exports account-level Dropbox metadata that isn't part
+// of any file, such as file requests and sharing policies, into a JSON bundle
+// next to the backed-up files.
+package accountmeta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// FileName is the name of the bundle kept in the backup directory root
+const FileName = ".dropbox-account-metadata.json"
+
+// Sections of the bundle, used as keys of Bundle.Unavailable
+const (
+	SectionFileRequests    = "file_requests"
+	SectionConnectedApps   = "connected_apps"
+	SectionSharingPolicies = "sharing_policies"
+)
+
+// Bundle is a snapshot of the account's metadata
+type Bundle struct {
+	ExportedAt  time.Time `json:"exported_at"`
+	AccountID   string    `json:"account_id"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	TeamName    string    `json:"team_name,omitempty"`
+
+	FileRequests    []dropbox.FileRequest    `json:"file_requests"`
+	ConnectedApps   []dropbox.LinkedApp      `json:"connected_apps"`
+	SharingPolicies *dropbox.SharingPolicies `json:"sharing_policies,omitempty"`
+
+	// Unavailable explains, by section, why a section couldn't be exported
+	Unavailable map[string]string `json:"unavailable,omitempty"`
+}
+
+// Source is the subset of the Dropbox client used to export metadata
+type Source interface {
+	GetAccountInfo(ctx context.Context) (*dropbox.AccountInfo, error)
+	FileRequests(ctx context.Context) ([]dropbox.FileRequest, error)
+	LinkedApps(ctx context.Context, teamMemberID string) ([]dropbox.LinkedApp, error)
+}
+
+// Path returns the bundle location for a backup directory
+func Path(backupDir string) string {
+	return filepath.Join(backupDir, FileName)
+}
+
+// Export collects the account's metadata. Only failing to identify the
+// account is an error; sections the token has no access to are recorded in
+// Unavailable so the bundle shows what is missing.
+func Export(ctx context.Context, src Source, now time.Time) (*Bundle, error) {
+	account, err := src.GetAccountInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export account metadata: %w", err)
+	}
+
+	b := &Bundle{
+		ExportedAt:      now,
+		AccountID:       account.AccountID,
+		Email:           account.Email,
+		DisplayName:     account.DisplayName,
+		TeamName:        account.TeamName,
+		FileRequests:    []dropbox.FileRequest{},
+		ConnectedApps:   []dropbox.LinkedApp{},
+		SharingPolicies: account.SharingPolicies,
+	}
+
+	if requests, err := src.FileRequests(ctx); err != nil {
+		b.unavailable(SectionFileRequests, err.Error())
+	} else if requests != nil {
+		b.FileRequests = requests
+	}
+
+	if account.TeamMemberID == "" {
+		b.unavailable(SectionConnectedApps, "only available for team accounts")
+		b.unavailable(SectionSharingPolicies, "only available for team accounts")
+		return b, nil
+	}
+	if apps, err := src.LinkedApps(ctx, account.TeamMemberID); err != nil {
+		b.unavailable(SectionConnectedApps, err.Error())
+	} else if apps != nil {
+		b.ConnectedApps = apps
+	}
+	return b, nil
+}
+
+// unavailable records why a section is missing and warns about it
+func (b *Bundle) unavailable(section, reason string) {
+	if b.Unavailable == nil {
+		b.Unavailable = make(map[string]string)
+	}
+	b.Unavailable[section] = reason
+	slog.Warn("Account metadata not exported",
+		slog.String("section", section),
+		slog.String("reason", reason),
+	)
+}
+
+// Save atomically writes the bundle to the backup directory
+func (b *Bundle) Save(backupDir string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode account metadata: %w", err)
+	}
+
+	path := Path(backupDir)
+	tmp, err := os.CreateTemp(backupDir, "."+FileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create account metadata: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write account metadata: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write account metadata: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace account metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package accountmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+type fakeSource struct {
+	account    dropbox.AccountInfo
+	requests   []dropbox.FileRequest
+	requestErr error
+	apps       []dropbox.LinkedApp
+	appsErr    error
+}
+
+func (f *fakeSource) GetAccountInfo(ctx context.Context) (*dropbox.AccountInfo, error) {
+	return &f.account, nil
+}
+
+func (f *fakeSource) FileRequests(ctx context.Context) ([]dropbox.FileRequest, error) {
+	return f.requests, f.requestErr
+}
+
+func (f *fakeSource) LinkedApps(ctx context.Context, teamMemberID string) ([]dropbox.LinkedApp, error) {
+	return f.apps, f.appsErr
+}
+
+func TestExport(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	policies := &dropbox.SharingPolicies{SharedLinkCreate: "team_only"}
+
+	tests := []struct {
+		name            string
+		src             *fakeSource
+		wantRequests    int
+		wantApps        int
+		wantUnavailable []string
+	}{
+		{
+			name: "personal account",
+			src: &fakeSource{
+				account:  dropbox.AccountInfo{AccountID: "dbid:1", Email: "jane@example.com"},
+				requests: []dropbox.FileRequest{{ID: "r1", Title: "Invoices"}},
+			},
+			wantRequests:    1,
+			wantUnavailable: []string{SectionConnectedApps, SectionSharingPolicies},
+		},
+		{
+			name: "team account with user token",
+			src: &fakeSource{
+				account:    dropbox.AccountInfo{AccountID: "dbid:1", TeamName: "Acme", TeamMemberID: "dbmid:1", SharingPolicies: policies},
+				requestErr: errors.New("missing_scope"),
+				appsErr:    errors.New("invalid_access_token"),
+			},
+			wantUnavailable: []string{SectionFileRequests, SectionConnectedApps},
+		},
+		{
+			name: "team admin token",
+			src: &fakeSource{
+				account: dropbox.AccountInfo{AccountID: "dbid:1", TeamMemberID: "dbmid:1", SharingPolicies: policies},
+				apps:    []dropbox.LinkedApp{{ID: "a1", Name: "Backup Tool"}},
+			},
+			wantApps: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Export(context.Background(), tt.src, now)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if len(b.FileRequests) != tt.wantRequests || len(b.ConnectedApps) != tt.wantApps {
+				t.Errorf("Export() = %d file requests, %d apps; want %d, %d",
+					len(b.FileRequests), len(b.ConnectedApps), tt.wantRequests, tt.wantApps)
+			}
+			if len(b.Unavailable) != len(tt.wantUnavailable) {
+				t.Errorf("Export() unavailable = %v, want %v", b.Unavailable, tt.wantUnavailable)
+			}
+			for _, section := range tt.wantUnavailable {
+				if b.Unavailable[section] == "" {
+					t.Errorf("Export() unavailable = %v, want %s", b.Unavailable, section)
+				}
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	b := &Bundle{
+		AccountID:     "dbid:1",
+		FileRequests:  []dropbox.FileRequest{{ID: "r1", Title: "Invoices"}},
+		ConnectedApps: []dropbox.LinkedApp{},
+	}
+	if err := b.Save(dir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(Path(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Bundle
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("bundle is not valid JSON: %v", err)
+	}
+	if loaded.AccountID != "dbid:1" || len(loaded.FileRequests) != 1 || loaded.FileRequests[0].Title != "Invoices" {
+		t.Errorf("saved bundle = %+v", loaded)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Save() left %d files, want only the bundle", len(entries))
+	}
+}
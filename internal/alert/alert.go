@@ -0,0 +1,152 @@
+// Package alert sends the outcome of a backup run to a webhook, e.g. a chat
+// channel or an incident tool, with what a responder needs to triage a
+// failed run without logging into the machine first: the run ID, a link to
+// the full report and the first failed paths.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds the delivery of an alert, so an unreachable webhook
+// doesn't hold up the end of a run
+const sendTimeout = 30 * time.Second
+
+// Failure is a failed path listed in an alert
+type Failure struct {
+	Path  string `json:"path"`
+	Class string `json:"class"`
+	Error string `json:"error"`
+}
+
+// Alert is the JSON body posted to the webhook. Text repeats it for people,
+// so chat webhooks that only show a text field (Slack, Mattermost, Teams
+// workflows) need no mapping.
+type Alert struct {
+	RunID      string    `json:"run_id"`
+	Status     string    `json:"status"`
+	Host       string    `json:"host"`
+	Profile    string    `json:"profile"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Downloaded int       `json:"downloaded"`
+	Failed     int       `json:"failed"`
+	Error      string    `json:"error,omitempty"`
+	// Report is the URL of the full report, or its path on Host without a
+	// report URL
+	Report string `json:"report,omitempty"`
+	// Failures lists the first failed paths; MoreFailures counts the others
+	Failures     []Failure `json:"failures,omitempty"`
+	MoreFailures int       `json:"more_failures,omitempty"`
+	// Summary is the RESULT line of the run
+	Summary string `json:"summary"`
+	Text    string `json:"text"`
+}
+
+// Status values of an alert
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Limit keeps the first n failures, counting the others in MoreFailures.
+// failed is the number of failed files, which may exceed the failures known.
+func (a *Alert) Limit(failures []Failure, failed, n int) {
+	if len(failures) > n {
+		failures = failures[:n]
+	}
+	a.Failures = failures
+	a.MoreFailures = max(failed, len(failures)) - len(failures)
+}
+
+// Format returns the alert for people, starting with a headline
+func (a *Alert) Format() string {
+	var b strings.Builder
+	if a.Status == StatusOK {
+		fmt.Fprintf(&b, "✅ Dropbox backup succeeded on %s (profile %s)\n", a.Host, a.Profile)
+	} else {
+		fmt.Fprintf(&b, "❌ Dropbox backup failed on %s (profile %s)\n", a.Host, a.Profile)
+	}
+	fmt.Fprintf(&b, "Run: %s\n", a.RunID)
+	fmt.Fprintf(&b, "Downloaded: %d files, %d failed, in %s\n", a.Downloaded, a.Failed, a.End.Sub(a.Start).Round(time.Second))
+	if a.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n", a.Error)
+	}
+	if a.Report != "" {
+		fmt.Fprintf(&b, "Report: %s\n", a.Report)
+	}
+	if len(a.Failures) > 0 {
+		b.WriteString("Failed paths:\n")
+		for _, failure := range a.Failures {
+			fmt.Fprintf(&b, "• %s (%s): %s\n", failure.Path, failure.Class, failure.Error)
+		}
+		if a.MoreFailures > 0 {
+			fmt.Fprintf(&b, "• and %d more\n", a.MoreFailures)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Send posts an alert to a webhook as JSON, filling in its Text
+func Send(ctx context.Context, url string, a Alert) error {
+	a.Text = a.Format()
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("alert webhook returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// WriteReport writes the full report of a run as <run id>.json to a
+// directory and returns its path. Reports are world-readable, like the
+// metrics file, so a web server can serve the directory.
+func WriteReport(dir, runID string, report any) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode run report: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+	path := filepath.Join(dir, runID+".json")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write run report: %w", err)
+	}
+	return path, nil
+}
+
+// ReportLink returns the URL of a report written by WriteReport in the
+// directory served at baseURL, or its path without a base URL
+func ReportLink(baseURL, path string) string {
+	if baseURL == "" {
+		return path
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + filepath.Base(path)
+}
@@ -0,0 +1,130 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimit(t *testing.T) {
+	failures := []Failure{{Path: "/a"}, {Path: "/b"}, {Path: "/c"}}
+
+	tests := []struct {
+		name      string
+		failed    int
+		n         int
+		wantPaths int
+		wantMore  int
+	}{
+		{name: "all fit", failed: 3, n: 10, wantPaths: 3},
+		{name: "first n", failed: 3, n: 2, wantPaths: 2, wantMore: 1},
+		{name: "more failed than listed", failed: 150, n: 2, wantPaths: 2, wantMore: 148},
+		{name: "none listed", failed: 3, n: 0, wantMore: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a Alert
+			a.Limit(failures, tt.failed, tt.n)
+			if len(a.Failures) != tt.wantPaths || a.MoreFailures != tt.wantMore {
+				t.Errorf("Limit() = %d failures and %d more, want %d and %d", len(a.Failures), a.MoreFailures, tt.wantPaths, tt.wantMore)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	a := Alert{
+		RunID:        "20240101T020000Z-0a1b2c",
+		Status:       StatusFailed,
+		Host:         "nas",
+		Profile:      "default",
+		Start:        start,
+		End:          start.Add(90 * time.Second),
+		Downloaded:   12,
+		Failed:       3,
+		Error:        "3 files failed",
+		Report:       "https://nas/reports/20240101T020000Z-0a1b2c.json",
+		Failures:     []Failure{{Path: "/a.txt", Class: "permission", Error: "denied"}},
+		MoreFailures: 2,
+	}
+
+	want := `❌ Dropbox backup failed on nas (profile default)
+Run: 20240101T020000Z-0a1b2c
+Downloaded: 12 files, 3 failed, in 1m30s
+Error: 3 files failed
+Report: https://nas/reports/20240101T020000Z-0a1b2c.json
+Failed paths:
+• /a.txt (permission): denied
+• and 2 more`
+	if got := a.Format(); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s with %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode alert: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := Send(context.Background(), server.URL, Alert{RunID: "run-1", Status: StatusOK}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got.RunID != "run-1" || !strings.Contains(got.Text, "Run: run-1") {
+		t.Errorf("posted alert = %+v, want run-1 with its text", got)
+	}
+}
+
+func TestSendRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := Send(context.Background(), server.URL, Alert{RunID: "run-1"})
+	if err == nil || !strings.Contains(err.Error(), "no such hook") {
+		t.Errorf("Send() error = %v, want the webhook's answer", err)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+
+	path, err := WriteReport(dir, "run-1", map[string]int{"failed_files": 3})
+	if err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	if path != filepath.Join(dir, "run-1.json") {
+		t.Errorf("WriteReport() = %s, want run-1.json in the directory", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"failed_files": 3`) {
+		t.Errorf("report = %q (%v), want the report as JSON", data, err)
+	}
+}
+
+func TestReportLink(t *testing.T) {
+	path := filepath.Join("reports", "run-1.json")
+	if got := ReportLink("", path); got != path {
+		t.Errorf("ReportLink() without a URL = %s, want the path", got)
+	}
+	for _, base := range []string{"https://nas/reports", "https://nas/reports/"} {
+		if got := ReportLink(base, path); got != "https://nas/reports/run-1.json" {
+			t.Errorf("ReportLink(%q) = %s, want the URL of the report", base, got)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package archive implements the write-once archive mode: backed-up files are
+// sealed read-only, and every run appends the hash of its manifest to a hash
+// chain so later changes to the backup can be detected.
+package archive
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// ChainFileName is the name of the hash chain kept in the backup directory root
+const ChainFileName = ".dropbox-archive-chain.jsonl"
+
+// Link is one run in the hash chain. Hash covers all other fields, including
+// the previous link's hash, so changing or removing an earlier run breaks
+// every later link.
+type Link struct {
+	Run            int       `json:"run"`
+	Time           time.Time `json:"time"`
+	Files          int       `json:"files"`
+	ManifestSHA256 string    `json:"manifest_sha256"`
+	Prev           string    `json:"prev"`
+	Hash           string    `json:"hash"`
+}
+
+// ChainPath returns the hash chain location for a backup directory
+func ChainPath(backupDir string) string {
+	return filepath.Join(backupDir, ChainFileName)
+}
+
+// Seal makes a file read-only. On Windows this sets FILE_ATTRIBUTE_READONLY.
+func Seal(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to seal %s: %w", path, err)
+	}
+	if err := os.Chmod(path, info.Mode().Perm()&^0222); err != nil {
+		return fmt.Errorf("failed to seal %s: %w", path, err)
+	}
+	return nil
+}
+
+// Sealed reports whether a file is read-only
+func Sealed(info os.FileInfo) bool {
+	return info.Mode().Perm()&0222 == 0
+}
+
+// Append adds the backup directory's current manifest to the hash chain
+func Append(backupDir string, now time.Time, files int) (Link, error) {
+	links, err := readChain(backupDir)
+	if err != nil {
+		return Link{}, err
+	}
+	sum, err := fileSHA256(manifest.Path(backupDir))
+	if err != nil {
+		return Link{}, fmt.Errorf("failed to hash manifest: %w", err)
+	}
+
+	link := Link{Run: 1, Time: now.UTC(), Files: files, ManifestSHA256: sum}
+	if len(links) > 0 {
+		last := links[len(links)-1]
+		link.Run = last.Run + 1
+		link.Prev = last.Hash
+	}
+	link.Hash = link.digest()
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return Link{}, fmt.Errorf("failed to encode archive chain: %w", err)
+	}
+	f, err := os.OpenFile(ChainPath(backupDir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return Link{}, fmt.Errorf("failed to open archive chain: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return Link{}, fmt.Errorf("failed to append to archive chain: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return Link{}, fmt.Errorf("failed to append to archive chain: %w", err)
+	}
+	return link, nil
+}
+
+// digest computes the hash of a link from all fields but Hash
+func (l Link) digest() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%d|%s|%s",
+		l.Run, l.Time.Format(time.RFC3339Nano), l.Files, l.ManifestSHA256, l.Prev)))
+	return hex.EncodeToString(sum[:])
+}
+
+// readChain reads all links of a backup directory's hash chain
+func readChain(backupDir string) ([]Link, error) {
+	f, err := os.Open(ChainPath(backupDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive chain: %w", err)
+	}
+	defer f.Close()
+
+	var links []Link
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var link Link
+		if err := json.Unmarshal(scanner.Bytes(), &link); err != nil {
+			return nil, fmt.Errorf("failed to parse archive chain after run %d: %w", len(links), err)
+		}
+		links = append(links, link)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive chain: %w", err)
+	}
+	return links, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// newArchive creates a backup directory with one file recorded in the
+// manifest and chained once
+func newArchive(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	content := []byte("quarterly report")
+	if err := os.WriteFile(filepath.Join(dir, "report.txt"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := dropbox.ContentHash(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set("/report.txt", manifest.Entry{Rev: "1", ContentHash: hash, Size: uint64(len(content))})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Append(dir, time.Now(), m.Len()); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	return dir
+}
+
+func TestAppendChainsRuns(t *testing.T) {
+	dir := newArchive(t)
+	second, err := Append(dir, time.Now(), 1)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	links, err := readChain(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || second.Run != 2 || second.Prev != links[0].Hash {
+		t.Errorf("chain = %+v, want second run linked to the first", links)
+	}
+
+	report, err := Verify(context.Background(), dir, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !report.OK() || report.Runs != 2 || report.Checked != 1 {
+		t.Errorf("Verify() = %+v, want 2 runs and 1 intact file", report)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name         string
+		tamper       func(t *testing.T, dir string)
+		wantErr      string
+		wantModified bool
+		wantMissing  bool
+	}{
+		{
+			name: "modified file",
+			tamper: func(t *testing.T, dir string) {
+				writeFile(t, filepath.Join(dir, "report.txt"), "forged report")
+			},
+			wantModified: true,
+		},
+		{
+			name: "deleted file",
+			tamper: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, "report.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantMissing: true,
+		},
+		{
+			name: "edited manifest",
+			tamper: func(t *testing.T, dir string) {
+				data, err := os.ReadFile(manifest.Path(dir))
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, manifest.Path(dir), strings.Replace(string(data), `"rev": "1"`, `"rev": "2"`, 1))
+			},
+			wantErr: "manifest was changed",
+		},
+		{
+			name: "rewritten chain",
+			tamper: func(t *testing.T, dir string) {
+				data, err := os.ReadFile(ChainPath(dir))
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, ChainPath(dir), strings.Replace(string(data), `"files":1`, `"files":2`, 1))
+			},
+			wantErr: "chain is broken",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newArchive(t)
+			tt.tamper(t, dir)
+
+			report, err := Verify(context.Background(), dir, VerifyOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Verify() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if (len(report.Modified) > 0) != tt.wantModified || (len(report.Missing) > 0) != tt.wantMissing {
+				t.Errorf("Verify() = %+v", report)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutChain(t *testing.T) {
+	if _, err := Verify(context.Background(), t.TempDir(), VerifyOptions{}); err == nil {
+		t.Error("Verify() without chain should fail")
+	}
+}
+
+func TestSeal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	writeFile(t, path, "quarterly report")
+
+	if err := Seal(path); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sealed(info) {
+		t.Errorf("mode after Seal() = %v, want read-only", info.Mode())
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	_ = os.Chmod(path, 0644)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,218 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"create-dropbox-backup-folder/internal/compare"
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// errModified marks a file whose content no longer matches the manifest
+var errModified = errors.New("content changed")
+
+// VerifyOptions select which files Verify checks and how
+type VerifyOptions struct {
+	// Percent of the files to check, chosen at random; 0 or 100 and above
+	// check every file
+	Percent float64
+	// RecentDays also checks every file modified on Dropbox within this many
+	// days, whether sampled or not
+	RecentDays int
+	// Compare is the --compare mode files are checked by, as a backup would
+	// decide to keep them; empty hashes every file, the point of verifying.
+	// Other modes trust the recorded revision and only check the size.
+	Compare string
+
+	// Executor runs the checks in parallel and reports progress
+	Executor *transfer.Executor
+	// Now and Rand are replaced in tests
+	Now  time.Time
+	Rand *rand.Rand
+}
+
+// Report is the result of verifying an archive
+type Report struct {
+	// Runs is the number of links in the chain
+	Runs int `json:"runs"`
+	// Checked is the number of files whose content was compared
+	Checked int `json:"checked"`
+	// Skipped is the number of files left out by sampling
+	Skipped int `json:"skipped"`
+	// Modified lists files whose content no longer matches the manifest
+	Modified []string `json:"modified"`
+	// Missing lists files recorded in the manifest that no longer exist
+	Missing []string `json:"missing"`
+}
+
+// OK reports whether no file was modified or missing
+func (r Report) OK() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0
+}
+
+// check is a file selected for verification
+type check struct {
+	localPath string
+	entry     manifest.Entry
+}
+
+// Verify checks the hash chain, that the manifest is the one recorded by the
+// last run, and that the backed-up files selected by opts still have the
+// content recorded in the manifest. A broken chain or changed manifest is an
+// error; changed files are listed in the report.
+func Verify(ctx context.Context, backupDir string, opts VerifyOptions) (Report, error) {
+	links, err := readChain(backupDir)
+	if err != nil {
+		return Report{}, err
+	}
+	if len(links) == 0 {
+		return Report{}, fmt.Errorf("no archive chain in %s", backupDir)
+	}
+
+	prev := ""
+	for _, link := range links {
+		if link.Prev != prev || link.Hash != link.digest() {
+			return Report{}, fmt.Errorf("archive chain is broken at run %d", link.Run)
+		}
+		prev = link.Hash
+	}
+
+	sum, err := fileSHA256(manifest.Path(backupDir))
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to hash manifest: %w", err)
+	}
+	if last := links[len(links)-1]; sum != last.ManifestSHA256 {
+		return Report{}, fmt.Errorf("manifest was changed after run %d", last.Run)
+	}
+
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		return Report{}, err
+	}
+	checks, skipped := selectChecks(m.Entries(), opts)
+
+	report := Report{Runs: len(links), Skipped: skipped}
+	strategy := compare.Strategy{Mode: opts.Compare}
+	if strategy.Mode == "" {
+		strategy.Mode = config.CompareHash
+	}
+	if err := runChecks(ctx, backupDir, checks, strategy, opts.Executor, &report); err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// selectChecks picks the files to verify: a random opts.Percent of them plus
+// every file modified within opts.RecentDays. Exported copies have no
+// comparable hash and are never checked.
+func selectChecks(entries map[string]manifest.Entry, opts VerifyOptions) (checks []check, skipped int) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	recentSince := now.AddDate(0, 0, -opts.RecentDays)
+
+	var candidates []check
+	for key, entry := range entries {
+		if entry.Exported || entry.ContentHash == "" {
+			continue
+		}
+		c := check{localPath: key, entry: entry}
+		if entry.LocalPath != "" {
+			c.localPath = entry.LocalPath
+		}
+
+		if opts.RecentDays > 0 && entry.ModTime.After(recentSince) {
+			checks = append(checks, c)
+		} else {
+			candidates = append(candidates, c)
+		}
+	}
+
+	if opts.Percent <= 0 || opts.Percent >= 100 {
+		return append(checks, candidates...), 0
+	}
+
+	// Sort first so a seeded Rand picks the same files every time
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].localPath < candidates[j].localPath })
+	rng := opts.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	n := int(math.Ceil(float64(len(candidates)) * opts.Percent / 100))
+	return append(checks, candidates[:n]...), len(candidates) - n
+}
+
+// runChecks compares the selected files with their manifest entries by
+// strategy in parallel and adds the outcome to report. Modified and missing
+// files are reported; failing to read a file is an error.
+func runChecks(ctx context.Context, backupDir string, checks []check, strategy compare.Strategy, executor *transfer.Executor, report *Report) error {
+	if executor == nil {
+		executor = transfer.New(transfer.Options{Concurrency: 1})
+	}
+
+	// Indexes into results, which each check writes to exactly once
+	indexes := make([]int, len(checks))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	results := make([]error, len(checks))
+
+	name := func(i int) string { return checks[i].localPath }
+	runErr := transfer.Run(ctx, executor, indexes, name, func(ctx context.Context, i int) error {
+		path := filepath.Join(backupDir, filepath.FromSlash(strings.TrimPrefix(checks[i].localPath, "/")))
+		results[i] = checkFile(path, checks[i].entry, strategy)
+		return results[i]
+	})
+	if ctx.Err() != nil {
+		return runErr
+	}
+
+	for i, err := range results {
+		switch {
+		case err == nil:
+			report.Checked++
+		case errors.Is(err, errModified):
+			report.Modified = append(report.Modified, checks[i].localPath)
+		case errors.Is(err, os.ErrNotExist):
+			report.Missing = append(report.Missing, checks[i].localPath)
+		default:
+			return err
+		}
+	}
+	sort.Strings(report.Modified)
+	sort.Strings(report.Missing)
+	return nil
+}
+
+// checkFile compares a local file with the manifest entry it was recorded by
+// as the Dropbox file it is a copy of; errModified if it no longer matches
+func checkFile(path string, entry manifest.Entry, strategy compare.Strategy) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	local := compare.Local{Path: path, Size: info.Size(), ModTime: info.ModTime(), Rev: entry.Rev, RevSize: int64(entry.Size)}
+	recorded := dropbox.FileInfo{Size: entry.Size, ModTime: entry.ModTime, Rev: entry.Rev, ContentHash: entry.ContentHash}
+	same, err := strategy.Same(local, recorded)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return errModified
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestSelectChecks(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := make(map[string]manifest.Entry)
+	for i := range 100 {
+		entries[fmt.Sprintf("/old/%03d.txt", i)] = manifest.Entry{ContentHash: "h", ModTime: now.AddDate(-1, 0, 0)}
+	}
+	for i := range 5 {
+		entries[fmt.Sprintf("/new/%d.txt", i)] = manifest.Entry{ContentHash: "h", ModTime: now.AddDate(0, 0, -2)}
+	}
+	entries["/notes.paper"] = manifest.Entry{ContentHash: "h", Exported: true}
+	entries["/moved.txt"] = manifest.Entry{ContentHash: "h", ModTime: now.AddDate(-1, 0, 0), LocalPath: "/shared/jane/moved.txt"}
+
+	tests := []struct {
+		name        string
+		opts        VerifyOptions
+		wantChecks  int
+		wantSkipped int
+	}{
+		{name: "all", opts: VerifyOptions{}, wantChecks: 106},
+		{name: "ten percent", opts: VerifyOptions{Percent: 10}, wantChecks: 11, wantSkipped: 95},
+		{name: "ten percent and last week", opts: VerifyOptions{Percent: 10, RecentDays: 7}, wantChecks: 5 + 11, wantSkipped: 90},
+		{name: "recent only", opts: VerifyOptions{Percent: 0.001, RecentDays: 7}, wantChecks: 5 + 1, wantSkipped: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Now = now
+			tt.opts.Rand = rand.New(rand.NewPCG(1, 2))
+			checks, skipped := selectChecks(entries, tt.opts)
+			if len(checks) != tt.wantChecks || skipped != tt.wantSkipped {
+				t.Errorf("selectChecks() = %d checks, %d skipped; want %d, %d", len(checks), skipped, tt.wantChecks, tt.wantSkipped)
+			}
+
+			seen := make(map[string]bool)
+			for _, c := range checks {
+				if seen[c.localPath] {
+					t.Errorf("selectChecks() picked %s twice", c.localPath)
+				}
+				seen[c.localPath] = true
+				if c.localPath == "/moved.txt" || c.localPath == "/notes.paper" {
+					t.Errorf("selectChecks() picked %s", c.localPath)
+				}
+			}
+			if tt.opts.RecentDays > 0 && !seen["/new/0.txt"] {
+				t.Error("selectChecks() left out a recently modified file")
+			}
+		})
+	}
+}
+
+func TestVerifyParallelWithProgress(t *testing.T) {
+	dir := t.TempDir()
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 20 {
+		content := []byte(fmt.Sprintf("file %d", i))
+		name := fmt.Sprintf("f%02d.txt", i)
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := dropbox.ContentHash(bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Set("/"+name, manifest.Entry{Rev: "1", ContentHash: hash})
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Append(dir, time.Now(), m.Len()); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "f07.txt"), "forged")
+
+	var progress bytes.Buffer
+	opts := VerifyOptions{Executor: transfer.New(transfer.Options{Concurrency: 4, Progress: &progress})}
+	report, err := Verify(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if report.Checked != 19 || len(report.Modified) != 1 || report.Modified[0] != "/f07.txt" {
+		t.Errorf("Verify() = %+v, want 19 intact and /f07.txt modified", report)
+	}
+	if lines := bytes.Count(progress.Bytes(), []byte("\n")); lines != 20 {
+		t.Errorf("progress lines = %d, want 20", lines)
+	}
+}
+
+func TestVerifyCompareModes(t *testing.T) {
+	dir := t.TempDir()
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"intact.txt", "edited.txt", "truncated.txt"} {
+		content := []byte("original")
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := dropbox.ContentHash(bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Set("/"+name, manifest.Entry{Rev: "1", Size: uint64(len(content)), ContentHash: hash})
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Append(dir, time.Now(), m.Len()); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "edited.txt"), "0riginal")
+	writeFile(t, filepath.Join(dir, "truncated.txt"), "orig")
+
+	tests := []struct {
+		compare      string
+		wantModified []string
+	}{
+		{compare: "", wantModified: []string{"/edited.txt", "/truncated.txt"}},
+		{compare: config.CompareHash, wantModified: []string{"/edited.txt", "/truncated.txt"}},
+		// Trust the recorded revision of a copy of its size, as a backup would
+		{compare: config.CompareRev, wantModified: []string{"/truncated.txt"}},
+		{compare: config.CompareMtimeSize, wantModified: []string{"/truncated.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.compare, func(t *testing.T) {
+			report, err := Verify(context.Background(), dir, VerifyOptions{Compare: tt.compare})
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if !slices.Equal(report.Modified, tt.wantModified) {
+				t.Errorf("Verify() modified = %v, want %v", report.Modified, tt.wantModified)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// errAccountSwitch is returned when the backup directory holds another
+// Dropbox account's files than the token's
+var errAccountSwitch = errors.New("backup directory belongs to another Dropbox account")
+
+// checkAccount refuses to back up into a directory recorded for another
+// Dropbox account unless --force-account-switch is set: the other account's
+// files would be mixed with this one's, and removed with --delete. A
+// directory without a recorded account is adopted by this run.
+func (e *Engine) checkAccount(info *dropbox.AccountInfo) error {
+	e.account = manifest.Account{ID: info.AccountID, Email: info.Email}
+
+	recorded, ok, err := manifest.ReadAccount(e.config.BackupDir)
+	if err != nil || !ok {
+		return err
+	}
+	return checkAccountSwitch(recorded, e.account, e.config.ForceAccountSwitch, e.config.Delete)
+}
+
+// checkAccountSwitch compares the recorded account of a backup directory
+// with the current one
+func checkAccountSwitch(recorded, current manifest.Account, force, deleting bool) error {
+	if recorded.ID == current.ID {
+		return nil
+	}
+
+	if force {
+		slog.Warn("Backing up into a directory holding another Dropbox account's files",
+			slog.String("recorded_account", recorded.String()),
+			slog.String("account", current.String()),
+			slog.Bool("delete", deleting),
+		)
+		return nil
+	}
+
+	hint := "use another --backup-dir, or --force-account-switch to take it over"
+	if deleting {
+		hint = "--delete would remove its files; " + hint
+	}
+	return fmt.Errorf("%w: it holds %s, the token is for %s; %s", errAccountSwitch, recorded, current, hint)
+}
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+func TestCheckAccountSwitch(t *testing.T) {
+	alice := manifest.Account{ID: "dbid:alice", Email: "alice@example.com"}
+	bob := manifest.Account{ID: "dbid:bob", Email: "bob@example.com"}
+
+	tests := []struct {
+		name            string
+		current         manifest.Account
+		force, deleting bool
+		wantErr         bool
+		wantHint        string
+	}{
+		{name: "same account", current: alice},
+		{name: "same account, new email", current: manifest.Account{ID: alice.ID, Email: "new@example.com"}},
+		{name: "other account", current: bob, wantErr: true, wantHint: "--force-account-switch"},
+		{name: "other account with delete", current: bob, deleting: true, wantErr: true, wantHint: "--delete would remove"},
+		{name: "forced", current: bob, force: true, deleting: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAccountSwitch(alice, tt.current, tt.force, tt.deleting)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkAccountSwitch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, errAccountSwitch) {
+				t.Errorf("error = %v, want errAccountSwitch", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantHint) || !strings.Contains(err.Error(), alice.Email) {
+				t.Errorf("error = %q, want %q and the recorded account", err, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestCheckAccount(t *testing.T) {
+	dir := t.TempDir()
+	e := &Engine{config: &config.Config{BackupDir: dir}}
+
+	// A directory without a manifest is adopted
+	if err := e.checkAccount(&dropbox.AccountInfo{AccountID: "dbid:alice"}); err != nil {
+		t.Fatalf("checkAccount() on a new directory = %v", err)
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetAccount(e.account)
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.checkAccount(&dropbox.AccountInfo{AccountID: "dbid:alice"}); err != nil {
+		t.Errorf("checkAccount() with the recorded account = %v", err)
+	}
+	if err := e.checkAccount(&dropbox.AccountInfo{AccountID: "dbid:bob"}); !errors.Is(err, errAccountSwitch) {
+		t.Errorf("checkAccount() with another account = %v, want errAccountSwitch", err)
+	}
+}
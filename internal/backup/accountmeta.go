@@ -0,0 +1,32 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+
+	"create-dropbox-backup-folder/internal/accountmeta"
+)
+
+// exportAccountMetadata writes the account metadata bundle to the backup root
+func (e *Engine) exportAccountMetadata(ctx context.Context) error {
+	bundle, err := accountmeta.Export(ctx, e.dropboxClient, e.now())
+	if err != nil {
+		return err
+	}
+
+	if e.config.DryRun {
+		e.messages().Message("[dry-run] write account metadata (%d file requests, %d connected apps)",
+			len(bundle.FileRequests), len(bundle.ConnectedApps))
+		return nil
+	}
+	if err := bundle.Save(e.config.BackupDir); err != nil {
+		return err
+	}
+
+	slog.Info("Exported account metadata",
+		slog.String("path", accountmeta.Path(e.config.BackupDir)),
+		slog.Int("file_requests", len(bundle.FileRequests)),
+		slog.Int("connected_apps", len(bundle.ConnectedApps)),
+	)
+	return nil
+}
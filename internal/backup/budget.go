@@ -0,0 +1,48 @@
+package backup
+
+import (
+	"fmt"
+
+	"create-dropbox-backup-folder/internal/budget"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// BudgetError stops a run when the daily API call budget is spent, leaving
+// the rest of the downloads to the next run
+type BudgetError struct {
+	// RemainingFiles and RemainingBytes describe the files not downloaded.
+	// Some of them may turn out to be up to date, so this is an upper bound.
+	RemainingFiles int
+	RemainingBytes uint64
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%v: %d files (up to %s) left for the next run",
+		budget.ErrExhausted, e.RemainingFiles, formatBytes(e.RemainingBytes))
+}
+
+func (e *BudgetError) Unwrap() error {
+	return budget.ErrExhausted
+}
+
+// newBudgetError describes the downloads that didn't finish
+func newBudgetError(downloads []dropbox.FileInfo, finished []bool) *BudgetError {
+	exhausted := &BudgetError{}
+	for i, file := range downloads {
+		if !finished[i] {
+			exhausted.RemainingFiles++
+			exhausted.RemainingBytes += file.Size
+		}
+	}
+	return exhausted
+}
+
+// recordBudget adds the API calls of the run to the statistics, along with
+// the usage to carry over to the next run
+func (e *Engine) recordBudget(stats *Stats) {
+	if e.budget == nil {
+		return
+	}
+	stats.APICalls = e.budget.Calls()
+	stats.APIUsage = e.budget.Usage()
+}
@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/budget"
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestNewBudgetError(t *testing.T) {
+	downloads := []dropbox.FileInfo{
+		{Path: "/a.txt", Size: 100},
+		{Path: "/b.bin", Size: 2048},
+		{Path: "/c.bin", Size: 1024},
+	}
+	err := newBudgetError(downloads, []bool{true, false, true})
+
+	if err.RemainingFiles != 1 || err.RemainingBytes != 2048 {
+		t.Errorf("remaining = %d files, %d bytes; want 1, 2048", err.RemainingFiles, err.RemainingBytes)
+	}
+	if !errors.Is(err, budget.ErrExhausted) {
+		t.Error("BudgetError should wrap budget.ErrExhausted")
+	}
+}
+
+func TestDownloadFilesStopsWhenBudgetSpent(t *testing.T) {
+	client, err := dropbox.New("id", "secret", "token", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent := budget.New(5, budget.Usage{time.Now().Truncate(time.Hour).Unix(): 5})
+	client.SetBudget(spent)
+	engine := &Engine{
+		config:        &config.Config{BackupDir: t.TempDir()},
+		dropboxClient: client,
+		transfers:     transfer.New(transfer.Options{Concurrency: 1}),
+		budget:        spent,
+	}
+	downloads := []dropbox.FileInfo{
+		{Path: "/a.txt", Name: "a.txt", Size: 100},
+		{Path: "/b.bin", Name: "b.bin", Size: 2048},
+	}
+
+	stats := &Stats{}
+	err = engine.downloadFiles(context.Background(), downloads, stats)
+
+	var exhausted *BudgetError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("downloadFiles() error = %v, want a BudgetError", err)
+	}
+	if exhausted.RemainingFiles != 2 || exhausted.RemainingBytes != 2148 {
+		t.Errorf("remaining = %d files, %d bytes; want 2, 2148", exhausted.RemainingFiles, exhausted.RemainingBytes)
+	}
+	if stats.FailedFiles != 0 {
+		t.Errorf("failed = %d, want none for files left to the next run", stats.FailedFiles)
+	}
+}
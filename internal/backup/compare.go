@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"create-dropbox-backup-folder/internal/compare"
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// shouldSkipFile reports whether the local copy of remoteFile is already up
+// to date by the configured comparison strategy, see compare.Strategy. The
+// Dropbox revision recorded in the manifest at download time changes
+// whenever the content does and costs nothing to check, so it decides unless
+// --compare is hash. Exported copies never match the remote size, mtime or
+// hash and are only compared by revision.
+//
+// With --xattr the revision a local copy was tagged with stands in for a
+// missing manifest entry. With --sidecar the modification time recorded in
+// the directory's sidecar file stands in for the local one. Local times are
+// corrected for a skewed clock, see detectClockSkew.
+//
+// A missing or unreadable local file is never skipped.
+func (e *Engine) shouldSkipFile(localPath string, remoteFile dropbox.FileInfo) bool {
+	stat, err := e.fsys().Stat(localPath)
+	if err != nil {
+		return false // File doesn't exist, don't skip
+	}
+
+	local := compare.Local{Path: localPath, Size: stat.Size(), ModTime: e.localModTime(localPath, stat)}
+	local.Rev, local.RevSize = e.recordedRevision(localPath, stat, remoteFile)
+	if local.Rev == "" {
+		local.Rev, local.RevSize = e.taggedRevision(localPath, stat, remoteFile)
+	}
+
+	strategy := e.strategy()
+	if remoteFile.ExportAs != "" {
+		strategy = compare.Strategy{Mode: config.CompareRev}
+	}
+	skip, err := strategy.UpToDate(local, remoteFile)
+	if err != nil {
+		slog.Warn("Failed to compare local file",
+			slog.String("path", localPath),
+			slog.String("error", err.Error()),
+		)
+		return false
+	}
+	return skip
+}
+
+// strategy returns the comparison strategy of this backup
+func (e *Engine) strategy() compare.Strategy {
+	return compare.Strategy{
+		Mode:   e.config.Compare,
+		Verify: e.config.Verify,
+		Skew:   e.skew,
+		Open:   func(path string) (io.ReadCloser, error) { return e.fsys().Open(path) },
+	}
+}
+
+// recordedRevision returns the revision recorded for a local copy at its last
+// download and the size it had; rev is empty if none was recorded. A file
+// recorded under another key, e.g. before its shared folder was remounted
+// elsewhere, is found by its local path.
+func (e *Engine) recordedRevision(localPath string, stat os.FileInfo, remoteFile dropbox.FileInfo) (rev string, size int64) {
+	if e.manifest == nil {
+		return "", 0
+	}
+	entry, ok := e.manifest.Get(manifest.Key(remoteFile.Namespace, remoteFile.Path))
+	if !ok {
+		_, entry, ok = e.manifest.Resolve(e.relPath(localPath))
+	}
+	if !ok {
+		return "", 0
+	}
+	return entry.Rev, int64(entry.Size)
+}
+
+// intact reports whether a local copy of the recorded revision still has its
+// content; only checked with --verify, as it reads the whole file
+func (e *Engine) intact(localPath string, remoteFile dropbox.FileInfo) bool {
+	if !e.config.Verify || remoteFile.ExportAs != "" || remoteFile.ContentHash == "" {
+		return true
+	}
+	if e.sameContentHash(localPath, remoteFile) {
+		return true
+	}
+	slog.Warn("Local copy no longer matches Dropbox, downloading it again", slog.String("path", localPath))
+	return false
+}
+
+// sameContentHash reports whether a local copy has the Dropbox content hash
+// of remoteFile, as the hash comparison does
+func (e *Engine) sameContentHash(localPath string, remoteFile dropbox.FileInfo) bool {
+	strategy := compare.Strategy{Mode: config.CompareHash, Open: e.strategy().Open}
+	same, err := strategy.Same(compare.Local{Path: localPath}, remoteFile)
+	if err != nil {
+		slog.Warn("Failed to hash local file",
+			slog.String("path", localPath),
+			slog.String("error", err.Error()),
+		)
+		return false
+	}
+	return same
+}
+
+// detectClockSkew compares the local clock with Dropbox's. Beyond
+// --max-clock-skew, the "local file is newer" rule of the mtime,size
+// comparison would silently skip or download the wrong files, so a warning
+// is logged and local times are corrected by the skew from now on.
+func (e *Engine) detectClockSkew(ctx context.Context, stats *Stats) {
+	if e.config.MaxClockSkew <= 0 {
+		return
+	}
+	skew, err := e.dropboxClient.ClockSkew(ctx)
+	if err != nil {
+		slog.Warn("Failed to check the local clock against Dropbox", slog.String("error", err.Error()))
+		return
+	}
+	if skew.Abs() <= e.config.MaxClockSkew {
+		slog.Debug("Local clock agrees with Dropbox", slog.Duration("skew", skew))
+		return
+	}
+	slog.Warn("Local clock is off from Dropbox, correcting modification time comparisons; check the time synchronization of this computer",
+		slog.Duration("skew", skew),
+		slog.Duration("max_clock_skew", e.config.MaxClockSkew),
+	)
+	e.skew = skew
+	stats.ClockSkew = skew
+}
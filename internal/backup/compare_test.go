@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/localfs/localfstest"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+func TestShouldSkipFileCompareModes(t *testing.T) {
+	tempDir := t.TempDir()
+
+	content := []byte("test content")
+	testFile := filepath.Join(tempDir, "test.txt")
+	if err := os.WriteFile(testFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matchingHash, err := dropbox.ContentHash(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherHash, err := dropbox.ContentHash(bytes.NewReader([]byte("other content")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded, err := manifest.Load(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded.Set("/test.txt", manifest.Entry{Rev: "rev1", Size: uint64(len(content))})
+
+	tests := []struct {
+		name     string
+		compare  string
+		verify   bool
+		manifest *manifest.Manifest
+		remote   dropbox.FileInfo
+		want     bool
+	}{
+		{
+			name:    "mtime,size ignores content hash",
+			compare: config.CompareMtimeSize,
+			remote:  dropbox.FileInfo{ModTime: stat.ModTime(), ContentHash: otherHash},
+			want:    false, // size differs (0 vs 12)
+		},
+		{
+			name:    "hash matches",
+			compare: config.CompareHash,
+			remote:  dropbox.FileInfo{ModTime: stat.ModTime().Add(time.Hour), ContentHash: matchingHash},
+			want:    true,
+		},
+		{
+			name:    "hash differs despite same mtime and size",
+			compare: config.CompareHash,
+			remote:  dropbox.FileInfo{Size: uint64(len(content)), ModTime: stat.ModTime(), ContentHash: otherHash},
+			want:    false,
+		},
+		{
+			name:    "hash missing on remote",
+			compare: config.CompareHash,
+			remote:  dropbox.FileInfo{Size: uint64(len(content)), ModTime: stat.ModTime()},
+			want:    false,
+		},
+		{
+			name:     "rev matches recorded revision",
+			compare:  config.CompareRev,
+			manifest: recorded,
+			remote:   dropbox.FileInfo{Path: "/test.txt", Size: uint64(len(content)), Rev: "rev1", ContentHash: otherHash},
+			want:     true,
+		},
+		{
+			name:     "rev changed",
+			compare:  config.CompareRev,
+			manifest: recorded,
+			remote:   dropbox.FileInfo{Path: "/test.txt", Size: uint64(len(content)), Rev: "rev2", ContentHash: matchingHash},
+			want:     false,
+		},
+		{
+			name:     "recorded rev takes precedence over mtime,size",
+			compare:  config.CompareMtimeSize,
+			manifest: recorded,
+			remote:   dropbox.FileInfo{Path: "/test.txt", Size: uint64(len(content)), ModTime: stat.ModTime(), Rev: "rev2"},
+			want:     false,
+		},
+		{
+			name:     "hash mode ignores recorded rev",
+			compare:  config.CompareHash,
+			manifest: recorded,
+			remote:   dropbox.FileInfo{Path: "/test.txt", Size: uint64(len(content)), Rev: "rev1", ContentHash: otherHash},
+			want:     false,
+		},
+		{
+			name:    "rev downloads without recorded revisions",
+			compare: config.CompareRev,
+			remote:  dropbox.FileInfo{Path: "/test.txt", Size: uint64(len(content)), ModTime: stat.ModTime(), Rev: "rev2", ContentHash: matchingHash},
+			want:    false,
+		},
+		{
+			name:     "verify catches corrupted copy of recorded rev",
+			compare:  config.CompareMtimeSize,
+			verify:   true,
+			manifest: recorded,
+			remote:   dropbox.FileInfo{Path: "/test.txt", Size: uint64(len(content)), Rev: "rev1", ContentHash: otherHash},
+			want:     false,
+		},
+		{
+			name:     "verify keeps intact copy of recorded rev",
+			compare:  config.CompareMtimeSize,
+			verify:   true,
+			manifest: recorded,
+			remote:   dropbox.FileInfo{Path: "/test.txt", Size: uint64(len(content)), Rev: "rev1", ContentHash: matchingHash},
+			want:     true,
+		},
+		{
+			name:    "verify hashes instead of mtime,size",
+			compare: config.CompareMtimeSize,
+			verify:  true,
+			remote:  dropbox.FileInfo{Size: uint64(len(content)), ModTime: stat.ModTime(), ContentHash: otherHash},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.remote.Name = "test.txt"
+			engine := &Engine{
+				config:   &config.Config{BackupDir: tempDir, Compare: tt.compare, Verify: tt.verify},
+				manifest: tt.manifest,
+			}
+			if got := engine.shouldSkipFile(testFile, tt.remote); got != tt.want {
+				t.Errorf("shouldSkipFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldSkipFileSimulatedModTime(t *testing.T) {
+	localPath := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(localPath, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	remoteTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	remote := dropbox.FileInfo{Path: "/report.pdf", Size: 7, ModTime: remoteTime}
+
+	tests := []struct {
+		name         string
+		localModTime time.Time
+		want         bool
+	}{
+		{name: "same time", localModTime: remoteTime, want: true},
+		{name: "local newer", localModTime: remoteTime.Add(time.Minute), want: true},
+		{name: "local older", localModTime: remoteTime.Add(-time.Minute), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := localfstest.New()
+			fsys.SetModTime(localPath, tt.localModTime)
+			engine := &Engine{config: &config.Config{Compare: config.CompareMtimeSize}, fs: fsys}
+
+			if got := engine.shouldSkipFile(localPath, remote); got != tt.want {
+				t.Errorf("shouldSkipFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"create-dropbox-backup-folder/internal/coord"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// deleteLockTTL bounds how long a crashed instance blocks the delete phase
+// of the others
+const deleteLockTTL = 2 * time.Hour
+
+// logPeers reports what other instances sharing the state backend did last
+func (e *Engine) logPeers(ctx context.Context) {
+	if e.coord == nil {
+		return
+	}
+
+	statuses, err := e.coord.Instances(ctx)
+	if err != nil {
+		slog.Warn("Failed to read shared instance state", slog.String("error", err.Error()))
+		return
+	}
+	for name, status := range statuses {
+		if name == e.coord.Instance() {
+			continue
+		}
+		slog.Info("Other instance",
+			slog.String("instance", name),
+			slog.String("host", status.Host),
+			slog.Time("last_run", status.LastRun),
+			slog.Bool("success", status.Success),
+		)
+	}
+}
+
+// publishStatus shares the result of this run with the other instances
+func (e *Engine) publishStatus(ctx context.Context, stats *Stats, runErr error) {
+	if e.coord == nil || e.config.DryRun {
+		return
+	}
+
+	// Publish even if the run was cancelled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	err := e.coord.Publish(ctx, coord.Status{
+		BackupDir: e.config.BackupDir,
+		LastRun:   stats.EndTime,
+		Success:   runErr == nil,
+		Files:     stats.DownloadedFiles + stats.SkippedFiles,
+		Bytes:     stats.TotalBytes,
+	})
+	if err != nil {
+		slog.Warn("Failed to publish instance status", slog.String("error", err.Error()))
+	}
+}
+
+// deletePhase removes orphaned local files while holding the shared delete
+// lock, so instances never delete at the same time. If another instance holds
+// the lock the phase is skipped until the next run.
+func (e *Engine) deletePhase(ctx context.Context, files []dropbox.FileInfo, stats *Stats) error {
+	if e.coord != nil && !e.config.DryRun {
+		release, err := e.coord.TryLock(ctx, "delete", deleteLockTTL)
+		if errors.Is(err, coord.ErrLocked) {
+			slog.Warn("Skipping delete phase", slog.String("reason", err.Error()))
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock delete phase: %w", err)
+		}
+		defer release()
+	}
+
+	return e.deleteOrphanedFiles(ctx, files, stats)
+}
@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/coord"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+func TestDeletePhaseLock(t *testing.T) {
+	tests := []struct {
+		name        string
+		otherHolds  bool
+		wantDeleted int
+	}{
+		{name: "lock free", wantDeleted: 1},
+		{name: "held by other instance", otherHolds: true, wantDeleted: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backupDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(backupDir, "orphan.txt"), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			backend, err := coord.NewFileBackend(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.otherHolds {
+				if _, err := coord.New(backend, "offsite").TryLock(ctx, "delete", time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			m, err := manifest.Load(backupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := &Engine{
+				config:   &config.Config{BackupDir: backupDir, Delete: true},
+				manifest: m,
+				coord:    coord.New(backend, "nas"),
+			}
+
+			stats := &Stats{}
+			if err := engine.deletePhase(ctx, nil, stats); err != nil {
+				t.Fatalf("deletePhase() error = %v", err)
+			}
+			if stats.DeletedFiles != tt.wantDeleted {
+				t.Errorf("DeletedFiles = %d, want %d", stats.DeletedFiles, tt.wantDeleted)
+			}
+
+			// The lock is released afterwards
+			if !tt.otherHolds {
+				if _, err := coord.New(backend, "offsite").TryLock(ctx, "delete", time.Hour); err != nil {
+					t.Errorf("delete lock not released: %v", err)
+				}
+			}
+		})
+	}
+}
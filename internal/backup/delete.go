@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"create-dropbox-backup-folder/internal/logbatch"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// deleteWorkers is how many orphaned files are removed at once. Removing is
+// cheap for the disk, but after a large reorganization on Dropbox there may
+// be hundreds of thousands of them, mostly waiting on file system latency,
+// e.g. on a NAS share.
+const deleteWorkers = 8
+
+// orphan is a local file no longer on Dropbox. shared is set if its content
+// stays on disk after it is deleted, as other hard links to it remain.
+type orphan struct {
+	path   string
+	size   uint64
+	shared bool
+}
+
+// markShared flags the orphans whose content is still referenced by hard
+// links that aren't deleted, e.g. from snapshots made with "cp -al" or
+// rsnapshot. Of several orphans linked to the same content, only one frees
+// it, and only if no other link remains.
+func markShared(orphans []orphan, infos []os.FileInfo) {
+	type inode struct {
+		links   uint64
+		orphans []int
+	}
+	inodes := make(map[string]*inode)
+	for i, info := range infos {
+		links, id, ok := fileLinks(info)
+		if !ok || links <= 1 {
+			continue
+		}
+		n := inodes[id]
+		if n == nil {
+			n = &inode{links: links}
+			inodes[id] = n
+		}
+		n.orphans = append(n.orphans, i)
+	}
+	for _, n := range inodes {
+		freed := uint64(len(n.orphans)) >= n.links
+		for j, i := range n.orphans {
+			orphans[i].shared = !freed || j > 0
+		}
+	}
+}
+
+// deleteOrphans removes orphaned files in parallel, logging the progress
+// while it takes a while, and forgets them. In a dry run they are only
+// reported.
+func (e *Engine) deleteOrphans(ctx context.Context, orphans []orphan, stats *Stats) error {
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	// Per-file logs are summarized with --log-batch
+	ctx = logbatch.PerFile(ctx)
+	if e.config.DryRun {
+		for _, o := range orphans {
+			e.messages().Message("[dry-run] delete %s", o.path)
+			e.record(stats, Result{Path: o.path, Action: ActionDeleted, Bytes: o.size, Shared: o.shared, DryRun: true})
+		}
+		return nil
+	}
+
+	var total uint64
+	for _, o := range orphans {
+		total += o.size
+	}
+	slog.Info("Found orphaned files",
+		slog.Int("files", len(orphans)),
+		slog.String("size", formatBytes(total)),
+	)
+
+	executor := transfer.New(transfer.Options{Concurrency: deleteWorkers})
+	if e.transfers != nil {
+		executor = e.transfers.Lane(deleteWorkers)
+	}
+
+	var deleted atomic.Int64
+	stop := e.watchDeletes(&deleted, len(orphans))
+	defer stop()
+
+	name := func(o orphan) string { return o.path }
+	return transfer.Run(ctx, executor, orphans, name, func(ctx context.Context, o orphan) error {
+		slog.InfoContext(ctx, "Deleting orphaned file", slog.String("path", o.path))
+		if err := e.fsys().Remove(o.path); err != nil {
+			return fmt.Errorf("failed to delete file %s: %w", o.path, err)
+		}
+		if err := e.deleteFromMirrors(o.path); err != nil {
+			return err
+		}
+		e.forgetFile(o.path)
+		e.rememberChange(o.path, true)
+		e.record(stats, Result{Path: o.path, Action: ActionDeleted, Bytes: o.size, Shared: o.shared})
+		deleted.Add(1)
+		return nil
+	})
+}
+
+// watchDeletes logs how many of total files are deleted at the progress
+// interval until the returned function is called
+func (e *Engine) watchDeletes(deleted *atomic.Int64, total int) (stop func()) {
+	interval := e.config.ProgressInterval
+	if interval <= 0 {
+		interval = listProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				slog.Info("Deleting orphaned files",
+					slog.Int64("deleted", deleted.Load()),
+					slog.Int("total", total),
+				)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
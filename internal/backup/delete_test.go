@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestDeleteOrphansInParallel(t *testing.T) {
+	tempDir := t.TempDir()
+	var files []dropbox.FileInfo
+	for i := range 60 {
+		dir := filepath.Join(tempDir, fmt.Sprintf("dir%d", i%6))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("file%d.txt", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Repeat("x", 10)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Every third file is still on Dropbox
+		if i%3 == 0 {
+			files = append(files, dropbox.FileInfo{Path: fmt.Sprintf("/dir%d/%s", i%6, name), Name: name})
+		}
+	}
+
+	engine := &Engine{
+		config:    &config.Config{BackupDir: tempDir, Delete: true},
+		transfers: transfer.New(transfer.Options{Concurrency: 2}),
+	}
+	stats := &Stats{}
+	if err := engine.deleteOrphanedFiles(t.Context(), files, stats); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+
+	if stats.DeletedFiles != 40 || stats.DeletedBytes != 400 {
+		t.Errorf("deleted %d files (%d bytes), want 40 (400 bytes)", stats.DeletedFiles, stats.DeletedBytes)
+	}
+	remaining := 0
+	filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			remaining++
+		}
+		return nil
+	})
+	if remaining != len(files) {
+		t.Errorf("%d files left, want %d", remaining, len(files))
+	}
+}
+
+func TestDeleteOrphansDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	orphanPath := filepath.Join(tempDir, "orphan.txt")
+	if err := os.WriteFile(orphanPath, []byte("12345"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &Engine{config: &config.Config{BackupDir: tempDir, Delete: true, DryRun: true}}
+	stats := &Stats{}
+	if err := engine.deleteOrphanedFiles(t.Context(), nil, stats); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+
+	if stats.DeletedFiles != 1 || stats.DeletedBytes != 5 {
+		t.Errorf("deleted %d files (%d bytes), want 1 (5 bytes)", stats.DeletedFiles, stats.DeletedBytes)
+	}
+	if _, err := os.Stat(orphanPath); err != nil {
+		t.Errorf("dry run removed the file: %v", err)
+	}
+}
+
+func TestDeleteOrphansHardLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("link counts aren't known on Windows")
+	}
+	tempDir := t.TempDir()
+	backupDir := filepath.Join(tempDir, "backup")
+	snapshot := filepath.Join(tempDir, "snapshot")
+	for _, dir := range []string{backupDir, snapshot} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(from, to string) {
+		t.Helper()
+		if err := os.Link(from, to); err != nil {
+			t.Skipf("hard links not supported: %v", err)
+		}
+	}
+
+	// Only in the backup: freed
+	write(filepath.Join(backupDir, "alone.txt"), "1")
+	// Also in a snapshot: not freed
+	write(filepath.Join(backupDir, "snapshotted.txt"), "22")
+	link(filepath.Join(backupDir, "snapshotted.txt"), filepath.Join(snapshot, "snapshotted.txt"))
+	// Two orphans linked to each other only: freed once
+	write(filepath.Join(backupDir, "a.txt"), "4444")
+	link(filepath.Join(backupDir, "a.txt"), filepath.Join(backupDir, "b.txt"))
+
+	engine := &Engine{config: &config.Config{BackupDir: backupDir, Delete: true}}
+	stats := &Stats{}
+	if err := engine.deleteOrphanedFiles(t.Context(), nil, stats); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+
+	if stats.DeletedFiles != 4 || stats.DeletedBytes != 11 {
+		t.Errorf("deleted %d files (%d bytes), want 4 (11 bytes)", stats.DeletedFiles, stats.DeletedBytes)
+	}
+	if stats.FreedBytes != 5 {
+		t.Errorf("FreedBytes = %d, want 5", stats.FreedBytes)
+	}
+	if _, err := os.Stat(filepath.Join(snapshot, "snapshotted.txt")); err != nil {
+		t.Errorf("snapshot copy removed: %v", err)
+	}
+}
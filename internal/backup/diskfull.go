@@ -0,0 +1,40 @@
+package backup
+
+import (
+	"fmt"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// DiskFullError stops a run when the backup disk runs out of space. It
+// reports what was left to download, so the user knows how much space to free.
+type DiskFullError struct {
+	// RemainingFiles and RemainingBytes describe the files not downloaded.
+	// Some of them may turn out to be up to date, so this is an upper bound.
+	RemainingFiles int
+	RemainingBytes uint64
+
+	// Err is the write error that revealed the full disk
+	Err error
+}
+
+func (e *DiskFullError) Error() string {
+	return fmt.Sprintf("backup disk is full: %d files not downloaded, up to %s more space needed: %v",
+		e.RemainingFiles, formatBytes(e.RemainingBytes), e.Err)
+}
+
+func (e *DiskFullError) Unwrap() error {
+	return e.Err
+}
+
+// newDiskFullError describes the downloads that didn't finish
+func newDiskFullError(downloads []dropbox.FileInfo, finished []bool, err error) *DiskFullError {
+	diskFull := &DiskFullError{Err: err}
+	for i, file := range downloads {
+		if !finished[i] {
+			diskFull.RemainingFiles++
+			diskFull.RemainingBytes += file.Size
+		}
+	}
+	return diskFull
+}
@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/localfs/localfstest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestNewDiskFullError(t *testing.T) {
+	writeErr := &os.PathError{Op: "write", Path: "/backup/b.bin", Err: diskFullErrors[0]}
+	downloads := []dropbox.FileInfo{
+		{Path: "/a.txt", Size: 100},
+		{Path: "/b.bin", Size: 2048},
+		{Path: "/c.bin", Size: 1024},
+	}
+
+	err := newDiskFullError(downloads, []bool{true, false, false}, writeErr)
+	if err.RemainingFiles != 2 || err.RemainingBytes != 3072 {
+		t.Errorf("remaining = %d files, %d bytes; want 2, 3072", err.RemainingFiles, err.RemainingBytes)
+	}
+	if !strings.Contains(err.Error(), "2 files not downloaded, up to 3.0 KB more space needed") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if !errors.Is(err, diskFullErrors[0]) {
+		t.Error("DiskFullError should wrap the write error")
+	}
+}
+
+func TestDownloadFilesStopsWhenDiskFull(t *testing.T) {
+	fsys := localfstest.New()
+	fsys.Fail(localfstest.OpMkdir, "", diskFullErrors[0])
+	engine := &Engine{
+		config:    &config.Config{BackupDir: t.TempDir()},
+		transfers: transfer.New(transfer.Options{Concurrency: 1}),
+		fs:        fsys,
+	}
+	downloads := []dropbox.FileInfo{
+		{Path: "/a.txt", Name: "a.txt", Size: 100},
+		{Path: "/b.bin", Name: "b.bin", Size: 2048},
+		{Path: "/c.bin", Name: "c.bin", Size: 1024},
+	}
+
+	stats := &Stats{}
+	err := engine.downloadFiles(context.Background(), downloads, stats)
+
+	var diskFull *DiskFullError
+	if !errors.As(err, &diskFull) {
+		t.Fatalf("downloadFiles() error = %v, want a DiskFullError", err)
+	}
+	if diskFull.RemainingFiles != 3 || diskFull.RemainingBytes != 3172 {
+		t.Errorf("remaining = %d files, %d bytes; want 3, 3172", diskFull.RemainingFiles, diskFull.RemainingBytes)
+	}
+	if stats.FailedFiles == 0 || stats.Failures[FailureDiskFull] != stats.FailedFiles {
+		t.Errorf("failed = %d, failures = %v; want disk full failures", stats.FailedFiles, stats.Failures)
+	}
+}
@@ -2,50 +2,181 @@ package backup
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"create-dropbox-backup-folder/internal/accountmeta"
+	"create-dropbox-backup-folder/internal/archive"
+	"create-dropbox-backup-folder/internal/budget"
 	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/coord"
 	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/hook"
+	"create-dropbox-backup-folder/internal/listing"
+	"create-dropbox-backup-folder/internal/localfs"
+	"create-dropbox-backup-folder/internal/logbatch"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/metrics"
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/retry"
+	"create-dropbox-backup-folder/internal/sidecar"
+	"create-dropbox-backup-folder/internal/signing"
+	"create-dropbox-backup-folder/internal/transfer"
+
+	"golang.org/x/oauth2"
 )
 
 // Engine handles the backup process
 type Engine struct {
 	config        *config.Config
 	dropboxClient *dropbox.Client
-	semaphore     chan struct{}
+	transfers     *transfer.Executor
+	manifest      *manifest.Manifest
+
+	// namespaceClients are clients for namespaces other than the home
+	// namespace (e.g. a team space), by namespace ID
+	namespaceClients map[string]*dropbox.Client
+
+	// sharedRoots maps the manifest keys of shared folder mount points to
+	// their local directories with the shared layout; see resolveSharedLayout
+	sharedRoots map[string]string
+
+	// downloads are the files written by this run, kept for --verify-after
+	downloads   []download
+	downloadsMu sync.Mutex
+
+	// skips are a random sample of skipsSeen files skipped by this run, kept
+	// for --spot-check; see rememberSkip
+	skips     []skip
+	skipsSeen int
+	skipsMu   sync.Mutex
+
+	// parts are the parts left by interrupted downloads by directory and
+	// target file, each directory read once per run; see removeParts
+	parts   map[string]map[string][]string
+	partsMu sync.Mutex
+
+	// changes are the files written and deleted by this run, kept for the
+	// post-run command
+	changes   hook.Changes
+	changesMu sync.Mutex
+
+	// out writes the messages of a run for people, e.g. the downloads of a
+	// dry run, in the selected output format; nil writes none, see messages
+	out output.Formatter
+
+	// onResult receives the result of every file; see OnResult
+	onResult func(Result)
+	// resultsMu serializes recording results into Stats during transfers
+	resultsMu sync.Mutex
+
+	// xattrUnsupported is set once tagging failed because the platform or
+	// file system has no extended attributes, so the warning is shown once
+	xattrUnsupported atomic.Bool
+
+	// sidecars hold file metadata next to the backed-up files when the
+	// target can't keep it; nil unless --sidecar is set
+	sidecars *sidecar.Store
+
+	// coord is shared with other instances backing up the account; nil if
+	// no state backend is configured
+	coord *coord.Coordinator
+
+	// estimate predicts the end of the download phase while it runs; see
+	// Estimate
+	estimate atomic.Pointer[transfer.Estimate]
+
+	// maxFileSize is the largest file the backup file system can store, 0 if
+	// unlimited, and fileSystem its type if detected; set for each download
+	// phase, see fileSizeLimit
+	maxFileSize uint64
+	fileSystem  string
+
+	// account is the Dropbox account of the token, recorded in the manifest
+	// so a later run with another account's token is caught; see
+	// checkAccount
+	account manifest.Account
+
+	// listState is the listing of this run with the cursors to continue it
+	// from; nil if the next run has to list everything. See listPaths.
+	listState *listing.State
+
+	// fs and clock are the local file system and time, replaced in tests;
+	// nil means the real ones, see fsys and now
+	fs    localfs.FS
+	clock func() time.Time
+	// rng picks the files to spot-check, replaced in tests; guarded by skipsMu
+	rng *rand.Rand
+
+	// skew is how far the local clock is ahead of Dropbox's if beyond
+	// --max-clock-skew, zero otherwise; see detectClockSkew
+	skew time.Duration
+
+	// budget caps the API calls of the client with --api-call-budget; nil
+	// for no limit
+	budget *budget.Budget
 }
 
-// Stats tracks backup statistics
-type Stats struct {
-	TotalFiles      int
-	TotalFolders    int
-	DownloadedFiles int
-	SkippedFiles    int
-	DeletedFiles    int
-	TotalBytes      uint64
-	StartTime       time.Time
-	EndTime         time.Time
+// FolderSummary describes a top-level Dropbox folder and the size of its contents
+type FolderSummary struct {
+	Path  string
+	Name  string
+	Size  uint64
+	Files int
 }
 
-// New creates a new backup engine
-func New(cfg *config.Config) (*Engine, error) {
+// Detail returns a short human-readable description of the folder contents
+func (f FolderSummary) Detail() string {
+	return fmt.Sprintf("%s, %s files", formatBytes(f.Size), output.Count(f.Files))
+}
+
+// New creates a new backup engine writing its messages to out
+func New(cfg *config.Config, out output.Formatter) (*Engine, error) {
 	// Create Dropbox client with enhanced authentication
-	dbxClient, err := dropbox.New(
-		cfg.ClientID,
-		cfg.ClientSecret,
-		cfg.AccessToken,
-		cfg.RefreshToken,
+	dbxClient, err := dropbox.NewWithToken(
+		dropbox.NewAuthConfig(cfg.ClientID, cfg.ClientSecret, ""),
+		cfg.Token(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Dropbox client: %w", err)
 	}
+	dbxClient.SetCallTimeout(cfg.APITimeout)
+	dbxClient.SetRetryPolicy(cfg.Retry)
+	chaos, err := cfg.ChaosSettings()
+	if err != nil {
+		return nil, err
+	}
+	if chaos.Enabled() {
+		dbxClient.SetChaos(chaos)
+	}
+	memory, err := cfg.MemoryPlan()
+	if err != nil {
+		return nil, err
+	}
+	dbxClient.SetListPageSize(memory.ListPageSize)
+	var calls *budget.Budget
+	if cfg.APICallBudget > 0 {
+		calls = cfg.APIBudget
+		if calls == nil {
+			calls = budget.New(cfg.APICallBudget, cfg.APIUsage)
+		}
+		dbxClient.SetBudget(calls)
+		slog.Info("Daily API call budget",
+			slog.Int("budget", cfg.APICallBudget),
+			slog.Int("remaining", calls.Remaining()),
+		)
+	}
 
 	// Validate token and permissions
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -54,53 +185,264 @@ func New(cfg *config.Config) (*Engine, error) {
 	if err := dbxClient.ValidateTokenScopes(ctx); err != nil {
 		return nil, fmt.Errorf("token validation failed: %w", err)
 	}
+	if cfg.ReadOnly {
+		if err := dbxClient.RequireReadOnly(ctx); err != nil {
+			return nil, err
+		}
+		slog.Info("Token is read-only")
+	}
 
 	slog.Info("Dropbox authentication successful")
 
-	// Create semaphore for concurrency control
-	semaphore := make(chan struct{}, cfg.MaxConcurrency)
+	// Create the executor shared by all downloads
+	if out == nil {
+		out = output.Discard
+	}
+	transfers, err := transfer.NewFromConfig(cfg, output.MessageWriter(out))
+	if err != nil {
+		return nil, err
+	}
 
-	return &Engine{
+	engine := &Engine{
 		config:        cfg,
 		dropboxClient: dbxClient,
-		semaphore:     semaphore,
-	}, nil
+		transfers:     transfers,
+		budget:        calls,
+		out:           out,
+	}
+
+	backend, err := coord.Open(cfg.StateBackend)
+	if err != nil {
+		return nil, err
+	}
+	if backend != nil {
+		engine.coord = coord.New(backend, cfg.InstanceID)
+	}
+
+	return engine, nil
+}
+
+// fsys returns the local file system the engine works on
+func (e *Engine) fsys() localfs.FS {
+	if e.fs == nil {
+		return localfs.OS
+	}
+	return e.fs
+}
+
+// messages returns the formatter of the engine's messages
+func (e *Engine) messages() output.Formatter {
+	if e.out == nil {
+		return output.Discard
+	}
+	return e.out
+}
+
+// now returns the current time of the engine's clock
+func (e *Engine) now() time.Time {
+	if e.clock == nil {
+		return time.Now()
+	}
+	return e.clock()
+}
+
+// manifestCheckpointInterval is how often the manifest is saved while a run
+// executes, so a crash or kill loses at most that much of its progress
+const manifestCheckpointInterval = time.Minute
+
+// listProgressInterval is how often progress is reported while listing,
+// unless configured otherwise
+const listProgressInterval = 10 * time.Second
+
+// eventListing is the event type of listing progress in a stream of events
+const eventListing = "listing"
+
+// listingEvent is the progress of a listing as a stream event
+type listingEvent struct {
+	Entries int    `json:"entries"`
+	Folders int    `json:"folders"`
+	Path    string `json:"path"`
+}
+
+// reportListProgress reports the progress of a listing that is taking a
+// while to the log and the output: a line for people, or an event for
+// formatters that stream them
+func (e *Engine) reportListProgress(p dropbox.ListProgress) {
+	slog.Info("Listing files from Dropbox",
+		slog.Int("entries", p.Entries),
+		slog.Int("folders", p.Folders),
+		slog.String("path", p.Path),
+	)
+	out := e.messages()
+	if streamer, ok := out.(output.Streamer); ok {
+		if err := streamer.Event(eventListing, listingEvent{Entries: p.Entries, Folders: p.Folders, Path: p.Path}); err != nil {
+			slog.Warn("Failed to write listing event", slog.String("error", err.Error()))
+		}
+		return
+	}
+	out.Message("Listing: %d entries in %d folders, at %s", p.Entries, p.Folders, p.Path)
 }
 
 // Run executes the backup process
-func (e *Engine) Run(ctx context.Context) error {
-	stats := &Stats{
-		StartTime: time.Now(),
+func (e *Engine) Run(ctx context.Context) (stats *Stats, err error) {
+	start := e.now()
+	stats = &Stats{
+		RunID:     NewRunID(start),
+		StartTime: start,
+	}
+
+	// Complete the result even on failure
+	defer func() {
+		if stats.EndTime.IsZero() {
+			stats.EndTime = e.now()
+		}
+		if stats.APILatency == nil {
+			stats.APILatency = e.dropboxClient.APILatencies()
+		}
+		e.recordBudget(stats)
+		if err != nil {
+			stats.Error = err.Error()
+		}
+		if e.config.MetricsFile != "" {
+			if metricsErr := e.writeMetrics(stats, err); metricsErr != nil {
+				slog.Warn("Failed to write metrics file",
+					slog.String("path", e.config.MetricsFile),
+					slog.String("error", metricsErr.Error()),
+				)
+			}
+		}
+		e.publishStatus(ctx, stats, err)
+	}()
+
+	account, err := e.dropboxClient.GetAccountInfo(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to identify the Dropbox account: %w", err)
 	}
+	e.resolveBackupDir(account, stats.StartTime)
+	if err := e.checkAccount(account); err != nil {
+		return stats, err
+	}
+	e.logPeers(ctx)
+	if err := e.checkRemotePaths(ctx); err != nil {
+		return stats, err
+	}
+	e.detectClockSkew(ctx, stats)
 
 	slog.Info("Starting backup process",
 		slog.String("backup_dir", e.config.BackupDir),
 		slog.Int("max_concurrency", e.config.MaxConcurrency),
 	)
 
+	done := stats.startPhase(PhaseList, e.now)
+	filteredFiles, err := e.plan(ctx, stats)
+	done()
+	if err != nil {
+		return stats, err
+	}
+
+	if err := e.execute(ctx, filteredFiles, stats); err != nil {
+		return stats, err
+	}
+
+	stats.EndTime = e.now()
+	stats.APILatency = e.dropboxClient.APILatencies()
+	e.logStats(stats)
+
+	// Replicate what changed once the backup is complete
+	if err := e.runPostCommand(ctx); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// Token returns the OAuth2 token of the Dropbox client, which may have been
+// refreshed since the configuration was loaded
+func (e *Engine) Token() *oauth2.Token {
+	return e.dropboxClient.Token()
+}
+
+// TopLevelFolders lists the folders in the Dropbox root together with their sizes
+func (e *Engine) TopLevelFolders(ctx context.Context) ([]FolderSummary, error) {
+	entries, err := e.dropboxClient.ListFolder(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top-level folders: %w", err)
+	}
+
+	var folders []FolderSummary
+	for _, entry := range entries {
+		if !entry.IsFolder {
+			continue
+		}
+
+		size, count, err := e.dropboxClient.FolderSize(ctx, entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure folder %s: %w", entry.Path, err)
+		}
+
+		folders = append(folders, FolderSummary{
+			Path:  entry.Path,
+			Name:  entry.Name,
+			Size:  size,
+			Files: count,
+		})
+	}
+
+	return folders, nil
+}
+
+// resolveBackupDir expands placeholders in the configured backup directory
+func (e *Engine) resolveBackupDir(account *dropbox.AccountInfo, now time.Time) {
+	e.config.ExpandBackupDir(config.PathValues{
+		Now:          now,
+		Profile:      e.config.Profile,
+		AccountEmail: account.Email,
+	})
+}
+
+// plan lists the remote account and applies filters. It never writes to the
+// local filesystem.
+func (e *Engine) plan(ctx context.Context, stats *Stats) ([]dropbox.FileInfo, error) {
 	// Check and refresh token if needed
 	if !e.dropboxClient.IsTokenValid() {
 		slog.Info("Token needs refresh, attempting to refresh...")
 		if err := e.dropboxClient.RefreshToken(ctx); err != nil {
-			return fmt.Errorf("failed to refresh token: %w", err)
+			return nil, fmt.Errorf("failed to refresh token: %w", err)
 		}
 	}
 
-	// List all files from Dropbox
+	// List all files from Dropbox, showing signs of life on large accounts
 	slog.Info("Listing files from Dropbox...")
-	dropboxFiles, err := e.dropboxClient.ListAll(ctx)
+	interval := e.config.ProgressInterval
+	if interval <= 0 {
+		interval = listProgressInterval
+	}
+	e.dropboxClient.OnListProgress(interval, e.reportListProgress)
+	dropboxFiles, err := e.listRemote(ctx)
 	if err != nil {
 		// Try refreshing token and retry once if listing fails
 		slog.Warn("File listing failed, attempting token refresh...")
 		if refreshErr := e.dropboxClient.RefreshToken(ctx); refreshErr != nil {
-			return fmt.Errorf("failed to list Dropbox files and refresh token: %w", err)
+			return nil, fmt.Errorf("failed to list Dropbox files and refresh token: %w", err)
 		}
 
 		// Retry listing after token refresh
-		dropboxFiles, err = e.dropboxClient.ListAll(ctx)
+		dropboxFiles, err = e.listRemote(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Dropbox files after token refresh: %w", err)
+		}
+	}
+
+	if e.config.TeamSpace {
+		teamFiles, err := e.listTeamSpace(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to list Dropbox files after token refresh: %w", err)
+			return nil, err
 		}
+		dropboxFiles = append(dropboxFiles, teamFiles...)
+	}
+
+	if e.config.Layout == config.LayoutShared {
+		e.resolveSharedLayout(ctx, dropboxFiles)
 	}
 
 	// Count files and folders separately
@@ -123,38 +465,213 @@ func (e *Engine) Run(ctx context.Context) error {
 	)
 
 	// Filter files based on exclusion patterns
-	filteredFiles := e.filterFiles(dropboxFiles)
-	slog.Info("Files after filtering", slog.Int("count", len(filteredFiles)))
+	filteredFiles := e.filterFiles(dropboxFiles, stats)
+	stats.MatchedFiles = fileCount - stats.ExcludedFiles
+	slog.Info("Files after filtering",
+		slog.Int("count", len(filteredFiles)),
+		slog.Int("excluded_files", stats.ExcludedFiles),
+	)
+
+	return filteredFiles, nil
+}
+
+// execute applies a plan to the local backup directory. This is the only
+// phase that creates directories or writes files, and with --dry-run it
+// only reports what it would do.
+func (e *Engine) execute(ctx context.Context, files []dropbox.FileInfo, stats *Stats) (err error) {
+	if !e.config.DryRun {
+		if err := e.ensureBackupDir(); err != nil {
+			return err
+		}
+	}
+
+	// Load the revisions recorded by earlier runs; saved even if the run fails
+	// so completed downloads aren't fetched again
+	m, err := manifest.Load(e.config.BackupDir)
+	if err != nil {
+		return err
+	}
+	e.manifest = m
+	if coldDir := m.ColdDir(); coldDir != "" && e.config.ColdDir != "" && coldDir != e.config.ColdDir {
+		return fmt.Errorf("cold files of this backup are in %s, not %s; move them there first", coldDir, e.config.ColdDir)
+	}
+	if e.account.ID != "" {
+		m.SetAccount(e.account)
+	}
+	if e.config.Sidecar {
+		e.sidecars = sidecar.NewStore()
+	}
+	e.partsMu.Lock()
+	e.parts = nil
+	e.partsMu.Unlock()
+	defer func() {
+		if e.config.DryRun {
+			return
+		}
+		if e.sidecars != nil {
+			if saveErr := e.sidecars.Save(); saveErr != nil {
+				slog.Error("Failed to save file metadata", slog.String("error", saveErr.Error()))
+				if err == nil {
+					err = saveErr
+				}
+			}
+		}
+		if saveErr := e.saveListing(); saveErr != nil {
+			slog.Warn("The next run will list everything again", slog.String("error", saveErr.Error()))
+		}
+		if saveErr := e.manifest.Save(); saveErr != nil {
+			slog.Error("Failed to save manifest", slog.String("error", saveErr.Error()))
+			if err == nil {
+				err = saveErr
+			}
+			return
+		}
+		if e.config.RcloneSum {
+			if sumErr := e.manifest.SaveHashSum(); sumErr != nil {
+				slog.Error("Failed to save hash sum file", slog.String("error", sumErr.Error()))
+				if err == nil {
+					err = sumErr
+				}
+			}
+		}
+		if e.config.Archive {
+			if chainErr := e.chainManifest(); chainErr != nil && err == nil {
+				err = chainErr
+			}
+		}
+		if e.config.SignKey != "" {
+			if signErr := signing.Sign(context.WithoutCancel(ctx), e.config.SignKey, manifest.Path(e.config.BackupDir)); signErr != nil && err == nil {
+				err = signErr
+			}
+		}
+	}()
+
+	if !e.config.DryRun {
+		// Stopped before the final save above
+		checkpointCtx, stopCheckpoints := context.WithCancel(ctx)
+		var checkpoints sync.WaitGroup
+		checkpoints.Add(1)
+		go func() {
+			defer checkpoints.Done()
+			e.checkpointManifest(checkpointCtx, manifestCheckpointInterval)
+		}()
+		defer func() {
+			stopCheckpoints()
+			checkpoints.Wait()
+		}()
+	}
+
+	// Pause transfers while the network is metered or the required interface is down
+	e.transfers.Start(ctx)
 
 	// Download files concurrently
-	if err := e.downloadFiles(ctx, filteredFiles, stats); err != nil {
+	done := stats.startPhase(PhaseDownload, e.now)
+	err = e.downloadFiles(ctx, files, stats)
+	done()
+	if err != nil {
 		return fmt.Errorf("failed to download files: %w", err)
 	}
 
+	// Read back what was written before trusting it
+	if e.config.VerifyAfter && !e.config.DryRun {
+		done := stats.startPhase(PhaseVerify, e.now)
+		err := e.verifyDownloads(ctx, stats)
+		done()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check a sample of the skip decisions against fresh metadata
+	if e.config.SpotCheck > 0 {
+		done := stats.startPhase(PhaseSpotCheck, e.now)
+		err := e.spotCheck(ctx, stats)
+		done()
+		if err != nil {
+			return err
+		}
+	}
+
 	// Handle deletion if enabled
 	if e.config.Delete {
-		if err := e.deleteOrphanedFiles(ctx, filteredFiles, stats); err != nil {
+		done := stats.startPhase(PhaseDelete, e.now)
+		err := e.deletePhase(ctx, files, stats)
+		done()
+		if err != nil {
 			return fmt.Errorf("failed to delete orphaned files: %w", err)
 		}
 	}
 
-	stats.EndTime = time.Now()
-	e.logStats(stats)
+	// Move files unchanged for long to the cold directory
+	if e.config.ColdDir != "" {
+		done := stats.startPhase(PhaseTier, e.now)
+		err := e.tierFiles(ctx, stats)
+		done()
+		if err != nil {
+			return fmt.Errorf("failed to move files to cold directory: %w", err)
+		}
+	}
+
+	if e.config.AccountMetadata {
+		done := stats.startPhase(PhaseAccountMetadata, e.now)
+		err := e.exportAccountMetadata(ctx)
+		done()
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-func (e *Engine) filterFiles(files []dropbox.FileInfo) []dropbox.FileInfo {
-	if len(e.config.Exclude) == 0 {
+// checkpointManifest saves the manifest every interval while it has unsaved
+// changes, until ctx is done
+func (e *Engine) checkpointManifest(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !e.manifest.Unsaved() {
+				continue
+			}
+			if err := e.manifest.Save(); err != nil {
+				slog.Warn("Failed to save manifest checkpoint", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// ensureBackupDir creates the backup directory if it doesn't exist
+func (e *Engine) ensureBackupDir() error {
+	if err := e.fsys().MkdirAll(e.config.BackupDir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return nil
+}
+
+// filterFiles drops the files excluded by the include and exclude patterns,
+// counting them by the rule that excluded them
+func (e *Engine) filterFiles(files []dropbox.FileInfo, stats *Stats) []dropbox.FileInfo {
+	if len(e.config.Include) == 0 && len(e.config.Exclude) == 0 {
 		return files
 	}
 
+	f := e.config.Filter()
+
 	var filtered []dropbox.FileInfo
 	for _, file := range files {
-		if !e.shouldExclude(file.Path) {
+		if file.IsFolder {
+			filtered = append(filtered, file)
+			continue
+		}
+		if decision := f.Explain(file.Path); decision.Allowed {
 			filtered = append(filtered, file)
 		} else {
-			slog.Debug("Excluding file", slog.String("path", file.Path))
+			stats.exclude(decision.Rule, file.Size)
+			slog.Debug("Excluding file", slog.String("path", file.Path), slog.String("rule", decision.Rule))
 		}
 	}
 
@@ -162,198 +679,567 @@ func (e *Engine) filterFiles(files []dropbox.FileInfo) []dropbox.FileInfo {
 }
 
 func (e *Engine) shouldExclude(path string) bool {
-	for _, pattern := range e.config.Exclude {
-		// Handle @filename pattern (exclusion file)
-		if strings.HasPrefix(pattern, "@") {
-			excludeFile := strings.TrimPrefix(pattern, "@")
-			if e.isInExcludeFile(path, excludeFile) {
-				return true
-			}
-			continue
-		}
+	return e.config.Filter().Match(e.config.Exclude, path)
+}
 
-		// Handle directory patterns
-		if strings.HasSuffix(pattern, "/") {
-			if strings.HasPrefix(path, pattern) || strings.Contains(path, "/"+pattern) {
-				return true
-			}
-			continue
+func (e *Engine) downloadFiles(ctx context.Context, files []dropbox.FileInfo, stats *Stats) error {
+	var downloads []dropbox.FileInfo
+	for _, file := range files {
+		if !file.IsFolder { // Skip folders, they're created automatically
+			downloads = append(downloads, file)
 		}
+	}
 
-		// Handle file patterns
-		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-			return true
-		}
+	e.maxFileSize, e.fileSystem = e.fileSizeLimit()
 
-		// Handle path patterns
-		if matched, _ := filepath.Match(pattern, path); matched {
-			return true
-		}
+	// Per-file logs are summarized with --log-batch
+	ctx = logbatch.PerFile(ctx)
+
+	// A full disk stops the phase: queued downloads aren't started and running
+	// ones are cancelled, instead of each failing on its own
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	var diskFull error
+	var diskFullOnce sync.Once
+	// Likewise a spent API call budget, which leaves the rest to the next run
+	var exhausted bool
+	var exhaustedOnce sync.Once
+
+	finished := make([]bool, len(downloads))
+
+	var total uint64
+	for _, file := range downloads {
+		total += file.Size
 	}
+	estimate := transfer.NewEstimate(total, len(downloads), e.config.HistoricalThroughput)
+	e.estimate.Store(estimate)
+	defer e.estimate.Store(nil)
+	e.transfers.Track(estimate)
+	defer e.transfers.Track(nil)
+
+	err := e.runDownloads(ctx, downloads, func(ctx context.Context, i int) error {
+		file := downloads[i]
+		start := e.now()
+		fileCtx, retries := retry.WithCounter(ctx)
+		// A panic fails this file only; the others keep downloading
+		var result Result
+		err := transfer.Safely(file.Path, func() (err error) {
+			result, err = e.downloadFile(fileCtx, file)
+			return err
+		})
+		result.Path = file.Path
+		result.Duration = e.now().Sub(start)
+		result.Retries = int(retries.Load())
+		if err == nil && result.Action == ActionDownloaded {
+			estimate.Done(file.Size)
+		} else {
+			estimate.Skip(file.Size)
+		}
+		if reason, ok := dropbox.Unsupported(err); ok {
+			e.record(stats, result.unsupported(reason, err))
+			finished[i] = true
+			return nil
+		}
+		if errors.Is(err, budget.ErrExhausted) {
+			exhaustedOnce.Do(func() {
+				exhausted = true
+				stop(err)
+			})
+			return err
+		}
+		if err != nil {
+			if classifyFailure(err) == FailureDiskFull {
+				diskFullOnce.Do(func() {
+					diskFull = err
+					stop(err)
+				})
+			}
+			if ctx.Err() == nil || classifyFailure(err) == FailureDiskFull {
+				e.record(stats, result.failed(err))
+			}
+			return fmt.Errorf("failed to download %s: %w", file.Path, err)
+		}
+		e.record(stats, result)
+		finished[i] = true
+		return nil
+	})
 
-	return false
+	if diskFull != nil {
+		full := newDiskFullError(downloads, finished, diskFull)
+		slog.Error("Backup disk is full, stopped downloading",
+			slog.Int("remaining_files", full.RemainingFiles),
+			slog.Uint64("remaining_bytes", full.RemainingBytes),
+		)
+		return full
+	}
+	if exhausted {
+		spent := newBudgetError(downloads, finished)
+		slog.Warn("Daily API call budget reached, stopped downloading",
+			slog.Int("remaining_files", spent.RemainingFiles),
+			slog.Uint64("remaining_bytes", spent.RemainingBytes),
+		)
+		return spent
+	}
+	return err
 }
 
-func (e *Engine) isInExcludeFile(path, excludeFile string) bool {
-	// This is a simplified implementation
-	// In a real implementation, you would read the exclude file
-	// and check if the path matches any patterns in it
-	return false
+// Estimate returns the progress of the download phase and its expected
+// remaining time, and false while no download phase runs. It is safe to call
+// while the engine runs.
+func (e *Engine) Estimate() (transfer.EstimateStatus, bool) {
+	estimate := e.estimate.Load()
+	if estimate == nil {
+		return transfer.EstimateStatus{}, false
+	}
+	return estimate.Status(), true
 }
 
-func (e *Engine) downloadFiles(ctx context.Context, files []dropbox.FileInfo, stats *Stats) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(files))
+// runDownloads calls fn with the index of every download. Files of at least
+// the large file threshold run in a lane of their own, next to the others.
+func (e *Engine) runDownloads(ctx context.Context, downloads []dropbox.FileInfo, fn func(context.Context, int) error) error {
+	threshold, err := e.config.LargeFileThresholdSize()
+	if err != nil {
+		return err
+	}
 
-	for _, file := range files {
-		if file.IsFolder {
-			continue // Skip folders, they're created automatically
+	var small, large []int
+	for i, file := range downloads {
+		if threshold > 0 && file.Size >= threshold {
+			large = append(large, i)
+		} else {
+			small = append(small, i)
 		}
+	}
 
-		wg.Add(1)
-		go func(file dropbox.FileInfo) {
-			defer wg.Done()
-
-			// Acquire semaphore
-			select {
-			case e.semaphore <- struct{}{}:
-				defer func() { <-e.semaphore }()
-			case <-ctx.Done():
-				errChan <- ctx.Err()
-				return
-			}
-
-			if err := e.downloadFile(ctx, file, stats); err != nil {
-				errChan <- fmt.Errorf("failed to download %s: %w", file.Path, err)
-			}
-		}(file)
+	name := func(i int) string { return downloads[i].Path }
+	if len(large) == 0 {
+		return transfer.Run(ctx, e.transfers, small, name, fn)
 	}
 
-	// Wait for all downloads to complete
+	lane := e.transfers.Lane(e.config.LargeFileConcurrency)
+	slog.Info("Downloading large files in a separate lane",
+		slog.Int("files", len(large)),
+		slog.Int("concurrency", lane.Concurrency()),
+	)
+	largeErr := make(chan error, 1)
 	go func() {
-		wg.Wait()
-		close(errChan)
+		largeErr <- transfer.Run(ctx, lane, large, name, fn)
 	}()
-
-	// Collect any errors
-	for err := range errChan {
-		if err != nil {
-			return err
-		}
+	err = transfer.Run(ctx, e.transfers, small, name, fn)
+	if laneErr := <-largeErr; err == nil {
+		err = laneErr
 	}
-
-	return nil
+	return err
 }
 
-func (e *Engine) downloadFile(ctx context.Context, file dropbox.FileInfo, stats *Stats) error {
-	localPath := filepath.Join(e.config.BackupDir, strings.TrimPrefix(file.Path, "/"))
+// downloadFile brings the local copy of a file up to date. The result tells
+// the action taken; the caller fills in the path, timing and retries.
+func (e *Engine) downloadFile(ctx context.Context, file dropbox.FileInfo) (Result, error) {
+	localPath := e.localPath(file)
+
+	// Never touch anything outside the backup directory, whatever Dropbox
+	// reports
+	if err := checkWithin(e.config.BackupDir, localPath); err != nil {
+		slog.Error("Rejecting file that would be written outside the backup directory",
+			slog.String("path", file.Path),
+			slog.String("local_path", localPath),
+		)
+		return Result{}, err
+	}
+
+	// Files moved to the cold directory stay there until they change
+	if e.coldCopy(file) {
+		slog.DebugContext(ctx, "Skipping file (up to date in cold directory)", slog.String("path", file.Path))
+		return Result{Action: ActionSkipped}, nil
+	}
 
 	// Check if file already exists and is newer
 	if e.shouldSkipFile(localPath, file) {
-		stats.SkippedFiles++
-		slog.Debug("Skipping file (already up to date)", slog.String("path", file.Path))
-		return nil
+		if info, err := e.fsys().Stat(localPath); err == nil {
+			e.recordFile(file, uint64(info.Size()))
+			if e.config.Archive && !e.config.DryRun && !archive.Sealed(info) {
+				if err := archive.Seal(localPath); err != nil {
+					return Result{}, err
+				}
+			}
+		}
+		if !e.config.DryRun {
+			if err := e.syncMirrors(localPath); err != nil {
+				return Result{}, err
+			}
+		}
+		e.rememberSkip(localPath, file)
+		slog.DebugContext(ctx, "Skipping file (already up to date)", slog.String("path", file.Path))
+		return Result{Action: ActionSkipped}, nil
 	}
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	// Archived copies are never overwritten, even if the file changed
+	if e.config.Archive {
+		if info, err := e.fsys().Stat(localPath); err == nil && archive.Sealed(info) {
+			slog.Warn("Keeping archived copy of changed file", slog.String("path", file.Path))
+			return Result{Action: ActionSkipped}, nil
+		}
 	}
 
-	// Download file
-	reader, _, err := e.dropboxClient.Download(ctx, file.Path)
-	if err != nil {
-		return fmt.Errorf("failed to download from Dropbox: %w", err)
+	// A file the backup file system can't store would fail midway. The size
+	// of exports isn't known up front.
+	if e.maxFileSize > 0 && file.Size > e.maxFileSize && file.ExportAs == "" && e.config.OnOversize != config.OversizeDownload {
+		return e.oversize(file)
 	}
-	defer reader.Close()
 
-	// Create local file
-	localFile, err := os.Create(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to create local file: %w", err)
+	if e.config.DryRun {
+		e.messages().Message("[dry-run] download %s (%s)", file.Path, formatBytes(file.Size))
+		return Result{Action: ActionDownloaded, Bytes: file.Size, DryRun: true}, nil
+	}
+
+	// Create directory if it doesn't exist
+	if err := e.fsys().MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return Result{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// The file timeout covers the whole transfer including retries of
+	// stalls; API calls have their own
+	fileCtx := ctx
+	if e.config.FileTimeout > 0 {
+		var cancel context.CancelFunc
+		fileCtx, cancel = context.WithTimeout(ctx, e.config.FileTimeout)
+		defer cancel()
 	}
-	defer localFile.Close()
 
-	// Copy content
-	written, err := io.Copy(localFile, reader)
+	// A stalled body is abandoned and fetched again under the retry policy
+	var written int64
+	err := e.config.Retry.Do(fileCtx, fetchClass, func() (err error) {
+		written, err = e.fetch(fileCtx, file, localPath)
+		return err
+	})
+	if err != nil && ctx.Err() == nil && fileCtx.Err() != nil {
+		return Result{}, fmt.Errorf("%w after %s", errFileTimeout, e.config.FileTimeout)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to write file content: %w", err)
+		return Result{}, err
 	}
 
-	// Set modification time
+	// Set modification time (and on Windows creation time)
 	if !file.ModTime.IsZero() {
-		if err := os.Chtimes(localPath, file.ModTime, file.ModTime); err != nil {
+		if err := setFileTimes(localPath, file.ModTime); err != nil {
 			slog.Warn("Failed to set file modification time",
 				slog.String("path", localPath),
 				slog.String("error", err.Error()),
 			)
 		}
 	}
+	if e.config.TagXattr {
+		e.tagFile(localPath, file)
+	}
+	if e.config.HideDotFiles && strings.HasPrefix(file.Name, ".") {
+		if err := hideFile(localPath); err != nil {
+			slog.Warn("Failed to hide file",
+				slog.String("path", localPath),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+	if e.config.Archive {
+		if err := archive.Seal(localPath); err != nil {
+			return Result{}, err
+		}
+	}
 
-	stats.DownloadedFiles++
-	stats.TotalBytes += uint64(written)
+	if err := e.syncMirrors(localPath); err != nil {
+		return Result{}, err
+	}
+
+	e.recordFile(file, uint64(written))
+	e.rememberDownload(localPath, file)
+	e.rememberChange(localPath, false)
 
-	slog.Info("Downloaded file",
+	slog.InfoContext(ctx, "Downloaded file",
 		slog.String("path", file.Path),
 		slog.Int64("size", written),
 	)
 
-	return nil
+	return Result{Action: ActionDownloaded, Bytes: uint64(written)}, nil
 }
 
-func (e *Engine) shouldSkipFile(localPath string, remoteFile dropbox.FileInfo) bool {
-	stat, err := os.Stat(localPath)
+// fetch writes the remote content of a file to its local path. The content
+// goes to a part file first, which replaces the local file once complete. A
+// part left by an interrupted download of the same revision is resumed; on
+// other failures, e.g. when the disk is full, the part is removed.
+func (e *Engine) fetch(ctx context.Context, file dropbox.FileInfo, localPath string) (written int64, err error) {
+	ctx, stall := transfer.WatchStall(ctx, e.config.StallTimeout)
+	defer stall.Stop()
+
+	partPath := transfer.PartPath(localPath, file.Rev)
+	resumable := file.Rev != "" && file.ContentHash != "" && file.ExportAs == ""
+	var offset int64
+	if resumable {
+		offset = resumeOffset(e.fsys(), partPath, file)
+	}
+	if offset == 0 {
+		e.removeParts(localPath)
+	}
+
+	// Download file, exporting it if Dropbox can't serve it directly
+	reader, err := e.openRemote(ctx, file, offset)
 	if err != nil {
-		return false // File doesn't exist, don't skip
+		return 0, fmt.Errorf("failed to download from Dropbox: %w", err)
 	}
+	defer reader.Close()
 
-	// Compare modification times
-	if !remoteFile.ModTime.IsZero() && stat.ModTime().After(remoteFile.ModTime) {
-		return true // Local file is newer
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+		slog.InfoContext(ctx, "Resuming download",
+			slog.String("path", file.Path),
+			slog.Int64("offset", offset),
+		)
 	}
+	partFile, err := e.fsys().OpenFile(partPath, flags, 0666)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer partFile.Close()
+	complete := false
+	defer func() {
+		if !complete {
+			partFile.Close()
+			if !resumable || isAnyOf(err, diskFullErrors) {
+				e.fsys().Remove(partPath)
+			}
+		}
+	}()
+
+	// A fresh download is written to the mirrors as it arrives; resumed ones
+	// are copied to them afterwards
+	var mirrors *mirrorSet
+	if offset == 0 {
+		if mirrors, err = e.openMirrors(localPath, file.Rev); err != nil {
+			return 0, err
+		}
+		defer mirrors.abort()
+	}
+
+	// Copy content, pausing and throttling as configured and writing in
+	// buffers of the configured size
+	writer := e.transfers.Writer(partFile)
+	var dst io.Writer = writer
+	if mirrors != nil {
+		dst = io.MultiWriter(writer, mirrors)
+	}
+
+	// Hash fresh downloads as they are written, so content corrupted on the
+	// way never replaces the local copy
+	var hasher hash.Hash
+	if offset == 0 && file.ContentHash != "" && file.ExportAs == "" {
+		hasher = dropbox.NewContentHash()
+		dst = io.MultiWriter(dst, hasher)
+	}
+	// The estimate of the download phase follows the bytes as they arrive
+	var src io.Reader = reader
+	if estimate := e.estimate.Load(); estimate != nil {
+		t := estimate.Start(file.Path, file.Size, offset)
+		defer estimate.Finish(t)
+		src = t.Reader(src)
+	}
+	written, err = io.Copy(dst, e.transfers.Reader(ctx, stall.Reader(src)))
+	if err == nil {
+		err = writer.Flush()
+	}
+	if stallErr := stall.Err(); err != nil && stallErr != nil {
+		slog.Warn("Download stalled", slog.String("path", file.Path), slog.Int64("received", offset+written))
+		return 0, stallErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write file content: %w", err)
+	}
+
+	// Close before setting times, which closing would otherwise overwrite on
+	// Windows, and before sealing, which fails for open files there
+	if err := partFile.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write file content: %w", err)
+	}
+
+	if hasher != nil {
+		if received := hex.EncodeToString(hasher.Sum(nil)); received != file.ContentHash {
+			e.fsys().Remove(partPath)
+			return 0, fmt.Errorf("%w: received %s, Dropbox %s", errChecksumMismatch, received, file.ContentHash)
+		}
+	}
+
+	// A resumed copy is pieced together, so check it as a whole
+	if offset > 0 {
+		if err := verifyDownload(e.fsys(), download{localPath: partPath, file: file}); err != nil {
+			e.fsys().Remove(partPath)
+			return 0, err
+		}
+	}
+
+	if err := e.fsys().Rename(partPath, localPath); err != nil {
+		e.fsys().Remove(partPath)
+		return 0, fmt.Errorf("failed to move downloaded file into place: %w", err)
+	}
+	complete = true
+	if mirrors != nil {
+		if err := mirrors.finish(file.ModTime); err != nil {
+			return 0, err
+		}
+	}
+	return offset + written, nil
+}
+
+// fetchClass makes the retry policy retry stalled downloads as timeouts and
+// downloads corrupted on the way as network errors
+func fetchClass(err error) string {
+	var stall *transfer.StallError
+	switch {
+	case errors.As(err, &stall):
+		return retry.Timeout
+	case errors.Is(err, errChecksumMismatch):
+		return retry.Network
+	}
+	return ""
+}
 
-	// Compare sizes
-	if stat.Size() == int64(remoteFile.Size) && !remoteFile.ModTime.IsZero() && stat.ModTime().Equal(remoteFile.ModTime) {
-		return true // Same size and modification time
+// recordFile stores the revision of a file that is now up to date locally.
+// size is the size of the local copy, which differs for exported files.
+func (e *Engine) recordFile(file dropbox.FileInfo, size uint64) {
+	e.recordSidecar(file, size)
+	if e.manifest == nil || file.Rev == "" {
+		return
 	}
+	key := manifest.Key(file.Namespace, file.Path)
+	entry := manifest.Entry{
+		Rev:         file.Rev,
+		ContentHash: file.ContentHash,
+		Size:        size,
+		ModTime:     file.ModTime,
+		Exported:    file.ExportAs != "",
+		Namespace:   file.Namespace,
+		DisplayPath: file.DisplayPath,
+	}
+	if e.layoutPath(key) != key {
+		entry.LocalPath = e.relPath(e.localPath(file))
+	}
+	e.manifest.Set(key, entry)
+}
+
+// relPath returns a local path relative to the backup directory as used by
+// the manifest: slash-separated with a leading "/"
+func (e *Engine) relPath(localPath string) string {
+	rel, err := filepath.Rel(e.config.BackupDir, localPath)
+	if err != nil {
+		return ""
+	}
+	return "/" + filepath.ToSlash(rel)
+}
 
-	return false
+// forgetFile drops the manifest entry for a deleted local file
+func (e *Engine) forgetFile(localPath string) {
+	e.forgetSidecar(localPath)
+	if e.manifest == nil {
+		return
+	}
+	if remotePath, _, ok := e.manifest.Resolve(e.relPath(localPath)); ok {
+		e.manifest.Delete(remotePath)
+	}
 }
 
+// deleteOrphanedFiles removes the local copies of files no longer on
+// Dropbox. The backed-up folders are walked first to find them, then they are
+// removed in parallel, see deleteOrphans.
 func (e *Engine) deleteOrphanedFiles(ctx context.Context, dropboxFiles []dropbox.FileInfo, stats *Stats) error {
 	// Create a map of Dropbox files for quick lookup
 	dropboxFileMap := make(map[string]bool)
 	for _, file := range dropboxFiles {
-		localPath := filepath.Join(e.config.BackupDir, strings.TrimPrefix(file.Path, "/"))
-		dropboxFileMap[localPath] = true
+		dropboxFileMap[e.localPath(file)] = true
 	}
 
-	// Walk through local backup directory
-	return filepath.Walk(e.config.BackupDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	// Walk through the local copies of the backed-up folders
+	var orphans []orphan
+	var infos []os.FileInfo
+	for _, root := range e.deleteRoots() {
+		if _, err := e.fsys().Stat(root); os.IsNotExist(err) {
+			continue
 		}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+		err := e.fsys().Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
 
-		// Check if file exists in Dropbox
-		if !dropboxFileMap[path] {
-			slog.Info("Deleting orphaned file", slog.String("path", path))
-			if err := os.Remove(path); err != nil {
-				return fmt.Errorf("failed to delete file %s: %w", path, err)
+			// Skip directories and the files kept next to the backup
+			if info.IsDir() || e.isBookkeeping(path) {
+				return nil
 			}
-			stats.DeletedFiles++
+			// Incomplete downloads of files still on Dropbox are resumed later
+			if transfer.IsPart(path) && dropboxFileMap[transfer.PartTarget(path)] {
+				return nil
+			}
+
+			// Check if file exists in Dropbox
+			if !dropboxFileMap[path] {
+				orphans = append(orphans, orphan{path: path, size: uint64(info.Size())})
+				infos = append(infos, info)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
+	}
 
-		return nil
-	})
+	markShared(orphans, infos)
+	if err := e.deleteOrphans(ctx, orphans, stats); err != nil {
+		return err
+	}
+	return e.deleteColdFiles(ctx, dropboxFileMap, e.deleteRoots(), stats)
+}
+
+// isBookkeeping reports whether a local path is one of the files this tool
+// keeps next to the backup rather than a backed-up file
+func (e *Engine) isBookkeeping(path string) bool {
+	return sidecar.IsSidecar(path) ||
+		path == manifest.Path(e.config.BackupDir) ||
+		path == manifest.HashSumPath(e.config.BackupDir) ||
+		path == accountmeta.Path(e.config.BackupDir) ||
+		path == listing.Path(e.config.BackupDir) ||
+		path == archive.ChainPath(e.config.BackupDir) ||
+		path == signing.SignaturePath(manifest.Path(e.config.BackupDir))
+}
+
+// chainManifest appends the manifest saved by this run to the archive chain
+func (e *Engine) chainManifest() error {
+	link, err := archive.Append(e.config.BackupDir, e.now(), e.manifest.Len())
+	if err != nil {
+		return err
+	}
+	slog.Info("Recorded run in archive chain",
+		slog.Int("run", link.Run),
+		slog.String("hash", link.Hash),
+	)
+	return nil
+}
+
+// deleteRoots returns the local directories the delete phase may clean up.
+// When only some remote folders are backed up, everything else is left alone.
+func (e *Engine) deleteRoots() []string {
+	if len(e.config.RemotePaths) == 0 {
+		return []string{e.config.BackupDir}
+	}
+
+	roots := make([]string, 0, len(e.config.RemotePaths))
+	for _, remotePath := range e.config.RemotePaths {
+		roots = append(roots, LocalPath(e.config.BackupDir, strings.ToLower(remotePath)))
+	}
+	// Shared folders inside the selected folders are stored elsewhere
+	for _, root := range e.sharedRoots {
+		roots = append(roots, LocalPath(e.config.BackupDir, root))
+	}
+	return roots
 }
 
 func (e *Engine) logStats(stats *Stats) {
-	duration := stats.EndTime.Sub(stats.StartTime)
+	duration := stats.Duration()
 
 	// Always log basic completion info
 	slog.Info("Backup completed",
@@ -362,46 +1248,76 @@ func (e *Engine) logStats(stats *Stats) {
 		slog.Int("deleted_files", stats.DeletedFiles),
 		slog.Duration("duration", duration),
 	)
+	if stats.TooLargeFiles > 0 {
+		slog.Warn("Skipped files too large for the backup file system",
+			slog.Int("files", stats.TooLargeFiles),
+			slog.Uint64("bytes", stats.TooLargeBytes),
+			slog.String("hint", oversizeHint),
+		)
+	}
+	if stats.UnsupportedFiles > 0 {
+		slog.Warn("Skipped files Dropbox can't serve, see --list-unsupported",
+			slog.Int("files", stats.UnsupportedFiles),
+			slog.String("by_reason", stats.UnsupportedSummary()),
+		)
+	}
+	if stats.FailedFiles > 0 {
+		slog.Warn("Files failed",
+			slog.Int("failed_files", stats.FailedFiles),
+			slog.String("by_class", stats.FailureSummary()),
+		)
+	}
 
-	// Display count information if requested
-	if e.config.ShowCount {
-		fmt.Printf("\n📊 File Count Summary:\n")
-		fmt.Printf("   Total files processed: %d\n", stats.TotalFiles)
-		fmt.Printf("   Total folders processed: %d\n", stats.TotalFolders)
-		fmt.Printf("   Total items: %d\n", stats.TotalFiles+stats.TotalFolders)
-		fmt.Printf("   Files downloaded: %d\n", stats.DownloadedFiles)
-		fmt.Printf("   Files skipped: %d\n", stats.SkippedFiles)
-		if stats.DeletedFiles > 0 {
-			fmt.Printf("   Files deleted: %d\n", stats.DeletedFiles)
-		}
+	// Latency percentiles help tell network problems from API-side throttling
+	for op, latency := range stats.APILatency {
+		slog.Debug("Dropbox API latency",
+			slog.String("op", op),
+			slog.Int("calls", latency.Count),
+			slog.Duration("p50", latency.P50),
+			slog.Duration("p95", latency.P95),
+			slog.Duration("p99", latency.P99),
+		)
 	}
+}
 
-	// Display size information if requested
-	if e.config.ShowSize {
-		fmt.Printf("\n💾 Size Summary:\n")
-		fmt.Printf("   Total bytes processed: %s\n", formatBytes(stats.TotalBytes))
-		if duration > 0 {
-			bytesPerSecond := float64(stats.TotalBytes) / duration.Seconds()
-			fmt.Printf("   Average transfer rate: %s/s\n", formatBytes(uint64(bytesPerSecond)))
-		}
+// writeMetrics writes run results and API latencies in Prometheus text format
+func (e *Engine) writeMetrics(stats *Stats, runErr error) error {
+	success := 1.0
+	if runErr != nil {
+		success = 0
 	}
 
-	// Add a separator if either count or size was displayed
-	if e.config.ShowCount || e.config.ShowSize {
-		fmt.Println()
+	var tf metrics.TextFile
+	tf.Gauge("dropbox_backup_success", "Whether the last backup run succeeded", success)
+	tf.Gauge("dropbox_backup_last_run_timestamp_seconds", "Time the last backup run finished", float64(stats.EndTime.Unix()))
+	tf.Gauge("dropbox_backup_duration_seconds", "Duration of the last backup run", stats.EndTime.Sub(stats.StartTime).Seconds())
+	tf.Gauge("dropbox_backup_files_downloaded", "Files downloaded in the last backup run", float64(stats.DownloadedFiles))
+	tf.Gauge("dropbox_backup_files_skipped", "Files skipped as up to date in the last backup run", float64(stats.SkippedFiles))
+	tf.Gauge("dropbox_backup_files_failed", "Files that failed in the last backup run", float64(stats.FailedFiles))
+	failures := make(map[string]float64, len(stats.Failures))
+	for class, n := range stats.Failures {
+		failures[class] = float64(n)
 	}
+	tf.GaugeVec("dropbox_backup_files_failed_by_class", "Files that failed in the last backup run by failure class", "class", failures)
+	tf.Gauge("dropbox_backup_spot_check_mismatches", "Spot-checked files skipped in the last backup run although they differ from Dropbox", float64(stats.SpotCheckMismatches))
+	tf.Gauge("dropbox_backup_api_retries", "Retried API calls for files in the last backup run", float64(stats.Retries))
+	tf.Gauge("dropbox_backup_api_calls", "API calls in the last backup run, counted with --api-call-budget", float64(stats.APICalls))
+	tf.Gauge("dropbox_backup_bytes_downloaded", "Bytes downloaded in the last backup run", float64(stats.TotalBytes))
+	folderBytes := make(map[string]float64, len(stats.Folders))
+	folderSeconds := make(map[string]float64, len(stats.Folders))
+	for folder, fs := range stats.Folders {
+		folderBytes[folder] = float64(fs.DownloadedBytes)
+		folderSeconds[folder] = fs.Duration.Seconds()
+	}
+	tf.GaugeVec("dropbox_backup_folder_bytes_downloaded", "Bytes downloaded in the last backup run by top-level folder", "folder", folderBytes)
+	tf.GaugeVec("dropbox_backup_folder_transfer_seconds", "Time spent transferring files in the last backup run by top-level folder", "folder", folderSeconds)
+	tf.LatencySummary("dropbox_backup_api_latency_seconds", "Dropbox API call latency by operation type", stats.APILatency)
+
+	return tf.WriteFile(e.config.MetricsFile)
 }
 
-// formatBytes formats byte counts in human-readable format
+// formatBytes formats byte counts in the units and number format set up
+// for output
 func formatBytes(bytes uint64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := uint64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return output.Bytes(bytes)
 }
@@ -1,13 +1,29 @@
 package backup
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"create-dropbox-backup-folder/internal/accountmeta"
 	"create-dropbox-backup-folder/internal/config"
 	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/localfs/localfstest"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/metrics"
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/retry"
+	"create-dropbox-backup-folder/internal/transfer"
 )
 
 // mockDropboxClient implements a mock Dropbox client for testing
@@ -275,33 +291,71 @@ func TestLogStats(t *testing.T) {
 		StartTime:       time.Now().Add(-time.Minute * 5),
 		EndTime:         time.Now(),
 	}
-
-	// Test with both count and size enabled
-	engine := &Engine{
-		config: &config.Config{
-			ShowCount: true,
-			ShowSize:  true,
+	warned := &Stats{
+		TotalFiles:       10,
+		DownloadedFiles:  2,
+		FailedFiles:      3,
+		Failures:         map[string]int{"disk_full": 2, "auth": 1},
+		TooLargeFiles:    1,
+		TooLargeBytes:    5 << 30,
+		UnsupportedFiles: 2,
+		Unsupported: []UnsupportedEntry{
+			{Path: "/movies/film.mp4", Reason: dropbox.UnsupportedRestricted},
+			{Path: "/movies/clip.mp4", Reason: dropbox.UnsupportedRestricted},
 		},
 	}
 
-	// This primarily tests that logStats doesn't panic
-	// In a real test environment, you might want to capture log output
-	engine.logStats(stats)
+	tests := []struct {
+		name  string
+		stats *Stats
+		want  []string
+		never []string
+	}{
+		{
+			name:  "clean run",
+			stats: stats,
+			want:  []string{"Backup completed", "downloaded_files=75", "skipped_files=20", "deleted_files=5"},
+			never: []string{"level=WARN"},
+		},
+		{
+			name:  "oversize files",
+			stats: warned,
+			want:  []string{"Skipped files too large for the backup file system", "files=1", "bytes=5368709120", "exFAT"},
+		},
+		{
+			name:  "unsupported files",
+			stats: warned,
+			want:  []string{"Skipped files Dropbox can't serve", "files=2", "by_reason=\"" + dropbox.UnsupportedRestricted + " 2\""},
+		},
+		{
+			name:  "failed files",
+			stats: warned,
+			want:  []string{"Files failed", "failed_files=3", "by_class=\"disk_full 2, auth 1\""},
+		},
+	}
 
-	// Test with count only
-	engine.config.ShowCount = true
-	engine.config.ShowSize = false
-	engine.logStats(stats)
+	engine := &Engine{config: &config.Config{ShowCount: true, ShowSize: true}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			defaultLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			defer slog.SetDefault(defaultLogger)
 
-	// Test with size only
-	engine.config.ShowCount = false
-	engine.config.ShowSize = true
-	engine.logStats(stats)
+			engine.logStats(tt.stats)
 
-	// Test with neither
-	engine.config.ShowCount = false
-	engine.config.ShowSize = false
-	engine.logStats(stats)
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("logStats() logged %q, missing %q", buf.String(), want)
+				}
+			}
+			for _, never := range tt.never {
+				if strings.Contains(buf.String(), never) {
+					t.Errorf("logStats() logged %q, want no %q", buf.String(), never)
+				}
+			}
+		})
+	}
 }
 
 func TestEngineCreation(t *testing.T) {
@@ -337,10 +391,358 @@ func TestEngineCreation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := New(tt.config)
+			_, err := New(tt.config, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestEnsureBackupDir(t *testing.T) {
+	backupDir := filepath.Join(t.TempDir(), "nested", "backup")
+
+	engine := &Engine{
+		config: &config.Config{
+			BackupDir: backupDir,
+		},
+	}
+
+	if err := engine.ensureBackupDir(); err != nil {
+		t.Fatalf("ensureBackupDir() error = %v", err)
+	}
+
+	info, err := os.Stat(backupDir)
+	if err != nil {
+		t.Fatalf("backup directory was not created: %v", err)
+	}
+	if !info.IsDir() {
+		t.Errorf("backup path %s is not a directory", backupDir)
+	}
+}
+
+func TestEnsureBackupDirPermissionDenied(t *testing.T) {
+	backupDir := filepath.Join(t.TempDir(), "backup")
+	fsys := localfstest.New()
+	fsys.Fail(localfstest.OpMkdir, backupDir, os.ErrPermission)
+
+	engine := &Engine{config: &config.Config{BackupDir: backupDir}, fs: fsys}
+
+	err := engine.ensureBackupDir()
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("ensureBackupDir() error = %v, want permission denied", err)
+	}
+	if !strings.Contains(err.Error(), "failed to create backup directory") {
+		t.Errorf("ensureBackupDir() error = %q", err.Error())
+	}
+}
+
+func TestDeleteRoots(t *testing.T) {
+	tests := []struct {
+		name        string
+		remotePaths []string
+		want        []string
+	}{
+		{
+			name: "whole account",
+			want: []string{"/backup"},
+		},
+		{
+			name:        "selected folders",
+			remotePaths: []string{"/Photos", "/documents/work"},
+			want:        []string{"/backup/photos", "/backup/documents/work"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{
+				config: &config.Config{
+					BackupDir:   "/backup",
+					RemotePaths: tt.remotePaths,
+				},
+			}
+
+			got := engine.deleteRoots()
+			if len(got) != len(tt.want) {
+				t.Fatalf("deleteRoots() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("deleteRoots()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	metricsFile := filepath.Join(t.TempDir(), "backup.prom")
+	engine := &Engine{
+		config: &config.Config{
+			MetricsFile: metricsFile,
+		},
+	}
+
+	start := time.Now()
+	stats := &Stats{
+		DownloadedFiles: 4,
+		TotalBytes:      2048,
+		StartTime:       start,
+		EndTime:         start.Add(time.Minute),
+		APILatency: map[string]metrics.Summary{
+			dropbox.OpDownload: {Count: 4, P50: 100 * time.Millisecond},
+		},
+		Folders: map[string]FolderStats{
+			"/Photos": {DownloadedFiles: 4, DownloadedBytes: 2048, Duration: 90 * time.Second},
+		},
+	}
+
+	if err := engine.writeMetrics(stats, nil); err != nil {
+		t.Fatalf("writeMetrics() error = %v", err)
+	}
+
+	data, err := os.ReadFile(metricsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"dropbox_backup_success 1",
+		"dropbox_backup_files_downloaded 4",
+		"dropbox_backup_bytes_downloaded 2048",
+		`dropbox_backup_folder_bytes_downloaded{folder="/Photos"} 2048`,
+		`dropbox_backup_folder_transfer_seconds{folder="/Photos"} 90`,
+		`dropbox_backup_api_latency_seconds{op="download",quantile="0.5"} 0.1`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("metrics file missing %q", want)
+		}
+	}
+}
+
+func TestDeleteOrphanedFilesManifest(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, name := range []string{"keep.txt", "orphan.txt", accountmeta.FileName} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := manifest.Load(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set("/keep.txt", manifest.Entry{Rev: "a"})
+	m.Set("/orphan.txt", manifest.Entry{Rev: "b"})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &Engine{
+		config:   &config.Config{BackupDir: tempDir, Delete: true},
+		manifest: m,
+	}
+
+	stats := &Stats{}
+	files := []dropbox.FileInfo{{Path: "/keep.txt", Name: "keep.txt"}}
+	if err := engine.deleteOrphanedFiles(context.Background(), files, stats); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+
+	if stats.DeletedFiles != 1 {
+		t.Errorf("DeletedFiles = %d, want 1", stats.DeletedFiles)
+	}
+	if _, err := os.Stat(manifest.Path(tempDir)); err != nil {
+		t.Errorf("manifest was deleted: %v", err)
+	}
+	if _, err := os.Stat(accountmeta.Path(tempDir)); err != nil {
+		t.Errorf("account metadata was deleted: %v", err)
+	}
+	if _, ok := m.Get("/orphan.txt"); ok {
+		t.Error("manifest entry for deleted file was kept")
+	}
+	if _, ok := m.Get("/keep.txt"); !ok {
+		t.Error("manifest entry for kept file was dropped")
+	}
+}
+
+func TestDeleteOrphanedFilesRemoveFails(t *testing.T) {
+	tempDir := t.TempDir()
+	orphan := filepath.Join(tempDir, "orphan.txt")
+	if err := os.WriteFile(orphan, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys := localfstest.New()
+	fsys.Fail(localfstest.OpRemove, orphan, os.ErrPermission)
+
+	engine := &Engine{config: &config.Config{BackupDir: tempDir, Delete: true}, fs: fsys}
+	stats := &Stats{}
+	err := engine.deleteOrphanedFiles(context.Background(), nil, stats)
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("deleteOrphanedFiles() error = %v, want permission denied", err)
+	}
+	if stats.DeletedFiles != 0 {
+		t.Errorf("DeletedFiles = %d, want 0", stats.DeletedFiles)
+	}
+}
+
+func TestFetchClass(t *testing.T) {
+	stall := fmt.Errorf("failed: %w", &transfer.StallError{After: time.Minute})
+	if got := fetchClass(stall); got != retry.Timeout {
+		t.Errorf("fetchClass(stall) = %q, want %q", got, retry.Timeout)
+	}
+	mismatch := fmt.Errorf("%w: received a, Dropbox b", errChecksumMismatch)
+	if got := fetchClass(mismatch); got != retry.Network {
+		t.Errorf("fetchClass(mismatch) = %q, want %q", got, retry.Network)
+	}
+	if got := fetchClass(errors.New("disk full")); got != "" {
+		t.Errorf("fetchClass(other) = %q, want empty", got)
+	}
+}
+
+func TestRunDownloadsLargeFileLane(t *testing.T) {
+	engine := &Engine{
+		config:    &config.Config{LargeFileThreshold: "1M", LargeFileConcurrency: 1},
+		transfers: transfer.New(transfer.Options{Concurrency: 4}),
+	}
+	var downloads []dropbox.FileInfo
+	for i := range 12 {
+		size := uint64(1024)
+		if i%3 == 0 {
+			size = 5 << 20
+		}
+		downloads = append(downloads, dropbox.FileInfo{Path: fmt.Sprintf("/f%d", i), Size: size})
+	}
+
+	var mu sync.Mutex
+	running := map[bool]int{}
+	peak := map[bool]int{}
+	var calls atomic.Int32
+	err := engine.runDownloads(context.Background(), downloads, func(ctx context.Context, i int) error {
+		large := downloads[i].Size >= 1<<20
+		mu.Lock()
+		running[large]++
+		peak[large] = max(peak[large], running[large])
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running[large]--
+		mu.Unlock()
+		calls.Add(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runDownloads() error = %v", err)
+	}
+	if calls.Load() != 12 {
+		t.Errorf("calls = %d, want 12", calls.Load())
+	}
+	if peak[true] != 1 {
+		t.Errorf("peak large file downloads = %d, want 1", peak[true])
+	}
+	if peak[false] < 2 {
+		t.Errorf("peak small file downloads = %d, want them in parallel", peak[false])
+	}
+}
+
+func TestDryRunOutput(t *testing.T) {
+	tests := []struct {
+		format string
+		// lines tells whether stdout is a JSON document per line rather than
+		// a single one
+		lines bool
+	}{
+		{format: output.Text},
+		{format: output.JSON},
+		{format: output.JSONStream, lines: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			tempDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tempDir, "orphan.txt"), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout bytes.Buffer
+			out, err := output.New(tt.format, &stdout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := &Engine{config: &config.Config{BackupDir: tempDir, Delete: true, DryRun: true}, out: out}
+			if streamer, ok := out.(output.Streamer); ok {
+				engine.OnResult(func(result Result) {
+					if err := streamer.Event("file", result); err != nil {
+						t.Errorf("Event() error = %v", err)
+					}
+				})
+			}
+
+			stats := &Stats{}
+			file := dropbox.FileInfo{Path: "/new.txt", Name: "new.txt", Size: 42, ModTime: time.Now()}
+			result, err := engine.downloadFile(context.Background(), file)
+			if err != nil {
+				t.Fatalf("downloadFile() error = %v", err)
+			}
+			engine.record(stats, result)
+			if err := engine.deleteOrphanedFiles(context.Background(), []dropbox.FileInfo{file}, stats); err != nil {
+				t.Fatalf("deleteOrphanedFiles() error = %v", err)
+			}
+			if err := out.Report(stats.Report(true, true, false, false, false)); err != nil {
+				t.Fatalf("Report() error = %v", err)
+			}
+
+			if tt.format == output.Text {
+				// The dry-run lines go through the formatter, not around it
+				for _, want := range []string{"[dry-run] download /new.txt", "[dry-run] delete " + filepath.Join(tempDir, "orphan.txt")} {
+					if !strings.Contains(stdout.String(), want) {
+						t.Errorf("stdout = %q, want %q", stdout.String(), want)
+					}
+				}
+				return
+			}
+
+			documents := [][]byte{stdout.Bytes()}
+			if tt.lines {
+				documents = bytes.Split(bytes.TrimSuffix(stdout.Bytes(), []byte("\n")), []byte("\n"))
+			}
+			for _, document := range documents {
+				var decoded map[string]any
+				if err := json.Unmarshal(document, &decoded); err != nil {
+					t.Fatalf("stdout isn't JSON: %v\n%s", err, stdout.String())
+				}
+			}
+			if tt.lines && len(documents) != 3 {
+				t.Errorf("got %d lines, want a download, a delete and the summary:\n%s", len(documents), stdout.String())
+			}
+		})
+	}
+}
+
+func TestReportListProgress(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: output.Text, want: "Listing: 1200 entries in 35 folders, at /photos/2019\n"},
+		{format: output.JSON, want: ""},
+		{format: output.JSONStream, want: `{"type":"listing","entries":1200,"folders":35,"path":"/photos/2019"}` + "\n"},
+		{format: output.Quiet, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var stdout bytes.Buffer
+			out, err := output.New(tt.format, &stdout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := &Engine{out: out}
+			engine.reportListProgress(dropbox.ListProgress{Entries: 1200, Folders: 35, Path: "/photos/2019"})
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
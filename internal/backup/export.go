@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// exportExtensions maps Dropbox export formats to local file extensions where
+// they differ from the format name
+var exportExtensions = map[string]string{
+	"markdown": "md",
+}
+
+// localPath returns where a Dropbox file is stored in the backup directory.
+// Files outside the home namespace are stored below their namespace's root,
+// files in shared folders may be relocated by the layout (see layoutPath),
+// and export-only files get the extension of their export format appended
+// (e.g. "notes.paper" becomes "notes.paper.md").
+func (e *Engine) localPath(file dropbox.FileInfo) string {
+	localPath := LocalPath(e.config.BackupDir, e.layoutPath(manifest.Key(file.Namespace, file.Path)))
+	if file.ExportAs == "" {
+		return localPath
+	}
+
+	format := e.exportFormat(file)
+	if ext, ok := exportExtensions[format]; ok {
+		format = ext
+	}
+	return localPath + "." + format
+}
+
+// exportFormat picks the format an export-only file is downloaded in: the one
+// configured for its extension if Dropbox offers it, otherwise the default
+func (e *Engine) exportFormat(file dropbox.FileInfo) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Name)), ".")
+	format, ok := e.config.ExportFormats[ext]
+	if !ok || format == file.ExportAs {
+		return file.ExportAs
+	}
+
+	if !slices.Contains(file.ExportOptions, format) {
+		slog.Warn("Configured export format not available, using default",
+			slog.String("path", file.Path),
+			slog.String("format", format),
+			slog.String("default", file.ExportAs),
+		)
+		return file.ExportAs
+	}
+	return format
+}
+
+// openRemote opens a Dropbox file for reading from offset on, exporting it
+// if it cannot be downloaded directly. Exports always start at the beginning.
+func (e *Engine) openRemote(ctx context.Context, file dropbox.FileInfo, offset int64) (io.ReadCloser, error) {
+	client := e.clientFor(file)
+	if file.ExportAs != "" {
+		return client.Export(ctx, file.Path, e.exportFormat(file))
+	}
+	if offset > 0 {
+		return client.DownloadRange(ctx, file.Path, file.Rev, offset)
+	}
+
+	reader, _, err := client.Download(ctx, file.Path)
+	return reader, err
+}
@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"path/filepath"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+func TestLocalPathAndExportFormat(t *testing.T) {
+	engine := &Engine{
+		config: &config.Config{
+			BackupDir:     "/backup",
+			ExportFormats: map[string]string{"paper": "html", "gsheet": "ods"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		file       dropbox.FileInfo
+		wantFormat string
+		wantPath   string
+	}{
+		{
+			name:     "regular file",
+			file:     dropbox.FileInfo{Path: "/docs/report.pdf", Name: "report.pdf"},
+			wantPath: "/backup/docs/report.pdf",
+		},
+		{
+			name:       "configured format offered",
+			file:       dropbox.FileInfo{Path: "/docs/notes.paper", Name: "Notes.paper", ExportAs: "markdown", ExportOptions: []string{"markdown", "html"}},
+			wantFormat: "html",
+			wantPath:   "/backup/docs/notes.paper.html",
+		},
+		{
+			name:       "configured format not offered",
+			file:       dropbox.FileInfo{Path: "/sheet.gsheet", Name: "sheet.gsheet", ExportAs: "xlsx", ExportOptions: []string{"xlsx"}},
+			wantFormat: "xlsx",
+			wantPath:   "/backup/sheet.gsheet.xlsx",
+		},
+		{
+			name:     "team space file",
+			file:     dropbox.FileInfo{Path: "/docs/report.pdf", Name: "report.pdf", Namespace: "4242"},
+			wantPath: "/backup/ns-4242/docs/report.pdf",
+		},
+		{
+			name:       "default format with mapped extension",
+			file:       dropbox.FileInfo{Path: "/plan.papert", Name: "plan.papert", ExportAs: "markdown"},
+			wantFormat: "markdown",
+			wantPath:   "/backup/plan.papert.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantFormat != "" {
+				if got := engine.exportFormat(tt.file); got != tt.wantFormat {
+					t.Errorf("exportFormat() = %q, want %q", got, tt.wantFormat)
+				}
+			}
+			if got := engine.localPath(tt.file); got != filepath.FromSlash(tt.wantPath) {
+				t.Errorf("localPath() = %q, want %q", got, tt.wantPath)
+			}
+		})
+	}
+}
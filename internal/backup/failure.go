@@ -0,0 +1,115 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/netwatch"
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// Failure classes that failed files are counted under in Stats.Failures
+const (
+	FailureAuth        = "auth"
+	FailureRateLimit   = "rate_limit"
+	FailurePathTooLong = "path_too_long"
+	FailureUnsafePath  = "unsafe_path"
+	FailureTooLarge    = "too_large"
+	FailureDiskFull    = "disk_full"
+	FailureChecksum    = "checksum_mismatch"
+	FailureTimeout     = "timeout"
+	FailureNetwork     = "network"
+	FailurePanic       = "panic"
+	FailureOther       = "other"
+)
+
+var (
+	// errChecksumMismatch is returned when a downloaded copy doesn't match
+	// the Dropbox content hash
+	errChecksumMismatch = errors.New("content hash mismatch")
+
+	// errFileTimeout is returned when a download exceeds --file-timeout. It
+	// doesn't wrap the context error, so it isn't retried as an outage.
+	errFileTimeout = errors.New("download timed out")
+
+	// errUnsafePath is returned for a remote entry whose local path would
+	// be outside the backup directory
+	errUnsafePath = errors.New("local path outside the backup directory")
+
+	// errTooLarge is returned with --on-oversize fail for a file larger than
+	// the backup file system can store
+	errTooLarge = errors.New("file too large for the backup file system")
+)
+
+// classifyFailure returns the failure class of a file's error
+func classifyFailure(err error) string {
+	var netErr net.Error
+	var panicErr *transfer.PanicError
+	var stallErr *transfer.StallError
+	switch {
+	case errors.As(err, &panicErr):
+		return FailurePanic
+	case errors.Is(err, errChecksumMismatch):
+		return FailureChecksum
+	case errors.Is(err, errUnsafePath):
+		return FailureUnsafePath
+	case errors.Is(err, errTooLarge):
+		return FailureTooLarge
+	case dropbox.IsAuthError(err):
+		return FailureAuth
+	case dropbox.IsRateLimited(err):
+		return FailureRateLimit
+	case isAnyOf(err, pathTooLongErrors):
+		return FailurePathTooLong
+	case isAnyOf(err, diskFullErrors):
+		return FailureDiskFull
+	case errors.Is(err, errFileTimeout), errors.Is(err, context.DeadlineExceeded), errors.As(err, &stallErr),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case netwatch.IsNetworkError(err):
+		return FailureNetwork
+	}
+	return FailureOther
+}
+
+// isAnyOf reports whether err matches one of targets
+func isAnyOf(err error, targets []error) bool {
+	return slices.ContainsFunc(targets, func(target error) bool { return errors.Is(err, target) })
+}
+
+// addFailure records a failed file under the class of its error. It is safe
+// to call from concurrent transfers.
+func (e *Engine) addFailure(stats *Stats, path string, err error) {
+	e.record(stats, Result{Path: path}.failed(err))
+}
+
+// FailureSummary describes the failures by class, most frequent first, e.g.
+// "disk_full 132, auth 2"; empty if nothing failed
+func (s *Stats) FailureSummary() string {
+	return countSummary(s.Failures)
+}
+
+// countSummary lists counts by key, most frequent first
+func countSummary(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if diff := counts[b] - counts[a]; diff != 0 {
+			return diff
+		}
+		return strings.Compare(a, b)
+	})
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + " " + output.Count(counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package backup
+
+import "syscall"
+
+// Errors the file system reports for paths and full disks
+var (
+	pathTooLongErrors = []error{syscall.ENAMETOOLONG}
+	diskFullErrors    = []error{syscall.ENOSPC, syscall.EDQUOT}
+)
@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/transfer"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "checksum", err: fmt.Errorf("%w: local a, Dropbox b", errChecksumMismatch), want: FailureChecksum},
+		{name: "expired token", err: fmt.Errorf("failed to download: %w", auth.AuthAPIError{}), want: FailureAuth},
+		{name: "rate limited", err: auth.RateLimitAPIError{}, want: FailureRateLimit},
+		{name: "disk full", err: &os.PathError{Op: "write", Path: "/backup/a", Err: diskFullErrors[0]}, want: FailureDiskFull},
+		{name: "unsafe path", err: fmt.Errorf("%w: /etc/x", errUnsafePath), want: FailureUnsafePath},
+		{name: "path too long", err: &os.PathError{Op: "open", Path: "/backup/a", Err: pathTooLongErrors[0]}, want: FailurePathTooLong},
+		{name: "file timeout", err: fmt.Errorf("%w after 1h", errFileTimeout), want: FailureTimeout},
+		{name: "API timeout", err: context.DeadlineExceeded, want: FailureTimeout},
+		{name: "connection reset", err: syscall.ECONNRESET, want: FailureNetwork},
+		{name: "stall", err: &transfer.StallError{After: time.Minute}, want: FailureTimeout},
+		{name: "panic", err: fmt.Errorf("failed to download /a: %w", &transfer.PanicError{Value: "boom"}), want: FailurePanic},
+		{name: "anything else", err: errors.New("unexpected"), want: FailureOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyFailure(tt.err); got != tt.want {
+				t.Errorf("classifyFailure(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureSummary(t *testing.T) {
+	engine := &Engine{}
+	stats := &Stats{}
+	if got := stats.FailureSummary(); got != "" {
+		t.Errorf("FailureSummary() = %q, want empty", got)
+	}
+
+	for range 3 {
+		engine.addFailure(stats, "/a", auth.RateLimitAPIError{})
+	}
+	engine.addFailure(stats, "/b", errors.New("unexpected"))
+	engine.addFailure(stats, "/c", auth.AuthAPIError{})
+
+	if stats.FailedFiles != 5 {
+		t.Errorf("FailedFiles = %d, want 5", stats.FailedFiles)
+	}
+	if got, want := stats.FailureSummary(), "rate_limit 3, auth 1, other 1"; got != want {
+		t.Errorf("FailureSummary() = %q, want %q", got, want)
+	}
+}
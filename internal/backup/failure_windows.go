@@ -0,0 +1,15 @@
+package backup
+
+import "syscall"
+
+// Errors Windows reports for paths and full disks, which the POSIX error
+// constants of package syscall don't match
+var (
+	pathTooLongErrors = []error{
+		syscall.Errno(206), // ERROR_FILENAME_EXCED_RANGE
+	}
+	diskFullErrors = []error{
+		syscall.Errno(112), // ERROR_DISK_FULL
+		syscall.Errno(39),  // ERROR_HANDLE_DISK_FULL
+	}
+)
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package backup
+
+import (
+	"os"
+	"time"
+)
+
+// setFileTimes sets the access and modification times of a downloaded file.
+// The creation time can't be set on these platforms.
+func setFileTimes(path string, modTime time.Time) error {
+	return os.Chtimes(path, modTime, modTime)
+}
+
+// hideFile does nothing: names starting with a dot already hide files here
+func hideFile(path string) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetFileTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".hidden.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := setFileTimes(path, modTime); err != nil {
+		t.Fatalf("setFileTimes() error = %v", err)
+	}
+	if err := hideFile(path); err != nil {
+		t.Fatalf("hideFile() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("ModTime() = %v, want %v", info.ModTime(), modTime)
+	}
+}
@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// setFileTimes sets the creation, access and modification times of a
+// downloaded file. os.Chtimes leaves the creation time at the download time,
+// which Explorer shows as "Date created".
+func setFileTimes(path string, modTime time.Time) error {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	// FILE_WRITE_ATTRIBUTES is allowed on read-only files too
+	handle, err := syscall.CreateFile(name, syscall.FILE_WRITE_ATTRIBUTES,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer syscall.CloseHandle(handle)
+
+	ft := syscall.NsecToFiletime(modTime.UnixNano())
+	if err := syscall.SetFileTime(handle, &ft, &ft, &ft); err != nil {
+		return fmt.Errorf("failed to set file times of %s: %w", path, err)
+	}
+	return nil
+}
+
+// hideFile sets the hidden attribute of a file, keeping its other
+// attributes such as read-only
+func hideFile(path string) error {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	attrs, err := syscall.GetFileAttributes(name)
+	if err != nil {
+		return fmt.Errorf("failed to read attributes of %s: %w", path, err)
+	}
+	if attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0 {
+		return nil
+	}
+	if err := syscall.SetFileAttributes(name, attrs|syscall.FILE_ATTRIBUTE_HIDDEN); err != nil {
+		return fmt.Errorf("failed to hide %s: %w", path, err)
+	}
+	return nil
+}
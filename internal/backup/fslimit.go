@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fatMaxFileSize is the largest file FAT file systems can store
+const fatMaxFileSize = 4<<30 - 1
+
+// fileSizeLimits are the largest files of the file systems that limit them,
+// by the lowercase type fileSystemType reports
+var fileSizeLimits = map[string]uint64{
+	"vfat":  fatMaxFileSize, // Linux
+	"msdos": fatMaxFileSize, // macOS
+	"fat":   fatMaxFileSize, // Windows
+	"fat32": fatMaxFileSize,
+}
+
+// detectMaxFileSize returns the largest file the file system holding dir can
+// store and the type of that file system; 0 if the size is unlimited or
+// unknown. A dir that doesn't exist yet, e.g. in a dry run, is looked up on
+// its closest existing parent.
+func detectMaxFileSize(dir string) (uint64, string) {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return 0, ""
+		}
+		dir = parent
+	}
+
+	fsType := strings.ToLower(fileSystemType(dir))
+	return fileSizeLimits[fsType], fsType
+}
@@ -0,0 +1,20 @@
+package backup
+
+import "syscall"
+
+// fileSystemType names the file system holding path (e.g. "msdos" or
+// "apfs"), empty if unknown
+func fileSystemType(path string) string {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return ""
+	}
+	name := make([]byte, 0, len(stat.Fstypename))
+	for _, c := range stat.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name)
+}
@@ -0,0 +1,19 @@
+package backup
+
+import "syscall"
+
+// msdosSuperMagic is the file system type of FAT volumes
+const msdosSuperMagic = 0x4d44
+
+// fileSystemType names the file system holding path, empty if unknown. Only
+// types with a file size limit are told apart.
+func fileSystemType(path string) string {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return ""
+	}
+	if stat.Type == msdosSuperMagic {
+		return "vfat"
+	}
+	return ""
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package backup
+
+// fileSystemType names the file system holding path; it is unknown on this
+// platform
+func fileSystemType(path string) string {
+	return ""
+}
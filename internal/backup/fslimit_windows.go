@@ -0,0 +1,34 @@
+package backup
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var procGetVolumeInformation = syscall.NewLazyDLL("kernel32.dll").NewProc("GetVolumeInformationW")
+
+// fileSystemType names the file system of the volume holding path (e.g.
+// "FAT32" or "NTFS"), empty if unknown
+func fileSystemType(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	root, err := syscall.UTF16PtrFromString(filepath.VolumeName(abs) + `\`)
+	if err != nil {
+		return ""
+	}
+
+	var name [syscall.MAX_PATH + 1]uint16
+	ok, _, _ := procGetVolumeInformation.Call(
+		uintptr(unsafe.Pointer(root)),
+		0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&name[0])),
+		uintptr(len(name)),
+	)
+	if ok == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(name[:])
+}
@@ -0,0 +1,43 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"create-dropbox-backup-folder/internal/hook"
+)
+
+// rememberChange records a file written or deleted by this run for the
+// post-run command
+func (e *Engine) rememberChange(localPath string, deleted bool) {
+	if e.config.PostRunCommand == "" {
+		return
+	}
+	rel := strings.TrimPrefix(e.relPath(localPath), "/")
+	e.changesMu.Lock()
+	defer e.changesMu.Unlock()
+	if deleted {
+		e.changes.Deleted = append(e.changes.Deleted, rel)
+	} else {
+		e.changes.Changed = append(e.changes.Changed, rel)
+	}
+}
+
+// runPostCommand runs the post-run command with the files this run changed;
+// not if nothing changed or in a dry run
+func (e *Engine) runPostCommand(ctx context.Context) error {
+	if e.config.PostRunCommand == "" || e.config.DryRun {
+		return nil
+	}
+	e.changesMu.Lock()
+	changes := e.changes
+	e.changes = hook.Changes{}
+	e.changesMu.Unlock()
+
+	if changes.Empty() {
+		slog.Info("Skipping post-run command, nothing changed")
+		return nil
+	}
+	return hook.Run(ctx, e.config.PostRunCommand, e.config.BackupDir, changes)
+}
@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/hook"
+)
+
+func TestRememberChange(t *testing.T) {
+	dir := t.TempDir()
+	engine := &Engine{config: &config.Config{BackupDir: dir}}
+
+	engine.rememberChange(filepath.Join(dir, "a.txt"), false)
+	if !engine.changes.Empty() {
+		t.Fatalf("changes recorded without a post-run command: %+v", engine.changes)
+	}
+
+	engine.config.PostRunCommand = "true"
+	engine.rememberChange(filepath.Join(dir, "docs", "a.txt"), false)
+	engine.rememberChange(filepath.Join(dir, "old.txt"), true)
+
+	want := hook.Changes{Changed: []string{"docs/a.txt"}, Deleted: []string{"old.txt"}}
+	if !slices.Equal(engine.changes.Changed, want.Changed) || !slices.Equal(engine.changes.Deleted, want.Deleted) {
+		t.Errorf("changes = %+v, want %+v", engine.changes, want)
+	}
+}
+
+func TestRunPostCommandSkips(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.Config
+		changes hook.Changes
+	}{
+		{name: "nothing changed", config: config.Config{PostRunCommand: "exit 1"}},
+		{name: "dry run", config: config.Config{PostRunCommand: "exit 1", DryRun: true}, changes: hook.Changes{Changed: []string{"a.txt"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.BackupDir = t.TempDir()
+			engine := &Engine{config: &tt.config, changes: tt.changes}
+			if err := engine.runPostCommand(t.Context()); err != nil {
+				t.Errorf("runPostCommand() error = %v, want the command skipped", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/listing"
+)
+
+// listPaths lists the given folders, or the whole account if there are none.
+// When the backup directory holds a listing of the same folders from an
+// earlier run, only the changes since then are fetched from Dropbox and
+// applied to it.
+func (e *Engine) listPaths(ctx context.Context, paths []string) ([]dropbox.FileInfo, error) {
+	e.listState = nil
+	if !e.config.FullListing {
+		state, err := listing.Load(e.config.BackupDir)
+		if err != nil {
+			slog.Warn("Ignoring listing of the last run", slog.String("error", err.Error()))
+		}
+		if state != nil && state.Covers(paths) {
+			err := e.continueListing(ctx, state)
+			if err == nil {
+				e.listState = state
+				return state.Files, nil
+			}
+			if !dropbox.IsCursorReset(err) {
+				return nil, err
+			}
+			slog.Warn("Dropbox can no longer list changes since the last run, listing everything")
+		}
+	}
+
+	// Take the cursors first, so changes made while listing are picked up
+	// by the next run
+	var cursors []string
+	for _, root := range listing.Roots(paths) {
+		cursor, err := e.dropboxClient.LatestCursor(ctx, root)
+		if err != nil {
+			slog.Warn("The next run will list everything again", slog.String("error", err.Error()))
+			cursors = nil
+			break
+		}
+		cursors = append(cursors, cursor)
+	}
+
+	files, err := e.dropboxClient.ListPaths(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	if cursors != nil {
+		e.listState = &listing.State{Paths: paths, Cursors: cursors, Files: files}
+	}
+	return files, nil
+}
+
+// continueListing brings a listing up to date with the changes since its
+// cursors were taken
+func (e *Engine) continueListing(ctx context.Context, state *listing.State) error {
+	added, deleted := 0, 0
+	for i, cursor := range state.Cursors {
+		changes, err := e.dropboxClient.ListChanges(ctx, cursor)
+		if err != nil {
+			return err
+		}
+		state.Apply(changes)
+		state.Cursors[i] = changes.Cursor
+		added += len(changes.Entries)
+		deleted += len(changes.Deleted)
+	}
+
+	slog.Info("Listed changes since the last run",
+		slog.Int("changed", added),
+		slog.Int("deleted", deleted),
+		slog.Time("last_run", state.Updated),
+	)
+	return nil
+}
+
+// saveListing keeps the listing of this run for the next one
+func (e *Engine) saveListing() error {
+	if e.listState == nil {
+		return nil
+	}
+	e.listState.Updated = e.now()
+	if err := e.listState.Save(e.config.BackupDir); err != nil {
+		return fmt.Errorf("failed to save listing: %w", err)
+	}
+	return nil
+}
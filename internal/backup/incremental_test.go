@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/listing"
+)
+
+func TestSaveListing(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	engine := &Engine{config: &config.Config{BackupDir: dir}, clock: func() time.Time { return now }}
+
+	if err := engine.saveListing(); err != nil {
+		t.Fatalf("saveListing() without listing error = %v", err)
+	}
+	if _, err := os.Stat(listing.Path(dir)); !os.IsNotExist(err) {
+		t.Fatalf("listing saved without cursors: %v", err)
+	}
+
+	engine.listState = &listing.State{Cursors: []string{"cursor"}, Files: []dropbox.FileInfo{{Path: "/a.txt"}}}
+	if err := engine.saveListing(); err != nil {
+		t.Fatalf("saveListing() error = %v", err)
+	}
+	state, err := listing.Load(dir)
+	if err != nil || state == nil {
+		t.Fatalf("Load() = %v, %v", state, err)
+	}
+	if !state.Updated.Equal(now) || !state.Covers(nil) {
+		t.Errorf("saved listing = %+v, want one updated at %v covering the account", state, now)
+	}
+}
+
+func TestDeleteKeepsListing(t *testing.T) {
+	dir := t.TempDir()
+	state := &listing.State{Cursors: []string{"cursor"}}
+	if err := state.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &Engine{config: &config.Config{BackupDir: dir, Delete: true}}
+	if err := engine.deleteOrphanedFiles(t.Context(), nil, &Stats{}); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+	if _, err := os.Stat(listing.Path(dir)); err != nil {
+		t.Errorf("listing was deleted: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// sharedLayoutRoot is the local directory shared folders are grouped under
+// with the shared layout
+const sharedLayoutRoot = "/shared"
+
+// resolveSharedLayout decides where each shared folder in files is stored
+// locally with the shared layout: /shared/<owner>/<folder name>, or
+// /shared/<folder name> when the owner can't be looked up. The location only
+// depends on the shared folder itself, so moving its mount point in Dropbox
+// doesn't move the local copy.
+func (e *Engine) resolveSharedLayout(ctx context.Context, files []dropbox.FileInfo) {
+	var mounts []dropbox.FileInfo
+	for _, file := range files {
+		if file.IsFolder && file.SharedFolderID != "" {
+			mounts = append(mounts, file)
+		}
+	}
+	sort.Slice(mounts, func(i, j int) bool {
+		return manifest.Key(mounts[i].Namespace, mounts[i].Path) < manifest.Key(mounts[j].Namespace, mounts[j].Path)
+	})
+
+	e.sharedRoots = make(map[string]string, len(mounts))
+	used := make(map[string]bool, len(mounts))
+	lookupFailed := false
+	for _, mount := range mounts {
+		folder := dropbox.SharedFolder{ID: mount.SharedFolderID, Name: mount.Name}
+		if !lookupFailed {
+			found, err := e.clientFor(mount).SharedFolder(ctx, mount.SharedFolderID)
+			if err == nil {
+				folder = found
+			} else {
+				// Usually the token lacks the sharing.read scope; one warning is enough
+				lookupFailed = true
+				slog.Warn("Failed to look up shared folder owners, grouping shared folders by name only (run auth --sharing to fix)",
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+
+		root := sharedLayoutPath(folder)
+		if used[root] {
+			root += " (" + folder.ID + ")"
+		}
+		used[root] = true
+		e.sharedRoots[manifest.Key(mount.Namespace, mount.Path)] = root
+
+		slog.Debug("Placing shared folder",
+			slog.String("path", mount.Path),
+			slog.String("local_path", root),
+		)
+	}
+
+	slog.Info("Resolved shared folder layout", slog.Int("shared_folders", len(e.sharedRoots)))
+}
+
+// sharedLayoutPath returns the local directory of a shared folder, lower-cased
+// like the rest of the backup
+func sharedLayoutPath(folder dropbox.SharedFolder) string {
+	name := layoutElement(folder.Name)
+	if folder.Owner == "" {
+		return sharedLayoutRoot + "/" + name
+	}
+	return sharedLayoutRoot + "/" + layoutElement(folder.Owner) + "/" + name
+}
+
+// layoutElement turns a name into a single local path element
+func layoutElement(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(strings.ToLower(name))
+	if name == "" || name == "." || name == ".." {
+		return "unknown"
+	}
+	return name
+}
+
+// layoutPath maps a manifest key to its path relative to the backup
+// directory. Keys inside a relocated shared folder are moved to the shared
+// folder's local root; all others are stored at the key itself.
+func (e *Engine) layoutPath(key string) string {
+	if len(e.sharedRoots) == 0 {
+		return key
+	}
+	for prefix := key; prefix != "/" && prefix != "."; prefix = path.Dir(prefix) {
+		if root, ok := e.sharedRoots[prefix]; ok {
+			return root + strings.TrimPrefix(key, prefix)
+		}
+	}
+	return key
+}
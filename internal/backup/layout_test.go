@@ -0,0 +1,75 @@
+package backup
+
+import (
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+func TestSharedLayoutPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		folder dropbox.SharedFolder
+		want   string
+	}{
+		{"with owner", dropbox.SharedFolder{Name: "Q3 Plans", Owner: "Jane Doe"}, "/shared/jane doe/q3 plans"},
+		{"without owner", dropbox.SharedFolder{Name: "Q3 Plans"}, "/shared/q3 plans"},
+		{"unsafe names", dropbox.SharedFolder{Name: "..", Owner: "Acme/Sales"}, "/shared/acme_sales/unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sharedLayoutPath(tt.folder); got != tt.want {
+				t.Errorf("sharedLayoutPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutPath(t *testing.T) {
+	engine := &Engine{
+		config: &config.Config{BackupDir: "/backup"},
+		sharedRoots: map[string]string{
+			"/work/q3 plans":        "/shared/jane doe/q3 plans",
+			"/ns-4242/team/budgets": "/shared/acme/budgets",
+		},
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"/work/q3 plans/draft.txt", "/shared/jane doe/q3 plans/draft.txt"},
+		{"/work/q3 plans/a/b.txt", "/shared/jane doe/q3 plans/a/b.txt"},
+		{"/work/q3 plans archive/old.txt", "/work/q3 plans archive/old.txt"},
+		{"/ns-4242/team/budgets/2024.xlsx", "/shared/acme/budgets/2024.xlsx"},
+		{"/notes.txt", "/notes.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := engine.layoutPath(tt.key); got != tt.want {
+				t.Errorf("layoutPath(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	file := dropbox.FileInfo{Path: "/work/q3 plans/draft.txt", DisplayPath: "/Work/Q3 Plans/Draft.txt", Rev: "015f"}
+	if got := engine.localPath(file); got != "/backup/shared/jane doe/q3 plans/draft.txt" {
+		t.Errorf("localPath() = %q", got)
+	}
+
+	m, err := manifest.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.manifest = m
+	engine.recordFile(file, 10)
+	key, entry, ok := m.Resolve("/shared/jane doe/q3 plans/draft.txt")
+	if key != file.Path || !ok {
+		t.Errorf("Resolve() of relocated copy = %q, %v; want %q", key, ok, file.Path)
+	}
+	if entry.DisplayPath != file.DisplayPath {
+		t.Errorf("recorded DisplayPath = %q, want %q", entry.DisplayPath, file.DisplayPath)
+	}
+}
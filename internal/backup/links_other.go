@@ -0,0 +1,11 @@
+//go:build !unix
+
+package backup
+
+import "os"
+
+// fileLinks returns the number of hard links to a file and an identifier of
+// its inode; they aren't known from a FileInfo on this platform
+func fileLinks(info os.FileInfo) (links uint64, inode string, ok bool) {
+	return 0, "", false
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package backup
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileLinks returns the number of hard links to a file and an identifier of
+// its inode, shared by all of them; ok is false if they aren't known
+func fileLinks(info os.FileInfo) (links uint64, inode string, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, "", false
+	}
+	return uint64(stat.Nlink), fmt.Sprintf("%d:%d", stat.Dev, stat.Ino), true
+}
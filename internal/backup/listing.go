@@ -0,0 +1,84 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// listRemote lists the Dropbox files the include patterns can allow. When
+// they only name folders at the root, just those folders are listed, and with
+// --search-extensions patterns like "*.pdf" are looked up in the search index
+// instead of listing every folder. The include patterns still apply to the
+// result, so the plan is the same as after listing everything.
+func (e *Engine) listRemote(ctx context.Context) ([]dropbox.FileInfo, error) {
+	f := e.config.Filter()
+	paths := e.config.RemotePaths
+
+	if roots, ok := f.Roots(); ok {
+		paths = narrowPaths(paths, roots)
+		if len(paths) == 0 {
+			slog.Info("No selected folder can hold files matching the include patterns")
+			return nil, nil
+		}
+		slog.Info("Listing only the folders named by include patterns", slog.Any("folders", paths))
+	}
+
+	if e.config.SearchExtensions {
+		if extensions, ok := f.Extensions(); ok {
+			return e.dropboxClient.SearchExtensions(ctx, paths, extensions)
+		}
+		slog.Warn("Listing every folder: --search-extensions needs include patterns of the form *.ext only")
+	}
+
+	return e.listPaths(ctx, paths)
+}
+
+// checkRemotePaths makes sure every folder selected with --remote-path (or a
+// profile or preset) exists before anything is listed, so a typo fails the
+// run instead of backing up nothing, or with --delete removing the local copy
+func (e *Engine) checkRemotePaths(ctx context.Context) error {
+	for _, path := range e.config.RemotePaths {
+		info, err := e.dropboxClient.GetMetadata(ctx, path)
+		if dropbox.IsNotFound(err) {
+			return fmt.Errorf("remote path %s doesn't exist on Dropbox", path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check remote path %s: %w", path, err)
+		}
+		if !info.IsFolder {
+			return fmt.Errorf("remote path %s is a file, not a folder", path)
+		}
+	}
+	return nil
+}
+
+// narrowPaths returns the folders to list for include roots when only the
+// selected folders are backed up: each root inside a selected folder, and
+// each selected folder inside a root. No selection means the whole account.
+func narrowPaths(selected, roots []string) []string {
+	if len(selected) == 0 {
+		return roots
+	}
+
+	var paths []string
+	for _, root := range roots {
+		for _, folder := range selected {
+			switch {
+			case isWithin(strings.ToLower(root), strings.ToLower(folder)):
+				paths = append(paths, root)
+			case isWithin(strings.ToLower(folder), strings.ToLower(root)):
+				paths = append(paths, folder)
+			}
+		}
+	}
+	return paths
+}
+
+// isWithin reports whether a Dropbox path is a folder or below it
+func isWithin(path, folder string) bool {
+	return path == folder || strings.HasPrefix(path, strings.TrimSuffix(folder, "/")+"/")
+}
@@ -0,0 +1,30 @@
+package backup
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNarrowPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		selected []string
+		roots    []string
+		want     []string
+	}{
+		{"no selection", nil, []string{"/docs", "/photos"}, []string{"/docs", "/photos"}},
+		{"root inside selection", []string{"/Work"}, []string{"/work/reports"}, []string{"/work/reports"}},
+		{"selection inside root", []string{"/work/reports", "/Photos"}, []string{"/work"}, []string{"/work/reports"}},
+		{"same folder", []string{"/docs"}, []string{"/docs"}, []string{"/docs"}},
+		{"sibling with common prefix", []string{"/docs-old"}, []string{"/docs"}, nil},
+		{"disjoint", []string{"/photos"}, []string{"/docs"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := narrowPaths(tt.selected, tt.roots); !slices.Equal(got, tt.want) {
+				t.Errorf("narrowPaths(%v, %v) = %v, want %v", tt.selected, tt.roots, got, tt.want)
+			}
+		})
+	}
+}
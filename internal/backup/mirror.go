@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"create-dropbox-backup-folder/internal/localfs"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// mirrorPaths returns where the mirrors keep a file of the backup directory
+func (e *Engine) mirrorPaths(localPath string) ([]string, error) {
+	rel, err := filepath.Rel(e.config.BackupDir, localPath)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(e.config.Mirrors))
+	for i, mirror := range e.config.Mirrors {
+		paths[i] = filepath.Join(mirror, rel)
+	}
+	return paths, nil
+}
+
+// mirrorTarget is the part file a download is written to in one mirror
+type mirrorTarget struct {
+	path, partPath string
+	file           localfs.File
+	writer         *transfer.FileWriter
+}
+
+// mirrorSet writes a download to every mirror as it arrives, so each file
+// is downloaded once however many copies are kept
+type mirrorSet struct {
+	fs      localfs.FS
+	targets []*mirrorTarget
+	done    bool
+}
+
+// openMirrors creates the part files of a download in every mirror; nil if
+// there are no mirrors
+func (e *Engine) openMirrors(localPath, rev string) (*mirrorSet, error) {
+	if len(e.config.Mirrors) == 0 {
+		return nil, nil
+	}
+	paths, err := e.mirrorPaths(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mirrorSet{fs: e.fsys()}
+	for _, path := range paths {
+		if err := m.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			m.abort()
+			return nil, fmt.Errorf("failed to create mirror directory: %w", err)
+		}
+		partPath := transfer.PartPath(path, rev)
+		f, err := m.fs.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			m.abort()
+			return nil, fmt.Errorf("failed to create mirror file: %w", err)
+		}
+		m.targets = append(m.targets, &mirrorTarget{path: path, partPath: partPath, file: f, writer: e.transfers.Writer(f)})
+	}
+	return m, nil
+}
+
+// Write writes p to every mirror
+func (m *mirrorSet) Write(p []byte) (int, error) {
+	for _, t := range m.targets {
+		if _, err := t.writer.Write(p); err != nil {
+			return 0, fmt.Errorf("failed to write mirror %s: %w", t.path, err)
+		}
+	}
+	return len(p), nil
+}
+
+// finish moves the complete copies into place in every mirror
+func (m *mirrorSet) finish(modTime time.Time) error {
+	for _, t := range m.targets {
+		if err := t.writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write mirror %s: %w", t.path, err)
+		}
+		if err := t.file.Close(); err != nil {
+			return fmt.Errorf("failed to write mirror %s: %w", t.path, err)
+		}
+		if err := m.fs.Rename(t.partPath, t.path); err != nil {
+			return fmt.Errorf("failed to move mirror copy into place: %w", err)
+		}
+		setMirrorTimes(t.path, modTime)
+	}
+	m.done = true
+	return nil
+}
+
+// abort removes the part files of an unfinished download
+func (m *mirrorSet) abort() {
+	if m == nil || m.done {
+		return
+	}
+	for _, t := range m.targets {
+		t.file.Close()
+		m.fs.Remove(t.partPath)
+	}
+}
+
+// syncMirrors copies a file of the backup directory to the mirrors that
+// lack it or hold another version, e.g. a mirror added after the file was
+// backed up or a resumed download, which isn't written to mirrors as it
+// arrives. The copy is local; nothing is downloaded again.
+func (e *Engine) syncMirrors(localPath string) error {
+	if len(e.config.Mirrors) == 0 {
+		return nil
+	}
+	info, err := e.fsys().Stat(localPath)
+	if err != nil {
+		return err
+	}
+	paths, err := e.mirrorPaths(localPath)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if copy, err := e.fsys().Stat(path); err == nil && copy.Size() == info.Size() && copy.ModTime().Equal(info.ModTime()) {
+			continue
+		}
+		if err := e.copyToMirror(localPath, path, info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyToMirror copies a local file to its path in a mirror through a part
+// file, so an interrupted copy never replaces a good one
+func (e *Engine) copyToMirror(localPath, path string, modTime time.Time) error {
+	src, err := e.fsys().Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s for mirroring: %w", localPath, err)
+	}
+	defer src.Close()
+
+	if err := e.fsys().MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	partPath := transfer.PartPath(path, "")
+	dst, err := e.fsys().OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to create mirror file: %w", err)
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = e.fsys().Rename(partPath, path)
+	}
+	if err != nil {
+		e.fsys().Remove(partPath)
+		return fmt.Errorf("failed to copy %s to mirror: %w", localPath, err)
+	}
+
+	setMirrorTimes(path, modTime)
+	slog.Debug("Copied file to mirror", slog.String("path", path))
+	return nil
+}
+
+// deleteFromMirrors removes a file deleted from the backup directory from
+// the mirrors too
+func (e *Engine) deleteFromMirrors(localPath string) error {
+	paths, err := e.mirrorPaths(localPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := e.fsys().Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete mirror copy %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// setMirrorTimes sets the modification time of a mirror copy; a failure
+// only means the copy is compared by content next time
+func setMirrorTimes(path string, modTime time.Time) {
+	if modTime.IsZero() {
+		return
+	}
+	if err := setFileTimes(path, modTime); err != nil {
+		slog.Warn("Failed to set modification time of mirror copy",
+			slog.String("path", path),
+			slog.String("error", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func mirrorEngine(t *testing.T) (*Engine, string) {
+	t.Helper()
+	mirror := t.TempDir()
+	engine := &Engine{
+		config:    &config.Config{BackupDir: t.TempDir(), Delete: true, Mirrors: []string{mirror}},
+		transfers: transfer.New(transfer.Options{Concurrency: 1}),
+	}
+	return engine, mirror
+}
+
+func TestMirrorSet(t *testing.T) {
+	engine, mirror := mirrorEngine(t)
+	localPath := filepath.Join(engine.config.BackupDir, "docs", "a.txt")
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mirrors, err := engine.openMirrors(localPath, "015f3b2c1a")
+	if err != nil {
+		t.Fatalf("openMirrors() error = %v", err)
+	}
+	if _, err := mirrors.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := mirrors.finish(modTime); err != nil {
+		t.Fatalf("finish() error = %v", err)
+	}
+	mirrors.abort()
+
+	copyPath := filepath.Join(mirror, "docs", "a.txt")
+	data, err := os.ReadFile(copyPath)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("mirror copy = %q, %v; want hello", data, err)
+	}
+	if info, _ := os.Stat(copyPath); !info.ModTime().Equal(modTime) {
+		t.Errorf("mirror copy modified %v, want %v", info.ModTime(), modTime)
+	}
+	if _, err := os.Stat(transfer.PartPath(copyPath, "015f3b2c1a")); !os.IsNotExist(err) {
+		t.Errorf("part file left in mirror: %v", err)
+	}
+}
+
+func TestMirrorSetAbort(t *testing.T) {
+	engine, mirror := mirrorEngine(t)
+	localPath := filepath.Join(engine.config.BackupDir, "a.txt")
+
+	mirrors, err := engine.openMirrors(localPath, "015f3b2c1a")
+	if err != nil {
+		t.Fatalf("openMirrors() error = %v", err)
+	}
+	mirrors.abort()
+
+	entries, _ := os.ReadDir(mirror)
+	if len(entries) != 0 {
+		t.Errorf("mirror holds %d entries after abort, want 0", len(entries))
+	}
+}
+
+func TestSyncMirrors(t *testing.T) {
+	engine, mirror := mirrorEngine(t)
+	localPath := filepath.Join(engine.config.BackupDir, "a.txt")
+	copyPath := filepath.Join(mirror, "a.txt")
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.WriteFile(localPath, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(localPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func()
+	}{
+		{name: "missing copy", setup: func() {}},
+		{name: "other version", setup: func() {
+			os.WriteFile(copyPath, []byte("old"), 0644)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(copyPath)
+			tt.setup()
+			if err := engine.syncMirrors(localPath); err != nil {
+				t.Fatalf("syncMirrors() error = %v", err)
+			}
+			data, err := os.ReadFile(copyPath)
+			if err != nil || string(data) != "new" {
+				t.Errorf("mirror copy = %q, %v; want new", data, err)
+			}
+			if info, _ := os.Stat(copyPath); !info.ModTime().Equal(modTime) {
+				t.Errorf("mirror copy modified %v, want %v", info.ModTime(), modTime)
+			}
+		})
+	}
+}
+
+func TestDeleteOrphanedFilesFromMirrors(t *testing.T) {
+	engine, mirror := mirrorEngine(t)
+	for _, dir := range []string{engine.config.BackupDir, mirror} {
+		for _, name := range []string{"keep.txt", "gone.txt"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	files := []dropbox.FileInfo{{Path: "/keep.txt", Name: "keep.txt"}}
+	if err := engine.deleteOrphanedFiles(t.Context(), files, &Stats{}); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(mirror, "gone.txt")); !os.IsNotExist(err) {
+		t.Errorf("mirror copy of deleted file kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "keep.txt")); err != nil {
+		t.Errorf("mirror copy of kept file deleted: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// listTeamSpace lists the team space of a team member's account. The
+// member's own folder, which is mounted in the team space, is left out since
+// it is already backed up from the home namespace.
+func (e *Engine) listTeamSpace(ctx context.Context) ([]dropbox.FileInfo, error) {
+	namespaces, err := e.dropboxClient.Namespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up team space: %w", err)
+	}
+	if !namespaces.HasTeamSpace() {
+		slog.Info("Account has no team space; backing up the home namespace only")
+		return nil, nil
+	}
+
+	client := e.dropboxClient.InNamespace(ctx, namespaces.Root)
+	files, err := client.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team space: %w", err)
+	}
+
+	teamFiles := files[:0]
+	for _, file := range files {
+		if !namespaces.InHome(file.Path) {
+			teamFiles = append(teamFiles, file)
+		}
+	}
+
+	if e.namespaceClients == nil {
+		e.namespaceClients = make(map[string]*dropbox.Client)
+	}
+	e.namespaceClients[namespaces.Root] = client
+
+	slog.Info("Listed team space",
+		slog.String("namespace", namespaces.Root),
+		slog.String("local_root", manifest.Key(namespaces.Root, "/")),
+		slog.Int("total", len(teamFiles)),
+	)
+	return teamFiles, nil
+}
+
+// clientFor returns the client for the namespace a file was listed in
+func (e *Engine) clientFor(file dropbox.FileInfo) *dropbox.Client {
+	if client, ok := e.namespaceClients[file.Namespace]; ok {
+		return client
+	}
+	return e.dropboxClient
+}
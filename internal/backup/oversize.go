@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"fmt"
+	"log/slog"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// ReasonTooLarge is the Reason of a file skipped because the backup file
+// system can't store a file of its size
+const ReasonTooLarge = "too_large"
+
+// oversizeHint suggests what to do about files too large for the backup
+const oversizeHint = "back up to a drive formatted with exFAT, NTFS or another file system without a 4 GiB limit, or leave the files out with --exclude"
+
+// fileSizeLimit returns the largest file the backup directory can store,
+// configured with --max-file-size or detected, and the type of the file
+// system if detected; 0 if unlimited
+func (e *Engine) fileSizeLimit() (uint64, string) {
+	if limit, err := e.config.MaxFileSizeBytes(); err == nil && limit > 0 {
+		return limit, ""
+	}
+	limit, fsType := detectMaxFileSize(e.config.BackupDir)
+	if limit > 0 {
+		slog.Info("Backup file system limits the file size",
+			slog.String("file_system", fsType),
+			slog.Uint64("max_file_size", limit),
+		)
+	}
+	return limit, fsType
+}
+
+// oversize handles a file larger than the backup file system can store
+// before anything is written: it is skipped, or fails with --on-oversize
+// fail
+func (e *Engine) oversize(file dropbox.FileInfo) (Result, error) {
+	fileSystem := "backup file system"
+	if e.fileSystem != "" {
+		fileSystem = e.fileSystem + " " + fileSystem
+	}
+	err := fmt.Errorf("%w: %s is more than the %s the %s can store", errTooLarge,
+		formatBytes(file.Size), formatBytes(e.maxFileSize), fileSystem)
+
+	if e.config.OnOversize == config.OversizeFail {
+		return Result{}, err
+	}
+	slog.Warn("Skipping file too large for the backup file system",
+		slog.String("path", file.Path),
+		slog.String("reason", err.Error()),
+	)
+	return Result{Action: ActionSkipped, Bytes: file.Size, Reason: ReasonTooLarge}, nil
+}
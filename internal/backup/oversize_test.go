@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+func TestDetectMaxFileSize(t *testing.T) {
+	// Test machines back temp dirs with file systems without a size limit;
+	// a missing directory is looked up on its parent
+	dir := filepath.Join(t.TempDir(), "not", "yet", "created")
+	if limit, _ := detectMaxFileSize(dir); limit != 0 {
+		t.Skipf("temp dir is on a file system limited to %d bytes", limit)
+	}
+	if _, fsType := detectMaxFileSize(dir); fsType != "" {
+		if _, limited := fileSizeLimits[fsType]; limited {
+			t.Errorf("detectMaxFileSize() = 0 for %s, want its limit", fsType)
+		}
+	}
+}
+
+func TestDownloadFileOversize(t *testing.T) {
+	file := dropbox.FileInfo{Path: "/videos/big.mov", Name: "big.mov", Size: 5 << 30}
+
+	tests := []struct {
+		name       string
+		onOversize string
+		file       dropbox.FileInfo
+		wantAction string
+		wantErr    bool
+	}{
+		{name: "skip", onOversize: config.OversizeSkip, file: file, wantAction: ActionSkipped},
+		{name: "fail", onOversize: config.OversizeFail, file: file, wantErr: true},
+		{name: "download anyway", onOversize: config.OversizeDownload, file: file, wantAction: ActionDownloaded},
+		{name: "within the limit", onOversize: config.OversizeFail, file: dropbox.FileInfo{Path: "/a.txt", Name: "a.txt", Size: 1 << 20}, wantAction: ActionDownloaded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{
+				config:      &config.Config{BackupDir: t.TempDir(), DryRun: true, OnOversize: tt.onOversize},
+				maxFileSize: fatMaxFileSize,
+				fileSystem:  "vfat",
+			}
+			result, err := engine.downloadFile(context.Background(), tt.file)
+			if tt.wantErr {
+				if !errors.Is(err, errTooLarge) || classifyFailure(err) != FailureTooLarge {
+					t.Fatalf("downloadFile() error = %v, want a too large failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadFile() error = %v", err)
+			}
+			if result.Action != tt.wantAction {
+				t.Errorf("Action = %v, want %v", result.Action, tt.wantAction)
+			}
+
+			stats := &Stats{}
+			stats.add(result)
+			if tt.wantAction == ActionSkipped && (stats.TooLargeFiles != 1 || stats.TooLargeBytes != tt.file.Size) {
+				t.Errorf("too large %d files, %d bytes; want 1, %d", stats.TooLargeFiles, stats.TooLargeBytes, tt.file.Size)
+			}
+		})
+	}
+}
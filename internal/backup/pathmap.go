@@ -0,0 +1,51 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LocalPath maps a Dropbox path, or a manifest key, to its local path below
+// root. Every element of the Dropbox path becomes exactly one local path
+// element, so no name can lead outside root: the special names "." and ".."
+// are replaced, and so are separators of the local platform inside names
+// (e.g. a backslash on Windows).
+func LocalPath(root, remotePath string) string {
+	parts := []string{root}
+	for _, element := range strings.Split(remotePath, "/") {
+		if element != "" {
+			parts = append(parts, localElement(element))
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+// localElement turns a Dropbox path element into a single local path element
+func localElement(name string) string {
+	switch name {
+	case ".":
+		return "_"
+	case "..":
+		return "__"
+	}
+	if filepath.Separator != '/' {
+		name = strings.ReplaceAll(name, string(filepath.Separator), "_")
+	}
+	if volume := filepath.VolumeName(name); volume != "" {
+		// A drive letter or UNC prefix would make the element absolute
+		name = strings.ReplaceAll(volume, ":", "_") + name[len(volume):]
+	}
+	return name
+}
+
+// checkWithin returns an error wrapping errUnsafePath unless localPath is
+// below root. LocalPath never leads outside root, but other parts of a local
+// path, such as the extension of an export format, come from elsewhere.
+func checkWithin(root, localPath string) error {
+	rel, err := filepath.Rel(root, localPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return fmt.Errorf("%w: %s", errUnsafePath, localPath)
+	}
+	return nil
+}
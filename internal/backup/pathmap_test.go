@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+func TestLocalPath(t *testing.T) {
+	root := filepath.FromSlash("/backup")
+
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{remote: "/documents/report.pdf", want: "/backup/documents/report.pdf"},
+		{remote: "", want: "/backup"},
+		{remote: "/", want: "/backup"},
+		{remote: "/a//b/", want: "/backup/a/b"},
+		{remote: "/../../etc/cron.d/x", want: "/backup/__/__/etc/cron.d/x"},
+		{remote: "/a/./b", want: "/backup/a/_/b"},
+		{remote: "/...", want: "/backup/..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			if got := LocalPath(root, tt.remote); got != filepath.FromSlash(tt.want) {
+				t.Errorf("LocalPath(%q) = %q, want %q", tt.remote, got, filepath.FromSlash(tt.want))
+			}
+		})
+	}
+}
+
+func TestCheckWithin(t *testing.T) {
+	root := filepath.FromSlash("/backup")
+
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{path: "/backup/a.txt", ok: true},
+		{path: "/backup/docs/../a.txt", ok: true},
+		{path: "/backup/..a", ok: true},
+		{path: "/backup"},
+		{path: "/backup/../etc/passwd"},
+		{path: "/etc/passwd"},
+		{path: "/backup-other/a.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := checkWithin(root, filepath.FromSlash(tt.path))
+			if (err == nil) != tt.ok {
+				t.Errorf("checkWithin(%q) error = %v, want ok %v", tt.path, err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, errUnsafePath) {
+				t.Errorf("checkWithin(%q) error = %v, want errUnsafePath", tt.path, err)
+			}
+		})
+	}
+}
+
+func TestDownloadFileRejectsUnsafePath(t *testing.T) {
+	backupDir := filepath.Join(t.TempDir(), "backup")
+	engine := &Engine{config: &config.Config{BackupDir: backupDir, DryRun: true}}
+
+	// The export format is appended to the local path as an extension
+	file := dropbox.FileInfo{Path: "/notes.paper", Name: "notes.paper", ExportAs: "md/../../../escaped"}
+	_, err := engine.downloadFile(context.Background(), file)
+	if !errors.Is(err, errUnsafePath) || classifyFailure(err) != FailureUnsafePath {
+		t.Fatalf("downloadFile() error = %v, want an unsafe path failure", err)
+	}
+}
+
+func FuzzLocalPath(f *testing.F) {
+	for _, seed := range []string{
+		"/documents/report.pdf",
+		"../../etc/cron.d/x",
+		"/a/../../b",
+		"/..",
+		`/a\..\..\b`,
+		"/C:/windows",
+		"/\u202e\u0000/x",
+		"/\xff\xfe/..",
+	} {
+		f.Add(seed)
+	}
+
+	root := filepath.Join(f.TempDir(), "backup")
+	f.Fuzz(func(t *testing.T, remote string) {
+		got := LocalPath(root, remote)
+		rel, err := filepath.Rel(root, got)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+			t.Fatalf("LocalPath(%q) = %q escapes %q", remote, got, root)
+		}
+		if got != root && checkWithin(root, got) != nil {
+			t.Fatalf("LocalPath(%q) = %q is rejected by checkWithin", remote, got)
+		}
+		if LocalPath(root, remote) != got {
+			t.Fatalf("LocalPath(%q) is not deterministic", remote)
+		}
+	})
+}
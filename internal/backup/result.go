@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"time"
+)
+
+// Actions taken for a file
+const (
+	ActionDownloaded = "downloaded"
+	ActionSkipped    = "skipped"
+	ActionDeleted    = "deleted"
+	ActionFailed     = "failed"
+
+	// ActionUnsupported is a file Dropbox can't serve, see Stats.Unsupported
+	ActionUnsupported = "unsupported"
+)
+
+// Result is what happened to one file in a run. Results are passed to the
+// function set with OnResult as files finish, and add up to the counts in
+// Stats. A file that fails --verify-after gets a failed result after its
+// downloaded one.
+type Result struct {
+	// Path is the Dropbox path, or the local path of deleted files. Bytes is
+	// the size downloaded, or the size of the deleted file.
+	Path     string        `json:"path"`
+	Action   string        `json:"action"`
+	Bytes    uint64        `json:"bytes,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+
+	// Retries counts the API calls for the file that were retried
+	Retries int `json:"retries,omitempty"`
+
+	// Reason tells why a file was skipped other than being up to date, e.g.
+	// ReasonTooLarge, in which case Bytes is its size, or why Dropbox can't
+	// serve an unsupported file
+	Reason string `json:"reason,omitempty"`
+
+	// Class and Error describe why the file failed (see classifyFailure)
+	Class string `json:"class,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	// Shared marks a deleted file whose content is still referenced by other
+	// hard links, e.g. from snapshots, so deleting it freed no space
+	Shared bool `json:"shared,omitempty"`
+
+	// DryRun marks downloads and deletions --dry-run only reported
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// unverified marks the failure of a file downloaded earlier in the run,
+	// which no longer counts as downloaded
+	unverified bool
+}
+
+// failed turns a result into the failure of the file with err
+func (r Result) failed(err error) Result {
+	r.Action = ActionFailed
+	r.Class = classifyFailure(err)
+	r.Error = err.Error()
+	return r
+}
+
+// OnResult sets a function called with the result of every file of the
+// following runs, e.g. to stream them to a report. Calls don't overlap.
+func (e *Engine) OnResult(fn func(Result)) {
+	e.onResult = fn
+}
+
+// record adds the result of a file to the stats of the run and passes it to
+// the OnResult function. It is safe to call from concurrent transfers, and
+// the only way the counts of Stats change while transfers run.
+func (e *Engine) record(stats *Stats, result Result) {
+	e.resultsMu.Lock()
+	defer e.resultsMu.Unlock()
+	stats.add(result)
+	if e.onResult != nil {
+		e.onResult(result)
+	}
+}
+
+// add counts a result
+func (s *Stats) add(result Result) {
+	if result.Action != ActionDeleted && result.Action != ActionUnsupported {
+		s.countFolder(result)
+	}
+	if result.Retries > 0 {
+		s.RetriedFiles++
+		s.Retries += result.Retries
+		if len(s.Retried) < maxFileErrors {
+			s.Retried = append(s.Retried, FileRetries{Path: result.Path, Retries: result.Retries})
+		}
+	}
+	switch result.Action {
+	case ActionDownloaded:
+		s.DownloadedFiles++
+		s.TotalBytes += result.Bytes
+	case ActionSkipped:
+		s.SkippedFiles++
+		if result.Reason == ReasonTooLarge {
+			s.TooLargeFiles++
+			s.TooLargeBytes += result.Bytes
+		}
+	case ActionDeleted:
+		s.DeletedFiles++
+		s.DeletedBytes += result.Bytes
+		if !result.Shared {
+			s.FreedBytes += result.Bytes
+		}
+	case ActionUnsupported:
+		s.UnsupportedFiles++
+		s.Unsupported = append(s.Unsupported, UnsupportedEntry{Path: result.Path, Reason: result.Reason})
+	case ActionFailed:
+		if result.unverified {
+			s.DownloadedFiles--
+		}
+		s.FailedFiles++
+		if s.Failures == nil {
+			s.Failures = make(map[string]int)
+		}
+		s.Failures[result.Class]++
+		if len(s.Errors) < maxFileErrors {
+			s.Errors = append(s.Errors, FileError{Path: result.Path, Class: result.Class, Error: result.Error})
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestStatsAdd(t *testing.T) {
+	stats := &Stats{}
+	results := []Result{
+		{Path: "/a", Action: ActionDownloaded, Bytes: 10},
+		{Path: "/b", Action: ActionDownloaded, Bytes: 5, Retries: 2},
+		{Path: "/c", Action: ActionSkipped},
+		{Path: "/d", Action: ActionDeleted},
+		{Path: "/e", Action: ActionFailed, Class: FailureNetwork, Error: "connection reset", Retries: 1},
+	}
+	for _, result := range results {
+		stats.add(result)
+	}
+
+	if stats.DownloadedFiles != 2 || stats.TotalBytes != 15 || stats.SkippedFiles != 1 || stats.DeletedFiles != 1 || stats.FailedFiles != 1 {
+		t.Errorf("stats = %+v", *stats)
+	}
+	if stats.Failures[FailureNetwork] != 1 {
+		t.Errorf("Failures = %v, want network 1", stats.Failures)
+	}
+	if len(stats.Errors) != 1 || stats.Errors[0].Path != "/e" {
+		t.Errorf("Errors = %+v, want /e", stats.Errors)
+	}
+	if stats.RetriedFiles != 2 || stats.Retries != 3 {
+		t.Errorf("retried = %d files, %d retries; want 2, 3", stats.RetriedFiles, stats.Retries)
+	}
+	if len(stats.Retried) != 2 || stats.Retried[0] != (FileRetries{Path: "/b", Retries: 2}) {
+		t.Errorf("Retried = %+v, want /b and /e", stats.Retried)
+	}
+}
+
+func TestOnResult(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "orphan.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &Engine{config: &config.Config{BackupDir: tempDir, DryRun: true}}
+	var results []Result
+	engine.OnResult(func(result Result) {
+		results = append(results, result)
+	})
+
+	stats := &Stats{}
+	file := dropbox.FileInfo{Path: "/new.txt", Name: "new.txt", Size: 42, ModTime: time.Now()}
+	result, err := engine.downloadFile(context.Background(), file)
+	if err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	if result.Action != ActionDownloaded || result.Bytes != 42 || !result.DryRun {
+		t.Errorf("downloadFile() = %+v, want dry-run download of 42 bytes", result)
+	}
+
+	if err := engine.deleteOrphanedFiles(context.Background(), []dropbox.FileInfo{file}, stats); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+	engine.addFailure(stats, "/broken.txt", os.ErrPermission)
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+	if results[0].Action != ActionDeleted || results[0].Path != filepath.Join(tempDir, "orphan.txt") {
+		t.Errorf("results[0] = %+v, want deletion of orphan.txt", results[0])
+	}
+	if results[1].Action != ActionFailed || results[1].Class != FailureOther || results[1].Error == "" {
+		t.Errorf("results[1] = %+v, want failure", results[1])
+	}
+	if stats.DeletedFiles != 1 || stats.FailedFiles != 1 {
+		t.Errorf("stats = %+v, want 1 deleted and 1 failed", *stats)
+	}
+}
+
+func TestDownloadFilesCountsConcurrently(t *testing.T) {
+	tempDir := t.TempDir()
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Half the files are up to date locally, the other half dry-run downloads
+	var files []dropbox.FileInfo
+	for i := range 200 {
+		file := dropbox.FileInfo{Path: fmt.Sprintf("/f%03d.txt", i), Size: 3, ModTime: modTime}
+		if i%2 == 0 {
+			localPath := filepath.Join(tempDir, file.Path)
+			if err := os.WriteFile(localPath, []byte("abc"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(localPath, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		files = append(files, file)
+	}
+
+	engine := &Engine{
+		config:    &config.Config{BackupDir: tempDir, DryRun: true},
+		transfers: transfer.New(transfer.Options{Concurrency: 16}),
+	}
+	var results int
+	engine.OnResult(func(Result) { results++ })
+
+	stats := &Stats{}
+	if err := engine.downloadFiles(context.Background(), files, stats); err != nil {
+		t.Fatalf("downloadFiles() error = %v", err)
+	}
+	if stats.SkippedFiles != 100 || stats.DownloadedFiles != 100 || stats.TotalBytes != 300 {
+		t.Errorf("stats = %d skipped, %d downloaded, %d bytes; want 100, 100, 300", stats.SkippedFiles, stats.DownloadedFiles, stats.TotalBytes)
+	}
+	if results != 200 {
+		t.Errorf("got %d results, want 200", results)
+	}
+}
+
+func TestStatsAddUnverified(t *testing.T) {
+	stats := &Stats{}
+	stats.add(Result{Path: "/a", Action: ActionDownloaded, Bytes: 10})
+	stats.add(Result{Path: "/a", unverified: true}.failed(errChecksumMismatch))
+
+	if stats.DownloadedFiles != 0 || stats.FailedFiles != 1 || stats.Failures[FailureChecksum] != 1 {
+		t.Errorf("stats = %+v, want the download turned into a failure", *stats)
+	}
+}
+
+func TestDownloadFilesRecordsPanics(t *testing.T) {
+	// Without a Dropbox client downloading panics
+	engine := &Engine{
+		config:    &config.Config{BackupDir: t.TempDir()},
+		transfers: transfer.New(transfer.Options{Concurrency: 2}),
+	}
+	files := []dropbox.FileInfo{{Path: "/a.txt", Size: 1}, {Path: "/b.txt", Size: 1}}
+
+	stats := &Stats{}
+	err := engine.downloadFiles(context.Background(), files, stats)
+	if err == nil {
+		t.Fatal("downloadFiles() error = nil, want the panics")
+	}
+	if stats.FailedFiles != 2 || stats.Failures[FailurePanic] != 2 {
+		t.Errorf("stats = %d failed, by class %v; want 2 panics", stats.FailedFiles, stats.Failures)
+	}
+}
@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"log/slog"
+	"path/filepath"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/localfs"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// resumeOffset returns how much of a file an interrupted download already
+// wrote to its part, or 0 to start over
+func resumeOffset(fsys localfs.FS, partPath string, file dropbox.FileInfo) int64 {
+	info, err := fsys.Stat(partPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	if info.Size() >= int64(file.Size) {
+		// Longer than the file can be, so not a download of it
+		return 0
+	}
+	return info.Size()
+}
+
+// removeParts removes the parts left by interrupted downloads of a file,
+// e.g. of a revision that has since been replaced
+func (e *Engine) removeParts(localPath string) {
+	for _, path := range e.takeParts(localPath) {
+		if err := e.fsys().Remove(path); err != nil {
+			slog.Warn("Failed to remove incomplete download",
+				slog.String("path", path),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+// takeParts returns the parts left by interrupted downloads of a file and
+// forgets them. The directory of the file is read on its first download in
+// a run only, so fresh downloads into a large directory don't read it again
+// for every file. Parts written later by this run are its own and cleaned up
+// by fetch.
+func (e *Engine) takeParts(localPath string) []string {
+	e.partsMu.Lock()
+	defer e.partsMu.Unlock()
+
+	dir := filepath.Dir(localPath)
+	parts, ok := e.parts[dir]
+	if !ok {
+		parts = findParts(e.fsys(), dir)
+		if e.parts == nil {
+			e.parts = make(map[string]map[string][]string)
+		}
+		e.parts[dir] = parts
+	}
+	found := parts[localPath]
+	delete(parts, localPath)
+	return found
+}
+
+// findParts returns the parts in a directory by the file they are a download
+// of; nil if there are none
+func findParts(fsys localfs.FS, dir string) map[string][]string {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var parts map[string][]string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !transfer.IsPart(path) {
+			continue
+		}
+		if parts == nil {
+			parts = make(map[string][]string)
+		}
+		target := transfer.PartTarget(path)
+		parts[target] = append(parts[target], path)
+	}
+	return parts
+}
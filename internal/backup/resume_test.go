@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/localfs"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestResumeOffset(t *testing.T) {
+	dir := t.TempDir()
+	part := filepath.Join(dir, "a.bin.015f3b2c1a.part")
+	file := dropbox.FileInfo{Path: "/a.bin", Size: 10, Rev: "015f3b2c1a"}
+
+	if got := resumeOffset(localfs.OS, part, file); got != 0 {
+		t.Errorf("resumeOffset() without part = %d, want 0", got)
+	}
+
+	if err := os.WriteFile(part, []byte("abcd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := resumeOffset(localfs.OS, part, file); got != 4 {
+		t.Errorf("resumeOffset() = %d, want 4", got)
+	}
+
+	file.Size = 4
+	if got := resumeOffset(localfs.OS, part, file); got != 0 {
+		t.Errorf("resumeOffset() of a part as long as the file = %d, want 0", got)
+	}
+}
+
+func TestRemoveParts(t *testing.T) {
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "a.bin")
+	old := transfer.PartPath(localPath, "015f3b2c1a")
+	other := transfer.PartPath(filepath.Join(dir, "a.bin2"), "015f3b2c1a")
+	for _, path := range []string{localPath, old, other} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := &Engine{config: &config.Config{BackupDir: dir}}
+	engine.removeParts(localPath)
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("part of a.bin still exists: %v", err)
+	}
+	for _, path := range []string{localPath, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+}
+
+// readDirCounter counts the directories read from the OS file system
+type readDirCounter struct {
+	localfs.FS
+	reads atomic.Int32
+}
+
+func (c *readDirCounter) ReadDir(name string) ([]fs.DirEntry, error) {
+	c.reads.Add(1)
+	return c.FS.ReadDir(name)
+}
+
+func TestRemovePartsReadsDirectoryOnce(t *testing.T) {
+	dir := t.TempDir()
+	var parts []string
+	for i := range 50 {
+		part := transfer.PartPath(filepath.Join(dir, fmt.Sprintf("f%02d.bin", i)), "015f3b2c1a")
+		if err := os.WriteFile(part, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part)
+	}
+
+	fsys := &readDirCounter{FS: localfs.OS}
+	engine := &Engine{config: &config.Config{BackupDir: dir}, fs: fsys}
+	for i := range 100 {
+		engine.removeParts(filepath.Join(dir, fmt.Sprintf("f%02d.bin", i)))
+	}
+
+	if got := fsys.reads.Load(); got != 1 {
+		t.Errorf("directory read %d times for 100 downloads, want once", got)
+	}
+	for _, part := range parts {
+		if _, err := os.Stat(part); !os.IsNotExist(err) {
+			t.Errorf("%s still exists: %v", part, err)
+		}
+	}
+}
+
+func TestDeleteKeepsResumableParts(t *testing.T) {
+	dir := t.TempDir()
+	live := transfer.PartPath(filepath.Join(dir, "a.bin"), "015f3b2c1a")
+	orphan := transfer.PartPath(filepath.Join(dir, "gone.bin"), "015f3b2c1a")
+	for _, path := range []string{live, orphan} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := &Engine{config: &config.Config{BackupDir: dir, Delete: true}}
+	files := []dropbox.FileInfo{{Path: "/a.bin", Name: "a.bin"}}
+	if err := engine.deleteOrphanedFiles(t.Context(), files, &Stats{}); err != nil {
+		t.Fatalf("deleteOrphanedFiles() error = %v", err)
+	}
+
+	if _, err := os.Stat(live); err != nil {
+		t.Errorf("part of a file on Dropbox was deleted: %v", err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("part of a file gone from Dropbox was kept: %v", err)
+	}
+}
+
+func TestCancelLeavesResumablePart(t *testing.T) {
+	small := "finished before the interruption"
+	large := strings.Repeat("0123456789abcdef", 16<<10) // 256 KiB
+	contents := map[string]string{"/small.txt": small, "/large.bin": large}
+	// The first download of the large file stops after this many bytes until
+	// the run is cancelled
+	const sent = 128 << 10
+
+	var interrupted atomic.Bool
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Path string `json:"path"`
+		}
+		json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+		path := arg.Path
+		if strings.HasPrefix(path, "rev:") {
+			path = "/large.bin" // Only the large file is resumed
+		}
+		content := contents[path]
+		w.Header().Set("Dropbox-API-Result", fmt.Sprintf(`{".tag":"file","name":"%s","path_display":"%s","id":"id:%s",`+
+			`"client_modified":"2025-03-01T12:00:00Z","server_modified":"2025-03-01T12:00:00Z","rev":"r1","size":%d}`,
+			path[1:], path, path[1:], len(content)))
+
+		if header := r.Header.Get("Range"); header != "" {
+			ranges = append(ranges, header)
+			offset, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, "bytes="), "-"))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(content[offset:]))
+			return
+		}
+		if path == "/large.bin" && interrupted.CompareAndSwap(false, true) {
+			w.Write([]byte(content[:sent]))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	t.Cleanup(func() { dropbox.SetEndpoints(dropbox.Endpoints{}) })
+	if err := dropbox.SetEndpoints(dropbox.Endpoints{API: srv.URL, Content: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := dropbox.New("id", "secret", "token", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backupDir := t.TempDir()
+	modTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var files []dropbox.FileInfo
+	for _, path := range []string{"/small.txt", "/large.bin"} {
+		files = append(files, dropbox.FileInfo{
+			Path: path, Name: path[1:], Rev: "r1", ModTime: modTime,
+			Size: uint64(len(contents[path])), ContentHash: contentHash(t, contents[path]),
+		})
+	}
+	newEngine := func() *Engine {
+		return &Engine{
+			config:        &config.Config{BackupDir: backupDir, MaxConcurrency: 2},
+			dropboxClient: client,
+			transfers:     transfer.New(transfer.Options{Concurrency: 2}),
+		}
+	}
+
+	// Interrupt the run once the small file is done and part of the large
+	// one is written
+	largePath := filepath.Join(backupDir, "large.bin")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for {
+			_, err := os.Stat(filepath.Join(backupDir, "small.txt"))
+			if part, partErr := os.Stat(transfer.PartPath(largePath, "r1")); err == nil && partErr == nil && part.Size() > 0 {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+	}()
+	if err := newEngine().execute(ctx, files, &Stats{}); err == nil {
+		t.Fatal("execute() error = nil, want cancelled")
+	}
+
+	if _, err := os.Stat(largePath); !os.IsNotExist(err) {
+		t.Errorf("interrupted download in place: %v", err)
+	}
+	part, err := os.ReadFile(transfer.PartPath(largePath, "r1"))
+	if err != nil {
+		t.Fatalf("part of the interrupted download: %v", err)
+	}
+	if len(part) == 0 || len(part) > sent || !bytes.Equal(part, []byte(large[:len(part)])) {
+		t.Errorf("part holds %d bytes, want a prefix of the %d sent", len(part), sent)
+	}
+
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatalf("manifest after the interruption: %v", err)
+	}
+	if _, ok := m.Get("/small.txt"); !ok {
+		t.Error("manifest is missing the download finished before the interruption")
+	}
+	if _, ok := m.Get("/large.bin"); ok {
+		t.Error("manifest records the interrupted download")
+	}
+
+	// The next run picks up where the interrupted one stopped
+	if err := newEngine().execute(context.Background(), files, &Stats{}); err != nil {
+		t.Fatalf("execute() after the interruption error = %v", err)
+	}
+	if want := fmt.Sprintf("bytes=%d-", len(part)); len(ranges) != 1 || ranges[0] != want {
+		t.Errorf("resumed with ranges %v, want %s", ranges, want)
+	}
+	if got, err := os.ReadFile(largePath); err != nil || string(got) != large {
+		t.Errorf("resumed download = %d bytes, %v; want the whole file", len(got), err)
+	}
+	if _, err := os.Stat(transfer.PartPath(largePath, "r1")); !os.IsNotExist(err) {
+		t.Errorf("part left after the resumed download: %v", err)
+	}
+}
+
+func TestCheckpointManifest(t *testing.T) {
+	dir := t.TempDir()
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := &Engine{config: &config.Config{BackupDir: dir}, manifest: m}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		engine.checkpointManifest(ctx, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Recorded downloads reach the disk before the run ends
+	m.Set("/a.txt", manifest.Entry{Rev: "015f1", Size: 1})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		saved, err := manifest.Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := saved.Get("/a.txt"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("manifest checkpoint not saved")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/sidecar"
+)
+
+// recordSidecar stores the metadata of a file that is now up to date locally
+// in the sidecar of its directory
+func (e *Engine) recordSidecar(file dropbox.FileInfo, size uint64) {
+	if e.sidecars == nil {
+		return
+	}
+	localPath := e.localPath(file)
+	entry := sidecar.Entry{
+		ModTime:     file.ModTime,
+		Size:        size,
+		ContentHash: file.ContentHash,
+		Rev:         file.Rev,
+	}
+	if err := e.sidecars.Set(localPath, entry); err != nil {
+		slog.Warn("Failed to record file metadata",
+			slog.String("path", localPath),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// forgetSidecar drops the sidecar entry of a deleted local file
+func (e *Engine) forgetSidecar(localPath string) {
+	if e.sidecars == nil {
+		return
+	}
+	if err := e.sidecars.Delete(localPath); err != nil {
+		slog.Warn("Failed to forget file metadata",
+			slog.String("path", localPath),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// localModTime returns the modification time of a local file: the one
+// recorded in its sidecar if the target doesn't keep it, as long as the file
+// still has the recorded size
+func (e *Engine) localModTime(localPath string, stat os.FileInfo) time.Time {
+	if e.sidecars == nil {
+		return stat.ModTime()
+	}
+	entry, ok, err := e.sidecars.Get(localPath)
+	if err != nil {
+		slog.Warn("Failed to read file metadata",
+			slog.String("path", localPath),
+			slog.String("error", err.Error()),
+		)
+		return stat.ModTime()
+	}
+	if !ok || stat.Size() != int64(entry.Size) {
+		return stat.ModTime()
+	}
+	return entry.ModTime
+}
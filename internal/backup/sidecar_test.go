@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/sidecar"
+)
+
+func TestShouldSkipFileSidecarModTime(t *testing.T) {
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(localPath, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The target lost the modification time set at download
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(localPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	remote := dropbox.FileInfo{Path: "/report.pdf", Name: "report.pdf", Size: 7, ModTime: modTime}
+
+	tests := []struct {
+		name    string
+		sidecar bool
+		entry   *sidecar.Entry
+		want    bool
+	}{
+		{name: "recorded mtime matches", sidecar: true, entry: &sidecar.Entry{ModTime: modTime, Size: 7}, want: true},
+		{name: "file changed size since", sidecar: true, entry: &sidecar.Entry{ModTime: modTime, Size: 3}, want: false},
+		{name: "no entry", sidecar: true, want: false},
+		{name: "sidecar disabled", sidecar: false, entry: &sidecar.Entry{ModTime: modTime, Size: 7}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{config: &config.Config{BackupDir: dir, Sidecar: tt.sidecar}}
+			store := sidecar.NewStore()
+			if tt.entry != nil {
+				if err := store.Set(localPath, *tt.entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.sidecar {
+				engine.sidecars = store
+			}
+			if got := engine.shouldSkipFile(localPath, remote); got != tt.want {
+				t.Errorf("shouldSkipFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordAndForgetSidecar(t *testing.T) {
+	dir := t.TempDir()
+	engine := &Engine{
+		config:   &config.Config{BackupDir: dir, Sidecar: true},
+		sidecars: sidecar.NewStore(),
+	}
+	file := dropbox.FileInfo{Path: "/docs/a.txt", Name: "a.txt", Rev: "015f", ContentHash: "abc", ModTime: time.Now().UTC()}
+	localPath := engine.localPath(file)
+
+	engine.recordFile(file, 10)
+	entry, ok, err := engine.sidecars.Get(localPath)
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v, want entry", ok, err)
+	}
+	if entry.Rev != "015f" || entry.ContentHash != "abc" || entry.Size != 10 || !entry.ModTime.Equal(file.ModTime) {
+		t.Errorf("recorded %+v", entry)
+	}
+
+	engine.forgetFile(localPath)
+	if _, ok, _ := engine.sidecars.Get(localPath); ok {
+		t.Error("forgetFile() kept the sidecar entry")
+	}
+
+	if !engine.isBookkeeping(sidecar.Path(filepath.Join(dir, "docs"))) {
+		t.Error("isBookkeeping() = false for a sidecar file")
+	}
+}
@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// skip is a file skipped as up to date, kept for --spot-check
+type skip struct {
+	localPath string
+	file      dropbox.FileInfo
+}
+
+// Outcomes of spot-checking a skipped file
+const (
+	// spotOK means the skipped copy matches Dropbox
+	spotOK = iota
+	// spotChanged means the file changed on Dropbox or locally since it was
+	// listed, so the skip can't be judged; the next run catches up
+	spotChanged
+	// spotWrong means the copy was skipped although it differs from Dropbox
+	spotWrong
+)
+
+// rememberSkip keeps a uniform random sample of at most --spot-check of the
+// files skipped by this run (reservoir sampling), so the memory it takes
+// doesn't grow with the account. Exported files have no content hash to
+// check against and are left out.
+func (e *Engine) rememberSkip(localPath string, file dropbox.FileInfo) {
+	if e.config.SpotCheck <= 0 || file.ExportAs != "" || file.ContentHash == "" {
+		return
+	}
+	e.skipsMu.Lock()
+	defer e.skipsMu.Unlock()
+	if e.rng == nil {
+		e.rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	e.skipsSeen++
+	s := skip{localPath: localPath, file: file}
+	if len(e.skips) < e.config.SpotCheck {
+		e.skips = append(e.skips, s)
+		return
+	}
+	if i := e.rng.IntN(e.skipsSeen); i < len(e.skips) {
+		e.skips[i] = s
+	}
+}
+
+// spotCheck fetches fresh metadata for the sampled skipped files and decides
+// again whether to skip them, hashing each local copy to see whether the
+// decision was right. Copies skipped although they differ from Dropbox are
+// removed and forgotten, so the next run downloads them again; if there are
+// any, the skip heuristics can't be trusted and the run fails.
+func (e *Engine) spotCheck(ctx context.Context, stats *Stats) error {
+	if len(e.skips) == 0 {
+		return nil
+	}
+	slog.Info("Spot-checking skipped files",
+		slog.Int("count", len(e.skips)),
+		slog.Int("skipped", e.skipsSeen),
+	)
+
+	indexes := make([]int, len(e.skips))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	outcomes := make([]int, len(e.skips))
+	fresh := make([]dropbox.FileInfo, len(e.skips))
+	errs := make([]error, len(e.skips))
+
+	// Hashing may take fewer workers than downloads, see --hash-workers
+	executor := e.transfers
+	if limit := e.config.HashWorkerLimit(); limit > 0 && limit < executor.Concurrency() {
+		executor = executor.Lane(limit)
+	}
+
+	name := func(i int) string { return e.skips[i].file.Path }
+	runErr := transfer.Run(ctx, executor, indexes, name, func(ctx context.Context, i int) error {
+		s := e.skips[i]
+		info, err := e.clientFor(s.file).GetMetadata(ctx, s.file.Path)
+		switch {
+		case dropbox.IsNotFound(err):
+			outcomes[i] = spotChanged
+			return nil
+		case err != nil:
+			errs[i] = err
+			return err
+		}
+		fresh[i] = *info
+		outcomes[i] = e.recheckSkip(s, *info)
+		return nil
+	})
+	if ctx.Err() != nil {
+		return runErr
+	}
+
+	var wrong []int
+	for i, err := range errs {
+		switch {
+		case err != nil:
+			slog.Warn("Failed to spot-check skipped file",
+				slog.String("path", e.skips[i].file.Path),
+				slog.String("error", err.Error()),
+			)
+			continue
+		case outcomes[i] == spotWrong:
+			wrong = append(wrong, i)
+		}
+		stats.SpotChecked++
+	}
+	stats.SpotCheckMismatches = len(wrong)
+
+	if len(wrong) == 0 {
+		slog.Info("Spot check found no wrongly skipped files", slog.Int("checked", stats.SpotChecked))
+		return nil
+	}
+
+	offsets := make([]time.Duration, 0, len(wrong))
+	for _, i := range wrong {
+		s := e.skips[i]
+		modTime := time.Time{}
+		if stat, err := e.fsys().Stat(s.localPath); err == nil {
+			modTime = e.localModTime(s.localPath, stat)
+			offsets = append(offsets, modTime.Sub(fresh[i].ModTime))
+		}
+		slog.Error("Skipped file differs from Dropbox",
+			slog.String("path", s.file.Path),
+			slog.String("local_path", s.localPath),
+			slog.Time("local_modified", modTime),
+			slog.Time("dropbox_modified", fresh[i].ModTime),
+		)
+		if e.config.DryRun {
+			continue
+		}
+		if err := e.fsys().Remove(s.localPath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove wrongly skipped file",
+				slog.String("path", s.localPath),
+				slog.String("error", err.Error()),
+			)
+		}
+		e.forgetFile(s.localPath)
+	}
+
+	// Copies that all look newer than Dropbox point at a clock running ahead
+	if offset, ahead := clockSkew(offsets); ahead {
+		slog.Warn("Local copies look newer than Dropbox although they differ; check the clock of this computer and of the backup disk",
+			slog.Duration("median_offset", offset),
+		)
+	}
+	return fmt.Errorf("%d of %d spot-checked files were skipped although they differ from Dropbox", len(wrong), stats.SpotChecked)
+}
+
+// recheckSkip decides again whether to skip a file with its fresh metadata
+// and hashes the local copy to judge the decision
+func (e *Engine) recheckSkip(s skip, fresh dropbox.FileInfo) int {
+	if fresh.Rev != s.file.Rev || fresh.ContentHash != s.file.ContentHash {
+		return spotChanged
+	}
+	if !e.shouldSkipFile(s.localPath, fresh) {
+		return spotChanged
+	}
+	if !e.sameContentHash(s.localPath, fresh) {
+		return spotWrong
+	}
+	return spotOK
+}
+
+// clockSkew returns the median of the offsets between local and Dropbox
+// modification times, and whether every local time is ahead
+func clockSkew(offsets []time.Duration) (median time.Duration, ahead bool) {
+	if len(offsets) == 0 {
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2], sorted[0] > 0
+}
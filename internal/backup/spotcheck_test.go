@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func contentHash(t *testing.T, content string) string {
+	t.Helper()
+	hash, err := dropbox.ContentHash(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestRememberSkip(t *testing.T) {
+	e := &Engine{
+		config: &config.Config{SpotCheck: 3},
+		rng:    rand.New(rand.NewPCG(1, 2)),
+	}
+	for i := 0; i < 100; i++ {
+		e.rememberSkip(fmt.Sprintf("/backup/%d.txt", i), dropbox.FileInfo{Path: fmt.Sprintf("/%d.txt", i), ContentHash: "hash"})
+	}
+	e.rememberSkip("/backup/doc.docx", dropbox.FileInfo{Path: "/doc.paper", ExportAs: "docx"})
+
+	if len(e.skips) != 3 {
+		t.Errorf("kept %d skipped files, want 3", len(e.skips))
+	}
+	if e.skipsSeen != 100 {
+		t.Errorf("saw %d skipped files, want 100 without the exported one", e.skipsSeen)
+	}
+}
+
+func TestRecheckSkip(t *testing.T) {
+	modTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		local   string
+		fresh   dropbox.FileInfo
+		skipped dropbox.FileInfo
+		want    int
+	}{
+		{
+			name:    "matching copy",
+			local:   "same",
+			skipped: dropbox.FileInfo{Path: "/a.txt", Rev: "r1", Size: 4, ModTime: modTime, ContentHash: "same"},
+			fresh:   dropbox.FileInfo{Path: "/a.txt", Rev: "r1", Size: 4, ModTime: modTime, ContentHash: "same"},
+			want:    spotOK,
+		},
+		{
+			name:    "same size and time but other content",
+			local:   "diff",
+			skipped: dropbox.FileInfo{Path: "/a.txt", Rev: "r1", Size: 4, ModTime: modTime, ContentHash: "same"},
+			fresh:   dropbox.FileInfo{Path: "/a.txt", Rev: "r1", Size: 4, ModTime: modTime, ContentHash: "same"},
+			want:    spotWrong,
+		},
+		{
+			name:    "changed on Dropbox since listing",
+			local:   "same",
+			skipped: dropbox.FileInfo{Path: "/a.txt", Rev: "r1", Size: 4, ModTime: modTime, ContentHash: "same"},
+			fresh:   dropbox.FileInfo{Path: "/a.txt", Rev: "r2", Size: 5, ModTime: modTime.Add(time.Hour), ContentHash: "newer"},
+			want:    spotChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localPath := filepath.Join(t.TempDir(), "a.txt")
+			if err := os.WriteFile(localPath, []byte(tt.local), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(localPath, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			for _, info := range []*dropbox.FileInfo{&tt.skipped, &tt.fresh} {
+				if info.ContentHash == "same" {
+					info.ContentHash = contentHash(t, "same")
+				}
+			}
+
+			e := &Engine{config: &config.Config{}}
+			if got := e.recheckSkip(skip{localPath: localPath, file: tt.skipped}, tt.fresh); got != tt.want {
+				t.Errorf("recheckSkip() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	tests := []struct {
+		name      string
+		offsets   []time.Duration
+		wantAhead bool
+		want      time.Duration
+	}{
+		{name: "none", wantAhead: false},
+		{name: "all ahead", offsets: []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}, wantAhead: true, want: 2 * time.Hour},
+		{name: "mixed", offsets: []time.Duration{-time.Minute, time.Hour}, wantAhead: false, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ahead := clockSkew(tt.offsets)
+			if ahead != tt.wantAhead || got != tt.want {
+				t.Errorf("clockSkew() = %s, %v; want %s, %v", got, ahead, tt.want, tt.wantAhead)
+			}
+		})
+	}
+}
+
+func TestSpotCheck(t *testing.T) {
+	modTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	hash := contentHash(t, "good")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		path := "/bad.txt"
+		if strings.Contains(string(body), "/good.txt") {
+			path = "/good.txt"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{".tag":"file","name":"%s","path_lower":"%s","path_display":"%s","id":"id:1",`+
+			`"client_modified":"2025-03-01T12:00:00Z","server_modified":"2025-03-01T12:00:00Z","rev":"r1","size":4,"content_hash":"%s"}`,
+			path[1:], path, path, hash)
+	}))
+	defer srv.Close()
+
+	t.Cleanup(func() { dropbox.SetEndpoints(dropbox.Endpoints{}) })
+	if err := dropbox.SetEndpoints(dropbox.Endpoints{API: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := dropbox.New("id", "secret", "token", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backupDir := t.TempDir()
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Engine{
+		config:        &config.Config{BackupDir: backupDir, SpotCheck: 10},
+		dropboxClient: client,
+		transfers:     transfer.New(transfer.Options{Concurrency: 2}),
+		manifest:      m,
+	}
+	for name, content := range map[string]string{"good.txt": "good", "bad.txt": "worn"} {
+		localPath := filepath.Join(backupDir, name)
+		if err := os.WriteFile(localPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(localPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		e.rememberSkip(localPath, dropbox.FileInfo{Path: "/" + name, Rev: "r1", Size: 4, ModTime: modTime, ContentHash: hash})
+	}
+
+	stats := &Stats{}
+	err = e.spotCheck(context.Background(), stats)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 spot-checked files") {
+		t.Errorf("spotCheck() error = %v, want 1 of 2 wrongly skipped", err)
+	}
+	if stats.SpotChecked != 2 || stats.SpotCheckMismatches != 1 {
+		t.Errorf("checked %d, %d wrong; want 2, 1", stats.SpotChecked, stats.SpotCheckMismatches)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "bad.txt")); !os.IsNotExist(err) {
+		t.Errorf("wrongly skipped copy kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "good.txt")); err != nil {
+		t.Errorf("matching copy removed: %v", err)
+	}
+}
@@ -0,0 +1,423 @@
+package backup
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"create-dropbox-backup-folder/internal/budget"
+	"create-dropbox-backup-folder/internal/metrics"
+	"create-dropbox-backup-folder/internal/output"
+)
+
+// Phases of a backup run, in the order they run
+const (
+	PhaseList            = "list"
+	PhaseDownload        = "download"
+	PhaseVerify          = "verify"
+	PhaseSpotCheck       = "spot_check"
+	PhaseDelete          = "delete"
+	PhaseTier            = "tier"
+	PhaseAccountMetadata = "account_metadata"
+)
+
+// maxFileErrors bounds the failed files listed in Stats.Errors; Failures
+// still counts all of them. It bounds Stats.Retried likewise.
+const maxFileErrors = 100
+
+// Stats is the result of a backup run as returned by Engine.Run. It is
+// also written to the metrics file and published to the state backend.
+//
+// TotalFiles counts the files listed on Dropbox, which are either excluded
+// by the include and exclude patterns or matched. Every matched file is
+// downloaded, skipped, unsupported or failed, unless the run stopped early.
+type Stats struct {
+	// RunID identifies the run in the run history, reports and alerts
+	RunID string `json:"run_id"`
+
+	TotalFiles      int    `json:"total_files"`
+	ExcludedFiles   int    `json:"excluded_files"`
+	ExcludedBytes   uint64 `json:"excluded_bytes"`
+	MatchedFiles    int    `json:"matched_files"`
+	TotalFolders    int    `json:"total_folders"`
+	DownloadedFiles int    `json:"downloaded_files"`
+	SkippedFiles    int    `json:"skipped_files"`
+	DeletedFiles    int    `json:"deleted_files"`
+	DeletedBytes    uint64 `json:"deleted_bytes"`
+	FailedFiles     int    `json:"failed_files"`
+	TooLargeFiles   int    `json:"too_large_files"`
+	TooLargeBytes   uint64 `json:"too_large_bytes"`
+
+	// FreedBytes is the part of DeletedBytes whose disk space was freed:
+	// content still hard-linked elsewhere, e.g. from snapshots, isn't
+	FreedBytes uint64 `json:"freed_bytes"`
+
+	// UnsupportedFiles counts the files Dropbox can't serve, e.g. some cloud
+	// docs or restricted content, and Unsupported lists them
+	UnsupportedFiles int                `json:"unsupported_files"`
+	Unsupported      []UnsupportedEntry `json:"unsupported,omitempty"`
+
+	// TieredFiles counts the files moved to the cold directory (--cold-dir)
+	TieredFiles int    `json:"tiered_files,omitempty"`
+	TieredBytes uint64 `json:"tiered_bytes,omitempty"`
+
+	// ClockSkew is how far the local clock was ahead of Dropbox's, if beyond
+	// --max-clock-skew (negative if behind)
+	ClockSkew time.Duration `json:"clock_skew_ns,omitempty"`
+
+	// SpotChecked counts the skipped files checked against fresh metadata
+	// (--spot-check) and SpotCheckMismatches those that differ from Dropbox
+	SpotChecked         int `json:"spot_checked,omitempty"`
+	SpotCheckMismatches int `json:"spot_check_mismatches,omitempty"`
+
+	// APICalls counts the API calls of the run with --api-call-budget, and
+	// APIUsage holds the calls of the last 24 hours to carry over
+	APICalls int          `json:"api_calls,omitempty"`
+	APIUsage budget.Usage `json:"-"`
+
+	TotalBytes uint64    `json:"total_bytes"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+
+	// ExcludedBy counts the excluded files by the rule that excluded them:
+	// an exclude pattern, or filter.NotIncluded
+	ExcludedBy map[string]FilterHits `json:"excluded_by,omitempty"`
+
+	// Folders breaks the downloads down by top-level Dropbox folder, see
+	// FolderStats
+	Folders map[string]FolderStats `json:"folders,omitempty"`
+
+	// Phases are the timings of the phases that ran, in order
+	Phases []Phase `json:"phases,omitempty"`
+
+	// Failures counts failed files by class (see classifyFailure)
+	Failures map[string]int `json:"failures,omitempty"`
+
+	// Errors describes the first failed files (at most maxFileErrors)
+	Errors []FileError `json:"errors,omitempty"`
+
+	// RetriedFiles counts the files whose API calls were retried, Retries
+	// those retries, and Retried lists the first of the files (at most
+	// maxFileErrors)
+	RetriedFiles int           `json:"retried_files,omitempty"`
+	Retries      int           `json:"retries,omitempty"`
+	Retried      []FileRetries `json:"retried,omitempty"`
+
+	// Error is the error the run failed with; empty if it succeeded
+	Error string `json:"error,omitempty"`
+
+	// APILatency holds Dropbox API latency percentiles per operation type
+	APILatency map[string]metrics.Summary `json:"api_latency,omitempty"`
+}
+
+// FilterHits counts the files a filter rule excluded
+type FilterHits struct {
+	Files int    `json:"files"`
+	Bytes uint64 `json:"bytes"`
+}
+
+// exclude counts a file excluded by a filter rule
+func (s *Stats) exclude(rule string, size uint64) {
+	if s.ExcludedBy == nil {
+		s.ExcludedBy = make(map[string]FilterHits)
+	}
+	hits := s.ExcludedBy[rule]
+	hits.Files++
+	hits.Bytes += size
+	s.ExcludedBy[rule] = hits
+	s.ExcludedFiles++
+	s.ExcludedBytes += size
+}
+
+// FolderStats is what a top-level Dropbox folder cost a run. Duration adds
+// up the time spent on each of its downloaded and failed files, so with
+// concurrent transfers the folders add up to more than the run took.
+type FolderStats struct {
+	DownloadedFiles int           `json:"downloaded_files"`
+	DownloadedBytes uint64        `json:"downloaded_bytes"`
+	SkippedFiles    int           `json:"skipped_files"`
+	FailedFiles     int           `json:"failed_files"`
+	Duration        time.Duration `json:"duration_ns"`
+}
+
+// topLevelFolder returns the top-level folder of a Dropbox path; "/" for
+// files in the root
+func topLevelFolder(path string) string {
+	rest := strings.TrimPrefix(path, "/")
+	folder, _, found := strings.Cut(rest, "/")
+	if !found || folder == "" {
+		return "/"
+	}
+	return "/" + folder
+}
+
+// countFolder adds a result to the stats of its top-level folder
+func (s *Stats) countFolder(result Result) {
+	if s.Folders == nil {
+		s.Folders = make(map[string]FolderStats)
+	}
+	folder := topLevelFolder(result.Path)
+	fs := s.Folders[folder]
+	switch result.Action {
+	case ActionDownloaded:
+		fs.DownloadedFiles++
+		fs.DownloadedBytes += result.Bytes
+		fs.Duration += result.Duration
+	case ActionSkipped:
+		fs.SkippedFiles++
+	case ActionFailed:
+		if result.unverified {
+			fs.DownloadedFiles--
+		}
+		fs.FailedFiles++
+		fs.Duration += result.Duration
+	}
+	s.Folders[folder] = fs
+}
+
+// Phase is the timing of one phase of a run
+type Phase struct {
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// Duration returns how long the phase took
+func (p Phase) Duration() time.Duration {
+	return p.EndTime.Sub(p.StartTime)
+}
+
+// FileError describes why a file failed
+type FileError struct {
+	Path  string `json:"path"`
+	Class string `json:"class"`
+	Error string `json:"error"`
+}
+
+// FileRetries tells how often the API calls for a file were retried
+type FileRetries struct {
+	Path    string `json:"path"`
+	Retries int    `json:"retries"`
+}
+
+// Duration returns how long the run took
+func (s *Stats) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// minThroughputBytes is how much a run must download before its throughput
+// says something about the next run
+const minThroughputBytes = 1 << 20
+
+// Throughput returns the download throughput of the run in bytes per second,
+// and false if it downloaded too little to tell
+func (s *Stats) Throughput() (float64, bool) {
+	if s.TotalBytes < minThroughputBytes {
+		return 0, false
+	}
+	for _, phase := range s.Phases {
+		if phase.Name == PhaseDownload && phase.Duration() > 0 {
+			return float64(s.TotalBytes) / phase.Duration().Seconds(), true
+		}
+	}
+	return 0, false
+}
+
+// startPhase records the start of a phase at the time now returns; calling
+// the returned function records its end
+func (s *Stats) startPhase(name string, now func() time.Time) func() {
+	s.Phases = append(s.Phases, Phase{Name: name, StartTime: now()})
+	i := len(s.Phases) - 1
+	return func() {
+		s.Phases[i].EndTime = now()
+	}
+}
+
+// SummaryLine returns a single-line, stable-format summary of the run suitable
+// for log scrapers, e.g. "RESULT ok files=12 downloaded=2 skipped=10 deleted=0
+// bytes=5678 errors=0 duration=3s excluded=0 run=20240101T000000Z-0a1b2c".
+// Keys are never reordered or
+// removed; new keys are appended.
+func (s *Stats) SummaryLine(runErr error) string {
+	status := "ok"
+	errorCount := s.FailedFiles
+	if runErr != nil {
+		status = "failed"
+		if errorCount == 0 {
+			errorCount = 1
+		}
+	}
+
+	duration := s.Duration()
+	if duration < 0 {
+		duration = 0
+	}
+
+	return fmt.Sprintf("RESULT %s files=%d downloaded=%d skipped=%d deleted=%d bytes=%d errors=%d duration=%ds excluded=%d run=%s",
+		status,
+		s.TotalFiles,
+		s.DownloadedFiles,
+		s.SkippedFiles,
+		s.DeletedFiles,
+		s.TotalBytes,
+		errorCount,
+		int64(duration.Round(time.Second)/time.Second),
+		s.ExcludedFiles,
+		s.RunID,
+	)
+}
+
+// NewRunID returns an ID for a run starting at start: the UTC start time,
+// which sorts the IDs, and a random suffix telling apart runs of several
+// profiles or hosts starting at the same second
+func NewRunID(start time.Time) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return start.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// Report returns the result of the run for the output formatters: the file
+// count (--count), size (--size), filter hit (--explain-filters), unsupported
+// file (--list-unsupported) and per-folder (--folder-stats) summaries for
+// people, and the stats themselves as data
+func (s *Stats) Report(count, size, explainFilters, listUnsupported, folders bool) output.Report {
+	report := output.Report{Data: s}
+
+	if count {
+		section := output.Section{
+			Title: "📊 File Count Summary:",
+			Fields: []output.Field{
+				output.F("Total files processed", output.Count(s.TotalFiles)),
+				output.F("Files excluded", fmt.Sprintf("%s (%s)", output.Count(s.ExcludedFiles), formatBytes(s.ExcludedBytes))),
+				output.F("Files matched", output.Count(s.MatchedFiles)),
+				output.F("Total folders processed", output.Count(s.TotalFolders)),
+				output.F("Total items", output.Count(s.TotalFiles+s.TotalFolders)),
+				output.F("Files downloaded", output.Count(s.DownloadedFiles)),
+				output.F("Files skipped", output.Count(s.SkippedFiles)),
+			},
+		}
+		if s.SpotChecked > 0 {
+			section.Fields = append(section.Fields, output.F("Skipped files spot-checked", fmt.Sprintf("%s, %s wrongly skipped", output.Count(s.SpotChecked), output.Count(s.SpotCheckMismatches))))
+		}
+		if s.TooLargeFiles > 0 {
+			section.Fields = append(section.Fields, output.F("Files too large for the backup disk", fmt.Sprintf("%s (%s), skipped", output.Count(s.TooLargeFiles), formatBytes(s.TooLargeBytes))))
+		}
+		if s.UnsupportedFiles > 0 {
+			section.Fields = append(section.Fields, output.F("Files Dropbox can't serve", fmt.Sprintf("%s (%s)", output.Count(s.UnsupportedFiles), s.UnsupportedSummary())))
+		}
+		if s.FailedFiles > 0 {
+			section.Fields = append(section.Fields, output.F("Files failed", fmt.Sprintf("%s (%s)", output.Count(s.FailedFiles), s.FailureSummary())))
+		}
+		if s.DeletedFiles > 0 {
+			deleted := fmt.Sprintf("%s (%s)", output.Count(s.DeletedFiles), formatBytes(s.DeletedBytes))
+			if s.FreedBytes != s.DeletedBytes {
+				deleted += fmt.Sprintf(", %s freed; the rest is still linked from snapshots", formatBytes(s.FreedBytes))
+			}
+			section.Fields = append(section.Fields, output.F("Files deleted", deleted))
+		}
+		if s.TieredFiles > 0 {
+			section.Fields = append(section.Fields, output.F("Files moved to cold directory", fmt.Sprintf("%s (%s)", output.Count(s.TieredFiles), formatBytes(s.TieredBytes))))
+		}
+		if s.RetriedFiles > 0 {
+			section.Fields = append(section.Fields, output.F("Files retried", fmt.Sprintf("%s (%s retries)", output.Count(s.RetriedFiles), output.Count(s.Retries))))
+		}
+		if s.APICalls > 0 {
+			section.Fields = append(section.Fields, output.F("API calls", output.Count(s.APICalls)))
+		}
+		report.Sections = append(report.Sections, section)
+	}
+
+	if size {
+		section := output.Section{
+			Title:  "💾 Size Summary:",
+			Fields: []output.Field{output.F("Total bytes processed", formatBytes(s.TotalBytes))},
+		}
+		if duration := s.Duration(); duration > 0 {
+			bytesPerSecond := float64(s.TotalBytes) / duration.Seconds()
+			section.Fields = append(section.Fields, output.F("Average transfer rate", formatBytes(uint64(bytesPerSecond))+"/s"))
+		}
+		report.Sections = append(report.Sections, section)
+	}
+
+	if explainFilters {
+		report.Sections = append(report.Sections, s.filterSection())
+	}
+
+	if listUnsupported {
+		report.Sections = append(report.Sections, s.unsupportedSection())
+	}
+
+	if folders {
+		report.Sections = append(report.Sections, s.folderSection())
+	}
+
+	return report
+}
+
+// folderSection lists the downloads of each top-level folder, most bytes
+// first, so the folders that cost the most time and bandwidth stand out
+func (s *Stats) folderSection() output.Section {
+	folders := make([]string, 0, len(s.Folders))
+	for folder := range s.Folders {
+		folders = append(folders, folder)
+	}
+	slices.SortFunc(folders, func(a, b string) int {
+		fa, fb := s.Folders[a], s.Folders[b]
+		if fa.DownloadedBytes != fb.DownloadedBytes {
+			if fa.DownloadedBytes > fb.DownloadedBytes {
+				return -1
+			}
+			return 1
+		}
+		if fa.Duration != fb.Duration {
+			if fa.Duration > fb.Duration {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	section := output.Section{Title: "📁 Folders:"}
+	for _, folder := range folders {
+		fs := s.Folders[folder]
+		value := fmt.Sprintf("%s files (%s) in %s, %s skipped",
+			output.Count(fs.DownloadedFiles), formatBytes(fs.DownloadedBytes), fs.Duration.Round(time.Second), output.Count(fs.SkippedFiles))
+		if fs.FailedFiles > 0 {
+			value += fmt.Sprintf(", %s failed", output.Count(fs.FailedFiles))
+		}
+		section.Fields = append(section.Fields, output.F(folder, value))
+	}
+	if len(folders) == 0 {
+		section.Fields = append(section.Fields, output.F("Downloaded", "no files"))
+	}
+	return section
+}
+
+// filterSection lists the files and bytes each filter rule excluded, most
+// files first
+func (s *Stats) filterSection() output.Section {
+	rules := make([]string, 0, len(s.ExcludedBy))
+	for rule := range s.ExcludedBy {
+		rules = append(rules, rule)
+	}
+	slices.SortFunc(rules, func(a, b string) int {
+		if diff := s.ExcludedBy[b].Files - s.ExcludedBy[a].Files; diff != 0 {
+			return diff
+		}
+		return strings.Compare(a, b)
+	})
+
+	section := output.Section{Title: "🔍 Filter Hits:"}
+	for _, rule := range rules {
+		hits := s.ExcludedBy[rule]
+		section.Fields = append(section.Fields, output.F(rule, fmt.Sprintf("%s files (%s)", output.Count(hits.Files), formatBytes(hits.Bytes))))
+	}
+	if len(rules) == 0 {
+		section.Fields = append(section.Fields, output.F("Excluded", "no files"))
+	}
+	return section
+}
@@ -0,0 +1,297 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/output"
+)
+
+func TestNewRunID(t *testing.T) {
+	start := time.Date(2024, 1, 1, 2, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	id := NewRunID(start)
+	if !strings.HasPrefix(id, "20240101T013000Z-") || len(id) != len("20240101T013000Z-")+6 {
+		t.Errorf("NewRunID() = %q, want the UTC start time and a 6-digit suffix", id)
+	}
+	if other := NewRunID(start); other == id {
+		t.Errorf("NewRunID() returned %q twice", id)
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		stats  Stats
+		runErr error
+		want   string
+	}{
+		{
+			name: "successful run",
+			stats: Stats{
+				TotalFiles:      15,
+				ExcludedFiles:   3,
+				MatchedFiles:    12,
+				DownloadedFiles: 2,
+				SkippedFiles:    10,
+				TotalBytes:      5678,
+				RunID:           "20240101T000000Z-0a1b2c",
+				StartTime:       start,
+				EndTime:         start.Add(123 * time.Second),
+			},
+			want: "RESULT ok files=15 downloaded=2 skipped=10 deleted=0 bytes=5678 errors=0 duration=123s excluded=3 run=20240101T000000Z-0a1b2c",
+		},
+		{
+			name: "failed run with file errors",
+			stats: Stats{
+				TotalFiles:      5,
+				DownloadedFiles: 3,
+				FailedFiles:     2,
+				TotalBytes:      100,
+				StartTime:       start,
+				EndTime:         start.Add(1500 * time.Millisecond),
+			},
+			runErr: os.ErrPermission,
+			want:   "RESULT failed files=5 downloaded=3 skipped=0 deleted=0 bytes=100 errors=2 duration=2s excluded=0 run=",
+		},
+		{
+			name: "failed run without file errors",
+			stats: Stats{
+				StartTime: start,
+				EndTime:   start,
+			},
+			runErr: os.ErrNotExist,
+			want:   "RESULT failed files=0 downloaded=0 skipped=0 deleted=0 bytes=0 errors=1 duration=0s excluded=0 run=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.stats.SummaryLine(tt.runErr)
+			if got != tt.want {
+				t.Errorf("SummaryLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := &Stats{
+		TotalFiles:      100,
+		ExcludedFiles:   5,
+		MatchedFiles:    95,
+		TotalFolders:    20,
+		DownloadedFiles: 75,
+		SkippedFiles:    20,
+		DeletedFiles:    5,
+		TotalBytes:      2097152, // 2 MB
+		StartTime:       start,
+		EndTime:         start.Add(2 * time.Second),
+	}
+
+	tests := []struct {
+		name        string
+		count, size bool
+		want        []string
+		notWant     []string
+	}{
+		{name: "count and size", count: true, size: true, want: []string{"Total items: 120", "Files excluded: 5", "Files matched: 95", "Files deleted: 5", "Total bytes processed: 2.0 MB", "1.0 MB/s"}},
+		{name: "count only", count: true, want: []string{"Files downloaded: 75"}, notWant: []string{"Size Summary"}},
+		{name: "size only", size: true, want: []string{"Size Summary"}, notWant: []string{"File Count Summary"}},
+		{name: "neither"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := stats.Report(tt.count, tt.size, false, false, false)
+			if report.Data != stats {
+				t.Errorf("Report().Data = %v, want the stats", report.Data)
+			}
+
+			var buf bytes.Buffer
+			text, _ := output.New(output.Text, &buf)
+			if err := text.Report(report); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			if !tt.count && !tt.size && out != "" {
+				t.Errorf("text output = %q, want nothing", out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("text output = %q, missing %q", out, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out, notWant) {
+					t.Errorf("text output = %q, unexpected %q", out, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsPhasesAndJSON(t *testing.T) {
+	engine := &Engine{}
+	stats := &Stats{StartTime: time.Now()}
+
+	done := stats.startPhase(PhaseList, time.Now)
+	done()
+	done = stats.startPhase(PhaseDownload, time.Now)
+	engine.addFailure(stats, "/docs/a.txt", os.ErrPermission)
+	done()
+
+	if len(stats.Phases) != 2 || stats.Phases[0].Name != PhaseList || stats.Phases[1].Name != PhaseDownload {
+		t.Fatalf("Phases = %+v, want list and download", stats.Phases)
+	}
+	for _, phase := range stats.Phases {
+		if phase.EndTime.IsZero() || phase.Duration() < 0 {
+			t.Errorf("phase %s not finished: %+v", phase.Name, phase)
+		}
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"total_files", "failed_files", "phases", "failures", "errors"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("JSON result lacks %q: %s", key, data)
+		}
+	}
+	errs, _ := decoded["errors"].([]any)
+	if len(errs) != 1 {
+		t.Fatalf("errors = %v, want one", decoded["errors"])
+	}
+	if got := errs[0].(map[string]any)["path"]; got != "/docs/a.txt" {
+		t.Errorf("error path = %v, want /docs/a.txt", got)
+	}
+}
+
+func TestAddFailureBoundsErrors(t *testing.T) {
+	engine := &Engine{}
+	stats := &Stats{}
+	for range maxFileErrors + 10 {
+		engine.addFailure(stats, "/a", os.ErrPermission)
+	}
+	if stats.FailedFiles != maxFileErrors+10 {
+		t.Errorf("FailedFiles = %d, want %d", stats.FailedFiles, maxFileErrors+10)
+	}
+	if len(stats.Errors) != maxFileErrors {
+		t.Errorf("len(Errors) = %d, want %d", len(stats.Errors), maxFileErrors)
+	}
+}
+
+func TestFilterHits(t *testing.T) {
+	stats := &Stats{}
+	stats.exclude("*.tmp", 100)
+	stats.exclude("*.tmp", 50)
+	stats.exclude("cache/", 2048)
+
+	if stats.ExcludedFiles != 3 || stats.ExcludedBytes != 2198 {
+		t.Errorf("excluded %d files, %d bytes; want 3, 2198", stats.ExcludedFiles, stats.ExcludedBytes)
+	}
+	if got := stats.ExcludedBy["*.tmp"]; got != (FilterHits{Files: 2, Bytes: 150}) {
+		t.Errorf("ExcludedBy[*.tmp] = %+v", got)
+	}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(stats.Report(false, false, true, false, false)); err != nil {
+		t.Fatal(err)
+	}
+	want := "🔍 Filter Hits:\n   *.tmp: 2 files (150 B)\n   cache/: 1 files (2.0 KB)\n"
+	if buf.String() != want {
+		t.Errorf("filter hits = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestThroughput(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	download := []Phase{{Name: PhaseDownload, StartTime: start, EndTime: start.Add(4 * time.Second)}}
+
+	tests := []struct {
+		name   string
+		stats  Stats
+		want   float64
+		wantOK bool
+	}{
+		{name: "downloaded", stats: Stats{TotalBytes: 8 << 20, Phases: download}, want: 2 << 20, wantOK: true},
+		{name: "too little", stats: Stats{TotalBytes: 1024, Phases: download}},
+		{name: "no download phase", stats: Stats{TotalBytes: 8 << 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.stats.Throughput()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Throughput() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTopLevelFolder(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/Photos/2024/a.jpg", want: "/Photos"},
+		{path: "/Photos/a.jpg", want: "/Photos"},
+		{path: "/notes.txt", want: "/"},
+		{path: "", want: "/"},
+	}
+	for _, tt := range tests {
+		if got := topLevelFolder(tt.path); got != tt.want {
+			t.Errorf("topLevelFolder(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFolderStats(t *testing.T) {
+	stats := &Stats{}
+	stats.add(Result{Path: "/Photos/2024/a.jpg", Action: ActionDownloaded, Bytes: 3 << 20, Duration: 40 * time.Second})
+	stats.add(Result{Path: "/Photos/b.jpg", Action: ActionDownloaded, Bytes: 1 << 20, Duration: 20 * time.Second})
+	stats.add(Result{Path: "/Photos/c.jpg", Action: ActionSkipped})
+	stats.add(Result{Path: "/Docs/a.txt", Action: ActionDownloaded, Bytes: 1024, Duration: time.Second})
+	stats.add(Result{Path: "/Docs/b.txt", Action: ActionFailed, Duration: 5 * time.Second})
+	stats.add(Result{Path: "/notes.txt", Action: ActionSkipped})
+	stats.add(Result{Path: "/backup/Old/x.txt", Action: ActionDeleted})
+
+	want := map[string]FolderStats{
+		"/Photos": {DownloadedFiles: 2, DownloadedBytes: 4 << 20, SkippedFiles: 1, Duration: time.Minute},
+		"/Docs":   {DownloadedFiles: 1, DownloadedBytes: 1024, FailedFiles: 1, Duration: 6 * time.Second},
+		"/":       {SkippedFiles: 1},
+	}
+	if len(stats.Folders) != len(want) {
+		t.Errorf("Folders = %+v, want %d folders", stats.Folders, len(want))
+	}
+	for folder, w := range want {
+		if got := stats.Folders[folder]; got != w {
+			t.Errorf("Folders[%s] = %+v, want %+v", folder, got, w)
+		}
+	}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(stats.Report(false, false, false, false, true)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	photos := strings.Index(out, "/Photos: 2 files (4.0 MB) in 1m0s, 1 skipped")
+	docs := strings.Index(out, "/Docs: 1 files (1.0 KB) in 6s, 0 skipped, 1 failed")
+	if photos < 0 || docs < 0 || docs < photos {
+		t.Errorf("text output = %q, want /Photos before /Docs", out)
+	}
+}
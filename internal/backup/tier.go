@@ -0,0 +1,201 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// hotPath returns where a manifest entry is kept in the backup directory
+func (e *Engine) hotPath(key string, entry manifest.Entry) string {
+	return filepath.Join(e.config.BackupDir, filepath.FromSlash(manifest.LocalPath(key, entry)))
+}
+
+// coldCopy reports whether a file is kept up to date in the cold directory,
+// so it isn't downloaded to the backup directory again. A cold copy of an
+// older revision is removed, as the new one is downloaded.
+func (e *Engine) coldCopy(file dropbox.FileInfo) bool {
+	if e.manifest == nil {
+		return false
+	}
+	key := manifest.Key(file.Namespace, file.Path)
+	entry, ok := e.manifest.Get(key)
+	if !ok || !entry.Cold {
+		return false
+	}
+	coldPath := e.manifest.ColdPath(key, entry)
+	if entry.Rev == file.Rev {
+		if info, err := e.fsys().Stat(coldPath); err == nil && info.Size() == int64(entry.Size) && e.intact(coldPath, file) {
+			return true
+		}
+	}
+	if e.config.DryRun {
+		return false
+	}
+	if err := e.fsys().Remove(coldPath); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove outdated cold copy",
+			slog.String("path", coldPath),
+			slog.String("error", err.Error()),
+		)
+	}
+	return false
+}
+
+// tierFiles moves the files not modified for --cold-after-days from the
+// backup directory to the cold directory and marks them cold in the
+// manifest. Files are moved in path order so runs are reproducible.
+func (e *Engine) tierFiles(ctx context.Context, stats *Stats) error {
+	cutoff := e.now().AddDate(0, 0, -e.config.ColdAfterDays)
+	entries := e.manifest.Entries()
+	keys := make([]string, 0, len(entries))
+	for key, entry := range entries {
+		if !entry.Cold && !entry.ModTime.IsZero() && entry.ModTime.Before(cutoff) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if !e.config.DryRun {
+		e.manifest.SetColdDir(e.config.ColdDir)
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entry := entries[key]
+		hotPath := e.hotPath(key, entry)
+		coldPath := e.manifest.ColdPath(key, entry)
+		if e.config.DryRun {
+			coldPath = filepath.Join(e.config.ColdDir, filepath.FromSlash(manifest.LocalPath(key, entry)))
+			e.messages().Message("[dry-run] move %s to %s", hotPath, coldPath)
+			continue
+		}
+		if _, err := e.fsys().Stat(hotPath); os.IsNotExist(err) {
+			continue // Downloaded again next run
+		}
+
+		if err := e.moveFile(hotPath, coldPath, entry); err != nil {
+			return err
+		}
+		e.forgetSidecar(hotPath)
+		entry.Cold = true
+		e.manifest.Set(key, entry)
+		stats.TieredFiles++
+		stats.TieredBytes += entry.Size
+		slog.DebugContext(ctx, "Moved file to cold directory", slog.String("path", hotPath))
+	}
+	if stats.TieredFiles > 0 {
+		slog.Info("Moved unchanged files to cold directory",
+			slog.Int("files", stats.TieredFiles),
+			slog.String("size", formatBytes(stats.TieredBytes)),
+		)
+	}
+	return nil
+}
+
+// moveFile moves a file to the cold directory. The cold directory is usually
+// on another file system, where the file is copied through a part file and
+// then removed.
+func (e *Engine) moveFile(hotPath, coldPath string, entry manifest.Entry) error {
+	if err := e.fsys().MkdirAll(filepath.Dir(coldPath), 0755); err != nil {
+		return fmt.Errorf("failed to create cold directory: %w", err)
+	}
+	if err := e.fsys().Rename(hotPath, coldPath); err == nil {
+		return nil
+	}
+
+	src, err := e.fsys().Open(hotPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s for moving: %w", hotPath, err)
+	}
+	defer src.Close()
+	partPath := transfer.PartPath(coldPath, "")
+	dst, err := e.fsys().OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to create cold file: %w", err)
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = e.fsys().Rename(partPath, coldPath)
+	}
+	if err != nil {
+		e.fsys().Remove(partPath)
+		return fmt.Errorf("failed to move %s to cold directory: %w", hotPath, err)
+	}
+	if !entry.ModTime.IsZero() {
+		if err := setFileTimes(coldPath, entry.ModTime); err != nil {
+			slog.Warn("Failed to set file modification time",
+				slog.String("path", coldPath),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+	if err := e.fsys().Remove(hotPath); err != nil {
+		return fmt.Errorf("failed to remove %s after moving it to cold directory: %w", hotPath, err)
+	}
+	return nil
+}
+
+// deleteColdFiles removes the cold copies of files deleted from Dropbox.
+// dropboxFiles holds the local paths of the files still on Dropbox, and only
+// files under roots are considered, as for the backup directory.
+func (e *Engine) deleteColdFiles(ctx context.Context, dropboxFiles map[string]bool, roots []string, stats *Stats) error {
+	if e.manifest == nil {
+		return nil
+	}
+	entries := e.manifest.Entries()
+	keys := make([]string, 0, len(entries))
+	for key, entry := range entries {
+		if entry.Cold {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := entries[key]
+		hotPath := e.hotPath(key, entry)
+		if dropboxFiles[hotPath] || !withinAny(roots, hotPath) {
+			continue
+		}
+		coldPath := e.manifest.ColdPath(key, entry)
+		if e.config.DryRun {
+			e.messages().Message("[dry-run] delete %s", coldPath)
+			e.record(stats, Result{Path: coldPath, Action: ActionDeleted, Bytes: entry.Size, DryRun: true})
+			continue
+		}
+
+		slog.InfoContext(ctx, "Deleting orphaned cold file", slog.String("path", coldPath))
+		if err := e.fsys().Remove(coldPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete file %s: %w", coldPath, err)
+		}
+		e.manifest.Delete(key)
+		e.rememberChange(hotPath, true)
+		e.record(stats, Result{Path: coldPath, Action: ActionDeleted, Bytes: entry.Size})
+	}
+	return nil
+}
+
+// withinAny reports whether path is inside one of roots
+func withinAny(roots []string, path string) bool {
+	for _, root := range roots {
+		if checkWithin(root, path) == nil {
+			return true
+		}
+	}
+	return false
+}
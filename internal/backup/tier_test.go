@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func tierEngine(t *testing.T) *Engine {
+	t.Helper()
+	backupDir := t.TempDir()
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Engine{
+		config:    &config.Config{BackupDir: backupDir, ColdDir: t.TempDir(), ColdAfterDays: 365, Delete: true},
+		transfers: transfer.New(transfer.Options{Concurrency: 1}),
+		manifest:  m,
+		clock:     func() time.Time { return now },
+	}
+}
+
+// writeBackupFile writes a backed-up file and records it in the manifest
+func writeBackupFile(t *testing.T, e *Engine, remotePath, content string, modTime time.Time) {
+	t.Helper()
+	localPath := filepath.Join(e.config.BackupDir, filepath.FromSlash(remotePath))
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e.manifest.Set(remotePath, manifest.Entry{Rev: "rev1", Size: uint64(len(content)), ModTime: modTime})
+}
+
+func TestTierFiles(t *testing.T) {
+	e := tierEngine(t)
+	writeBackupFile(t, e, "/docs/old.txt", "old", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	writeBackupFile(t, e, "/docs/new.txt", "new", time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC))
+
+	stats := &Stats{}
+	if err := e.tierFiles(context.Background(), stats); err != nil {
+		t.Fatalf("tierFiles() error = %v", err)
+	}
+
+	if stats.TieredFiles != 1 || stats.TieredBytes != 3 {
+		t.Errorf("tiered %d files (%d bytes), want 1 (3 bytes)", stats.TieredFiles, stats.TieredBytes)
+	}
+	if _, err := os.Stat(filepath.Join(e.config.BackupDir, "docs", "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("old file still in backup directory: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(e.config.ColdDir, "docs", "old.txt"))
+	if err != nil || string(data) != "old" {
+		t.Errorf("cold copy = %q, %v; want old", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(e.config.BackupDir, "docs", "new.txt")); err != nil {
+		t.Errorf("new file moved: %v", err)
+	}
+
+	if entry, _ := e.manifest.Get("/docs/old.txt"); !entry.Cold {
+		t.Error("old file not marked cold")
+	}
+	if entry, _ := e.manifest.Get("/docs/new.txt"); entry.Cold {
+		t.Error("new file marked cold")
+	}
+	if got := e.manifest.ColdDir(); got != e.config.ColdDir {
+		t.Errorf("manifest cold dir = %q, want %q", got, e.config.ColdDir)
+	}
+}
+
+func TestColdCopy(t *testing.T) {
+	tests := []struct {
+		name     string
+		rev      string
+		wantSkip bool
+		wantKept bool
+	}{
+		{name: "same revision", rev: "rev1", wantSkip: true, wantKept: true},
+		{name: "new revision", rev: "rev2", wantSkip: false, wantKept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tierEngine(t)
+			writeBackupFile(t, e, "/old.txt", "old", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+			if err := e.tierFiles(context.Background(), &Stats{}); err != nil {
+				t.Fatal(err)
+			}
+
+			file := dropbox.FileInfo{Path: "/old.txt", Rev: tt.rev, Size: 3}
+			if got := e.coldCopy(file); got != tt.wantSkip {
+				t.Errorf("coldCopy() = %v, want %v", got, tt.wantSkip)
+			}
+			_, err := os.Stat(filepath.Join(e.config.ColdDir, "old.txt"))
+			if kept := err == nil; kept != tt.wantKept {
+				t.Errorf("cold copy kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestDeleteColdFiles(t *testing.T) {
+	e := tierEngine(t)
+	writeBackupFile(t, e, "/gone.txt", "gone", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	writeBackupFile(t, e, "/kept.txt", "kept", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err := e.tierFiles(context.Background(), &Stats{}); err != nil {
+		t.Fatal(err)
+	}
+
+	onDropbox := map[string]bool{filepath.Join(e.config.BackupDir, "kept.txt"): true}
+	stats := &Stats{}
+	if err := e.deleteColdFiles(context.Background(), onDropbox, e.deleteRoots(), stats); err != nil {
+		t.Fatalf("deleteColdFiles() error = %v", err)
+	}
+
+	if stats.DeletedFiles != 1 {
+		t.Errorf("deleted %d files, want 1", stats.DeletedFiles)
+	}
+	if _, err := os.Stat(filepath.Join(e.config.ColdDir, "gone.txt")); !os.IsNotExist(err) {
+		t.Errorf("deleted file still in cold directory: %v", err)
+	}
+	if _, ok := e.manifest.Get("/gone.txt"); ok {
+		t.Error("deleted file still in manifest")
+	}
+	if _, err := os.Stat(filepath.Join(e.config.ColdDir, "kept.txt")); err != nil {
+		t.Errorf("file still on Dropbox deleted from cold directory: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"log/slog"
+
+	"create-dropbox-backup-folder/internal/output"
+)
+
+// UnsupportedEntry is a file Dropbox can't serve, with the reason it gave
+// (see dropbox.Unsupported)
+type UnsupportedEntry struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// unsupported turns a result into that of a file Dropbox can't serve. It
+// doesn't count as a failure, as retrying wouldn't help.
+func (r Result) unsupported(reason string, err error) Result {
+	slog.Info("Skipping file Dropbox can't serve",
+		slog.String("path", r.Path),
+		slog.String("reason", reason),
+	)
+	r.Action = ActionUnsupported
+	r.Reason = reason
+	r.Error = err.Error()
+	return r
+}
+
+// UnsupportedSummary describes the unsupported files by reason, most
+// frequent first, e.g. "unsupported_file 3, restricted_content 1"; empty if
+// there are none
+func (s *Stats) UnsupportedSummary() string {
+	reasons := make(map[string]int)
+	for _, entry := range s.Unsupported {
+		reasons[entry.Reason]++
+	}
+	return countSummary(reasons)
+}
+
+// unsupportedSection lists the files Dropbox can't serve
+func (s *Stats) unsupportedSection() output.Section {
+	section := output.Section{Title: "🚫 Unsupported Files:"}
+	for _, entry := range s.Unsupported {
+		section.Fields = append(section.Fields, output.F(entry.Path, entry.Reason))
+	}
+	if len(s.Unsupported) == 0 {
+		section.Fields = append(section.Fields, output.F("Unsupported", "no files"))
+	}
+	return section
+}
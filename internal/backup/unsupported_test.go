@@ -0,0 +1,56 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/localfs/localfstest"
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/transfer"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+func TestDownloadFilesCountsUnsupported(t *testing.T) {
+	restricted := files.DownloadAPIError{EndpointError: &files.DownloadError{Path: &files.LookupError{}}}
+	restricted.EndpointError.Tag = files.DownloadErrorPath
+	restricted.EndpointError.Path.Tag = files.LookupErrorRestrictedContent
+
+	// The simulated file system returns the Dropbox error, as the client
+	// can't be replaced
+	fsys := localfstest.New()
+	fsys.Fail(localfstest.OpMkdir, "", restricted)
+	engine := &Engine{
+		config:    &config.Config{BackupDir: t.TempDir()},
+		transfers: transfer.New(transfer.Options{Concurrency: 1}),
+		fs:        fsys,
+	}
+	downloads := []dropbox.FileInfo{{Path: "/movies/film.mp4", Name: "film.mp4", Size: 100}}
+
+	stats := &Stats{}
+	if err := engine.downloadFiles(context.Background(), downloads, stats); err != nil {
+		t.Fatalf("downloadFiles() error = %v, want unsupported files not to fail the run", err)
+	}
+	if stats.FailedFiles != 0 || stats.UnsupportedFiles != 1 {
+		t.Fatalf("failed = %d, unsupported = %d; want 0, 1", stats.FailedFiles, stats.UnsupportedFiles)
+	}
+	want := UnsupportedEntry{Path: "/movies/film.mp4", Reason: dropbox.UnsupportedRestricted}
+	if len(stats.Unsupported) != 1 || stats.Unsupported[0] != want {
+		t.Errorf("Unsupported = %+v, want %+v", stats.Unsupported, want)
+	}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(stats.Report(true, false, false, true, false)); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Files Dropbox can't serve: 1 (restricted_content 1)", "/movies/film.mp4: restricted_content"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("report = %q, missing %q", buf.String(), line)
+		}
+	}
+}
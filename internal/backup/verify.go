@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/localfs"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// download is a file written by this run, kept for --verify-after
+type download struct {
+	localPath string
+	file      dropbox.FileInfo
+}
+
+// rememberDownload records a finished download for verification. Exported
+// files have no content hash to compare against and are left out.
+func (e *Engine) rememberDownload(localPath string, file dropbox.FileInfo) {
+	if !e.config.VerifyAfter || file.ExportAs != "" || file.ContentHash == "" {
+		return
+	}
+	e.downloadsMu.Lock()
+	defer e.downloadsMu.Unlock()
+	e.downloads = append(e.downloads, download{localPath: localPath, file: file})
+}
+
+// verifyDownloads re-reads the files downloaded in this run from disk and
+// compares them with their Dropbox content hash. A copy that doesn't match
+// is removed and forgotten so the next run downloads it again.
+func (e *Engine) verifyDownloads(ctx context.Context, stats *Stats) error {
+	if len(e.downloads) == 0 {
+		return nil
+	}
+	slog.Info("Verifying downloaded files", slog.Int("count", len(e.downloads)))
+
+	indexes := make([]int, len(e.downloads))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	results := make([]error, len(e.downloads))
+
+	// Hashing may take fewer workers than downloads, see --hash-workers
+	executor := e.transfers
+	if limit := e.config.HashWorkerLimit(); limit > 0 && limit < executor.Concurrency() {
+		executor = executor.Lane(limit)
+	}
+
+	name := func(i int) string { return e.downloads[i].file.Path }
+	runErr := transfer.Run(ctx, executor, indexes, name, func(ctx context.Context, i int) error {
+		results[i] = verifyDownload(e.fsys(), e.downloads[i])
+		return results[i]
+	})
+	if ctx.Err() != nil {
+		return runErr
+	}
+
+	failed := 0
+	for i, err := range results {
+		if err == nil {
+			continue
+		}
+		failed++
+		d := e.downloads[i]
+		slog.Error("Downloaded file failed verification",
+			slog.String("path", d.file.Path),
+			slog.String("local_path", d.localPath),
+			slog.String("error", err.Error()),
+		)
+		if removeErr := e.fsys().Remove(d.localPath); removeErr != nil && !os.IsNotExist(removeErr) {
+			slog.Warn("Failed to remove unverified file",
+				slog.String("path", d.localPath),
+				slog.String("error", removeErr.Error()),
+			)
+		}
+		e.forgetFile(d.localPath)
+		e.record(stats, Result{Path: d.file.Path, unverified: true}.failed(err))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloaded files failed verification", failed, len(e.downloads))
+	}
+	slog.Info("Verified downloaded files", slog.Int("count", len(e.downloads)))
+	return nil
+}
+
+// verifyDownload flushes a downloaded file to disk, reads it back and
+// compares its content hash with the one reported by Dropbox
+func verifyDownload(fsys localfs.FS, d download) error {
+	f, err := fsys.Open(d.localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Only files of the real file system have a page cache to drop
+	if osFile, ok := f.(*os.File); ok {
+		if err := dropCache(osFile); err != nil {
+			slog.Debug("Failed to flush file before verification",
+				slog.String("path", d.localPath),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	hash, err := dropbox.ContentHash(f)
+	if err != nil {
+		return fmt.Errorf("failed to read back: %w", err)
+	}
+	if hash != d.file.ContentHash {
+		return fmt.Errorf("%w: local %s, Dropbox %s", errChecksumMismatch, hash, d.file.ContentHash)
+	}
+	return nil
+}
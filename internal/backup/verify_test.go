@@ -0,0 +1,75 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestVerifyDownloads(t *testing.T) {
+	dir := t.TempDir()
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := &Engine{
+		config:    &config.Config{BackupDir: dir, VerifyAfter: true},
+		transfers: transfer.New(transfer.Options{Concurrency: 2}),
+		manifest:  m,
+	}
+
+	write := func(name, content, remoteContent string) dropbox.FileInfo {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := dropbox.ContentHash(bytes.NewReader([]byte(remoteContent)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := dropbox.FileInfo{Path: "/" + name, Name: name, Rev: "1", ContentHash: hash}
+		engine.recordFile(file, uint64(len(content)))
+		engine.rememberDownload(filepath.Join(dir, name), file)
+		return file
+	}
+	write("good.txt", "intact", "intact")
+	write("bad.txt", "corrupted on write", "original content")
+	engine.rememberDownload(filepath.Join(dir, "notes.paper.md"), dropbox.FileInfo{Path: "/notes.paper", ExportAs: "markdown"})
+
+	stats := &Stats{DownloadedFiles: 3}
+	err = engine.verifyDownloads(context.Background(), stats)
+	if err == nil {
+		t.Fatal("verifyDownloads() should fail for a corrupted file")
+	}
+
+	if stats.DownloadedFiles != 2 || stats.FailedFiles != 1 {
+		t.Errorf("stats = %d downloaded, %d failed; want 2, 1", stats.DownloadedFiles, stats.FailedFiles)
+	}
+	if stats.Failures[FailureChecksum] != 1 {
+		t.Errorf("stats.Failures = %v, want one %s", stats.Failures, FailureChecksum)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.txt")); !os.IsNotExist(err) {
+		t.Errorf("corrupted copy was kept: %v", err)
+	}
+	if _, ok := m.Get("/bad.txt"); ok {
+		t.Error("manifest entry of corrupted copy was kept")
+	}
+	if _, ok := m.Get("/good.txt"); !ok {
+		t.Error("manifest entry of verified copy was dropped")
+	}
+}
+
+func TestRememberDownloadDisabled(t *testing.T) {
+	engine := &Engine{config: &config.Config{}}
+	engine.rememberDownload("/backup/a.txt", dropbox.FileInfo{Path: "/a.txt", ContentHash: "abc"})
+	if len(engine.downloads) != 0 {
+		t.Errorf("downloads = %d, want none without --verify-after", len(engine.downloads))
+	}
+}
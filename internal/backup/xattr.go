@@ -0,0 +1,51 @@
+package backup
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/xattr"
+)
+
+// tagFile records the Dropbox revision and content hash of a downloaded file
+// in its extended attributes. Failures are only logged: the tag is a
+// convenience, the manifest stays authoritative.
+func (e *Engine) tagFile(localPath string, file dropbox.FileInfo) {
+	if e.xattrUnsupported.Load() {
+		return
+	}
+
+	err := xattr.Tag(localPath, file.Rev, file.ContentHash)
+	if errors.Is(err, xattr.ErrUnsupported) {
+		if !e.xattrUnsupported.Swap(true) {
+			slog.Warn("Extended attributes not supported, not tagging files",
+				slog.String("backup_dir", e.config.BackupDir),
+			)
+		}
+		return
+	}
+	if err != nil {
+		slog.Warn("Failed to tag file", slog.String("path", localPath), slog.String("error", err.Error()))
+	}
+}
+
+// taggedRevision returns the revision a local copy was tagged with, for files
+// the manifest doesn't know (e.g. after it was lost), and the size a copy of
+// it has. rev is empty when the file has no tag.
+func (e *Engine) taggedRevision(localPath string, stat os.FileInfo, remoteFile dropbox.FileInfo) (rev string, size int64) {
+	if !e.config.TagXattr {
+		return "", 0
+	}
+	rev, err := xattr.Rev(localPath)
+	if err != nil || rev == "" {
+		return "", 0
+	}
+
+	// Exported copies have a different size than the remote file
+	if remoteFile.ExportAs != "" {
+		return rev, stat.Size()
+	}
+	return rev, int64(remoteFile.Size)
+}
@@ -0,0 +1,55 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/xattr"
+)
+
+func TestShouldSkipFileTaggedRevision(t *testing.T) {
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(localPath, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// An old mtime, so the default comparison alone would download again
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(localPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := xattr.Tag(localPath, "rev1", ""); errors.Is(err, xattr.ErrUnsupported) {
+		t.Skip("extended attributes not supported here")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := dropbox.FileInfo{Path: "/report.pdf", Name: "report.pdf", Rev: "rev1", Size: 7, ModTime: time.Now()}
+
+	tests := []struct {
+		name     string
+		tagXattr bool
+		rev      string
+		want     bool
+	}{
+		{name: "tag matches", tagXattr: true, rev: "rev1", want: true},
+		{name: "tag is outdated", tagXattr: true, rev: "rev2", want: false},
+		{name: "tags ignored without --xattr", tagXattr: false, rev: "rev1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{config: &config.Config{BackupDir: dir, TagXattr: tt.tagXattr}}
+			file := remote
+			file.Rev = tt.rev
+			if got := engine.shouldSkipFile(localPath, file); got != tt.want {
+				t.Errorf("shouldSkipFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
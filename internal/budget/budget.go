@@ -0,0 +1,109 @@
+// Package budget limits the number of Dropbox API calls made in a rolling
+// 24 hour window. The calls are counted per hour, so the usage can be
+// persisted and carried over to the next run.
+package budget
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Window is the period the budget applies to
+const Window = 24 * time.Hour
+
+// ErrExhausted is returned by Take once the budget of the window is spent
+var ErrExhausted = errors.New("daily API call budget exhausted")
+
+// Usage counts API calls by the start of the hour they were made in, as a
+// Unix timestamp
+type Usage map[int64]int
+
+// Budget allows up to a limit of calls in the last 24 hours. It is safe for
+// concurrent use.
+type Budget struct {
+	mu    sync.Mutex
+	limit int
+	usage Usage
+	calls int
+	now   func() time.Time
+}
+
+// New creates a budget of limit calls per 24 hours, with the usage of
+// earlier runs counting against it
+func New(limit int, usage Usage) *Budget {
+	b := &Budget{
+		limit: limit,
+		usage: make(Usage, len(usage)),
+		now:   time.Now,
+	}
+	for hour, calls := range usage {
+		b.usage[hour] = calls
+	}
+	return b
+}
+
+// Take counts a call, or returns ErrExhausted if the budget is spent
+func (b *Budget) Take() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.prune(now)
+	if b.used() >= b.limit {
+		return ErrExhausted
+	}
+	b.usage[now.Truncate(time.Hour).Unix()]++
+	b.calls++
+	return nil
+}
+
+// Remaining returns the number of calls left in the current window
+func (b *Budget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune(b.now())
+	return max(b.limit-b.used(), 0)
+}
+
+// Calls returns the number of calls counted since the budget was created
+func (b *Budget) Calls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls
+}
+
+// Usage returns a copy of the calls of the current window, to be persisted
+// for the next run
+func (b *Budget) Usage() Usage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune(b.now())
+	usage := make(Usage, len(b.usage))
+	for hour, calls := range b.usage {
+		usage[hour] = calls
+	}
+	return usage
+}
+
+// prune forgets the hours that ended before the window. The hour the window
+// starts in is kept whole, erring on the side of fewer calls.
+func (b *Budget) prune(now time.Time) {
+	start := now.Add(-Window).Truncate(time.Hour).Unix()
+	for hour := range b.usage {
+		if hour < start {
+			delete(b.usage, hour)
+		}
+	}
+}
+
+// used returns the number of calls in the window
+func (b *Budget) used() int {
+	total := 0
+	for _, calls := range b.usage {
+		total += calls
+	}
+	return total
+}
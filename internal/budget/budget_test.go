@@ -0,0 +1,72 @@
+package budget
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	now := time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC)
+	hour := func(d time.Duration) int64 { return now.Add(d).Truncate(time.Hour).Unix() }
+
+	tests := []struct {
+		name  string
+		limit int
+		usage Usage
+		want  int
+	}{
+		{name: "fresh budget", limit: 3, want: 3},
+		{name: "earlier runs count", limit: 5, usage: Usage{hour(-2 * time.Hour): 3}, want: 2},
+		{name: "spent budget", limit: 5, usage: Usage{hour(-time.Hour): 5}, want: 0},
+		{name: "start hour of the window is kept", limit: 5, usage: Usage{hour(-Window): 4}, want: 1},
+		{name: "older hours are forgotten", limit: 5, usage: Usage{hour(-Window - time.Hour): 5}, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.limit, tt.usage)
+			b.now = func() time.Time { return now }
+
+			if got := b.Remaining(); got != tt.want {
+				t.Errorf("Remaining() = %d, want %d", got, tt.want)
+			}
+			for i := 0; i < tt.want; i++ {
+				if err := b.Take(); err != nil {
+					t.Fatalf("Take() #%d error = %v", i+1, err)
+				}
+			}
+			if err := b.Take(); !errors.Is(err, ErrExhausted) {
+				t.Errorf("Take() beyond the budget error = %v, want ErrExhausted", err)
+			}
+			if got := b.Calls(); got != tt.want {
+				t.Errorf("Calls() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	now := time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC)
+	old := now.Add(-2 * Window).Truncate(time.Hour).Unix()
+	earlier := now.Add(-time.Hour).Truncate(time.Hour).Unix()
+	usage := Usage{old: 7, earlier: 2}
+
+	b := New(10, usage)
+	b.now = func() time.Time { return now }
+	b.Take()
+
+	got := b.Usage()
+	want := Usage{earlier: 2, now.Truncate(time.Hour).Unix(): 1}
+	if len(got) != len(want) {
+		t.Fatalf("Usage() = %v, want %v", got, want)
+	}
+	for hour, calls := range want {
+		if got[hour] != calls {
+			t.Errorf("Usage()[%d] = %d, want %d", hour, got[hour], calls)
+		}
+	}
+	if usage[old] != 7 {
+		t.Error("New() modified the usage it was given")
+	}
+}
@@ -0,0 +1,124 @@
+// Package compare decides whether a local copy of a Dropbox file is up to
+// date, by the --compare strategy. Backup, restore and verify-archive all ask
+// it, so they agree on which copies hold the content of which files.
+package compare
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// Local is a local copy of a Dropbox file
+type Local struct {
+	// Path is read when the copy is hashed
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// Rev and RevSize are the revision and size recorded when the copy was
+	// downloaded; Rev is empty if none was recorded
+	Rev     string
+	RevSize int64
+}
+
+// Strategy compares local copies with Dropbox files
+type Strategy struct {
+	// Mode is config.CompareMtimeSize (also when empty), CompareHash or
+	// CompareRev
+	Mode string
+	// Verify also hashes copies matched by revision, and hashes instead of
+	// comparing size and modification time (--verify)
+	Verify bool
+	// Skew is how far the local clock is ahead of Dropbox's
+	Skew time.Duration
+	// Open opens a local copy for hashing; nil opens it with os.Open
+	Open func(path string) (io.ReadCloser, error)
+}
+
+// Same reports whether local holds the content of remote:
+//
+//   - mtime,size (default): its recorded revision is the remote one or,
+//     without recorded revisions, it has the same size and modification time.
+//     Cheap, but trusts timestamps.
+//   - hash: its Dropbox content hash is the remote one. Reads every copy but
+//     detects silent corruption and local edits, so recorded revisions are
+//     ignored.
+//   - rev: its recorded revision is the remote one. A copy without a recorded
+//     revision is never the same.
+//
+// A copy of another size than recorded isn't the recorded revision, e.g.
+// when it was truncated or replaced.
+func (s Strategy) Same(local Local, remote dropbox.FileInfo) (bool, error) {
+	if s.byRevision(local, remote) {
+		if local.Rev != remote.Rev || local.Size != local.RevSize {
+			return false, nil
+		}
+		if !s.Verify || remote.ContentHash == "" {
+			return true, nil
+		}
+		same, err := s.sameContentHash(local.Path, remote)
+		if err == nil && !same {
+			slog.Warn("Local copy no longer has the content of its recorded revision", slog.String("path", local.Path))
+		}
+		return same, err
+	}
+
+	switch {
+	case s.Mode == config.CompareRev:
+		return false, nil
+	case s.Mode == config.CompareHash, s.Verify:
+		return s.sameContentHash(local.Path, remote)
+	default:
+		return local.Size == int64(remote.Size) && !remote.ModTime.IsZero() && local.ModTime.Equal(remote.ModTime), nil
+	}
+}
+
+// UpToDate reports whether a backup keeps local instead of downloading
+// remote: it is the Same, or, in mtime,size mode without recorded revisions,
+// it was edited locally after the remote change. Local times are corrected
+// for the skew first; copies whose time was set from Dropbox at download are
+// compared as they are.
+func (s Strategy) UpToDate(local Local, remote dropbox.FileInfo) (bool, error) {
+	same, err := s.Same(local, remote)
+	if same || err != nil {
+		return same, err
+	}
+	if s.Mode != config.CompareHash && s.Mode != config.CompareRev && !s.Verify && !s.byRevision(local, remote) {
+		return !remote.ModTime.IsZero() && local.ModTime.Add(-s.Skew).After(remote.ModTime), nil
+	}
+	return false, nil
+}
+
+// byRevision reports whether local and remote are compared by revision
+func (s Strategy) byRevision(local Local, remote dropbox.FileInfo) bool {
+	return s.Mode != config.CompareHash && local.Rev != "" && remote.Rev != ""
+}
+
+// sameContentHash implements the hash comparison; without a remote hash
+// there's nothing to compare against and the copy isn't the same
+func (s Strategy) sameContentHash(localPath string, remote dropbox.FileInfo) (bool, error) {
+	if remote.ContentHash == "" {
+		return false, nil
+	}
+
+	open := s.Open
+	if open == nil {
+		open = func(path string) (io.ReadCloser, error) { return os.Open(path) }
+	}
+	f, err := open(localPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer f.Close()
+
+	hash, err := dropbox.ContentHash(f)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash local file: %w", err)
+	}
+	return hash == remote.ContentHash, nil
+}
@@ -0,0 +1,115 @@
+package compare
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+func TestStrategySame(t *testing.T) {
+	content := []byte("test content")
+	localPath := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(localPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	matchingHash, err := dropbox.ContentHash(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherHash, err := dropbox.ContentHash(bytes.NewReader([]byte("other content")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	size := int64(len(content))
+	unrecorded := Local{Path: localPath, Size: size, ModTime: modTime}
+	recorded := Local{Path: localPath, Size: size, ModTime: modTime.Add(-time.Hour), Rev: "rev1", RevSize: size}
+	truncated := Local{Path: localPath, Size: size, ModTime: modTime, Rev: "rev1", RevSize: size + 1}
+
+	tests := []struct {
+		name   string
+		mode   string
+		verify bool
+		local  Local
+		remote dropbox.FileInfo
+		want   bool
+	}{
+		{name: "mtime,size matches", mode: config.CompareMtimeSize, local: unrecorded, remote: dropbox.FileInfo{Size: 12, ModTime: modTime, ContentHash: otherHash}, want: true},
+		{name: "mtime,size is the default", local: unrecorded, remote: dropbox.FileInfo{Size: 12, ModTime: modTime}, want: true},
+		{name: "mtime,size differs in size", mode: config.CompareMtimeSize, local: unrecorded, remote: dropbox.FileInfo{Size: 5, ModTime: modTime, ContentHash: matchingHash}, want: false},
+		{name: "mtime,size trusts the recorded revision", mode: config.CompareMtimeSize, local: recorded, remote: dropbox.FileInfo{Size: 12, ModTime: modTime, Rev: "rev1"}, want: true},
+		{name: "mtime,size sees a new revision", mode: config.CompareMtimeSize, local: recorded, remote: dropbox.FileInfo{Size: 12, ModTime: modTime.Add(-time.Hour), Rev: "rev2"}, want: false},
+		{name: "hash matches", mode: config.CompareHash, local: unrecorded, remote: dropbox.FileInfo{Size: 5, ContentHash: matchingHash}, want: true},
+		{name: "hash ignores the recorded revision", mode: config.CompareHash, local: recorded, remote: dropbox.FileInfo{Size: 12, Rev: "rev1", ContentHash: otherHash}, want: false},
+		{name: "hash missing on remote", mode: config.CompareHash, local: unrecorded, remote: dropbox.FileInfo{Size: 12, ModTime: modTime}, want: false},
+		{name: "rev matches", mode: config.CompareRev, local: recorded, remote: dropbox.FileInfo{Size: 5, Rev: "rev1", ContentHash: otherHash}, want: true},
+		{name: "rev changed", mode: config.CompareRev, local: recorded, remote: dropbox.FileInfo{Size: 12, Rev: "rev2", ContentHash: matchingHash}, want: false},
+		{name: "rev without recorded revision", mode: config.CompareRev, local: unrecorded, remote: dropbox.FileInfo{Size: 12, ModTime: modTime, Rev: "rev1", ContentHash: matchingHash}, want: false},
+		{name: "rev of a truncated copy", mode: config.CompareRev, local: truncated, remote: dropbox.FileInfo{Size: 13, Rev: "rev1"}, want: false},
+		{name: "verify catches a corrupted copy of the revision", mode: config.CompareRev, verify: true, local: recorded, remote: dropbox.FileInfo{Rev: "rev1", ContentHash: otherHash}, want: false},
+		{name: "verify keeps an intact copy of the revision", mode: config.CompareRev, verify: true, local: recorded, remote: dropbox.FileInfo{Rev: "rev1", ContentHash: matchingHash}, want: true},
+		{name: "verify hashes instead of mtime,size", mode: config.CompareMtimeSize, verify: true, local: unrecorded, remote: dropbox.FileInfo{Size: 12, ModTime: modTime, ContentHash: otherHash}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Strategy{Mode: tt.mode, Verify: tt.verify}
+			got, err := s.Same(tt.local, tt.remote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Same() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategySameMissingFile(t *testing.T) {
+	s := Strategy{Mode: config.CompareHash}
+	_, err := s.Same(Local{Path: filepath.Join(t.TempDir(), "missing")}, dropbox.FileInfo{ContentHash: "abc"})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Same() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestStrategyUpToDate(t *testing.T) {
+	remoteTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	remote := dropbox.FileInfo{Size: 10, ModTime: remoteTime, Rev: "rev1"}
+
+	tests := []struct {
+		name  string
+		mode  string
+		local Local
+		skew  time.Duration
+		want  bool
+	}{
+		{name: "edited locally after the remote change", local: Local{Size: 5, ModTime: remoteTime.Add(time.Hour)}, want: true},
+		{name: "seemingly newer only by a clock running ahead", local: Local{Size: 5, ModTime: remoteTime.Add(time.Hour)}, skew: 2 * time.Hour, want: false},
+		{name: "newer than a clock running behind suggests", local: Local{Size: 5, ModTime: remoteTime.Add(-time.Minute)}, skew: -time.Hour, want: true},
+		{name: "time set from Dropbox at download", local: Local{Size: 10, ModTime: remoteTime}, skew: 2 * time.Hour, want: true},
+		{name: "older copy", local: Local{Size: 5, ModTime: remoteTime.Add(-time.Minute)}, want: false},
+		{name: "newer copy of another recorded revision", local: Local{Size: 5, ModTime: remoteTime.Add(time.Hour), Rev: "rev0", RevSize: 5}, want: false},
+		{name: "rev mode ignores a newer copy", mode: config.CompareRev, local: Local{Size: 5, ModTime: remoteTime.Add(time.Hour)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Strategy{Mode: tt.mode, Skew: tt.skew}
+			got, err := s.UpToDate(tt.local, remote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("UpToDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
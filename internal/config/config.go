@@ -2,9 +2,84 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"create-dropbox-backup-folder/internal/budget"
+	"create-dropbox-backup-folder/internal/configfile"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/filter"
+	"create-dropbox-backup-folder/internal/localfs"
+	"create-dropbox-backup-folder/internal/retry"
+	"create-dropbox-backup-folder/internal/throttle"
+	"create-dropbox-backup-folder/internal/tokenstore"
+)
+
+// Placeholders supported in the backup directory path. They are expanded at
+// run time by ExpandBackupDir.
+const (
+	PlaceholderDate         = "{date}"
+	PlaceholderTime         = "{time}"
+	PlaceholderAccountEmail = "{account_email}"
+	PlaceholderProfile      = "{profile}"
+)
+
+// maxWriteBuffer bounds the write buffer, which every parallel download
+// allocates
+const maxWriteBuffer = 1 << 30
+
+// DefaultProfile is the profile name used when none is selected
+const DefaultProfile = "default"
+
+// ProfileName returns the selected profile: the --profile option, else
+// DROPBOX_PROFILE, else DefaultProfile
+func ProfileName(option string) string {
+	if option != "" {
+		return option
+	}
+	if profile := os.Getenv("DROPBOX_PROFILE"); profile != "" {
+		return profile
+	}
+	return DefaultProfile
+}
+
+// fsys and now are the file system and clock of the package, replaced in
+// tests to simulate failures and fixed times
+var (
+	fsys localfs.FS = localfs.OS
+	now             = time.Now
+)
+
+// Comparison strategies for deciding whether a local file is up to date
+const (
+	// CompareMtimeSize trusts a local file whose size and modification time match
+	CompareMtimeSize = "mtime,size"
+	// CompareHash hashes the local file and compares it to the Dropbox content hash
+	CompareHash = "hash"
+	// CompareRev compares the Dropbox revision recorded at the last download
+	CompareRev = "rev"
+)
+
+// Local layouts of shared folders
+const (
+	// LayoutMounted mirrors shared folders wherever they are mounted in Dropbox
+	LayoutMounted = "mounted"
+	// LayoutShared places shared folders under /shared/<owner>/<folder name>
+	LayoutShared = "shared"
+)
+
+// What to do with files larger than the backup file system can store
+const (
+	// OversizeSkip skips them and reports them after the run
+	OversizeSkip = "skip"
+	// OversizeFail counts them as failed
+	OversizeFail = "fail"
+	// OversizeDownload downloads them anyway
+	OversizeDownload = "download"
 )
 
 // Config holds the application configuration
@@ -14,41 +89,368 @@ type Config struct {
 	ClientSecret string `json:"client_secret"`
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	// TokenExpiry is when the access token loaded from the token store
+	// expires; zero for tokens from the environment
+	TokenExpiry time.Time `json:"-"`
+	// StoredToken is set when the tokens came from the token store, which
+	// then receives refreshed tokens
+	StoredToken bool `json:"-"`
+	// TokenStore is where auth and setup keep the tokens (tokenstore.Modes)
+	TokenStore string `json:"token_store"`
+
+	// Base URLs of the Dropbox API, e.g. a proxy or a mock server; empty
+	// keeps the default
+	APIURL     string `json:"api_url"`
+	ContentURL string `json:"content_url"`
+	NotifyURL  string `json:"notify_url"`
+	AuthURL    string `json:"auth_url"`
+
+	// ConfigFile is the YAML or TOML file the settings were read from, if any
+	ConfigFile string `json:"-"`
 
 	// Backup settings
-	BackupDir string   `json:"backup_dir"`
-	Delete    bool     `json:"delete"`
-	Exclude   []string `json:"exclude"`
+	BackupDir string `json:"backup_dir"`
+	Delete    bool   `json:"delete"`
+
+	// Mirrors are further directories that receive every downloaded file in
+	// the same pass, e.g. a second disk for a 3-2-1 backup
+	Mirrors []string `json:"mirrors"`
+
+	// ColdDir receives the files not modified on Dropbox for ColdAfterDays
+	// days, keeping the backup directory small; empty to keep everything in
+	// the backup directory
+	ColdDir       string `json:"cold_dir"`
+	ColdAfterDays int    `json:"cold_after_days"`
+
+	// PostRunCommand runs through the shell after a successful run with the
+	// path of a file listing the changed files, e.g. to replicate them with
+	// rclone
+	PostRunCommand string `json:"post_run_command"`
+
+	// ForceAccountSwitch backs up into a directory holding another Dropbox
+	// account's files instead of refusing to
+	ForceAccountSwitch bool `json:"force_account_switch"`
+
+	// ReadOnly refuses to run with a token that can change the account
+	ReadOnly bool `json:"read_only"`
+
+	Exclude []string `json:"exclude"`
+	Include []string `json:"include"`
+	// PatternFiles holds the patterns of the "@file" include and exclude
+	// files by file name, read by Load
+	PatternFiles map[string][]string `json:"-"`
+	RemotePaths  []string            `json:"remote_paths"`
+	Profile      string              `json:"profile"`
+	// Preset names a bundle of remote paths, filters and layout, built in or
+	// defined in the settings file
+	Preset string `json:"preset"`
+
+	// TeamSpace also backs up the team space of team member accounts, below
+	// a separate local root per namespace
+	TeamSpace bool `json:"team_space"`
+	// Layout selects where shared folders are stored locally (LayoutMounted
+	// or LayoutShared)
+	Layout string `json:"layout"`
+	// AccountMetadata also exports file requests, connected apps and sharing
+	// policies into a JSON bundle in the backup root
+	AccountMetadata bool `json:"account_metadata"`
+	// Archive makes downloaded files read-only, never overwrites or deletes
+	// them, and chains the manifest hash of every run (see package archive)
+	Archive bool `json:"archive"`
+	// SignKey is the GPG key that signs the manifest after every run; empty
+	// disables signing
+	SignKey string `json:"sign_key"`
+	// VerifyAfter re-reads the files downloaded by a run from disk and
+	// compares them with their Dropbox content hash
+	VerifyAfter bool `json:"verify_after"`
+	// Verify hashes existing local copies and compares them with the Dropbox
+	// content hash, also when their recorded revision matches, downloading
+	// corrupted copies again
+	Verify bool `json:"verify"`
+	// SpotCheck is how many files skipped as up to date are chosen at random
+	// after a run and checked against fresh Dropbox metadata and their
+	// content hash; 0 disables the spot check
+	SpotCheck int `json:"spot_check"`
+	// RcloneSum writes the recorded content hashes to a sum file in the
+	// format of "rclone hashsum dropbox" after every run
+	RcloneSum bool `json:"rclone_sum"`
+	// SearchExtensions finds the files of extension-only include patterns
+	// (e.g. "*.pdf") with the Dropbox search index instead of listing every
+	// folder. The index can lag behind recent changes.
+	SearchExtensions bool `json:"search_extensions"`
+	// FullListing lists the whole account even if the backup directory holds
+	// a listing from the last run that only the changes could be applied to
+	FullListing bool `json:"full_listing"`
 
 	// Application settings
-	LogLevel  string `json:"log_level"`
-	ShowCount bool   `json:"show_count"`
-	ShowSize  bool   `json:"show_size"`
+	MetricsFile     string `json:"metrics_file"`
+	LogLevel        string `json:"log_level"`
+	ShowCount       bool   `json:"show_count"`
+	ShowSize        bool   `json:"show_size"`
+	ExplainFilters  bool   `json:"explain_filters"`
+	ListUnsupported bool   `json:"list_unsupported"`
+	FolderStats     bool   `json:"folder_stats"`
+	Progress        bool   `json:"progress"`
+	// NoProgress reports the progress of downloads in a line per progress
+	// interval even on a terminal, instead of a live display
+	NoProgress bool `json:"no_progress"`
+	DryRun     bool `json:"dry_run"`
+
+	// Chaos injects failures into Dropbox requests for operational testing,
+	// see dropbox.ParseChaos; empty injects none
+	Chaos string `json:"chaos"`
+
+	// TerminalTitle keeps the download progress in the terminal title, and
+	// Notify shows a desktop notification when a backup ends
+	TerminalTitle bool `json:"terminal_title"`
+	Notify        bool `json:"notify"`
+
+	// AlertWebhook receives the outcome of every run as JSON, with the run
+	// ID, a link to its report and up to AlertFailures failed paths
+	AlertWebhook  string `json:"alert_webhook"`
+	AlertFailures int    `json:"alert_failures"`
+	// ReportDir keeps the full JSON report of every run as <run id>.json,
+	// and ReportURL is where that directory is served, for links in alerts
+	ReportDir string `json:"report_dir"`
+	ReportURL string `json:"report_url"`
+
+	// Transfer settings
+	BandwidthLimit    string `json:"bwlimit"`
+	BandwidthSchedule string `json:"bwlimit_schedule"`
+	PauseOnMetered    bool   `json:"pause_on_metered"`
+	RequireInterface  string `json:"require_interface"`
+
+	// WriteBuffer is the size local files are written in (e.g. "4M"); empty
+	// uses a small default. SerializeWrites writes to one file at a time per
+	// device while downloads continue in parallel, which suits spinning disks.
+	WriteBuffer     string `json:"write_buffer"`
+	SerializeWrites bool   `json:"serialize_writes"`
+
+	// LargeFileThreshold (e.g. "1G") sends files of at least this size to a
+	// separate lane of LargeFileConcurrency downloads, so a few large files
+	// don't occupy every worker; empty disables the lane
+	LargeFileThreshold   string `json:"large_file_threshold"`
+	LargeFileConcurrency int    `json:"large_file_concurrency"`
+
+	// MaxFileSize (e.g. "4G") is the largest file the backup file system can
+	// store; empty detects it (e.g. FAT32 stores files up to 4 GiB). Larger
+	// files are handled as OnOversize says before they are downloaded.
+	MaxFileSize string `json:"max_file_size"`
+	OnOversize  string `json:"on_oversize"`
+
+	// MaxMemory (e.g. "512M") is a memory budget the transfer, queue, hash
+	// and listing limits are derived from, see MemoryPlan; empty is unbounded
+	MaxMemory string `json:"max_memory"`
+
+	// HashWorkers bounds the files hashed at once by --verify-after; 0 uses
+	// as many as transfers
+	HashWorkers int `json:"hash_workers"`
+
+	// ProgressInterval is how often progress is reported while listing,
+	// downloading or deleting
+	ProgressInterval time.Duration `json:"progress_interval"`
+
+	// LogBatch summarizes per-file info logs (e.g. "Downloaded file") once
+	// per interval instead of writing a line per file; 0 writes each
+	LogBatch time.Duration `json:"log_batch"`
+
+	// HardwareProfile (e.g. "low") tunes the settings left at their
+	// defaults for a class of device, see hardwareProfiles
+	HardwareProfile string `json:"hardware_profile"`
+
+	// HistoricalThroughput is the download throughput of earlier runs of
+	// the profile in bytes per second, 0 if unknown; see
+	// ProfileSettings.Throughput
+	HistoricalThroughput float64 `json:"historical_throughput"`
+
+	// HideDotFiles marks downloaded files whose name starts with a dot hidden
+	// on Windows, where the name alone doesn't hide them
+	HideDotFiles bool `json:"hide_dot_files"`
+
+	// TagXattr tags downloaded files with extended attributes holding their
+	// Dropbox revision and content hash
+	TagXattr bool `json:"tag_xattr"`
+
+	// Sidecar keeps the modification time, content hash and revision of
+	// backed-up files in a metadata file per directory, for targets that
+	// can't preserve modification times
+	Sidecar bool `json:"sidecar"`
+
+	// ExportFormats maps file extensions of export-only files (e.g. "paper")
+	// to the format they are exported in (e.g. "markdown")
+	ExportFormats map[string]string `json:"export_formats"`
+
+	// Compare selects how an existing local file is judged up to date
+	// (CompareMtimeSize, CompareHash or CompareRev)
+	Compare string `json:"compare"`
+
+	// OutageTimeout is how long to wait for connectivity to return after a
+	// network drop before failing the run (0 fails immediately)
+	OutageTimeout time.Duration `json:"outage_timeout"`
+
+	// APITimeout bounds each metadata and listing call (0 disables).
+	// FileTimeout bounds the download of a single file (0 disables), which
+	// may legitimately take much longer.
+	APITimeout  time.Duration `json:"api_timeout"`
+	FileTimeout time.Duration `json:"file_timeout"`
+	// StallTimeout aborts a download whose body delivers no data for this
+	// long and retries it (0 disables)
+	StallTimeout time.Duration `json:"stall_timeout"`
+	// MaxClockSkew is how far the local clock may be off from Dropbox's
+	// before a warning is logged and modification times compared by the
+	// mtime,size comparison are corrected for it (0 disables the check)
+	MaxClockSkew time.Duration `json:"max_clock_skew"`
+
+	// StateBackend is a directory or http(s) URL shared by instances backing
+	// up the same account; empty disables coordination
+	StateBackend string `json:"state_backend"`
+	// InstanceID names this instance in the shared state (defaults to the hostname)
+	InstanceID string `json:"instance_id"`
 
 	// Runtime settings
-	MaxConcurrency int           `json:"max_concurrency"`
-	RetryAttempts  int           `json:"retry_attempts"`
-	RetryDelay     time.Duration `json:"retry_delay"`
+	MaxConcurrency int `json:"max_concurrency"`
+
+	// APICallBudget caps the Dropbox API calls made with the app key in any
+	// 24 hours, counting the calls of earlier runs; 0 for no limit
+	APICallBudget int `json:"api_call_budget"`
+	// APIUsage holds the calls of earlier runs with the app key, see
+	// Settings.APIUsage
+	APIUsage budget.Usage `json:"-"`
+	// APIBudget counts the calls against APICallBudget when it is shared by
+	// several clients of the process, e.g. across the runs of the daemon;
+	// nil starts a budget from APIUsage
+	APIBudget *budget.Budget `json:"-"`
+
+	// Retry is the policy for retrying failed API calls
+	Retry retry.Policy `json:"retry"`
+}
+
+// PathValues holds the run-time values substituted into backup directory placeholders
+type PathValues struct {
+	Now          time.Time
+	AccountEmail string
+	Profile      string
 }
 
 // Options represents command-line options for configuration
 type Options struct {
-	ConfigFile string
-	BackupDir  string
-	LogLevel   string
-	Delete     bool
-	Exclude    []string
-	ShowCount  bool
-	ShowSize   bool
+	ConfigFile      string
+	Profile         string
+	BackupDir       string
+	Mirrors         []string
+	PostRunCommand  string
+	ColdDir         string
+	ColdAfterDays   int
+	LogLevel        string
+	Delete          bool
+	ForceAccount    bool
+	ReadOnly        bool
+	Exclude         []string
+	Include         []string
+	RemotePaths     []string
+	ShowCount       bool
+	ShowSize        bool
+	ExplainFilters  bool
+	ListUnsupported bool
+	FolderStats     bool
+	Progress        bool
+	NoProgress      bool
+	Chaos           string
+	DryRun          bool
+	TerminalTitle   bool
+	Notify          bool
+	AlertWebhook    string
+	// AlertFailures overrides the failed paths listed in alerts when > 0
+	AlertFailures int
+	ReportDir     string
+	ReportURL     string
+
+	// Concurrency overrides the number of parallel transfers when > 0
+	Concurrency int
+
+	BandwidthLimit       string
+	BandwidthSchedule    string
+	PauseOnMetered       bool
+	RequireInterface     string
+	WriteBuffer          string
+	SerializeWrites      bool
+	LargeFileThreshold   string
+	LargeFileConcurrency int
+	MaxFileSize          string
+	OnOversize           string
+	MaxMemory            string
+	HashWorkers          int
+	ProgressInterval     *time.Duration
+	LogBatch             *time.Duration
+	HardwareProfile      string
+	HideDotFiles         bool
+	TagXattr             bool
+	Sidecar              bool
+	OutageTimeout        *time.Duration
+	APITimeout           *time.Duration
+	FileTimeout          *time.Duration
+	StallTimeout         *time.Duration
+	MaxClockSkew         *time.Duration
+	APICallBudget        int
+	MetricsFile          string
+	Compare              string
+	ExportFormats        []string
+	StateBackend         string
+	InstanceID           string
+	TeamSpace            bool
+	Layout               string
+	AccountMetadata      bool
+	Archive              bool
+	SignKey              string
+	VerifyAfter          bool
+	SpotCheck            int
+	Verify               bool
+	RcloneSum            bool
+	SearchExtensions     bool
+	FullListing          bool
+	Preset               string
 }
 
-// Load creates a new configuration from options and environment variables
-func Load(opts Options) (*Config, error) {
-	cfg := &Config{
+// defaultConfig returns the configuration before the environment, settings
+// and options are applied
+func defaultConfig() *Config {
+	return &Config{
+		Profile:        DefaultProfile,
 		LogLevel:       "error",
 		MaxConcurrency: 5,
-		RetryAttempts:  3,
-		RetryDelay:     time.Second * 2,
+		TokenStore:     tokenstore.ModeAuto,
+		Retry:          retry.DefaultPolicy(),
+		OutageTimeout:  30 * time.Minute,
+		APITimeout:     time.Minute,
+		StallTimeout:   5 * time.Minute,
+		MaxClockSkew:   time.Minute,
+
+		LargeFileConcurrency: 2,
+		Compare:              CompareMtimeSize,
+		Layout:               LayoutMounted,
+		OnOversize:           OversizeSkip,
+		ProgressInterval:     10 * time.Second,
+		AlertFailures:        10,
+	}
+}
+
+// Load creates a new configuration from options and environment variables
+func Load(opts Options) (*Config, error) {
+	cfg := defaultConfig()
+
+	// Precedence: command-line options > selected profile > environment >
+	// config file > defaults
+	configFile := opts.ConfigFile
+	if configFile == "" {
+		configFile = os.Getenv("DROPBOX_CONFIG_FILE")
+	}
+	var profiles configfile.Values
+	if configFile != "" {
+		var err error
+		if profiles, err = cfg.loadFile(configFile); err != nil {
+			return nil, err
+		}
+		cfg.ConfigFile = configFile
 	}
 
 	// Load from environment variables
@@ -56,6 +458,41 @@ func Load(opts Options) (*Config, error) {
 		return nil, fmt.Errorf("failed to load from environment: %w", err)
 	}
 
+	// The profile's own settings, e.g. the credentials of another account,
+	// override the environment shared by all profiles
+	cfg.Profile = ProfileName(opts.Profile)
+	if err := cfg.applyProfile(profiles); err != nil {
+		return nil, err
+	}
+
+	// Load settings persisted by earlier runs (e.g. --choose selections)
+	settings, err := LoadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved settings: %w", err)
+	}
+	cfg.applySettings(settings)
+
+	// Tokens in the environment take priority over stored ones
+	if err := cfg.loadStoredToken(); err != nil {
+		return nil, err
+	}
+
+	// Apply the selected preset before the options that override it
+	if opts.Preset != "" {
+		cfg.Preset = opts.Preset
+	}
+	if err := cfg.applyPreset(settings); err != nil {
+		return nil, err
+	}
+
+	// Likewise the hardware profile, which only changes defaults
+	if opts.HardwareProfile != "" {
+		cfg.HardwareProfile = opts.HardwareProfile
+	}
+	if err := cfg.applyHardwareProfile(); err != nil {
+		return nil, err
+	}
+
 	// Override with command-line options
 	if opts.LogLevel != "" {
 		cfg.LogLevel = opts.LogLevel
@@ -63,16 +500,233 @@ func Load(opts Options) (*Config, error) {
 	if opts.Delete {
 		cfg.Delete = opts.Delete
 	}
+	if opts.ForceAccount {
+		cfg.ForceAccountSwitch = true
+	}
+	if opts.ReadOnly {
+		cfg.ReadOnly = true
+	}
 	if len(opts.Exclude) > 0 {
 		cfg.Exclude = opts.Exclude
 	}
-	cfg.ShowCount = opts.ShowCount
-	cfg.ShowSize = opts.ShowSize
+	if len(opts.Mirrors) > 0 {
+		cfg.Mirrors = append([]string(nil), opts.Mirrors...)
+	}
+	if opts.PostRunCommand != "" {
+		cfg.PostRunCommand = opts.PostRunCommand
+	}
+	if opts.ColdDir != "" {
+		cfg.ColdDir = opts.ColdDir
+	}
+	if opts.ColdAfterDays > 0 {
+		cfg.ColdAfterDays = opts.ColdAfterDays
+	}
+	cfg.Exclude = append(cfg.Exclude, settings.Profile(cfg.Profile).SyncExclude...)
+	if len(opts.Include) > 0 {
+		cfg.Include = opts.Include
+	}
+	if len(opts.RemotePaths) > 0 {
+		cfg.RemotePaths = opts.RemotePaths
+	}
+	cfg.RemotePaths = normalizeRemotePaths(cfg.RemotePaths)
+	if opts.ShowCount {
+		cfg.ShowCount = true
+	}
+	if opts.ShowSize {
+		cfg.ShowSize = true
+	}
+	if opts.ExplainFilters {
+		cfg.ExplainFilters = true
+	}
+	if opts.ListUnsupported {
+		cfg.ListUnsupported = true
+	}
+	if opts.FolderStats {
+		cfg.FolderStats = true
+	}
+	if opts.Progress {
+		cfg.Progress = true
+	}
+	if opts.NoProgress {
+		cfg.NoProgress = true
+	}
+	if opts.Chaos != "" {
+		cfg.Chaos = opts.Chaos
+	}
+	if opts.TerminalTitle {
+		cfg.TerminalTitle = true
+	}
+	if opts.Notify {
+		cfg.Notify = true
+	}
+	if opts.AlertWebhook != "" {
+		cfg.AlertWebhook = opts.AlertWebhook
+	}
+	if opts.AlertFailures > 0 {
+		cfg.AlertFailures = opts.AlertFailures
+	}
+	if opts.ReportDir != "" {
+		cfg.ReportDir = opts.ReportDir
+	}
+	if opts.ReportURL != "" {
+		cfg.ReportURL = opts.ReportURL
+	}
+	if opts.DryRun {
+		cfg.DryRun = true
+	}
+	if opts.Concurrency > 0 {
+		cfg.MaxConcurrency = opts.Concurrency
+	}
+	if opts.BandwidthLimit != "" {
+		cfg.BandwidthLimit = opts.BandwidthLimit
+	}
+	if opts.BandwidthSchedule != "" {
+		cfg.BandwidthSchedule = opts.BandwidthSchedule
+	}
+	if opts.PauseOnMetered {
+		cfg.PauseOnMetered = opts.PauseOnMetered
+	}
+	if opts.RequireInterface != "" {
+		cfg.RequireInterface = opts.RequireInterface
+	}
+	if opts.WriteBuffer != "" {
+		cfg.WriteBuffer = opts.WriteBuffer
+	}
+	if opts.SerializeWrites {
+		cfg.SerializeWrites = opts.SerializeWrites
+	}
+	if opts.LargeFileThreshold != "" {
+		cfg.LargeFileThreshold = opts.LargeFileThreshold
+	}
+	if opts.LargeFileConcurrency > 0 {
+		cfg.LargeFileConcurrency = opts.LargeFileConcurrency
+	}
+	if opts.MaxFileSize != "" {
+		cfg.MaxFileSize = opts.MaxFileSize
+	}
+	if opts.OnOversize != "" {
+		cfg.OnOversize = opts.OnOversize
+	}
+	if opts.MaxMemory != "" {
+		cfg.MaxMemory = opts.MaxMemory
+	}
+	if opts.HashWorkers > 0 {
+		cfg.HashWorkers = opts.HashWorkers
+	}
+	if opts.ProgressInterval != nil {
+		cfg.ProgressInterval = *opts.ProgressInterval
+	}
+	if opts.LogBatch != nil {
+		cfg.LogBatch = *opts.LogBatch
+	}
+	if opts.HideDotFiles {
+		cfg.HideDotFiles = opts.HideDotFiles
+	}
+	if opts.TagXattr {
+		cfg.TagXattr = opts.TagXattr
+	}
+	if opts.Sidecar {
+		cfg.Sidecar = opts.Sidecar
+	}
+	if opts.MetricsFile != "" {
+		cfg.MetricsFile = opts.MetricsFile
+	}
+	if opts.Compare != "" {
+		cfg.Compare = opts.Compare
+	}
+	if len(opts.ExportFormats) > 0 {
+		formats, err := ParseExportFormats(opts.ExportFormats)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ExportFormats = formats
+	}
+	if opts.OutageTimeout != nil {
+		cfg.OutageTimeout = *opts.OutageTimeout
+	}
+	if opts.APITimeout != nil {
+		cfg.APITimeout = *opts.APITimeout
+	}
+	if opts.FileTimeout != nil {
+		cfg.FileTimeout = *opts.FileTimeout
+	}
+	if opts.StallTimeout != nil {
+		cfg.StallTimeout = *opts.StallTimeout
+	}
+	if opts.MaxClockSkew != nil {
+		cfg.MaxClockSkew = *opts.MaxClockSkew
+	}
+	if opts.TeamSpace {
+		cfg.TeamSpace = opts.TeamSpace
+	}
+	if opts.VerifyAfter {
+		cfg.VerifyAfter = opts.VerifyAfter
+	}
+	if opts.SpotCheck > 0 {
+		cfg.SpotCheck = opts.SpotCheck
+	}
+	if opts.APICallBudget > 0 {
+		cfg.APICallBudget = opts.APICallBudget
+	}
+	if opts.Verify {
+		cfg.Verify = opts.Verify
+	}
+	if opts.SignKey != "" {
+		cfg.SignKey = opts.SignKey
+	}
+	if opts.Archive {
+		cfg.Archive = opts.Archive
+	}
+	if opts.RcloneSum {
+		cfg.RcloneSum = opts.RcloneSum
+	}
+	if opts.SearchExtensions {
+		cfg.SearchExtensions = opts.SearchExtensions
+	}
+	if opts.FullListing {
+		cfg.FullListing = opts.FullListing
+	}
+	if opts.AccountMetadata {
+		cfg.AccountMetadata = opts.AccountMetadata
+	}
+	if opts.Layout != "" {
+		cfg.Layout = opts.Layout
+	}
+	if opts.StateBackend != "" {
+		cfg.StateBackend = opts.StateBackend
+	}
+	if opts.InstanceID != "" {
+		cfg.InstanceID = opts.InstanceID
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID, _ = os.Hostname()
+	}
 
 	// Set backup directory
 	if err := cfg.setBackupDir(opts.BackupDir); err != nil {
 		return nil, fmt.Errorf("failed to set backup directory: %w", err)
 	}
+	for i, mirror := range cfg.Mirrors {
+		abs, err := filepath.Abs(mirror)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path for mirror %s: %w", mirror, err)
+		}
+		cfg.Mirrors[i] = abs
+	}
+	if cfg.ColdDir != "" {
+		abs, err := filepath.Abs(cfg.ColdDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path for cold directory: %w", err)
+		}
+		cfg.ColdDir = abs
+	}
+
+	// Read the pattern files once, so edits apply from the next load on
+	patternFiles, err := filter.ReadPatternFiles(cfg.Include, cfg.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	cfg.PatternFiles = patternFiles
 
 	// Validate configuration
 	if err := cfg.validate(); err != nil {
@@ -82,25 +736,296 @@ func Load(opts Options) (*Config, error) {
 	return cfg, nil
 }
 
+// loadFromEnv applies the environment variables that are set, overriding
+// the config file
 func (c *Config) loadFromEnv() error {
 	// Dropbox OAuth2 credentials
-	c.ClientID = os.Getenv("DROPBOX_CLIENT_ID")
-	c.ClientSecret = os.Getenv("DROPBOX_CLIENT_SECRET")
-	c.AccessToken = os.Getenv("DROPBOX_ACCESS_TOKEN")
-	c.RefreshToken = os.Getenv("DROPBOX_REFRESH_TOKEN")
+	envString("DROPBOX_CLIENT_ID", &c.ClientID)
+	envString("DROPBOX_CLIENT_SECRET", &c.ClientSecret)
+	envString("DROPBOX_ACCESS_TOKEN", &c.AccessToken)
+	envString("DROPBOX_REFRESH_TOKEN", &c.RefreshToken)
+	envString("DROPBOX_TOKEN_STORE", &c.TokenStore)
+	envString("DROPBOX_BACKUP_FOLDER", &c.BackupDir)
+	envString("DROPBOX_API_URL", &c.APIURL)
+	envString("DROPBOX_CONTENT_URL", &c.ContentURL)
+	envString("DROPBOX_NOTIFY_URL", &c.NotifyURL)
+	envString("DROPBOX_AUTH_URL", &c.AuthURL)
+
+	// Transfer settings
+	envString("DROPBOX_BWLIMIT", &c.BandwidthLimit)
+	envString("DROPBOX_BWLIMIT_SCHEDULE", &c.BandwidthSchedule)
+	envFlag("DROPBOX_PAUSE_ON_METERED", &c.PauseOnMetered)
+	envString("DROPBOX_REQUIRE_INTERFACE", &c.RequireInterface)
+	envString("DROPBOX_WRITE_BUFFER", &c.WriteBuffer)
+	envFlag("DROPBOX_SERIALIZE_WRITES", &c.SerializeWrites)
+	envString("DROPBOX_LARGE_FILE_THRESHOLD", &c.LargeFileThreshold)
+	if value := os.Getenv("DROPBOX_LARGE_FILE_CONCURRENCY"); value != "" {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DROPBOX_LARGE_FILE_CONCURRENCY: %w", err)
+		}
+		c.LargeFileConcurrency = concurrency
+	}
+	envString("DROPBOX_MAX_FILE_SIZE", &c.MaxFileSize)
+	if onOversize := os.Getenv("DROPBOX_ON_OVERSIZE"); onOversize != "" {
+		c.OnOversize = onOversize
+	}
+	envString("DROPBOX_MAX_MEMORY", &c.MaxMemory)
+	if value := os.Getenv("DROPBOX_HASH_WORKERS"); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DROPBOX_HASH_WORKERS: %w", err)
+		}
+		c.HashWorkers = workers
+	}
+	if err := envDuration("DROPBOX_PROGRESS_INTERVAL", &c.ProgressInterval); err != nil {
+		return err
+	}
+	if err := envDuration("DROPBOX_LOG_BATCH", &c.LogBatch); err != nil {
+		return err
+	}
+	envString("DROPBOX_HARDWARE_PROFILE", &c.HardwareProfile)
+	envFlag("DROPBOX_HIDE_DOTFILES", &c.HideDotFiles)
+	envFlag("DROPBOX_TAG_XATTR", &c.TagXattr)
+	envFlag("DROPBOX_SIDECAR", &c.Sidecar)
+	envFlag("DROPBOX_READ_ONLY", &c.ReadOnly)
+	if paths := os.Getenv("DROPBOX_REMOTE_PATHS"); paths != "" {
+		c.RemotePaths = strings.Split(paths, ",")
+	}
+	if mirrors := os.Getenv("DROPBOX_MIRRORS"); mirrors != "" {
+		c.Mirrors = nil
+		for _, mirror := range strings.Split(mirrors, ",") {
+			if mirror = strings.TrimSpace(mirror); mirror != "" {
+				c.Mirrors = append(c.Mirrors, mirror)
+			}
+		}
+	}
+	envString("DROPBOX_POST_RUN_COMMAND", &c.PostRunCommand)
+	envString("DROPBOX_COLD_DIR", &c.ColdDir)
+	if value := os.Getenv("DROPBOX_COLD_AFTER_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DROPBOX_COLD_AFTER_DAYS: %w", err)
+		}
+		c.ColdAfterDays = days
+	}
+	envFlag("DROPBOX_TERMINAL_TITLE", &c.TerminalTitle)
+	envFlag("DROPBOX_NOTIFY", &c.Notify)
+	envFlag("DROPBOX_NO_PROGRESS", &c.NoProgress)
+	envString("DROPBOX_CHAOS", &c.Chaos)
+	envString("DROPBOX_ALERT_WEBHOOK", &c.AlertWebhook)
+	if value := os.Getenv("DROPBOX_ALERT_FAILURES"); value != "" {
+		paths, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DROPBOX_ALERT_FAILURES: %w", err)
+		}
+		c.AlertFailures = paths
+	}
+	envString("DROPBOX_REPORT_DIR", &c.ReportDir)
+	envString("DROPBOX_REPORT_URL", &c.ReportURL)
+	if err := envDuration("DROPBOX_API_TIMEOUT", &c.APITimeout); err != nil {
+		return err
+	}
+	if err := envDuration("DROPBOX_FILE_TIMEOUT", &c.FileTimeout); err != nil {
+		return err
+	}
+	if err := envDuration("DROPBOX_STALL_TIMEOUT", &c.StallTimeout); err != nil {
+		return err
+	}
+	if err := envDuration("DROPBOX_MAX_CLOCK_SKEW", &c.MaxClockSkew); err != nil {
+		return err
+	}
+	if err := c.loadRetryFromEnv(); err != nil {
+		return err
+	}
+	if compare := os.Getenv("DROPBOX_COMPARE"); compare != "" {
+		c.Compare = compare
+	}
+	if formats := os.Getenv("DROPBOX_EXPORT_FORMATS"); formats != "" {
+		parsed, err := ParseExportFormats(strings.Split(formats, ","))
+		if err != nil {
+			return err
+		}
+		c.ExportFormats = parsed
+	}
+
+	// Application settings
+	envString("DROPBOX_METRICS_FILE", &c.MetricsFile)
+
+	// Backup settings
+	envFlag("DROPBOX_TEAM_SPACE", &c.TeamSpace)
+	envFlag("DROPBOX_ACCOUNT_METADATA", &c.AccountMetadata)
+	envFlag("DROPBOX_ARCHIVE", &c.Archive)
+	envString("DROPBOX_SIGN_KEY", &c.SignKey)
+	envFlag("DROPBOX_VERIFY_AFTER", &c.VerifyAfter)
+	if value := os.Getenv("DROPBOX_SPOT_CHECK"); value != "" {
+		files, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DROPBOX_SPOT_CHECK: %w", err)
+		}
+		c.SpotCheck = files
+	}
+	if value := os.Getenv("DROPBOX_API_CALL_BUDGET"); value != "" {
+		calls, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DROPBOX_API_CALL_BUDGET: %w", err)
+		}
+		c.APICallBudget = calls
+	}
+	envFlag("DROPBOX_VERIFY", &c.Verify)
+	envFlag("DROPBOX_RCLONE_SUM", &c.RcloneSum)
+	envFlag("DROPBOX_SEARCH_EXTENSIONS", &c.SearchExtensions)
+	envFlag("DROPBOX_FULL_LISTING", &c.FullListing)
+	envString("DROPBOX_PRESET", &c.Preset)
+	if layout := os.Getenv("DROPBOX_LAYOUT"); layout != "" {
+		c.Layout = layout
+	}
+
+	// Coordination between instances
+	envString("DROPBOX_STATE_BACKEND", &c.StateBackend)
+	envString("DROPBOX_INSTANCE_ID", &c.InstanceID)
+
+	return nil
+}
+
+// Filter returns the include/exclude patterns as a path filter
+func (c *Config) Filter() filter.Filter {
+	return filter.Filter{Include: c.Include, Exclude: c.Exclude, Files: c.PatternFiles}
+}
+
+// ParseExportFormats parses "extension=format" pairs such as "paper=markdown".
+// Extensions are matched case-insensitively and may include a leading dot.
+func ParseExportFormats(pairs []string) (map[string]string, error) {
+	formats := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		ext, format, ok := strings.Cut(pair, "=")
+		ext = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+		format = strings.TrimSpace(format)
+		if !ok || ext == "" || format == "" {
+			return nil, fmt.Errorf("invalid export format %q (expected extension=format, e.g. paper=markdown)", pair)
+		}
+		formats[ext] = format
+	}
+	return formats, nil
+}
 
+// envBool reports whether an environment variable is set to a true value
+// envString sets s to the value of an environment variable if set
+func envString(key string, s *string) {
+	if value := os.Getenv(key); value != "" {
+		*s = value
+	}
+}
+
+// envFlag sets b from an environment variable if set, see envBool
+func envFlag(key string, b *bool) {
+	if os.Getenv(key) != "" {
+		*b = envBool(key)
+	}
+}
+
+func envBool(key string) bool {
+	switch strings.ToLower(os.Getenv(key)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// loadRetryFromEnv overrides parts of the retry policy from DROPBOX_RETRY_*
+// variables
+func (c *Config) loadRetryFromEnv() error {
+	if value := os.Getenv("DROPBOX_RETRY_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DROPBOX_RETRY_ATTEMPTS: %w", err)
+		}
+		c.Retry.MaxAttempts = attempts
+	}
+	if err := envDuration("DROPBOX_RETRY_BASE_DELAY", &c.Retry.BaseDelay); err != nil {
+		return err
+	}
+	if err := envDuration("DROPBOX_RETRY_MAX_DELAY", &c.Retry.MaxDelay); err != nil {
+		return err
+	}
+	for key, target := range map[string]*float64{
+		"DROPBOX_RETRY_MULTIPLIER": &c.Retry.Multiplier,
+		"DROPBOX_RETRY_JITTER":     &c.Retry.Jitter,
+	} {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*target = parsed
+		}
+	}
+	if classes := os.Getenv("DROPBOX_RETRY_ON"); classes != "" {
+		c.Retry.RetryOn = nil
+		for _, class := range strings.Split(classes, ",") {
+			if class = strings.TrimSpace(class); class != "" {
+				c.Retry.RetryOn = append(c.Retry.RetryOn, class)
+			}
+		}
+	}
+	return nil
+}
+
+// envDuration sets *d from an environment variable holding a duration
+// (e.g. "90s"), leaving it unchanged if the variable is unset
+func envDuration(key string, d *time.Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*d = parsed
 	return nil
 }
 
+// normalizeRemotePaths returns Dropbox folder paths in the form listing
+// expects: with a leading and without a trailing slash. The root selects the
+// whole account, as does no path at all.
+func normalizeRemotePaths(paths []string) []string {
+	var normalized []string
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if path = strings.Trim(path, "/"); path == "" {
+			return nil
+		}
+		normalized = append(normalized, "/"+path)
+	}
+	return normalized
+}
+
+func (c *Config) applySettings(settings *Settings) {
+	profile := settings.Profile(c.Profile)
+	if len(profile.RemotePaths) > 0 {
+		c.RemotePaths = profile.RemotePaths
+	}
+	c.HistoricalThroughput = profile.Throughput
+	c.APIUsage = settings.APIUsage[c.ClientID]
+}
+
 func (c *Config) setBackupDir(backupDir string) error {
-	// Priority: command-line flag > environment variable > default
+	// Priority: command-line flag > profile > environment variable > config
+	// file (see loadFromEnv) > default
 	if backupDir != "" {
 		c.BackupDir = backupDir
-	} else if envDir := os.Getenv("DROPBOX_BACKUP_FOLDER"); envDir != "" {
-		c.BackupDir = envDir
-	} else {
+	} else if c.BackupDir == "" {
 		// Create default backup folder with timestamp
-		timestamp := time.Now().Format("2006-01-02-15-04-05")
+		timestamp := now().Format("2006-01-02-15-04-05")
 		c.BackupDir = fmt.Sprintf("./dropbox_backup_%s", timestamp)
 	}
 
@@ -111,11 +1036,137 @@ func (c *Config) setBackupDir(backupDir string) error {
 	}
 	c.BackupDir = absPath
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(c.BackupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+	// The directory itself is created by the backup engine when it starts
+	// writing, so read-only commands never touch the filesystem.
+	return nil
+}
+
+// Endpoints returns the Dropbox endpoint overrides
+func (c *Config) Endpoints() dropbox.Endpoints {
+	return dropbox.Endpoints{API: c.APIURL, Content: c.ContentURL, Notify: c.NotifyURL, Auth: c.AuthURL}
+}
+
+// Check parses the settings that are otherwise only parsed when a backup
+// starts, e.g. sizes and the bandwidth schedule
+func (c *Config) Check() error {
+	if _, err := c.Bandwidth(); err != nil {
+		return err
+	}
+	if _, err := c.WriteBufferSize(); err != nil {
+		return err
+	}
+	if _, err := c.MaxFileSizeBytes(); err != nil {
+		return err
+	}
+	if _, err := c.LargeFileThresholdSize(); err != nil {
+		return err
+	}
+	if _, err := c.ChaosSettings(); err != nil {
+		return err
+	}
+	_, err := c.MemoryPlan()
+	return err
+}
+
+// Bandwidth returns the parsed bandwidth schedule combining the flat limit
+// with any time-of-day windows
+func (c *Config) Bandwidth() (throttle.Schedule, error) {
+	schedule, err := throttle.ParseSchedule(c.BandwidthLimit, c.BandwidthSchedule)
+	if err != nil {
+		return throttle.Schedule{}, fmt.Errorf("invalid bandwidth settings: %w", err)
+	}
+	return schedule, nil
+}
+
+// WriteBufferSize returns the parsed write buffer size in bytes, 0 for the
+// default
+func (c *Config) WriteBufferSize() (int, error) {
+	size, err := throttle.ParseRate(c.WriteBuffer)
+	if err != nil || size > maxWriteBuffer {
+		return 0, fmt.Errorf("invalid write buffer %q (e.g. 256K or 4M, at most 1G)", c.WriteBuffer)
+	}
+	return int(size), nil
+}
+
+// MaxFileSizeBytes returns the parsed maximum file size in bytes, 0 to
+// detect it from the backup file system
+func (c *Config) MaxFileSizeBytes() (uint64, error) {
+	size, err := throttle.ParseRate(c.MaxFileSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max file size %q (e.g. 4G)", c.MaxFileSize)
+	}
+	return uint64(size), nil
+}
+
+// LargeFileThresholdSize returns the parsed large file threshold in bytes,
+// 0 if large files share the lane of all others
+func (c *Config) LargeFileThresholdSize() (uint64, error) {
+	size, err := throttle.ParseRate(c.LargeFileThreshold)
+	if err != nil {
+		return 0, fmt.Errorf("invalid large file threshold %q (e.g. 512M or 2G)", c.LargeFileThreshold)
+	}
+	return uint64(size), nil
+}
+
+// ChaosSettings returns the parsed failure injection settings, which inject
+// nothing unless chaos mode is set
+func (c *Config) ChaosSettings() (dropbox.Chaos, error) {
+	return dropbox.ParseChaos(c.Chaos)
+}
+
+// NeedsAccountInfo reports whether the backup directory references placeholders
+// that can only be resolved after authenticating with Dropbox
+func (c *Config) NeedsAccountInfo() bool {
+	return strings.Contains(c.BackupDir, PlaceholderAccountEmail)
+}
+
+// ExpandBackupDir replaces placeholders in BackupDir and Mirrors with run-time
+// values
+func (c *Config) ExpandBackupDir(values PathValues) {
+	c.BackupDir = expandPlaceholders(c.BackupDir, values)
+	for i, mirror := range c.Mirrors {
+		c.Mirrors[i] = expandPlaceholders(mirror, values)
+	}
+}
+
+func expandPlaceholders(path string, values PathValues) string {
+	if !strings.Contains(path, "{") {
+		return path
+	}
+
+	if values.Now.IsZero() {
+		values.Now = now()
+	}
+	if values.Profile == "" {
+		values.Profile = DefaultProfile
+	}
+
+	replacer := strings.NewReplacer(
+		PlaceholderDate, values.Now.Format("2006-01-02"),
+		PlaceholderTime, values.Now.Format("15-04-05"),
+		PlaceholderAccountEmail, sanitizePathElement(values.AccountEmail),
+		PlaceholderProfile, sanitizePathElement(values.Profile),
+	)
+	return replacer.Replace(path)
+}
+
+// sanitizePathElement keeps substituted values from introducing new path levels
+func sanitizePathElement(value string) string {
+	value = strings.NewReplacer("/", "_", "\\", "_").Replace(value)
+	if value == "" || value == "." || value == ".." {
+		return "unknown"
 	}
+	return value
+}
 
+// validateHTTPURL checks that a setting, if set, is an http or https URL
+func validateHTTPURL(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s: %s (must be an http or https URL)", name, value)
+	}
 	return nil
 }
 
@@ -129,6 +1180,19 @@ func (c *Config) validate() error {
 	if c.BackupDir == "" {
 		return fmt.Errorf("backup directory is required")
 	}
+	for _, endpoint := range []string{c.APIURL, c.ContentURL, c.NotifyURL, c.AuthURL} {
+		if endpoint == "" {
+			continue
+		}
+		if err := dropbox.ValidateEndpoint(endpoint); err != nil {
+			return err
+		}
+	}
+	for _, mirror := range c.Mirrors {
+		if isWithin(mirror, c.BackupDir) || isWithin(c.BackupDir, mirror) {
+			return fmt.Errorf("invalid mirror: %s overlaps the backup directory %s", mirror, c.BackupDir)
+		}
+	}
 
 	// Validate log level
 	validLevels := map[string]bool{
@@ -141,5 +1205,116 @@ func (c *Config) validate() error {
 		return fmt.Errorf("invalid log level: %s (must be debug, info, warn, or error)", c.LogLevel)
 	}
 
+	if _, err := c.Bandwidth(); err != nil {
+		return err
+	}
+	if _, err := c.WriteBufferSize(); err != nil {
+		return err
+	}
+	if _, err := c.LargeFileThresholdSize(); err != nil {
+		return err
+	}
+	if c.LargeFileConcurrency < 1 && c.LargeFileThreshold != "" {
+		return fmt.Errorf("invalid large file concurrency: %d (must be at least 1)", c.LargeFileConcurrency)
+	}
+	if _, err := c.MaxFileSizeBytes(); err != nil {
+		return err
+	}
+	if _, err := c.MemoryPlan(); err != nil {
+		return err
+	}
+	if c.HashWorkers < 0 {
+		return fmt.Errorf("invalid hash workers: %d (must not be negative)", c.HashWorkers)
+	}
+	switch c.OnOversize {
+	case "", OversizeSkip, OversizeFail, OversizeDownload:
+	default:
+		return fmt.Errorf("invalid oversize handling: %s (must be %s, %s, or %s)",
+			c.OnOversize, OversizeSkip, OversizeFail, OversizeDownload)
+	}
+
+	switch c.Compare {
+	case "", CompareMtimeSize, CompareHash, CompareRev:
+	default:
+		return fmt.Errorf("invalid compare mode: %s (must be %s, %s, or %s)",
+			c.Compare, CompareMtimeSize, CompareHash, CompareRev)
+	}
+
+	if err := c.Retry.Validate(); err != nil {
+		return err
+	}
+
+	if c.LogBatch < 0 {
+		return fmt.Errorf("invalid log batch interval: %s (must not be negative)", c.LogBatch)
+	}
+	if c.APITimeout < 0 {
+		return fmt.Errorf("invalid API timeout: %s (must not be negative)", c.APITimeout)
+	}
+	if c.FileTimeout < 0 {
+		return fmt.Errorf("invalid file timeout: %s (must not be negative)", c.FileTimeout)
+	}
+	if c.StallTimeout < 0 {
+		return fmt.Errorf("invalid stall timeout: %s (must not be negative)", c.StallTimeout)
+	}
+	if c.MaxClockSkew < 0 {
+		return fmt.Errorf("invalid max clock skew: %s (must not be negative)", c.MaxClockSkew)
+	}
+
+	if c.Archive && c.Delete {
+		return fmt.Errorf("--delete can't be used in archive mode")
+	}
+	if (c.ColdDir == "") != (c.ColdAfterDays == 0) {
+		return fmt.Errorf("--cold-dir and --cold-after-days must be used together")
+	}
+	if c.SpotCheck < 0 {
+		return fmt.Errorf("invalid spot check: %d files (must not be negative)", c.SpotCheck)
+	}
+	if c.AlertFailures < 0 {
+		return fmt.Errorf("invalid alert failures: %d paths (must not be negative)", c.AlertFailures)
+	}
+	if err := validateHTTPURL("alert webhook", c.AlertWebhook); err != nil {
+		return err
+	}
+	if err := validateHTTPURL("report URL", c.ReportURL); err != nil {
+		return err
+	}
+	if c.APICallBudget < 0 {
+		return fmt.Errorf("invalid API call budget: %d (must not be negative)", c.APICallBudget)
+	}
+	if c.ColdAfterDays < 0 {
+		return fmt.Errorf("invalid cold after days: %d (must not be negative)", c.ColdAfterDays)
+	}
+	if c.ColdDir != "" {
+		if isWithin(c.ColdDir, c.BackupDir) || isWithin(c.BackupDir, c.ColdDir) {
+			return fmt.Errorf("invalid cold directory: %s overlaps the backup directory %s", c.ColdDir, c.BackupDir)
+		}
+		if c.Archive {
+			// Sealed files stay where the archive chain recorded them
+			return fmt.Errorf("--cold-dir can't be used in archive mode")
+		}
+	}
+	if c.SearchExtensions && c.Delete {
+		// Files missing from a lagging search index would be deleted locally
+		return fmt.Errorf("--delete can't be used with --search-extensions")
+	}
+
+	switch c.TokenStore {
+	case "", tokenstore.ModeAuto, tokenstore.ModeKeychain, tokenstore.ModeFile:
+	default:
+		return fmt.Errorf("invalid token store: %s (must be one of %v)", c.TokenStore, tokenstore.Modes)
+	}
+
+	switch c.Layout {
+	case "", LayoutMounted, LayoutShared:
+	default:
+		return fmt.Errorf("invalid layout: %s (must be %s or %s)", c.Layout, LayoutMounted, LayoutShared)
+	}
+
 	return nil
 }
+
+// isWithin reports whether a local path is a directory or below it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
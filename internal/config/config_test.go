@@ -3,9 +3,12 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"create-dropbox-backup-folder/internal/retry"
 )
 
 func TestLoad(t *testing.T) {
@@ -43,8 +46,7 @@ func TestLoad(t *testing.T) {
 				ClientSecret:   "test_client_secret",
 				LogLevel:       "error",
 				MaxConcurrency: 5,
-				RetryAttempts:  3,
-				RetryDelay:     time.Second * 2,
+				Retry:          retry.DefaultPolicy(),
 			},
 		},
 		{
@@ -70,8 +72,7 @@ func TestLoad(t *testing.T) {
 				ShowCount:      true,
 				ShowSize:       true,
 				MaxConcurrency: 5,
-				RetryAttempts:  3,
-				RetryDelay:     time.Second * 2,
+				Retry:          retry.DefaultPolicy(),
 			},
 		},
 		{
@@ -91,8 +92,7 @@ func TestLoad(t *testing.T) {
 				RefreshToken:   "test_refresh_token",
 				LogLevel:       "error",
 				MaxConcurrency: 5,
-				RetryAttempts:  3,
-				RetryDelay:     time.Second * 2,
+				Retry:          retry.DefaultPolicy(),
 			},
 		},
 		{
@@ -111,8 +111,7 @@ func TestLoad(t *testing.T) {
 				ClientSecret:   "test_client_secret",
 				LogLevel:       "info",
 				MaxConcurrency: 5,
-				RetryAttempts:  3,
-				RetryDelay:     time.Second * 2,
+				Retry:          retry.DefaultPolicy(),
 			},
 		},
 		{
@@ -172,11 +171,8 @@ func TestLoad(t *testing.T) {
 				if got.MaxConcurrency != tt.want.MaxConcurrency {
 					t.Errorf("Load() MaxConcurrency = %v, want %v", got.MaxConcurrency, tt.want.MaxConcurrency)
 				}
-				if got.RetryAttempts != tt.want.RetryAttempts {
-					t.Errorf("Load() RetryAttempts = %v, want %v", got.RetryAttempts, tt.want.RetryAttempts)
-				}
-				if got.RetryDelay != tt.want.RetryDelay {
-					t.Errorf("Load() RetryDelay = %v, want %v", got.RetryDelay, tt.want.RetryDelay)
+				if !reflect.DeepEqual(got.Retry, tt.want.Retry) {
+					t.Errorf("Load() Retry = %+v, want %+v", got.Retry, tt.want.Retry)
 				}
 				// Only check BackupDir if we set one specifically and it's not converted to absolute
 				if tt.opts.BackupDir != "" {
@@ -240,6 +236,33 @@ func TestSetBackupDir(t *testing.T) {
 	}
 }
 
+// fixClock makes the package clock return at for the rest of the test
+func fixClock(t *testing.T, at time.Time) {
+	t.Helper()
+	original := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = original })
+}
+
+func TestDefaultBackupDirUsesClock(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_FOLDER", "")
+	fixClock(t, time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local))
+
+	cfg := &Config{}
+	if err := cfg.setBackupDir(""); err != nil {
+		t.Fatal(err)
+	}
+	if want := "dropbox_backup_2024-05-06-07-08-09"; filepath.Base(cfg.BackupDir) != want {
+		t.Errorf("BackupDir = %s, want a directory named %s", cfg.BackupDir, want)
+	}
+
+	cfg.BackupDir = "/backups/{date}"
+	cfg.ExpandBackupDir(PathValues{})
+	if cfg.BackupDir != "/backups/2024-05-06" {
+		t.Errorf("expanded BackupDir = %s, want /backups/2024-05-06", cfg.BackupDir)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -293,6 +316,321 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid bandwidth schedule",
+			config: &Config{
+				ClientID:          "test_client_id",
+				ClientSecret:      "test_client_secret",
+				BackupDir:         "/valid/path",
+				LogLevel:          "error",
+				BandwidthLimit:    "10M",
+				BandwidthSchedule: "09:00-18:00=2M",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid bandwidth schedule",
+			config: &Config{
+				ClientID:          "test_client_id",
+				ClientSecret:      "test_client_secret",
+				BackupDir:         "/valid/path",
+				LogLevel:          "error",
+				BandwidthSchedule: "daytime=2M",
+			},
+			wantErr: true,
+		},
+		{
+			name: "hash compare mode",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				Compare:      CompareHash,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid compare mode",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				Compare:      "checksum",
+			},
+			wantErr: true,
+		},
+		{
+			name: "shared layout",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				Layout:       LayoutShared,
+			},
+			wantErr: false,
+		},
+		{
+			name: "delete in archive mode",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				Archive:      true,
+				Delete:       true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "delete with search",
+			config: &Config{
+				ClientID:         "test_client_id",
+				ClientSecret:     "test_client_secret",
+				BackupDir:        "/valid/path",
+				LogLevel:         "error",
+				SearchExtensions: true,
+				Delete:           true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid oversize handling",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				OnOversize:   "truncate",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid max file size",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				MaxFileSize:  "4 gigs",
+			},
+			wantErr: true,
+		},
+		{
+			name: "memory budget too small",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				MaxMemory:    "8M",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid large file threshold",
+			config: &Config{
+				ClientID:           "test_client_id",
+				ClientSecret:       "test_client_secret",
+				BackupDir:          "/valid/path",
+				LogLevel:           "error",
+				LargeFileThreshold: "huge",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid write buffer",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				WriteBuffer:  "lots",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative log batch interval",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				LogBatch:     -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative API timeout",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				APITimeout:   -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid layout",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				Layout:       "owner",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid mirror",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				Mirrors:      []string{"/mnt/nas/backup"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "mirror inside backup directory",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				Mirrors:      []string{"/valid/path/copy"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid cold directory",
+			config: &Config{
+				ClientID:      "test_client_id",
+				ClientSecret:  "test_client_secret",
+				BackupDir:     "/valid/path",
+				LogLevel:      "error",
+				ColdDir:       "/mnt/archive/dropbox",
+				ColdAfterDays: 730,
+			},
+			wantErr: false,
+		},
+		{
+			name: "cold directory without age",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				ColdDir:      "/mnt/archive/dropbox",
+			},
+			wantErr: true,
+		},
+		{
+			name: "cold directory inside backup directory",
+			config: &Config{
+				ClientID:      "test_client_id",
+				ClientSecret:  "test_client_secret",
+				BackupDir:     "/valid/path",
+				LogLevel:      "error",
+				ColdDir:       "/valid/path/cold",
+				ColdAfterDays: 730,
+			},
+			wantErr: true,
+		},
+		{
+			name: "mock API endpoint",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				APIURL:       "http://localhost:8081",
+				ContentURL:   "http://localhost:8081",
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative max clock skew",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				MaxClockSkew: -time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative API call budget",
+			config: &Config{
+				ClientID:      "test_client_id",
+				ClientSecret:  "test_client_secret",
+				BackupDir:     "/valid/path",
+				LogLevel:      "error",
+				APICallBudget: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid token store",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				TokenStore:   "vault",
+			},
+			wantErr: true,
+		},
+		{
+			name: "endpoint without scheme",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				NotifyURL:    "notify.example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "alert webhook and report URL",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				AlertWebhook: "https://hooks.example.com/backup",
+				ReportURL:    "http://nas.local/reports/",
+			},
+			wantErr: false,
+		},
+		{
+			name: "alert webhook without scheme",
+			config: &Config{
+				ClientID:     "test_client_id",
+				ClientSecret: "test_client_secret",
+				BackupDir:    "/valid/path",
+				LogLevel:     "error",
+				AlertWebhook: "hooks.example.com/backup",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative alert failures",
+			config: &Config{
+				ClientID:      "test_client_id",
+				ClientSecret:  "test_client_secret",
+				BackupDir:     "/valid/path",
+				LogLevel:      "error",
+				AlertFailures: -1,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -304,3 +642,346 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadDoesNotCreateBackupDir(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+
+	backupDir := filepath.Join(t.TempDir(), "not-yet-created")
+
+	cfg, err := Load(Options{BackupDir: backupDir})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.BackupDir != backupDir {
+		t.Errorf("Load() BackupDir = %v, want %v", cfg.BackupDir, backupDir)
+	}
+	if _, err := os.Stat(backupDir); !os.IsNotExist(err) {
+		t.Errorf("Load() should not create the backup directory, stat error = %v", err)
+	}
+}
+
+func TestLoadPatternFiles(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	dir := t.TempDir()
+	file := filepath.Join(dir, ".backupignore")
+	if err := os.WriteFile(file, []byte("*.iso\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(Options{BackupDir: dir, Exclude: []string{"*.tmp", "@" + file}})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Filter().Allows("/images/disk.iso") {
+		t.Error("Filter() allows a path excluded by the pattern file")
+	}
+
+	if _, err := Load(Options{BackupDir: dir, Exclude: []string{"@" + filepath.Join(dir, "missing")}}); err == nil {
+		t.Error("Load() with a missing pattern file error = nil, want error")
+	}
+}
+
+func TestLoadInstanceID(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_STATE_BACKEND", "/mnt/share/state")
+	t.Setenv("DROPBOX_INSTANCE_ID", "")
+
+	hostname, _ := os.Hostname()
+	tests := []struct {
+		name string
+		env  string
+		opt  string
+		want string
+	}{
+		{name: "hostname by default", want: hostname},
+		{name: "environment", env: "nas", want: "nas"},
+		{name: "option overrides environment", env: "nas", opt: "offsite", want: "offsite"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DROPBOX_INSTANCE_ID", tt.env)
+			cfg, err := Load(Options{BackupDir: t.TempDir(), InstanceID: tt.opt})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.InstanceID != tt.want {
+				t.Errorf("Load() InstanceID = %q, want %q", cfg.InstanceID, tt.want)
+			}
+			if cfg.StateBackend != "/mnt/share/state" {
+				t.Errorf("Load() StateBackend = %q, want /mnt/share/state", cfg.StateBackend)
+			}
+		})
+	}
+}
+
+func TestLoadRemotePaths(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+
+	tests := []struct {
+		name string
+		env  string
+		opt  []string
+		want []string
+	}{
+		{name: "whole account by default"},
+		{name: "environment", env: "/Photos, Documents/ ,", want: []string{"/Photos", "/Documents"}},
+		{name: "option overrides environment", env: "/Photos", opt: []string{"/Work/Clients/"}, want: []string{"/Work/Clients"}},
+		{name: "root selects everything", opt: []string{"/Photos", "/"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DROPBOX_REMOTE_PATHS", tt.env)
+			cfg, err := Load(Options{BackupDir: t.TempDir(), RemotePaths: tt.opt})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(cfg.RemotePaths, tt.want) {
+				t.Errorf("Load() RemotePaths = %q, want %q", cfg.RemotePaths, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTimeouts(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+
+	fileTimeout := 3 * time.Hour
+	tests := []struct {
+		name     string
+		env      map[string]string
+		opts     Options
+		wantAPI  time.Duration
+		wantFile time.Duration
+		// wantStall defaults to 5m
+		wantStall time.Duration
+		wantErr   bool
+	}{
+		{name: "defaults", wantAPI: time.Minute},
+		{
+			name:      "environment",
+			env:       map[string]string{"DROPBOX_API_TIMEOUT": "20s", "DROPBOX_FILE_TIMEOUT": "2h", "DROPBOX_STALL_TIMEOUT": "90s"},
+			wantAPI:   20 * time.Second,
+			wantFile:  2 * time.Hour,
+			wantStall: 90 * time.Second,
+		},
+		{
+			name:     "option overrides environment",
+			env:      map[string]string{"DROPBOX_FILE_TIMEOUT": "2h"},
+			opts:     Options{FileTimeout: &fileTimeout},
+			wantAPI:  time.Minute,
+			wantFile: 3 * time.Hour,
+		},
+		{
+			name:    "invalid environment",
+			env:     map[string]string{"DROPBOX_API_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DROPBOX_API_TIMEOUT", "")
+			t.Setenv("DROPBOX_FILE_TIMEOUT", "")
+			t.Setenv("DROPBOX_STALL_TIMEOUT", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			opts := tt.opts
+			opts.BackupDir = t.TempDir()
+			cfg, err := Load(opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if cfg.APITimeout != tt.wantAPI || cfg.FileTimeout != tt.wantFile {
+				t.Errorf("Load() timeouts = %s, %s, want %s, %s", cfg.APITimeout, cfg.FileTimeout, tt.wantAPI, tt.wantFile)
+			}
+			wantStall := tt.wantStall
+			if wantStall == 0 {
+				wantStall = 5 * time.Minute
+			}
+			if cfg.StallTimeout != wantStall {
+				t.Errorf("Load() StallTimeout = %s, want %s", cfg.StallTimeout, wantStall)
+			}
+		})
+	}
+}
+
+func TestLoadRetryPolicy(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_RETRY_ATTEMPTS", "5")
+	t.Setenv("DROPBOX_RETRY_BASE_DELAY", "500ms")
+	t.Setenv("DROPBOX_RETRY_MAX_DELAY", "10s")
+	t.Setenv("DROPBOX_RETRY_MULTIPLIER", "3")
+	t.Setenv("DROPBOX_RETRY_JITTER", "0")
+	t.Setenv("DROPBOX_RETRY_ON", "rate_limit, server")
+
+	cfg, err := Load(Options{BackupDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := retry.Policy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Multiplier:  3,
+		RetryOn:     []string{retry.RateLimit, retry.Server},
+	}
+	if !reflect.DeepEqual(cfg.Retry, want) {
+		t.Errorf("Load() Retry = %+v, want %+v", cfg.Retry, want)
+	}
+
+	t.Setenv("DROPBOX_RETRY_ON", "auth")
+	if _, err := Load(Options{BackupDir: t.TempDir()}); err == nil {
+		t.Error("Load() expected error for unknown retry error class")
+	}
+}
+
+func TestExpandBackupDir(t *testing.T) {
+	now := time.Date(2024, 3, 9, 14, 5, 6, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		backupDir string
+		values    PathValues
+		want      string
+	}{
+		{
+			name:      "no placeholders",
+			backupDir: "/backups/dropbox",
+			values:    PathValues{Now: now},
+			want:      "/backups/dropbox",
+		},
+		{
+			name:      "date and time",
+			backupDir: "/backups/{date}/{time}",
+			values:    PathValues{Now: now},
+			want:      "/backups/2024-03-09/14-05-06",
+		},
+		{
+			name:      "account email and profile",
+			backupDir: "/backups/{account_email}/{profile}",
+			values:    PathValues{Now: now, AccountEmail: "user@example.com", Profile: "work"},
+			want:      "/backups/user@example.com/work",
+		},
+		{
+			name:      "default profile",
+			backupDir: "/backups/{profile}",
+			values:    PathValues{Now: now},
+			want:      "/backups/default",
+		},
+		{
+			name:      "values cannot add path levels",
+			backupDir: "/backups/{account_email}",
+			values:    PathValues{Now: now, AccountEmail: "../etc"},
+			want:      "/backups/.._etc",
+		},
+		{
+			name:      "missing account email",
+			backupDir: "/backups/{account_email}",
+			values:    PathValues{Now: now},
+			want:      "/backups/unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{BackupDir: tt.backupDir}
+			cfg.ExpandBackupDir(tt.values)
+			if cfg.BackupDir != tt.want {
+				t.Errorf("ExpandBackupDir() BackupDir = %v, want %v", cfg.BackupDir, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsAccountInfo(t *testing.T) {
+	if (&Config{BackupDir: "/backups/{date}"}).NeedsAccountInfo() {
+		t.Error("NeedsAccountInfo() = true for path without account placeholder")
+	}
+	if !(&Config{BackupDir: "/backups/{account_email}"}).NeedsAccountInfo() {
+		t.Error("NeedsAccountInfo() = false for path with account placeholder")
+	}
+}
+
+func TestParseExportFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		pairs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "multiple pairs",
+			pairs: []string{"paper=markdown", " .GSHEET = xlsx "},
+			want:  map[string]string{"paper": "markdown", "gsheet": "xlsx"},
+		},
+		{
+			name:  "blank entries ignored",
+			pairs: []string{"", "paper=html"},
+			want:  map[string]string{"paper": "html"},
+		},
+		{name: "missing format", pairs: []string{"paper="}, wantErr: true},
+		{name: "missing separator", pairs: []string{"paper"}, wantErr: true},
+		{name: "missing extension", pairs: []string{"=markdown"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExportFormats(tt.pairs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExportFormats() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseExportFormats() = %v, want %v", got, tt.want)
+			}
+			for ext, format := range tt.want {
+				if got[ext] != format {
+					t.Errorf("ParseExportFormats()[%q] = %q, want %q", ext, got[ext], format)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "defaults", config: Config{MaxConcurrency: 5}},
+		{name: "invalid bandwidth limit", config: Config{MaxConcurrency: 5, BandwidthLimit: "fast"}, wantErr: true},
+		{name: "invalid write buffer", config: Config{MaxConcurrency: 5, WriteBuffer: "big"}, wantErr: true},
+		{name: "invalid max file size", config: Config{MaxConcurrency: 5, MaxFileSize: "4X"}, wantErr: true},
+		{name: "invalid large file threshold", config: Config{MaxConcurrency: 5, LargeFileThreshold: "-1"}, wantErr: true},
+		{name: "invalid max memory", config: Config{MaxConcurrency: 5, MaxMemory: "lots"}, wantErr: true},
+		{name: "chaos", config: Config{MaxConcurrency: 5, Chaos: "rate_limits=5%"}},
+		{name: "invalid chaos", config: Config{MaxConcurrency: 5, Chaos: "floods=1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Check(); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"create-dropbox-backup-folder/internal/configfile"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// profilesKey holds the settings of each profile in a config file
+const profilesKey = "profiles"
+
+// loadFile applies the settings of a YAML or TOML file, whose keys are the
+// JSON names of the Config fields, e.g. backup_dir or max_concurrency. The
+// profiles table is returned to be applied by applyProfile.
+func (c *Config) loadFile(path string) (configfile.Values, error) {
+	values, err := configfile.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := splitProfiles(values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := c.assignFile(values, ""); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// splitProfiles removes the profiles table from the settings of a file
+func splitProfiles(values configfile.Values) (configfile.Values, error) {
+	if _, ok := values["profile"]; ok {
+		// The file is shared by all profiles, so it can't select one
+		return nil, fmt.Errorf("profile can't be set in the config file; use --profile or DROPBOX_PROFILE")
+	}
+	value, ok := values[profilesKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(values, profilesKey)
+	profiles, ok := value.(configfile.Values)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s: must be a table of profiles", profilesKey)
+	}
+	for name, settings := range profiles {
+		if _, ok := settings.(configfile.Values); !ok {
+			return nil, fmt.Errorf("invalid %s.%s: must be a table of settings", profilesKey, name)
+		}
+	}
+	return profiles, nil
+}
+
+// applyProfile applies the settings of the selected profile from the
+// profiles table of the config file. Only the default profile may be
+// missing from the table.
+func (c *Config) applyProfile(profiles configfile.Values) error {
+	if profiles == nil {
+		return nil
+	}
+	settings, ok := profiles[c.Profile].(configfile.Values)
+	if !ok {
+		if c.Profile == DefaultProfile {
+			return nil
+		}
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("unknown profile %q (%s defines %s)", c.Profile, c.ConfigFile, strings.Join(names, ", "))
+	}
+
+	prefix := profilesKey + "." + c.Profile + "."
+	if _, ok := settings["profile"]; ok {
+		return fmt.Errorf("invalid config file %s: unknown setting %sprofile", c.ConfigFile, prefix)
+	}
+	if err := c.assignFile(settings, prefix); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", c.ConfigFile, err)
+	}
+	return nil
+}
+
+// assignFile sets the fields named by the settings of a file
+func (c *Config) assignFile(values configfile.Values, prefix string) error {
+	if err := assignFields(reflect.ValueOf(c).Elem(), values, prefix); err != nil {
+		return err
+	}
+
+	// Extensions are normalized as for --export-format
+	if len(c.ExportFormats) > 0 {
+		pairs := make([]string, 0, len(c.ExportFormats))
+		for ext, format := range c.ExportFormats {
+			pairs = append(pairs, ext+"="+format)
+		}
+		formats, err := ParseExportFormats(pairs)
+		if err != nil {
+			return err
+		}
+		c.ExportFormats = formats
+	}
+	return nil
+}
+
+// assignFields sets the fields of a struct from a table of values
+func assignFields(target reflect.Value, values configfile.Values, prefix string) error {
+	fields := make(map[string]int)
+	for i := 0; i < target.NumField(); i++ {
+		name, _, _ := strings.Cut(target.Type().Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		i, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown setting %s%s", prefix, key)
+		}
+		if err := assign(target.Field(i), values[key], prefix+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign converts a value of the file to the type of a field
+func assign(field reflect.Value, value any, name string) error {
+	if table, ok := value.(configfile.Values); ok {
+		switch {
+		case field.Kind() == reflect.Struct:
+			return assignFields(field, table, name+".")
+		case field.Kind() == reflect.Map && field.Type().Key().Kind() == reflect.String && field.Type().Elem().Kind() == reflect.String:
+			m := reflect.MakeMapWithSize(field.Type(), len(table))
+			for key, value := range table {
+				s, ok := value.(string)
+				if !ok {
+					return fmt.Errorf("invalid %s.%s: must be a string", name, key)
+				}
+				m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(s))
+			}
+			field.Set(m)
+			return nil
+		}
+		return fmt.Errorf("invalid %s: must not be a table", name)
+	}
+
+	if list, ok := value.([]string); ok {
+		if field.Kind() != reflect.Slice || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("invalid %s: must not be a list", name)
+		}
+		field.Set(reflect.ValueOf(slices.Clone(list)))
+		return nil
+	}
+
+	s := value.(string)
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		// A single value is a list of one
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("invalid %s: unsupported setting", name)
+		}
+		field.Set(reflect.ValueOf([]string{s}))
+	default:
+		return fmt.Errorf("invalid %s: unsupported setting", name)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_ACCESS_TOKEN", "token")
+	for _, key := range []string{"DROPBOX_BACKUP_FOLDER", "DROPBOX_BWLIMIT", "DROPBOX_API_TIMEOUT", "DROPBOX_VERIFY", "DROPBOX_CONFIG_FILE"} {
+		t.Setenv(key, "")
+	}
+
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "from-file")
+	yaml := filepath.Join(dir, "backup.yaml")
+	writeFile(t, yaml, `backup_dir: `+backupDir+`
+exclude: ["*.tmp", "cache/"]
+max_concurrency: 8
+bwlimit: 2M
+log_level: info
+api_timeout: 20s
+verify: true
+show_count: true
+export_formats:
+  .Paper: markdown
+retry:
+  max_attempts: 5
+`)
+
+	cfg, err := Load(Options{ConfigFile: yaml})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.BackupDir != backupDir || cfg.MaxConcurrency != 8 || cfg.BandwidthLimit != "2M" || cfg.LogLevel != "info" {
+		t.Errorf("Load() = dir %s, concurrency %d, bwlimit %s, log level %s; want the file's", cfg.BackupDir, cfg.MaxConcurrency, cfg.BandwidthLimit, cfg.LogLevel)
+	}
+	if !slices.Equal(cfg.Exclude, []string{"*.tmp", "cache/"}) {
+		t.Errorf("Exclude = %v", cfg.Exclude)
+	}
+	if cfg.APITimeout != 20*time.Second || !cfg.Verify || !cfg.ShowCount {
+		t.Errorf("APITimeout = %s, Verify = %v, ShowCount = %v; want the file's", cfg.APITimeout, cfg.Verify, cfg.ShowCount)
+	}
+	if cfg.ExportFormats["paper"] != "markdown" {
+		t.Errorf("ExportFormats = %v, want paper=markdown", cfg.ExportFormats)
+	}
+	if cfg.Retry.MaxAttempts != 5 || cfg.Retry.BaseDelay != 2*time.Second {
+		t.Errorf("Retry = %+v, want 5 attempts and the default delay", cfg.Retry)
+	}
+	// Settings the file leaves out keep their defaults
+	if cfg.StallTimeout != 5*time.Minute {
+		t.Errorf("StallTimeout = %s, want the default", cfg.StallTimeout)
+	}
+
+	// The environment overrides the file, and options the environment
+	t.Setenv("DROPBOX_BWLIMIT", "1M")
+	t.Setenv("DROPBOX_VERIFY", "false")
+	t.Setenv("DROPBOX_BACKUP_FOLDER", filepath.Join(dir, "from-env"))
+	t.Setenv("DROPBOX_CONFIG_FILE", yaml)
+	cfg, err = Load(Options{Concurrency: 2, LogLevel: "warn"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.BandwidthLimit != "1M" || cfg.Verify || cfg.BackupDir != filepath.Join(dir, "from-env") {
+		t.Errorf("Load() = bwlimit %s, verify %v, dir %s; want the environment's", cfg.BandwidthLimit, cfg.Verify, cfg.BackupDir)
+	}
+	if cfg.MaxConcurrency != 2 || cfg.LogLevel != "warn" {
+		t.Errorf("Load() = concurrency %d, log level %s; want the options'", cfg.MaxConcurrency, cfg.LogLevel)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_ACCESS_TOKEN", "token")
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "unknown setting", file: "a.yaml", content: "backup_folder: /tmp\n"},
+		{name: "unknown nested setting", file: "a.toml", content: "[retry]\nattempts = 3\n"},
+		{name: "invalid number", file: "a.toml", content: "max_concurrency = \"many\"\n"},
+		{name: "invalid duration", file: "a.yaml", content: "api_timeout: 20\n"},
+		{name: "list for a scalar", file: "a.yaml", content: "log_level: [info]\n"},
+		{name: "invalid value", file: "a.yaml", content: "log_level: loud\n"},
+		{name: "runtime field", file: "a.yaml", content: "stored_token: true\n"},
+		{name: "syntax error", file: "a.toml", content: "max_concurrency 8\n"},
+		{name: "unsupported format", file: "a.ini", content: "log_level=info\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeFile(t, path, tt.content)
+			if _, err := Load(Options{ConfigFile: path, BackupDir: t.TempDir()}); err == nil {
+				t.Error("Load() expected error")
+			}
+		})
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+	t.Setenv("DROPBOX_CLIENT_ID", "env_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "env_client_secret")
+	t.Setenv("DROPBOX_ACCESS_TOKEN", "token")
+	t.Setenv("DROPBOX_BACKUP_FOLDER", "")
+	t.Setenv("DROPBOX_PROFILE", "")
+	t.Setenv("DROPBOX_CONFIG_FILE", "")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.toml")
+	writeFile(t, path, `exclude = ["*.tmp"]
+max_concurrency = 4
+backup_dir = "`+filepath.Join(dir, "shared")+`"
+
+[profiles.personal]
+backup_dir = "`+filepath.Join(dir, "personal")+`"
+
+[profiles.work]
+client_id = "work_client_id"
+client_secret = "work_client_secret"
+backup_dir = "`+filepath.Join(dir, "work")+`"
+exclude = ["Archive/"]
+`)
+
+	tests := []struct {
+		name         string
+		profile      string
+		envProfile   string
+		opts         Options
+		wantClientID string
+		wantDir      string
+		wantExclude  []string
+		wantErr      bool
+	}{
+		{name: "default profile", wantClientID: "env_client_id", wantDir: "shared", wantExclude: []string{"*.tmp"}},
+		{name: "profile inherits the top level", profile: "personal", wantClientID: "env_client_id", wantDir: "personal", wantExclude: []string{"*.tmp"}},
+		{name: "profile overrides the environment", profile: "work", wantClientID: "work_client_id", wantDir: "work", wantExclude: []string{"Archive/"}},
+		{name: "profile from the environment", envProfile: "work", wantClientID: "work_client_id", wantDir: "work", wantExclude: []string{"Archive/"}},
+		{name: "options override the profile", profile: "work", opts: Options{Exclude: []string{"x"}, BackupDir: filepath.Join(dir, "flag")}, wantClientID: "work_client_id", wantDir: "flag", wantExclude: []string{"x"}},
+		{name: "unknown profile", profile: "travel", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DROPBOX_PROFILE", tt.envProfile)
+			opts := tt.opts
+			opts.ConfigFile = path
+			opts.Profile = tt.profile
+			cfg, err := Load(opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cfg.ClientID != tt.wantClientID || cfg.BackupDir != filepath.Join(dir, tt.wantDir) {
+				t.Errorf("Load() = client %s, dir %s; want %s, %s", cfg.ClientID, cfg.BackupDir, tt.wantClientID, tt.wantDir)
+			}
+			if !slices.Equal(cfg.Exclude, tt.wantExclude) {
+				t.Errorf("Exclude = %v, want %v", cfg.Exclude, tt.wantExclude)
+			}
+			if cfg.MaxConcurrency != 4 {
+				t.Errorf("MaxConcurrency = %d, want the top-level 4", cfg.MaxConcurrency)
+			}
+		})
+	}
+}
+
+func TestProfileInConfigFile(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+
+	for _, content := range []string{
+		"profile: work\n",
+		"profiles:\n  work:\n    profile: home\n",
+		"profiles: [work]\n",
+		"profiles:\n  work: yes\n",
+	} {
+		path := filepath.Join(t.TempDir(), "backup.yaml")
+		writeFile(t, path, content)
+		if _, err := Load(Options{ConfigFile: path, Profile: "work", BackupDir: t.TempDir()}); err == nil {
+			t.Errorf("Load() accepted %q", content)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
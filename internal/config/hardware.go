@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HardwareLow tunes for small ARM NAS devices with little memory and CPU
+const HardwareLow = "low"
+
+// hardwareProfile holds the settings a hardware profile tunes
+type hardwareProfile struct {
+	Concurrency          int
+	LargeFileConcurrency int
+	WriteBuffer          string
+	MaxMemory            string
+	HashWorkers          int
+	ProgressInterval     time.Duration
+}
+
+// hardwareProfiles are the profiles selectable with --profile-hardware
+var hardwareProfiles = map[string]hardwareProfile{
+	HardwareLow: {
+		Concurrency:          2,
+		LargeFileConcurrency: 1,
+		WriteBuffer:          "256K",
+		MaxMemory:            "256M",
+		HashWorkers:          1,
+		ProgressInterval:     time.Minute,
+	},
+}
+
+// applyHardwareProfile applies the selected hardware profile, if any, to
+// the settings still at their defaults; settings made in the environment,
+// a preset or with options win
+func (c *Config) applyHardwareProfile() error {
+	if c.HardwareProfile == "" {
+		return nil
+	}
+	profile, ok := hardwareProfiles[c.HardwareProfile]
+	if !ok {
+		names := slices.Sorted(maps.Keys(hardwareProfiles))
+		return fmt.Errorf("unknown hardware profile: %s (must be %s)", c.HardwareProfile, strings.Join(names, ", "))
+	}
+
+	defaults := defaultConfig()
+	if c.MaxConcurrency == defaults.MaxConcurrency {
+		c.MaxConcurrency = profile.Concurrency
+	}
+	if c.LargeFileConcurrency == defaults.LargeFileConcurrency {
+		c.LargeFileConcurrency = profile.LargeFileConcurrency
+	}
+	if c.WriteBuffer == defaults.WriteBuffer {
+		c.WriteBuffer = profile.WriteBuffer
+	}
+	if c.MaxMemory == defaults.MaxMemory {
+		c.MaxMemory = profile.MaxMemory
+	}
+	if c.HashWorkers == defaults.HashWorkers {
+		c.HashWorkers = profile.HashWorkers
+	}
+	if c.ProgressInterval == defaults.ProgressInterval {
+		c.ProgressInterval = profile.ProgressInterval
+	}
+	return nil
+}
+
+// HashWorkerLimit returns the most files to hash at once, from HashWorkers
+// and the memory plan; 0 if unbounded
+func (c *Config) HashWorkerLimit() int {
+	limit := c.HashWorkers
+	if memory, err := c.MemoryPlan(); err == nil && memory.HashWorkers > 0 && (limit == 0 || memory.HashWorkers < limit) {
+		limit = memory.HashWorkers
+	}
+	return limit
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadHardwareProfile(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+	t.Setenv("DROPBOX_HARDWARE_PROFILE", "")
+	t.Setenv("DROPBOX_WRITE_BUFFER", "1M")
+
+	cfg, err := Load(Options{BackupDir: t.TempDir(), HardwareProfile: HardwareLow, Concurrency: 3})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// The options and environment win over the profile
+	if cfg.MaxConcurrency != 3 || cfg.WriteBuffer != "1M" {
+		t.Errorf("concurrency %d, write buffer %q; want 3, 1M", cfg.MaxConcurrency, cfg.WriteBuffer)
+	}
+	if cfg.LargeFileConcurrency != 1 || cfg.MaxMemory != "256M" || cfg.HashWorkers != 1 || cfg.ProgressInterval != time.Minute {
+		t.Errorf("Load() low profile = %d, %q, %d, %v", cfg.LargeFileConcurrency, cfg.MaxMemory, cfg.HashWorkers, cfg.ProgressInterval)
+	}
+
+	t.Setenv("DROPBOX_HARDWARE_PROFILE", HardwareLow)
+	cfg, err = Load(Options{BackupDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.MaxConcurrency != 2 {
+		t.Errorf("concurrency = %d with DROPBOX_HARDWARE_PROFILE, want 2", cfg.MaxConcurrency)
+	}
+
+	if _, err := Load(Options{BackupDir: t.TempDir(), HardwareProfile: "tiny"}); err == nil {
+		t.Error("Load() with an unknown hardware profile should fail")
+	}
+}
+
+func TestHashWorkerLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   int
+	}{
+		{name: "unbounded", want: 0},
+		{name: "configured", config: Config{HashWorkers: 2}, want: 2},
+		{name: "memory plan", config: Config{MaxMemory: "64M"}, want: 4},
+		{name: "lower of both", config: Config{MaxMemory: "64M", HashWorkers: 8}, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.HashWorkerLimit(); got != tt.want {
+				t.Errorf("HashWorkerLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
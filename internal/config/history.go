@@ -0,0 +1,64 @@
+package config
+
+import "time"
+
+// maxRunHistory bounds the runs kept per profile in the settings file
+const maxRunHistory = 20
+
+// RunRecord is the outcome of a backup run, kept in the settings file for
+// the status command
+type RunRecord struct {
+	ID         string    `json:"id,omitempty"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Success    bool      `json:"success"`
+	Downloaded int       `json:"downloaded"`
+	Failed     int       `json:"failed"`
+	Bytes      uint64    `json:"bytes"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Duration returns how long the run took
+func (r RunRecord) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Schedule describes the interval a running daemon backs up on, see daemon
+// --interval
+type Schedule struct {
+	Interval time.Duration `json:"interval_ns"`
+	// Start is when the daemon started; scheduled runs fall on multiples of
+	// Interval after it
+	Start time.Time `json:"start"`
+}
+
+// Next returns the first scheduled run after t
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.Interval <= 0 {
+		return time.Time{}
+	}
+	if t.Before(s.Start) {
+		return s.Start.Add(s.Interval)
+	}
+	ticks := t.Sub(s.Start)/s.Interval + 1
+	return s.Start.Add(ticks * s.Interval)
+}
+
+// RecordRun adds a run to the history of a profile, dropping the oldest
+// beyond maxRunHistory
+func (s *Settings) RecordRun(name string, run RunRecord) {
+	profile := s.Profiles[name]
+	profile.Runs = append(profile.Runs, run)
+	if len(profile.Runs) > maxRunHistory {
+		profile.Runs = profile.Runs[len(profile.Runs)-maxRunHistory:]
+	}
+	s.Profiles[name] = profile
+}
+
+// SetSchedule stores the schedule of the daemon backing up a profile; nil
+// when it stops
+func (s *Settings) SetSchedule(name string, schedule *Schedule) {
+	profile := s.Profiles[name]
+	profile.Schedule = schedule
+	s.Profiles[name] = profile
+}
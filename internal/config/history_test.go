@@ -0,0 +1,64 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := Schedule{Interval: time.Hour, Start: start}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{name: "at start", t: start, want: start.Add(time.Hour)},
+		{name: "between ticks", t: start.Add(90 * time.Minute), want: start.Add(2 * time.Hour)},
+		{name: "on a tick", t: start.Add(2 * time.Hour), want: start.Add(3 * time.Hour)},
+		{name: "before start", t: start.Add(-time.Minute), want: start.Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.Next(tt.t); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordRun(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range maxRunHistory + 5 {
+		runStart := start.Add(time.Duration(i) * time.Hour)
+		settings.RecordRun(DefaultProfile, RunRecord{Start: runStart, End: runStart.Add(time.Minute), Success: true})
+	}
+	settings.SetSchedule(DefaultProfile, &Schedule{Interval: time.Hour, Start: start})
+	if err := settings.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := reloaded.Profile(DefaultProfile)
+	if len(profile.Runs) != maxRunHistory {
+		t.Fatalf("len(Runs) = %d, want %d", len(profile.Runs), maxRunHistory)
+	}
+	if first := profile.Runs[0].Start; !first.Equal(start.Add(5 * time.Hour)) {
+		t.Errorf("oldest run started %v, want the oldest ones dropped", first)
+	}
+	if profile.Schedule == nil || profile.Schedule.Interval != time.Hour {
+		t.Errorf("Schedule = %+v, want hourly", profile.Schedule)
+	}
+}
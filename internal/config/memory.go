@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+
+	"create-dropbox-backup-folder/internal/throttle"
+)
+
+// minMaxMemory is the smallest --max-memory budget; the Go runtime alone
+// needs a good part of it
+const minMaxMemory = 32 << 20
+
+// Memory each consumer of a --max-memory budget is assumed to need
+const (
+	// transferMemory is what a transfer needs besides its write buffer: the
+	// connection with its TLS and read buffers
+	transferMemory = 256 << 10
+	// queuedTransferMemory is what a transfer waiting for a slot needs
+	queuedTransferMemory = 16 << 10
+	// hashWorkerMemory is what hashing a local file needs, with read-ahead
+	hashWorkerMemory = 1 << 20
+	// listEntryMemory is what a listed entry needs while its page is decoded
+	listEntryMemory = 4 << 10
+)
+
+// MaxListPageSize is the most entries Dropbox returns per listing page
+const MaxListPageSize = 2000
+
+// MemoryPlan bounds what is held in memory at once to stay within a
+// --max-memory budget. Zero fields are unbounded.
+type MemoryPlan struct {
+	// Limit is the soft memory limit of the Go runtime, which collects
+	// garbage more often as memory use gets close to it
+	Limit int64
+	// Concurrency bounds the transfers at once, each with a write buffer
+	Concurrency int
+	// QueuedTransfers bounds the transfers waiting for a slot
+	QueuedTransfers int
+	// HashWorkers bounds the files hashed at once by --verify-after
+	HashWorkers int
+	// ListPageSize is the number of entries to request per listing page
+	ListPageSize int
+}
+
+// MemoryPlan divides the --max-memory budget: a quarter for transfers, a
+// sixteenth each for queued transfers and hash workers and a sixty-fourth
+// for listing pages. The rest is left for the file list and everything else.
+func (c *Config) MemoryPlan() (MemoryPlan, error) {
+	budget, err := throttle.ParseRate(c.MaxMemory)
+	if err != nil {
+		return MemoryPlan{}, fmt.Errorf("invalid max memory %q (e.g. 512M)", c.MaxMemory)
+	}
+	if budget == 0 {
+		return MemoryPlan{}, nil
+	}
+	if budget < minMaxMemory {
+		return MemoryPlan{}, fmt.Errorf("invalid max memory %q (must be at least 32M)", c.MaxMemory)
+	}
+	writeBuffer, err := c.WriteBufferSize()
+	if err != nil {
+		return MemoryPlan{}, err
+	}
+
+	return MemoryPlan{
+		Limit:           budget,
+		Concurrency:     share(budget, 4, transferMemory+int64(writeBuffer)),
+		QueuedTransfers: share(budget, 16, queuedTransferMemory),
+		HashWorkers:     share(budget, 16, hashWorkerMemory),
+		ListPageSize:    min(MaxListPageSize, share(budget, 64, listEntryMemory)),
+	}, nil
+}
+
+// share returns how many consumers of size fit into one part of a budget,
+// at least one
+func share(budget, parts, size int64) int {
+	return max(1, int(budget/parts/size))
+}
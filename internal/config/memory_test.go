@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+func TestMemoryPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		want    MemoryPlan
+		wantErr bool
+	}{
+		{name: "unbounded", config: Config{}, want: MemoryPlan{}},
+		{
+			name:   "NAS budget",
+			config: Config{MaxMemory: "512M"},
+			want:   MemoryPlan{Limit: 512 << 20, Concurrency: 512, QueuedTransfers: 2048, HashWorkers: 32, ListPageSize: MaxListPageSize},
+		},
+		{
+			name:   "large write buffers",
+			config: Config{MaxMemory: "512M", WriteBuffer: "8M"},
+			want:   MemoryPlan{Limit: 512 << 20, Concurrency: 15, QueuedTransfers: 2048, HashWorkers: 32, ListPageSize: MaxListPageSize},
+		},
+		{
+			name:   "small budget",
+			config: Config{MaxMemory: "64M", WriteBuffer: "4M"},
+			want:   MemoryPlan{Limit: 64 << 20, Concurrency: 3, QueuedTransfers: 256, HashWorkers: 4, ListPageSize: 256},
+		},
+		{name: "too small", config: Config{MaxMemory: "16M"}, wantErr: true},
+		{name: "invalid", config: Config{MaxMemory: "lots"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.MemoryPlan()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MemoryPlan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MemoryPlan() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
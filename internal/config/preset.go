@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Preset bundles the folders, filters and layout of a common partial backup,
+// selected by name with --preset
+type Preset struct {
+	RemotePaths []string `json:"remote_paths,omitempty"`
+	Include     []string `json:"include,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"`
+	Layout      string   `json:"layout,omitempty"`
+}
+
+// builtinPresets are available without defining them in the settings file,
+// which can override them
+var builtinPresets = map[string]Preset{
+	"documents": {Include: []string{
+		"*.pdf", "*.txt", "*.md", "*.rtf", "*.csv",
+		"*.doc", "*.docx", "*.xls", "*.xlsx", "*.ppt", "*.pptx",
+		"*.odt", "*.ods", "*.odp", "*.pages", "*.numbers", "*.key",
+	}},
+	"photos": {Include: []string{
+		"*.jpg", "*.jpeg", "*.png", "*.gif", "*.heic", "*.heif", "*.webp",
+		"*.tif", "*.tiff", "*.dng", "*.cr2", "*.cr3", "*.nef", "*.arw",
+	}},
+}
+
+// Preset returns the named preset, defined in the settings file or built in
+func (s *Settings) Preset(name string) (Preset, bool) {
+	if preset, ok := s.Presets[name]; ok {
+		return preset, true
+	}
+	preset, ok := builtinPresets[name]
+	return preset, ok
+}
+
+// PresetNames returns the names of all presets in order
+func (s *Settings) PresetNames() []string {
+	names := slices.Collect(maps.Keys(builtinPresets))
+	for name := range s.Presets {
+		if _, ok := builtinPresets[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// applyPreset applies the values of the selected preset, if any; options
+// given on the command line still override them
+func (c *Config) applyPreset(settings *Settings) error {
+	if c.Preset == "" {
+		return nil
+	}
+	preset, ok := settings.Preset(c.Preset)
+	if !ok {
+		return fmt.Errorf("unknown preset: %s (must be one of %s)", c.Preset, strings.Join(settings.PresetNames(), ", "))
+	}
+
+	if len(preset.RemotePaths) > 0 {
+		c.RemotePaths = preset.RemotePaths
+	}
+	if len(preset.Include) > 0 {
+		c.Include = preset.Include
+	}
+	if len(preset.Exclude) > 0 {
+		c.Exclude = preset.Exclude
+	}
+	if preset.Layout != "" {
+		c.Layout = preset.Layout
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadPreset(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_PRESET", "")
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", path)
+	settings := `{"presets": {
+		"work": {"remote_paths": ["/work"], "exclude": ["*.tmp"], "layout": "shared"},
+		"photos": {"remote_paths": ["/camera uploads"]}
+	}}`
+	if err := os.WriteFile(path, []byte(settings), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(Options{BackupDir: t.TempDir(), Preset: "work"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !slices.Equal(cfg.RemotePaths, []string{"/work"}) || !slices.Equal(cfg.Exclude, []string{"*.tmp"}) || cfg.Layout != LayoutShared {
+		t.Errorf("Load() preset work = %v, %v, %q", cfg.RemotePaths, cfg.Exclude, cfg.Layout)
+	}
+
+	// Options override the preset
+	cfg, err = Load(Options{BackupDir: t.TempDir(), Preset: "work", Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !slices.Equal(cfg.Exclude, []string{"*.log"}) {
+		t.Errorf("Load() Exclude = %v, want [*.log]", cfg.Exclude)
+	}
+
+	// Presets in the settings file replace built-in ones
+	t.Setenv("DROPBOX_PRESET", "photos")
+	cfg, err = Load(Options{BackupDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !slices.Equal(cfg.RemotePaths, []string{"/camera uploads"}) || len(cfg.Include) != 0 {
+		t.Errorf("Load() preset photos = %v, %v", cfg.RemotePaths, cfg.Include)
+	}
+
+	cfg, err = Load(Options{BackupDir: t.TempDir(), Preset: "documents"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !slices.Contains(cfg.Include, "*.pdf") {
+		t.Errorf("Load() preset documents Include = %v", cfg.Include)
+	}
+
+	_, err = Load(Options{BackupDir: t.TempDir(), Preset: "music"})
+	if err == nil || err.Error() != "unknown preset: music (must be one of documents, photos, work)" {
+		t.Errorf("Load() unknown preset error = %v", err)
+	}
+}
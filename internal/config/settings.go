@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"create-dropbox-backup-folder/internal/budget"
+)
+
+// Settings holds values the tool persists on the user's behalf, such as the
+// folder selection made with --choose, keyed by profile name
+type Settings struct {
+	Profiles map[string]ProfileSettings `json:"profiles"`
+	// Presets are defined by the user and override built-in presets of the
+	// same name
+	Presets map[string]Preset `json:"presets,omitempty"`
+	// APIUsage counts the API calls of recent runs by app key, as other
+	// profiles and apps may share the daily budget of the same key
+	APIUsage map[string]budget.Usage `json:"api_usage,omitempty"`
+
+	path string
+}
+
+// ProfileSettings holds the persisted settings for a single profile
+type ProfileSettings struct {
+	RemotePaths []string `json:"remote_paths,omitempty"`
+	// SyncExclude are exclude patterns imported from the Dropbox desktop
+	// client with import-sync; they apply in addition to --exclude
+	SyncExclude []string `json:"sync_exclude,omitempty"`
+	// Throughput is the download throughput of earlier runs in bytes per
+	// second, used to estimate the remaining time of the next run
+	Throughput float64 `json:"throughput,omitempty"`
+	// Runs are the outcomes of the latest runs, oldest first
+	Runs []RunRecord `json:"runs,omitempty"`
+	// Schedule is set while a daemon backs up on an interval
+	Schedule *Schedule `json:"schedule,omitempty"`
+}
+
+// RecordThroughput averages the download throughput of a run into the
+// stored throughput of a profile, so one unusual run doesn't dominate
+func (s *Settings) RecordThroughput(name string, bytesPerSecond float64) {
+	profile := s.Profiles[name]
+	if profile.Throughput > 0 {
+		bytesPerSecond = (profile.Throughput + bytesPerSecond) / 2
+	}
+	profile.Throughput = bytesPerSecond
+	s.Profiles[name] = profile
+}
+
+// RecordAPIUsage stores the API calls made with an app key in the last 24
+// hours, replacing what was stored before
+func (s *Settings) RecordAPIUsage(appKey string, usage budget.Usage) {
+	if s.APIUsage == nil {
+		s.APIUsage = make(map[string]budget.Usage)
+	}
+	s.APIUsage[appKey] = usage
+}
+
+// SettingsPath returns the location of the persisted settings file.
+// DROPBOX_BACKUP_SETTINGS overrides the default under the user config directory.
+func SettingsPath() (string, error) {
+	if path := os.Getenv("DROPBOX_BACKUP_SETTINGS"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+
+	return filepath.Join(dir, "create-dropbox-backup-folder", "settings.json"), nil
+}
+
+// LoadSettings reads the persisted settings file. A missing file yields empty settings.
+func LoadSettings() (*Settings, error) {
+	path, err := SettingsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &Settings{
+		Profiles: make(map[string]ProfileSettings),
+		path:     path,
+	}
+
+	data, err := fsys.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse settings file %s: %w", path, err)
+	}
+	if settings.Profiles == nil {
+		settings.Profiles = make(map[string]ProfileSettings)
+	}
+
+	return settings, nil
+}
+
+// Profile returns the settings stored for the named profile
+func (s *Settings) Profile(name string) ProfileSettings {
+	return s.Profiles[name]
+}
+
+// SetProfile replaces the settings stored for the named profile
+func (s *Settings) SetProfile(name string, profile ProfileSettings) {
+	s.Profiles[name] = profile
+}
+
+// Save writes the settings back to disk
+func (s *Settings) Save() error {
+	if s.path == "" {
+		path, err := SettingsPath()
+		if err != nil {
+			return err
+		}
+		s.path = path
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+
+	if err := fsys.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create settings directory: %w", err)
+	}
+
+	if err := fsys.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write settings file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/budget"
+	"create-dropbox-backup-folder/internal/localfs/localfstest"
+)
+
+func TestLoadSettingsMissingFile(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+
+	if len(settings.Profile(DefaultProfile).RemotePaths) != 0 {
+		t.Errorf("LoadSettings() RemotePaths = %v, want empty", settings.Profile(DefaultProfile).RemotePaths)
+	}
+}
+
+func TestSettingsSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "settings.json")
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", path)
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+
+	settings.SetProfile(DefaultProfile, ProfileSettings{RemotePaths: []string{"/photos", "/documents"}})
+	if err := settings.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+
+	got := reloaded.Profile(DefaultProfile).RemotePaths
+	if len(got) != 2 || got[0] != "/photos" || got[1] != "/documents" {
+		t.Errorf("reloaded RemotePaths = %v, want [/photos /documents]", got)
+	}
+}
+
+func TestLoadSettingsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", path)
+
+	if _, err := LoadSettings(); err == nil {
+		t.Error("LoadSettings() expected error for invalid file")
+	}
+}
+
+func TestRecordThroughput(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.SetProfile(DefaultProfile, ProfileSettings{RemotePaths: []string{"/work"}})
+	settings.RecordThroughput(DefaultProfile, 1000)
+	settings.RecordThroughput(DefaultProfile, 3000)
+	if err := settings.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DROPBOX_CLIENT_ID", "id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "secret")
+	cfg, err := Load(Options{BackupDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HistoricalThroughput != 2000 {
+		t.Errorf("HistoricalThroughput = %v, want 2000", cfg.HistoricalThroughput)
+	}
+	if len(cfg.RemotePaths) != 1 || cfg.RemotePaths[0] != "/work" {
+		t.Errorf("RemotePaths = %v, want the stored selection", cfg.RemotePaths)
+	}
+}
+
+func TestRecordAPIUsage(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.RecordAPIUsage("app", budget.Usage{1700000000: 3})
+	settings.RecordAPIUsage("other", budget.Usage{1700000000: 9})
+	if err := settings.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DROPBOX_CLIENT_ID", "app")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "secret")
+	t.Setenv("DROPBOX_API_CALL_BUDGET", "500")
+	cfg, err := Load(Options{BackupDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APICallBudget != 500 {
+		t.Errorf("APICallBudget = %d, want 500", cfg.APICallBudget)
+	}
+	if len(cfg.APIUsage) != 1 || cfg.APIUsage[1700000000] != 3 {
+		t.Errorf("APIUsage = %v, want the usage of the app key", cfg.APIUsage)
+	}
+}
+
+func TestSaveSettingsPermissionDenied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", path)
+
+	faults := localfstest.New()
+	faults.Fail(localfstest.OpCreate, path, os.ErrPermission)
+	original := fsys
+	fsys = faults
+	t.Cleanup(func() { fsys = original })
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = settings.Save()
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("Save() error = %v, want permission denied", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("settings file written despite the fault: %v", err)
+	}
+}
+
+func TestLoadSyncExclude(t *testing.T) {
+	t.Setenv("DROPBOX_CLIENT_ID", "test_client_id")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "test_client_secret")
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.SetProfile(DefaultProfile, ProfileSettings{SyncExclude: []string{"/photos/old/"}})
+	if err := settings.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Imported exclusions apply in addition to --exclude
+	cfg, err := Load(Options{BackupDir: t.TempDir(), Exclude: []string{"*.tmp"}})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := []string{"*.tmp", "/photos/old/"}; !slices.Equal(cfg.Exclude, want) {
+		t.Errorf("Load() Exclude = %v, want %v", cfg.Exclude, want)
+	}
+}
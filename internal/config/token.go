@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"create-dropbox-backup-folder/internal/tokenstore"
+
+	"golang.org/x/oauth2"
+)
+
+// OpenTokenStore returns the token store of a mode, which keeps its file
+// next to the settings
+func OpenTokenStore(mode string) (*tokenstore.Store, error) {
+	path, err := SettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	return tokenstore.Open(mode, filepath.Dir(path))
+}
+
+// TokenKey returns the entry of the token store holding the tokens of an
+// app key for a profile. Profiles may use the same app key for different
+// accounts, so each has its own entry; the default profile's is the app key.
+func TokenKey(clientID, profile string) string {
+	if profile == "" || profile == DefaultProfile {
+		return clientID
+	}
+	return clientID + "/" + profile
+}
+
+// TokenKey returns the entry of the token store of the configuration
+func (c *Config) TokenKey() string {
+	return TokenKey(c.ClientID, c.Profile)
+}
+
+// loadStoredToken loads the tokens of the app key from the token store
+// unless the environment holds tokens
+func (c *Config) loadStoredToken() error {
+	if c.ClientID == "" || c.AccessToken != "" || c.RefreshToken != "" {
+		return nil
+	}
+	store, err := OpenTokenStore(c.TokenStore)
+	if err != nil {
+		return err
+	}
+	token, err := store.Load(c.TokenKey())
+	if errors.Is(err, tokenstore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load stored tokens: %w", err)
+	}
+	c.AccessToken = token.AccessToken
+	c.RefreshToken = token.RefreshToken
+	c.TokenExpiry = token.Expiry
+	c.StoredToken = true
+	return nil
+}
+
+// Token returns the OAuth2 token to connect with
+func (c *Config) Token() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  c.AccessToken,
+		RefreshToken: c.RefreshToken,
+		Expiry:       c.TokenExpiry,
+	}
+}
+
+// SaveToken stores refreshed tokens when they came from the token store
+func (c *Config) SaveToken(token *oauth2.Token) error {
+	if !c.StoredToken || token == nil {
+		return nil
+	}
+	if token.AccessToken == c.AccessToken && token.RefreshToken == c.RefreshToken {
+		return nil
+	}
+	store, err := OpenTokenStore(c.TokenStore)
+	if err != nil {
+		return err
+	}
+	if _, err := store.Save(c.TokenKey(), token); err != nil {
+		return fmt.Errorf("failed to save refreshed tokens: %w", err)
+	}
+	c.AccessToken = token.AccessToken
+	c.RefreshToken = token.RefreshToken
+	c.TokenExpiry = token.Expiry
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/tokenstore"
+
+	"golang.org/x/oauth2"
+)
+
+func TestLoadStoredToken(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+	t.Setenv("DROPBOX_CLIENT_ID", "app")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "secret")
+	t.Setenv("DROPBOX_TOKEN_STORE", tokenstore.ModeFile)
+	t.Setenv("DROPBOX_ACCESS_TOKEN", "")
+	t.Setenv("DROPBOX_REFRESH_TOKEN", "")
+
+	expiry := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+	store, err := OpenTokenStore(tokenstore.ModeFile)
+	if err != nil {
+		t.Fatalf("OpenTokenStore failed: %v", err)
+	}
+	if _, err := store.Save("app", &oauth2.Token{AccessToken: "stored", RefreshToken: "refresh", Expiry: expiry}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cfg, err := Load(Options{BackupDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.StoredToken || cfg.AccessToken != "stored" || cfg.RefreshToken != "refresh" || !cfg.TokenExpiry.Equal(expiry) {
+		t.Fatalf("Load did not use the stored tokens: %+v", cfg.Token())
+	}
+
+	// Refreshed tokens replace the stored ones
+	if err := cfg.SaveToken(&oauth2.Token{AccessToken: "fresh", RefreshToken: "refresh"}); err != nil {
+		t.Fatalf("SaveToken failed: %v", err)
+	}
+	token, err := store.Load("app")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if token.AccessToken != "fresh" {
+		t.Errorf("stored access token = %q, want fresh", token.AccessToken)
+	}
+
+	// Tokens in the environment take priority
+	t.Setenv("DROPBOX_ACCESS_TOKEN", "env")
+	cfg, err = Load(Options{BackupDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.StoredToken || cfg.AccessToken != "env" {
+		t.Errorf("Load access token = %q (stored %v), want env", cfg.AccessToken, cfg.StoredToken)
+	}
+	if err := cfg.SaveToken(&oauth2.Token{AccessToken: "other"}); err != nil {
+		t.Fatalf("SaveToken failed: %v", err)
+	}
+	if token, _ := store.Load("app"); token.AccessToken != "fresh" {
+		t.Errorf("SaveToken replaced stored tokens with environment ones: %q", token.AccessToken)
+	}
+}
+
+func TestTokenKey(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{profile: "", want: "app"},
+		{profile: DefaultProfile, want: "app"},
+		{profile: "work", want: "app/work"},
+	}
+	for _, tt := range tests {
+		if got := TokenKey("app", tt.profile); got != tt.want {
+			t.Errorf("TokenKey(app, %q) = %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package configfile reads configuration files in the subsets of YAML and
+// TOML that settings need: scalars, lists of scalars and nested tables.
+// Anchors, multi-line strings and lists of tables are not supported.
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Values are the settings of a file by key. A value is a string for a
+// scalar (quotes removed), a []string for a list or a Values for a table.
+type Values map[string]any
+
+// Formats by file extension
+var parsers = map[string]func(string) (Values, error){
+	".yaml": ParseYAML,
+	".yml":  ParseYAML,
+	".toml": ParseTOML,
+}
+
+// Read parses a file as YAML or TOML according to its extension
+func Read(path string) (Values, error) {
+	parse, ok := parsers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported config file %s (must end in .yaml, .yml or .toml)", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	values, err := parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// set adds a value, rejecting a key given twice
+func (v Values) set(key string, value any) error {
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+	if _, ok := v[key]; ok {
+		return fmt.Errorf("duplicate key %q", key)
+	}
+	v[key] = value
+	return nil
+}
+
+// stripComment removes a comment starting with # outside quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitList splits the items of an inline list or table at commas outside
+// quotes and brackets
+func splitList(s string) ([]string, error) {
+	var items []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string in %q", s)
+	}
+	// A trailing comma is allowed, an empty item elsewhere is not
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	for _, item := range items {
+		if item == "" {
+			return nil, fmt.Errorf("empty item in %q", s)
+		}
+	}
+	return items, nil
+}
+
+// unquote returns a scalar without its quotes. Double-quoted strings take
+// the escapes \", \\, \n and \t.
+func unquote(s string) (string, error) {
+	if len(s) == 0 || (s[0] != '"' && s[0] != '\'') {
+		return s, nil
+	}
+	quote := s[0]
+	if len(s) < 2 || s[len(s)-1] != quote {
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	body := s[1 : len(s)-1]
+	if quote == '\'' {
+		return body, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 == len(body) {
+			return "", fmt.Errorf("invalid escape at the end of %s", s)
+		}
+		i++
+		switch body[i] {
+		case '"', '\\':
+			b.WriteByte(body[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			return "", fmt.Errorf("unsupported escape \\%c in %s", body[i], s)
+		}
+	}
+	return b.String(), nil
+}
+
+// inlineList parses the items of a [a, b] list of scalars
+func inlineList(s string) ([]string, error) {
+	items, err := splitList(s[1 : len(s)-1])
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if strings.HasPrefix(item, "[") || strings.HasPrefix(item, "{") {
+			return nil, fmt.Errorf("nested lists and tables are not supported: %s", item)
+		}
+		value, err := unquote(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
@@ -0,0 +1,142 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// want is the settings both example files hold
+var want = Values{
+	"backup_dir":       "/backups/dropbox",
+	"exclude":          []string{"*.tmp", "cache/", "a, b"},
+	"max_concurrency":  "8",
+	"bwlimit":          "2M",
+	"log_level":        "info",
+	"delete":           "true",
+	"post_run_command": `echo "done" # not a comment`,
+	"retry": Values{
+		"max_attempts": "5",
+		"retry_on":     []string{"network", "server"},
+	},
+	"export_formats": Values{"paper": "markdown"},
+}
+
+func TestParseYAML(t *testing.T) {
+	got, err := ParseYAML(`---
+# Dropbox backup
+backup_dir: /backups/dropbox
+exclude:
+  - "*.tmp"
+  - cache/   # trailing comment
+  - 'a, b'
+max_concurrency: 8
+bwlimit: 2M
+log_level: "info"
+delete: true
+post_run_command: 'echo "done" # not a comment'
+
+retry:
+  max_attempts: 5
+  retry_on: [network, "server"]
+export_formats:
+  paper: markdown
+`)
+	if err != nil {
+		t.Fatalf("ParseYAML() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseYAML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	got, err := ParseTOML(`# Dropbox backup
+backup_dir = "/backups/dropbox"
+exclude = [
+  "*.tmp",
+  "cache/",   # trailing comment
+  'a, b',
+]
+max_concurrency = 8
+bwlimit = "2M"
+log_level = "info"
+delete = true
+post_run_command = 'echo "done" # not a comment'
+export_formats = { paper = "markdown" }
+
+[retry]
+max_attempts = 5
+retry_on = ["network", "server"]
+`)
+	if err != nil {
+		t.Fatalf("ParseTOML() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTOML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseTOMLDottedKeys(t *testing.T) {
+	got, err := ParseTOML("retry.max_attempts = 2\nretry.jitter = 0.5\n")
+	if err != nil {
+		t.Fatalf("ParseTOML() error = %v", err)
+	}
+	want := Values{"retry": Values{"max_attempts": "2", "jitter": "0.5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTOML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (Values, error)
+		data  string
+	}{
+		{name: "yaml duplicate key", parse: ParseYAML, data: "a: 1\na: 2\n"},
+		{name: "yaml missing colon", parse: ParseYAML, data: "backup_dir /tmp\n"},
+		{name: "yaml bad indentation", parse: ParseYAML, data: "a: 1\n  b: 2\n"},
+		{name: "yaml multi-line string", parse: ParseYAML, data: "a: |\n  text\n"},
+		{name: "yaml list of mappings", parse: ParseYAML, data: "a:\n  - b: c\n"},
+		{name: "yaml unterminated string", parse: ParseYAML, data: "a: \"open\n"},
+		{name: "yaml tab indentation", parse: ParseYAML, data: "a:\n\tb: c\n"},
+		{name: "toml missing value", parse: ParseTOML, data: "a =\n"},
+		{name: "toml missing equals", parse: ParseTOML, data: "a\n"},
+		{name: "toml duplicate key", parse: ParseTOML, data: "a = 1\na = 2\n"},
+		{name: "toml array of tables", parse: ParseTOML, data: "[[a]]\n"},
+		{name: "toml unterminated array", parse: ParseTOML, data: "a = [1, 2\n"},
+		{name: "toml table over value", parse: ParseTOML, data: "a = 1\n[a]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.parse(tt.data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	yaml := filepath.Join(dir, "backup.yml")
+	if err := os.WriteFile(yaml, []byte("log_level: debug\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(yaml)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got["log_level"] != "debug" {
+		t.Errorf("Read() = %v", got)
+	}
+
+	if _, err := Read(filepath.Join(dir, "backup.json")); err == nil {
+		t.Error("Read() accepted an unsupported extension")
+	}
+	if _, err := Read(filepath.Join(dir, "missing.toml")); err == nil {
+		t.Error("Read() accepted a missing file")
+	}
+}
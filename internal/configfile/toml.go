@@ -0,0 +1,165 @@
+package configfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseTOML parses key = value pairs, [table] headers, dotted keys, arrays
+// of scalars (which may span lines) and inline { key = value } tables
+func ParseTOML(data string) (Values, error) {
+	root := make(Values)
+	table := root
+
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(stripComment(strings.TrimRight(lines[i], "\r")))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", number)
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: expected [table]", number)
+			}
+			var err error
+			if table, err = tomlTable(root, strings.TrimSpace(line[1:len(line)-1])); err != nil {
+				return nil, fmt.Errorf("line %d: %w", number, err)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", number)
+		}
+		value = strings.TrimSpace(value)
+		// Arrays continue on the following lines until their bracket closes
+		for strings.HasPrefix(value, "[") && !balanced(value) && i+1 < len(lines) {
+			i++
+			value += " " + strings.TrimSpace(stripComment(strings.TrimRight(lines[i], "\r")))
+		}
+
+		parsed, err := tomlValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		if err := tomlSet(table, strings.TrimSpace(key), parsed); err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+	}
+	return root, nil
+}
+
+// tomlTable returns the table of a header, creating it
+func tomlTable(root Values, name string) (Values, error) {
+	table := root
+	for _, part := range strings.Split(name, ".") {
+		part, err := unquote(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if part == "" {
+			return nil, fmt.Errorf("invalid table name %q", name)
+		}
+		switch existing := table[part].(type) {
+		case nil:
+			next := make(Values)
+			table[part] = next
+			table = next
+		case Values:
+			table = existing
+		default:
+			return nil, fmt.Errorf("%q is already a value", part)
+		}
+	}
+	return table, nil
+}
+
+// tomlSet sets a possibly dotted key in a table
+func tomlSet(table Values, key string, value any) error {
+	parts := strings.Split(key, ".")
+	if len(parts) > 1 {
+		var err error
+		if table, err = tomlTable(table, strings.Join(parts[:len(parts)-1], ".")); err != nil {
+			return err
+		}
+	}
+	name, err := unquote(strings.TrimSpace(parts[len(parts)-1]))
+	if err != nil {
+		return err
+	}
+	return table.set(name, value)
+}
+
+// tomlValue parses the value of a key
+func tomlValue(value string) (any, error) {
+	switch {
+	case value == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''"):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case value[0] == '[':
+		if !strings.HasSuffix(value, "]") || !balanced(value) {
+			return nil, fmt.Errorf("unterminated array %s", value)
+		}
+		return inlineList(value)
+	case value[0] == '{':
+		if !strings.HasSuffix(value, "}") {
+			return nil, fmt.Errorf("unterminated inline table %s", value)
+		}
+		return tomlInlineTable(value[1 : len(value)-1])
+	}
+	return unquote(value)
+}
+
+// tomlInlineTable parses the key = value pairs of an inline table
+func tomlInlineTable(s string) (Values, error) {
+	items, err := splitList(s)
+	if err != nil {
+		return nil, err
+	}
+	table := make(Values)
+	for _, item := range items {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected key = value in inline table: %s", item)
+		}
+		parsed, err := tomlValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		if err := tomlSet(table, strings.TrimSpace(key), parsed); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// balanced reports whether the brackets of an array are closed, ignoring
+// those in strings
+func balanced(s string) bool {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth == 0
+}
@@ -0,0 +1,134 @@
+package configfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// yamlLine is a line of a YAML file without its comment and indentation
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// ParseYAML parses block mappings of scalars, inline [a, b] lists and
+// block lists of scalars, nested by indentation
+func ParseYAML(data string) (Values, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(data, "\n") {
+		raw = strings.TrimRight(stripComment(strings.TrimRight(raw, "\r")), " \t")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || text == "---" || text == "..." {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't indent YAML", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(raw) - len(text), text: text})
+	}
+
+	values, rest, err := yamlMapping(lines, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("line %d: unexpected indentation", rest[0].number)
+	}
+	return values, nil
+}
+
+// yamlMapping parses the keys indented by indent and returns the lines
+// after them
+func yamlMapping(lines []yamlLine, indent int) (Values, []yamlLine, error) {
+	values := make(Values)
+	for len(lines) > 0 && lines[0].indent == indent {
+		line := lines[0]
+		lines = lines[1:]
+		if strings.HasPrefix(line.text, "- ") || line.text == "-" {
+			return nil, nil, fmt.Errorf("line %d: list item where a key was expected", line.number)
+		}
+		key, value, ok := strings.Cut(line.text, ":")
+		if !ok || (value != "" && value[0] != ' ') {
+			return nil, nil, fmt.Errorf("line %d: expected key: value", line.number)
+		}
+		key, err := unquote(strings.TrimSpace(key))
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+
+		var parsed any
+		value = strings.TrimSpace(value)
+		switch {
+		case value != "":
+			if parsed, err = yamlValue(value); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+		case len(lines) > 0 && lines[0].indent > indent && strings.HasPrefix(lines[0].text, "-"):
+			parsed, lines, err = yamlList(lines, lines[0].indent)
+		case len(lines) > 0 && lines[0].indent > indent:
+			parsed, lines, err = yamlMapping(lines, lines[0].indent)
+		case len(lines) > 0 && lines[0].indent == indent && strings.HasPrefix(lines[0].text, "- "):
+			// Lists may start at the indentation of their key
+			parsed, lines, err = yamlList(lines, indent)
+		default:
+			// A key without a value is empty
+			parsed = ""
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := values.set(key, parsed); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+	}
+	if len(lines) > 0 && lines[0].indent > indent {
+		return nil, nil, fmt.Errorf("line %d: unexpected indentation", lines[0].number)
+	}
+	return values, lines, nil
+}
+
+// yamlList parses the "- item" lines indented by indent
+func yamlList(lines []yamlLine, indent int) ([]string, []yamlLine, error) {
+	var list []string
+	for len(lines) > 0 && lines[0].indent == indent && (strings.HasPrefix(lines[0].text, "- ") || lines[0].text == "-") {
+		line := lines[0]
+		lines = lines[1:]
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if item == "" || strings.HasPrefix(item, "[") || strings.HasPrefix(item, "{") || strings.HasPrefix(item, "- ") {
+			return nil, nil, fmt.Errorf("line %d: list items must be scalars", line.number)
+		}
+		if !strings.HasPrefix(item, "\"") && !strings.HasPrefix(item, "'") && strings.Contains(item, ": ") {
+			return nil, nil, fmt.Errorf("line %d: list items must be scalars", line.number)
+		}
+		value, err := unquote(item)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		list = append(list, value)
+	}
+	if len(lines) > 0 && lines[0].indent > indent {
+		return nil, nil, fmt.Errorf("line %d: unexpected indentation", lines[0].number)
+	}
+	return list, lines, nil
+}
+
+// yamlValue parses the value after a key
+func yamlValue(value string) (any, error) {
+	switch {
+	case value == "|" || value == ">" || strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case value[0] == '&' || value[0] == '*' || value[0] == '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	case value == "{}":
+		return Values{}, nil
+	case value[0] == '{':
+		return nil, fmt.Errorf("inline mappings are not supported; indent the keys on their own lines")
+	case value[0] == '[':
+		if value[len(value)-1] != ']' {
+			return nil, fmt.Errorf("unterminated list %s", value)
+		}
+		return inlineList(value)
+	}
+	return unquote(value)
+}
@@ -0,0 +1,236 @@
+// Package coord lets several instances backing up the same Dropbox account
+// coordinate through a shared state backend: a directory on a network share
+// or an HTTP object store. Instances take short-lived locks around phases
+// that must not overlap and publish their run status for each other.
+package coord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by Backend.Get for missing keys
+	ErrNotFound = errors.New("state object not found")
+	// ErrExists is returned by Backend.Create when the key is already taken
+	ErrExists = errors.New("state object already exists")
+	// ErrChanged is returned by Backend.Replace when the object is no longer
+	// the version read before
+	ErrChanged = errors.New("state object changed meanwhile")
+	// ErrLocked is returned by TryLock when another instance holds the lock
+	ErrLocked = errors.New("lock held by another instance")
+)
+
+// Backend stores small objects shared between instances
+type Backend interface {
+	// Create writes data to key only if the key doesn't exist yet
+	Create(ctx context.Context, key string, data []byte) error
+	// Put writes data to key, replacing any existing object
+	Put(ctx context.Context, key string, data []byte) error
+	// Get reads the object at key along with its version, which changes
+	// whenever the object does
+	Get(ctx context.Context, key string) (data []byte, version string, err error)
+	// Replace writes data to key only if the object there is still at
+	// version, in one step, so of several writers only one succeeds
+	Replace(ctx context.Context, key string, data []byte, version string) error
+	// Delete removes the object at key; missing keys are not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Open returns the backend for a location: an http:// or https:// base URL,
+// or a directory (optionally as a file:// URL). An empty location disables
+// coordination and returns nil.
+func Open(location string) (Backend, error) {
+	switch {
+	case location == "":
+		return nil, nil
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		return NewHTTPBackend(location, os.Getenv("DROPBOX_STATE_TOKEN")), nil
+	case strings.HasPrefix(location, "file://"):
+		return NewFileBackend(strings.TrimPrefix(location, "file://"))
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("unsupported state backend: %s (must be a directory or http(s) URL)", location)
+	default:
+		return NewFileBackend(location)
+	}
+}
+
+// Lock is the content of a lock object. Process tells apart the processes of
+// one instance, e.g. a daemon and a manual run on the same host.
+type Lock struct {
+	Owner    string    `json:"owner"`
+	Process  string    `json:"process"`
+	Host     string    `json:"host"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Status is what an instance publishes about its last run
+type Status struct {
+	Instance  string    `json:"instance"`
+	Host      string    `json:"host"`
+	BackupDir string    `json:"backup_dir"`
+	LastRun   time.Time `json:"last_run"`
+	Success   bool      `json:"success"`
+	Files     int       `json:"files"`
+	Bytes     uint64    `json:"bytes"`
+}
+
+// statusKey holds the statuses of all instances
+const statusKey = "instances.json"
+
+// statusLockTTL bounds how long a crashed instance can block status updates
+const statusLockTTL = time.Minute
+
+// processNonce identifies this process in the locks it takes
+var processNonce = newNonce()
+
+// newNonce returns a random identifier
+func newNonce() string {
+	return rand.Text()
+}
+
+// Coordinator takes locks and publishes status for one instance
+type Coordinator struct {
+	backend  Backend
+	instance string
+	process  string
+	host     string
+	now      func() time.Time
+}
+
+// New creates a coordinator for the named instance
+func New(backend Backend, instance string) *Coordinator {
+	host, _ := os.Hostname()
+	return &Coordinator{backend: backend, instance: instance, process: processNonce, host: host, now: time.Now}
+}
+
+// Instance returns the name of this instance
+func (c *Coordinator) Instance() string {
+	return c.instance
+}
+
+// TryLock takes the named lock for ttl without waiting. It returns an error
+// wrapping ErrLocked if another instance, or another process of this one,
+// holds an unexpired lock. Locks left behind by crashed processes expire
+// after their ttl.
+func (c *Coordinator) TryLock(ctx context.Context, name string, ttl time.Duration) (release func(), err error) {
+	key := "locks/" + name + ".json"
+	now := c.now()
+	data, err := json.Marshal(Lock{Owner: c.instance, Process: c.process, Host: c.host, Acquired: now, Expires: now.Add(ttl)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	err = c.backend.Create(ctx, key, data)
+	if errors.Is(err, ErrExists) {
+		err = c.takeOver(ctx, name, key, data, now)
+	}
+	if errors.Is(err, ErrLocked) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take lock %s: %w", name, err)
+	}
+
+	return func() {
+		// Use a fresh context so the lock is released even after cancellation
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if held, _, err := c.readLock(ctx, key); err != nil || !c.owns(held) {
+			return // Expired and taken over
+		}
+		_ = c.backend.Delete(ctx, key)
+	}, nil
+}
+
+// takeOver replaces an existing lock object with data if it expired or this
+// process left it behind. The object is replaced only if it is still the one
+// read, so of several instances seeing the same stale lock only one takes it.
+func (c *Coordinator) takeOver(ctx context.Context, name, key string, data []byte, now time.Time) error {
+	held, version, err := c.readLock(ctx, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// Released meanwhile
+		err = c.backend.Create(ctx, key, data)
+	case err != nil:
+		return err
+	case !c.owns(held) && now.Before(held.Expires):
+		return fmt.Errorf("%w: %s is held by %s on %s until %s", ErrLocked, name, held.Owner, held.Host, held.Expires.Format(time.RFC3339))
+	default:
+		// Expired, or left behind by this process
+		err = c.backend.Replace(ctx, key, data, version)
+	}
+	if errors.Is(err, ErrExists) || errors.Is(err, ErrChanged) {
+		return fmt.Errorf("%w: %s was taken by another instance", ErrLocked, name)
+	}
+	return err
+}
+
+// owns reports whether a lock was taken by this process
+func (c *Coordinator) owns(lock Lock) bool {
+	return lock.Owner == c.instance && lock.Process == c.process
+}
+
+// readLock reads a lock object and its version
+func (c *Coordinator) readLock(ctx context.Context, key string) (Lock, string, error) {
+	data, version, err := c.backend.Get(ctx, key)
+	if err != nil {
+		return Lock{}, "", err
+	}
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return Lock{}, "", fmt.Errorf("failed to parse lock %s: %w", key, err)
+	}
+	return lock, version, nil
+}
+
+// Publish records this instance's status next to those of the others
+func (c *Coordinator) Publish(ctx context.Context, status Status) error {
+	status.Instance = c.instance
+	status.Host = c.host
+
+	release, err := c.TryLock(ctx, "status", statusLockTTL)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	statuses, err := c.Instances(ctx)
+	if err != nil {
+		return err
+	}
+	statuses[c.instance] = status
+
+	data, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode instance status: %w", err)
+	}
+	if err := c.backend.Put(ctx, statusKey, data); err != nil {
+		return fmt.Errorf("failed to publish instance status: %w", err)
+	}
+	return nil
+}
+
+// Instances returns the last published status of every instance by name
+func (c *Coordinator) Instances(ctx context.Context) (map[string]Status, error) {
+	statuses := make(map[string]Status)
+
+	data, _, err := c.backend.Get(ctx, statusKey)
+	if errors.Is(err, ErrNotFound) {
+		return statuses, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instance status: %w", err)
+	}
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to parse instance status: %w", err)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,212 @@
+package coord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCoordinator(t *testing.T, backend Backend, instance string, now *time.Time) *Coordinator {
+	t.Helper()
+	c := New(backend, instance)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestTryLock(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	nas := newTestCoordinator(t, backend, "nas", &now)
+	offsite := newTestCoordinator(t, backend, "offsite", &now)
+
+	release, err := nas.TryLock(ctx, "delete", time.Hour)
+	if err != nil {
+		t.Fatalf("TryLock() error = %v", err)
+	}
+
+	// Held by the other instance
+	if _, err := offsite.TryLock(ctx, "delete", time.Hour); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock() while held error = %v, want ErrLocked", err)
+	}
+
+	// Released locks can be taken
+	release()
+	releaseOffsite, err := offsite.TryLock(ctx, "delete", time.Hour)
+	if err != nil {
+		t.Fatalf("TryLock() after release error = %v", err)
+	}
+
+	// Expired locks are taken over, and the old owner's release keeps the new lock
+	now = now.Add(2 * time.Hour)
+	release, err = nas.TryLock(ctx, "delete", time.Hour)
+	if err != nil {
+		t.Fatalf("TryLock() after expiry error = %v", err)
+	}
+	releaseOffsite()
+	if _, err := offsite.TryLock(ctx, "delete", time.Hour); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock() after stale release error = %v, want ErrLocked", err)
+	}
+	release()
+}
+
+func TestTryLockOwnStaleLock(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c := newTestCoordinator(t, backend, "nas", &now)
+
+	// An earlier run of this process left its lock behind
+	if _, err := c.TryLock(ctx, "delete", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.TryLock(ctx, "delete", time.Hour); err != nil {
+		t.Errorf("TryLock() on own lock error = %v", err)
+	}
+}
+
+func TestTryLockOtherProcess(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	daemon := newTestCoordinator(t, backend, "nas", &now)
+	manual := newTestCoordinator(t, backend, "nas", &now)
+	manual.process = newNonce()
+
+	release, err := daemon.TryLock(ctx, "delete", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manual.TryLock(ctx, "delete", time.Hour); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock() by another process of the instance error = %v, want ErrLocked", err)
+	}
+
+	// A stale release of another process keeps the lock
+	now = now.Add(2 * time.Hour)
+	releaseManual, err := manual.TryLock(ctx, "delete", time.Hour)
+	if err != nil {
+		t.Fatalf("TryLock() after expiry error = %v", err)
+	}
+	release()
+	if _, err := daemon.TryLock(ctx, "delete", time.Hour); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock() after stale release error = %v, want ErrLocked", err)
+	}
+	releaseManual()
+}
+
+func TestTryLockConcurrentTakeover(t *testing.T) {
+	server := objectStore(t, "")
+	defer server.Close()
+	fileBackend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := map[string]Backend{
+		"file": fileBackend,
+		"http": NewHTTPBackend(server.URL, ""),
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			if _, err := newTestCoordinator(t, backend, "crashed", &now).TryLock(ctx, "delete", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			// Every instance sees the expired lock, only one may take it over
+			later := now.Add(time.Hour)
+			var wg sync.WaitGroup
+			var taken atomic.Int32
+			for i := range 8 {
+				c := newTestCoordinator(t, backend, fmt.Sprintf("instance%d", i), &later)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := c.TryLock(ctx, "delete", time.Hour)
+					switch {
+					case err == nil:
+						taken.Add(1)
+					case !errors.Is(err, ErrLocked):
+						t.Errorf("TryLock() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			if got := taken.Load(); got != 1 {
+				t.Errorf("lock taken over %d times, want 1", got)
+			}
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	nas := newTestCoordinator(t, backend, "nas", &now)
+	offsite := newTestCoordinator(t, backend, "offsite", &now)
+
+	if err := nas.Publish(ctx, Status{Success: true, Files: 3}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := offsite.Publish(ctx, Status{Files: 5}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	statuses, err := nas.Instances(ctx)
+	if err != nil {
+		t.Fatalf("Instances() error = %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Instances() = %d entries, want 2", len(statuses))
+	}
+	if got := statuses["nas"]; !got.Success || got.Files != 3 || got.Instance != "nas" {
+		t.Errorf("nas status = %+v", got)
+	}
+	if got := statuses["offsite"]; got.Files != 5 {
+		t.Errorf("offsite status = %+v", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		location string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{location: "", wantNil: true},
+		{location: t.TempDir()},
+		{location: "file://" + t.TempDir()},
+		{location: "https://state.example.com/dropbox"},
+		{location: "s3://bucket/key", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			backend, err := Open(tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (backend == nil) != tt.wantNil {
+				t.Errorf("Open() backend = %v, wantNil %v", backend, tt.wantNil)
+			}
+		})
+	}
+}
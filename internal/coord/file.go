@@ -0,0 +1,148 @@
+package coord
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileBackend keeps state objects as files in a directory, e.g. on a
+// network share mounted by every instance
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend creates a backend storing objects under dir
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+// path maps a key to its file
+func (b *FileBackend) path(key string) (string, error) {
+	path := filepath.Join(b.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return path, nil
+}
+
+// Create implements Backend using an exclusive create
+func (b *FileBackend) Create(ctx context.Context, key string, data []byte) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return ErrExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return f.Close()
+}
+
+// Put implements Backend, replacing the file atomically
+func (b *FileBackend) Put(ctx context.Context, key string, data []byte) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// Replace implements Backend by renaming the file aside, which only one
+// instance can do, and creating the new one once the moved file turns out to
+// be the expected version
+func (b *FileBackend) Replace(ctx context.Context, key string, data []byte, version string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	aside, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.replaced")
+	if err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	aside.Close()
+	defer os.Remove(aside.Name())
+
+	if err := os.Rename(path, aside.Name()); errors.Is(err, os.ErrNotExist) {
+		return ErrChanged
+	} else if err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	old, err := os.ReadFile(aside.Name())
+	if err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	if fileVersion(old) != version {
+		// Another instance replaced it first: put its file back unless
+		// yet another one was created meanwhile
+		_ = os.Link(aside.Name(), path)
+		return ErrChanged
+	}
+	if err := b.Create(ctx, key, data); errors.Is(err, ErrExists) {
+		return ErrChanged
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get implements Backend; the version is the hash of the content
+func (b *FileBackend) Get(ctx context.Context, key string) ([]byte, string, error) {
+	data, err := os.ReadFile(filepath.Join(b.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read state file: %w", err)
+	}
+	return data, fileVersion(data), nil
+}
+
+// fileVersion returns the version of a state file's content
+func fileVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Delete implements Backend
+func (b *FileBackend) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(b.dir, filepath.FromSlash(key)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete state file: %w", err)
+	}
+	return nil
+}
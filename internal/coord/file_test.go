@@ -0,0 +1,47 @@
+package coord
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFileBackend(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := b.Get(ctx, "locks/a.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() missing error = %v, want ErrNotFound", err)
+	}
+	if err := b.Create(ctx, "locks/a.json", []byte("1")); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := b.Create(ctx, "locks/a.json", []byte("2")); !errors.Is(err, ErrExists) {
+		t.Errorf("Create() existing error = %v, want ErrExists", err)
+	}
+	if err := b.Put(ctx, "locks/a.json", []byte("3")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	data, version, err := b.Get(ctx, "locks/a.json")
+	if err != nil || string(data) != "3" {
+		t.Errorf("Get() = %q, %v, want 3", data, err)
+	}
+	if err := b.Replace(ctx, "locks/a.json", []byte("4"), version); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if err := b.Replace(ctx, "locks/a.json", []byte("5"), version); !errors.Is(err, ErrChanged) {
+		t.Errorf("Replace() outdated error = %v, want ErrChanged", err)
+	}
+	if data, _, err := b.Get(ctx, "locks/a.json"); err != nil || string(data) != "4" {
+		t.Errorf("Get() after Replace() = %q, %v, want 4", data, err)
+	}
+	if err := b.Delete(ctx, "locks/a.json"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := b.Delete(ctx, "locks/a.json"); err != nil {
+		t.Errorf("Delete() missing error = %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package coord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPBackend keeps state objects in an HTTP object store that supports
+// PUT, GET and DELETE, conditional creation with "If-None-Match: *" and
+// conditional replacement with ETags and "If-Match", such as an
+// S3-compatible bucket or a WebDAV share
+type HTTPBackend struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPBackend creates a backend storing objects below baseURL. A non-empty
+// token is sent as a bearer token.
+func NewHTTPBackend(baseURL, token string) *HTTPBackend {
+	return &HTTPBackend{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// response is the part of an HTTP response the backend looks at
+type response struct {
+	data   []byte
+	status int
+	etag   string
+}
+
+// do sends a request for a key and returns the response
+func (b *HTTPBackend) do(ctx context.Context, method, key string, body []byte, header http.Header) (response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return response{}, fmt.Errorf("failed to create state request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return response{}, fmt.Errorf("failed to %s state object %s: %w", method, key, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response{}, fmt.Errorf("failed to read state object %s: %w", key, err)
+	}
+	return response{data: data, status: resp.StatusCode, etag: resp.Header.Get("ETag")}, nil
+}
+
+// Create implements Backend with a conditional PUT
+func (b *HTTPBackend) Create(ctx context.Context, key string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, key, data, http.Header{"If-None-Match": {"*"}})
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.status == http.StatusPreconditionFailed, resp.status == http.StatusConflict:
+		return ErrExists
+	case resp.status >= 300:
+		return fmt.Errorf("failed to create state object %s: HTTP %d", key, resp.status)
+	}
+	return nil
+}
+
+// Put implements Backend
+func (b *HTTPBackend) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, key, data, nil)
+	if err != nil {
+		return err
+	}
+	if resp.status >= 300 {
+		return fmt.Errorf("failed to write state object %s: HTTP %d", key, resp.status)
+	}
+	return nil
+}
+
+// Replace implements Backend with a PUT conditional on the ETag
+func (b *HTTPBackend) Replace(ctx context.Context, key string, data []byte, version string) error {
+	if version == "" {
+		return fmt.Errorf("failed to replace state object %s: the store sent no ETag", key)
+	}
+	resp, err := b.do(ctx, http.MethodPut, key, data, http.Header{"If-Match": {version}})
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.status == http.StatusPreconditionFailed, resp.status == http.StatusConflict, resp.status == http.StatusNotFound:
+		return ErrChanged
+	case resp.status >= 300:
+		return fmt.Errorf("failed to replace state object %s: HTTP %d", key, resp.status)
+	}
+	return nil
+}
+
+// Get implements Backend; the version is the ETag
+func (b *HTTPBackend) Get(ctx context.Context, key string) ([]byte, string, error) {
+	resp, err := b.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case resp.status == http.StatusNotFound:
+		return nil, "", ErrNotFound
+	case resp.status >= 300:
+		return nil, "", fmt.Errorf("failed to read state object %s: HTTP %d", key, resp.status)
+	}
+	return resp.data, resp.etag, nil
+}
+
+// Delete implements Backend
+func (b *HTTPBackend) Delete(ctx context.Context, key string) error {
+	resp, err := b.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.status >= 300 && resp.status != http.StatusNotFound {
+		return fmt.Errorf("failed to delete state object %s: HTTP %d", key, resp.status)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package coord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// objectStore is a minimal in-memory HTTP object store
+func objectStore(t *testing.T, token string) *httptest.Server {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	etags := make(map[string]string)
+	writes := 0
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			_, exists := objects[r.URL.Path]
+			if exists && r.Header.Get("If-None-Match") == "*" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			if match := r.Header.Get("If-Match"); match != "" && (!exists || etags[r.URL.Path] != match) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			writes++
+			objects[r.URL.Path] = data
+			etags[r.URL.Path] = fmt.Sprintf(`"%d"`, writes)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("ETag", etags[r.URL.Path])
+			_, _ = w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			delete(etags, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestHTTPBackend(t *testing.T) {
+	ctx := context.Background()
+	server := objectStore(t, "secret")
+	defer server.Close()
+
+	b := NewHTTPBackend(server.URL+"/state/", "secret")
+
+	if _, _, err := b.Get(ctx, "a.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() missing error = %v, want ErrNotFound", err)
+	}
+	if err := b.Create(ctx, "a.json", []byte("1")); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := b.Create(ctx, "a.json", []byte("2")); !errors.Is(err, ErrExists) {
+		t.Errorf("Create() existing error = %v, want ErrExists", err)
+	}
+	if err := b.Put(ctx, "a.json", []byte("3")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	data, version, err := b.Get(ctx, "a.json")
+	if err != nil || string(data) != "3" {
+		t.Errorf("Get() = %q, %v, want 3", data, err)
+	}
+	if err := b.Replace(ctx, "a.json", []byte("4"), version); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if err := b.Replace(ctx, "a.json", []byte("5"), version); !errors.Is(err, ErrChanged) {
+		t.Errorf("Replace() outdated error = %v, want ErrChanged", err)
+	}
+	if data, _, err := b.Get(ctx, "a.json"); err != nil || string(data) != "4" {
+		t.Errorf("Get() after Replace() = %q, %v, want 4", data, err)
+	}
+	if err := b.Delete(ctx, "a.json"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := b.Delete(ctx, "a.json"); err != nil {
+		t.Errorf("Delete() missing error = %v", err)
+	}
+
+	unauthorized := NewHTTPBackend(server.URL, "wrong")
+	if _, _, err := unauthorized.Get(ctx, "a.json"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() with wrong token error = %v, want HTTP error", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package desktop shows the progress of a run outside its log output: in the
+// title of the terminal and as a desktop notification when it ends.
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// appName names the run in titles and notifications
+const appName = "Dropbox backup"
+
+// ProgressTitle returns the terminal title for a download phase: the percent
+// complete and, once the throughput is known, the time left
+func ProgressTitle(status transfer.EstimateStatus) string {
+	if status.TotalBytes == 0 {
+		return appName
+	}
+	title := fmt.Sprintf("%s %d%%", appName, status.DoneBytes*100/status.TotalBytes)
+	if status.BytesPerSecond > 0 {
+		title += fmt.Sprintf(" (%s left)", status.Remaining.Round(time.Second))
+	}
+	return title
+}
+
+// StatusTitle returns the terminal title for a phase without a percentage,
+// e.g. "listing"
+func StatusTitle(status string) string {
+	return appName + ": " + status
+}
+
+// SetTitle sets the title of the terminal f writes to; nothing is written
+// if f isn't a terminal, so redirected output stays clean
+func SetTitle(f *os.File, title string) {
+	if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	writeTitle(f, title)
+}
+
+// writeTitle writes the escape sequence setting a terminal title, which most
+// terminals including Windows Terminal understand
+func writeTitle(w io.Writer, title string) {
+	io.WriteString(w, "\x1b]0;"+stripControl(title)+"\a")
+}
+
+// stripControl removes control characters, which could end the escape
+// sequence early
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Notify shows a desktop notification: with notify-send on Linux and BSD,
+// osascript on macOS and a PowerShell toast on Windows
+func Notify(ctx context.Context, title, message string) error {
+	name, args, ok := notifyCommand(runtime.GOOS, title, message)
+	if !ok {
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	if err := exec.CommandContext(ctx, name, args...).Run(); err != nil {
+		return fmt.Errorf("failed to show desktop notification: %w", err)
+	}
+	return nil
+}
+
+// powerShellAppID is the application the toast is shown for; Windows only
+// shows toasts of registered applications
+const powerShellAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// notifyCommand returns the command showing a notification on an OS
+func notifyCommand(goos, title, message string) (string, []string, bool) {
+	switch goos {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return "notify-send", []string{"--app-name", appName, title, message}, true
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		return "osascript", []string{"-e", script}, true
+	case "windows":
+		script := strings.Join([]string{
+			"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null",
+			"$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)",
+			"$text = $xml.GetElementsByTagName('text')",
+			"$text[0].AppendChild($xml.CreateTextNode(" + powerShellString(title) + ")) > $null",
+			"$text[1].AppendChild($xml.CreateTextNode(" + powerShellString(message) + ")) > $null",
+			"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier(" + powerShellString(powerShellAppID) + ").Show([Windows.UI.Notifications.ToastNotification]::new($xml))",
+		}, "; ")
+		return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", script}, true
+	}
+	return "", nil, false
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(stripControl(s)) + `"`
+}
+
+// powerShellString quotes s as a PowerShell string literal, in which nothing
+// is expanded
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(stripControl(s), "'", "''") + "'"
+}
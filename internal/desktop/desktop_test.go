@@ -0,0 +1,70 @@
+package desktop
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestProgressTitle(t *testing.T) {
+	tests := []struct {
+		name   string
+		status transfer.EstimateStatus
+		want   string
+	}{
+		{name: "nothing to download", want: "Dropbox backup"},
+		{name: "throughput unknown", status: transfer.EstimateStatus{TotalBytes: 400, DoneBytes: 100}, want: "Dropbox backup 25%"},
+		{
+			name:   "with time left",
+			status: transfer.EstimateStatus{TotalBytes: 400, DoneBytes: 300, BytesPerSecond: 1, Remaining: 100*time.Second + 300*time.Millisecond},
+			want:   "Dropbox backup 75% (1m40s left)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProgressTitle(tt.status); got != tt.want {
+				t.Errorf("ProgressTitle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTitle(t *testing.T) {
+	var buf bytes.Buffer
+	writeTitle(&buf, "Dropbox backup 5%\a\x1b]0;evil")
+	if want := "\x1b]0;Dropbox backup 5%]0;evil\a"; buf.String() != want {
+		t.Errorf("writeTitle() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestNotifyCommand(t *testing.T) {
+	tests := []struct {
+		goos     string
+		wantName string
+		wantArg  string
+	}{
+		{goos: "linux", wantName: "notify-send", wantArg: `Backup "done"`},
+		{goos: "darwin", wantName: "osascript", wantArg: `display notification "it's \"ok\"" with title "Backup \"done\""`},
+		{goos: "windows", wantName: "powershell", wantArg: `CreateTextNode('it''s "ok"')`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			name, args, ok := notifyCommand(tt.goos, `Backup "done"`, `it's "ok"`)
+			if !ok || name != tt.wantName {
+				t.Fatalf("notifyCommand() = %q, %v; want %q", name, ok, tt.wantName)
+			}
+			if joined := strings.Join(args, " "); !strings.Contains(joined, tt.wantArg) {
+				t.Errorf("notifyCommand() args = %q, want %q in them", joined, tt.wantArg)
+			}
+		})
+	}
+
+	if _, _, ok := notifyCommand("plan9", "a", "b"); ok {
+		t.Error("notifyCommand() supports plan9")
+	}
+}
@@ -0,0 +1,141 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/file_requests"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/team"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/team_policies"
+)
+
+// FileRequest describes a file request owned by the account
+type FileRequest struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Destination string     `json:"destination,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Created     time.Time  `json:"created"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	IsOpen      bool       `json:"is_open"`
+	FileCount   int64      `json:"file_count"`
+}
+
+// LinkedApp describes a third-party app connected to the account
+type LinkedApp struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Publisher    string     `json:"publisher,omitempty"`
+	PublisherURL string     `json:"publisher_url,omitempty"`
+	Linked       *time.Time `json:"linked,omitempty"`
+	AppFolder    bool       `json:"app_folder"`
+}
+
+// SharingPolicies are the sharing policies of the account's team, given by
+// their Dropbox policy tags (e.g. "team" or "anyone")
+type SharingPolicies struct {
+	SharedFolderMember string `json:"shared_folder_member_policy,omitempty"`
+	SharedFolderJoin   string `json:"shared_folder_join_policy,omitempty"`
+	SharedLinkCreate   string `json:"shared_link_create_policy,omitempty"`
+}
+
+// fileRequestPageSize is the number of file requests fetched per call
+const fileRequestPageSize = 1000
+
+// FileRequests lists all file requests owned by the account, open and
+// closed. It needs the file_requests.read scope.
+func (c *Client) FileRequests(ctx context.Context) ([]FileRequest, error) {
+	var page *file_requests.ListFileRequestsV2Result
+	err := c.guard(ctx, OpAccount, func() (err error) {
+		page, err = c.requests.ListV2(&file_requests.ListFileRequestsArg{Limit: fileRequestPageSize})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file requests: %w", err)
+	}
+
+	var requests []FileRequest
+	for {
+		for _, request := range page.FileRequests {
+			requests = append(requests, fileRequest(request))
+		}
+		if !page.HasMore {
+			return requests, nil
+		}
+
+		cursor := page.Cursor
+		err := c.guard(ctx, OpAccount, func() (err error) {
+			page, err = c.requests.ListContinue(file_requests.NewListFileRequestsContinueArg(cursor))
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list file requests: %w", err)
+		}
+	}
+}
+
+// LinkedApps lists the third-party apps a team member has connected. Dropbox
+// only offers this through its team API, which needs a token with team
+// admin access (sessions.list scope); user tokens get an error.
+func (c *Client) LinkedApps(ctx context.Context, teamMemberID string) ([]LinkedApp, error) {
+	var res *team.ListMemberAppsResult
+	err := c.guard(ctx, OpAccount, func() (err error) {
+		res, err = c.team.LinkedAppsListMemberLinkedApps(team.NewListMemberAppsArg(teamMemberID))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list linked apps: %w", err)
+	}
+
+	apps := make([]LinkedApp, 0, len(res.LinkedApiApps))
+	for _, app := range res.LinkedApiApps {
+		apps = append(apps, LinkedApp{
+			ID:           app.AppId,
+			Name:         app.AppName,
+			Publisher:    app.Publisher,
+			PublisherURL: app.PublisherUrl,
+			Linked:       app.Linked,
+			AppFolder:    app.IsAppFolder,
+		})
+	}
+	return apps, nil
+}
+
+// fileRequest converts a file request returned by the API
+func fileRequest(request *file_requests.FileRequest) FileRequest {
+	converted := FileRequest{
+		ID:          request.Id,
+		Title:       request.Title,
+		URL:         request.Url,
+		Destination: request.Destination,
+		Description: request.Description,
+		Created:     request.Created,
+		IsOpen:      request.IsOpen,
+		FileCount:   request.FileCount,
+	}
+	if request.Deadline != nil {
+		deadline := request.Deadline.Deadline
+		converted.Deadline = &deadline
+	}
+	return converted
+}
+
+// sharingPolicies converts a team's sharing policies to their tags
+func sharingPolicies(policies *team_policies.TeamSharingPolicies) *SharingPolicies {
+	if policies == nil {
+		return nil
+	}
+	converted := &SharingPolicies{}
+	if policies.SharedFolderMemberPolicy != nil {
+		converted.SharedFolderMember = policies.SharedFolderMemberPolicy.Tag
+	}
+	if policies.SharedFolderJoinPolicy != nil {
+		converted.SharedFolderJoin = policies.SharedFolderJoinPolicy.Tag
+	}
+	if policies.SharedLinkCreatePolicy != nil {
+		converted.SharedLinkCreate = policies.SharedLinkCreatePolicy.Tag
+	}
+	return converted
+}
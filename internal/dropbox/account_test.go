@@ -0,0 +1,51 @@
+package dropbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/file_requests"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/team_policies"
+)
+
+func TestFileRequest(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	deadline := created.AddDate(0, 1, 0)
+
+	got := fileRequest(&file_requests.FileRequest{
+		Id:          "oaCAVmEyrqYnkZX9955Y",
+		Title:       "Invoices",
+		Url:         "https://www.dropbox.com/request/oaCAVmEyrqYnkZX9955Y",
+		Destination: "/File Requests/Invoices",
+		Created:     created,
+		Deadline:    &file_requests.FileRequestDeadline{Deadline: deadline},
+		IsOpen:      true,
+		FileCount:   3,
+	})
+	if got.ID != "oaCAVmEyrqYnkZX9955Y" || got.Destination != "/File Requests/Invoices" || !got.IsOpen || got.FileCount != 3 {
+		t.Errorf("fileRequest() = %+v", got)
+	}
+	if got.Deadline == nil || !got.Deadline.Equal(deadline) {
+		t.Errorf("fileRequest() deadline = %v, want %v", got.Deadline, deadline)
+	}
+
+	if got := fileRequest(&file_requests.FileRequest{Id: "x"}); got.Deadline != nil {
+		t.Errorf("fileRequest() without deadline = %v, want nil", got.Deadline)
+	}
+}
+
+func TestSharingPolicies(t *testing.T) {
+	if got := sharingPolicies(nil); got != nil {
+		t.Errorf("sharingPolicies(nil) = %+v, want nil", got)
+	}
+
+	got := sharingPolicies(&team_policies.TeamSharingPolicies{
+		SharedFolderMemberPolicy: &team_policies.SharedFolderMemberPolicy{Tagged: dropbox.Tagged{Tag: "team"}},
+		SharedLinkCreatePolicy:   &team_policies.SharedLinkCreatePolicy{Tagged: dropbox.Tagged{Tag: "team_only"}},
+	})
+	want := SharingPolicies{SharedFolderMember: "team", SharedLinkCreate: "team_only"}
+	if got == nil || *got != want {
+		t.Errorf("sharingPolicies() = %+v, want %+v", got, want)
+	}
+}
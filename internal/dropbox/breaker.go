@@ -0,0 +1,153 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+// Circuit breaker defaults: trip after this many consecutive server failures
+// and cool down before letting a single probe request through
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops all workers from calling the API while Dropbox returns
+// sustained 5xx or timeout errors. While open, callers wait for the cool-down
+// instead of failing, then one probe request decides whether to close again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openUntil time.Time
+	changed   chan struct{}
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		changed:   make(chan struct{}),
+	}
+}
+
+// acquire blocks until a request may be sent
+func (b *circuitBreaker) acquire(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := b.now()
+		state := b.state
+
+		if state == breakerClosed {
+			b.mu.Unlock()
+			return nil
+		}
+		if state == breakerOpen && !now.Before(b.openUntil) {
+			// This caller becomes the probe; everyone else waits for its result
+			b.setState(breakerHalfOpen)
+			b.mu.Unlock()
+			return nil
+		}
+
+		changed := b.changed
+		var timer *time.Timer
+		var cooldown <-chan time.Time
+		if state == breakerOpen {
+			timer = time.NewTimer(b.openUntil.Sub(now))
+			cooldown = timer.C
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-cooldown:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// record updates the breaker with the outcome of a request
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isServerFailure(err) {
+		if b.state != breakerClosed {
+			slog.Info("Dropbox API recovered, closing circuit breaker")
+		}
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.setState(breakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+		slog.Warn("Dropbox API is failing, pausing requests",
+			slog.Int("consecutive_failures", b.failures),
+			slog.Duration("cooldown", b.cooldown),
+			slog.String("error", err.Error()),
+		)
+		b.setState(breakerOpen)
+	}
+}
+
+// setState changes state and wakes up waiting callers. Callers hold b.mu.
+func (b *circuitBreaker) setState(state breakerState) {
+	b.state = state
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// isServerFailure reports whether err indicates Dropbox itself is unhealthy
+// (5xx responses or timeouts) rather than a problem with the request
+func isServerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var serverErr auth.ServerError
+	if errors.As(err, &serverErr) {
+		return true
+	}
+
+	var sdkErr dropbox.SDKInternalError
+	if errors.As(err, &sdkErr) && sdkErr.StatusCode >= 500 {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,105 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+func TestIsServerFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"server error", auth.ServerError{}, true},
+		{"internal 503", dropbox.SDKInternalError{StatusCode: 503}, true},
+		{"internal 404", dropbox.SDKInternalError{StatusCode: 404}, false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"path error", errors.New("path/not_found/"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isServerFailure(tt.err); got != tt.want {
+				t.Errorf("isServerFailure(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	b := newCircuitBreaker(3, 20*time.Millisecond)
+	ctx := context.Background()
+
+	// Failures below the threshold keep the circuit closed
+	b.record(auth.ServerError{})
+	b.record(auth.ServerError{})
+	if b.state != breakerClosed {
+		t.Fatalf("state = %v after 2 failures, want closed", b.state)
+	}
+
+	b.record(auth.ServerError{})
+	if b.state != breakerOpen {
+		t.Fatalf("state = %v after 3 failures, want open", b.state)
+	}
+
+	// While open, callers wait out the cool-down
+	start := time.Now()
+	if err := b.acquire(ctx); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("acquire() returned after %v, want to wait for the cool-down", elapsed)
+	}
+	if b.state != breakerHalfOpen {
+		t.Fatalf("state = %v after cool-down, want half-open", b.state)
+	}
+
+	// A successful probe closes the circuit
+	b.record(nil)
+	if b.state != breakerClosed {
+		t.Errorf("state = %v after successful probe, want closed", b.state)
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	b := newCircuitBreaker(1, time.Millisecond)
+	ctx := context.Background()
+
+	b.record(auth.ServerError{})
+	if err := b.acquire(ctx); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	b.record(auth.ServerError{})
+	if b.state != breakerOpen {
+		t.Errorf("state = %v after failed probe, want open", b.state)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	b := newCircuitBreaker(1, time.Hour)
+
+	b.record(errors.New("path/not_found/"))
+	if b.state != breakerClosed {
+		t.Errorf("state = %v after client error, want closed", b.state)
+	}
+}
+
+func TestCircuitBreakerAcquireHonorsCancellation(t *testing.T) {
+	b := newCircuitBreaker(1, time.Hour)
+	b.record(auth.ServerError{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := b.acquire(ctx); err == nil {
+		t.Error("acquire() expected error for cancelled context")
+	}
+}
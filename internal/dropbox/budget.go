@@ -0,0 +1,10 @@
+package dropbox
+
+import "create-dropbox-backup-folder/internal/budget"
+
+// SetBudget caps the number of API calls, shared with the clients of other
+// namespaces derived from this one. Calls beyond the budget fail with
+// budget.ErrExhausted and aren't retried.
+func (c *Client) SetBudget(b *budget.Budget) {
+	c.budget = b
+}
@@ -0,0 +1,38 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/budget"
+	"create-dropbox-backup-folder/internal/retry"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+func TestGuardBudget(t *testing.T) {
+	c := &Client{retry: retry.Policy{MaxAttempts: 5, RetryOn: retry.Classes}}
+	c.SetBudget(budget.New(3, nil))
+
+	// Retries are calls too, so the budget ends the retries early
+	calls := 0
+	err := c.guard(context.Background(), OpMetadata, func() error {
+		calls++
+		return auth.ServerError{}
+	})
+	if !errors.Is(err, budget.ErrExhausted) {
+		t.Errorf("guard() error = %v, want budget.ErrExhausted", err)
+	}
+	if calls != 3 {
+		t.Errorf("guard() made %d calls, want 3", calls)
+	}
+
+	err = c.guard(context.Background(), OpMetadata, func() error {
+		t.Error("guard() made a call beyond the budget")
+		return nil
+	})
+	if !errors.Is(err, budget.ErrExhausted) {
+		t.Errorf("guard() error = %v, want budget.ErrExhausted", err)
+	}
+}
@@ -0,0 +1,42 @@
+package dropbox
+
+import (
+	"context"
+	"io"
+)
+
+// cancelableBody closes a response body when its context is done, so a Read
+// blocked on a slow transfer returns instead of waiting for it to finish.
+// The SDK takes no context, so this is how downloads honor cancellation.
+type cancelableBody struct {
+	ctx  context.Context
+	body io.ReadCloser
+	stop func() bool
+}
+
+// withContext ties a response body to ctx
+func withContext(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	return &cancelableBody{
+		ctx:  ctx,
+		body: body,
+		stop: context.AfterFunc(ctx, func() { body.Close() }),
+	}
+}
+
+// Read reads from the body, reporting the context's error once it is done
+func (b *cancelableBody) Read(p []byte) (int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := b.body.Read(p)
+	if err != nil && b.ctx.Err() != nil {
+		return n, b.ctx.Err()
+	}
+	return n, err
+}
+
+// Close closes the body and stops watching the context
+func (b *cancelableBody) Close() error {
+	b.stop()
+	return b.body.Close()
+}
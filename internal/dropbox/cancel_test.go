@@ -0,0 +1,86 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingBody blocks reads until it is closed
+type blockingBody struct {
+	closed chan struct{}
+}
+
+func (b *blockingBody) Read(p []byte) (int, error) {
+	<-b.closed
+	return 0, errors.New("read on closed body")
+}
+
+func (b *blockingBody) Close() error {
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+	return nil
+}
+
+func TestWithContextReads(t *testing.T) {
+	body := withContext(context.Background(), io.NopCloser(strings.NewReader("content")))
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != "content" {
+		t.Errorf("ReadAll() = %q, want %q", data, "content")
+	}
+}
+
+func TestWithContextUnblocksOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	body := withContext(ctx, &blockingBody{closed: make(chan struct{})})
+	defer body.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := body.Read(make([]byte, 16))
+		done <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Read() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read() did not return after cancellation")
+	}
+
+	if _, err := body.Read(make([]byte, 16)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Read() after cancel error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestGuardSkipsCallWhenCancelled(t *testing.T) {
+	c := &Client{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := c.guard(ctx, OpMetadata, func() error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("guard() error = %v, want %v", err, context.Canceled)
+	}
+	if called {
+		t.Error("guard() made the call with a cancelled context")
+	}
+}
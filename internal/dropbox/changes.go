@@ -0,0 +1,81 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+// Changes are the entries of a recursive listing that changed since a cursor
+// was taken
+type Changes struct {
+	// Entries are the files and folders added or modified
+	Entries []FileInfo
+	// Deleted are the lower-case paths of deleted entries; a deleted folder
+	// stands for everything below it
+	Deleted []string
+	// Cursor picks up after these changes
+	Cursor string
+}
+
+// LatestCursor returns a cursor for the current state of a folder and
+// everything below it, without listing any entries; "" is the whole account
+func (c *Client) LatestCursor(ctx context.Context, path string) (string, error) {
+	var res *files.ListFolderGetLatestCursorResult
+	err := c.guard(ctx, OpList, func() (err error) {
+		res, err = c.dbx.ListFolderGetLatestCursor(&files.ListFolderArg{
+			Path:      path,
+			Recursive: true,
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get cursor for %s: %w", path, err)
+	}
+	return res.Cursor, nil
+}
+
+// ListChanges lists what changed since a cursor was taken. A cursor Dropbox
+// no longer accepts fails with an error IsCursorReset reports.
+func (c *Client) ListChanges(ctx context.Context, cursor string) (*Changes, error) {
+	changes := &Changes{Cursor: cursor}
+	for {
+		var res *files.ListFolderResult
+		err := c.guard(ctx, OpList, func() (err error) {
+			res, err = c.dbx.ListFolderContinue(&files.ListFolderContinueArg{
+				Cursor: changes.Cursor,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list changes: %w", err)
+		}
+
+		c.listProgress.entries(len(res.Entries))
+		for _, entry := range res.Entries {
+			if deleted, ok := entry.(*files.DeletedMetadata); ok {
+				changes.Deleted = append(changes.Deleted, deleted.PathLower)
+				continue
+			}
+			changes.Entries = append(changes.Entries, c.convertToFileInfo(entry))
+		}
+		changes.Cursor = res.Cursor
+
+		if !res.HasMore {
+			return changes, nil
+		}
+	}
+}
+
+// IsCursorReset reports whether err means a listing cursor expired or was
+// invalidated and the folder has to be listed from scratch
+func IsCursorReset(err error) bool {
+	if errors.Is(err, errCursorReset) {
+		return true
+	}
+	var continueErr files.ListFolderContinueAPIError
+	return errors.As(err, &continueErr) && continueErr.EndpointError != nil &&
+		continueErr.EndpointError.Tag == files.ListFolderContinueErrorReset
+}
@@ -0,0 +1,34 @@
+package dropbox
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+func TestIsCursorReset(t *testing.T) {
+	reset := files.ListFolderContinueAPIError{EndpointError: &files.ListFolderContinueError{}}
+	reset.EndpointError.Tag = files.ListFolderContinueErrorReset
+	notFound := files.ListFolderContinueAPIError{EndpointError: &files.ListFolderContinueError{}}
+	notFound.EndpointError.Tag = files.ListFolderContinueErrorPath
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "reset", err: fmt.Errorf("failed to list changes: %w", reset), want: true},
+		{name: "folder gone", err: notFound, want: false},
+		{name: "no endpoint error", err: files.ListFolderContinueAPIError{}, want: false},
+		{name: "other error", err: fmt.Errorf("network down"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCursorReset(tt.err); got != tt.want {
+				t.Errorf("IsCursorReset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultSlowDelay is how long slow responses are held up unless the chaos
+// settings say otherwise
+const defaultSlowDelay = 5 * time.Second
+
+// chaosRetryAfter is the wait injected rate limits ask for, short enough not
+// to drag out a rehearsal
+const chaosRetryAfter = 1
+
+// Chaos are the rates, between 0 and 1, at which failures are injected into
+// Dropbox requests, to rehearse how monitoring, alerts and resuming respond
+// before a real incident. The zero value injects nothing.
+type Chaos struct {
+	// ServerErrors answer requests with 503 Service Unavailable
+	ServerErrors float64
+	// RateLimits answer requests with 429 Too Many Requests
+	RateLimits float64
+	// DownloadErrors break the content of downloads off halfway with a
+	// connection reset, leaving a part file to resume
+	DownloadErrors float64
+	// Slow holds requests up for SlowDelay before sending them
+	Slow      float64
+	SlowDelay time.Duration
+}
+
+// ParseChaos parses chaos settings like
+// "server_errors=0.05,rate_limits=0.02,download_errors=10%,slow=0.1,slow_delay=10s".
+// Rates are fractions or percentages.
+func ParseChaos(spec string) (Chaos, error) {
+	chaos := Chaos{SlowDelay: defaultSlowDelay}
+	if strings.TrimSpace(spec) == "" {
+		return chaos, nil
+	}
+	for _, setting := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return Chaos{}, fmt.Errorf("invalid chaos setting %q (expected key=value)", setting)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if key == "slow_delay" {
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay < 0 {
+				return Chaos{}, fmt.Errorf("invalid chaos slow_delay %q (expected a duration like 5s)", value)
+			}
+			chaos.SlowDelay = delay
+			continue
+		}
+
+		var rate *float64
+		switch key {
+		case "server_errors":
+			rate = &chaos.ServerErrors
+		case "rate_limits":
+			rate = &chaos.RateLimits
+		case "download_errors":
+			rate = &chaos.DownloadErrors
+		case "slow":
+			rate = &chaos.Slow
+		default:
+			return Chaos{}, fmt.Errorf("unknown chaos setting %q (expected server_errors, rate_limits, download_errors, slow or slow_delay)", key)
+		}
+		parsed, err := parseRate(value)
+		if err != nil {
+			return Chaos{}, fmt.Errorf("invalid chaos %s: %w", key, err)
+		}
+		*rate = parsed
+	}
+	return chaos, nil
+}
+
+// parseRate reads a fraction like 0.05 or a percentage like 5%
+func parseRate(value string) (float64, error) {
+	percent := strings.HasSuffix(value, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	if percent {
+		rate /= 100
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("%s is not between 0 and 1 (or 0%% and 100%%)", value)
+	}
+	return rate, nil
+}
+
+// Enabled reports whether any failure is injected
+func (c Chaos) Enabled() bool {
+	return c.ServerErrors > 0 || c.RateLimits > 0 || c.DownloadErrors > 0 || c.Slow > 0
+}
+
+// String describes the settings in the form ParseChaos reads
+func (c Chaos) String() string {
+	return fmt.Sprintf("server_errors=%g,rate_limits=%g,download_errors=%g,slow=%g,slow_delay=%s",
+		c.ServerErrors, c.RateLimits, c.DownloadErrors, c.Slow, c.SlowDelay)
+}
+
+// SetChaos injects failures into the requests of the client. Clients for
+// other namespaces created afterwards inject them too.
+func (c *Client) SetChaos(chaos Chaos) {
+	c.chaos = chaos
+	if chaos.Enabled() {
+		slog.Warn("Chaos mode: injecting failures into Dropbox requests", slog.String("chaos", chaos.String()))
+	}
+	c.applyToken(context.Background(), c.token)
+}
+
+// chaosTransport injects the failures of Chaos below the transports that
+// react to them, so injected rate limits pause calls like real ones and
+// slow responses count against the call timeout
+type chaosTransport struct {
+	base  http.RoundTripper
+	chaos Chaos
+	// roll returns a random number in [0, 1)
+	roll func() float64
+}
+
+func newChaosTransport(base http.RoundTripper, chaos Chaos) *chaosTransport {
+	return &chaosTransport{base: base, chaos: chaos, roll: rand.Float64}
+}
+
+// RoundTrip sends a request unless a failure is injected instead
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.roll() < t.chaos.Slow {
+		slog.Debug("Chaos: slowing down request", slog.String("path", req.URL.Path), slog.Duration("delay", t.chaos.SlowDelay))
+		timer := time.NewTimer(t.chaos.SlowDelay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if t.roll() < t.chaos.RateLimits {
+		slog.Debug("Chaos: rate limiting request", slog.String("path", req.URL.Path))
+		body := fmt.Sprintf(`{"error_summary": "too_many_requests/", "error": {"reason": {".tag": "too_many_requests"}, "retry_after": %d}}`, chaosRetryAfter)
+		resp := chaosResponse(req, http.StatusTooManyRequests, body)
+		resp.Header.Set("Retry-After", strconv.Itoa(chaosRetryAfter))
+		return resp, nil
+	}
+	if t.roll() < t.chaos.ServerErrors {
+		slog.Debug("Chaos: failing request", slog.String("path", req.URL.Path))
+		return chaosResponse(req, http.StatusServiceUnavailable, "chaos: injected server error"), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return resp, err
+	}
+	if isContentDownload(req) && t.roll() < t.chaos.DownloadErrors {
+		slog.Debug("Chaos: breaking off download", slog.String("path", req.URL.Path))
+		resp.Body = &brokenBody{ReadCloser: resp.Body, remaining: max(resp.ContentLength/2, 0)}
+	}
+	return resp, nil
+}
+
+// isContentDownload reports whether a request downloads file content
+func isContentDownload(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/files/download") || strings.HasSuffix(req.URL.Path, "/files/export")
+}
+
+// chaosResponse returns a response Dropbox could have sent
+func chaosResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// brokenBody fails with a connection reset after its remaining bytes
+type brokenBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
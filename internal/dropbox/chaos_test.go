@@ -0,0 +1,158 @@
+package dropbox
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/netwatch"
+	"create-dropbox-backup-folder/internal/retry"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+func TestParseChaos(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    Chaos
+		wantErr bool
+	}{
+		{name: "empty", want: Chaos{SlowDelay: defaultSlowDelay}},
+		{
+			name: "all settings",
+			spec: "server_errors=0.05, rate_limits=2%,download_errors=0.1,slow=1,slow_delay=10s",
+			want: Chaos{ServerErrors: 0.05, RateLimits: 0.02, DownloadErrors: 0.1, Slow: 1, SlowDelay: 10 * time.Second},
+		},
+		{name: "unknown setting", spec: "timeouts=0.1", wantErr: true},
+		{name: "missing value", spec: "slow", wantErr: true},
+		{name: "rate above 1", spec: "server_errors=1.5", wantErr: true},
+		{name: "percentage above 100", spec: "rate_limits=150%", wantErr: true},
+		{name: "negative rate", spec: "slow=-0.1", wantErr: true},
+		{name: "invalid delay", spec: "slow_delay=soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChaos(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChaos() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseChaos() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if chaos, _ := ParseChaos(""); chaos.Enabled() {
+		t.Error("Enabled() = true without settings")
+	}
+}
+
+func TestChaosTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "0123456789")
+	}))
+	defer server.Close()
+
+	// Rolls are made for slowing down, rate limiting, failing and, for
+	// downloads, breaking off, in this order; a roll below the rate injects
+	tests := []struct {
+		name       string
+		rolls      []float64
+		path       string
+		wantStatus int
+		wantBody   string
+		wantBroken bool
+	}{
+		{name: "no failure", rolls: []float64{1, 1, 1, 1}, path: "/2/files/download", wantStatus: http.StatusOK, wantBody: "0123456789"},
+		{name: "server error", rolls: []float64{1, 1, 0}, path: "/2/files/list_folder", wantStatus: http.StatusServiceUnavailable},
+		{name: "rate limit", rolls: []float64{1, 0}, path: "/2/files/list_folder", wantStatus: http.StatusTooManyRequests},
+		{name: "broken download", rolls: []float64{1, 1, 1, 0}, path: "/2/files/download", wantStatus: http.StatusOK, wantBody: "01234", wantBroken: true},
+		{name: "listing not broken", rolls: []float64{1, 1, 1, 0}, path: "/2/files/list_folder", wantStatus: http.StatusOK, wantBody: "0123456789"},
+		{name: "slow", rolls: []float64{0, 1, 1, 1}, path: "/2/files/download", wantStatus: http.StatusOK, wantBody: "0123456789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chaos := Chaos{ServerErrors: 0.5, RateLimits: 0.5, DownloadErrors: 0.5, Slow: 0.5, SlowDelay: time.Millisecond}
+			transport := newChaosTransport(http.DefaultTransport, chaos)
+			rolls := tt.rolls
+			transport.roll = func() float64 {
+				if len(rolls) == 0 {
+					t.Fatal("more rolls than expected")
+				}
+				roll := rolls[0]
+				rolls = rolls[1:]
+				return roll
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL+tt.path, nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("RoundTrip() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+				t.Error("rate limit has no Retry-After header")
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if broken := err != nil; broken != tt.wantBroken {
+				t.Errorf("read error = %v, want broken %v", err, tt.wantBroken)
+			}
+			if tt.wantBroken && !netwatch.IsNetworkError(err) {
+				t.Errorf("read error = %v, want a network error", err)
+			}
+		})
+	}
+}
+
+func TestChaosErrorsAreRetried(t *testing.T) {
+	tests := []struct {
+		name   string
+		rolls  []float64
+		status int
+		want   string
+	}{
+		{name: "rate limit", rolls: []float64{1, 0}, status: http.StatusTooManyRequests, want: retry.RateLimit},
+		{name: "server error", rolls: []float64{1, 1, 0}, status: http.StatusServiceUnavailable, want: retry.Server},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newChaosTransport(http.DefaultTransport, Chaos{ServerErrors: 0.5, RateLimits: 0.5})
+			rolls := tt.rolls
+			transport.roll = func() float64 {
+				roll := rolls[0]
+				rolls = rolls[1:]
+				return roll
+			}
+			req, _ := http.NewRequest(http.MethodPost, "https://api.dropboxapi.com/2/files/list_folder", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			// The SDK turns the response into an error the way it does for
+			// real ones
+			err = auth.ParseError(dropbox.SDKInternalError{StatusCode: resp.StatusCode, Content: string(body)}, nil)
+			if got := errorClass(err); got != tt.want {
+				t.Errorf("errorClass(%v) = %q, want %q", err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ClockSkew measures how far the local clock is ahead of Dropbox's, from the
+// Date header of a request to the API endpoint; negative if it is behind.
+// The header has a resolution of one second, so smaller offsets can't be
+// told apart.
+func (c *Client) ClockSkew(ctx context.Context) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.endpoints.API, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Dropbox server time: %w", err)
+	}
+	end := time.Now()
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Dropbox server time: %w", err)
+	}
+	return skewAt(start, end, serverTime), nil
+}
+
+// skewAt is the local clock's offset from a server time sent somewhere
+// between start and end. The server time is truncated to the second, so
+// half a second is added to centre it.
+func skewAt(start, end, serverTime time.Time) time.Duration {
+	local := start.Add(end.Sub(start) / 2)
+	return local.Sub(serverTime.Add(500 * time.Millisecond)).Round(time.Second)
+}
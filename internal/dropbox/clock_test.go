@@ -0,0 +1,47 @@
+package dropbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSkewAt(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 200_000_000, time.UTC)
+	tests := []struct {
+		name       string
+		serverTime time.Time
+		want       time.Duration
+	}{
+		{name: "in sync", serverTime: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), want: 0},
+		{name: "local clock ahead", serverTime: time.Date(2025, 6, 1, 11, 55, 0, 0, time.UTC), want: 5 * time.Minute},
+		{name: "local clock behind", serverTime: time.Date(2025, 6, 1, 13, 0, 0, 0, time.UTC), want: -time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skewAt(start, start.Add(400*time.Millisecond), tt.serverTime); got != tt.want {
+				t.Errorf("skewAt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := &Client{endpoints: Endpoints{API: srv.URL}}
+	skew, err := c.ClockSkew(context.Background())
+	if err != nil {
+		t.Fatalf("ClockSkew() error = %v", err)
+	}
+	if skew < time.Hour-2*time.Second || skew > time.Hour+2*time.Second {
+		t.Errorf("ClockSkew() = %s, want about 1h", skew)
+	}
+}
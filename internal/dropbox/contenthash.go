@@ -0,0 +1,73 @@
+package dropbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// contentHashBlockSize is the block size of the Dropbox content hash scheme
+const contentHashBlockSize = 4 * 1024 * 1024
+
+// contentHasher implements the Dropbox content hash: the file is split into
+// 4 MB blocks, each block is hashed with SHA-256, and the concatenated block
+// hashes are hashed again with SHA-256.
+// See https://www.dropbox.com/developers/reference/content-hash
+type contentHasher struct {
+	blockHashes []byte
+	block       hash.Hash
+	blockUsed   int
+}
+
+// NewContentHash returns a hash.Hash computing the Dropbox content hash
+func NewContentHash() hash.Hash {
+	return &contentHasher{block: sha256.New()}
+}
+
+func (h *contentHasher) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if h.blockUsed == contentHashBlockSize {
+			h.blockHashes = h.block.Sum(h.blockHashes)
+			h.block.Reset()
+			h.blockUsed = 0
+		}
+
+		n := contentHashBlockSize - h.blockUsed
+		if n > len(p) {
+			n = len(p)
+		}
+		h.block.Write(p[:n])
+		h.blockUsed += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (h *contentHasher) Sum(b []byte) []byte {
+	overall := sha256.New()
+	overall.Write(h.blockHashes)
+	if h.blockUsed > 0 {
+		overall.Write(h.block.Sum(nil))
+	}
+	return overall.Sum(b)
+}
+
+func (h *contentHasher) Reset() {
+	h.blockHashes = h.blockHashes[:0]
+	h.block.Reset()
+	h.blockUsed = 0
+}
+
+func (h *contentHasher) Size() int      { return sha256.Size }
+func (h *contentHasher) BlockSize() int { return sha256.BlockSize }
+
+// ContentHash computes the Dropbox content hash of everything read from r
+func ContentHash(r io.Reader) (string, error) {
+	h := NewContentHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,80 @@
+package dropbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// referenceContentHash computes the content hash directly from the spec
+func referenceContentHash(data []byte) string {
+	var blockHashes []byte
+	for len(data) > 0 {
+		n := contentHashBlockSize
+		if n > len(data) {
+			n = len(data)
+		}
+		sum := sha256.Sum256(data[:n])
+		blockHashes = append(blockHashes, sum[:]...)
+		data = data[n:]
+	}
+	overall := sha256.Sum256(blockHashes)
+	return hex.EncodeToString(overall[:])
+}
+
+func TestContentHash(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"small", 11},
+		{"exactly one block", contentHashBlockSize},
+		{"one block plus one byte", contentHashBlockSize + 1},
+		{"several blocks", 2*contentHashBlockSize + 12345},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte{0xab}, tt.size)
+
+			got, err := ContentHash(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ContentHash() error = %v", err)
+			}
+			if want := referenceContentHash(data); got != want {
+				t.Errorf("ContentHash() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestContentHashEmptyInput(t *testing.T) {
+	// No blocks: the hash of an empty concatenation is SHA-256 of nothing
+	got, err := ContentHash(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; got != want {
+		t.Errorf("ContentHash(empty) = %s, want %s", got, want)
+	}
+}
+
+func TestContentHashIncrementalWrites(t *testing.T) {
+	data := bytes.Repeat([]byte("dropbox"), contentHashBlockSize/3)
+
+	h := NewContentHash()
+	for chunk := data; len(chunk) > 0; {
+		n := 100000
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		h.Write(chunk[:n])
+		chunk = chunk[n:]
+	}
+
+	if got, want := hex.EncodeToString(h.Sum(nil)), referenceContentHash(data); got != want {
+		t.Errorf("incremental hash = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package dropbox
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Default Dropbox endpoints
+const (
+	DefaultAPIURL     = "https://api.dropboxapi.com"
+	DefaultContentURL = "https://content.dropboxapi.com"
+	DefaultNotifyURL  = "https://notify.dropboxapi.com"
+	DefaultAuthURL    = "https://www.dropbox.com"
+)
+
+// Endpoints are the base URLs of the Dropbox API, e.g. to route requests
+// through a proxy, to a regional deployment or to a mock server in tests.
+// Empty fields keep the defaults.
+type Endpoints struct {
+	// API serves the RPC endpoints and the OAuth2 token endpoint
+	API string
+	// Content serves downloads and uploads
+	Content string
+	// Notify serves long polls for changes
+	Notify string
+	// Auth serves the OAuth2 authorization page
+	Auth string
+}
+
+var (
+	endpointsMu sync.RWMutex
+	endpoints   Endpoints
+)
+
+// SetEndpoints overrides the Dropbox endpoints used by all clients and
+// authentication flows created afterwards
+func SetEndpoints(e Endpoints) error {
+	for _, u := range []*string{&e.API, &e.Content, &e.Notify, &e.Auth} {
+		if *u == "" {
+			continue
+		}
+		if err := ValidateEndpoint(*u); err != nil {
+			return err
+		}
+		*u = strings.TrimSuffix(*u, "/")
+	}
+	endpointsMu.Lock()
+	endpoints = e
+	endpointsMu.Unlock()
+	return nil
+}
+
+// CurrentEndpoints returns the endpoints in use, with the defaults filled in
+func CurrentEndpoints() Endpoints {
+	endpointsMu.RLock()
+	e := endpoints
+	endpointsMu.RUnlock()
+	if e.API == "" {
+		e.API = DefaultAPIURL
+	}
+	if e.Content == "" {
+		e.Content = DefaultContentURL
+	}
+	if e.Notify == "" {
+		e.Notify = DefaultNotifyURL
+	}
+	if e.Auth == "" {
+		e.Auth = DefaultAuthURL
+	}
+	return e
+}
+
+// ValidateEndpoint checks that an endpoint is an absolute http(s) URL
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q (must be an http or https URL)", endpoint)
+	}
+	return nil
+}
+
+// overridden reports whether any endpoint differs from the defaults
+func (e Endpoints) overridden() bool {
+	return e != Endpoints{API: DefaultAPIURL, Content: DefaultContentURL, Notify: DefaultNotifyURL, Auth: DefaultAuthURL}
+}
+
+// apiHost returns the host of the RPC endpoint
+func (e Endpoints) apiHost() string {
+	u, err := url.Parse(e.API)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// urlGenerator builds the request URLs for the SDK the way it does by
+// default, on top of the configured base URLs
+func (e Endpoints) urlGenerator(hostType, namespace, route string) string {
+	base := e.API
+	switch hostType {
+	case "content":
+		base = e.Content
+	case "notify":
+		base = e.Notify
+	}
+	return fmt.Sprintf("%s/2/%s/%s", base, namespace, route)
+}
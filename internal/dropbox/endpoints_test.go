@@ -0,0 +1,106 @@
+package dropbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestSetEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints Endpoints
+		wantErr   bool
+	}{
+		{name: "defaults", endpoints: Endpoints{}},
+		{name: "mock server", endpoints: Endpoints{API: "http://127.0.0.1:8080/", Content: "http://127.0.0.1:8080"}},
+		{name: "missing scheme", endpoints: Endpoints{API: "api.example.com"}, wantErr: true},
+		{name: "unsupported scheme", endpoints: Endpoints{Notify: "ftp://notify.example.com"}, wantErr: true},
+	}
+
+	t.Cleanup(func() { SetEndpoints(Endpoints{}) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetEndpoints(tt.endpoints); (err != nil) != tt.wantErr {
+				t.Errorf("SetEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := SetEndpoints(Endpoints{API: "http://127.0.0.1:8080/"}); err != nil {
+		t.Fatal(err)
+	}
+	got := CurrentEndpoints()
+	want := Endpoints{API: "http://127.0.0.1:8080", Content: DefaultContentURL, Notify: DefaultNotifyURL, Auth: DefaultAuthURL}
+	if got != want {
+		t.Errorf("CurrentEndpoints() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEndpointsURLGenerator(t *testing.T) {
+	e := Endpoints{API: "http://api.test", Content: "http://content.test", Notify: "http://notify.test"}
+	tests := []struct {
+		hostType string
+		want     string
+	}{
+		{hostType: "api", want: "http://api.test/2/files/list_folder"},
+		{hostType: "content", want: "http://content.test/2/files/list_folder"},
+		{hostType: "notify", want: "http://notify.test/2/files/list_folder"},
+	}
+	for _, tt := range tests {
+		if got := e.urlGenerator(tt.hostType, "files", "list_folder"); got != tt.want {
+			t.Errorf("urlGenerator(%q) = %q, want %q", tt.hostType, got, tt.want)
+		}
+	}
+}
+
+func TestClientUsesEndpoints(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth2/token":
+			w.Write([]byte(`{"access_token":"fresh","token_type":"bearer","expires_in":14400}`))
+		case "/2/users/get_current_account":
+			w.Write([]byte(`{"account_id":"dbid:1","email":"mock@example.com","name":{"display_name":"Mock"},` +
+				`"account_type":{".tag":"basic"},"root_info":{".tag":"user","root_namespace_id":"1","home_namespace_id":"1"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Cleanup(func() { SetEndpoints(Endpoints{}) })
+	if err := SetEndpoints(Endpoints{API: srv.URL, Auth: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	authConfig := NewAuthConfig("id", "secret", "")
+	if got, want := authConfig.GetOAuth2Config().Endpoint.AuthURL, srv.URL+"/oauth2/authorize"; got != want {
+		t.Errorf("AuthURL = %q, want %q", got, want)
+	}
+
+	// An expired token is refreshed through the overridden token endpoint
+	token := &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+	client, err := NewWithToken(authConfig, token)
+	if err != nil {
+		t.Fatalf("NewWithToken() error = %v", err)
+	}
+	client.SetCallTimeout(time.Minute)
+
+	account, err := client.GetAccountInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetAccountInfo() error = %v", err)
+	}
+	if account.Email != "mock@example.com" {
+		t.Errorf("Email = %q, want mock@example.com", account.Email)
+	}
+	if len(paths) != 2 || paths[0] != "/oauth2/token" || paths[1] != "/2/users/get_current_account" {
+		t.Errorf("requested %v, want the token and account endpoints", paths)
+	}
+}
@@ -0,0 +1,85 @@
+package dropbox
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+	"golang.org/x/oauth2"
+)
+
+// IsAuthError reports whether err means the token is invalid, expired or
+// lacks the permission for a call
+func IsAuthError(err error) bool {
+	var authErr auth.AuthAPIError
+	var accessErr auth.AccessAPIError
+	var retrieveErr *oauth2.RetrieveError
+	var sdkErr dropbox.SDKInternalError
+
+	switch {
+	case errors.As(err, &authErr), errors.As(err, &accessErr), errors.As(err, &retrieveErr):
+		return true
+	case errors.As(err, &sdkErr):
+		return sdkErr.StatusCode == http.StatusUnauthorized || sdkErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// IsRateLimited reports whether err means Dropbox rejected a call because
+// too many were made
+func IsRateLimited(err error) bool {
+	var rateErr auth.RateLimitAPIError
+	var sdkErr dropbox.SDKInternalError
+	return errors.As(err, &rateErr) ||
+		errors.As(err, &sdkErr) && sdkErr.StatusCode == http.StatusTooManyRequests
+}
+
+// Reasons Dropbox gives for entries it can't serve, returned by Unsupported
+const (
+	// UnsupportedFile is a file that can't be downloaded, e.g. some cloud docs
+	UnsupportedFile = files.DownloadErrorUnsupportedFile
+	// UnsupportedNonExportable is a file that can't be exported either
+	UnsupportedNonExportable = files.ExportErrorNonExportable
+	// UnsupportedRestricted is content Dropbox withholds, e.g. for copyright
+	// reasons or without permission to view it
+	UnsupportedRestricted = files.LookupErrorRestrictedContent
+	// UnsupportedContentType is a type of content the API doesn't serve
+	UnsupportedContentType = files.LookupErrorUnsupportedContentType
+)
+
+// Unsupported reports whether err means Dropbox can't serve an entry's
+// content, neither directly nor as an export, and returns the reason it gave.
+// Retrying such an entry doesn't help.
+func Unsupported(err error) (string, bool) {
+	var downloadErr files.DownloadAPIError
+	if errors.As(err, &downloadErr) && downloadErr.EndpointError != nil {
+		if downloadErr.EndpointError.Tag == files.DownloadErrorUnsupportedFile {
+			return UnsupportedFile, true
+		}
+		return unsupportedLookup(downloadErr.EndpointError.Path)
+	}
+
+	var exportErr files.ExportAPIError
+	if errors.As(err, &exportErr) && exportErr.EndpointError != nil {
+		if exportErr.EndpointError.Tag == files.ExportErrorNonExportable {
+			return UnsupportedNonExportable, true
+		}
+		return unsupportedLookup(exportErr.EndpointError.Path)
+	}
+	return "", false
+}
+
+// unsupportedLookup returns the reason of a path lookup error that means the
+// entry can't be served
+func unsupportedLookup(lookupErr *files.LookupError) (string, bool) {
+	if lookupErr == nil {
+		return "", false
+	}
+	switch lookupErr.Tag {
+	case files.LookupErrorRestrictedContent, files.LookupErrorUnsupportedContentType:
+		return lookupErr.Tag, true
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package dropbox
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+	"golang.org/x/oauth2"
+)
+
+func TestErrorPredicates(t *testing.T) {
+	unsupported := files.DownloadAPIError{EndpointError: &files.DownloadError{}}
+	unsupported.EndpointError.Tag = files.DownloadErrorUnsupportedFile
+	nonExportable := files.ExportAPIError{EndpointError: &files.ExportError{}}
+	nonExportable.EndpointError.Tag = files.ExportErrorNonExportable
+	missing := files.DownloadAPIError{EndpointError: &files.DownloadError{Path: &files.LookupError{}}}
+	missing.EndpointError.Tag = files.DownloadErrorPath
+	missing.EndpointError.Path.Tag = files.LookupErrorNotFound
+	restricted := files.DownloadAPIError{EndpointError: &files.DownloadError{Path: &files.LookupError{}}}
+	restricted.EndpointError.Tag = files.DownloadErrorPath
+	restricted.EndpointError.Path.Tag = files.LookupErrorRestrictedContent
+	contentType := files.ExportAPIError{EndpointError: &files.ExportError{Path: &files.LookupError{}}}
+	contentType.EndpointError.Tag = files.ExportErrorPath
+	contentType.EndpointError.Path.Tag = files.LookupErrorUnsupportedContentType
+
+	tests := []struct {
+		name        string
+		err         error
+		auth, rate  bool
+		unsupported string
+	}{
+		{name: "invalid token", err: auth.AuthAPIError{}, auth: true},
+		{name: "missing scope", err: fmt.Errorf("failed: %w", auth.AccessAPIError{}), auth: true},
+		{name: "refresh rejected", err: &oauth2.RetrieveError{}, auth: true},
+		{name: "unparsed 401", err: dropbox.SDKInternalError{StatusCode: 401}, auth: true},
+		{name: "rate limited", err: auth.RateLimitAPIError{}, rate: true},
+		{name: "unparsed 429", err: dropbox.SDKInternalError{StatusCode: 429}, rate: true},
+		{name: "unsupported file", err: fmt.Errorf("failed to download: %w", unsupported), unsupported: UnsupportedFile},
+		{name: "non-exportable", err: nonExportable, unsupported: UnsupportedNonExportable},
+		{name: "restricted content", err: restricted, unsupported: UnsupportedRestricted},
+		{name: "unsupported content type", err: contentType, unsupported: UnsupportedContentType},
+		{name: "missing file", err: missing},
+		{name: "other", err: errors.New("unexpected")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAuthError(tt.err); got != tt.auth {
+				t.Errorf("IsAuthError() = %v, want %v", got, tt.auth)
+			}
+			if got := IsRateLimited(tt.err); got != tt.rate {
+				t.Errorf("IsRateLimited() = %v, want %v", got, tt.rate)
+			}
+			if got, ok := Unsupported(tt.err); got != tt.unsupported || ok != (tt.unsupported != "") {
+				t.Errorf("Unsupported() = %q, %v; want %q", got, ok, tt.unsupported)
+			}
+		})
+	}
+}
@@ -1,17 +1,28 @@
 package dropbox
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
 )
 
+// callbackAddr is where the OAuth2 callback of the redirect URL is received
+const callbackAddr = ":8080"
+
+// authTimeout bounds how long the user has to authorize the app
+const authTimeout = 5 * time.Minute
+
 // InteractiveAuth handles the interactive OAuth2 flow
 type InteractiveAuth struct {
 	authConfig   *AuthConfig
@@ -19,6 +30,12 @@ type InteractiveAuth struct {
 	resultChan   chan AuthResult
 	codeVerifier string
 	state        string
+
+	// addr is where the callback server listens, input where the code is
+	// read from when it can't, and open opens the authorization page
+	addr  string
+	input io.Reader
+	open  func(url string) error
 }
 
 // AuthResult represents the result of an authentication flow
@@ -27,13 +44,18 @@ type AuthResult struct {
 	Error error
 }
 
-// NewInteractiveAuth creates a new interactive authentication handler
-func NewInteractiveAuth(clientID, clientSecret string) *InteractiveAuth {
+// NewInteractiveAuth creates a new interactive authentication handler.
+// extraScopes are requested in addition to the read-only defaults.
+func NewInteractiveAuth(clientID, clientSecret string, extraScopes ...string) *InteractiveAuth {
 	authConfig := NewAuthConfig(clientID, clientSecret, "http://localhost:8080/callback")
+	authConfig.Scopes = append(authConfig.Scopes, extraScopes...)
 
 	return &InteractiveAuth{
 		authConfig: authConfig,
 		resultChan: make(chan AuthResult, 1),
+		addr:       callbackAddr,
+		input:      os.Stdin,
+		open:       openBrowser,
 	}
 }
 
@@ -42,9 +64,12 @@ func (ia *InteractiveAuth) Authenticate(ctx context.Context) (*oauth2.Token, err
 	// Debug OAuth2 configuration
 	ia.authConfig.DebugOAuth2Config()
 
-	// Start local server for callback
+	// Start local server for callback. If the port is taken, e.g. by another
+	// app, the callback would never arrive, so the code is pasted instead.
 	if err := ia.startCallbackServer(); err != nil {
-		return nil, fmt.Errorf("failed to start callback server: %w", err)
+		slog.Warn("Callback server unavailable, falling back to pasting the authorization code", slog.String("error", err.Error()))
+		fmt.Printf("⚠️  Can't receive the authorization callback on %s: %v\n", ia.addr, err)
+		return ia.authenticateManually(ctx)
 	}
 	defer ia.stopCallbackServer()
 
@@ -67,11 +92,52 @@ func (ia *InteractiveAuth) Authenticate(ctx context.Context) (*oauth2.Token, err
 	fmt.Printf("Opening browser for Dropbox authorization...\n")
 	fmt.Printf("If the browser doesn't open automatically, visit: %s\n", authURL)
 
-	if err := openBrowser(authURL); err != nil {
+	if err := ia.open(authURL); err != nil {
+		slog.Warn("Failed to open browser automatically", slog.String("error", err.Error()))
+	}
+
+	return ia.wait(ctx)
+}
+
+// authenticateManually runs the OAuth2 flow without a redirect URL: Dropbox
+// shows the authorization code, which the user pastes into the console
+func (ia *InteractiveAuth) authenticateManually(ctx context.Context) (*oauth2.Token, error) {
+	// The code is exchanged without a redirect URL too
+	ia.authConfig.RedirectURL = ""
+	authURL, _, codeVerifier, err := StartOAuthFlow(ia.authConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start OAuth flow: %w", err)
+	}
+
+	fmt.Printf("Authorize the app in the browser, then paste the code Dropbox shows.\n")
+	fmt.Printf("If the browser doesn't open automatically, visit: %s\n", authURL)
+	if err := ia.open(authURL); err != nil {
 		slog.Warn("Failed to open browser automatically", slog.String("error", err.Error()))
 	}
+	fmt.Printf("Authorization code: ")
 
-	// Wait for callback or timeout
+	go func() {
+		line, err := bufio.NewReader(ia.input).ReadString('\n')
+		code := strings.TrimSpace(line)
+		if code == "" {
+			if err == nil {
+				err = fmt.Errorf("no authorization code entered")
+			}
+			ia.resultChan <- AuthResult{Error: fmt.Errorf("failed to read authorization code: %w", err)}
+			return
+		}
+		token, err := ia.authConfig.ExchangeCode(ctx, code, codeVerifier)
+		if err != nil {
+			err = fmt.Errorf("failed to exchange code: %w", err)
+		}
+		ia.resultChan <- AuthResult{Token: token, Error: err}
+	}()
+
+	return ia.wait(ctx)
+}
+
+// wait waits for the result of the flow or its timeout
+func (ia *InteractiveAuth) wait(ctx context.Context) (*oauth2.Token, error) {
 	select {
 	case result := <-ia.resultChan:
 		if result.Error != nil {
@@ -80,31 +146,34 @@ func (ia *InteractiveAuth) Authenticate(ctx context.Context) (*oauth2.Token, err
 		return result.Token, nil
 	case <-ctx.Done():
 		return nil, fmt.Errorf("authentication timeout or cancelled")
-	case <-time.After(5 * time.Minute):
-		return nil, fmt.Errorf("authentication timeout after 5 minutes")
+	case <-time.After(authTimeout):
+		return nil, fmt.Errorf("authentication timeout after %s", authTimeout)
 	}
 }
 
-// startCallbackServer starts the local HTTP server for OAuth callback
+// startCallbackServer starts the local HTTP server for OAuth callback. The
+// port is bound before returning, so a port in use fails right away.
 func (ia *InteractiveAuth) startCallbackServer() error {
+	listener, err := net.Listen("tcp", ia.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for callback: %w", err)
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/callback", ia.handleCallback)
 	mux.HandleFunc("/", ia.handleRoot)
 
 	ia.server = &http.Server{
-		Addr:    ":8080",
+		Addr:    ia.addr,
 		Handler: mux,
 	}
 
 	go func() {
-		if err := ia.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := ia.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			ia.resultChan <- AuthResult{Error: fmt.Errorf("callback server error: %w", err)}
 		}
 	}()
 
-	// Give the server a moment to start
-	time.Sleep(100 * time.Millisecond)
-
 	return nil
 }
 
@@ -266,7 +335,7 @@ func openBrowser(url string) error {
 }
 
 // AuthenticateWithStoredToken attempts to use a stored token, falling back to interactive auth
-func AuthenticateWithStoredToken(clientID, clientSecret, accessToken, refreshToken string) (*oauth2.Token, error) {
+func AuthenticateWithStoredToken(clientID, clientSecret, accessToken, refreshToken string, extraScopes ...string) (*oauth2.Token, error) {
 	// If we have tokens, try to use them
 	if accessToken != "" {
 		token := &oauth2.Token{
@@ -293,7 +362,7 @@ func AuthenticateWithStoredToken(clientID, clientSecret, accessToken, refreshTok
 	}
 
 	// Fall back to interactive authentication
-	interactiveAuth := NewInteractiveAuth(clientID, clientSecret)
+	interactiveAuth := NewInteractiveAuth(clientID, clientSecret, extraScopes...)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
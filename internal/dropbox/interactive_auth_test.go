@@ -1,11 +1,16 @@
 package dropbox
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGenerateRandomString(t *testing.T) {
@@ -144,6 +149,88 @@ func TestFindAvailablePort(t *testing.T) {
 	}
 }
 
+func TestStartCallbackServerPortInUse(t *testing.T) {
+	// Another app owns the port
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer busy.Close()
+
+	ia := NewInteractiveAuth("id", "secret")
+	ia.addr = busy.Addr().String()
+	start := time.Now()
+	if err := ia.startCallbackServer(); err == nil {
+		ia.stopCallbackServer()
+		t.Fatal("startCallbackServer() error = nil, want port in use")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("startCallbackServer() took %s to fail", elapsed)
+	}
+
+	ia.addr = "127.0.0.1:0"
+	if err := ia.startCallbackServer(); err != nil {
+		t.Fatalf("startCallbackServer() on a free port error = %v", err)
+	}
+	ia.stopCallbackServer()
+}
+
+func TestAuthenticateFallsBackToManualCode(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer busy.Close()
+
+	var form map[string][]string
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "access", "refresh_token": "refresh", "token_type": "bearer", "expires_in": 14400}`))
+	}))
+	defer tokens.Close()
+
+	ia := NewInteractiveAuth("id", "secret")
+	ia.authConfig.Endpoints = Endpoints{API: tokens.URL, Auth: tokens.URL}
+	ia.addr = busy.Addr().String()
+	ia.input = strings.NewReader("  the-code  \n")
+	var opened string
+	ia.open = func(url string) error {
+		opened = url
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	token, err := ia.Authenticate(ctx)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" {
+		t.Errorf("Authenticate() token = %+v", token)
+	}
+	if strings.Contains(opened, "redirect_uri") {
+		t.Errorf("authorization URL %s has a redirect URI", opened)
+	}
+	if got := form["code"]; len(got) != 1 || got[0] != "the-code" {
+		t.Errorf("exchanged code = %v, want the-code", got)
+	}
+	if _, ok := form["redirect_uri"]; ok {
+		t.Errorf("code exchanged with redirect URI %v", form["redirect_uri"])
+	}
+}
+
+func TestAuthenticateManualCodeEmpty(t *testing.T) {
+	ia := NewInteractiveAuth("id", "secret")
+	ia.input = strings.NewReader("\n")
+	ia.open = func(string) error { return nil }
+
+	if _, err := ia.authenticateManually(context.Background()); err == nil {
+		t.Error("authenticateManually() error = nil, want no code entered")
+	}
+}
+
 // Helper function for testing random string generation
 func generateRandomString(length int) (string, error) {
 	bytes := make([]byte, length)
@@ -0,0 +1,64 @@
+package dropbox
+
+import (
+	"sync"
+	"time"
+)
+
+// ListProgress describes how far a recursive listing has got
+type ListProgress struct {
+	Entries int
+	Folders int
+	// Path is the folder currently being listed
+	Path string
+}
+
+// listProgress counts listed entries and reports them at most once per interval
+type listProgress struct {
+	mu       sync.Mutex
+	interval time.Duration
+	report   func(ListProgress)
+	last     time.Time
+	current  ListProgress
+	now      func() time.Time
+}
+
+// OnListProgress makes recursive listings call report with their progress
+// every interval, so long listings of large accounts show signs of life.
+// Clients for other namespaces created afterwards share the counters.
+func (c *Client) OnListProgress(interval time.Duration, report func(ListProgress)) {
+	c.listProgress = &listProgress{interval: interval, report: report, last: time.Now(), now: time.Now}
+}
+
+// folder records that listing a folder started
+func (p *listProgress) folder(path string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current.Folders++
+	p.current.Path = path
+	p.maybeReport()
+}
+
+// entries records listed entries
+func (p *listProgress) entries(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current.Entries += n
+	p.maybeReport()
+}
+
+// maybeReport reports the progress if the interval has passed. p.mu must be held.
+func (p *listProgress) maybeReport() {
+	now := p.now()
+	if now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	p.report(p.current)
+}
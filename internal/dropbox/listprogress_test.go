@@ -0,0 +1,47 @@
+package dropbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListProgress(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var reports []ListProgress
+	p := &listProgress{
+		interval: 10 * time.Second,
+		report:   func(lp ListProgress) { reports = append(reports, lp) },
+		last:     now,
+		now:      func() time.Time { return now },
+	}
+
+	p.folder("")
+	p.entries(500)
+	if len(reports) != 0 {
+		t.Fatalf("reported %v before the interval passed", reports)
+	}
+
+	now = now.Add(11 * time.Second)
+	p.folder("/photos")
+	p.entries(200)
+	now = now.Add(10 * time.Second)
+	p.entries(300)
+
+	want := []ListProgress{
+		{Entries: 500, Folders: 2, Path: "/photos"},
+		{Entries: 1000, Folders: 2, Path: "/photos"},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("reports = %+v, want %+v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("report %d = %+v, want %+v", i, reports[i], want[i])
+		}
+	}
+
+	// Disabled progress is a no-op
+	var disabled *listProgress
+	disabled.folder("/")
+	disabled.entries(1)
+}
@@ -0,0 +1,93 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// MaxLongpollTimeout is the longest Dropbox holds a longpoll request open
+const MaxLongpollTimeout = 480 * time.Second
+
+// longpollJitter is how much longer than its timeout Dropbox may hold a
+// longpoll request, to spread out clients
+const longpollJitter = 90 * time.Second
+
+// LongpollResult is the outcome of waiting for changes
+type LongpollResult struct {
+	// Changes is set if the folder changed since the cursor was taken
+	Changes bool
+	// Backoff is how long to wait before polling again; zero if Dropbox
+	// didn't ask for a pause
+	Backoff time.Duration
+}
+
+// Longpoll waits up to timeout (plus some jitter added by Dropbox) for
+// changes below the folder of a listing cursor. The endpoint takes no
+// authentication, as the cursor identifies the folder, so it is called
+// directly instead of through the SDK client, which would send the token.
+// An expired cursor fails with an error IsCursorReset reports.
+func (c *Client) Longpoll(ctx context.Context, cursor string, timeout time.Duration) (LongpollResult, error) {
+	if timeout > MaxLongpollTimeout {
+		timeout = MaxLongpollTimeout
+	}
+	body, err := json.Marshal(map[string]any{"cursor": cursor, "timeout": int(timeout.Seconds())})
+	if err != nil {
+		return LongpollResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout+longpollJitter+30*time.Second)
+	defer cancel()
+	url := c.endpoints.urlGenerator("notify", "files", "list_folder/longpoll")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return LongpollResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return LongpollResult{}, fmt.Errorf("failed to wait for changes: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return LongpollResult{}, fmt.Errorf("failed to wait for changes: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return LongpollResult{}, longpollError(resp.StatusCode, data)
+	}
+
+	var res struct {
+		Changes bool   `json:"changes"`
+		Backoff uint64 `json:"backoff"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return LongpollResult{}, fmt.Errorf("failed to parse longpoll response: %w", err)
+	}
+	return LongpollResult{Changes: res.Changes, Backoff: time.Duration(res.Backoff) * time.Second}, nil
+}
+
+// errCursorReset marks a longpoll for a cursor Dropbox no longer accepts
+var errCursorReset = errors.New("listing cursor was reset")
+
+// longpollError describes a failed longpoll. Endpoint errors come as 409
+// with a tag, e.g. "reset" for an expired cursor.
+func longpollError(status int, body []byte) error {
+	if status == http.StatusConflict {
+		var apiErr struct {
+			Error struct {
+				Tag string `json:".tag"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Tag == "reset" {
+			return fmt.Errorf("failed to wait for changes: %w", errCursorReset)
+		}
+	}
+	return fmt.Errorf("failed to wait for changes: %s: %s", http.StatusText(status), bytes.TrimSpace(body))
+}
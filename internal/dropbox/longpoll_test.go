@@ -0,0 +1,61 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLongpoll(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		want      LongpollResult
+		wantErr   bool
+		wantReset bool
+	}{
+		{name: "changes", status: http.StatusOK, body: `{"changes":true}`, want: LongpollResult{Changes: true}},
+		{name: "no changes with backoff", status: http.StatusOK, body: `{"changes":false,"backoff":60}`, want: LongpollResult{Backoff: time.Minute}},
+		{name: "reset cursor", status: http.StatusConflict, body: `{"error_summary":"reset/","error":{".tag":"reset"}}`, wantErr: true, wantReset: true},
+		{name: "server error", status: http.StatusInternalServerError, body: "oops", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/2/files/list_folder/longpoll" {
+					t.Errorf("path = %s, want the longpoll route", r.URL.Path)
+				}
+				if auth := r.Header.Get("Authorization"); auth != "" {
+					t.Errorf("Authorization = %q, want none", auth)
+				}
+				var arg struct {
+					Cursor  string `json:"cursor"`
+					Timeout int    `json:"timeout"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&arg); err != nil || arg.Cursor != "cursor1" || arg.Timeout != 30 {
+					t.Errorf("argument = %+v, %v; want cursor1 with 30s", arg, err)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := &Client{endpoints: Endpoints{Notify: srv.URL}}
+			got, err := c.Longpoll(context.Background(), "cursor1", 30*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Longpoll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if IsCursorReset(err) != tt.wantReset {
+				t.Errorf("IsCursorReset(%v) = %v, want %v", err, !tt.wantReset, tt.wantReset)
+			}
+			if got != tt.want {
+				t.Errorf("Longpoll() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
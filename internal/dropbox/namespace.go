@@ -0,0 +1,65 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/common"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/users"
+)
+
+// Namespaces describes the namespaces an account's files live in. For
+// members of a team space, Root is the team space and the home namespace is
+// mounted inside it at HomePath; otherwise Root equals Home.
+type Namespaces struct {
+	Home     string
+	Root     string
+	HomePath string
+}
+
+// HasTeamSpace reports whether the account has a team space besides its home
+func (n Namespaces) HasTeamSpace() bool {
+	return n.Root != "" && n.Root != n.Home
+}
+
+// InHome reports whether a path listed in the root namespace belongs to the
+// mounted home namespace
+func (n Namespaces) InHome(path string) bool {
+	if n.HomePath == "" {
+		return false
+	}
+	home := strings.ToLower(n.HomePath)
+	return path == home || strings.HasPrefix(path, home+"/")
+}
+
+// Namespaces returns the home and root namespaces of the account
+func (c *Client) Namespaces(ctx context.Context) (Namespaces, error) {
+	var account *users.FullAccount
+	err := c.guard(ctx, OpAccount, func() (err error) {
+		account, err = c.users.GetCurrentAccount()
+		return err
+	})
+	if err != nil {
+		return Namespaces{}, fmt.Errorf("failed to get current account: %w", err)
+	}
+
+	switch root := account.RootInfo.(type) {
+	case *common.TeamRootInfo:
+		return Namespaces{Home: root.HomeNamespaceId, Root: root.RootNamespaceId, HomePath: root.HomePath}, nil
+	case *common.UserRootInfo:
+		return Namespaces{Home: root.HomeNamespaceId, Root: root.RootNamespaceId}, nil
+	default:
+		return Namespaces{}, fmt.Errorf("failed to get current account: unknown root info %T", account.RootInfo)
+	}
+}
+
+// InNamespace returns a client whose paths are relative to the given
+// namespace, e.g. a team space root. Files it lists carry the namespace ID.
+// It shares the circuit breaker and latency statistics of c.
+func (c *Client) InNamespace(ctx context.Context, namespace string) *Client {
+	nc := *c
+	nc.namespace = namespace
+	nc.applyToken(ctx, c.token)
+	return &nc
+}
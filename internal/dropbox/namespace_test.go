@@ -0,0 +1,30 @@
+package dropbox
+
+import "testing"
+
+func TestNamespaces(t *testing.T) {
+	team := Namespaces{Home: "1", Root: "2", HomePath: "/Jane Doe"}
+	personal := Namespaces{Home: "1", Root: "1"}
+
+	if !team.HasTeamSpace() || personal.HasTeamSpace() {
+		t.Errorf("HasTeamSpace() = %v, %v, want true, false", team.HasTeamSpace(), personal.HasTeamSpace())
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/jane doe", want: true},
+		{path: "/jane doe/notes.txt", want: true},
+		{path: "/jane doe archive/notes.txt", want: false},
+		{path: "/marketing/plan.txt", want: false},
+	}
+	for _, tt := range tests {
+		if got := team.InHome(tt.path); got != tt.want {
+			t.Errorf("InHome(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if personal.InHome("/anything") {
+		t.Error("InHome() without team space should be false")
+	}
+}
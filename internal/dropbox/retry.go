@@ -0,0 +1,42 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"create-dropbox-backup-folder/internal/netwatch"
+	"create-dropbox-backup-folder/internal/retry"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+// SetRetryPolicy makes API calls retry failures according to policy. Uploads
+// stream their content once and are never retried here. Clients for other
+// namespaces created afterwards use the same policy.
+func (c *Client) SetRetryPolicy(policy retry.Policy) {
+	c.retry = policy
+}
+
+// errorClass maps an API call error to its retry class, or "" for errors
+// that retrying won't fix
+func errorClass(err error) string {
+	var serverErr auth.ServerError
+	var sdkErr dropbox.SDKInternalError
+	var netErr net.Error
+
+	switch {
+	case IsRateLimited(err):
+		return retry.RateLimit
+	case errors.As(err, &serverErr):
+		return retry.Server
+	case errors.As(err, &sdkErr) && sdkErr.StatusCode >= 500:
+		return retry.Server
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return retry.Timeout
+	case netwatch.IsNetworkError(err):
+		return retry.Network
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/retry"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "rate limited", err: auth.RateLimitAPIError{}, want: retry.RateLimit},
+		{name: "server error", err: fmt.Errorf("failed to list: %w", auth.ServerError{}), want: retry.Server},
+		{name: "unparsed 503", err: dropbox.SDKInternalError{StatusCode: 503}, want: retry.Server},
+		{name: "unparsed 429", err: dropbox.SDKInternalError{StatusCode: 429}, want: retry.RateLimit},
+		{name: "deadline", err: fmt.Errorf("timed out: %w", context.DeadlineExceeded), want: retry.Timeout},
+		{name: "connection reset", err: syscall.ECONNRESET, want: retry.Network},
+		{name: "not found", err: files.GetMetadataAPIError{}, want: ""},
+		{name: "bad request", err: auth.BadRequest{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorClass(tt.err); got != tt.want {
+				t.Errorf("errorClass(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardRetries(t *testing.T) {
+	c := &Client{retry: retry.Policy{MaxAttempts: 3, RetryOn: retry.Classes}}
+
+	tests := []struct {
+		name      string
+		op        string
+		wantCalls int
+	}{
+		{name: "metadata calls are retried", op: OpMetadata, wantCalls: 3},
+		{name: "uploads are not retried", op: OpUpload, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := c.guard(context.Background(), tt.op, func() error {
+				calls++
+				return auth.ServerError{}
+			})
+			if !errors.As(err, &auth.ServerError{}) {
+				t.Errorf("guard() error = %v, want server error", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("guard() made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+// maxRetryAfter bounds how long a Retry-After can hold off calls, so a bogus
+// value doesn't stall a run for hours
+const maxRetryAfter = 15 * time.Minute
+
+// retryPause holds off all API calls of a client, and those of its
+// namespaces, until the time Dropbox last asked to retry after. A rate limit
+// applies to the app or user as a whole, so calls that didn't fail wait too.
+type retryPause struct {
+	now func() time.Time
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+func newRetryPause() *retryPause {
+	return &retryPause{now: time.Now}
+}
+
+// extend holds off calls for at least d from now
+func (p *retryPause) extend(d time.Duration) {
+	d = min(d, maxRetryAfter)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	until := p.now().Add(d)
+	if until.After(p.until) {
+		p.until = until
+		slog.Info("Dropbox asked to retry later, pausing API calls", slog.Duration("retry_after", d))
+	}
+}
+
+// wait blocks until the pause is over or the context is done
+func (p *retryPause) wait(ctx context.Context) error {
+	p.mu.Lock()
+	remaining := p.until.Sub(p.now())
+	p.mu.Unlock()
+	if remaining <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfterTransport passes the Retry-After header of rate limited (429) and
+// unavailable (503) responses to the pause. The SDK turns these responses
+// into errors without their headers, so this is the only place to read it.
+type retryAfterTransport struct {
+	base  http.RoundTripper
+	pause *retryPause
+}
+
+// RoundTrip sends a request and records the wait its response asks for
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), t.pause.now()); ok {
+			t.pause.extend(d)
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of
+// seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// rateLimitWait returns the wait the body of a rate limit error asks for
+func rateLimitWait(err error) (time.Duration, bool) {
+	var rateErr auth.RateLimitAPIError
+	if !errors.As(err, &rateErr) || rateErr.RateLimitError == nil || rateErr.RateLimitError.RetryAfter == 0 {
+		return 0, false
+	}
+	return time.Duration(rateErr.RateLimitError.RetryAfter) * time.Second, true
+}
@@ -0,0 +1,105 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", wantOK: false},
+		{value: "30", want: 30 * time.Second, wantOK: true},
+		{value: " 5 ", want: 5 * time.Second, wantOK: true},
+		{value: "Sat, 01 Mar 2025 12:02:00 GMT", want: 2 * time.Minute, wantOK: true},
+		{value: "Sat, 01 Mar 2025 11:00:00 GMT", want: 0, wantOK: true},
+		{value: "soon", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryAfterTransport(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header string
+		want   time.Duration
+	}{
+		{name: "rate limited", status: http.StatusTooManyRequests, header: "30", want: 30 * time.Second},
+		{name: "unavailable", status: http.StatusServiceUnavailable, header: "10", want: 10 * time.Second},
+		{name: "capped", status: http.StatusTooManyRequests, header: "86400", want: maxRetryAfter},
+		{name: "other status", status: http.StatusInternalServerError, header: "30", want: 0},
+		{name: "no header", status: http.StatusTooManyRequests, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+			pause := &retryPause{now: func() time.Time { return now }}
+			client := &http.Client{Transport: &retryAfterTransport{base: http.DefaultTransport, pause: pause}}
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			got := max(pause.until.Sub(now), 0)
+			if got != tt.want {
+				t.Errorf("paused for %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardHonorsRateLimitWait(t *testing.T) {
+	c := &Client{pause: newRetryPause()}
+	rateErr := auth.RateLimitAPIError{RateLimitError: &auth.RateLimitError{RetryAfter: 1}}
+
+	err := c.guard(context.Background(), OpMetadata, func() error { return rateErr })
+	if !errors.As(err, &auth.RateLimitAPIError{}) {
+		t.Fatalf("guard() error = %v, want the rate limit error", err)
+	}
+
+	// The next call waits for the second Dropbox asked for
+	start := time.Now()
+	if err := c.guard(context.Background(), OpMetadata, func() error { return nil }); err != nil {
+		t.Fatalf("guard() error = %v", err)
+	}
+	if waited := time.Since(start); waited < 900*time.Millisecond {
+		t.Errorf("guard() waited %s, want about a second", waited)
+	}
+
+	// A cancelled call stops waiting
+	c.pause.extend(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.guard(ctx, OpMetadata, func() error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("guard() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,84 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+// ErrWriteAccess is returned by RequireReadOnly for tokens that can change
+// the account
+var ErrWriteAccess = errors.New("token has write access to Dropbox")
+
+// readOnlyGuidance tells how to get a token that can only read
+const readOnlyGuidance = "create a Dropbox app with only the files.metadata.read and files.content.read " +
+	"permissions at https://www.dropbox.com/developers/apps, then run auth with its key and secret"
+
+// RequireReadOnly fails with ErrWriteAccess if the token can change the
+// account. The scopes listed with the token are checked when Dropbox sent
+// them; otherwise the files.content.write scope, needed to upload, move or
+// delete files, is probed by starting an upload session that is closed at
+// once and never committed, so nothing is stored.
+func (c *Client) RequireReadOnly(ctx context.Context) error {
+	if scopes, ok := c.grantedScopes(); ok {
+		if writes := writeScopes(scopes); len(writes) > 0 {
+			return fmt.Errorf("%w (%s): %s", ErrWriteAccess, strings.Join(writes, ", "), readOnlyGuidance)
+		}
+		return nil
+	}
+
+	arg := files.NewUploadSessionStartArg()
+	arg.Close = true
+	err := c.guard(ctx, OpUpload, func() error {
+		_, err := c.dbx.UploadSessionStart(arg, bytes.NewReader(nil))
+		return err
+	})
+	if err == nil {
+		return fmt.Errorf("%w (%s): %s", ErrWriteAccess, ScopeContentWrite, readOnlyGuidance)
+	}
+	if _, ok := missingScope(err); ok {
+		return nil
+	}
+	return fmt.Errorf("failed to check the token for write access: %w", err)
+}
+
+// grantedScopes returns the scopes Dropbox listed with the token, which it
+// does when issuing or refreshing one
+func (c *Client) grantedScopes() ([]string, bool) {
+	if c.token == nil {
+		return nil, false
+	}
+	scope, _ := c.token.Extra("scope").(string)
+	if scope == "" {
+		return nil, false
+	}
+	return strings.Fields(scope), true
+}
+
+// writeScopes returns the scopes that allow changes, e.g. files.content.write
+func writeScopes(scopes []string) []string {
+	var writes []string
+	for _, scope := range scopes {
+		if strings.HasSuffix(scope, ".write") {
+			writes = append(writes, scope)
+		}
+	}
+	return writes
+}
+
+// missingScope reports whether err means the token lacks a scope, and which
+func missingScope(err error) (string, bool) {
+	var authErr auth.AuthAPIError
+	if !errors.As(err, &authErr) || authErr.AuthError == nil || authErr.AuthError.Tag != auth.AuthErrorMissingScope {
+		return "", false
+	}
+	if authErr.AuthError.MissingScope == nil {
+		return "", true
+	}
+	return authErr.AuthError.MissingScope.RequiredScope, true
+}
@@ -0,0 +1,79 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+	"golang.org/x/oauth2"
+)
+
+func TestWriteScopes(t *testing.T) {
+	got := writeScopes([]string{"account_info.read", "files.metadata.read", "files.content.write", "sharing.write"})
+	want := []string{"files.content.write", "sharing.write"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("writeScopes() = %v, want %v", got, want)
+	}
+	if got := writeScopes([]string{"files.content.read"}); got != nil {
+		t.Errorf("writeScopes() of read scopes = %v, want none", got)
+	}
+}
+
+func TestMissingScope(t *testing.T) {
+	missing := auth.AuthAPIError{AuthError: &auth.AuthError{
+		Tagged:       dropbox.Tagged{Tag: auth.AuthErrorMissingScope},
+		MissingScope: &auth.TokenScopeError{RequiredScope: ScopeContentWrite},
+	}}
+	expired := auth.AuthAPIError{AuthError: &auth.AuthError{Tagged: dropbox.Tagged{Tag: auth.AuthErrorExpiredAccessToken}}}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantScope string
+		wantOK    bool
+	}{
+		{name: "missing scope", err: fmt.Errorf("failed: %w", missing), wantScope: ScopeContentWrite, wantOK: true},
+		{name: "expired token", err: expired},
+		{name: "other error", err: errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, ok := missingScope(tt.err)
+			if scope != tt.wantScope || ok != tt.wantOK {
+				t.Errorf("missingScope() = %q, %v; want %q, %v", scope, ok, tt.wantScope, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRequireReadOnlyGrantedScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   string
+		wantErr bool
+	}{
+		{name: "read only", scope: "files.metadata.read files.content.read"},
+		{name: "write", scope: "files.content.read files.content.write", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := (&oauth2.Token{AccessToken: "x"}).WithExtra(map[string]any{"scope": tt.scope})
+			c := &Client{token: token}
+
+			err := c.RequireReadOnly(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequireReadOnly() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && (!errors.Is(err, ErrWriteAccess) || !strings.Contains(err.Error(), "files.content.write")) {
+				t.Errorf("RequireReadOnly() error = %v, want ErrWriteAccess naming the scope", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+// searchPageSize is the largest page search_v2 returns
+const searchPageSize = 1000
+
+// SearchExtensions lists the files with one of the extensions (e.g. "pdf")
+// below the given folders, using the Dropbox search index instead of listing
+// every folder. An empty list of folders means the whole account. The index
+// can lag behind recent changes, so files changed moments ago may be missing.
+func (c *Client) SearchExtensions(ctx context.Context, paths []string, extensions []string) ([]FileInfo, error) {
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+
+	var found []FileInfo
+	for _, path := range paths {
+		for _, extension := range extensions {
+			if err := c.search(ctx, path, extension, &found); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	slog.Info("Searched Dropbox by file extension",
+		slog.Int("folders", len(paths)),
+		slog.Int("extensions", len(extensions)),
+		slog.Int("total_files", len(found)),
+	)
+	return found, nil
+}
+
+// search pages through the active files with one extension below a folder.
+// The extension doubles as the query, as search_v2 needs one.
+func (c *Client) search(ctx context.Context, path, extension string, found *[]FileInfo) error {
+	arg := files.NewSearchV2Arg(extension)
+	arg.Options = files.NewSearchOptions()
+	arg.Options.Path = path
+	arg.Options.MaxResults = searchPageSize
+	arg.Options.FilenameOnly = true
+	arg.Options.FileExtensions = []string{extension}
+
+	var res *files.SearchV2Result
+	err := c.guard(ctx, OpList, func() (err error) {
+		res, err = c.dbx.SearchV2(arg)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to search %s for .%s files: %w", displayPath(path), extension, err)
+	}
+
+	for {
+		c.listProgress.entries(len(res.Matches))
+		*found = append(*found, c.searchMatches(res.Matches)...)
+
+		if !res.HasMore {
+			return nil
+		}
+
+		cursor := res.Cursor
+		err = c.guard(ctx, OpList, func() (err error) {
+			res, err = c.dbx.SearchContinueV2(files.NewSearchV2ContinueArg(cursor))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to continue searching %s for .%s files: %w", displayPath(path), extension, err)
+		}
+	}
+}
+
+// searchMatches converts the files among search matches
+func (c *Client) searchMatches(matches []*files.SearchMatchV2) []FileInfo {
+	var found []FileInfo
+	for _, match := range matches {
+		if match.Metadata == nil {
+			continue
+		}
+		if _, ok := match.Metadata.Metadata.(*files.FileMetadata); ok {
+			found = append(found, c.convertToFileInfo(match.Metadata.Metadata))
+		}
+	}
+	return found
+}
+
+// displayPath returns a folder path for messages, "/" for the root
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
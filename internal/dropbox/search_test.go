@@ -0,0 +1,30 @@
+package dropbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+func TestSearchMatches(t *testing.T) {
+	file := files.NewFileMetadata("report.pdf", "id:1", time.Time{}, time.Time{}, "015", 42)
+	file.PathLower = "/docs/report.pdf"
+	folder := files.NewFolderMetadata("pdf", "id:2")
+	folder.PathLower = "/pdf"
+
+	matches := []*files.SearchMatchV2{
+		{Metadata: &files.MetadataV2{Metadata: file}},
+		{Metadata: &files.MetadataV2{Metadata: folder}},
+		{},
+	}
+
+	c := &Client{namespace: "42"}
+	got := c.searchMatches(matches)
+	if len(got) != 1 {
+		t.Fatalf("searchMatches() returned %d files, want 1", len(got))
+	}
+	if got[0].Path != "/docs/report.pdf" || got[0].Size != 42 || got[0].Namespace != "42" {
+		t.Errorf("searchMatches()[0] = %+v", got[0])
+	}
+}
@@ -0,0 +1,42 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/sharing"
+)
+
+// SharedFolder describes a shared folder independently of where it is mounted
+type SharedFolder struct {
+	ID   string
+	Name string
+	// Owner is the display name of the owning user or team, empty if unknown
+	Owner string
+}
+
+// SharedFolder looks up a shared folder by ID. It needs the sharing.read scope.
+func (c *Client) SharedFolder(ctx context.Context, id string) (SharedFolder, error) {
+	var meta *sharing.SharedFolderMetadata
+	err := c.guard(ctx, OpMetadata, func() (err error) {
+		meta, err = c.sharing.GetFolderMetadata(sharing.NewGetMetadataArgs(id))
+		return err
+	})
+	if err != nil {
+		return SharedFolder{}, fmt.Errorf("failed to get shared folder %s: %w", id, err)
+	}
+	return sharedFolder(meta), nil
+}
+
+// sharedFolder converts shared folder metadata, preferring the owning user's
+// name over the owning team's
+func sharedFolder(meta *sharing.SharedFolderMetadata) SharedFolder {
+	folder := SharedFolder{ID: meta.SharedFolderId, Name: meta.Name}
+	switch {
+	case len(meta.OwnerDisplayNames) > 0:
+		folder.Owner = meta.OwnerDisplayNames[0]
+	case meta.OwnerTeam != nil:
+		folder.Owner = meta.OwnerTeam.Name
+	}
+	return folder
+}
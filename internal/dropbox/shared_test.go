@@ -0,0 +1,49 @@
+package dropbox
+
+import (
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/sharing"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/users"
+)
+
+func TestSharedFolderOwner(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *sharing.SharedFolderMetadata
+		want string
+	}{
+		{
+			name: "user owner",
+			meta: &sharing.SharedFolderMetadata{
+				SharedFolderMetadataBase: sharing.SharedFolderMetadataBase{
+					OwnerDisplayNames: []string{"Jane Doe"},
+					OwnerTeam:         &users.Team{Name: "Acme"},
+				},
+			},
+			want: "Jane Doe",
+		},
+		{
+			name: "team owner",
+			meta: &sharing.SharedFolderMetadata{
+				SharedFolderMetadataBase: sharing.SharedFolderMetadataBase{OwnerTeam: &users.Team{Name: "Acme"}},
+			},
+			want: "Acme",
+		},
+		{
+			name: "unknown owner",
+			meta: &sharing.SharedFolderMetadata{},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.meta.Name = "Plans"
+			tt.meta.SharedFolderId = "84528192421"
+			got := sharedFolder(tt.meta)
+			if got.Owner != tt.want || got.Name != "Plans" || got.ID != "84528192421" {
+				t.Errorf("sharedFolder() = %+v, want owner %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SetCallTimeout bounds every metadata and listing call to timeout, so a hung
+// call fails fast instead of stalling the run; zero disables the limit.
+// Content transfers aren't affected; bound those through the context passed
+// to Download or Export. Clients for other namespaces created afterwards use
+// the same timeout.
+func (c *Client) SetCallTimeout(timeout time.Duration) {
+	c.callTimeout = timeout
+	c.applyToken(context.Background(), c.token)
+}
+
+// callTimeoutTransport gives every request to the RPC endpoint its own
+// deadline. The SDK takes no context, so this is where per-call deadlines
+// are enforced.
+type callTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+	// host serves the RPC endpoints (metadata, listing, account). Content
+	// transfers and long polls go to other hosts and legitimately run long.
+	host string
+}
+
+// RoundTrip sends a request, with a deadline if it goes to the RPC endpoint
+func (t *callTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if req.Context().Err() == nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %s: %w", req.URL.Path, t.timeout, err)
+		}
+		return nil, err
+	}
+
+	// The deadline also covers reading the response
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request's context once its response is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the response body and releases the context
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,85 @@
+package dropbox
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// slowServer answers after delay unless the request's context ends first
+func slowServer(delay time.Duration) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		select {
+		case <-time.After(delay):
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	})
+}
+
+func TestCallTimeoutTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "API call times out", url: "https://api.dropboxapi.com/2/files/list_folder", wantErr: true},
+		{name: "content transfer is not limited", url: "https://content.dropboxapi.com/2/files/download"},
+		{name: "long poll is not limited", url: "https://notify.dropboxapi.com/2/files/list_folder/longpoll"},
+	}
+
+	transport := &callTimeoutTransport{base: slowServer(100 * time.Millisecond), timeout: 10 * time.Millisecond, host: "api.dropboxapi.com"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RoundTrip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), "timed out after 10ms") {
+					t.Errorf("RoundTrip() error = %v, want timeout", err)
+				}
+				return
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestCallTimeoutCoversBody(t *testing.T) {
+	transport := &callTimeoutTransport{base: slowServer(0), timeout: time.Hour, host: "api.dropboxapi.com"}
+	req, err := http.NewRequest(http.MethodPost, "https://api.dropboxapi.com/2/users/get_current_account", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	body, ok := resp.Body.(*cancelOnClose)
+	if !ok {
+		t.Fatalf("RoundTrip() body = %T, want *cancelOnClose", resp.Body)
+	}
+	if data, _ := io.ReadAll(body); string(data) != "ok" {
+		t.Errorf("body = %q, want %q", data, "ok")
+	}
+	if err := body.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
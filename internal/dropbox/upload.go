@@ -0,0 +1,254 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+const (
+	// maxSingleUpload is the largest file sent in a single upload request
+	maxSingleUpload = 150 * 1024 * 1024
+
+	// uploadChunkSize is the size of each upload session request; Dropbox
+	// requires a multiple of 4 MB
+	uploadChunkSize = 64 * 1024 * 1024
+)
+
+// ErrConflict is returned by Upload when the target path changed or exists
+// and the upload options don't allow replacing it
+var ErrConflict = errors.New("remote file conflicts with upload")
+
+// UploadOptions control how Upload treats an existing file at the target path
+type UploadOptions struct {
+	// Rev replaces the file only if its current revision still matches
+	Rev string
+	// Overwrite replaces whatever is at the target path
+	Overwrite bool
+	// Autorename saves under a new name instead of failing on a conflict
+	Autorename bool
+	// ModTime is recorded as the file's client modification time
+	ModTime time.Time
+}
+
+// MaxBatchCommit is the most staged uploads CommitBatch accepts at once
+const MaxBatchCommit = 1000
+
+// Upload writes size bytes from r to remotePath. Without Rev or Overwrite an
+// existing file with different content is a conflict.
+func (c *Client) Upload(ctx context.Context, remotePath string, r io.Reader, size int64, opts UploadOptions) (*FileInfo, error) {
+	commit := commitInfo(remotePath, opts)
+
+	var res *files.FileMetadata
+	var err error
+	if size <= maxSingleUpload {
+		err = c.guard(ctx, OpUpload, func() (err error) {
+			res, err = c.dbx.Upload(&files.UploadArg{CommitInfo: *commit}, r)
+			return err
+		})
+	} else {
+		res, err = c.uploadSession(ctx, commit, r, size)
+	}
+	if err != nil {
+		if isWriteConflict(err) {
+			return nil, fmt.Errorf("failed to upload file %s: %w", remotePath, ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to upload file %s: %w", remotePath, err)
+	}
+
+	info := c.convertToFileInfo(res)
+
+	slog.Debug("Uploaded file",
+		slog.String("path", info.Path),
+		slog.Uint64("size", info.Size),
+	)
+
+	return &info, nil
+}
+
+// commitInfo builds the commit arguments for an upload
+func commitInfo(remotePath string, opts UploadOptions) *files.CommitInfo {
+	commit := files.NewCommitInfo(remotePath)
+	commit.Autorename = opts.Autorename
+	switch {
+	case opts.Overwrite:
+		commit.Mode.Tag = files.WriteModeOverwrite
+	case opts.Rev != "":
+		commit.Mode.Tag = files.WriteModeUpdate
+		commit.Mode.Update = opts.Rev
+		commit.StrictConflict = true
+	}
+	if !opts.ModTime.IsZero() {
+		// Dropbox only accepts whole seconds in UTC
+		modTime := opts.ModTime.UTC().Truncate(time.Second)
+		commit.ClientModified = &modTime
+	}
+	return commit
+}
+
+// uploadSession uploads a large file in chunks
+func (c *Client) uploadSession(ctx context.Context, commit *files.CommitInfo, r io.Reader, size int64) (*files.FileMetadata, error) {
+	staged, err := c.Stage(ctx, r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *files.FileMetadata
+	err = c.guard(ctx, OpUpload, func() (err error) {
+		res, err = c.dbx.UploadSessionFinish(files.NewUploadSessionFinishArg(staged.cursor(), commit), http.NoBody)
+		return err
+	})
+	return res, err
+}
+
+// StagedUpload is file content sent to a closed upload session, waiting to
+// be committed with CommitBatch
+type StagedUpload struct {
+	sessionID string
+	size      uint64
+}
+
+// cursor points at the end of the staged content
+func (s *StagedUpload) cursor() *files.UploadSessionCursor {
+	return files.NewUploadSessionCursor(s.sessionID, s.size)
+}
+
+// Stage sends size bytes from r to a new upload session and closes it, so
+// many files can be committed together with CommitBatch
+func (c *Client) Stage(ctx context.Context, r io.Reader, size int64) (*StagedUpload, error) {
+	start := files.NewUploadSessionStartArg()
+	start.Close = size <= uploadChunkSize
+
+	var res *files.UploadSessionStartResult
+	err := c.guard(ctx, OpUpload, func() (err error) {
+		res, err = c.dbx.UploadSessionStart(start, io.LimitReader(r, uploadChunkSize))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start upload session: %w", err)
+	}
+
+	staged := &StagedUpload{sessionID: res.SessionId, size: uint64(min(size, uploadChunkSize))}
+	for int64(staged.size) < size {
+		arg := files.NewUploadSessionAppendArg(staged.cursor())
+		arg.Close = int64(staged.size)+uploadChunkSize >= size
+		err := c.guard(ctx, OpUpload, func() error {
+			return c.dbx.UploadSessionAppendV2(arg, io.LimitReader(r, uploadChunkSize))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to append to upload session: %w", err)
+		}
+		staged.size += uint64(min(size-int64(staged.size), uploadChunkSize))
+	}
+
+	return staged, nil
+}
+
+// BatchEntry is a staged upload to commit to a Dropbox path
+type BatchEntry struct {
+	Path    string
+	Staged  *StagedUpload
+	Options UploadOptions
+}
+
+// BatchResult is the outcome of committing one BatchEntry. Err wraps
+// ErrConflict when the target path conflicts with the upload options.
+type BatchResult struct {
+	File *FileInfo
+	Err  error
+}
+
+// CommitBatch commits up to MaxBatchCommit staged uploads in a single
+// request. The results are in the order of the entries.
+func (c *Client) CommitBatch(ctx context.Context, entries []BatchEntry) ([]BatchResult, error) {
+	if len(entries) > MaxBatchCommit {
+		return nil, fmt.Errorf("failed to commit uploads: %d files exceed the batch limit of %d", len(entries), MaxBatchCommit)
+	}
+
+	args := make([]*files.UploadSessionFinishArg, len(entries))
+	for i, entry := range entries {
+		args[i] = files.NewUploadSessionFinishArg(entry.Staged.cursor(), commitInfo(entry.Path, entry.Options))
+	}
+
+	var res *files.UploadSessionFinishBatchResult
+	err := c.guard(ctx, OpUpload, func() (err error) {
+		res, err = c.dbx.UploadSessionFinishBatchV2(files.NewUploadSessionFinishBatchArg(args))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit uploads: %w", err)
+	}
+	if len(res.Entries) != len(entries) {
+		return nil, fmt.Errorf("failed to commit uploads: got %d results for %d files", len(res.Entries), len(entries))
+	}
+
+	results := make([]BatchResult, len(entries))
+	for i, entry := range res.Entries {
+		results[i] = c.batchResult(entries[i].Path, entry)
+	}
+
+	slog.Debug("Committed upload batch", slog.Int("files", len(entries)))
+	return results, nil
+}
+
+// batchResult converts one finish_batch result entry
+func (c *Client) batchResult(remotePath string, entry *files.UploadSessionFinishBatchResultEntry) BatchResult {
+	if entry.Tag == files.UploadSessionFinishBatchResultEntrySuccess && entry.Success != nil {
+		info := c.convertToFileInfo(entry.Success)
+		return BatchResult{File: &info}
+	}
+
+	failure := entry.Failure
+	if failure == nil {
+		return BatchResult{Err: fmt.Errorf("failed to upload file %s: no result", remotePath)}
+	}
+	if failure.Tag == files.UploadSessionFinishErrorPath && failure.Path != nil && failure.Path.Tag == files.WriteErrorConflict {
+		return BatchResult{Err: fmt.Errorf("failed to upload file %s: %w", remotePath, ErrConflict)}
+	}
+
+	reason := failure.Tag
+	if failure.Path != nil {
+		reason += "/" + failure.Path.Tag
+	}
+	return BatchResult{Err: fmt.Errorf("failed to upload file %s: %s", remotePath, reason)}
+}
+
+// isWriteConflict reports whether an upload failed because of what is
+// already at the target path
+func isWriteConflict(err error) bool {
+	var uploadErr files.UploadAPIError
+	if errors.As(err, &uploadErr) && uploadErr.EndpointError != nil {
+		path := uploadErr.EndpointError.Path
+		return path != nil && path.Reason != nil && path.Reason.Tag == files.WriteErrorConflict
+	}
+
+	var finishErr files.UploadSessionFinishAPIError
+	if errors.As(err, &finishErr) && finishErr.EndpointError != nil {
+		path := finishErr.EndpointError.Path
+		return path != nil && path.Tag == files.WriteErrorConflict
+	}
+
+	return false
+}
+
+// IsNotFound reports whether a listing or metadata lookup failed because the
+// path doesn't exist
+func IsNotFound(err error) bool {
+	var listErr files.ListFolderAPIError
+	if errors.As(err, &listErr) && listErr.EndpointError != nil {
+		path := listErr.EndpointError.Path
+		return path != nil && path.Tag == files.LookupErrorNotFound
+	}
+	var metadataErr files.GetMetadataAPIError
+	if errors.As(err, &metadataErr) && metadataErr.EndpointError != nil {
+		path := metadataErr.EndpointError.Path
+		return path != nil && path.Tag == files.LookupErrorNotFound
+	}
+	return false
+}
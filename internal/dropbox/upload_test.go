@@ -0,0 +1,99 @@
+package dropbox
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+func TestCommitInfo(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 500, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name       string
+		opts       UploadOptions
+		wantMode   string
+		wantStrict bool
+	}{
+		{name: "add", opts: UploadOptions{}, wantMode: files.WriteModeAdd},
+		{name: "overwrite", opts: UploadOptions{Overwrite: true}, wantMode: files.WriteModeOverwrite},
+		{name: "update", opts: UploadOptions{Rev: "abc"}, wantMode: files.WriteModeUpdate, wantStrict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.ModTime = modTime
+			commit := commitInfo("/a.txt", tt.opts)
+			if commit.Mode.Tag != tt.wantMode {
+				t.Errorf("mode = %q, want %q", commit.Mode.Tag, tt.wantMode)
+			}
+			if commit.StrictConflict != tt.wantStrict {
+				t.Errorf("strict conflict = %v, want %v", commit.StrictConflict, tt.wantStrict)
+			}
+			if want := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC); !commit.ClientModified.Equal(want) || commit.ClientModified.Location() != time.UTC {
+				t.Errorf("client modified = %v, want %v", commit.ClientModified, want)
+			}
+		})
+	}
+}
+
+func TestBatchResult(t *testing.T) {
+	success := &files.UploadSessionFinishBatchResultEntry{
+		Tagged:  dropbox.Tagged{Tag: files.UploadSessionFinishBatchResultEntrySuccess},
+		Success: &files.FileMetadata{Metadata: files.Metadata{PathLower: "/a.txt"}, Rev: "r1"},
+	}
+	conflict := &files.UploadSessionFinishBatchResultEntry{
+		Tagged: dropbox.Tagged{Tag: files.UploadSessionFinishBatchResultEntryFailure},
+		Failure: &files.UploadSessionFinishError{
+			Tagged: dropbox.Tagged{Tag: files.UploadSessionFinishErrorPath},
+			Path:   &files.WriteError{Tagged: dropbox.Tagged{Tag: files.WriteErrorConflict}},
+		},
+	}
+	tooMany := &files.UploadSessionFinishBatchResultEntry{
+		Tagged: dropbox.Tagged{Tag: files.UploadSessionFinishBatchResultEntryFailure},
+		Failure: &files.UploadSessionFinishError{
+			Tagged: dropbox.Tagged{Tag: files.UploadSessionFinishErrorTooManyWriteOperations},
+		},
+	}
+
+	c := &Client{}
+
+	if res := c.batchResult("/a.txt", success); res.Err != nil || res.File == nil || res.File.Rev != "r1" {
+		t.Errorf("success result = %+v", res)
+	}
+	if res := c.batchResult("/a.txt", conflict); !errors.Is(res.Err, ErrConflict) {
+		t.Errorf("conflict result error = %v, want ErrConflict", res.Err)
+	}
+	if res := c.batchResult("/a.txt", tooMany); res.Err == nil || errors.Is(res.Err, ErrConflict) {
+		t.Errorf("failure result error = %v, want non-conflict error", res.Err)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	missingFolder := files.ListFolderAPIError{EndpointError: &files.ListFolderError{Path: &files.LookupError{}}}
+	missingFolder.EndpointError.Path.Tag = files.LookupErrorNotFound
+	missingPath := files.GetMetadataAPIError{EndpointError: &files.GetMetadataError{Path: &files.LookupError{}}}
+	missingPath.EndpointError.Path.Tag = files.LookupErrorNotFound
+	restricted := files.GetMetadataAPIError{EndpointError: &files.GetMetadataError{Path: &files.LookupError{}}}
+	restricted.EndpointError.Path.Tag = files.LookupErrorRestrictedContent
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "missing folder", err: fmt.Errorf("failed to list: %w", missingFolder), want: true},
+		{name: "missing path", err: fmt.Errorf("failed to get metadata: %w", missingPath), want: true},
+		{name: "restricted", err: restricted},
+		{name: "other", err: errors.New("unexpected")},
+	}
+	for _, tt := range tests {
+		if got := IsNotFound(tt.err); got != tt.want {
+			t.Errorf("IsNotFound(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package filetypes groups the files of a backup by extension or by class of
+// content, to show what takes up the space and which patterns are worth
+// excluding.
+package filetypes
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// Ways to group files
+const (
+	ByExtension = "extension"
+	ByClass     = "class"
+)
+
+// Classes of content, as returned by Class
+const (
+	ClassDocument = "document"
+	ClassImage    = "image"
+	ClassVideo    = "video"
+	ClassAudio    = "audio"
+	ClassArchive  = "archive"
+	ClassCode     = "code"
+	ClassData     = "data"
+	ClassOther    = "other"
+)
+
+// NoExtension is the group of files without an extension
+const NoExtension = "(none)"
+
+// classes maps extensions to their class of content, following the major
+// MIME types where there is one
+var classes = map[string]string{}
+
+func init() {
+	for class, extensions := range map[string][]string{
+		ClassDocument: {".pdf", ".doc", ".docx", ".odt", ".rtf", ".txt", ".md", ".pages", ".xls", ".xlsx", ".ods", ".numbers", ".csv", ".ppt", ".pptx", ".odp", ".key", ".epub", ".paper", ".gdoc", ".gsheet", ".gslides"},
+		ClassImage:    {".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tif", ".tiff", ".webp", ".heic", ".heif", ".svg", ".raw", ".cr2", ".cr3", ".nef", ".arw", ".dng", ".psd", ".ai"},
+		ClassVideo:    {".mp4", ".mov", ".m4v", ".avi", ".mkv", ".wmv", ".webm", ".mts", ".3gp"},
+		ClassAudio:    {".mp3", ".m4a", ".aac", ".wav", ".flac", ".ogg", ".aiff", ".wma"},
+		ClassArchive:  {".zip", ".tar", ".gz", ".tgz", ".bz2", ".xz", ".7z", ".rar", ".dmg", ".iso"},
+		ClassCode:     {".go", ".py", ".js", ".ts", ".java", ".c", ".h", ".cpp", ".cs", ".rb", ".php", ".sh", ".html", ".css", ".ipynb"},
+		ClassData:     {".json", ".xml", ".yaml", ".yml", ".sql", ".db", ".sqlite", ".parquet"},
+	} {
+		for _, ext := range extensions {
+			classes[ext] = class
+		}
+	}
+}
+
+// Extension returns the lower-case extension of a path including the dot,
+// or NoExtension
+func Extension(p string) string {
+	ext := strings.ToLower(path.Ext(p))
+	if ext == "" || ext == "." {
+		return NoExtension
+	}
+	return ext
+}
+
+// Class returns the class of content of a path judged by its extension
+func Class(p string) string {
+	if class, ok := classes[Extension(p)]; ok {
+		return class
+	}
+	return ClassOther
+}
+
+// File is a file of a group
+type File struct {
+	Path string `json:"path"`
+	Size uint64 `json:"size"`
+}
+
+// Group sums up the files of an extension or class
+type Group struct {
+	Name    string `json:"name"`
+	Files   int    `json:"files"`
+	Bytes   uint64 `json:"bytes"`
+	Largest []File `json:"largest"`
+}
+
+// Summarize groups the entries of a manifest by extension or class, largest
+// group first, keeping the given number of largest files of each group
+func Summarize(entries map[string]manifest.Entry, by string, largest int) []Group {
+	groupOf := Extension
+	if by == ByClass {
+		groupOf = Class
+	}
+
+	groups := make(map[string]*Group)
+	for key, entry := range entries {
+		_, remotePath := manifest.SplitKey(key)
+		name := groupOf(remotePath)
+		g, ok := groups[name]
+		if !ok {
+			g = &Group{Name: name}
+			groups[name] = g
+		}
+		g.Files++
+		g.Bytes += entry.Size
+		g.Largest = keepLargest(g.Largest, File{Path: key, Size: entry.Size}, largest)
+	}
+
+	result := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bytes != result[j].Bytes {
+			return result[i].Bytes > result[j].Bytes
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// keepLargest adds a file to a list of at most n files sorted by size,
+// dropping the smallest
+func keepLargest(files []File, file File, n int) []File {
+	if n <= 0 {
+		return files
+	}
+	i := sort.Search(len(files), func(i int) bool {
+		return files[i].Size < file.Size || files[i].Size == file.Size && files[i].Path > file.Path
+	})
+	if i >= n {
+		return files
+	}
+	files = append(files, File{})
+	copy(files[i+1:], files[i:])
+	files[i] = file
+	if len(files) > n {
+		files = files[:n]
+	}
+	return files
+}
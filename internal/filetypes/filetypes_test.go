@@ -0,0 +1,63 @@
+package filetypes
+
+import (
+	"reflect"
+	"testing"
+
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+func TestExtensionAndClass(t *testing.T) {
+	tests := []struct {
+		path      string
+		extension string
+		class     string
+	}{
+		{path: "/photos/IMG_0001.JPG", extension: ".jpg", class: ClassImage},
+		{path: "/docs/report.pdf", extension: ".pdf", class: ClassDocument},
+		{path: "/backups/site.tar.gz", extension: ".gz", class: ClassArchive},
+		{path: "/notes/README", extension: NoExtension, class: ClassOther},
+		{path: "/odd/name.", extension: NoExtension, class: ClassOther},
+		{path: "/misc/file.xyz", extension: ".xyz", class: ClassOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := Extension(tt.path); got != tt.extension {
+				t.Errorf("Extension() = %q, want %q", got, tt.extension)
+			}
+			if got := Class(tt.path); got != tt.class {
+				t.Errorf("Class() = %q, want %q", got, tt.class)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	entries := map[string]manifest.Entry{
+		"/a.jpg":           {Size: 100},
+		"/b.png":           {Size: 300},
+		"/c.jpg":           {Size: 200},
+		"/d.mp4":           {Size: 1000},
+		"/ns-123/team.JPG": {Size: 50},
+	}
+
+	byExtension := Summarize(entries, ByExtension, 2)
+	want := []Group{
+		{Name: ".mp4", Files: 1, Bytes: 1000, Largest: []File{{Path: "/d.mp4", Size: 1000}}},
+		{Name: ".jpg", Files: 3, Bytes: 350, Largest: []File{{Path: "/c.jpg", Size: 200}, {Path: "/a.jpg", Size: 100}}},
+		{Name: ".png", Files: 1, Bytes: 300, Largest: []File{{Path: "/b.png", Size: 300}}},
+	}
+	if !reflect.DeepEqual(byExtension, want) {
+		t.Errorf("Summarize(extension) = %+v, want %+v", byExtension, want)
+	}
+
+	byClass := Summarize(entries, ByClass, 0)
+	want = []Group{
+		{Name: ClassVideo, Files: 1, Bytes: 1000},
+		{Name: ClassImage, Files: 4, Bytes: 650},
+	}
+	if !reflect.DeepEqual(byClass, want) {
+		t.Errorf("Summarize(class) = %+v, want %+v", byClass, want)
+	}
+}
@@ -0,0 +1,197 @@
+// Package filter decides which Dropbox paths a backup or restore covers.
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Filter selects paths by include and exclude patterns. Patterns ending in
+// "/" match directories, other patterns are matched against the file name
+// and the full path, and "@file" refers to a file of patterns (see
+// ReadPatternFiles).
+type Filter struct {
+	Include []string
+	Exclude []string
+	// Files holds the patterns of the files referred to by "@file" by file
+	// name; a file missing from it matches nothing
+	Files map[string][]string
+}
+
+// NotIncluded is the Rule of a Decision excluding a path because it matches
+// none of the include patterns
+const NotIncluded = "(not included)"
+
+// Decision explains whether a path is covered and which rule decided it
+type Decision struct {
+	Allowed bool
+	// Rule is the exclude pattern or NotIncluded for excluded paths, the
+	// include pattern for included paths, and empty for paths allowed
+	// because there are no include patterns
+	Rule string
+}
+
+// Allows reports whether a path (e.g. "/docs/report.pdf") is covered: it must
+// match an include pattern, if there are any, and no exclude pattern
+func (f Filter) Allows(path string) bool {
+	return f.Explain(path).Allowed
+}
+
+// Explain decides whether a path is covered like Allows and reports the rule
+// that decided it. Of several matching patterns the first one listed wins.
+func (f Filter) Explain(path string) Decision {
+	var included string
+	if len(f.Include) > 0 {
+		pattern, ok := f.MatchingPattern(f.Include, path)
+		if !ok {
+			return Decision{Rule: NotIncluded}
+		}
+		included = pattern
+	}
+	if pattern, ok := f.MatchingPattern(f.Exclude, path); ok {
+		return Decision{Rule: pattern}
+	}
+	return Decision{Allowed: true, Rule: included}
+}
+
+// Match reports whether a path matches any of the patterns
+func (f Filter) Match(patterns []string, path string) bool {
+	_, ok := f.MatchingPattern(patterns, path)
+	return ok
+}
+
+// MatchingPattern returns the first of the patterns a path matches, reading
+// "@file" patterns from Files. A match in a pattern file is returned as
+// "@file (pattern)".
+func (f Filter) MatchingPattern(patterns []string, path string) (string, bool) {
+	for _, pattern := range patterns {
+		if file, ok := strings.CutPrefix(pattern, "@"); ok {
+			if inner, ok := f.MatchingPattern(f.Files[file], path); ok {
+				return pattern + " (" + inner + ")", true
+			}
+			continue
+		}
+		if matches(pattern, path) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// Roots returns the folders (e.g. "/documents") that hold every path the
+// filter can allow, when each include pattern is a folder anchored at the
+// root such as "/Documents/". Listing only these folders finds the same files
+// as listing the whole account.
+func (f Filter) Roots() ([]string, bool) {
+	if len(f.Include) == 0 {
+		return nil, false
+	}
+	roots := make([]string, 0, len(f.Include))
+	for _, pattern := range f.Include {
+		if !strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") || len(pattern) < 3 || hasMeta(pattern) {
+			return nil, false
+		}
+		roots = append(roots, strings.TrimSuffix(pattern, "/"))
+	}
+	return roots, true
+}
+
+// Extensions returns the file extensions (e.g. "pdf") of every path the
+// filter can allow, when each include pattern is of the form "*.pdf"
+func (f Filter) Extensions() ([]string, bool) {
+	if len(f.Include) == 0 {
+		return nil, false
+	}
+	extensions := make([]string, 0, len(f.Include))
+	for _, pattern := range f.Include {
+		extension, ok := strings.CutPrefix(pattern, "*.")
+		if !ok || extension == "" || hasMeta(extension) || strings.ContainsAny(extension, "./") {
+			return nil, false
+		}
+		extensions = append(extensions, extension)
+	}
+	return extensions, true
+}
+
+// hasMeta reports whether a pattern contains wildcards
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// matches reports whether a path matches one pattern. Dropbox paths are
+// separated by "/" on every platform, so they are matched with package path
+// rather than path/filepath.
+func matches(pattern, p string) bool {
+	// Handle directory patterns; those starting with "/" are anchored at
+	// the root, others match a folder of that name anywhere
+	if strings.HasSuffix(pattern, "/") {
+		if strings.HasPrefix(pattern, "/") {
+			return strings.HasPrefix(p, pattern)
+		}
+		return strings.HasPrefix(p, pattern) || strings.Contains(p, "/"+pattern)
+	}
+
+	// Handle file patterns
+	if matched, _ := path.Match(pattern, path.Base(p)); matched {
+		return true
+	}
+
+	// Handle path patterns
+	matched, _ := path.Match(pattern, p)
+	return matched
+}
+
+// ReadPatternFiles reads the files referred to by "@file" in lists of
+// patterns and returns their patterns by file name, for Filter.Files. Each
+// line of a file holds a pattern like those of --exclude; blank lines and
+// lines starting with # are ignored. Relative file names are resolved
+// against the working directory.
+func ReadPatternFiles(lists ...[]string) (map[string][]string, error) {
+	files := make(map[string][]string)
+	for _, patterns := range lists {
+		for _, pattern := range patterns {
+			file, ok := strings.CutPrefix(pattern, "@")
+			if !ok {
+				continue
+			}
+			if _, ok := files[file]; ok {
+				continue
+			}
+			parsed, err := readPatternFile(file)
+			if err != nil {
+				return nil, err
+			}
+			files[file] = parsed
+		}
+	}
+	return files, nil
+}
+
+// readPatternFile parses a file of patterns, one per line
+func readPatternFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pattern file: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		if strings.HasPrefix(pattern, "@") {
+			return nil, fmt.Errorf("invalid pattern file %s:%d: pattern files can't refer to other pattern files", file, line)
+		}
+		patterns = append(patterns, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pattern file %s: %w", file, err)
+	}
+	return patterns, nil
+}
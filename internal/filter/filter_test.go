@@ -0,0 +1,234 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		path   string
+		want   bool
+	}{
+		{"no patterns", Filter{}, "/docs/a.txt", true},
+		{"excluded file pattern", Filter{Exclude: []string{"*.tmp"}}, "/docs/a.tmp", false},
+		{"excluded directory", Filter{Exclude: []string{"cache/"}}, "/app/cache/x.bin", false},
+		{"included directory", Filter{Include: []string{"docs/"}}, "/docs/a.txt", true},
+		{"not included", Filter{Include: []string{"docs/"}}, "/photos/a.jpg", false},
+		{"included but excluded", Filter{Include: []string{"docs/"}, Exclude: []string{"*.tmp"}}, "/docs/a.tmp", false},
+		{"included by file pattern", Filter{Include: []string{"*.pdf", "*.txt"}}, "/a/b/report.pdf", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Allows(tt.path); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	f := Filter{Include: []string{"docs/", "*.pdf"}, Exclude: []string{"*.tmp", "drafts/"}}
+
+	tests := []struct {
+		path string
+		want Decision
+	}{
+		{"/docs/a.txt", Decision{Allowed: true, Rule: "docs/"}},
+		{"/photos/report.pdf", Decision{Allowed: true, Rule: "*.pdf"}},
+		{"/photos/a.jpg", Decision{Rule: NotIncluded}},
+		{"/docs/a.tmp", Decision{Rule: "*.tmp"}},
+		{"/docs/drafts/a.txt", Decision{Rule: "drafts/"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := f.Explain(tt.path); got != tt.want {
+				t.Errorf("Explain(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got := (Filter{}).Explain("/a.txt"); got != (Decision{Allowed: true}) {
+		t.Errorf("Explain() without patterns = %+v, want allowed without rule", got)
+	}
+}
+
+func TestRoots(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		want    []string
+		ok      bool
+	}{
+		{"no include patterns", nil, nil, false},
+		{"anchored folders", []string{"/docs/", "/photos/2024/"}, []string{"/docs", "/photos/2024"}, true},
+		{"unanchored folder", []string{"/docs/", "cache/"}, nil, false},
+		{"file pattern", []string{"/docs/", "*.pdf"}, nil, false},
+		{"wildcard folder", []string{"/docs/*/"}, nil, false},
+		{"root", []string{"/"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Filter{Include: tt.include}.Roots()
+			if ok != tt.ok || !slices.Equal(got, tt.want) {
+				t.Errorf("Roots() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		want    []string
+		ok      bool
+	}{
+		{"no include patterns", nil, nil, false},
+		{"extensions", []string{"*.pdf", "*.docx"}, []string{"pdf", "docx"}, true},
+		{"double extension", []string{"*.tar.gz"}, nil, false},
+		{"wildcard extension", []string{"*.do?"}, nil, false},
+		{"name pattern", []string{"*.pdf", "report*"}, nil, false},
+		{"folder", []string{"*.pdf", "/docs/"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Filter{Include: tt.include}.Extensions()
+			if ok != tt.ok || !slices.Equal(got, tt.want) {
+				t.Errorf("Extensions() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func FuzzFilter(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"/docs/", "/docs/a.txt"},
+		{"cache/", "/app/cache/x.bin"},
+		{"*.pdf", "/a/b/report.pdf"},
+		{"/docs/", "/x//docs/a.txt"},
+		{"[", "/["},
+		{`\`, `/a\b`},
+		{"*.tmp", "/\u202e\u0000/x.tmp"},
+		{"ü/", "/\xff\xfe/ü/x"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, pattern, p string) {
+		filter := Filter{Include: []string{pattern}}
+		decision := filter.Explain(p)
+		if decision.Allowed != filter.Allows(p) {
+			t.Fatalf("Explain(%q) = %+v disagrees with Allows", p, decision)
+		}
+		if decision.Allowed && decision.Rule != pattern {
+			t.Fatalf("Explain(%q) = %+v, want rule %q", p, decision, pattern)
+		}
+
+		// Listing only the roots or searching only the extensions must find
+		// every path the filter allows, for paths as Dropbox reports them
+		if !strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") || strings.Contains(p, "//") || !decision.Allowed {
+			return
+		}
+		if roots, ok := filter.Roots(); ok {
+			if !slices.ContainsFunc(roots, func(root string) bool { return strings.HasPrefix(p, root+"/") }) {
+				t.Fatalf("%q is allowed by %q but outside the roots %v", p, pattern, roots)
+			}
+		}
+		if extensions, ok := filter.Extensions(); ok {
+			if !slices.ContainsFunc(extensions, func(ext string) bool { return strings.HasSuffix(p, "."+ext) }) {
+				t.Fatalf("%q is allowed by %q but has none of the extensions %v", p, pattern, extensions)
+			}
+		}
+	})
+}
+
+func TestPatternFiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".backupignore")
+	content := "# Build output\n\n*.tmp\n  cache/  \r\n/Private/\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ReadPatternFiles([]string{"@" + file})
+	if err != nil {
+		t.Fatalf("ReadPatternFiles() error = %v", err)
+	}
+
+	f := Filter{Exclude: []string{"*.log", "@" + file}, Files: files}
+	tests := []struct {
+		path string
+		want Decision
+	}{
+		{"/docs/a.txt", Decision{Allowed: true}},
+		{"/docs/a.tmp", Decision{Rule: "@" + file + " (*.tmp)"}},
+		{"/app/cache/x.bin", Decision{Rule: "@" + file + " (cache/)"}},
+		{"/Private/keys.txt", Decision{Rule: "@" + file + " (/Private/)"}},
+		{"/docs/Private/a.txt", Decision{Allowed: true}},
+		{"/docs/a.log", Decision{Rule: "*.log"}},
+		{"/docs/# Build output", Decision{Allowed: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := f.Explain(tt.path); got != tt.want {
+				t.Errorf("Explain(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	included := Filter{Include: []string{"@" + file}, Files: files}
+	if !included.Allows("/a/b.tmp") || included.Allows("/a/b.txt") {
+		t.Error("pattern file doesn't select included paths")
+	}
+
+	// Reading again picks up edits, without changing filters built before
+	if err := os.WriteFile(file, []byte("*.bak\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := ReadPatternFiles([]string{"@" + file})
+	if err != nil {
+		t.Fatalf("ReadPatternFiles() error = %v", err)
+	}
+	if edited := (Filter{Exclude: f.Exclude, Files: reread}); !edited.Allows("/docs/a.tmp") || edited.Allows("/docs/a.bak") {
+		t.Error("reread pattern file still applies its old patterns")
+	}
+	if f.Allows("/docs/a.tmp") {
+		t.Error("filter built before the edit changed")
+	}
+
+	// A file that wasn't read matches nothing
+	if !(Filter{Exclude: []string{"@" + file}}).Allows("/docs/a.bak") {
+		t.Error("Allows() = false for a pattern file that wasn't read, want allowed")
+	}
+}
+
+func TestReadPatternFilesErrors(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "nested")
+	if err := os.WriteFile(nested, []byte("*.tmp\n@other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{"missing file", []string{"*.tmp", "@" + filepath.Join(dir, "missing")}},
+		{"nested pattern file", []string{"@" + nested}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadPatternFiles(nil, tt.patterns); err == nil {
+				t.Error("ReadPatternFiles() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Package hook runs a command after a backup, e.g. to replicate the files
+// the run changed to a second location with rclone or rsync, without
+// copying the whole backup again.
+package hook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Environment variables passed to the command in addition to the
+// changed-paths file argument
+const (
+	EnvBackupDir    = "DROPBOX_BACKUP_DIR"
+	EnvChangedFiles = "DROPBOX_CHANGED_FILES"
+	EnvDeletedFiles = "DROPBOX_DELETED_FILES"
+)
+
+// Changes are the files a run wrote and deleted, relative to the backup
+// directory with forward slashes
+type Changes struct {
+	Changed []string
+	Deleted []string
+}
+
+// Empty reports whether the run changed nothing
+func (c Changes) Empty() bool {
+	return len(c.Changed) == 0 && len(c.Deleted) == 0
+}
+
+// Run runs a command through the shell with the path of a file listing the
+// changed files, one per line, as its last argument. The list suits rclone's
+// and rsync's --files-from; the deleted files are listed in the file named by
+// DROPBOX_DELETED_FILES. Both files are removed once the command exits.
+func Run(ctx context.Context, command, backupDir string, changes Changes) error {
+	dir, err := os.MkdirTemp("", "dropbox-backup-hook-")
+	if err != nil {
+		return fmt.Errorf("failed to create changed-paths file: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	changedFile := filepath.Join(dir, "changed.txt")
+	deletedFile := filepath.Join(dir, "deleted.txt")
+	if err := writeList(changedFile, changes.Changed); err != nil {
+		return err
+	}
+	if err := writeList(deletedFile, changes.Deleted); err != nil {
+		return err
+	}
+
+	name, args := shellCommand(runtime.GOOS, command, changedFile)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = backupDir
+	cmd.Env = append(os.Environ(),
+		EnvBackupDir+"="+backupDir,
+		EnvChangedFiles+"="+changedFile,
+		EnvDeletedFiles+"="+deletedFile,
+	)
+	// Standard output is kept for the run's own report
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	slog.Info("Running post-run command",
+		slog.String("command", command),
+		slog.Int("changed", len(changes.Changed)),
+		slog.Int("deleted", len(changes.Deleted)),
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post-run command failed: %w", err)
+	}
+	return nil
+}
+
+// writeList writes paths to a file, sorted and one per line
+func writeList(path string, paths []string) error {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	var b strings.Builder
+	for _, p := range sorted {
+		b.WriteString(p)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write changed-paths file: %w", err)
+	}
+	return nil
+}
+
+// shellCommand returns the command running a command line through the
+// shell of an OS with an extra argument
+func shellCommand(goos, command, arg string) (string, []string) {
+	if goos == "windows" {
+		return "cmd", []string{"/C", command + ` "` + arg + `"`}
+	}
+	// The argument is passed as $1 rather than pasted into the command line,
+	// so no path needs quoting
+	return "sh", []string{"-c", command + ` "$1"`, "sh", arg}
+}
@@ -0,0 +1,63 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestShellCommand(t *testing.T) {
+	tests := []struct {
+		goos     string
+		wantName string
+		wantArgs []string
+	}{
+		{goos: "linux", wantName: "sh", wantArgs: []string{"-c", `rclone copy "$1"`, "sh", "/tmp/changed.txt"}},
+		{goos: "darwin", wantName: "sh", wantArgs: []string{"-c", `rclone copy "$1"`, "sh", "/tmp/changed.txt"}},
+		{goos: "windows", wantName: "cmd", wantArgs: []string{"/C", `rclone copy "/tmp/changed.txt"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			name, args := shellCommand(tt.goos, "rclone copy", "/tmp/changed.txt")
+			if name != tt.wantName || !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("shellCommand() = %s %q, want %s %q", name, args, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	changes := Changes{Changed: []string{"docs/b.txt", "a.txt"}, Deleted: []string{"old.txt"}}
+
+	// Copy both lists somewhere they survive the command, which runs in the
+	// backup directory, and check the argument names the changed-paths file
+	command := `cp "$DROPBOX_CHANGED_FILES" changed && cp "$DROPBOX_DELETED_FILES" deleted && cmp changed`
+	if err := Run(t.Context(), command, dir, changes); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	changed, err := os.ReadFile(filepath.Join(dir, "changed"))
+	if err != nil || string(changed) != "a.txt\ndocs/b.txt\n" {
+		t.Errorf("changed-paths file = %q, %v; want a.txt and docs/b.txt", changed, err)
+	}
+	deleted, err := os.ReadFile(filepath.Join(dir, "deleted"))
+	if err != nil || string(deleted) != "old.txt\n" {
+		t.Errorf("deleted-paths file = %q, %v; want old.txt", deleted, err)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	if err := Run(t.Context(), "exit 3; true", t.TempDir(), Changes{Changed: []string{"a.txt"}}); err == nil {
+		t.Error("Run() of a failing command succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+package install
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formatEnv returns the content of an environment file with the values of
+// the Dropbox variables
+func formatEnv(getenv func(string) string) string {
+	var b strings.Builder
+	b.WriteString("# Environment of scheduled backups: one NAME=value per line, without quotes\n")
+	for _, name := range envVars {
+		fmt.Fprintf(&b, "%s=%s\n", name, getenv(name))
+	}
+	return b.String()
+}
+
+// SaveEnvFile writes the environment file of the configuration directory
+// with the given values of the Dropbox variables, replacing an existing one.
+// It is readable by its owner only as it holds credentials.
+func SaveEnvFile(configDir string, values map[string]string) (string, error) {
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create configuration directory: %w", err)
+	}
+
+	path := filepath.Join(configDir, EnvFileName)
+	content := formatEnv(func(name string) string { return values[name] })
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write environment file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write environment file: %w", err)
+	}
+	return path, nil
+}
+
+// ReadEnvFile reads the variables of an environment file. Blank lines and
+// comments are ignored, and quotes around a value are removed.
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(name)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read environment file: %w", err)
+	}
+	return values, nil
+}
+
+// LoadEnvFile sets the variables of the environment file of the
+// configuration directory that aren't set already, so commands run by hand
+// use the configuration written by setup or install. A missing file sets
+// nothing.
+func LoadEnvFile(configDir string) error {
+	values, err := ReadEnvFile(filepath.Join(configDir, EnvFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read environment file: %w", err)
+	}
+	for name, value := range values {
+		if _, set := os.LookupEnv(name); !set && value != "" {
+			if err := os.Setenv(name, value); err != nil {
+				return fmt.Errorf("failed to set %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSaveEnvFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+	values := map[string]string{
+		"DROPBOX_CLIENT_ID":     "app-key",
+		"DROPBOX_BACKUP_FOLDER": "/srv/backup",
+	}
+
+	for range 2 {
+		path, err := SaveEnvFile(dir, values)
+		if err != nil {
+			t.Fatalf("SaveEnvFile() error = %v", err)
+		}
+		if path != filepath.Join(dir, EnvFileName) {
+			t.Errorf("SaveEnvFile() = %s, want the environment file of the directory", path)
+		}
+		if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("environment file mode = %v, want 0600", info.Mode().Perm())
+		}
+		values["DROPBOX_CLIENT_ID"] = "new-key"
+	}
+
+	got, err := ReadEnvFile(filepath.Join(dir, EnvFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["DROPBOX_CLIENT_ID"] != "new-key" || got["DROPBOX_BACKUP_FOLDER"] != "/srv/backup" {
+		t.Errorf("ReadEnvFile() = %v, want the saved values", got)
+	}
+	if _, ok := got["DROPBOX_REFRESH_TOKEN"]; !ok {
+		t.Errorf("ReadEnvFile() = %v, want the unset variables listed too", got)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), EnvFileName)
+	content := "# comment\n\nDROPBOX_CLIENT_ID=\"app-key\"\nDROPBOX_CLIENT_SECRET='secret'\n  DROPBOX_BACKUP_FOLDER = /srv/my backup \nnot a variable\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DROPBOX_CLIENT_ID":     "app-key",
+		"DROPBOX_CLIENT_SECRET": "secret",
+		"DROPBOX_BACKUP_FOLDER": "/srv/my backup",
+	}
+	if len(got) != len(want) {
+		t.Errorf("ReadEnvFile() = %v, want %v", got, want)
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("ReadEnvFile()[%s] = %q, want %q", name, got[name], value)
+		}
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := LoadEnvFile(dir); err != nil {
+		t.Fatalf("LoadEnvFile() without a file error = %v", err)
+	}
+
+	content := "DROPBOX_CLIENT_ID=from-file\nDROPBOX_CLIENT_SECRET=secret\nDROPBOX_REFRESH_TOKEN=\n"
+	if err := os.WriteFile(filepath.Join(dir, EnvFileName), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DROPBOX_CLIENT_ID", "from-env")
+	t.Setenv("DROPBOX_CLIENT_SECRET", "")
+	os.Unsetenv("DROPBOX_CLIENT_SECRET")
+	t.Setenv("DROPBOX_REFRESH_TOKEN", "")
+	os.Unsetenv("DROPBOX_REFRESH_TOKEN")
+
+	if err := LoadEnvFile(dir); err != nil {
+		t.Fatalf("LoadEnvFile() error = %v", err)
+	}
+	if got := os.Getenv("DROPBOX_CLIENT_ID"); got != "from-env" {
+		t.Errorf("DROPBOX_CLIENT_ID = %q, want the environment to win", got)
+	}
+	if got := os.Getenv("DROPBOX_CLIENT_SECRET"); got != "secret" {
+		t.Errorf("DROPBOX_CLIENT_SECRET = %q, want it loaded from the file", got)
+	}
+	if _, set := os.LookupEnv("DROPBOX_REFRESH_TOKEN"); set {
+		t.Error("empty value in the file was set")
+	}
+}
@@ -0,0 +1,237 @@
+// Package install copies the program to a standard location, sets up its
+// configuration directory and registers a daily backup with the scheduler of
+// the OS (cron, launchd or the Windows Task Scheduler), and reverses that.
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Name is the name of the installed program and of its scheduled entry
+const Name = "create-dropbox-backup-folder"
+
+// EnvFileName is the file in the configuration directory holding the
+// environment of scheduled backups, and LogFileName the file they log to
+const (
+	EnvFileName = "backup.env"
+	LogFileName = "backup.log"
+)
+
+// envVars are written to a new environment file, with their current values
+var envVars = []string{
+	"DROPBOX_CLIENT_ID",
+	"DROPBOX_CLIENT_SECRET",
+	"DROPBOX_ACCESS_TOKEN",
+	"DROPBOX_REFRESH_TOKEN",
+	"DROPBOX_TOKEN_STORE",
+	"DROPBOX_BACKUP_FOLDER",
+}
+
+// Options locate an installation
+type Options struct {
+	// BinDir receives the program; empty means DefaultBinDir
+	BinDir string
+	// ConfigDir holds the environment file and the log of scheduled backups
+	ConfigDir string
+	// Schedule is the daily time of backups, e.g. "02:30"; empty registers none
+	Schedule string
+}
+
+// Result tells what Install did
+type Result struct {
+	Binary     string
+	EnvFile    string
+	EnvCreated bool
+	// Scheduler names the scheduler the backup was registered with, if any
+	Scheduler string
+}
+
+// DefaultBinDir returns the standard directory for programs of all users
+func DefaultBinDir() string {
+	if runtime.GOOS == "windows" {
+		programFiles := os.Getenv("ProgramFiles")
+		if programFiles == "" {
+			programFiles = `C:\Program Files`
+		}
+		return filepath.Join(programFiles, "CreateDropboxBackupFolder")
+	}
+	return "/usr/local/bin"
+}
+
+// binaryName returns the file name of the program on an OS
+func binaryName(goos string) string {
+	if goos == "windows" {
+		return Name + ".exe"
+	}
+	return Name
+}
+
+// ParseSchedule parses a daily time like "02:30"
+func ParseSchedule(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid schedule %q (want a daily time like 02:30)", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// Install copies the running program to the bin directory, creates the
+// configuration directory with an environment file for scheduled backups and
+// registers the schedule, if any. An existing environment file is kept.
+func Install(opts Options) (Result, error) {
+	if opts.BinDir == "" {
+		opts.BinDir = DefaultBinDir()
+	}
+	var hour, minute int
+	if opts.Schedule != "" {
+		var err error
+		if hour, minute, err = ParseSchedule(opts.Schedule); err != nil {
+			return Result{}, err
+		}
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to locate the running program: %w", err)
+	}
+	result := Result{
+		Binary:  filepath.Join(opts.BinDir, binaryName(runtime.GOOS)),
+		EnvFile: filepath.Join(opts.ConfigDir, EnvFileName),
+	}
+	if err := copyBinary(self, result.Binary); err != nil {
+		return Result{}, err
+	}
+
+	if err := os.MkdirAll(opts.ConfigDir, 0700); err != nil {
+		return Result{}, fmt.Errorf("failed to create configuration directory: %w", err)
+	}
+	if result.EnvCreated, err = writeEnvFile(result.EnvFile, os.Getenv); err != nil {
+		return Result{}, err
+	}
+
+	if opts.Schedule == "" {
+		return result, nil
+	}
+	s, ok := newScheduler(runtime.GOOS)
+	if !ok {
+		return Result{}, fmt.Errorf("scheduling backups is not supported on %s", runtime.GOOS)
+	}
+	entry := Entry{
+		Binary:  result.Binary,
+		EnvFile: result.EnvFile,
+		LogFile: filepath.Join(opts.ConfigDir, LogFileName),
+		Hour:    hour,
+		Minute:  minute,
+	}
+	if err := s.register(entry); err != nil {
+		return Result{}, fmt.Errorf("failed to register the backup with %s: %w", s.name(), err)
+	}
+	result.Scheduler = s.name()
+	return result, nil
+}
+
+// Uninstall removes the scheduled backup and the installed program, and
+// with purge the configuration directory
+func Uninstall(opts Options, purge bool) error {
+	if opts.BinDir == "" {
+		opts.BinDir = DefaultBinDir()
+	}
+
+	if s, ok := newScheduler(runtime.GOOS); ok {
+		if err := s.unregister(); err != nil {
+			return fmt.Errorf("failed to remove the backup from %s: %w", s.name(), err)
+		}
+	}
+
+	binary := filepath.Join(opts.BinDir, binaryName(runtime.GOOS))
+	if err := os.Remove(binary); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", binary, err)
+	}
+
+	if purge {
+		if err := os.RemoveAll(opts.ConfigDir); err != nil {
+			return fmt.Errorf("failed to remove configuration directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// copyBinary copies the program to its installed path, replacing an older
+// copy atomically; installing the installed program again does nothing
+func copyBinary(src, dst string) error {
+	if same, err := samePath(src, dst); err == nil && same {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read the running program: %w", err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to install to %s: %w", filepath.Dir(dst), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to copy program: %w", err)
+	}
+	if err := tmp.Chmod(0755); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to make program executable: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to copy program: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("failed to install %s: %w", dst, err)
+	}
+	return nil
+}
+
+// samePath reports whether two paths name the same file
+func samePath(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
+
+// writeEnvFile creates the environment file of scheduled backups, filled
+// with the current values of the Dropbox variables, readable by its owner
+// only as it holds credentials. An existing file is kept.
+func writeEnvFile(path string, getenv func(string) string) (created bool, err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create environment file: %w", err)
+	}
+
+	if _, err := f.WriteString(formatEnv(getenv)); err != nil {
+		f.Close()
+		return false, fmt.Errorf("failed to write environment file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("failed to write environment file: %w", err)
+	}
+	return true, nil
+}
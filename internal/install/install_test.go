@@ -0,0 +1,97 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		in           string
+		hour, minute int
+		wantErr      bool
+	}{
+		{in: "02:30", hour: 2, minute: 30},
+		{in: "23:05", hour: 23, minute: 5},
+		{in: "25:00", wantErr: true},
+		{in: "daily", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			hour, minute, err := ParseSchedule(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if hour != tt.hour || minute != tt.minute {
+				t.Errorf("ParseSchedule() = %d:%d, want %d:%d", hour, minute, tt.hour, tt.minute)
+			}
+		})
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), EnvFileName)
+	getenv := func(name string) string {
+		if name == "DROPBOX_CLIENT_ID" {
+			return "app-key"
+		}
+		return ""
+	}
+
+	created, err := writeEnvFile(path, getenv)
+	if err != nil || !created {
+		t.Fatalf("writeEnvFile() = %v, %v; want created", created, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "DROPBOX_CLIENT_ID=app-key\n") || !strings.Contains(string(data), "DROPBOX_REFRESH_TOKEN=\n") {
+		t.Errorf("environment file = %q", data)
+	}
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("environment file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// An edited file is kept
+	if err := os.WriteFile(path, []byte("DROPBOX_CLIENT_ID=mine\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if created, err := writeEnvFile(path, getenv); err != nil || created {
+		t.Errorf("writeEnvFile() over an existing file = %v, %v; want kept", created, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "DROPBOX_CLIENT_ID=mine\n" {
+		t.Errorf("environment file = %q, want it unchanged", data)
+	}
+}
+
+func TestCopyBinary(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "build", Name)
+	dst := filepath.Join(dir, "bin", Name)
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := copyBinary(src, dst); err != nil {
+			t.Fatalf("copyBinary() error = %v", err)
+		}
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "v2" {
+		t.Errorf("installed program = %q, want v2", data)
+	}
+	if err := copyBinary(dst, dst); err != nil {
+		t.Errorf("copyBinary() onto itself = %v", err)
+	}
+	if info, err := os.Stat(dst); err != nil || runtime.GOOS != "windows" && info.Mode().Perm() != 0755 {
+		t.Errorf("installed program = %v, %v; want mode 0755", info, err)
+	}
+}
@@ -0,0 +1,266 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Entry is a daily backup registered with a scheduler
+type Entry struct {
+	Binary  string
+	EnvFile string
+	LogFile string
+	Hour    int
+	Minute  int
+}
+
+// scheduler registers the daily backup with the scheduler of an OS
+type scheduler interface {
+	name() string
+	register(entry Entry) error
+	unregister() error
+}
+
+// newScheduler returns the scheduler of an OS
+func newScheduler(goos string) (scheduler, bool) {
+	switch goos {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return cron{}, true
+	case "darwin":
+		return launchd{}, true
+	case "windows":
+		return taskScheduler{}, true
+	}
+	return nil, false
+}
+
+// run runs a command, returning its output and its error output in errors
+func run(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellCommand returns the shell command running a backup with the
+// environment file, appending its output to the log file
+func shellCommand(entry Entry) string {
+	return fmt.Sprintf("set -a; . %s; set +a; exec %s --no-input --loglevel info >> %s 2>&1",
+		shellQuote(entry.EnvFile), shellQuote(entry.Binary), shellQuote(entry.LogFile))
+}
+
+// cron registers the backup in the crontab of the user
+type cron struct{}
+
+// cronMarker ends the crontab line of the backup, so it can be found again
+const cronMarker = "# " + Name
+
+func (cron) name() string { return "cron" }
+
+func (c cron) register(entry Entry) error {
+	current, err := c.read()
+	if err != nil {
+		return err
+	}
+	return c.write(withCronEntry(current, cronLine(entry)))
+}
+
+func (c cron) unregister() error {
+	current, err := c.read()
+	if err != nil {
+		return err
+	}
+	if updated := withoutCronEntry(current); updated != current {
+		return c.write(updated)
+	}
+	return nil
+}
+
+// read returns the crontab of the user; a user without one has none
+func (cron) read() (string, error) {
+	out, err := run(nil, "crontab", "-l")
+	if err != nil {
+		if strings.Contains(err.Error(), "no crontab") {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (cron) write(crontab string) error {
+	_, err := run([]byte(crontab), "crontab", "-")
+	return err
+}
+
+// cronLine returns the crontab line of a daily backup
+func cronLine(entry Entry) string {
+	return fmt.Sprintf("%d %d * * * %s %s", entry.Minute, entry.Hour, shellCommand(entry), cronMarker)
+}
+
+// withCronEntry replaces the backup line of a crontab, or adds it
+func withCronEntry(crontab, line string) string {
+	return withoutCronEntry(crontab) + line + "\n"
+}
+
+// withoutCronEntry removes the backup line from a crontab
+func withoutCronEntry(crontab string) string {
+	var kept strings.Builder
+	for _, line := range strings.SplitAfter(crontab, "\n") {
+		if line == "" || strings.HasSuffix(strings.TrimRight(line, "\n"), cronMarker) {
+			continue
+		}
+		kept.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			kept.WriteString("\n")
+		}
+	}
+	return kept.String()
+}
+
+// launchd registers the backup as a launch agent of the user
+type launchd struct{}
+
+// launchdLabel identifies the launch agent
+const launchdLabel = "com.behrconsulting." + Name
+
+func (launchd) name() string { return "launchd" }
+
+// plistPath returns the location of the launch agent
+func (launchd) plistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+func (l launchd) register(entry Entry) error {
+	path, err := l.plistPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Reload an agent registered before, so the new schedule applies
+	_, _ = run(nil, "launchctl", "unload", path)
+	if err := os.WriteFile(path, []byte(launchdPlist(entry)), 0644); err != nil {
+		return err
+	}
+	_, err = run(nil, "launchctl", "load", "-w", path)
+	return err
+}
+
+func (l launchd) unregister() error {
+	path, err := l.plistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	_, _ = run(nil, "launchctl", "unload", "-w", path)
+	return os.Remove(path)
+}
+
+// launchdPlist returns the launch agent running a daily backup
+func launchdPlist(entry Entry) string {
+	var command strings.Builder
+	xmlEscape(&command, shellCommand(entry))
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchdLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>/bin/sh</string>
+		<string>-c</string>
+		<string>` + command.String() + `</string>
+	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+		<key>Hour</key>
+		<integer>` + fmt.Sprint(entry.Hour) + `</integer>
+		<key>Minute</key>
+		<integer>` + fmt.Sprint(entry.Minute) + `</integer>
+	</dict>
+</dict>
+</plist>
+`
+}
+
+// xmlEscape writes s with the characters special in XML escaped
+func xmlEscape(b *strings.Builder, s string) {
+	b.WriteString(strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s))
+}
+
+// taskScheduler registers the backup as a task of the Windows Task Scheduler
+type taskScheduler struct{}
+
+// taskName names the scheduled task
+const taskName = "CreateDropboxBackupFolder"
+
+// wrapperName is the batch file next to the environment file that loads it
+// and runs the backup, as tasks can't read environment files themselves
+const wrapperName = "backup.cmd"
+
+func (taskScheduler) name() string { return "Task Scheduler" }
+
+func (taskScheduler) register(entry Entry) error {
+	wrapper := filepath.Join(filepath.Dir(entry.EnvFile), wrapperName)
+	if err := os.WriteFile(wrapper, []byte(windowsWrapper(entry)), 0600); err != nil {
+		return err
+	}
+	_, err := run(nil, "schtasks", schtasksArgs(entry, wrapper)...)
+	return err
+}
+
+func (taskScheduler) unregister() error {
+	if _, err := run(nil, "schtasks", "/Query", "/TN", taskName); err != nil {
+		return nil // Not registered
+	}
+	_, err := run(nil, "schtasks", "/Delete", "/F", "/TN", taskName)
+	return err
+}
+
+// schtasksArgs returns the arguments of schtasks creating the daily task
+func schtasksArgs(entry Entry, wrapper string) []string {
+	return []string{
+		"/Create", "/F",
+		"/TN", taskName,
+		"/SC", "DAILY",
+		"/ST", fmt.Sprintf("%02d:%02d", entry.Hour, entry.Minute),
+		"/TR", `"` + wrapper + `"`,
+	}
+}
+
+// windowsWrapper returns the batch file setting the variables of the
+// environment file and running a backup
+func windowsWrapper(entry Entry) string {
+	return strings.Join([]string{
+		"@echo off",
+		fmt.Sprintf(`for /f "usebackq eol=# tokens=1,* delims==" %%%%a in ("%s") do set "%%%%a=%%%%b"`, entry.EnvFile),
+		fmt.Sprintf(`"%s" --no-input --loglevel info >> "%s" 2>&1`, entry.Binary, entry.LogFile),
+		"",
+	}, "\r\n")
+}
@@ -0,0 +1,103 @@
+package install
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testEntry = Entry{
+	Binary:  "/usr/local/bin/create-dropbox-backup-folder",
+	EnvFile: "/home/me/.config/create-dropbox-backup-folder/backup.env",
+	LogFile: "/home/me/.config/create-dropbox-backup-folder/backup.log",
+	Hour:    2,
+	Minute:  30,
+}
+
+func TestCronEntry(t *testing.T) {
+	line := cronLine(testEntry)
+	want := "30 2 * * * set -a; . '/home/me/.config/create-dropbox-backup-folder/backup.env'; set +a; " +
+		"exec '/usr/local/bin/create-dropbox-backup-folder' --no-input --loglevel info >> '/home/me/.config/create-dropbox-backup-folder/backup.log' 2>&1 " +
+		"# create-dropbox-backup-folder"
+	if line != want {
+		t.Errorf("cronLine() = %q, want %q", line, want)
+	}
+
+	existing := "MAILTO=me\n0 * * * * other-job\n"
+	added := withCronEntry(existing, line)
+	if added != existing+line+"\n" {
+		t.Errorf("withCronEntry() = %q", added)
+	}
+	// Registering again replaces the line instead of adding another
+	if again := withCronEntry(added, line); again != added {
+		t.Errorf("withCronEntry() twice = %q, want %q", again, added)
+	}
+	if removed := withoutCronEntry(added); removed != existing {
+		t.Errorf("withoutCronEntry() = %q, want %q", removed, existing)
+	}
+	if got := withoutCronEntry("0 * * * * other-job"); got != "0 * * * * other-job\n" {
+		t.Errorf("withoutCronEntry() without trailing newline = %q", got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got, want := shellQuote("/Users/o'neil/bin"), `'/Users/o'\''neil/bin'`; got != want {
+		t.Errorf("shellQuote() = %s, want %s", got, want)
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	entry := testEntry
+	entry.Binary = "/opt/a&b/create-dropbox-backup-folder"
+	plist := launchdPlist(entry)
+
+	for _, want := range []string{
+		"<string>" + launchdLabel + "</string>",
+		"exec '/opt/a&amp;b/create-dropbox-backup-folder' --no-input --loglevel info &gt;&gt; ",
+		"<key>Hour</key>\n\t\t<integer>2</integer>",
+		"<key>Minute</key>\n\t\t<integer>30</integer>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("launchdPlist() lacks %q:\n%s", want, plist)
+		}
+	}
+}
+
+func TestTaskScheduler(t *testing.T) {
+	entry := Entry{
+		Binary:  `C:\Program Files\CreateDropboxBackupFolder\create-dropbox-backup-folder.exe`,
+		EnvFile: `C:\Users\me\AppData\Roaming\create-dropbox-backup-folder\backup.env`,
+		LogFile: `C:\Users\me\AppData\Roaming\create-dropbox-backup-folder\backup.log`,
+		Hour:    2,
+		Minute:  5,
+	}
+	wrapper := `C:\Users\me\AppData\Roaming\create-dropbox-backup-folder\backup.cmd`
+
+	args := schtasksArgs(entry, wrapper)
+	want := []string{"/Create", "/F", "/TN", taskName, "/SC", "DAILY", "/ST", "02:05", "/TR", `"` + wrapper + `"`}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("schtasksArgs() = %q, want %q", args, want)
+	}
+
+	script := windowsWrapper(entry)
+	for _, want := range []string{
+		`for /f "usebackq eol=# tokens=1,* delims==" %%a in ("` + entry.EnvFile + `") do set "%%a=%%b"`,
+		`"` + entry.Binary + `" --no-input --loglevel info >> "` + entry.LogFile + `" 2>&1`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("windowsWrapper() lacks %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestNewScheduler(t *testing.T) {
+	for goos, want := range map[string]string{"linux": "cron", "darwin": "launchd", "windows": "Task Scheduler"} {
+		s, ok := newScheduler(goos)
+		if !ok || s.name() != want {
+			t.Errorf("newScheduler(%s) = %v, %v; want %s", goos, s, ok, want)
+		}
+	}
+	if _, ok := newScheduler("plan9"); ok {
+		t.Error("newScheduler(plan9) is supported")
+	}
+}
@@ -0,0 +1,149 @@
+// Package listing keeps the Dropbox listing of a backup directory together
+// with cursors Dropbox can resume from, so later runs only fetch the entries
+// that changed instead of listing the whole account again.
+package listing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// FileName is the name of the listing file kept in the backup directory root
+const FileName = ".dropbox-backup-listing.json"
+
+// currentVersion is the listing format version written by Save
+const currentVersion = 1
+
+// State is the listing of the folders of a backup as of its cursors
+type State struct {
+	Version int `json:"version"`
+
+	// Paths are the listed Dropbox folders; empty means the whole account
+	Paths []string `json:"paths"`
+
+	// Cursors continue the listing of each of Roots(Paths), in order
+	Cursors []string `json:"cursors"`
+
+	// Files are the listed files and folders
+	Files []dropbox.FileInfo `json:"files"`
+
+	// Updated is when the listing was last brought up to date
+	Updated time.Time `json:"updated"`
+}
+
+// Path returns the path of the listing file in a backup directory
+func Path(backupDir string) string {
+	return filepath.Join(backupDir, FileName)
+}
+
+// Roots returns the folders a listing of paths takes cursors for: the paths
+// themselves, or the account root if there are none
+func Roots(paths []string) []string {
+	if len(paths) == 0 {
+		return []string{""}
+	}
+	return paths
+}
+
+// Load reads the listing of a backup directory; nil if there is none
+func Load(backupDir string) (*State, error) {
+	path := Path(backupDir)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read listing: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse listing %s: %w", path, err)
+	}
+	if s.Version > currentVersion {
+		return nil, fmt.Errorf("listing %s has unsupported version %d", path, s.Version)
+	}
+	return &s, nil
+}
+
+// Covers reports whether the listing is of exactly these folders and can be
+// continued
+func (s *State) Covers(paths []string) bool {
+	return slices.Equal(s.Paths, paths) && len(s.Cursors) == len(Roots(paths))
+}
+
+// Apply updates the listing with changes from Dropbox. Deleted folders take
+// everything below them along; added or modified entries replace the entry
+// at their path.
+func (s *State) Apply(changes *dropbox.Changes) {
+	if len(changes.Deleted) > 0 {
+		deleted := make(map[string]bool, len(changes.Deleted))
+		for _, path := range changes.Deleted {
+			deleted[path] = true
+		}
+		s.Files = slices.DeleteFunc(s.Files, func(file dropbox.FileInfo) bool {
+			return isDeleted(file.Path, deleted)
+		})
+	}
+
+	index := make(map[string]int, len(s.Files))
+	for i, file := range s.Files {
+		index[file.Path] = i
+	}
+	for _, entry := range changes.Entries {
+		if i, ok := index[entry.Path]; ok {
+			s.Files[i] = entry
+			continue
+		}
+		index[entry.Path] = len(s.Files)
+		s.Files = append(s.Files, entry)
+	}
+}
+
+// isDeleted reports whether a path or one of the folders above it was deleted
+func isDeleted(path string, deleted map[string]bool) bool {
+	for path != "" && path != "/" {
+		if deleted[path] {
+			return true
+		}
+		path = path[:max(strings.LastIndex(path, "/"), 0)]
+	}
+	return false
+}
+
+// Save writes the listing to a backup directory, replacing the previous one
+// only once it is completely written
+func (s *State) Save(backupDir string) error {
+	s.Version = currentVersion
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode listing: %w", err)
+	}
+
+	path := Path(backupDir)
+	tmp, err := os.CreateTemp(backupDir, "."+FileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create listing: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write listing: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write listing: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace listing: %w", err)
+	}
+	return nil
+}
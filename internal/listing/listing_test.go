@@ -0,0 +1,92 @@
+package listing
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+func paths(files []dropbox.FileInfo) []string {
+	var out []string
+	for _, file := range files {
+		out = append(out, file.Path)
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	s := &State{Files: []dropbox.FileInfo{
+		{Path: "/docs", IsFolder: true},
+		{Path: "/docs/a.txt", Rev: "1"},
+		{Path: "/docs/old", IsFolder: true},
+		{Path: "/docs/old/b.txt"},
+		{Path: "/docs/older.txt"},
+		{Path: "/c.txt"},
+	}}
+
+	s.Apply(&dropbox.Changes{
+		Deleted: []string{"/docs/old", "/c.txt"},
+		Entries: []dropbox.FileInfo{
+			{Path: "/docs/a.txt", Rev: "2"},
+			{Path: "/docs/new.txt"},
+		},
+	})
+
+	want := []string{"/docs", "/docs/a.txt", "/docs/older.txt", "/docs/new.txt"}
+	if got := paths(s.Files); !slices.Equal(got, want) {
+		t.Errorf("Apply() files = %v, want %v", got, want)
+	}
+	if s.Files[1].Rev != "2" {
+		t.Errorf("modified file has rev %q, want 2", s.Files[1].Rev)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		name  string
+		state State
+		paths []string
+		want  bool
+	}{
+		{name: "whole account", state: State{Cursors: []string{"c"}}, want: true},
+		{name: "same folders", state: State{Paths: []string{"/a", "/b"}, Cursors: []string{"c1", "c2"}}, paths: []string{"/a", "/b"}, want: true},
+		{name: "other folders", state: State{Paths: []string{"/a"}, Cursors: []string{"c"}}, paths: []string{"/b"}, want: false},
+		{name: "whole account before", state: State{Cursors: []string{"c"}}, paths: []string{"/a"}, want: false},
+		{name: "no cursors", state: State{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.Covers(tt.paths); got != tt.want {
+				t.Errorf("Covers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	if s, err := Load(dir); err != nil || s != nil {
+		t.Fatalf("Load() of a new backup = %v, %v; want nil", s, err)
+	}
+
+	saved := &State{
+		Paths:   []string{"/docs"},
+		Cursors: []string{"cursor"},
+		Files:   []dropbox.FileInfo{{Path: "/docs/a.txt", Size: 3, Rev: "1", ModTime: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	if err := saved.Save(dir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !loaded.Covers([]string{"/docs"}) || len(loaded.Files) != 1 || !loaded.Files[0].ModTime.Equal(saved.Files[0].ModTime) {
+		t.Errorf("Load() = %+v, want %+v", loaded, saved)
+	}
+}
@@ -0,0 +1,174 @@
+// Package localfstest provides file systems for tests of code using
+// localfs.FS
+package localfstest
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"create-dropbox-backup-folder/internal/localfs"
+)
+
+// Operations a Faults file system can fail
+const (
+	OpStat   = "stat"
+	OpOpen   = "open"
+	OpCreate = "create" // OpenFile, and WriteFile
+	OpWrite  = "write"  // Write to a file opened with OpenFile
+	OpRead   = "read"   // ReadDir and ReadFile
+	OpMkdir  = "mkdir"
+	OpRemove = "remove"
+	OpRename = "rename"
+)
+
+// Faults wraps a file system, by default the real one. Operations on chosen
+// paths fail with chosen errors, and chosen paths report chosen modification
+// times. It is safe for concurrent use.
+type Faults struct {
+	localfs.FS
+
+	mu       sync.Mutex
+	faults   map[fault]error
+	modTimes map[string]time.Time
+}
+
+type fault struct {
+	op, path string
+}
+
+// New returns a Faults file system over the real one
+func New() *Faults {
+	return &Faults{FS: localfs.OS}
+}
+
+// Fail makes op on path fail with err; an empty path fails op on every path
+func (f *Faults) Fail(op, path string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.faults == nil {
+		f.faults = make(map[fault]error)
+	}
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	f.faults[fault{op, path}] = err
+}
+
+// SetModTime makes Stat report modTime for path
+func (f *Faults) SetModTime(path string, modTime time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.modTimes == nil {
+		f.modTimes = make(map[string]time.Time)
+	}
+	f.modTimes[filepath.Clean(path)] = modTime
+}
+
+// err returns the error op on path fails with, if any
+func (f *Faults) err(op, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err, ok := f.faults[fault{op, filepath.Clean(path)}]; ok {
+		return err
+	}
+	return f.faults[fault{op, ""}]
+}
+
+func (f *Faults) Stat(name string) (fs.FileInfo, error) {
+	if err := f.err(OpStat, name); err != nil {
+		return nil, &fs.PathError{Op: OpStat, Path: name, Err: err}
+	}
+	info, err := f.FS.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if modTime, ok := f.modTimes[filepath.Clean(name)]; ok {
+		return modTimeInfo{info, modTime}, nil
+	}
+	return info, nil
+}
+
+func (f *Faults) Open(name string) (localfs.File, error) {
+	if err := f.err(OpOpen, name); err != nil {
+		return nil, &fs.PathError{Op: OpOpen, Path: name, Err: err}
+	}
+	return f.FS.Open(name)
+}
+
+func (f *Faults) OpenFile(name string, flag int, perm fs.FileMode) (localfs.File, error) {
+	if err := f.err(OpCreate, name); err != nil {
+		return nil, &fs.PathError{Op: OpCreate, Path: name, Err: err}
+	}
+	file, err := f.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.err(OpWrite, name); err != nil {
+		return faultyFile{file, err}, nil
+	}
+	return file, nil
+}
+
+func (f *Faults) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.err(OpRead, name); err != nil {
+		return nil, &fs.PathError{Op: OpRead, Path: name, Err: err}
+	}
+	return f.FS.ReadDir(name)
+}
+
+func (f *Faults) ReadFile(name string) ([]byte, error) {
+	if err := f.err(OpRead, name); err != nil {
+		return nil, &fs.PathError{Op: OpRead, Path: name, Err: err}
+	}
+	return f.FS.ReadFile(name)
+}
+
+func (f *Faults) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := f.err(OpCreate, name); err != nil {
+		return &fs.PathError{Op: OpCreate, Path: name, Err: err}
+	}
+	return f.FS.WriteFile(name, data, perm)
+}
+
+func (f *Faults) MkdirAll(path string, perm fs.FileMode) error {
+	if err := f.err(OpMkdir, path); err != nil {
+		return &fs.PathError{Op: OpMkdir, Path: path, Err: err}
+	}
+	return f.FS.MkdirAll(path, perm)
+}
+
+func (f *Faults) Remove(name string) error {
+	if err := f.err(OpRemove, name); err != nil {
+		return &fs.PathError{Op: OpRemove, Path: name, Err: err}
+	}
+	return f.FS.Remove(name)
+}
+
+func (f *Faults) Rename(oldpath, newpath string) error {
+	if err := f.err(OpRename, oldpath); err != nil {
+		return &fs.PathError{Op: OpRename, Path: oldpath, Err: err}
+	}
+	return f.FS.Rename(oldpath, newpath)
+}
+
+// modTimeInfo reports a chosen modification time
+type modTimeInfo struct {
+	fs.FileInfo
+	modTime time.Time
+}
+
+func (i modTimeInfo) ModTime() time.Time { return i.modTime }
+
+// faultyFile fails every write
+type faultyFile struct {
+	localfs.File
+	err error
+}
+
+func (f faultyFile) Write(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: OpWrite, Path: f.Name(), Err: f.err}
+}
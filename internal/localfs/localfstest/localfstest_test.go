@@ -0,0 +1,53 @@
+package localfstest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFaults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := New()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys.SetModTime(path, modTime)
+	fsys.Fail(OpRemove, path, os.ErrPermission)
+	fsys.Fail(OpWrite, "", syscall.ENOSPC)
+
+	info, err := fsys.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) || info.Size() != 5 {
+		t.Errorf("Stat() = %v, %d bytes; want %v, 5 bytes", info.ModTime(), info.Size(), modTime)
+	}
+
+	if err := fsys.Remove(path); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Remove() error = %v, want permission denied", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file removed despite the fault: %v", err)
+	}
+
+	f, err := fsys.OpenFile(filepath.Join(dir, "b.txt"), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("x")); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Write() error = %v, want ENOSPC", err)
+	}
+
+	// Other operations and paths are untouched
+	if _, err := fsys.ReadFile(path); err != nil {
+		t.Errorf("ReadFile() error = %v", err)
+	}
+}
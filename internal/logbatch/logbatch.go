@@ -0,0 +1,201 @@
+// Package logbatch writes log records from a single goroutine, so lines of
+// concurrent workers never interleave and workers don't wait on the output,
+// and summarizes frequent per-file records instead of writing each one.
+package logbatch
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// queueSize is the number of records that can wait for the writer before
+// logging blocks
+const queueSize = 1024
+
+type perFileKey struct{}
+
+// PerFile marks ctx so records logged with it below warning level are
+// summarized by a Handler with an interval, see New
+func PerFile(ctx context.Context) context.Context {
+	return context.WithValue(ctx, perFileKey{}, true)
+}
+
+// Handler is a slog.Handler passing records to another handler from a single
+// goroutine. Flush or Close it to write the records still queued.
+type Handler struct {
+	next slog.Handler
+	w    *writer
+}
+
+// writer is the goroutine shared by a Handler and those derived from it
+type writer struct {
+	interval time.Duration
+	records  chan entry
+	done     chan struct{}
+
+	// mu guards closed; Handle holds it for reading while queueing
+	mu     sync.RWMutex
+	closed bool
+}
+
+// entry is a queued record with the handler that writes it, or a flush
+// request closing flushed once everything queued before it is written
+type entry struct {
+	handler slog.Handler
+	record  slog.Record
+	perFile bool
+	flushed chan struct{}
+}
+
+// batch counts the records with one message since the last summary
+type batch struct {
+	handler slog.Handler
+	last    slog.Record
+	count   int
+}
+
+// New creates a handler writing to next. With a non-zero interval, records
+// logged with a PerFile context below warning level are written once per
+// interval as one record per message, with their count and the attributes of
+// the last one; warnings and errors are always written at once.
+func New(next slog.Handler, interval time.Duration) *Handler {
+	w := &writer{
+		interval: interval,
+		records:  make(chan entry, queueSize),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return &Handler{next: next, w: w}
+}
+
+// Enabled reports whether the next handler writes records of a level
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle queues a record for the writer goroutine, or writes it directly once
+// the handler is closed
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	h.w.mu.RLock()
+	defer h.w.mu.RUnlock()
+	if h.w.closed {
+		return h.next.Handle(ctx, r)
+	}
+
+	perFile := h.w.interval > 0 && r.Level < slog.LevelWarn && ctx.Value(perFileKey{}) != nil
+	h.w.records <- entry{handler: h.next, record: r.Clone(), perFile: perFile}
+	return nil
+}
+
+// WithAttrs returns a handler adding attributes, sharing the writer of h
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), w: h.w}
+}
+
+// WithGroup returns a handler qualifying attributes, sharing the writer of h
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), w: h.w}
+}
+
+// Flush writes the queued records and pending summaries, e.g. before output
+// that must follow them, and keeps summarizing afterwards
+func (h *Handler) Flush() {
+	h.w.mu.RLock()
+	if h.w.closed {
+		h.w.mu.RUnlock()
+		return
+	}
+	flushed := make(chan struct{})
+	h.w.records <- entry{flushed: flushed}
+	h.w.mu.RUnlock()
+
+	<-flushed
+}
+
+// Close writes the queued records and pending summaries and stops the
+// writer; records logged afterwards are written directly
+func (h *Handler) Close() {
+	h.w.mu.Lock()
+	if h.w.closed {
+		h.w.mu.Unlock()
+		return
+	}
+	h.w.closed = true
+	close(h.w.records)
+	h.w.mu.Unlock()
+
+	<-h.w.done
+}
+
+// run writes queued records until the queue is closed, summarizing per-file
+// records every interval
+func (w *writer) run() {
+	defer close(w.done)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	batches := make(map[string]*batch)
+	var order []string
+	flush := func() {
+		for _, msg := range order {
+			batches[msg].write()
+		}
+		clear(batches)
+		order = order[:0]
+	}
+
+	for {
+		select {
+		case e, ok := <-w.records:
+			if !ok {
+				flush()
+				return
+			}
+			if e.flushed != nil {
+				flush()
+				close(e.flushed)
+				continue
+			}
+			if !e.perFile {
+				e.handler.Handle(context.Background(), e.record)
+				continue
+			}
+
+			b, ok := batches[e.record.Message]
+			if !ok {
+				b = &batch{}
+				batches[e.record.Message] = b
+				order = append(order, e.record.Message)
+			}
+			b.handler, b.last = e.handler, e.record
+			b.count++
+		case <-tick:
+			flush()
+		}
+	}
+}
+
+// write writes the last record of a batch, with the count of records and the
+// attributes of the last one grouped under "last" if there were several
+func (b *batch) write() {
+	if b.count == 1 {
+		b.handler.Handle(context.Background(), b.last)
+		return
+	}
+
+	var last []any
+	b.last.Attrs(func(a slog.Attr) bool {
+		last = append(last, a)
+		return true
+	})
+	summary := slog.NewRecord(b.last.Time, b.last.Level, b.last.Message, 0)
+	summary.AddAttrs(slog.Int("count", b.count), slog.Group("last", last...))
+	b.handler.Handle(context.Background(), summary)
+}
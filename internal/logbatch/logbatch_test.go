@@ -0,0 +1,105 @@
+package logbatch
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newLogger returns a logger writing through a Handler to a buffer without
+// timestamps
+func newLogger(interval time.Duration) (*slog.Logger, *Handler, *bytes.Buffer) {
+	var buf bytes.Buffer
+	text := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	h := New(text, interval)
+	return slog.New(h), h, &buf
+}
+
+func TestHandlerWritesInOrder(t *testing.T) {
+	logger, h, buf := newLogger(0)
+
+	ctx := PerFile(context.Background())
+	logger.Info("first")
+	logger.InfoContext(ctx, "Downloaded file", "path", "/a")
+	logger.With("run", 1).Warn("second")
+	h.Close()
+	logger.Info("after close")
+
+	want := "level=INFO msg=first\n" +
+		"level=INFO msg=\"Downloaded file\" path=/a\n" +
+		"level=WARN msg=second run=1\n" +
+		"level=INFO msg=\"after close\"\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestHandlerSummarizesPerFileRecords(t *testing.T) {
+	logger, h, buf := newLogger(time.Hour)
+
+	ctx := PerFile(context.Background())
+	var wg sync.WaitGroup
+	for _, path := range []string{"/a", "/b", "/c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.InfoContext(ctx, "Downloaded file", "path", path)
+		}()
+	}
+	wg.Wait()
+	logger.InfoContext(ctx, "Resuming download", "path", "/d")
+	logger.WarnContext(ctx, "Failed to download file", "path", "/e")
+	logger.Info("Backup completed")
+	h.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("output = %q, want 4 lines", buf.String())
+	}
+	if lines[0] != `level=WARN msg="Failed to download file" path=/e` || lines[1] != `level=INFO msg="Backup completed"` {
+		t.Errorf("immediate lines = %q", lines[:2])
+	}
+	if !strings.HasPrefix(lines[2], `level=INFO msg="Downloaded file" count=3 last.path=/`) {
+		t.Errorf("summary = %q, want a count of 3", lines[2])
+	}
+	if lines[3] != `level=INFO msg="Resuming download" path=/d` {
+		t.Errorf("single record = %q, want it unchanged", lines[3])
+	}
+}
+
+func TestHandlerFlush(t *testing.T) {
+	logger, h, buf := newLogger(time.Hour)
+	defer h.Close()
+
+	ctx := PerFile(context.Background())
+	logger.InfoContext(ctx, "Downloaded file", "path", "/a")
+	logger.InfoContext(ctx, "Downloaded file", "path", "/b")
+	logger.Info("Backup completed")
+	h.Flush()
+
+	want := "level=INFO msg=\"Backup completed\"\n" +
+		"level=INFO msg=\"Downloaded file\" count=2 last.path=/b\n"
+	if buf.String() != want {
+		t.Fatalf("output after Flush = %q, want %q", buf.String(), want)
+	}
+
+	// Still summarizing after a flush
+	logger.InfoContext(ctx, "Downloaded file", "path", "/c")
+	logger.InfoContext(ctx, "Downloaded file", "path", "/d")
+	h.Close()
+	if !strings.HasSuffix(buf.String(), "level=INFO msg=\"Downloaded file\" count=2 last.path=/d\n") {
+		t.Errorf("output after Close = %q, want a second summary", buf.String())
+	}
+	h.Flush() // No-op once closed
+}
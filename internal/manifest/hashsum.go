@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HashSumFileName is the name of the rclone hash sum file kept in the backup
+// directory root
+const HashSumFileName = ".dropbox-backup-hashes.sum"
+
+// HashSumPath returns the hash sum file location for a backup directory
+func HashSumPath(backupDir string) string {
+	return filepath.Join(backupDir, HashSumFileName)
+}
+
+// HashSum returns the recorded Dropbox content hashes in the format of
+// "rclone hashsum dropbox": one "<hash>  <path>" line per file, with paths
+// relative to the backup directory, sorted by path. Exported files and files
+// without a recorded hash are left out, as are paths rclone can't represent.
+func (m *Manifest) HashSum() []byte {
+	m.mu.Lock()
+	lines := make(map[string]string, len(m.files))
+	for key, entry := range m.files {
+		// Cold files aren't in the backup directory the sum file describes
+		if entry.Exported || entry.Cold || entry.ContentHash == "" {
+			continue
+		}
+		localPath := strings.TrimPrefix(LocalPath(key, entry), "/")
+		if strings.ContainsAny(localPath, "\r\n") {
+			continue
+		}
+		lines[localPath] = entry.ContentHash
+	}
+	m.mu.Unlock()
+
+	paths := make([]string, 0, len(lines))
+	for localPath := range lines {
+		paths = append(paths, localPath)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, localPath := range paths {
+		fmt.Fprintf(&b, "%s  %s\n", lines[localPath], localPath)
+	}
+	return []byte(b.String())
+}
+
+// SaveHashSum atomically writes the rclone hash sum file next to the manifest
+func (m *Manifest) SaveHashSum() error {
+	path := HashSumPath(filepath.Dir(m.path))
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create hash sum file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(m.HashSum()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write hash sum file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write hash sum file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace hash sum file: %w", err)
+	}
+	return nil
+}
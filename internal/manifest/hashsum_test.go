@@ -0,0 +1,36 @@
+package manifest
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHashSum(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Set("/docs/b.txt", Entry{Rev: "2", ContentHash: "bbb"})
+	m.Set("/docs/a.txt", Entry{Rev: "1", ContentHash: "aaa"})
+	m.Set("/notes.paper", Entry{Rev: "3", ContentHash: "ccc", Exported: true})
+	m.Set("/empty.txt", Entry{Rev: "4"})
+	m.Set("/shared/plan.txt", Entry{Rev: "5", ContentHash: "ddd", LocalPath: "/Shared/team/plan.txt"})
+
+	want := "ddd  Shared/team/plan.txt\naaa  docs/a.txt\nbbb  docs/b.txt\n"
+	if got := string(m.HashSum()); got != want {
+		t.Errorf("HashSum() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := m.SaveHashSum(); err != nil {
+		t.Fatalf("SaveHashSum() error = %v", err)
+	}
+	data, err := os.ReadFile(HashSumPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("hash sum file = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,359 @@
+// Package manifest records what was downloaded into a backup directory so
+// later runs can tell which files changed without re-reading them.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the manifest file kept in the backup directory root
+const FileName = ".dropbox-backup-manifest.json"
+
+// currentVersion is the manifest format version written by Save
+const currentVersion = 1
+
+// Entry describes a file as it was on Dropbox when it was last backed up
+type Entry struct {
+	Rev         string    `json:"rev"`
+	ContentHash string    `json:"content_hash,omitempty"`
+	Size        uint64    `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+
+	// Exported is set for files saved in an export format (e.g. Paper docs),
+	// whose local copy has the format's extension appended
+	Exported bool `json:"exported,omitempty"`
+
+	// Namespace is the Dropbox namespace ID of files from outside the home
+	// namespace (e.g. a team space); see Key
+	Namespace string `json:"namespace,omitempty"`
+
+	// LocalPath is set when the local copy isn't stored at the key, e.g. for
+	// files in shared folders with the shared layout. Like keys it is
+	// relative to the backup directory with a leading "/".
+	LocalPath string `json:"local_path,omitempty"`
+
+	// Cold is set for files moved to the cold directory for not having
+	// changed in a long time; see ColdPath
+	Cold bool `json:"cold,omitempty"`
+
+	// DisplayPath is the Dropbox path with its original capitalization,
+	// which keys and local copies don't keep; restores upload to it
+	DisplayPath string `json:"display_path,omitempty"`
+}
+
+// namespacePrefix starts the local directory of a non-home namespace
+const namespacePrefix = "ns-"
+
+// Key returns the manifest key, which is also the path relative to the
+// backup directory, of a Dropbox path in a namespace. Files in the home
+// namespace keep their path; other namespaces get their own "ns-<id>" root
+// so equal paths in different namespaces don't collide.
+func Key(namespace, remotePath string) string {
+	if namespace == "" {
+		return remotePath
+	}
+	return "/" + namespacePrefix + namespace + remotePath
+}
+
+// SplitKey is the inverse of Key, returning the namespace ID (empty for the
+// home namespace) and the Dropbox path within it
+func SplitKey(key string) (namespace, remotePath string) {
+	rest, ok := strings.CutPrefix(key, "/"+namespacePrefix)
+	if !ok {
+		return "", key
+	}
+
+	id, remotePath, _ := strings.Cut(rest, "/")
+	if id == "" || strings.Trim(id, "0123456789") != "" {
+		return "", key // A home folder that happens to start with "ns-"
+	}
+	return id, "/" + remotePath
+}
+
+// Manifest maps keys (lower-case Dropbox paths, see Key) to their recorded
+// entries. It is safe for concurrent use.
+type Manifest struct {
+	mu    sync.Mutex
+	path  string
+	files map[string]Entry
+
+	// byLocal maps the LocalPath of entries that have one to their key
+	byLocal map[string]string
+
+	account *Account
+	coldDir string
+
+	// changes counts the changes made, saved those written by Save
+	changes, saved int
+}
+
+// Account identifies the Dropbox account whose files a backup directory holds
+type Account struct {
+	ID    string `json:"id"`
+	Email string `json:"email,omitempty"`
+}
+
+// String returns the account email with its ID, or just the ID
+func (a Account) String() string {
+	if a.Email == "" {
+		return a.ID
+	}
+	return a.Email + " (" + a.ID + ")"
+}
+
+// manifestFile is the on-disk representation of a Manifest
+type manifestFile struct {
+	Version int              `json:"version"`
+	Account *Account         `json:"account,omitempty"`
+	ColdDir string           `json:"cold_dir,omitempty"`
+	Files   map[string]Entry `json:"files"`
+}
+
+// Path returns the manifest location for a backup directory
+func Path(backupDir string) string {
+	return filepath.Join(backupDir, FileName)
+}
+
+// Load reads the manifest from a backup directory. A missing manifest
+// yields an empty one.
+func Load(backupDir string) (*Manifest, error) {
+	m := &Manifest{
+		path:    Path(backupDir),
+		files:   make(map[string]Entry),
+		byLocal: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var file manifestFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", m.path, err)
+	}
+	if file.Version > currentVersion {
+		return nil, fmt.Errorf("manifest %s has unsupported version %d", m.path, file.Version)
+	}
+	m.account = file.Account
+	m.coldDir = file.ColdDir
+	for key, entry := range file.Files {
+		m.files[key] = entry
+		if entry.LocalPath != "" {
+			m.byLocal[entry.LocalPath] = key
+		}
+	}
+
+	return m, nil
+}
+
+// ReadAccount returns the account recorded in the manifest of a backup
+// directory without keeping its entries; ok is false if none is recorded
+func ReadAccount(backupDir string) (account Account, ok bool, err error) {
+	path := Path(backupDir)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Account{}, false, nil
+	}
+	if err != nil {
+		return Account{}, false, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var file struct {
+		Account *Account `json:"account"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Account{}, false, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if file.Account == nil {
+		return Account{}, false, nil
+	}
+	return *file.Account, true, nil
+}
+
+// Account returns the Dropbox account the manifest was recorded for
+func (m *Manifest) Account() (Account, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.account == nil {
+		return Account{}, false
+	}
+	return *m.account, true
+}
+
+// SetAccount records the Dropbox account whose files the backup holds
+func (m *Manifest) SetAccount(account Account) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.account = &account
+	m.changes++
+}
+
+// ColdDir returns the directory cold files were moved to; empty if none were
+func (m *Manifest) ColdDir() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.coldDir
+}
+
+// SetColdDir records the directory cold files are moved to
+func (m *Manifest) SetColdDir(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.coldDir = dir
+	m.changes++
+}
+
+// LocalPath returns where the local copy of an entry is kept, relative to
+// the backup directory (or the cold directory for cold entries) with a
+// leading "/"
+func LocalPath(key string, entry Entry) string {
+	if entry.LocalPath != "" {
+		return entry.LocalPath
+	}
+	return key
+}
+
+// ColdPath returns the path of a cold entry's copy in the cold directory
+func (m *Manifest) ColdPath(key string, entry Entry) string {
+	return filepath.Join(m.ColdDir(), filepath.FromSlash(strings.TrimPrefix(LocalPath(key, entry), "/")))
+}
+
+// Get returns the recorded entry for a Dropbox path
+func (m *Manifest) Get(remotePath string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.files[remotePath]
+	return entry, ok
+}
+
+// Resolve maps a backed-up file, given as a slash-separated path relative to
+// the backup directory with a leading "/", to the key it was recorded under.
+// Exported copies resolve to their original path and relocated copies to
+// the key recorded with their LocalPath.
+func (m *Manifest) Resolve(localPath string) (string, Entry, bool) {
+	m.mu.Lock()
+	key, relocated := m.byLocal[localPath]
+	entry := m.files[key]
+	m.mu.Unlock()
+	if relocated {
+		return key, entry, true
+	}
+
+	if entry, ok := m.Get(localPath); ok && !entry.Exported {
+		return localPath, entry, true
+	}
+
+	if ext := path.Ext(localPath); ext != "" {
+		remotePath := strings.TrimSuffix(localPath, ext)
+		if entry, ok := m.Get(remotePath); ok && entry.Exported {
+			return remotePath, entry, true
+		}
+	}
+
+	return localPath, Entry{}, false
+}
+
+// Set records the entry for a Dropbox path. An entry recorded under another
+// key with the same LocalPath is replaced, since the file has moved on
+// Dropbox without moving locally.
+func (m *Manifest) Set(remotePath string, entry Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unindex(remotePath)
+	if entry.LocalPath != "" {
+		if previous, ok := m.byLocal[entry.LocalPath]; ok && previous != remotePath {
+			delete(m.files, previous)
+		}
+		m.byLocal[entry.LocalPath] = remotePath
+	}
+	m.files[remotePath] = entry
+	m.changes++
+}
+
+// Delete forgets a Dropbox path
+func (m *Manifest) Delete(remotePath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unindex(remotePath)
+	delete(m.files, remotePath)
+	m.changes++
+}
+
+// unindex drops the local path of the entry recorded for a key from byLocal.
+// m.mu must be held.
+func (m *Manifest) unindex(remotePath string) {
+	localPath := m.files[remotePath].LocalPath
+	if localPath != "" && m.byLocal[localPath] == remotePath {
+		delete(m.byLocal, localPath)
+	}
+}
+
+// Entries returns a copy of all recorded entries by key
+func (m *Manifest) Entries() map[string]Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make(map[string]Entry, len(m.files))
+	for key, entry := range m.files {
+		entries[key] = entry
+	}
+	return entries
+}
+
+// Len returns the number of recorded files
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.files)
+}
+
+// Save atomically writes the manifest back to the backup directory
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(manifestFile{Version: currentVersion, Account: m.account, ColdDir: m.coldDir, Files: m.files}, "", "  ")
+	changes := m.changes
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), "."+filepath.Base(m.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to replace manifest: %w", err)
+	}
+
+	m.mu.Lock()
+	m.saved = max(m.saved, changes)
+	m.mu.Unlock()
+	return nil
+}
+
+// Unsaved reports whether the manifest changed since it was last saved
+func (m *Manifest) Unsaved() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.changes != m.saved
+}
@@ -0,0 +1,249 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadMissing(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if m.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", m.Len())
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.Set("/docs/a.txt", Entry{Rev: "015f1", ContentHash: "abc", Size: 12, ModTime: modTime})
+	m.Set("/docs/b.txt", Entry{Rev: "015f2", Size: 3})
+	m.Delete("/docs/b.txt")
+
+	if !m.Unsaved() {
+		t.Error("Unsaved() = false after changes")
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if m.Unsaved() {
+		t.Error("Unsaved() = true after Save()")
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Unsaved() {
+		t.Error("Unsaved() = true after Load()")
+	}
+
+	entry, ok := loaded.Get("/docs/a.txt")
+	if !ok {
+		t.Fatal("Get(/docs/a.txt) not found after reload")
+	}
+	if entry.Rev != "015f1" || entry.ContentHash != "abc" || entry.Size != 12 || !entry.ModTime.Equal(modTime) {
+		t.Errorf("Get(/docs/a.txt) = %+v", entry)
+	}
+	if _, ok := loaded.Get("/docs/b.txt"); ok {
+		t.Error("deleted entry survived reload")
+	}
+}
+
+func TestAccount(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, ok, err := ReadAccount(dir); ok || err != nil {
+		t.Fatalf("ReadAccount() without manifest = %v, %v; want none", ok, err)
+	}
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Account(); ok {
+		t.Error("Account() of a new manifest is set")
+	}
+	want := Account{ID: "dbid:AAA", Email: "a@example.com"}
+	m.SetAccount(want)
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := ReadAccount(dir)
+	if err != nil || !ok || got != want {
+		t.Errorf("ReadAccount() = %+v, %v, %v; want %+v", got, ok, err, want)
+	}
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := loaded.Account(); !ok || got != want {
+		t.Errorf("Account() after Load = %+v, %v; want %+v", got, ok, want)
+	}
+	if want.String() != "a@example.com (dbid:AAA)" {
+		t.Errorf("String() = %q", want.String())
+	}
+}
+
+func TestColdDir(t *testing.T) {
+	dir := t.TempDir()
+	coldDir := t.TempDir()
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetColdDir(coldDir)
+	m.Set("/docs/a.txt", Entry{Rev: "015f1", Cold: true})
+	m.Set("/shared/b.txt", Entry{Rev: "015f2", Cold: true, LocalPath: "/ns-42/b.txt"})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.ColdDir(); got != coldDir {
+		t.Errorf("ColdDir() = %q, want %q", got, coldDir)
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "/docs/a.txt", want: filepath.Join(coldDir, "docs", "a.txt")},
+		{key: "/shared/b.txt", want: filepath.Join(coldDir, "ns-42", "b.txt")},
+	}
+	for _, tt := range tests {
+		entry, ok := loaded.Get(tt.key)
+		if !ok || !entry.Cold {
+			t.Errorf("Get(%s) = %+v, %v; want a cold entry", tt.key, entry, ok)
+		}
+		if got := loaded.ColdPath(tt.key, entry); got != tt.want {
+			t.Errorf("ColdPath(%s) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"malformed json", "{"},
+		{"future version", `{"version": 99, "files": {}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(Path(dir), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(dir); err == nil {
+				t.Error("Load() expected error")
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set("/docs/a.txt", Entry{Rev: "1"})
+	m.Set("/docs/notes.paper", Entry{Rev: "2", Exported: true})
+
+	tests := []struct {
+		localPath  string
+		wantRemote string
+		wantRev    string
+		wantOK     bool
+	}{
+		{"/docs/a.txt", "/docs/a.txt", "1", true},
+		{"/docs/notes.paper.md", "/docs/notes.paper", "2", true},
+		{"/docs/notes.paper", "/docs/notes.paper", "", false},
+		{"/docs/unknown.txt", "/docs/unknown.txt", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.localPath, func(t *testing.T) {
+			remotePath, entry, ok := m.Resolve(tt.localPath)
+			if remotePath != tt.wantRemote || entry.Rev != tt.wantRev || ok != tt.wantOK {
+				t.Errorf("Resolve(%q) = %q, %q, %v; want %q, %q, %v",
+					tt.localPath, remotePath, entry.Rev, ok, tt.wantRemote, tt.wantRev, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRelocated(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set("/old mount/plans/a.txt", Entry{Rev: "1", LocalPath: "/shared/jane/plans/a.txt"})
+
+	if key, entry, ok := m.Resolve("/shared/jane/plans/a.txt"); key != "/old mount/plans/a.txt" || entry.Rev != "1" || !ok {
+		t.Errorf("Resolve() = %q, %q, %v; want the relocated entry", key, entry.Rev, ok)
+	}
+
+	// Remounting the shared folder records the file under a new key
+	m.Set("/new mount/plans/a.txt", Entry{Rev: "1", LocalPath: "/shared/jane/plans/a.txt"})
+	if _, ok := m.Get("/old mount/plans/a.txt"); ok {
+		t.Error("Set() kept the entry of the old mount point")
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, _, ok := loaded.Resolve("/shared/jane/plans/a.txt"); key != "/new mount/plans/a.txt" || !ok {
+		t.Errorf("Resolve() after Load = %q, %v; want the new mount point", key, ok)
+	}
+
+	loaded.Delete("/new mount/plans/a.txt")
+	if _, _, ok := loaded.Resolve("/shared/jane/plans/a.txt"); ok {
+		t.Error("Resolve() found a deleted entry")
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		namespace  string
+		remotePath string
+		key        string
+	}{
+		{namespace: "", remotePath: "/docs/a.txt", key: "/docs/a.txt"},
+		{namespace: "12345", remotePath: "/docs/a.txt", key: "/ns-12345/docs/a.txt"},
+		{namespace: "", remotePath: "/ns-notes/a.txt", key: "/ns-notes/a.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := Key(tt.namespace, tt.remotePath); got != tt.key {
+				t.Errorf("Key() = %q, want %q", got, tt.key)
+			}
+			namespace, remotePath := SplitKey(tt.key)
+			if namespace != tt.namespace || remotePath != tt.remotePath {
+				t.Errorf("SplitKey() = %q, %q, want %q, %q", namespace, remotePath, tt.namespace, tt.remotePath)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package manifest
+
+import (
+	"sort"
+	"time"
+)
+
+// File is a recorded entry together with its key
+type File struct {
+	Key string `json:"path"`
+	Entry
+}
+
+// sorted returns the recorded entries kept by keep with their keys, ordered
+// by less and then by key
+func (m *Manifest) sorted(keep func(Entry) bool, less func(a, b File) bool) []File {
+	m.mu.Lock()
+	files := make([]File, 0, len(m.files))
+	for key, entry := range m.files {
+		if keep(entry) {
+			files = append(files, File{Key: key, Entry: entry})
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		if less(files[i], files[j]) {
+			return true
+		}
+		if less(files[j], files[i]) {
+			return false
+		}
+		return files[i].Key < files[j].Key
+	})
+	return files
+}
+
+// Largest returns the n largest recorded files, largest first; all of them
+// if n is 0
+func (m *Manifest) Largest(n int) []File {
+	files := m.sorted(func(Entry) bool { return true }, func(a, b File) bool {
+		return a.Size > b.Size
+	})
+	if n > 0 && len(files) > n {
+		files = files[:n]
+	}
+	return files
+}
+
+// ModifiedSince returns the recorded files modified on Dropbox after t,
+// most recently modified first
+func (m *Manifest) ModifiedSince(t time.Time) []File {
+	return m.sorted(func(e Entry) bool { return e.ModTime.After(t) }, func(a, b File) bool {
+		return a.ModTime.After(b.ModTime)
+	})
+}
@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	"testing"
+	"time"
+)
+
+func keys(files []File) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f.Key)
+	}
+	return out
+}
+
+func TestLargestAndModifiedSince(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	m.Set("/a.txt", Entry{Size: 10, ModTime: day})
+	m.Set("/b.mov", Entry{Size: 500, ModTime: day.Add(48 * time.Hour)})
+	m.Set("/c.jpg", Entry{Size: 200, ModTime: day.Add(24 * time.Hour)})
+	m.Set("/d.jpg", Entry{Size: 200, ModTime: day.Add(-24 * time.Hour)})
+
+	tests := []struct {
+		name  string
+		files []File
+		want  []string
+	}{
+		{name: "largest two", files: m.Largest(2), want: []string{"/b.mov", "/c.jpg"}},
+		{name: "all by size", files: m.Largest(0), want: []string{"/b.mov", "/c.jpg", "/d.jpg", "/a.txt"}},
+		{name: "modified since", files: m.ModifiedSince(day), want: []string{"/b.mov", "/c.jpg"}},
+		{name: "nothing modified", files: m.ModifiedSince(day.Add(72 * time.Hour)), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keys(tt.files)
+			if len(got) != len(tt.want) {
+				t.Fatalf("files = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("files = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
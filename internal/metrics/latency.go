@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSamples bounds the memory used by a histogram. Beyond this, samples are
+// kept by reservoir sampling so percentiles stay representative.
+const maxSamples = 10000
+
+// Summary holds latency percentiles for one kind of operation
+type Summary struct {
+	Count int           `json:"count"`
+	Sum   time.Duration `json:"sum"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// Histogram records latency samples for percentile calculation
+type Histogram struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	count   int
+	sum     time.Duration
+	max     time.Duration
+}
+
+// Observe records a single latency sample
+func (h *Histogram) Observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+
+	if len(h.samples) < maxSamples {
+		h.samples = append(h.samples, d)
+		return
+	}
+	if i := rand.Intn(h.count); i < maxSamples {
+		h.samples[i] = d
+	}
+}
+
+// Summary returns the percentiles of the recorded samples
+func (h *Histogram) Summary() Summary {
+	h.mu.Lock()
+	sorted := make([]time.Duration, len(h.samples))
+	copy(sorted, h.samples)
+	summary := Summary{Count: h.count, Sum: h.sum, Max: h.max}
+	h.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return summary
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	summary.P50 = percentile(sorted, 0.50)
+	summary.P95 = percentile(sorted, 0.95)
+	summary.P99 = percentile(sorted, 0.99)
+	return summary
+}
+
+// percentile uses the nearest-rank method on sorted samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// Latencies keeps one histogram per operation type (list, download, metadata, ...)
+type Latencies struct {
+	mu   sync.Mutex
+	byOp map[string]*Histogram
+}
+
+// NewLatencies creates an empty latency recorder
+func NewLatencies() *Latencies {
+	return &Latencies{byOp: make(map[string]*Histogram)}
+}
+
+// Observe records a latency sample for an operation type
+func (l *Latencies) Observe(op string, d time.Duration) {
+	l.mu.Lock()
+	h, ok := l.byOp[op]
+	if !ok {
+		h = &Histogram{}
+		l.byOp[op] = h
+	}
+	l.mu.Unlock()
+
+	h.Observe(d)
+}
+
+// Summaries returns the percentiles for every operation type seen so far
+func (l *Latencies) Summaries() map[string]Summary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	summaries := make(map[string]Summary, len(l.byOp))
+	for op, h := range l.byOp {
+		summaries[op] = h.Summary()
+	}
+	return summaries
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramSummary(t *testing.T) {
+	h := &Histogram{}
+	for i := 1; i <= 100; i++ {
+		h.Observe(time.Duration(i) * time.Millisecond)
+	}
+
+	s := h.Summary()
+	if s.Count != 100 {
+		t.Errorf("Count = %d, want 100", s.Count)
+	}
+	if s.P50 != 50*time.Millisecond {
+		t.Errorf("P50 = %v, want 50ms", s.P50)
+	}
+	if s.P95 != 95*time.Millisecond {
+		t.Errorf("P95 = %v, want 95ms", s.P95)
+	}
+	if s.P99 != 99*time.Millisecond {
+		t.Errorf("P99 = %v, want 99ms", s.P99)
+	}
+	if s.Max != 100*time.Millisecond {
+		t.Errorf("Max = %v, want 100ms", s.Max)
+	}
+	if s.Sum != 5050*time.Millisecond {
+		t.Errorf("Sum = %v, want 5.05s", s.Sum)
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	s := (&Histogram{}).Summary()
+	if s.Count != 0 || s.P99 != 0 {
+		t.Errorf("empty Summary() = %+v, want zero", s)
+	}
+}
+
+func TestHistogramBoundsMemory(t *testing.T) {
+	h := &Histogram{}
+	for i := 0; i < maxSamples*2; i++ {
+		h.Observe(time.Millisecond)
+	}
+
+	if len(h.samples) != maxSamples {
+		t.Errorf("samples = %d, want %d", len(h.samples), maxSamples)
+	}
+	if s := h.Summary(); s.Count != maxSamples*2 {
+		t.Errorf("Count = %d, want %d", s.Count, maxSamples*2)
+	}
+}
+
+func TestLatenciesByOperation(t *testing.T) {
+	l := NewLatencies()
+	l.Observe("list", 10*time.Millisecond)
+	l.Observe("list", 20*time.Millisecond)
+	l.Observe("download", time.Second)
+
+	summaries := l.Summaries()
+	if len(summaries) != 2 {
+		t.Fatalf("Summaries() has %d entries, want 2", len(summaries))
+	}
+	if summaries["list"].Count != 2 {
+		t.Errorf("list Count = %d, want 2", summaries["list"].Count)
+	}
+	if summaries["download"].P50 != time.Second {
+		t.Errorf("download P50 = %v, want 1s", summaries["download"].P50)
+	}
+}
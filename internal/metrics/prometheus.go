@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TextFile builds a Prometheus text exposition file, suitable for the
+// node_exporter textfile collector
+type TextFile struct {
+	buf bytes.Buffer
+}
+
+// Gauge adds a single gauge sample
+func (t *TextFile) Gauge(name, help string, value float64) {
+	fmt.Fprintf(&t.buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&t.buf, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(&t.buf, "%s %g\n", name, value)
+}
+
+// GaugeVec adds a gauge with one sample per label value
+func (t *TextFile) GaugeVec(name, help, label string, values map[string]float64) {
+	fmt.Fprintf(&t.buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&t.buf, "# TYPE %s gauge\n", name)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&t.buf, "%s{%s=%q} %g\n", name, label, key, values[key])
+	}
+}
+
+// LatencySummary adds a summary metric with p50/p95/p99 quantiles in seconds,
+// one series per operation type
+func (t *TextFile) LatencySummary(name, help string, summaries map[string]Summary) {
+	fmt.Fprintf(&t.buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&t.buf, "# TYPE %s summary\n", name)
+
+	ops := make([]string, 0, len(summaries))
+	for op := range summaries {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	for _, op := range ops {
+		s := summaries[op]
+		fmt.Fprintf(&t.buf, "%s{op=%q,quantile=\"0.5\"} %g\n", name, op, s.P50.Seconds())
+		fmt.Fprintf(&t.buf, "%s{op=%q,quantile=\"0.95\"} %g\n", name, op, s.P95.Seconds())
+		fmt.Fprintf(&t.buf, "%s{op=%q,quantile=\"0.99\"} %g\n", name, op, s.P99.Seconds())
+		fmt.Fprintf(&t.buf, "%s_sum{op=%q} %g\n", name, op, s.Sum.Seconds())
+		fmt.Fprintf(&t.buf, "%s_count{op=%q} %d\n", name, op, s.Count)
+	}
+}
+
+// WriteTo writes the exposition text to w
+func (t *TextFile) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(t.buf.Bytes())
+	return int64(n), err
+}
+
+// WriteFile atomically replaces path with the exposition text so collectors
+// never read a half-written file
+func (t *TextFile) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create metrics file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := t.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set metrics file permissions: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace metrics file: %w", err)
+	}
+	return nil
+}
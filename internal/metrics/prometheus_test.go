@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextFile(t *testing.T) {
+	var tf TextFile
+	tf.Gauge("dropbox_backup_files_downloaded", "Files downloaded in the last run", 12)
+	tf.GaugeVec("dropbox_backup_files_failed_by_class", "Failed files by class", "class", map[string]float64{"disk_full": 3, "auth": 1})
+	tf.LatencySummary("dropbox_backup_api_latency_seconds", "Dropbox API call latency", map[string]Summary{
+		"list": {Count: 3, Sum: 600 * time.Millisecond, P50: 200 * time.Millisecond, P95: 300 * time.Millisecond, P99: 300 * time.Millisecond},
+	})
+
+	var buf bytes.Buffer
+	if _, err := tf.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE dropbox_backup_files_downloaded gauge",
+		"dropbox_backup_files_downloaded 12",
+		`dropbox_backup_files_failed_by_class{class="auth"} 1`,
+		`dropbox_backup_files_failed_by_class{class="disk_full"} 3`,
+		"# TYPE dropbox_backup_api_latency_seconds summary",
+		`dropbox_backup_api_latency_seconds{op="list",quantile="0.5"} 0.2`,
+		`dropbox_backup_api_latency_seconds{op="list",quantile="0.95"} 0.3`,
+		`dropbox_backup_api_latency_seconds_count{op="list"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTextFileWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.prom")
+
+	var tf TextFile
+	tf.Gauge("dropbox_backup_success", "Whether the last run succeeded", 1)
+	if err := tf.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "dropbox_backup_success 1") {
+		t.Errorf("metrics file content = %q", data)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the metrics file, found %d entries", len(entries))
+	}
+}
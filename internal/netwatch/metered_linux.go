@@ -0,0 +1,41 @@
+package netwatch
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// detectMetered asks NetworkManager over D-Bus whether the primary connection is metered
+func detectMetered(ctx context.Context) (bool, error) {
+	out, err := exec.CommandContext(ctx, "busctl", "get-property",
+		"org.freedesktop.NetworkManager",
+		"/org/freedesktop/NetworkManager",
+		"org.freedesktop.NetworkManager",
+		"Metered",
+	).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to query NetworkManager: %w", err)
+	}
+
+	return parseNetworkManagerMetered(string(out))
+}
+
+// parseNetworkManagerMetered parses busctl output such as "u 1". NetworkManager
+// reports 0 unknown, 1 yes, 2 no, 3 guess-yes, 4 guess-no.
+func parseNetworkManagerMetered(output string) (bool, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 || fields[0] != "u" {
+		return false, fmt.Errorf("unexpected NetworkManager response: %q", strings.TrimSpace(output))
+	}
+
+	switch fields[1] {
+	case "1", "3":
+		return true, nil
+	case "0", "2", "4":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected NetworkManager metered state: %s", fields[1])
+	}
+}
@@ -0,0 +1,29 @@
+package netwatch
+
+import "testing"
+
+func TestParseNetworkManagerMetered(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    bool
+		wantErr bool
+	}{
+		{"u 0\n", false, false},
+		{"u 1\n", true, false},
+		{"u 2\n", false, false},
+		{"u 3\n", true, false},
+		{"u 4\n", false, false},
+		{"u 9\n", false, true},
+		{"garbage", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNetworkManagerMetered(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseNetworkManagerMetered(%q) error = %v, wantErr %v", tt.output, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseNetworkManagerMetered(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
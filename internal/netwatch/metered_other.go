@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+
+package netwatch
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// detectMetered is not supported on this platform without cgo; macOS only
+// exposes Low Data Mode through Network.framework
+func detectMetered(ctx context.Context) (bool, error) {
+	return false, fmt.Errorf("metered connection detection is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,29 @@
+package netwatch
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const connectionCostScript = `[Windows.Networking.Connectivity.NetworkInformation,Windows.Networking.Connectivity,ContentType=WindowsRuntime] | Out-Null; ` +
+	`$p = [Windows.Networking.Connectivity.NetworkInformation]::GetInternetConnectionProfile(); ` +
+	`if ($p) { $p.GetConnectionCost().NetworkCostType } else { 'Unknown' }`
+
+// detectMetered asks the Windows connectivity API for the connection cost type
+func detectMetered(ctx context.Context) (bool, error) {
+	out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", connectionCostScript).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to query connection cost: %w", err)
+	}
+
+	switch cost := strings.TrimSpace(string(out)); cost {
+	case "Fixed", "Variable":
+		return true, nil
+	case "Unrestricted", "Unknown":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected connection cost type: %q", cost)
+	}
+}
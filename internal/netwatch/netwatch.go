@@ -0,0 +1,211 @@
+package netwatch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often network conditions are re-checked
+const DefaultInterval = 30 * time.Second
+
+// Options configures which network conditions pause transfers
+type Options struct {
+	PauseOnMetered bool
+	Interface      string // pause while this interface is down; empty disables the check
+	Interval       time.Duration
+}
+
+// check reports a reason to pause transfers, or "" if they may proceed
+type check func(ctx context.Context) string
+
+// Monitor periodically evaluates network conditions and pauses transfers
+// while any of them say so. Paused transfers resume automatically.
+type Monitor struct {
+	checks   []check
+	interval time.Duration
+
+	mu      sync.Mutex
+	reason  string
+	resumed chan struct{}
+}
+
+// New creates a monitor for the given options. It returns nil when no
+// condition is enabled, and a nil monitor never pauses.
+func New(opts Options) *Monitor {
+	var checks []check
+	if opts.PauseOnMetered {
+		checks = append(checks, meteredCheck)
+	}
+	if opts.Interface != "" {
+		checks = append(checks, interfaceCheck(opts.Interface))
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return newMonitor(checks, interval)
+}
+
+func newMonitor(checks []check, interval time.Duration) *Monitor {
+	resumed := make(chan struct{})
+	close(resumed)
+
+	return &Monitor{
+		checks:   checks,
+		interval: interval,
+		resumed:  resumed,
+	}
+}
+
+// Start evaluates conditions immediately and then on every interval until ctx is done
+func (m *Monitor) Start(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	m.evaluate(ctx)
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.evaluate(ctx)
+			}
+		}
+	}()
+}
+
+// Paused reports whether transfers are currently paused and why
+func (m *Monitor) Paused() (bool, string) {
+	if m == nil {
+		return false, ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reason != "", m.reason
+}
+
+// Wait blocks while transfers are paused
+func (m *Monitor) Wait(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+
+	// Checked first: select picks at random when both channels are ready
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	resumed := m.resumed
+	m.mu.Unlock()
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader wraps r so that reads block while transfers are paused
+func (m *Monitor) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if m == nil {
+		return r
+	}
+	return &pausingReader{ctx: ctx, r: r, monitor: m}
+}
+
+func (m *Monitor) evaluate(ctx context.Context) {
+	reason := ""
+	for _, c := range m.checks {
+		if reason = c(ctx); reason != "" {
+			break
+		}
+	}
+	m.setReason(reason)
+}
+
+func (m *Monitor) setReason(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wasPaused := m.reason != ""
+	m.reason = reason
+
+	switch {
+	case reason != "" && !wasPaused:
+		m.resumed = make(chan struct{})
+		slog.Warn("Pausing transfers", slog.String("reason", reason))
+	case reason == "" && wasPaused:
+		close(m.resumed)
+		slog.Info("Network conditions changed, resuming transfers")
+	}
+}
+
+type pausingReader struct {
+	ctx     context.Context
+	r       io.Reader
+	monitor *Monitor
+}
+
+func (pr *pausingReader) Read(p []byte) (int, error) {
+	if err := pr.monitor.Wait(pr.ctx); err != nil {
+		return 0, err
+	}
+	return pr.r.Read(p)
+}
+
+func meteredCheck(ctx context.Context) string {
+	metered, err := detectMetered(ctx)
+	if err != nil {
+		slog.Debug("Metered connection detection unavailable", slog.String("error", err.Error()))
+		return ""
+	}
+	if metered {
+		return "connection is metered"
+	}
+	return ""
+}
+
+func interfaceCheck(name string) check {
+	return func(ctx context.Context) string {
+		if up, err := interfaceUp(name); err != nil || !up {
+			return fmt.Sprintf("network interface %s is down", name)
+		}
+		return ""
+	}
+}
+
+// interfaceUp reports whether the named interface is up and has an address
+func interfaceUp(name string) (bool, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return false, err
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return false, nil
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false, err
+	}
+	return len(addrs) > 0, nil
+}
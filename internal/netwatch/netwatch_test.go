@@ -0,0 +1,104 @@
+package netwatch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNewWithoutConditions(t *testing.T) {
+	m := New(Options{})
+	if m != nil {
+		t.Fatal("New() should return nil when no condition is enabled")
+	}
+
+	// A nil monitor never pauses
+	if err := m.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() on nil monitor error = %v", err)
+	}
+	if paused, _ := m.Paused(); paused {
+		t.Error("Paused() on nil monitor = true")
+	}
+}
+
+func TestMonitorPausesAndResumes(t *testing.T) {
+	reason := "connection is metered"
+	m := newMonitor([]check{func(ctx context.Context) string { return reason }}, time.Hour)
+	m.evaluate(context.Background())
+
+	if paused, got := m.Paused(); !paused || got != reason {
+		t.Fatalf("Paused() = %v, %q, want true, %q", paused, got, reason)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Wait(context.Background())
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Wait() returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	reason = ""
+	m.evaluate(context.Background())
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait() did not return after resume")
+	}
+}
+
+func TestMonitorWaitHonorsCancellation(t *testing.T) {
+	m := newMonitor([]check{func(ctx context.Context) string { return "paused" }}, time.Hour)
+	m.evaluate(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := m.Wait(ctx); err == nil {
+		t.Error("Wait() expected error for cancelled context")
+	}
+}
+
+func TestMonitorWaitCancelledWhileResumed(t *testing.T) {
+	m := newMonitor([]check{func(ctx context.Context) string { return "" }}, time.Hour)
+	m.evaluate(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Both channels are ready, so without the upfront check this would pass
+	// about half the time
+	for range 20 {
+		if err := m.Wait(ctx); err == nil {
+			t.Fatal("Wait() expected error for cancelled context")
+		}
+	}
+}
+
+func TestMonitorReader(t *testing.T) {
+	m := newMonitor([]check{func(ctx context.Context) string { return "" }}, time.Hour)
+	m.evaluate(context.Background())
+
+	data, err := io.ReadAll(m.Reader(context.Background(), bytes.NewReader([]byte("hello"))))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("ReadAll() = %q, want %q", data, "hello")
+	}
+}
+
+func TestInterfaceCheck(t *testing.T) {
+	if reason := interfaceCheck("does-not-exist0")(context.Background()); reason == "" {
+		t.Error("interfaceCheck() should report a missing interface as down")
+	}
+}
@@ -0,0 +1,184 @@
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultProbeAddress is dialled to decide whether Dropbox is reachable again
+const DefaultProbeAddress = "api.dropboxapi.com:443"
+
+// IsNetworkError reports whether err looks like a loss of connectivity rather
+// than a problem with a particular file
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &dnsErr), errors.As(err, &opErr):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	case errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETDOWN):
+		return true
+	}
+	return false
+}
+
+// OutageGuard pauses all transfers after a connectivity failure and probes
+// with exponential backoff until Dropbox is reachable again, so a network
+// drop doesn't fail the run.
+type OutageGuard struct {
+	probe      func(ctx context.Context) error
+	timeout    time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu      sync.Mutex
+	down    bool
+	failed  error
+	resumed chan struct{}
+}
+
+// NewOutageGuard creates a guard that gives up if connectivity isn't restored
+// within timeout. It returns nil when timeout is zero, and a nil guard never pauses.
+func NewOutageGuard(timeout time.Duration) *OutageGuard {
+	if timeout <= 0 {
+		return nil
+	}
+	return newOutageGuard(dialProbe(DefaultProbeAddress), timeout, 2*time.Second, time.Minute)
+}
+
+func newOutageGuard(probe func(ctx context.Context) error, timeout, minBackoff, maxBackoff time.Duration) *OutageGuard {
+	resumed := make(chan struct{})
+	close(resumed)
+
+	return &OutageGuard{
+		probe:      probe,
+		timeout:    timeout,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		resumed:    resumed,
+	}
+}
+
+// Wait blocks while an outage is in progress. It fails if connectivity could
+// not be restored in time.
+func (g *OutageGuard) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+
+	select {
+	case <-resumed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.failed
+}
+
+// Report inspects a transfer error. For connectivity errors it pauses the
+// queue, starts probing (once per outage) and returns true so the caller can
+// retry after Wait returns.
+func (g *OutageGuard) Report(ctx context.Context, err error) bool {
+	if g == nil || !IsNetworkError(err) {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failed != nil {
+		return false
+	}
+	if !g.down {
+		g.down = true
+		g.resumed = make(chan struct{})
+		slog.Warn("Network outage detected, pausing transfers", slog.String("error", err.Error()))
+		go g.recover(ctx, g.resumed)
+	}
+	return true
+}
+
+// recover probes connectivity with exponential backoff until it succeeds or times out
+func (g *OutageGuard) recover(ctx context.Context, resumed chan struct{}) {
+	start := time.Now()
+	backoff := g.minBackoff
+	var failed error
+
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			failed = ctx.Err()
+		case <-timer.C:
+		}
+		if failed != nil {
+			break
+		}
+
+		probeErr := g.probe(ctx)
+		if probeErr == nil {
+			slog.Info("Dropbox is reachable again, resuming transfers",
+				slog.Duration("outage", time.Since(start).Round(time.Second)),
+			)
+			break
+		}
+
+		if time.Since(start) >= g.timeout {
+			failed = fmt.Errorf("network outage lasted longer than %s: %w", g.timeout, probeErr)
+			break
+		}
+
+		slog.Debug("Connectivity probe failed",
+			slog.String("error", probeErr.Error()),
+			slog.Duration("next_probe", backoff),
+		)
+		backoff *= 2
+		if backoff > g.maxBackoff {
+			backoff = g.maxBackoff
+		}
+	}
+
+	g.mu.Lock()
+	g.down = false
+	g.failed = failed
+	close(resumed)
+	g.mu.Unlock()
+}
+
+func dialProbe(address string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		dialer := net.Dialer{Timeout: 5 * time.Second}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
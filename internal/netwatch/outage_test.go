@@ -0,0 +1,90 @@
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsNetworkError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain error", errors.New("path/not_found"), false},
+		{"dns error", &net.DNSError{Err: "no such host", Name: "api.dropboxapi.com"}, true},
+		{"op error", &net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{"wrapped reset", fmt.Errorf("failed to download: %w", syscall.ECONNRESET), true},
+		{"unexpected eof", fmt.Errorf("copy: %w", io.ErrUnexpectedEOF), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNetworkError(tt.err); got != tt.want {
+				t.Errorf("IsNetworkError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutageGuardRecovers(t *testing.T) {
+	var probes atomic.Int32
+	guard := newOutageGuard(func(ctx context.Context) error {
+		if probes.Add(1) < 3 {
+			return errors.New("unreachable")
+		}
+		return nil
+	}, time.Minute, time.Millisecond, 4*time.Millisecond)
+
+	ctx := context.Background()
+	if !guard.Report(ctx, syscall.ECONNRESET) {
+		t.Fatal("Report() = false for a network error")
+	}
+
+	if err := guard.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if got := probes.Load(); got != 3 {
+		t.Errorf("probe called %d times, want 3", got)
+	}
+}
+
+func TestOutageGuardGivesUp(t *testing.T) {
+	guard := newOutageGuard(func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}, 5*time.Millisecond, time.Millisecond, time.Millisecond)
+
+	ctx := context.Background()
+	guard.Report(ctx, syscall.ENETUNREACH)
+
+	if err := guard.Wait(ctx); err == nil {
+		t.Fatal("Wait() expected error after outage timeout")
+	}
+	if guard.Report(ctx, syscall.ENETUNREACH) {
+		t.Error("Report() should not retry after the guard gave up")
+	}
+}
+
+func TestOutageGuardIgnoresOtherErrors(t *testing.T) {
+	guard := newOutageGuard(func(ctx context.Context) error { return nil }, time.Minute, time.Millisecond, time.Millisecond)
+
+	if guard.Report(context.Background(), errors.New("not_found")) {
+		t.Error("Report() = true for a non-network error")
+	}
+
+	var nilGuard *OutageGuard
+	if nilGuard.Report(context.Background(), syscall.ECONNRESET) {
+		t.Error("Report() on nil guard = true")
+	}
+	if err := nilGuard.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() on nil guard error = %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Byte units, selected with --units
+const (
+	// UnitsJEDEC counts in multiples of 1024 labelled KB, MB, GB, ... as
+	// sizes always were; the default
+	UnitsJEDEC = "jedec"
+	// UnitsIEC counts in multiples of 1024: KiB, MiB, GiB, ...
+	UnitsIEC = "iec"
+	// UnitsSI counts in multiples of 1000: kB, MB, GB, ...
+	UnitsSI = "si"
+)
+
+// NumberFormat is how numbers and byte sizes are written
+type NumberFormat struct {
+	// Units is UnitsJEDEC, UnitsIEC or UnitsSI
+	Units string
+	// Group separates groups of three digits, e.g. "," in 1,247; empty
+	// writes numbers without grouping
+	Group string
+	// Decimal separates the fraction, e.g. "." in 1.5 MiB
+	Decimal string
+}
+
+var (
+	numberMu sync.RWMutex
+	numbers  = NumberFormat{Units: UnitsJEDEC, Decimal: "."}
+)
+
+// ParseUnits validates a --units value
+func ParseUnits(units string) (string, error) {
+	switch units = strings.ToLower(units); units {
+	case UnitsJEDEC, UnitsIEC, UnitsSI:
+		return units, nil
+	default:
+		return "", fmt.Errorf("invalid units: %s (must be jedec, iec or si)", units)
+	}
+}
+
+// SetNumberFormat sets how Bytes and Count write numbers from now on
+func SetNumberFormat(f NumberFormat) {
+	numberMu.Lock()
+	defer numberMu.Unlock()
+	numbers = f
+}
+
+// LocaleNumberFormat returns the number format of a POSIX locale name such
+// as "de_DE.UTF-8" with the given units. The C and POSIX locales and
+// unknown languages group digits like English.
+func LocaleNumberFormat(locale, units string) NumberFormat {
+	f := NumberFormat{Units: units, Group: ",", Decimal: "."}
+
+	lang, _, _ := strings.Cut(locale, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	language, region, _ := strings.Cut(lang, "_")
+	switch language {
+	case "de", "nl", "it", "es", "pt", "da", "id", "tr", "el", "ro", "sl", "hr":
+		f.Group, f.Decimal = ".", ","
+	case "fr", "ru", "pl", "cs", "sk", "sv", "nb", "nn", "fi", "uk", "hu", "bg", "lt", "lv", "et":
+		f.Group, f.Decimal = "\u202f", "," // Narrow no-break space
+	}
+	if region == "CH" || region == "LI" {
+		f.Group, f.Decimal = "'", "."
+	}
+	return f
+}
+
+// EnvLocale returns the locale that formats numbers according to the
+// environment: LC_ALL, LC_NUMERIC or LANG, in this order
+func EnvLocale() string {
+	for _, key := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if locale := os.Getenv(key); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// Count writes a number with its digits grouped, e.g. "1,247"
+func Count[T ~int | ~int64 | ~uint64](n T) string {
+	numberMu.RLock()
+	f := numbers
+	numberMu.RUnlock()
+	return group(strconv.FormatInt(int64(n), 10), f.Group)
+}
+
+// Bytes writes a byte size in the configured units, e.g. "1.5 MB", "1.5 MiB"
+// or "1.6 MB"
+func Bytes(n uint64) string {
+	numberMu.RLock()
+	f := numbers
+	numberMu.RUnlock()
+
+	unit, prefixes := uint64(1024), []string{"K", "M", "G", "T", "P", "E"}
+	switch f.Units {
+	case UnitsIEC:
+		prefixes = []string{"Ki", "Mi", "Gi", "Ti", "Pi", "Ei"}
+	case UnitsSI:
+		unit, prefixes = 1000, []string{"k", "M", "G", "T", "P", "E"}
+	}
+	if n < unit {
+		return group(strconv.FormatUint(n, 10), f.Group) + " B"
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	value := strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64)
+	whole, fraction, _ := strings.Cut(value, ".")
+	return group(whole, f.Group) + f.Decimal + fraction + " " + prefixes[exp] + "B"
+}
+
+// group inserts sep between groups of three digits
+func group(digits, sep string) string {
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if sep == "" || len(digits) <= 3 {
+		return sign + digits
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
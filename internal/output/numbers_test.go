@@ -0,0 +1,70 @@
+package output
+
+import "testing"
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		format NumberFormat
+		bytes  uint64
+		want   string
+	}{
+		{"zero", NumberFormat{Units: UnitsIEC, Decimal: "."}, 0, "0 B"},
+		{"default units", NumberFormat{Units: UnitsJEDEC, Decimal: "."}, 1536, "1.5 KB"},
+		{"bytes", NumberFormat{Units: UnitsIEC, Decimal: "."}, 512, "512 B"},
+		{"kibibytes", NumberFormat{Units: UnitsIEC, Decimal: "."}, 1536, "1.5 KiB"},
+		{"gibibytes", NumberFormat{Units: UnitsIEC, Decimal: "."}, 1073741824, "1.0 GiB"},
+		{"kilobytes", NumberFormat{Units: UnitsSI, Decimal: "."}, 1500, "1.5 kB"},
+		{"gigabytes", NumberFormat{Units: UnitsSI, Decimal: "."}, 1073741824, "1.1 GB"},
+		{"decimal comma", NumberFormat{Units: UnitsIEC, Group: ".", Decimal: ","}, 2621440, "2,5 MiB"},
+		{"grouped bytes", NumberFormat{Units: UnitsSI, Group: ",", Decimal: "."}, 999, "999 B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetNumberFormat(tt.format)
+			t.Cleanup(func() { SetNumberFormat(NumberFormat{Units: UnitsJEDEC, Decimal: "."}) })
+			if got := Bytes(tt.bytes); got != tt.want {
+				t.Errorf("Bytes(%d) = %q, want %q", tt.bytes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int
+		want   string
+	}{
+		{"en_US.UTF-8", 1247, "1,247"},
+		{"en_US.UTF-8", 999, "999"},
+		{"en_US.UTF-8", 1234567, "1,234,567"},
+		{"de_DE.UTF-8", 1234567, "1.234.567"},
+		{"fr_FR.UTF-8", 12345, "12\u202f345"},
+		{"de_CH.UTF-8", 12345, "12'345"},
+		{"C", -12345, "-12,345"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			SetNumberFormat(LocaleNumberFormat(tt.locale, UnitsIEC))
+			t.Cleanup(func() { SetNumberFormat(NumberFormat{Units: UnitsJEDEC, Decimal: "."}) })
+			if got := Count(tt.n); got != tt.want {
+				t.Errorf("Count(%d) = %q, want %q", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	if units, err := ParseUnits("SI"); err != nil || units != UnitsSI {
+		t.Errorf("ParseUnits(SI) = %q, %v", units, err)
+	}
+	if units, err := ParseUnits("jedec"); err != nil || units != UnitsJEDEC {
+		t.Errorf("ParseUnits(jedec) = %q, %v", units, err)
+	}
+	if _, err := ParseUnits("binary"); err == nil {
+		t.Error("ParseUnits(binary) error = nil, want error")
+	}
+}
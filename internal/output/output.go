@@ -0,0 +1,230 @@
+// Package output formats what commands print: status messages for people
+// and the result of the command as text, an aligned table, JSON, a stream of
+// JSON lines or nothing.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Format names, selected with --output
+const (
+	Text       = "text"
+	JSON       = "json"
+	JSONStream = "json-stream"
+	Table      = "table"
+	Quiet      = "quiet"
+)
+
+// Formats lists all format names
+var Formats = []string{Text, JSON, JSONStream, Table, Quiet}
+
+// Event types of the JSON stream
+const (
+	// EventSummary is the result of the command, the last line of the stream
+	EventSummary = "summary"
+)
+
+// Report is the result of a command
+type Report struct {
+	// Sections are what people read, e.g. the counts of a backup
+	Sections []Section `json:"sections,omitempty"`
+	// Data is encoded by the JSON format instead of the sections, e.g. the
+	// stats of a backup
+	Data any `json:"-"`
+}
+
+// Section is a titled group of labelled values
+type Section struct {
+	Title  string  `json:"title,omitempty"`
+	Fields []Field `json:"fields"`
+}
+
+// Field is a labelled value. Labels may repeat, e.g. one "Missing" field
+// per missing file.
+type Field struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// F creates a field, formatting the value with %v
+func F(label string, value any) Field {
+	return Field{Label: label, Value: fmt.Sprint(value)}
+}
+
+// Formatter writes the output of a command
+type Formatter interface {
+	// Message writes a status line meant for people, e.g. what is about to
+	// happen; machine-readable formats leave it out
+	Message(format string, args ...any)
+	// Report writes the result of the command
+	Report(r Report) error
+}
+
+// Streamer is implemented by formatters that also write events as they
+// happen, e.g. the result of every file of a backup
+type Streamer interface {
+	// Event writes data, which must encode as a JSON object, with its type
+	Event(eventType string, data any) error
+}
+
+// New returns the formatter for a format name writing to w
+func New(format string, w io.Writer) (Formatter, error) {
+	switch strings.ToLower(format) {
+	case Text, "":
+		return &textFormatter{w: w}, nil
+	case Table:
+		return &tableFormatter{textFormatter{w: w}}, nil
+	case JSON:
+		return &jsonFormatter{w: w}, nil
+	case JSONStream:
+		return &jsonStreamFormatter{w: w}, nil
+	case Quiet:
+		return quietFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("invalid output format: %s (must be %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// textFormatter writes sections as indented "Label: value" lines
+type textFormatter struct {
+	w io.Writer
+}
+
+func (f *textFormatter) Message(format string, args ...any) {
+	fmt.Fprintf(f.w, format+"\n", args...)
+}
+
+func (f *textFormatter) Report(r Report) error {
+	for i, section := range r.Sections {
+		if i > 0 {
+			fmt.Fprintln(f.w)
+		}
+		if section.Title != "" {
+			fmt.Fprintln(f.w, section.Title)
+		}
+		for _, field := range section.Fields {
+			fmt.Fprintf(f.w, "   %s: %s\n", field.Label, field.Value)
+		}
+	}
+	return nil
+}
+
+// tableFormatter writes sections with their values aligned in a column
+type tableFormatter struct {
+	textFormatter
+}
+
+func (f *tableFormatter) Report(r Report) error {
+	for i, section := range r.Sections {
+		if i > 0 {
+			fmt.Fprintln(f.w)
+		}
+		if section.Title != "" {
+			fmt.Fprintln(f.w, section.Title)
+		}
+		tw := tabwriter.NewWriter(f.w, 0, 0, 2, ' ', 0)
+		for _, field := range section.Fields {
+			fmt.Fprintf(tw, "   %s\t%s\n", field.Label, field.Value)
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	return nil
+}
+
+// jsonFormatter writes the report data as one indented JSON document
+type jsonFormatter struct {
+	w io.Writer
+}
+
+func (f *jsonFormatter) Message(format string, args ...any) {}
+
+func (f *jsonFormatter) Report(r Report) error {
+	var data any = r
+	if r.Data != nil {
+		data = r.Data
+	}
+	enc := json.NewEncoder(f.w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// jsonStreamFormatter writes events and the report data as JSON lines, each
+// object with its event type in a "type" field
+type jsonStreamFormatter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (f *jsonStreamFormatter) Message(format string, args ...any) {}
+
+func (f *jsonStreamFormatter) Report(r Report) error {
+	var data any = r
+	if r.Data != nil {
+		data = r.Data
+	}
+	return f.Event(EventSummary, data)
+}
+
+func (f *jsonStreamFormatter) Event(eventType string, data any) error {
+	object, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if len(object) < 2 || object[0] != '{' {
+		return fmt.Errorf("failed to write output: %s event is not a JSON object", eventType)
+	}
+	kind, _ := json.Marshal(eventType)
+
+	// The type goes first, so the lines are easy to tell apart by eye too
+	line := append([]byte(`{"type":`), kind...)
+	if len(object) > 2 {
+		line = append(line, ',')
+	}
+	line = append(line, object[1:]...)
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// MessageWriter returns a writer passing each line written to it to the
+// Message method of a formatter, e.g. for progress lines of other packages
+func MessageWriter(f Formatter) io.Writer {
+	return messageWriter{f}
+}
+
+type messageWriter struct {
+	f Formatter
+}
+
+func (w messageWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimSuffix(string(p), "\n"), "\n") {
+		w.f.Message("%s", line)
+	}
+	return len(p), nil
+}
+
+// Discard is a formatter that writes nothing, for callers without output
+var Discard Formatter = quietFormatter{}
+
+// quietFormatter writes nothing; the exit code tells the outcome
+type quietFormatter struct{}
+
+func (quietFormatter) Message(format string, args ...any) {}
+
+func (quietFormatter) Report(r Report) error { return nil }
@@ -0,0 +1,136 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestFormats(t *testing.T) {
+	report := Report{
+		Sections: []Section{{
+			Title:  "Summary:",
+			Fields: []Field{F("Files", 12), F("Total bytes", "1.5 MB")},
+		}},
+		Data: map[string]int{"files": 12},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: Text, want: "Starting\nSummary:\n   Files: 12\n   Total bytes: 1.5 MB\n"},
+		{format: Table, want: "Starting\nSummary:\n   Files        12\n   Total bytes  1.5 MB\n"},
+		{format: JSON, want: "{\n  \"files\": 12\n}\n"},
+		{format: JSONStream, want: `{"type":"summary","files":12}` + "\n"},
+		{format: Quiet, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			f, err := New(tt.format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Message("Starting")
+			if err := f.Report(report); err != nil {
+				t.Fatalf("Report() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONWithoutData(t *testing.T) {
+	var buf bytes.Buffer
+	f, _ := New(JSON, &buf)
+	report := Report{Sections: []Section{{Title: "Result", Fields: []Field{F("Runs", 3)}}}}
+	if err := f.Report(report); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, buf.String())
+	}
+	if len(decoded.Sections) != 1 || decoded.Sections[0].Fields[0].Value != "3" {
+		t.Errorf("decoded = %+v", decoded)
+	}
+}
+
+func TestJSONStreamEvents(t *testing.T) {
+	var buf bytes.Buffer
+	f, _ := New(JSONStream, &buf)
+	streamer, ok := f.(Streamer)
+	if !ok {
+		t.Fatal("json-stream formatter is not a Streamer")
+	}
+
+	events := []struct {
+		eventType string
+		data      any
+		wantErr   bool
+	}{
+		{eventType: "file", data: struct {
+			Path  string `json:"path"`
+			Bytes int    `json:"bytes"`
+		}{Path: "/a.txt", Bytes: 3}},
+		{eventType: "empty", data: struct{}{}},
+		{eventType: "number", data: 3, wantErr: true},
+	}
+	for _, event := range events {
+		if err := streamer.Event(event.eventType, event.data); (err != nil) != event.wantErr {
+			t.Errorf("Event(%s) error = %v, wantErr %v", event.eventType, err, event.wantErr)
+		}
+	}
+	f.Message("left out")
+	if err := f.Report(Report{Data: map[string]int{"files": 1}}); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+
+	want := `{"type":"file","path":"/a.txt","bytes":3}` + "\n" +
+		`{"type":"empty"}` + "\n" +
+		`{"type":"summary","files":1}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if !json.Valid(line) {
+			t.Errorf("line isn't JSON: %s", line)
+		}
+	}
+}
+
+func TestMessageWriter(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: Text, want: "[1/2] /a.txt\n[2/2] /b.txt\n"},
+		{format: JSON, want: ""},
+		{format: JSONStream, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			f, _ := New(tt.format, &buf)
+			w := MessageWriter(f)
+			if n, err := w.Write([]byte("[1/2] /a.txt\n[2/2] /b.txt\n")); err != nil || n != 26 {
+				t.Errorf("Write() = %d, %v", n, err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New("yaml", &bytes.Buffer{}); err == nil {
+		t.Error("New(yaml) error = nil, want error")
+	}
+}
@@ -0,0 +1,619 @@
+// Package restore uploads a local backup back to Dropbox without silently
+// overwriting files that changed on Dropbox since they were backed up.
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"create-dropbox-backup-folder/internal/accountmeta"
+	"create-dropbox-backup-folder/internal/archive"
+	"create-dropbox-backup-folder/internal/compare"
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/listing"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/sidecar"
+	"create-dropbox-backup-folder/internal/signing"
+	"create-dropbox-backup-folder/internal/transfer"
+	"create-dropbox-backup-folder/internal/ui"
+)
+
+// Policy decides what happens to a file whose Dropbox copy changed since the backup
+type Policy string
+
+// Conflict policies
+const (
+	PolicySkip        Policy = "skip"
+	PolicyOverwrite   Policy = "overwrite"
+	PolicyRename      Policy = "rename"
+	PolicyInteractive Policy = "interactive"
+)
+
+// ParsePolicy validates a conflict policy name
+func ParsePolicy(name string) (Policy, error) {
+	switch policy := Policy(strings.ToLower(name)); policy {
+	case PolicySkip, PolicyOverwrite, PolicyRename, PolicyInteractive:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy: %s (must be skip, overwrite, rename, or interactive)", name)
+	}
+}
+
+// remote is the part of the Dropbox client used by a restore
+type remote interface {
+	ListPaths(ctx context.Context, paths []string) ([]dropbox.FileInfo, error)
+	Upload(ctx context.Context, remotePath string, r io.Reader, size int64, opts dropbox.UploadOptions) (*dropbox.FileInfo, error)
+	Stage(ctx context.Context, r io.Reader, size int64) (*dropbox.StagedUpload, error)
+	CommitBatch(ctx context.Context, entries []dropbox.BatchEntry) ([]dropbox.BatchResult, error)
+}
+
+// Stats tracks restore statistics
+type Stats struct {
+	Uploaded  int    `json:"uploaded"`
+	Unchanged int    `json:"unchanged"`
+	Conflicts int    `json:"conflicts"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+	Bytes     uint64 `json:"bytes"`
+}
+
+// upload is a planned transfer of one local file
+type upload struct {
+	localPath string
+	// key is the manifest key; remotePath is uploaded to and has the
+	// capitalization recorded by the backup, if any
+	key        string
+	remotePath string
+	size       int64
+	opts       dropbox.UploadOptions
+
+	// cold is set for files restored from the cold directory
+	cold bool
+}
+
+// Restorer uploads the files of a backup directory to Dropbox
+type Restorer struct {
+	config    *config.Config
+	client    remote
+	transfers *transfer.Executor
+	policy    Policy
+	prompt    *ui.Prompt
+	since     *Since
+	manifest  *manifest.Manifest
+	sidecars  *sidecar.Store
+	out       output.Formatter
+	strategy  compare.Strategy
+
+	// mu guards stats and the prompt while uploads run concurrently
+	mu    sync.Mutex
+	stats *Stats
+}
+
+// New creates a restorer sharing the backup's transfer settings (concurrency,
+// bandwidth limit, network monitor, progress). prompt is only used with
+// PolicyInteractive. A non-nil since limits the restore to files that differ
+// on Dropbox from that snapshot or date. Messages for people, e.g. the
+// uploads of a dry run, go to out; nil writes none.
+func New(cfg *config.Config, client remote, policy Policy, prompt *ui.Prompt, since *Since, out output.Formatter) (*Restorer, error) {
+	if out == nil {
+		out = output.Discard
+	}
+	transfers, err := transfer.NewFromConfig(cfg, output.MessageWriter(out))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Restorer{
+		config:    cfg,
+		client:    client,
+		transfers: transfers,
+		policy:    policy,
+		prompt:    prompt,
+		since:     since,
+		out:       out,
+		strategy:  compare.Strategy{Mode: cfg.Compare, Verify: cfg.Verify},
+	}, nil
+}
+
+// Run restores every file in the backup directory (limited to the selected
+// remote folders and include/exclude patterns). Files whose Dropbox revision
+// still matches the one recorded at backup time are replaced; files that
+// changed on Dropbox since are conflicts resolved by the policy. With
+// --dry-run the planned uploads are only printed.
+func (r *Restorer) Run(ctx context.Context) (*Stats, error) {
+	r.stats = &Stats{}
+
+	m, err := manifest.Load(r.config.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Len() == 0 {
+		slog.Warn("No backup manifest found; every existing Dropbox file will be treated as a conflict")
+	}
+	r.manifest = m
+	if r.config.Sidecar {
+		r.sidecars = sidecar.NewStore()
+	}
+
+	uploads, err := r.plan(ctx)
+	if err != nil {
+		return r.stats, err
+	}
+	if r.since != nil {
+		slog.Info("Restoring files changed on Dropbox since comparison point",
+			slog.String("since", r.since.String()),
+			slog.Int("files", len(uploads)),
+		)
+	}
+
+	if r.config.DryRun {
+		for _, u := range uploads {
+			r.out.Message("[dry-run] upload %s (%s)", u.remotePath, uploadMode(u.opts))
+			r.stats.Uploaded++
+			r.stats.Bytes += uint64(u.size)
+		}
+		return r.stats, nil
+	}
+
+	r.transfers.Start(ctx)
+
+	for start := 0; start < len(uploads) && err == nil; start += dropbox.MaxBatchCommit {
+		err = r.uploadBatch(ctx, uploads[start:min(start+dropbox.MaxBatchCommit, len(uploads))])
+	}
+
+	if saveErr := r.manifest.Save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if err == nil && r.stats.Failed > 0 {
+		err = fmt.Errorf("%d file(s) failed to restore", r.stats.Failed)
+	}
+	return r.stats, err
+}
+
+// errAborted stops the restore when the user quits at a conflict prompt
+var errAborted = errors.New("restore aborted")
+
+// plan compares the backup with the current Dropbox state and returns the
+// uploads to make, resolving conflicts up front
+func (r *Restorer) plan(ctx context.Context) ([]upload, error) {
+	current, err := r.listRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files moved to the cold directory are restored from there
+	bases := []string{r.config.BackupDir}
+	if coldDir := r.manifest.ColdDir(); coldDir != "" {
+		bases = append(bases, coldDir)
+	}
+
+	var uploads []upload
+	for _, root := range r.localRoots(bases) {
+		if _, err := os.Stat(root.path); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.Walk(root.path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || r.isBookkeeping(path) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			u, ok, err := r.planFile(root.base, path, info, current)
+			if err != nil {
+				if errors.Is(err, errAborted) {
+					return err
+				}
+				r.stats.Failed++
+				slog.Error("Failed to restore file",
+					slog.String("path", path),
+					slog.String("error", err.Error()),
+				)
+				return nil
+			}
+			if ok {
+				uploads = append(uploads, u)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return uploads, nil
+}
+
+// listRemote returns the current Dropbox state of the restored folders by path
+func (r *Restorer) listRemote(ctx context.Context) (map[string]dropbox.FileInfo, error) {
+	roots := r.config.RemotePaths
+	if len(roots) == 0 {
+		roots = []string{""}
+	}
+
+	current := make(map[string]dropbox.FileInfo)
+	for _, root := range roots {
+		var paths []string
+		if root != "" {
+			paths = []string{root}
+		}
+
+		files, err := r.client.ListPaths(ctx, paths)
+		if dropbox.IsNotFound(err) {
+			continue // Deleted since the backup; everything in it is restored
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Dropbox files: %w", err)
+		}
+		for _, file := range files {
+			if !file.IsFolder {
+				current[file.Path] = file
+			}
+		}
+	}
+	return current, nil
+}
+
+// isBookkeeping reports whether a local path is one of the files the backup
+// keeps next to the backed-up files, or an incomplete download
+func (r *Restorer) isBookkeeping(path string) bool {
+	return sidecar.IsSidecar(path) || transfer.IsPart(path) ||
+		path == manifest.Path(r.config.BackupDir) ||
+		path == manifest.HashSumPath(r.config.BackupDir) ||
+		path == listing.Path(r.config.BackupDir) ||
+		path == accountmeta.Path(r.config.BackupDir) ||
+		path == archive.ChainPath(r.config.BackupDir) ||
+		path == signing.SignaturePath(manifest.Path(r.config.BackupDir))
+}
+
+// localRoot is a local directory to restore from, inside the backup or cold
+// directory base
+type localRoot struct {
+	base string
+	path string
+}
+
+// localRoots returns the local directories to restore from in each of bases
+func (r *Restorer) localRoots(bases []string) []localRoot {
+	var roots []localRoot
+	for _, base := range bases {
+		if len(r.config.RemotePaths) == 0 {
+			roots = append(roots, localRoot{base: base, path: base})
+			continue
+		}
+		for _, remotePath := range r.config.RemotePaths {
+			roots = append(roots, localRoot{base: base, path: filepath.Join(base, strings.TrimPrefix(strings.ToLower(remotePath), "/"))})
+		}
+	}
+	return roots
+}
+
+// planFile decides whether and how one local file in the backup or cold
+// directory base is uploaded
+func (r *Restorer) planFile(base, localPath string, info os.FileInfo, current map[string]dropbox.FileInfo) (upload, bool, error) {
+	rel, err := filepath.Rel(base, localPath)
+	if err != nil {
+		return upload{}, false, err
+	}
+	key, entry, recorded := r.manifest.Resolve("/" + filepath.ToSlash(rel))
+	entry, recorded = r.withSidecar(localPath, entry, recorded)
+	namespace, remotePath := manifest.SplitKey(key)
+	if namespace != "" {
+		slog.Info("Skipping team space file; only the home namespace can be restored", slog.String("path", localPath))
+		r.stats.Skipped++
+		return upload{}, false, nil
+	}
+	if !r.config.Filter().Allows(remotePath) {
+		slog.Debug("Excluding file", slog.String("path", remotePath))
+		return upload{}, false, nil
+	}
+	if recorded && entry.Exported {
+		slog.Info("Skipping exported file; it can't be uploaded back", slog.String("path", localPath))
+		r.stats.Skipped++
+		return upload{}, false, nil
+	}
+
+	remoteFile, exists := current[remotePath]
+	if r.since != nil && !r.since.Changed(remotePath, remoteFile, exists) {
+		// Same on Dropbox as at the comparison point; no need to read the file
+		r.stats.Unchanged++
+		return upload{}, false, nil
+	}
+	if exists {
+		// Already on Dropbox by the same --compare strategy a backup would
+		// skip downloading it by
+		local := compare.Local{Path: localPath, Size: info.Size(), ModTime: info.ModTime()}
+		if recorded {
+			local.Rev, local.RevSize = entry.Rev, int64(entry.Size)
+		}
+		same, err := r.strategy.Same(local, remoteFile)
+		if err != nil {
+			return upload{}, false, err
+		}
+		if same {
+			r.stats.Unchanged++
+			return upload{}, false, nil
+		}
+	}
+
+	modTime, err := restoredModTime(localPath, info, entry, recorded)
+	if err != nil {
+		return upload{}, false, err
+	}
+	u := upload{
+		localPath:  localPath,
+		key:        key,
+		remotePath: remotePath,
+		size:       info.Size(),
+		opts:       dropbox.UploadOptions{ModTime: modTime},
+		cold:       base != r.config.BackupDir,
+	}
+	if recorded && strings.EqualFold(entry.DisplayPath, remotePath) {
+		// Keys and local copies are lower-case; recreate the original names
+		u.remotePath = entry.DisplayPath
+	}
+
+	switch {
+	case !exists:
+		// Missing on Dropbox: add it, failing if something appears meanwhile
+		return u, true, nil
+	case recorded && entry.Rev == remoteFile.Rev:
+		// Unchanged on Dropbox since the backup: replace it only if that still holds
+		u.opts.Rev = entry.Rev
+		return u, true, nil
+	default:
+		ok, err := r.resolveConflict(&u)
+		return u, ok, err
+	}
+}
+
+// resolveConflict applies the conflict policy to a file that changed on
+// Dropbox, updating its upload options. It reports whether to upload at all.
+func (r *Restorer) resolveConflict(u *upload) (bool, error) {
+	r.stats.Conflicts++
+
+	policy := r.policy
+	if policy == PolicyInteractive {
+		if r.config.DryRun {
+			r.out.Message("[dry-run] conflict %s (would ask)", u.remotePath)
+			return false, nil
+		}
+
+		answer, err := r.prompt.Ask(fmt.Sprintf("⚠️  %s changed on Dropbox since the backup.", u.remotePath), []ui.Choice{
+			{Key: "s", Label: "skip"},
+			{Key: "o", Label: "overwrite"},
+			{Key: "r", Label: "upload renamed copy"},
+			{Key: "q", Label: "quit"},
+		})
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", errAborted, err)
+		}
+		switch answer {
+		case "o":
+			policy = PolicyOverwrite
+		case "r":
+			policy = PolicyRename
+		case "q":
+			return false, errAborted
+		default:
+			policy = PolicySkip
+		}
+	}
+
+	u.opts.Rev = ""
+	switch policy {
+	case PolicyOverwrite:
+		u.opts.Overwrite = true
+	case PolicyRename:
+		u.opts.Autorename = true
+	default:
+		slog.Warn("Skipping file changed on Dropbox since the backup", slog.String("path", u.remotePath))
+		r.stats.Skipped++
+		return false, nil
+	}
+	return true, nil
+}
+
+// uploadBatch stages a batch of files concurrently and commits them to Dropbox
+// in a single request, which is far cheaper than one commit per file when
+// restoring many small files. Failed files are counted; only cancellation or
+// quitting at a conflict prompt returns an error.
+func (r *Restorer) uploadBatch(ctx context.Context, batch []upload) error {
+	staged := make([]*dropbox.StagedUpload, len(batch))
+	stageErrs := make([]error, len(batch))
+	indexes := make([]int, len(batch))
+	for i := range batch {
+		indexes[i] = i
+	}
+
+	name := func(i int) string { return batch[i].remotePath }
+	// Failures are counted per file below; only cancellation stops the restore
+	_ = transfer.Run(ctx, r.transfers, indexes, name, func(ctx context.Context, i int) error {
+		staged[i], stageErrs[i] = r.stage(ctx, batch[i])
+		return stageErrs[i]
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for i, err := range stageErrs {
+		if err != nil {
+			r.failed(batch[i], err)
+		}
+	}
+
+	var entries []dropbox.BatchEntry
+	var committed []upload
+	for i, u := range batch {
+		if staged[i] != nil {
+			entries = append(entries, dropbox.BatchEntry{Path: u.remotePath, Staged: staged[i], Options: u.opts})
+			committed = append(committed, u)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	results, err := r.client.CommitBatch(ctx, entries)
+	if err != nil {
+		for _, u := range committed {
+			r.failed(u, err)
+		}
+		return ctx.Err()
+	}
+
+	for i, res := range results {
+		u := committed[i]
+		err := res.Err
+		if err == nil {
+			r.uploaded(u, res.File)
+			continue
+		}
+		if errors.Is(err, dropbox.ErrConflict) {
+			// Changed on Dropbox after planning
+			err = r.resolveAndUpload(ctx, u)
+		}
+		if errors.Is(err, errAborted) {
+			return err
+		}
+		if err != nil {
+			r.failed(u, err)
+		}
+	}
+
+	return nil
+}
+
+// stage sends a local file to an upload session for a later batch commit
+func (r *Restorer) stage(ctx context.Context, u upload) (*dropbox.StagedUpload, error) {
+	f, err := os.Open(u.localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer f.Close()
+
+	return r.client.Stage(ctx, r.transfers.Reader(ctx, f), u.size)
+}
+
+// resolveAndUpload handles a conflict found while uploading
+func (r *Restorer) resolveAndUpload(ctx context.Context, u upload) error {
+	r.mu.Lock()
+	ok, err := r.resolveConflict(&u)
+	r.mu.Unlock()
+	if err != nil || !ok {
+		return err
+	}
+	return r.upload(ctx, u)
+}
+
+// upload sends a single local file to Dropbox
+func (r *Restorer) upload(ctx context.Context, u upload) error {
+	f, err := os.Open(u.localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer f.Close()
+
+	uploaded, err := r.client.Upload(ctx, u.remotePath, r.transfers.Reader(ctx, f), u.size, u.opts)
+	if err != nil {
+		return err
+	}
+
+	r.uploaded(u, uploaded)
+	return nil
+}
+
+// uploaded records the new revision of a restored file
+func (r *Restorer) uploaded(u upload, uploaded *dropbox.FileInfo) {
+	// A renamed copy doesn't replace the file the manifest describes
+	if uploaded.Path == u.key {
+		r.manifest.Set(u.key, manifest.Entry{
+			Rev:         uploaded.Rev,
+			ContentHash: uploaded.ContentHash,
+			Size:        uploaded.Size,
+			ModTime:     uploaded.ModTime,
+			Cold:        u.cold,
+			DisplayPath: uploaded.DisplayPath,
+		})
+	}
+
+	r.count(func(s *Stats) {
+		s.Uploaded++
+		s.Bytes += uint64(u.size)
+	})
+
+	slog.Info("Restored file",
+		slog.String("path", uploaded.DisplayPath),
+		slog.Int64("size", u.size),
+	)
+}
+
+// failed records a file that couldn't be restored
+func (r *Restorer) failed(u upload, err error) {
+	r.count(func(s *Stats) { s.Failed++ })
+	slog.Error("Failed to restore file",
+		slog.String("path", u.localPath),
+		slog.String("error", err.Error()),
+	)
+}
+
+// count updates the statistics from a concurrent upload
+func (r *Restorer) count(update func(*Stats)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(r.stats)
+}
+
+// uploadMode describes upload options for dry-run output
+func uploadMode(opts dropbox.UploadOptions) string {
+	switch {
+	case opts.Overwrite:
+		return "overwrite"
+	case opts.Autorename:
+		return "renamed copy"
+	case opts.Rev != "":
+		return "update"
+	default:
+		return "new"
+	}
+}
+
+// restoredModTime returns the client modification time to give a restored
+// file: the time recorded at backup if the local copy is still what was backed
+// up (its own mtime may have been lost copying the backup around), otherwise
+// the local file's mtime
+func restoredModTime(localPath string, info os.FileInfo, entry manifest.Entry, recorded bool) (time.Time, error) {
+	if !recorded || entry.ModTime.IsZero() || info.ModTime().Equal(entry.ModTime) {
+		return info.ModTime(), nil
+	}
+	if uint64(info.Size()) != entry.Size {
+		return info.ModTime(), nil // Edited since the backup
+	}
+	if entry.ContentHash == "" {
+		return entry.ModTime, nil
+	}
+
+	hashed := compare.Strategy{Mode: config.CompareHash}
+	same, err := hashed.Same(compare.Local{Path: localPath}, dropbox.FileInfo{ContentHash: entry.ContentHash})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if same {
+		return entry.ModTime, nil
+	}
+	return info.ModTime(), nil
+}
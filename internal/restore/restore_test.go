@@ -0,0 +1,533 @@
+package restore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/sidecar"
+	"create-dropbox-backup-folder/internal/ui"
+)
+
+// fakeRemote is an in-memory Dropbox that applies upload write modes
+type fakeRemote struct {
+	mu      sync.Mutex
+	files   map[string]dropbox.FileInfo
+	content map[string]string
+	staged  map[*dropbox.StagedUpload]string
+	nextRev int
+	commits int // CommitBatch calls
+}
+
+func newFakeRemote() *fakeRemote {
+	return &fakeRemote{
+		files:   make(map[string]dropbox.FileInfo),
+		content: make(map[string]string),
+		staged:  make(map[*dropbox.StagedUpload]string),
+	}
+}
+
+// put stores a file; like Dropbox it keeps the capitalization of the first
+// upload and tells files apart by their lower-case path
+func (f *fakeRemote) put(path, content string) dropbox.FileInfo {
+	f.nextRev++
+	hash, _ := dropbox.ContentHash(strings.NewReader(content))
+	key := strings.ToLower(path)
+	displayPath := path
+	if existing, ok := f.files[key]; ok {
+		displayPath = existing.DisplayPath
+	}
+	info := dropbox.FileInfo{
+		Path:        key,
+		DisplayPath: displayPath,
+		Name:        filepath.Base(displayPath),
+		Size:        uint64(len(content)),
+		ContentHash: hash,
+		Rev:         fmt.Sprintf("rev%d", f.nextRev),
+	}
+	f.files[key] = info
+	f.content[key] = content
+	return info
+}
+
+func (f *fakeRemote) ListPaths(ctx context.Context, paths []string) ([]dropbox.FileInfo, error) {
+	var list []dropbox.FileInfo
+	for _, info := range f.files {
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+func (f *fakeRemote) Upload(ctx context.Context, remotePath string, r io.Reader, size int64, opts dropbox.UploadOptions) (*dropbox.FileInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return f.write(remotePath, string(data), opts)
+}
+
+func (f *fakeRemote) Stage(ctx context.Context, r io.Reader, size int64) (*dropbox.StagedUpload, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	staged := &dropbox.StagedUpload{}
+	f.staged[staged] = string(data)
+	return staged, nil
+}
+
+func (f *fakeRemote) CommitBatch(ctx context.Context, entries []dropbox.BatchEntry) ([]dropbox.BatchResult, error) {
+	f.commits++
+	results := make([]dropbox.BatchResult, len(entries))
+	for i, entry := range entries {
+		results[i].File, results[i].Err = f.write(entry.Path, f.staged[entry.Staged], entry.Options)
+	}
+	return results, nil
+}
+
+// write applies an upload's write mode to the fake Dropbox
+func (f *fakeRemote) write(remotePath, data string, opts dropbox.UploadOptions) (*dropbox.FileInfo, error) {
+	existing, exists := f.files[strings.ToLower(remotePath)]
+	conflict := false
+	switch {
+	case opts.Overwrite:
+	case opts.Rev != "":
+		conflict = !exists || existing.Rev != opts.Rev
+	default:
+		conflict = exists && f.content[strings.ToLower(remotePath)] != data
+	}
+	if conflict {
+		if !opts.Autorename {
+			return nil, fmt.Errorf("upload %s: %w", remotePath, dropbox.ErrConflict)
+		}
+		ext := filepath.Ext(remotePath)
+		remotePath = strings.TrimSuffix(remotePath, ext) + " (1)" + ext
+	}
+
+	info := f.put(remotePath, data)
+	return &info, nil
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, name := range []string{"skip", "overwrite", "rename", "Interactive"} {
+		if _, err := ParsePolicy(name); err != nil {
+			t.Errorf("ParsePolicy(%q) error = %v", name, err)
+		}
+	}
+	if _, err := ParsePolicy("merge"); err == nil {
+		t.Error("ParsePolicy(merge) expected error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       Policy
+		answers      string
+		wantStats    Stats
+		wantConflict string // content of /conflict.txt on Dropbox afterwards
+		wantRenamed  bool
+	}{
+		{
+			name:         "skip conflicts",
+			policy:       PolicySkip,
+			wantStats:    Stats{Uploaded: 2, Unchanged: 1, Conflicts: 1, Skipped: 1, Bytes: 15},
+			wantConflict: "changed remotely",
+		},
+		{
+			name:         "overwrite conflicts",
+			policy:       PolicyOverwrite,
+			wantStats:    Stats{Uploaded: 3, Unchanged: 1, Conflicts: 1, Bytes: 24},
+			wantConflict: "backed up",
+		},
+		{
+			name:         "rename conflicts",
+			policy:       PolicyRename,
+			wantStats:    Stats{Uploaded: 3, Unchanged: 1, Conflicts: 1, Bytes: 24},
+			wantConflict: "changed remotely",
+			wantRenamed:  true,
+		},
+		{
+			name:         "interactive overwrite",
+			policy:       PolicyInteractive,
+			answers:      "o\n",
+			wantStats:    Stats{Uploaded: 3, Unchanged: 1, Conflicts: 1, Bytes: 24},
+			wantConflict: "backed up",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupDir := t.TempDir()
+			remote := newFakeRemote()
+			m, err := manifest.Load(backupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Backed up and untouched on Dropbox, but changed locally
+			original := remote.put("/edited.txt", "old")
+			m.Set("/edited.txt", manifest.Entry{Rev: original.Rev, Size: original.Size})
+			writeFile(t, backupDir, "edited.txt", "restored")
+
+			// Identical on both sides
+			same := remote.put("/same.txt", "same")
+			m.Set("/same.txt", manifest.Entry{Rev: same.Rev, Size: same.Size})
+			writeFile(t, backupDir, "same.txt", "same")
+
+			// Deleted from Dropbox after the backup
+			m.Set("/docs/deleted.txt", manifest.Entry{Rev: "rev-old"})
+			writeFile(t, backupDir, "docs/deleted.txt", "deleted")
+
+			// Changed on Dropbox after the backup
+			m.Set("/conflict.txt", manifest.Entry{Rev: "rev-backup"})
+			remote.put("/conflict.txt", "changed remotely")
+			writeFile(t, backupDir, "conflict.txt", "backed up")
+
+			if err := m.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &config.Config{BackupDir: backupDir}
+			prompt := ui.NewPrompt(strings.NewReader(tt.answers), &bytes.Buffer{})
+			restorer, err := New(cfg, remote, tt.policy, prompt, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := restorer.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if *stats != tt.wantStats {
+				t.Errorf("Run() stats = %+v, want %+v", *stats, tt.wantStats)
+			}
+			if got := remote.content["/edited.txt"]; got != "restored" {
+				t.Errorf("/edited.txt = %q, want restored", got)
+			}
+			if got := remote.content["/docs/deleted.txt"]; got != "deleted" {
+				t.Errorf("/docs/deleted.txt = %q, want deleted", got)
+			}
+			if got := remote.content["/conflict.txt"]; got != tt.wantConflict {
+				t.Errorf("/conflict.txt = %q, want %q", got, tt.wantConflict)
+			}
+			if _, renamed := remote.files["/conflict (1).txt"]; renamed != tt.wantRenamed {
+				t.Errorf("renamed copy exists = %v, want %v", renamed, tt.wantRenamed)
+			}
+
+			// The manifest now describes the restored revisions
+			saved, err := manifest.Load(backupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry, _ := saved.Get("/edited.txt"); entry.Rev != remote.files["/edited.txt"].Rev {
+				t.Errorf("manifest rev for /edited.txt = %q, want %q", entry.Rev, remote.files["/edited.txt"].Rev)
+			}
+		})
+	}
+}
+
+func TestRunInteractiveQuit(t *testing.T) {
+	backupDir := t.TempDir()
+	remote := newFakeRemote()
+	remote.put("/a.txt", "remote")
+	writeFile(t, backupDir, "a.txt", "local")
+
+	cfg := &config.Config{BackupDir: backupDir}
+	prompt := ui.NewPrompt(strings.NewReader("q\n"), &bytes.Buffer{})
+	restorer, err := New(cfg, remote, PolicyInteractive, prompt, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restorer.Run(context.Background()); err == nil {
+		t.Error("Run() expected error after quitting")
+	}
+	if got := remote.content["/a.txt"]; got != "remote" {
+		t.Errorf("/a.txt = %q, want remote", got)
+	}
+}
+
+func TestRunCommitsInBatches(t *testing.T) {
+	backupDir := t.TempDir()
+	files := dropbox.MaxBatchCommit + 1
+	for i := 0; i < files; i++ {
+		writeFile(t, backupDir, fmt.Sprintf("dir/%04d.txt", i), "x")
+	}
+
+	remote := newFakeRemote()
+	cfg := &config.Config{BackupDir: backupDir, MaxConcurrency: 8}
+	restorer, err := New(cfg, remote, PolicySkip, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := restorer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if stats.Uploaded != files {
+		t.Errorf("Run() uploaded = %d, want %d", stats.Uploaded, files)
+	}
+	if remote.commits != 2 {
+		t.Errorf("CommitBatch calls = %d, want 2", remote.commits)
+	}
+}
+
+func TestRunSkipsTeamSpace(t *testing.T) {
+	backupDir := t.TempDir()
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set(manifest.Key("4242", "/plan.txt"), manifest.Entry{Rev: "a", Namespace: "4242"})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, backupDir, "ns-4242/plan.txt", "team")
+
+	remote := newFakeRemote()
+	restorer, err := New(&config.Config{BackupDir: backupDir}, remote, PolicySkip, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := restorer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if stats.Skipped != 1 || stats.Uploaded != 0 {
+		t.Errorf("Run() stats = %+v, want 1 skipped", *stats)
+	}
+	if len(remote.files) != 0 {
+		t.Errorf("uploaded %d files to the home namespace, want none", len(remote.files))
+	}
+}
+
+func TestRunKeepsDisplayPaths(t *testing.T) {
+	backupDir := t.TempDir()
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set("/docs/report.txt", manifest.Entry{Rev: "a", DisplayPath: "/Docs/Report.TXT"})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, backupDir, "docs/report.txt", "recorded")
+	writeFile(t, backupDir, "docs/notes.txt", "unrecorded")
+
+	remote := newFakeRemote()
+	restorer, err := New(&config.Config{BackupDir: backupDir}, remote, PolicySkip, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restorer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Without a recorded capitalization the lower-case path is all there is
+	if got := remote.files["/docs/notes.txt"].DisplayPath; got != "/docs/notes.txt" {
+		t.Errorf("unrecorded file uploaded to %q, want /docs/notes.txt", got)
+	}
+	if got := remote.files["/docs/report.txt"].DisplayPath; got != "/Docs/Report.TXT" {
+		t.Errorf("recorded file uploaded to %q, want /Docs/Report.TXT", got)
+	}
+}
+
+func TestRunRestoresColdFiles(t *testing.T) {
+	backupDir := t.TempDir()
+	coldDir := t.TempDir()
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetColdDir(coldDir)
+	m.Set("/docs/old.txt", manifest.Entry{Rev: "a", Cold: true})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, coldDir, "docs/old.txt", "cold")
+	writeFile(t, backupDir, "docs/new.txt", "hot")
+
+	remote := newFakeRemote()
+	restorer, err := New(&config.Config{BackupDir: backupDir, RemotePaths: []string{"/docs"}}, remote, PolicySkip, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := restorer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if stats.Uploaded != 2 {
+		t.Errorf("Run() stats = %+v, want 2 uploaded", *stats)
+	}
+	if got := remote.content["/docs/old.txt"]; got != "cold" {
+		t.Errorf("/docs/old.txt = %q, want cold", got)
+	}
+	saved, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := saved.Get("/docs/old.txt"); !entry.Cold {
+		t.Error("restored cold file no longer marked cold")
+	}
+	if entry, _ := saved.Get("/docs/new.txt"); entry.Cold {
+		t.Error("restored file marked cold")
+	}
+}
+
+func TestRestoredModTime(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "content")
+	path := filepath.Join(dir, "a.txt")
+
+	local := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC) // e.g. copied without preserving times
+	if err := os.Chtimes(path, local, local); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backedUp := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	hash, _ := dropbox.ContentHash(strings.NewReader("content"))
+
+	tests := []struct {
+		name     string
+		entry    manifest.Entry
+		recorded bool
+		want     time.Time
+	}{
+		{name: "not recorded", want: local},
+		{name: "unchanged content", entry: manifest.Entry{ModTime: backedUp, Size: 7, ContentHash: hash}, recorded: true, want: backedUp},
+		{name: "unchanged size without hash", entry: manifest.Entry{ModTime: backedUp, Size: 7}, recorded: true, want: backedUp},
+		{name: "edited, same size", entry: manifest.Entry{ModTime: backedUp, Size: 7, ContentHash: "other"}, recorded: true, want: local},
+		{name: "edited, different size", entry: manifest.Entry{ModTime: backedUp, Size: 3}, recorded: true, want: local},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := restoredModTime(path, info, tt.entry, tt.recorded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("restoredModTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunUsesSidecar(t *testing.T) {
+	backupDir := t.TempDir()
+	writeFile(t, backupDir, "docs/a.txt", "local edit")
+
+	remote := newFakeRemote()
+	backedUp := remote.put("/docs/a.txt", "original")
+
+	// No manifest: only the sidecar knows which revision was backed up
+	store := sidecar.NewStore()
+	if err := store.Set(filepath.Join(backupDir, "docs", "a.txt"), sidecar.Entry{Rev: backedUp.Rev, Size: uint64(len("original")), ModTime: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{BackupDir: backupDir, Sidecar: true}
+	restorer, err := New(cfg, remote, PolicySkip, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := restorer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if stats.Uploaded != 1 || stats.Conflicts != 0 {
+		t.Errorf("Run() stats = %+v, want 1 uploaded without conflicts", *stats)
+	}
+	if remote.content["/docs/a.txt"] != "local edit" {
+		t.Errorf("remote content = %q, want the local edit", remote.content["/docs/a.txt"])
+	}
+	if _, ok := remote.files["/docs/"+sidecar.FileName]; ok {
+		t.Error("restored the sidecar file")
+	}
+}
+
+func TestRunCompareModes(t *testing.T) {
+	tests := []struct {
+		compare   string
+		wantStats Stats
+	}{
+		// Trusts the recorded revision; the unrecorded file's times differ
+		{compare: config.CompareMtimeSize, wantStats: Stats{Unchanged: 1, Conflicts: 1, Skipped: 1}},
+		// Catches the edit of the recorded revision, recognizes the unrecorded copy
+		{compare: config.CompareHash, wantStats: Stats{Uploaded: 1, Unchanged: 1, Bytes: 4}},
+		// Trusts the recorded revision, knows nothing of the unrecorded file
+		{compare: config.CompareRev, wantStats: Stats{Unchanged: 1, Conflicts: 1, Skipped: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.compare, func(t *testing.T) {
+			backupDir := t.TempDir()
+			remote := newFakeRemote()
+			m, err := manifest.Load(backupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Edited locally without changing its size
+			recorded := remote.put("/recorded.txt", "same")
+			m.Set("/recorded.txt", manifest.Entry{Rev: recorded.Rev, Size: recorded.Size})
+			writeFile(t, backupDir, "recorded.txt", "edit")
+
+			// Identical on both sides, but not in the manifest
+			remote.put("/unrecorded.txt", "copy")
+			writeFile(t, backupDir, "unrecorded.txt", "copy")
+
+			if err := m.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &config.Config{BackupDir: backupDir, Compare: tt.compare}
+			restorer, err := New(cfg, remote, PolicySkip, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := restorer.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if *stats != tt.wantStats {
+				t.Errorf("Run() stats = %+v, want %+v", *stats, tt.wantStats)
+			}
+		})
+	}
+}
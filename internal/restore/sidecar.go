@@ -0,0 +1,35 @@
+package restore
+
+import (
+	"log/slog"
+
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// withSidecar fills in a file the manifest doesn't know from the sidecar of
+// its directory, so a backup copied around without its manifest (or written
+// to a target that lost the modification times) still restores with the
+// recorded revision and modification time
+func (r *Restorer) withSidecar(localPath string, entry manifest.Entry, recorded bool) (manifest.Entry, bool) {
+	if r.sidecars == nil || (recorded && !entry.ModTime.IsZero()) {
+		return entry, recorded
+	}
+	side, ok, err := r.sidecars.Get(localPath)
+	if err != nil {
+		slog.Warn("Failed to read file metadata",
+			slog.String("path", localPath),
+			slog.String("error", err.Error()),
+		)
+		return entry, recorded
+	}
+	if !ok {
+		return entry, recorded
+	}
+	if !recorded {
+		entry.Rev = side.Rev
+	}
+	entry.ModTime = side.ModTime
+	entry.Size = side.Size
+	entry.ContentHash = side.ContentHash
+	return entry, true
+}
@@ -0,0 +1,71 @@
+package restore
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+)
+
+// sinceDateLayouts are the date formats accepted by ParseSince
+var sinceDateLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+// Since limits a restore to files whose Dropbox copy differs from an earlier
+// state: either a backup snapshot or a point in time
+type Since struct {
+	// Snapshot is the manifest of the backup to compare Dropbox against
+	Snapshot *manifest.Manifest
+	// Time is used when no snapshot is given; files modified on Dropbox
+	// after it are restored
+	Time time.Time
+
+	label string
+}
+
+// ParseSince reads a --since value: a backup directory containing a manifest,
+// or a date (YYYY-MM-DD, YYYY-MM-DDTHH:MM or RFC 3339) in local time
+func ParseSince(value string) (*Since, error) {
+	if info, err := os.Stat(value); err == nil && info.IsDir() {
+		if _, err := os.Stat(manifest.Path(value)); err != nil {
+			return nil, fmt.Errorf("snapshot %s has no backup manifest", value)
+		}
+		m, err := manifest.Load(value)
+		if err != nil {
+			return nil, err
+		}
+		return &Since{Snapshot: m, label: "snapshot " + value}, nil
+	}
+
+	for _, layout := range sinceDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &Since{Time: t, label: t.Format(time.RFC3339)}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid --since value: %s (must be a backup directory or a date like 2024-03-01)", value)
+}
+
+// String describes the comparison point for output
+func (s *Since) String() string {
+	return s.label
+}
+
+// Changed reports whether a Dropbox file differs from the comparison point.
+// A file missing from Dropbox always differs unless the snapshot didn't have
+// it either.
+func (s *Since) Changed(remotePath string, remoteFile dropbox.FileInfo, exists bool) bool {
+	if s.Snapshot == nil {
+		return !exists || remoteFile.ModTime.After(s.Time)
+	}
+
+	entry, recorded := s.Snapshot.Get(remotePath)
+	switch {
+	case !exists || !recorded:
+		return exists != recorded
+	case entry.ContentHash != "" && remoteFile.ContentHash != "":
+		return entry.ContentHash != remoteFile.ContentHash
+	default:
+		return entry.Rev != remoteFile.Rev
+	}
+}
@@ -0,0 +1,144 @@
+package restore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/ui"
+)
+
+func TestParseSince(t *testing.T) {
+	snapshot := t.TempDir()
+	m, err := manifest.Load(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value        string
+		wantSnapshot bool
+		wantTime     time.Time
+		wantErr      bool
+	}{
+		{value: snapshot, wantSnapshot: true},
+		{value: "2024-03-01", wantTime: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2024-03-01T14:30", wantTime: time.Date(2024, 3, 1, 14, 30, 0, 0, time.Local)},
+		{value: "2024-03-01T14:30:00Z", wantTime: time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)},
+		{value: t.TempDir(), wantErr: true}, // no manifest
+		{value: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			since, err := ParseSince(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (since.Snapshot != nil) != tt.wantSnapshot {
+				t.Errorf("ParseSince() snapshot = %v, want %v", since.Snapshot != nil, tt.wantSnapshot)
+			}
+			if !since.Time.Equal(tt.wantTime) {
+				t.Errorf("ParseSince() time = %v, want %v", since.Time, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestSinceChanged(t *testing.T) {
+	cutoff := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	snapshot, err := manifest.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Set("/hash.txt", manifest.Entry{Rev: "a", ContentHash: "h1"})
+	snapshot.Set("/rev.txt", manifest.Entry{Rev: "a"})
+	snapshot.Set("/deleted.txt", manifest.Entry{Rev: "a"})
+
+	tests := []struct {
+		name   string
+		since  *Since
+		path   string
+		remote dropbox.FileInfo
+		exists bool
+		want   bool
+	}{
+		{name: "date: modified before", since: &Since{Time: cutoff}, remote: dropbox.FileInfo{ModTime: cutoff.Add(-time.Hour)}, exists: true, want: false},
+		{name: "date: modified after", since: &Since{Time: cutoff}, remote: dropbox.FileInfo{ModTime: cutoff.Add(time.Hour)}, exists: true, want: true},
+		{name: "date: missing", since: &Since{Time: cutoff}, want: true},
+		{name: "snapshot: same hash, new rev", since: &Since{Snapshot: snapshot}, path: "/hash.txt", remote: dropbox.FileInfo{Rev: "b", ContentHash: "h1"}, exists: true, want: false},
+		{name: "snapshot: different hash", since: &Since{Snapshot: snapshot}, path: "/hash.txt", remote: dropbox.FileInfo{Rev: "a", ContentHash: "h2"}, exists: true, want: true},
+		{name: "snapshot: same rev", since: &Since{Snapshot: snapshot}, path: "/rev.txt", remote: dropbox.FileInfo{Rev: "a", ContentHash: "h1"}, exists: true, want: false},
+		{name: "snapshot: different rev", since: &Since{Snapshot: snapshot}, path: "/rev.txt", remote: dropbox.FileInfo{Rev: "b"}, exists: true, want: true},
+		{name: "snapshot: deleted since", since: &Since{Snapshot: snapshot}, path: "/deleted.txt", want: true},
+		{name: "snapshot: added since", since: &Since{Snapshot: snapshot}, path: "/new.txt", remote: dropbox.FileInfo{Rev: "a"}, exists: true, want: true},
+		{name: "snapshot: never existed", since: &Since{Snapshot: snapshot}, path: "/other.txt", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.since.Changed(tt.path, tt.remote, tt.exists); got != tt.want {
+				t.Errorf("Changed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunSinceSnapshot(t *testing.T) {
+	backupDir := t.TempDir()
+	remote := newFakeRemote()
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged on Dropbox since the snapshot, but edited locally: out of scope
+	kept := remote.put("/kept.txt", "remote")
+	m.Set("/kept.txt", manifest.Entry{Rev: kept.Rev, ContentHash: kept.ContentHash})
+	writeFile(t, backupDir, "kept.txt", "local edit")
+
+	// Deleted from Dropbox since the snapshot
+	m.Set("/deleted.txt", manifest.Entry{Rev: "rev-old"})
+	writeFile(t, backupDir, "deleted.txt", "deleted")
+
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	since, err := ParseSince(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{BackupDir: backupDir}
+	restorer, err := New(cfg, remote, PolicySkip, ui.NewPrompt(strings.NewReader(""), &bytes.Buffer{}), since, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := restorer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := Stats{Uploaded: 1, Unchanged: 1, Bytes: 7}
+	if *stats != want {
+		t.Errorf("Run() stats = %+v, want %+v", *stats, want)
+	}
+	if got := remote.content["/kept.txt"]; got != "remote" {
+		t.Errorf("/kept.txt = %q, want remote", got)
+	}
+	if got := remote.content["/deleted.txt"]; got != "deleted" {
+		t.Errorf("/deleted.txt = %q, want deleted", got)
+	}
+}
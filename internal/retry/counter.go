@@ -0,0 +1,24 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// counterKey is the context key of a retry counter
+type counterKey struct{}
+
+// WithCounter returns a context that counts the retries Do makes for calls
+// under it, e.g. all API calls made for one file. The counter is shared
+// with contexts derived from the returned one.
+func WithCounter(ctx context.Context) (context.Context, *atomic.Int64) {
+	counter := new(atomic.Int64)
+	return context.WithValue(ctx, counterKey{}, counter), counter
+}
+
+// countRetry adds a retry to the counter of ctx, if it has one
+func countRetry(ctx context.Context) {
+	if counter, ok := ctx.Value(counterKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+}
@@ -0,0 +1,135 @@
+// Package retry repeats failed operations with exponential backoff and
+// jitter, following a policy that names the classes of errors worth retrying.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Error classes a policy can retry. Callers classify their errors into one
+// of these, or "" for errors that are never retried.
+const (
+	// Network covers connection failures such as refused or reset connections
+	Network = "network"
+	// Timeout covers calls that didn't finish in time
+	Timeout = "timeout"
+	// RateLimit covers requests rejected as too many (HTTP 429)
+	RateLimit = "rate_limit"
+	// Server covers server-side failures (HTTP 5xx)
+	Server = "server"
+)
+
+// Classes lists all error classes
+var Classes = []string{Network, Timeout, RateLimit, Server}
+
+// Policy decides whether and when a failed call is retried. The delay before
+// retry n is BaseDelay × Multiplier^(n-1), capped at MaxDelay and then varied
+// randomly by up to ±Jitter of itself.
+type Policy struct {
+	// MaxAttempts is the total number of calls, including the first; 1 or
+	// less disables retries
+	MaxAttempts int           `json:"max_attempts"`
+	BaseDelay   time.Duration `json:"base_delay"`
+	MaxDelay    time.Duration `json:"max_delay"`
+	Multiplier  float64       `json:"multiplier"`
+	// Jitter is the fraction (0 to 1) of each delay that is randomized
+	Jitter float64 `json:"jitter"`
+	// RetryOn lists the error classes that are retried
+	RetryOn []string `json:"retry_on"`
+}
+
+// DefaultPolicy returns the policy used unless configured otherwise
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    time.Minute,
+		Multiplier:  2,
+		Jitter:      0.2,
+		RetryOn:     slices.Clone(Classes),
+	}
+}
+
+// Validate checks that the policy's values are usable
+func (p Policy) Validate() error {
+	if p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("invalid retry delays: %s, %s (must not be negative)", p.BaseDelay, p.MaxDelay)
+	}
+	if p.MaxDelay > 0 && p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("invalid retry max delay: %s (must not be less than the base delay %s)", p.MaxDelay, p.BaseDelay)
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("invalid retry multiplier: %g (must be at least 1)", p.Multiplier)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("invalid retry jitter: %g (must be between 0 and 1)", p.Jitter)
+	}
+	for _, class := range p.RetryOn {
+		if !slices.Contains(Classes, class) {
+			return fmt.Errorf("invalid retry error class: %s (must be one of %s)", class, strings.Join(Classes, ", "))
+		}
+	}
+	return nil
+}
+
+// Retries reports whether the policy retries errors of a class
+func (p Policy) Retries(class string) bool {
+	return class != "" && slices.Contains(p.RetryOn, class)
+}
+
+// Delay returns how long to wait after the given failed attempt (counted from
+// 1). random is a number in [0, 1) that places the delay within the jitter.
+func (p Policy) Delay(attempt int, random float64) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	delay *= 1 + p.Jitter*(2*random-1)
+	return time.Duration(delay)
+}
+
+// Do calls fn until it succeeds, fails with an error classify maps to a class
+// the policy doesn't retry, or MaxAttempts calls have been made. It returns
+// the last error, or the context's error if ctx ends while waiting.
+// Retries are added to the counter of ctx, if any (see WithCounter).
+func (p Policy) Do(ctx context.Context, classify func(error) string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts {
+			return err
+		}
+		class := classify(err)
+		if !p.Retries(class) {
+			return err
+		}
+
+		delay := p.Delay(attempt, rand.Float64())
+		slog.Debug("Retrying after error",
+			slog.String("class", class),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.String("error", err.Error()),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		countRetry(ctx)
+	}
+}
@@ -0,0 +1,166 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	policy := Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2, Jitter: 0.5}
+
+	tests := []struct {
+		name    string
+		attempt int
+		random  float64
+		want    time.Duration
+	}{
+		{name: "first retry", attempt: 1, random: 0.5, want: time.Second},
+		{name: "grows by multiplier", attempt: 3, random: 0.5, want: 4 * time.Second},
+		{name: "capped at max delay", attempt: 5, random: 0.5, want: 5 * time.Second},
+		{name: "low jitter", attempt: 1, random: 0, want: 500 * time.Millisecond},
+		{name: "high jitter", attempt: 2, random: 1, want: 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Delay(tt.attempt, tt.random); got != tt.want {
+				t.Errorf("Delay(%d, %g) = %s, want %s", tt.attempt, tt.random, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	errRetryable := errors.New("temporary")
+	errFatal := errors.New("permanent")
+	classify := func(err error) string {
+		if errors.Is(err, errRetryable) {
+			return Server
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		failures  []error
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "succeeds first time",
+			policy:    Policy{MaxAttempts: 3, RetryOn: Classes},
+			wantCalls: 1,
+		},
+		{
+			name:      "retries until success",
+			policy:    Policy{MaxAttempts: 3, RetryOn: Classes},
+			failures:  []error{errRetryable, errRetryable},
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after max attempts",
+			policy:    Policy{MaxAttempts: 2, RetryOn: Classes},
+			failures:  []error{errRetryable, errRetryable, errRetryable},
+			wantCalls: 2,
+			wantErr:   errRetryable,
+		},
+		{
+			name:      "unclassified errors are not retried",
+			policy:    Policy{MaxAttempts: 3, RetryOn: Classes},
+			failures:  []error{errFatal},
+			wantCalls: 1,
+			wantErr:   errFatal,
+		},
+		{
+			name:      "class not in policy",
+			policy:    Policy{MaxAttempts: 3, RetryOn: []string{RateLimit}},
+			failures:  []error{errRetryable},
+			wantCalls: 1,
+			wantErr:   errRetryable,
+		},
+		{
+			name:      "zero policy calls once",
+			failures:  []error{errRetryable},
+			wantCalls: 1,
+			wantErr:   errRetryable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.policy.Do(context.Background(), classify, func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Do() made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{MaxAttempts: 5, BaseDelay: time.Hour, RetryOn: Classes}
+
+	calls := 0
+	err := policy.Do(ctx, func(error) string { return Network }, func() error {
+		calls++
+		cancel()
+		return errors.New("connection reset")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("Do() made %d calls, want 1", calls)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{name: "default", policy: DefaultPolicy()},
+		{name: "zero", policy: Policy{}},
+		{name: "negative delay", policy: Policy{BaseDelay: -time.Second}, wantErr: true},
+		{name: "max below base", policy: Policy{BaseDelay: time.Minute, MaxDelay: time.Second}, wantErr: true},
+		{name: "shrinking multiplier", policy: Policy{Multiplier: 0.5}, wantErr: true},
+		{name: "jitter above one", policy: Policy{Jitter: 1.5}, wantErr: true},
+		{name: "unknown class", policy: Policy{RetryOn: []string{"auth"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoCountsRetries(t *testing.T) {
+	ctx, retries := WithCounter(context.Background())
+	policy := Policy{MaxAttempts: 3, RetryOn: Classes}
+
+	for range 2 {
+		_ = policy.Do(ctx, func(error) string { return Network }, func() error {
+			return errors.New("connection reset")
+		})
+	}
+	if got := retries.Load(); got != 4 {
+		t.Errorf("counted %d retries, want 4", got)
+	}
+}
@@ -0,0 +1,162 @@
+// Package sidecar keeps file metadata in a file next to the backed-up files
+// of each directory, for backup targets that can't preserve it themselves
+// (e.g. SMB shares or S3 mounts that reset modification times).
+package sidecar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the sidecar file in every backed-up directory
+const FileName = ".dropbox-meta.json"
+
+// Entry is the metadata of one file as it was on Dropbox when downloaded
+type Entry struct {
+	ModTime     time.Time `json:"mod_time"`
+	Size        uint64    `json:"size"`
+	ContentHash string    `json:"content_hash,omitempty"`
+	Rev         string    `json:"rev,omitempty"`
+}
+
+// Path returns the sidecar file of a directory
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// IsSidecar reports whether a path is a sidecar file
+func IsSidecar(path string) bool {
+	return filepath.Base(path) == FileName
+}
+
+// Store reads and updates the sidecar files of a backup. Directories are
+// loaded when first used and written by Save. It is safe for concurrent use.
+type Store struct {
+	mu    sync.Mutex
+	dirs  map[string]map[string]Entry
+	dirty map[string]bool
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		dirs:  make(map[string]map[string]Entry),
+		dirty: make(map[string]bool),
+	}
+}
+
+// Get returns the recorded metadata of a file
+func (s *Store) Get(path string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.load(filepath.Dir(path))
+	if err != nil {
+		return Entry{}, false, err
+	}
+	entry, ok := entries[filepath.Base(path)]
+	return entry, ok, nil
+}
+
+// Set records the metadata of a file
+func (s *Store) Set(path string, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := filepath.Dir(path)
+	entries, err := s.load(dir)
+	if err != nil {
+		return err
+	}
+	entries[filepath.Base(path)] = entry
+	s.dirty[dir] = true
+	return nil
+}
+
+// Delete forgets the metadata of a file
+func (s *Store) Delete(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := filepath.Dir(path)
+	entries, err := s.load(dir)
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[filepath.Base(path)]; ok {
+		delete(entries, filepath.Base(path))
+		s.dirty[dir] = true
+	}
+	return nil
+}
+
+// load returns the entries of a directory, reading its sidecar file the
+// first time. s.mu must be held.
+func (s *Store) load(dir string) (map[string]Entry, error) {
+	if entries, ok := s.dirs[dir]; ok {
+		return entries, nil
+	}
+
+	entries := make(map[string]Entry)
+	data, err := os.ReadFile(Path(dir))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read sidecar: %w", err)
+	default:
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse sidecar %s: %w", Path(dir), err)
+		}
+	}
+	s.dirs[dir] = entries
+	return entries, nil
+}
+
+// Save writes the sidecar files of all changed directories. A directory
+// whose files are all gone loses its sidecar file.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for dir := range s.dirty {
+		if err := save(dir, s.dirs[dir]); err != nil {
+			return err
+		}
+		delete(s.dirty, dir)
+	}
+	return nil
+}
+
+// save atomically replaces the sidecar file of a directory
+func save(dir string, entries map[string]Entry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(Path(dir)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove sidecar: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sidecar: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+FileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create sidecar: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), Path(dir)); err != nil {
+		return fmt.Errorf("failed to replace sidecar: %w", err)
+	}
+	return nil
+}
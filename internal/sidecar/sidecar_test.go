@@ -0,0 +1,78 @@
+package sidecar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	if err := os.MkdirAll(docs, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	entry := Entry{ModTime: modTime, Size: 42, ContentHash: "abc", Rev: "015f"}
+
+	store := NewStore()
+	if err := store.Set(filepath.Join(docs, "a.txt"), entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(filepath.Join(dir, "b.txt"), Entry{ModTime: modTime, Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A fresh store reads what was saved
+	got, ok, err := NewStore().Get(filepath.Join(docs, "a.txt"))
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v, want entry", ok, err)
+	}
+	if !got.ModTime.Equal(modTime) || got.Size != 42 || got.ContentHash != "abc" || got.Rev != "015f" {
+		t.Errorf("Get() = %+v, want %+v", got, entry)
+	}
+
+	if _, ok, _ := NewStore().Get(filepath.Join(docs, "missing.txt")); ok {
+		t.Error("Get() found an entry for an unrecorded file")
+	}
+}
+
+func TestStoreDeleteRemovesEmptySidecar(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+
+	store := NewStore()
+	if err := store.Set(path, Entry{Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(Path(dir)); err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+
+	if err := store.Delete(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(Path(dir)); !os.IsNotExist(err) {
+		t.Errorf("sidecar of empty directory kept: %v", err)
+	}
+}
+
+func TestIsSidecar(t *testing.T) {
+	if !IsSidecar(filepath.Join("backup", "docs", FileName)) {
+		t.Error("IsSidecar() = false for a sidecar file")
+	}
+	if IsSidecar(filepath.Join("backup", "docs", "notes.json")) {
+		t.Error("IsSidecar() = true for a backed-up file")
+	}
+}
@@ -0,0 +1,79 @@
+// Package signing creates and checks detached GPG signatures of run
+// manifests, so consumers can tell whether a backup report was altered after
+// it was written.
+package signing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SignatureExt is appended to a file's path to name its signature
+const SignatureExt = ".asc"
+
+// ErrNotSigned is returned by Verify when a file has no signature
+var ErrNotSigned = errors.New("file is not signed")
+
+// gpgCommand is the GPG executable, replaced in tests
+var gpgCommand = "gpg"
+
+// SignaturePath returns where the signature of a file is stored
+func SignaturePath(path string) string {
+	return path + SignatureExt
+}
+
+// Sign writes an ASCII-armored detached signature of path made with the given
+// GPG key (a key ID, fingerprint or user ID) next to it. The key must be
+// usable without a passphrase prompt, e.g. through gpg-agent.
+func Sign(ctx context.Context, key, path string) error {
+	cmd := exec.CommandContext(ctx, gpgCommand, "--batch", "--yes", "--armor",
+		"--local-user", key, "--output", SignaturePath(path), "--detach-sign", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to sign %s: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Verify checks the detached signature of path and returns the user ID of
+// the key that made it. The key must be in the local keyring.
+func Verify(ctx context.Context, path string) (string, error) {
+	if _, err := os.Stat(SignaturePath(path)); os.IsNotExist(err) {
+		return "", ErrNotSigned
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, gpgCommand, "--batch", "--status-fd", "1",
+		"--verify", SignaturePath(path), path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("bad signature for %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	signer, ok := goodSignature(out)
+	if !ok {
+		return "", fmt.Errorf("bad signature for %s: %s", path, strings.TrimSpace(stderr.String()))
+	}
+	return signer, nil
+}
+
+// goodSignature finds the signer in GPG's machine-readable status output
+func goodSignature(status []byte) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		// [GNUPG:] GOODSIG <long key id> <user id>
+		rest, ok := strings.CutPrefix(scanner.Text(), "[GNUPG:] GOODSIG ")
+		if !ok {
+			continue
+		}
+		_, userID, _ := strings.Cut(rest, " ")
+		return userID, true
+	}
+	return "", false
+}
@@ -0,0 +1,91 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// newKeyring creates a throwaway GPG home with a passphrase-less signing key
+func newKeyring(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath(gpgCommand); err != nil {
+		t.Skip("gpg not installed")
+	}
+
+	// Kept short: gpg-agent's socket path is limited in length
+	home, err := os.MkdirTemp("", "gpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+	t.Cleanup(func() {
+		_ = exec.Command("gpgconf", "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+
+	uid := "Backup Test <backup@example.com>"
+	out, err := exec.Command(gpgCommand, "--batch", "--passphrase", "",
+		"--quick-gen-key", uid, "ed25519", "sign", "never").CombinedOutput()
+	if err != nil {
+		t.Skipf("gpg cannot create a key here: %v: %s", err, out)
+	}
+	return uid
+}
+
+func TestSignAndVerify(t *testing.T) {
+	uid := newKeyring(t)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte(`{"version":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(ctx, path); !errors.Is(err, ErrNotSigned) {
+		t.Errorf("Verify() before signing error = %v, want ErrNotSigned", err)
+	}
+
+	if err := Sign(ctx, "backup@example.com", path); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	signer, err := Verify(ctx, path)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if signer != uid {
+		t.Errorf("Verify() signer = %q, want %q", signer, uid)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(ctx, path); err == nil {
+		t.Error("Verify() of altered file should fail")
+	}
+}
+
+func TestSignUnknownKey(t *testing.T) {
+	newKeyring(t)
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Sign(context.Background(), "nobody@example.com", path); err == nil {
+		t.Error("Sign() with unknown key should fail")
+	}
+}
+
+func TestGoodSignature(t *testing.T) {
+	status := []byte("[GNUPG:] NEWSIG\n[GNUPG:] GOODSIG 0123456789ABCDEF Backup Test <backup@example.com>\n[GNUPG:] VALIDSIG ...\n")
+	if signer, ok := goodSignature(status); !ok || signer != "Backup Test <backup@example.com>" {
+		t.Errorf("goodSignature() = %q, %v", signer, ok)
+	}
+	if _, ok := goodSignature([]byte("[GNUPG:] BADSIG 0123456789ABCDEF Backup Test\n")); ok {
+		t.Error("goodSignature() accepted a bad signature")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package syncimport
+
+import "create-dropbox-backup-folder/internal/xattr"
+
+// isIgnored reports whether a folder is marked as ignored with an extended
+// attribute; where they aren't supported nothing is ignored
+func isIgnored(path string) bool {
+	value, err := xattr.Get(path, ignoredAttribute)
+	return err == nil && value != ""
+}
@@ -0,0 +1,10 @@
+package syncimport
+
+import "os"
+
+// isIgnored reports whether a folder is marked as ignored; on Windows the
+// mark is an alternate data stream
+func isIgnored(path string) bool {
+	_, err := os.Stat(path + ":" + ignoredAttribute)
+	return err == nil
+}
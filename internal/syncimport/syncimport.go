@@ -0,0 +1,217 @@
+// Package syncimport reads which folders the Dropbox desktop client leaves
+// out of the local Dropbox folder, either by selective sync or because they
+// are marked as ignored, so a backup can leave out the same folders.
+package syncimport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Sources of an Exclusion
+const (
+	// SourceSelectiveSync is a folder not synced to this computer
+	SourceSelectiveSync = "selective sync"
+	// SourceIgnored is a folder marked with the com.dropbox.ignored attribute
+	SourceIgnored = "ignored"
+)
+
+// ignoredAttribute marks files and folders the desktop client doesn't sync
+const ignoredAttribute = "com.dropbox.ignored"
+
+// cacheDir is the desktop client's own folder inside the Dropbox folder
+const cacheDir = ".dropbox.cache"
+
+// Folder is the local Dropbox folder of an account linked to the desktop
+// client
+type Folder struct {
+	// Account is the kind of account, "personal" or "business"
+	Account string `json:"account"`
+	Path    string `json:"path"`
+}
+
+// Exclusion is a Dropbox folder the desktop client leaves out
+type Exclusion struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
+}
+
+// Pattern returns the exclude pattern of the folder, e.g. "/photos/old/";
+// it is lower case, as Dropbox paths are matched in lower case
+func (e Exclusion) Pattern() string {
+	return strings.ToLower(strings.TrimSuffix(e.Path, "/")) + "/"
+}
+
+// infoPaths returns where the desktop client describes the linked accounts
+func infoPaths() []string {
+	var paths []string
+	if runtime.GOOS == "windows" {
+		for _, env := range []string{"APPDATA", "LOCALAPPDATA"} {
+			if dir := os.Getenv(env); dir != "" {
+				paths = append(paths, filepath.Join(dir, "Dropbox", "info.json"))
+			}
+		}
+		return paths
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".dropbox", "info.json"))
+	}
+	return paths
+}
+
+// Folders returns the local Dropbox folders of the accounts linked to the
+// desktop client, personal first
+func Folders() ([]Folder, error) {
+	for _, info := range infoPaths() {
+		data, err := os.ReadFile(info)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Dropbox client info: %w", err)
+		}
+		return parseInfo(data)
+	}
+	return nil, errors.New("no Dropbox desktop client found; pass the Dropbox folder with --dropbox-dir")
+}
+
+// parseInfo parses the info.json of the desktop client
+func parseInfo(data []byte) ([]Folder, error) {
+	var accounts map[string]struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse Dropbox client info: %w", err)
+	}
+
+	var folders []Folder
+	for account, info := range accounts {
+		if info.Path != "" {
+			folders = append(folders, Folder{Account: account, Path: info.Path})
+		}
+	}
+	slices.SortFunc(folders, func(a, b Folder) int {
+		if a.personal() != b.personal() {
+			if a.personal() {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Account, b.Account)
+	})
+	return folders, nil
+}
+
+// personal reports whether the folder is of the personal account
+func (f Folder) personal() bool {
+	return f.Account == "personal"
+}
+
+// Import returns the folders the desktop client leaves out of a local
+// Dropbox folder: those excluded from selective sync, as reported by the
+// dropbox command line tool where installed (Linux), and those marked as
+// ignored. Only folders are checked for the ignored mark, not each file.
+func Import(ctx context.Context, dropboxDir string) ([]Exclusion, error) {
+	if _, err := os.Stat(dropboxDir); err != nil {
+		return nil, fmt.Errorf("failed to open Dropbox folder: %w", err)
+	}
+
+	var exclusions []Exclusion
+	excluded, err := selectiveSync(ctx, dropboxDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range excluded {
+		exclusions = append(exclusions, Exclusion{Path: p, Source: SourceSelectiveSync})
+	}
+
+	ignored, err := ignoredFolders(dropboxDir, isIgnored)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ignored {
+		exclusions = append(exclusions, Exclusion{Path: p, Source: SourceIgnored})
+	}
+	return exclusions, nil
+}
+
+// selectiveSync returns the folders excluded from selective sync, as listed
+// by "dropbox exclude list"; none if the tool isn't installed
+func selectiveSync(ctx context.Context, dropboxDir string) ([]string, error) {
+	tool, err := exec.LookPath("dropbox")
+	if err != nil {
+		return nil, nil
+	}
+
+	// The tool lists folders relative to the working directory
+	cmd := exec.CommandContext(ctx, tool, "exclude", "list")
+	cmd.Dir = dropboxDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list selective sync exclusions: %w", err)
+	}
+	return parseExcludeList(string(out)), nil
+}
+
+// parseExcludeList converts the output of "dropbox exclude list", run in
+// the Dropbox folder, into Dropbox paths
+func parseExcludeList(out string) []string {
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Excluded:") || strings.HasPrefix(line, "No directories") {
+			continue
+		}
+		paths = append(paths, dropboxPath(line))
+	}
+	return paths
+}
+
+// dropboxPath converts a path relative to the Dropbox folder into a Dropbox
+// path
+func dropboxPath(rel string) string {
+	return path.Clean("/" + filepath.ToSlash(rel))
+}
+
+// ignoredFolders returns the folders below a Dropbox folder that isIgnored
+// reports as marked, without descending into them
+func ignoredFolders(dropboxDir string, isIgnored func(string) bool) ([]string, error) {
+	var ignored []string
+	err := filepath.WalkDir(dropboxDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p != dropboxDir && errors.Is(err, fs.ErrPermission) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() || p == dropboxDir {
+			return nil
+		}
+		if d.Name() == cacheDir {
+			return fs.SkipDir
+		}
+		if isIgnored(p) {
+			rel, err := filepath.Rel(dropboxDir, p)
+			if err != nil {
+				return err
+			}
+			ignored = append(ignored, dropboxPath(rel))
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for ignored folders: %w", err)
+	}
+	return ignored, nil
+}
@@ -0,0 +1,78 @@
+package syncimport
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseInfo(t *testing.T) {
+	data := []byte(`{"business": {"path": "/home/me/Dropbox (Acme)", "host": 1}, "personal": {"path": "/home/me/Dropbox"}}`)
+	folders, err := parseInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Folder{{Account: "personal", Path: "/home/me/Dropbox"}, {Account: "business", Path: "/home/me/Dropbox (Acme)"}}
+	if !reflect.DeepEqual(folders, want) {
+		t.Errorf("parseInfo() = %+v, want %+v", folders, want)
+	}
+
+	if _, err := parseInfo([]byte("not json")); err == nil {
+		t.Error("parseInfo() of invalid data succeeded")
+	}
+}
+
+func TestParseExcludeList(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []string
+	}{
+		{name: "folders", out: "Excluded: \nPhotos/Old\nWork Archive\n", want: []string{"/Photos/Old", "/Work Archive"}},
+		{name: "none", out: "No directories are being ignored.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseExcludeList(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseExcludeList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoredFolders(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"code/app/node_modules/lib", "code/app/src", ".dropbox.cache/x", "Photos"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var checked []string
+	isIgnored := func(p string) bool {
+		rel, _ := filepath.Rel(root, p)
+		checked = append(checked, filepath.ToSlash(rel))
+		return filepath.Base(p) == "node_modules"
+	}
+
+	got, err := ignoredFolders(root, isIgnored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/code/app/node_modules"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ignoredFolders() = %q, want %q", got, want)
+	}
+	for _, p := range checked {
+		if p == "code/app/node_modules/lib" || p == ".dropbox.cache" || p == ".dropbox.cache/x" {
+			t.Errorf("ignoredFolders() checked %s", p)
+		}
+	}
+}
+
+func TestExclusionPattern(t *testing.T) {
+	if got := (Exclusion{Path: "/Photos/Old"}).Pattern(); got != "/photos/old/" {
+		t.Errorf("Pattern() = %q, want /photos/old/", got)
+	}
+}
@@ -0,0 +1,108 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxChunk bounds a single read so throttled transfers stay smooth
+const maxChunk = 32 * 1024
+
+// Limiter is a token-bucket bandwidth limiter shared by all readers it wraps.
+// The limit is looked up from the schedule on every read, so changes in the
+// time of day take effect immediately in long-running processes.
+type Limiter struct {
+	mu       sync.Mutex
+	schedule Schedule
+	now      func() time.Time
+	tokens   float64
+	last     time.Time
+}
+
+// NewLimiter creates a limiter that follows the given schedule
+func NewLimiter(schedule Schedule) *Limiter {
+	return &Limiter{
+		schedule: schedule,
+		now:      time.Now,
+	}
+}
+
+// Rate returns the limit currently in effect in bytes per second (0 = unlimited)
+func (l *Limiter) Rate() int64 {
+	return l.schedule.RateAt(l.now())
+}
+
+// Reader wraps r so that reads from it consume the limiter's bandwidth budget
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &limitedReader{ctx: ctx, r: r, limiter: l}
+}
+
+// waitN blocks until n bytes may be transferred
+func (l *Limiter) waitN(ctx context.Context, n int) error {
+	for {
+		l.mu.Lock()
+		now := l.now()
+		rate := float64(l.schedule.RateAt(now))
+		if rate <= 0 {
+			l.tokens = 0
+			l.last = now
+			l.mu.Unlock()
+			return nil
+		}
+
+		if !l.last.IsZero() {
+			l.tokens += now.Sub(l.last).Seconds() * rate
+		}
+		l.last = now
+
+		// Allow at most one second of burst
+		if l.tokens > rate {
+			l.tokens = rate
+		}
+
+		if l.tokens >= float64(n) {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((float64(n) - l.tokens) / rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if rate := lr.limiter.Rate(); rate > 0 {
+		chunk := int64(maxChunk)
+		if rate < chunk {
+			chunk = rate
+		}
+		if int64(len(p)) > chunk {
+			p = p[:chunk]
+		}
+	}
+
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if waitErr := lr.limiter.waitN(lr.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
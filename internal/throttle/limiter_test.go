@@ -0,0 +1,78 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLimiterUnlimited(t *testing.T) {
+	limiter := NewLimiter(Schedule{})
+	data := bytes.Repeat([]byte("x"), 1<<20)
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, limiter.Reader(context.Background(), bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("io.Copy() error = %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("io.Copy() = %d bytes, want %d", n, len(data))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("unlimited copy took %v", elapsed)
+	}
+}
+
+func TestLimiterThrottles(t *testing.T) {
+	// 64 KB/s with a 64 KB burst: copying 128 KB should take about one second
+	limiter := NewLimiter(Schedule{Default: 64 * 1024})
+	limiter.tokens = 64 * 1024
+	limiter.last = time.Now()
+
+	data := bytes.Repeat([]byte("x"), 128*1024)
+
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, limiter.Reader(context.Background(), bytes.NewReader(data))); err != nil {
+		t.Fatalf("io.Copy() error = %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 800*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("throttled copy took %v, want about 1s", elapsed)
+	}
+}
+
+func TestLimiterFollowsScheduleLive(t *testing.T) {
+	current := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	schedule, err := ParseSchedule("", "09:00-18:00=1K")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiter := NewLimiter(schedule)
+	limiter.now = func() time.Time { return current }
+
+	if got := limiter.Rate(); got != 1024 {
+		t.Errorf("Rate() during window = %d, want 1024", got)
+	}
+
+	current = current.Add(7 * time.Hour)
+	if got := limiter.Rate(); got != 0 {
+		t.Errorf("Rate() after window = %d, want 0", got)
+	}
+}
+
+func TestLimiterHonorsCancellation(t *testing.T) {
+	limiter := NewLimiter(Schedule{Default: 1})
+	limiter.last = time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := io.Copy(io.Discard, limiter.Reader(ctx, bytes.NewReader([]byte("hello"))))
+	if err == nil {
+		t.Error("expected error from cancelled context")
+	}
+}
@@ -0,0 +1,169 @@
+package throttle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window applies a bandwidth limit between two times of day. Windows whose
+// end is before their start wrap around midnight (e.g. 22:00-06:00).
+type Window struct {
+	Start time.Duration // offset from midnight
+	End   time.Duration // offset from midnight
+	Rate  int64         // bytes per second, 0 means unlimited
+}
+
+// Schedule maps the time of day to a bandwidth limit
+type Schedule struct {
+	Default int64 // bytes per second outside all windows, 0 means unlimited
+	Windows []Window
+}
+
+// RateAt returns the bandwidth limit in effect at the given time
+func (s Schedule) RateAt(t time.Time) int64 {
+	offset := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	for _, w := range s.Windows {
+		if w.contains(offset) {
+			return w.Rate
+		}
+	}
+	return s.Default
+}
+
+// IsUnlimited reports whether the schedule never limits bandwidth
+func (s Schedule) IsUnlimited() bool {
+	if s.Default > 0 {
+		return false
+	}
+	for _, w := range s.Windows {
+		if w.Rate > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (w Window) contains(offset time.Duration) bool {
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// ParseSchedule builds a schedule from a default limit (as accepted by
+// ParseRate) and a comma-separated list of windows such as
+// "09:00-18:00=2M,22:00-06:00=0"
+func ParseSchedule(defaultRate, windows string) (Schedule, error) {
+	var schedule Schedule
+
+	rate, err := ParseRate(defaultRate)
+	if err != nil {
+		return Schedule{}, err
+	}
+	schedule.Default = rate
+
+	for _, entry := range strings.Split(windows, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		window, err := parseWindow(entry)
+		if err != nil {
+			return Schedule{}, err
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+
+	return schedule, nil
+}
+
+func parseWindow(entry string) (Window, error) {
+	span, rateText, found := strings.Cut(entry, "=")
+	if !found {
+		return Window{}, fmt.Errorf("invalid bandwidth window %q (expected HH:MM-HH:MM=RATE)", entry)
+	}
+
+	startText, endText, found := strings.Cut(strings.TrimSpace(span), "-")
+	if !found {
+		return Window{}, fmt.Errorf("invalid bandwidth window %q (expected HH:MM-HH:MM=RATE)", entry)
+	}
+
+	start, err := parseTimeOfDay(startText)
+	if err != nil {
+		return Window{}, err
+	}
+	end, err := parseTimeOfDay(endText)
+	if err != nil {
+		return Window{}, err
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("bandwidth window %q is empty", entry)
+	}
+
+	rate, err := ParseRate(rateText)
+	if err != nil {
+		return Window{}, err
+	}
+
+	return Window{Start: start, End: end, Rate: rate}, nil
+}
+
+func parseTimeOfDay(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	hourText, minuteText, found := strings.Cut(text, ":")
+	if !found {
+		minuteText = "0"
+	}
+
+	hour, err := strconv.Atoi(hourText)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid time of day %q", text)
+	}
+	minute, err := strconv.Atoi(minuteText)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time of day %q", text)
+	}
+
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// ParseRate parses a bandwidth such as "512K", "2M" or "1.5G" into bytes per
+// second. Suffixes are binary (1K = 1024 bytes). Empty, "0" and "off" mean
+// unlimited.
+func ParseRate(text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	switch strings.ToLower(text) {
+	case "", "0", "off", "unlimited":
+		return 0, nil
+	}
+
+	multiplier := float64(1)
+	number := strings.TrimSuffix(strings.TrimSuffix(text, "B"), "b")
+	if number == "" {
+		return 0, fmt.Errorf("invalid bandwidth %q (e.g. 512K, 2M, 1G)", text)
+	}
+	switch suffix := strings.ToUpper(number[len(number)-1:]); suffix {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		number = number[:len(number)-1]
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q (e.g. 512K, 2M, 1G)", text)
+	}
+
+	return int64(value * multiplier), nil
+}
@@ -0,0 +1,110 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"off", 0, false},
+		{"1024", 1024, false},
+		{"512K", 512 * 1024, false},
+		{"2M", 2 * 1024 * 1024, false},
+		{"2MB", 2 * 1024 * 1024, false},
+		{"1.5G", 1536 * 1024 * 1024, false},
+		{"abc", 0, true},
+		{"-1M", 0, true},
+		{"B", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultRate string
+		windows     string
+		wantWindows int
+		wantErr     bool
+	}{
+		{"no limits", "", "", 0, false},
+		{"default only", "4M", "", 0, false},
+		{"single window", "", "09:00-18:00=2M", 1, false},
+		{"multiple windows", "0", "09:00-12:00=1M, 12:00-18:00=2M", 2, false},
+		{"missing rate", "", "09:00-18:00", 0, true},
+		{"missing end", "", "09:00=2M", 0, true},
+		{"invalid time", "", "25:00-18:00=2M", 0, true},
+		{"empty window", "", "09:00-09:00=2M", 0, true},
+		{"invalid default", "fast", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchedule(tt.defaultRate, tt.windows)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got.Windows) != tt.wantWindows {
+				t.Errorf("ParseSchedule() windows = %d, want %d", len(got.Windows), tt.wantWindows)
+			}
+		})
+	}
+}
+
+func TestScheduleRateAt(t *testing.T) {
+	schedule, err := ParseSchedule("", "09:00-18:00=2M,22:00-06:00=512K")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want int64
+	}{
+		{"before work hours", day(8, 59), 0},
+		{"start of work hours", day(9, 0), 2 * 1024 * 1024},
+		{"during work hours", day(13, 30), 2 * 1024 * 1024},
+		{"end of work hours", day(18, 0), 0},
+		{"late night", day(23, 0), 512 * 1024},
+		{"after midnight", day(2, 0), 512 * 1024},
+		{"morning", day(6, 0), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.RateAt(tt.at); got != tt.want {
+				t.Errorf("RateAt(%v) = %d, want %d", tt.at, got, tt.want)
+			}
+		})
+	}
+
+	if schedule.IsUnlimited() {
+		t.Error("IsUnlimited() = true for schedule with limits")
+	}
+	if !(Schedule{}).IsUnlimited() {
+		t.Error("IsUnlimited() = false for empty schedule")
+	}
+}
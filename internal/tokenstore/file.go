@@ -0,0 +1,94 @@
+package tokenstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the token file in the configuration directory
+const FileName = "tokens.json"
+
+// fileBackend keeps the tokens of all app keys in a JSON file readable by
+// its owner only
+type fileBackend struct {
+	path string
+}
+
+func newFileBackend(configDir string) *fileBackend {
+	return &fileBackend{path: filepath.Join(configDir, FileName)}
+}
+
+func (f *fileBackend) name() string {
+	return f.path
+}
+
+func (f *fileBackend) get(appKey string) (string, error) {
+	secrets, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[appKey]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return string(secret), nil
+}
+
+func (f *fileBackend) set(appKey, secret string) error {
+	secrets, err := f.read()
+	if err != nil {
+		return err
+	}
+	secrets[appKey] = json.RawMessage(secret)
+	return f.write(secrets)
+}
+
+func (f *fileBackend) remove(appKey string) error {
+	secrets, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[appKey]; !ok {
+		return nil
+	}
+	delete(secrets, appKey)
+	return f.write(secrets)
+}
+
+// read returns the tokens by app key; none if there is no file yet
+func (f *fileBackend) read() (map[string]json.RawMessage, error) {
+	secrets := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", f.path, err)
+	}
+	return secrets, nil
+}
+
+// write replaces the file, which is created readable by its owner only
+func (f *fileBackend) write(secrets map[string]json.RawMessage) error {
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package tokenstore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// command is a program run with arguments and input
+type command struct {
+	name  string
+	args  []string
+	stdin string
+}
+
+// keychain keeps secrets in the keychain of the OS through its command line
+// tool: security on macOS, secret-tool (libsecret) on Linux and BSD and
+// PowerShell's PasswordVault on Windows
+type keychain struct {
+	goos string
+	// notFound is the exit code of a lookup that found nothing
+	notFound int
+	// run runs a command, replaced in tests
+	run func(command) ([]byte, error)
+}
+
+// keychainTools names the tool each OS needs
+var keychainTools = map[string]string{
+	"darwin":  "security",
+	"linux":   "secret-tool",
+	"freebsd": "secret-tool",
+	"openbsd": "secret-tool",
+	"netbsd":  "secret-tool",
+	"windows": "powershell",
+}
+
+// newKeychain returns the keychain of an OS if its tool is installed
+func newKeychain(goos string) (*keychain, bool) {
+	tool, ok := keychainTools[goos]
+	if !ok {
+		return nil, false
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, false
+	}
+	return &keychain{goos: goos, notFound: notFoundCode(goos), run: runCommand}, true
+}
+
+// notFoundCode returns the exit code of a lookup that found nothing
+func notFoundCode(goos string) int {
+	switch goos {
+	case "darwin":
+		return 44
+	case "windows":
+		return vaultNotFound
+	}
+	return 1
+}
+
+func (k *keychain) name() string {
+	switch k.goos {
+	case "darwin":
+		return "macOS Keychain"
+	case "windows":
+		return "Windows Credential Manager"
+	}
+	return "Secret Service keyring"
+}
+
+func (k *keychain) get(appKey string) (string, error) {
+	out, err := k.run(k.getCommand(appKey))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == k.notFound {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read tokens from the %s: %w", k.name(), err)
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (k *keychain) set(appKey, secret string) error {
+	if _, err := k.run(k.setCommand(appKey, secret)); err != nil {
+		return fmt.Errorf("failed to save tokens in the %s: %w", k.name(), err)
+	}
+	return nil
+}
+
+func (k *keychain) remove(appKey string) error {
+	_, err := k.run(k.removeCommand(appKey))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == k.notFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove tokens from the %s: %w", k.name(), err)
+	}
+	return nil
+}
+
+// vaultNotFound is the exit code of the PowerShell scripts when the vault
+// holds no tokens for the app key
+const vaultNotFound = 2
+
+// vaultScript loads the PasswordVault, which stores its credentials in the
+// Windows Credential Manager
+const vaultScript = "[Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime] > $null; " +
+	"$vault = New-Object Windows.Security.Credentials.PasswordVault; "
+
+// getCommand prints the secret of an app key
+func (k *keychain) getCommand(appKey string) command {
+	switch k.goos {
+	case "darwin":
+		return command{name: "security", args: []string{"find-generic-password", "-s", Service, "-a", appKey, "-w"}}
+	case "windows":
+		script := vaultScript +
+			fmt.Sprintf("try { $c = $vault.Retrieve(%s, %s) } catch { exit %d }; ", powerShellString(Service), powerShellString(appKey), vaultNotFound) +
+			"$c.RetrievePassword(); [Console]::Out.Write($c.Password)"
+		return powerShell(script, "")
+	}
+	return command{name: "secret-tool", args: []string{"lookup", "service", Service, "account", appKey}}
+}
+
+// setCommand stores the secret of an app key, replacing an earlier one. The
+// secret is passed on standard input, as arguments are visible to other
+// users in the process list.
+func (k *keychain) setCommand(appKey, secret string) command {
+	switch k.goos {
+	case "darwin":
+		// security -i reads commands from standard input; the secret is hex
+		// encoded so it needs no quoting
+		line := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", Service, appKey, hex.EncodeToString([]byte(secret)))
+		return command{name: "security", args: []string{"-i"}, stdin: line}
+	case "windows":
+		script := vaultScript +
+			fmt.Sprintf("try { $vault.Remove($vault.Retrieve(%s, %s)) } catch { }; ", powerShellString(Service), powerShellString(appKey)) +
+			fmt.Sprintf("$vault.Add((New-Object Windows.Security.Credentials.PasswordCredential(%s, %s, [Console]::In.ReadToEnd())))", powerShellString(Service), powerShellString(appKey))
+		return powerShell(script, secret)
+	}
+	return command{
+		name:  "secret-tool",
+		args:  []string{"store", "--label", "Dropbox backup tokens (" + appKey + ")", "service", Service, "account", appKey},
+		stdin: secret,
+	}
+}
+
+// removeCommand deletes the secret of an app key
+func (k *keychain) removeCommand(appKey string) command {
+	switch k.goos {
+	case "darwin":
+		return command{name: "security", args: []string{"delete-generic-password", "-s", Service, "-a", appKey}}
+	case "windows":
+		script := vaultScript +
+			fmt.Sprintf("try { $vault.Remove($vault.Retrieve(%s, %s)) } catch { }", powerShellString(Service), powerShellString(appKey))
+		return powerShell(script, "")
+	}
+	return command{name: "secret-tool", args: []string{"clear", "service", Service, "account", appKey}}
+}
+
+// powerShell returns the command running a PowerShell script
+func powerShell(script, stdin string) command {
+	return command{name: "powershell", args: []string{"-NoProfile", "-NonInteractive", "-Command", script}, stdin: stdin}
+}
+
+// powerShellString quotes s as a PowerShell string literal, in which nothing
+// is expanded
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// runCommand runs a command, returning its output and its error output in
+// errors
+func runCommand(c command) ([]byte, error) {
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stdin = strings.NewReader(c.stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", c.name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	return out, nil
+}
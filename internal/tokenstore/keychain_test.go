@@ -0,0 +1,114 @@
+package tokenstore
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestKeychainCommands(t *testing.T) {
+	tests := []struct {
+		goos      string
+		tool      string
+		getArgs   string
+		setArgs   string
+		setStdin  string
+		clearArgs string
+	}{
+		{
+			goos:      "darwin",
+			tool:      "security",
+			getArgs:   "find-generic-password -s " + Service + " -a key -w",
+			setArgs:   "-i",
+			setStdin:  "add-generic-password -U -s " + Service + " -a key -X 736563726574\n",
+			clearArgs: "delete-generic-password -s " + Service + " -a key",
+		},
+		{
+			goos:      "linux",
+			tool:      "secret-tool",
+			getArgs:   "lookup service " + Service + " account key",
+			setArgs:   "store --label Dropbox backup tokens (key) service " + Service + " account key",
+			setStdin:  "secret",
+			clearArgs: "clear service " + Service + " account key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			k := &keychain{goos: tt.goos}
+			get := k.getCommand("key")
+			if get.name != tt.tool || strings.Join(get.args, " ") != tt.getArgs {
+				t.Errorf("getCommand = %s %v, want %s %s", get.name, get.args, tt.tool, tt.getArgs)
+			}
+			set := k.setCommand("key", "secret")
+			if set.name != tt.tool || strings.Join(set.args, " ") != tt.setArgs || set.stdin != tt.setStdin {
+				t.Errorf("setCommand = %s %v %q, want %s %s %q", set.name, set.args, set.stdin, tt.tool, tt.setArgs, tt.setStdin)
+			}
+			clear := k.removeCommand("key")
+			if clear.name != tt.tool || strings.Join(clear.args, " ") != tt.clearArgs {
+				t.Errorf("removeCommand = %s %v, want %s %s", clear.name, clear.args, tt.tool, tt.clearArgs)
+			}
+		})
+	}
+}
+
+func TestKeychainWindowsCommands(t *testing.T) {
+	k := &keychain{goos: "windows"}
+	set := k.setCommand("it's", "secret")
+	if set.name != "powershell" {
+		t.Fatalf("setCommand runs %s, want powershell", set.name)
+	}
+	script := set.args[len(set.args)-1]
+	if !strings.Contains(script, "'it''s'") {
+		t.Errorf("setCommand does not quote the app key: %s", script)
+	}
+	if strings.Contains(script, "secret") || set.stdin != "secret" {
+		t.Errorf("setCommand must pass the secret on standard input: %s", script)
+	}
+	if get := k.getCommand("key"); !strings.Contains(get.args[len(get.args)-1], "exit 2") {
+		t.Errorf("getCommand does not signal missing tokens: %v", get.args)
+	}
+}
+
+func TestKeychainGet(t *testing.T) {
+	// A real exit status of 1 is needed for the not found mapping
+	notFound := exec.Command("sh", "-c", "exit 1").Run()
+	if notFound == nil {
+		t.Skip("sh unavailable")
+	}
+
+	tests := []struct {
+		name    string
+		out     string
+		err     error
+		want    string
+		wantErr error
+	}{
+		{name: "found", out: "secret\n", want: "secret"},
+		{name: "not found", err: notFound, wantErr: ErrNotFound},
+		{name: "empty", out: "", wantErr: ErrNotFound},
+		{name: "failure", err: errors.New("no dbus")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &keychain{goos: "linux", notFound: 1, run: func(command) ([]byte, error) {
+				return []byte(tt.out), tt.err
+			}}
+			got, err := k.get("key")
+			if tt.err != nil && tt.wantErr == nil {
+				if err == nil || errors.Is(err, ErrNotFound) {
+					t.Errorf("get error = %v, want a failure", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("get error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("get = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package tokenstore keeps the OAuth tokens of the Dropbox app out of
+// environment files: in the keychain of the OS (macOS Keychain, Windows
+// Credential Manager or a Secret Service such as GNOME Keyring), or in a file
+// readable by its owner only where no keychain is available.
+package tokenstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+
+	"golang.org/x/oauth2"
+)
+
+// Service names the tokens in the keychain; the account is the app key
+const Service = "create-dropbox-backup-folder"
+
+// Where tokens are stored, as selected with DROPBOX_TOKEN_STORE
+const (
+	// ModeAuto uses the keychain if available, else the file
+	ModeAuto = "auto"
+	// ModeKeychain requires the keychain
+	ModeKeychain = "keychain"
+	// ModeFile always uses the file
+	ModeFile = "file"
+)
+
+// Modes lists the valid modes
+var Modes = []string{ModeAuto, ModeKeychain, ModeFile}
+
+// ErrNotFound is returned by Load when no tokens are stored for an app key
+var ErrNotFound = errors.New("no stored tokens")
+
+// backend keeps a secret per app key
+type backend interface {
+	name() string
+	get(appKey string) (string, error)
+	set(appKey, secret string) error
+	remove(appKey string) error
+}
+
+// Store loads and saves the tokens of app keys
+type Store struct {
+	// keychain is nil if not used
+	keychain backend
+	file     backend
+	// fallback lets a failing keychain fall back to the file
+	fallback bool
+}
+
+// Open returns the store of a mode, keeping its file in configDir
+func Open(mode, configDir string) (*Store, error) {
+	file := newFileBackend(configDir)
+	switch mode {
+	case ModeFile:
+		return &Store{file: file}, nil
+	case ModeKeychain:
+		keychain, ok := newKeychain(runtime.GOOS)
+		if !ok {
+			return nil, fmt.Errorf("no keychain available on this system (see DROPBOX_TOKEN_STORE)")
+		}
+		return &Store{keychain: keychain, file: file}, nil
+	case ModeAuto, "":
+		store := &Store{file: file, fallback: true}
+		if keychain, ok := newKeychain(runtime.GOOS); ok {
+			store.keychain = keychain
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("invalid token store: %s (must be one of %v)", mode, Modes)
+}
+
+// Load returns the tokens stored for an app key, or ErrNotFound
+func (s *Store) Load(appKey string) (*oauth2.Token, error) {
+	if s.keychain != nil {
+		secret, err := s.keychain.get(appKey)
+		switch {
+		case err == nil:
+			return decode(secret, s.keychain.name())
+		case !s.fallback:
+			return nil, err
+		case !errors.Is(err, ErrNotFound):
+			slog.Warn("Failed to read tokens from the keychain, trying the token file", slog.String("error", err.Error()))
+		}
+	}
+
+	secret, err := s.file.get(appKey)
+	if err != nil {
+		return nil, err
+	}
+	return decode(secret, s.file.name())
+}
+
+// Save stores the tokens of an app key and returns where they went
+func (s *Store) Save(appKey string, token *oauth2.Token) (string, error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	if s.keychain != nil {
+		err := s.keychain.set(appKey, string(data))
+		if err == nil {
+			// Don't leave older tokens behind in the file
+			if err := s.file.remove(appKey); err != nil {
+				slog.Warn("Failed to remove tokens from the token file", slog.String("error", err.Error()))
+			}
+			return s.keychain.name(), nil
+		}
+		if !s.fallback {
+			return "", err
+		}
+		slog.Warn("Failed to save tokens in the keychain, using the token file", slog.String("error", err.Error()))
+	}
+
+	if err := s.file.set(appKey, string(data)); err != nil {
+		return "", err
+	}
+	return s.file.name(), nil
+}
+
+// Delete removes the tokens of an app key from the keychain and the file
+func (s *Store) Delete(appKey string) error {
+	if s.keychain != nil {
+		if err := s.keychain.remove(appKey); err != nil {
+			return err
+		}
+	}
+	return s.file.remove(appKey)
+}
+
+// decode parses stored tokens
+func decode(secret, from string) (*oauth2.Token, error) {
+	var token oauth2.Token
+	if err := json.Unmarshal([]byte(secret), &token); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens from %s: %w", from, err)
+	}
+	return &token, nil
+}
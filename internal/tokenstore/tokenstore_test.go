@@ -0,0 +1,194 @@
+package tokenstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeBackend keeps secrets in memory, failing every call if err is set
+type fakeBackend struct {
+	secrets map[string]string
+	err     error
+}
+
+func (f *fakeBackend) name() string { return "fake keychain" }
+
+func (f *fakeBackend) get(appKey string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	secret, ok := f.secrets[appKey]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (f *fakeBackend) set(appKey, secret string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.secrets[appKey] = secret
+	return nil
+}
+
+func (f *fakeBackend) remove(appKey string) error {
+	if f.err != nil {
+		return f.err
+	}
+	delete(f.secrets, appKey)
+	return nil
+}
+
+func testToken() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenType:    "bearer",
+		Expiry:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(ModeFile, dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if _, err := store.Load("key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load of an empty store = %v, want ErrNotFound", err)
+	}
+
+	where, err := store.Save("key", testToken())
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	path := filepath.Join(dir, FileName)
+	if where != path {
+		t.Errorf("Save stored in %q, want %q", where, path)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Errorf("token file mode = %o, want 600", mode)
+		}
+	}
+
+	token, err := store.Load("key")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := testToken()
+	if token.AccessToken != want.AccessToken || token.RefreshToken != want.RefreshToken || !token.Expiry.Equal(want.Expiry) {
+		t.Errorf("Load = %+v, want %+v", token, want)
+	}
+	if _, err := store.Load("other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of another key = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Load("key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreKeychain(t *testing.T) {
+	dir := t.TempDir()
+	keychain := &fakeBackend{secrets: make(map[string]string)}
+	store := &Store{keychain: keychain, file: newFileBackend(dir), fallback: true}
+
+	// Tokens left in the file by an earlier run move to the keychain
+	if err := store.file.set("key", `{"access_token":"old"}`); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	where, err := store.Save("key", testToken())
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if where != "fake keychain" {
+		t.Errorf("Save stored in %q, want the keychain", where)
+	}
+	if _, err := store.file.get("key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("token file still holds the tokens: %v", err)
+	}
+
+	token, err := store.Load("key")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if token.AccessToken != "access" {
+		t.Errorf("Load access token = %q, want access", token.AccessToken)
+	}
+}
+
+func TestStoreFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback bool
+		wantErr  bool
+	}{
+		{name: "auto falls back to the file", fallback: true},
+		{name: "keychain fails", fallback: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keychain := &fakeBackend{err: errors.New("locked")}
+			store := &Store{keychain: keychain, file: newFileBackend(dir), fallback: tt.fallback}
+
+			where, err := store.Save("key", testToken())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Save error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := store.Load("key"); err == nil {
+					t.Error("Load succeeded without a keychain")
+				}
+				return
+			}
+			if where != filepath.Join(dir, FileName) {
+				t.Errorf("Save stored in %q, want the token file", where)
+			}
+			token, err := store.Load("key")
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if token.RefreshToken != "refresh" {
+				t.Errorf("Load refresh token = %q, want refresh", token.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestOpenInvalidMode(t *testing.T) {
+	if _, err := Open("vault", t.TempDir()); err == nil {
+		t.Error("Open accepted an invalid mode")
+	}
+}
+
+func TestCorruptTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("not json"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	store, err := Open(ModeFile, dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := store.Load("key"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of a corrupt file = %v, want a parse error", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package transfer
+
+import (
+	"path/filepath"
+	"strings"
+
+	"create-dropbox-backup-folder/internal/localfs"
+)
+
+// deviceKey identifies the device a file is stored on by its volume name,
+// e.g. "c:" on Windows
+func deviceKey(f localfs.File) string {
+	return strings.ToLower(filepath.VolumeName(f.Name()))
+}
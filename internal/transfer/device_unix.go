@@ -0,0 +1,22 @@
+//go:build unix
+
+package transfer
+
+import (
+	"fmt"
+	"syscall"
+
+	"create-dropbox-backup-folder/internal/localfs"
+)
+
+// deviceKey identifies the device a file is stored on
+func deviceKey(f localfs.File) string {
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprint(stat.Dev)
+	}
+	return ""
+}
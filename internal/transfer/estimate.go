@@ -0,0 +1,168 @@
+package transfer
+
+import (
+	"cmp"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// estimateWarmup is how long a run transfers before its own throughput is
+// trusted over the throughput of earlier runs
+const estimateWarmup = 30 * time.Second
+
+// Estimate predicts how long the remaining transfers of a run take. Until the
+// run has transferred for estimateWarmup it uses the throughput of earlier
+// runs, so there is an estimate right from the start. It also follows the
+// running transfers byte by byte, see Start. It is safe for concurrent use.
+type Estimate struct {
+	mu         sync.Mutex
+	total      uint64
+	done       uint64
+	files      int
+	doneFiles  int
+	active     map[*Transfer]struct{}
+	historical float64
+	start      time.Time
+	now        func() time.Time
+}
+
+// EstimateStatus is a snapshot of an Estimate. DoneBytes includes the bytes
+// received by running transfers. BytesPerSecond and Remaining are zero while
+// the throughput is unknown.
+type EstimateStatus struct {
+	TotalBytes     uint64        `json:"total_bytes"`
+	DoneBytes      uint64        `json:"done_bytes"`
+	TotalFiles     int           `json:"total_files"`
+	DoneFiles      int           `json:"done_files"`
+	BytesPerSecond float64       `json:"bytes_per_second"`
+	Remaining      time.Duration `json:"remaining_ns"`
+	// Active lists the running transfers, largest first
+	Active []TransferStatus `json:"active,omitempty"`
+}
+
+// TransferStatus is the progress of a running transfer
+type TransferStatus struct {
+	Name     string `json:"name"`
+	Size     uint64 `json:"size"`
+	Received uint64 `json:"received"`
+}
+
+// NewEstimate starts estimating the transfer of files totalling total bytes;
+// historical is the throughput of earlier runs in bytes per second, 0 if
+// unknown
+func NewEstimate(total uint64, files int, historical float64) *Estimate {
+	return &Estimate{
+		total:      total,
+		files:      files,
+		active:     make(map[*Transfer]struct{}),
+		historical: historical,
+		start:      time.Now(),
+		now:        time.Now,
+	}
+}
+
+// Done records a file of n bytes transferred
+func (e *Estimate) Done(n uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.done += n
+	e.doneFiles++
+}
+
+// Skip records a file of n bytes that turned out not to need a transfer,
+// e.g. because it was up to date or failed
+func (e *Estimate) Skip(n uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.total -= min(n, e.total-e.done)
+	e.doneFiles++
+}
+
+// Transfer counts the bytes received by a running transfer, see Start
+type Transfer struct {
+	name     string
+	size     uint64
+	received atomic.Uint64
+}
+
+// Start follows the transfer of a file of size bytes, of which offset were
+// received before, e.g. by an interrupted download, until Finish is called
+func (e *Estimate) Start(name string, size uint64, offset int64) *Transfer {
+	t := &Transfer{name: name, size: size}
+	t.received.Store(uint64(offset))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active[t] = struct{}{}
+	return t
+}
+
+// Finish stops following a transfer; Done or Skip records its file
+func (e *Estimate) Finish(t *Transfer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.active, t)
+}
+
+// Reader counts the bytes read from r as received by the transfer
+func (t *Transfer) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, t: t}
+}
+
+// countingReader counts the bytes read for a Transfer
+type countingReader struct {
+	r io.Reader
+	t *Transfer
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.t.received.Add(uint64(n))
+	return n, err
+}
+
+// Status returns the current estimate
+func (e *Estimate) Status() EstimateStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := EstimateStatus{
+		TotalBytes:     e.total,
+		DoneBytes:      e.done,
+		TotalFiles:     e.files,
+		DoneFiles:      e.doneFiles,
+		BytesPerSecond: e.historical,
+	}
+	for t := range e.active {
+		transfer := TransferStatus{Name: t.name, Size: t.size, Received: min(t.received.Load(), t.size)}
+		status.Active = append(status.Active, transfer)
+		status.DoneBytes += transfer.Received
+	}
+	status.DoneBytes = min(status.DoneBytes, status.TotalBytes)
+	slices.SortFunc(status.Active, func(a, b TransferStatus) int {
+		if a.Size != b.Size {
+			return cmp.Compare(b.Size, a.Size)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	elapsed := e.now().Sub(e.start)
+	if status.DoneBytes > 0 && elapsed > 0 && (elapsed >= estimateWarmup || e.historical <= 0) {
+		status.BytesPerSecond = float64(status.DoneBytes) / elapsed.Seconds()
+	}
+	if status.BytesPerSecond > 0 {
+		seconds := float64(status.TotalBytes-status.DoneBytes) / status.BytesPerSecond
+		status.Remaining = time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	}
+	return status
+}
+
+// Remaining returns the estimated time the transfers still take, and false
+// while the throughput is unknown
+func (e *Estimate) Remaining() (time.Duration, bool) {
+	status := e.Status()
+	return status.Remaining, status.BytesPerSecond > 0
+}
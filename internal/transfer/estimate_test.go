@@ -0,0 +1,80 @@
+package transfer
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		historical    float64
+		elapsed       time.Duration
+		done, skipped uint64
+		want          time.Duration
+		wantOK        bool
+	}{
+		{name: "history at start", historical: 100, want: 10 * time.Second, wantOK: true},
+		{name: "history during warmup", historical: 100, elapsed: 10 * time.Second, done: 500, want: 5 * time.Second, wantOK: true},
+		{name: "observed after warmup", historical: 100, elapsed: 40 * time.Second, done: 800, want: 10 * time.Second, wantOK: true},
+		{name: "observed without history", elapsed: 5 * time.Second, done: 500, want: 5 * time.Second, wantOK: true},
+		{name: "unknown at start without history"},
+		{name: "skipped bytes", historical: 100, skipped: 600, want: 4 * time.Second, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := NewEstimate(1000, 1, tt.historical)
+			estimate.start = start
+			estimate.now = func() time.Time { return start.Add(tt.elapsed) }
+			estimate.Done(tt.done)
+			estimate.Skip(tt.skipped)
+
+			got, ok := estimate.Remaining()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Remaining() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEstimateSkipKeepsDoneBytes(t *testing.T) {
+	estimate := NewEstimate(100, 2, 10)
+	estimate.Done(80)
+	estimate.Skip(50)
+
+	status := estimate.Status()
+	if status.TotalBytes != 80 || status.Remaining != 0 {
+		t.Errorf("Status() = %+v, want 80 total bytes and nothing remaining", status)
+	}
+}
+
+func TestEstimateTransfers(t *testing.T) {
+	estimate := NewEstimate(1000, 3, 0)
+	small := estimate.Start("/small.txt", 100, 0)
+	large := estimate.Start("/large.bin", 800, 200)
+
+	if _, err := io.Copy(io.Discard, large.Reader(strings.NewReader(strings.Repeat("x", 300)))); err != nil {
+		t.Fatal(err)
+	}
+	status := estimate.Status()
+	if status.DoneBytes != 500 || status.TotalFiles != 3 || status.DoneFiles != 0 {
+		t.Errorf("Status() = %+v, want 500 bytes received and no file done", status)
+	}
+	if len(status.Active) != 2 || status.Active[0].Name != "/large.bin" || status.Active[0].Received != 500 {
+		t.Errorf("Status().Active = %+v, want the large transfer with 500 bytes first", status.Active)
+	}
+
+	estimate.Finish(large)
+	estimate.Done(800)
+	estimate.Finish(small)
+	estimate.Skip(100)
+	status = estimate.Status()
+	if status.DoneBytes != 800 || status.TotalBytes != 900 || status.DoneFiles != 2 || len(status.Active) != 0 {
+		t.Errorf("Status() = %+v, want 800 of 900 bytes and 2 files done", status)
+	}
+}
@@ -0,0 +1,330 @@
+// Package transfer runs the file transfers of a backup or restore: it bounds
+// concurrency, shares one bandwidth limit, pauses for the network monitor,
+// waits out network outages and reports progress.
+package transfer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/netwatch"
+	"create-dropbox-backup-folder/internal/throttle"
+)
+
+// Options configure an Executor
+type Options struct {
+	Concurrency int
+	Bandwidth   throttle.Schedule
+
+	// LaneConcurrency caps the concurrency of lanes (see Executor.Lane); 0
+	// leaves it as requested
+	LaneConcurrency int
+
+	// MaxQueued bounds the transfers started and waiting for a slot, so a
+	// long list doesn't hold a goroutine per item; 0 is unbounded
+	MaxQueued int
+
+	Network       netwatch.Options
+	OutageTimeout time.Duration
+
+	// Progress receives a line per finished transfer; nil disables it
+	Progress io.Writer
+
+	// WriteBuffer is the size of the local file write buffer (default
+	// DefaultWriteBuffer); SerializeWrites writes one buffer at a time per
+	// device, see FileWriter
+	WriteBuffer     int
+	SerializeWrites bool
+}
+
+// Executor runs transfers with the configured limits
+type Executor struct {
+	semaphore chan struct{}
+	queue     chan struct{}
+	limiter   *throttle.Limiter
+	network   *netwatch.Monitor
+	outage    *netwatch.OutageGuard
+	progress  io.Writer
+	estimate  *Estimate
+
+	writeBuffer     int
+	serializeWrites bool
+	devices         *devices
+	laneLimit       int
+}
+
+// New creates an executor
+func New(opts Options) *Executor {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var limiter *throttle.Limiter
+	if !opts.Bandwidth.IsUnlimited() {
+		limiter = throttle.NewLimiter(opts.Bandwidth)
+	}
+
+	writeBuffer := opts.WriteBuffer
+	if writeBuffer < 1 {
+		writeBuffer = DefaultWriteBuffer
+	}
+
+	var queue chan struct{}
+	if opts.MaxQueued > 0 {
+		queue = make(chan struct{}, max(opts.MaxQueued, concurrency))
+	}
+
+	return &Executor{
+		semaphore:       make(chan struct{}, concurrency),
+		queue:           queue,
+		limiter:         limiter,
+		network:         netwatch.New(opts.Network),
+		outage:          netwatch.NewOutageGuard(opts.OutageTimeout),
+		progress:        opts.Progress,
+		writeBuffer:     writeBuffer,
+		serializeWrites: opts.SerializeWrites,
+		devices:         &devices{},
+		laneLimit:       opts.LaneConcurrency,
+	}
+}
+
+// Lane returns an executor that shares the bandwidth limit, network watch,
+// queue, write settings and progress output of x but runs at most concurrency
+// transfers of its own, e.g. to keep a few large files from occupying every
+// slot of x
+func (x *Executor) Lane(concurrency int) *Executor {
+	if x.laneLimit > 0 {
+		concurrency = min(concurrency, x.laneLimit)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	lane := *x
+	lane.semaphore = make(chan struct{}, concurrency)
+	return &lane
+}
+
+// Concurrency returns the number of transfers x runs at once
+func (x *Executor) Concurrency() int {
+	return cap(x.semaphore)
+}
+
+// Start begins watching the network so transfers pause when required
+func (x *Executor) Start(ctx context.Context) {
+	x.network.Start(ctx)
+}
+
+// Reader wraps a transfer body with the network monitor and bandwidth limiter
+func (x *Executor) Reader(ctx context.Context, r io.Reader) io.Reader {
+	r = x.network.Reader(ctx, r)
+	if x.limiter != nil {
+		r = x.limiter.Reader(ctx, r)
+	}
+	return r
+}
+
+// Run calls fn for every item, at most Concurrency at a time. A call that
+// fails because of a network outage is retried once connectivity returns,
+// and a call that panics fails with a *PanicError while the others go on.
+// Run waits for all calls and returns the first error; name labels items in
+// progress output and logs.
+func Run[T any](ctx context.Context, x *Executor, items []T, name func(T) string, fn func(context.Context, T) error) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(items))
+
+	var mu sync.Mutex
+	done := 0
+
+	for _, item := range items {
+		// Wait for room in the queue before starting another transfer
+		if x.queue != nil {
+			select {
+			case x.queue <- struct{}{}:
+			case <-ctx.Done():
+				errChan <- ctx.Err()
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		wg.Add(1)
+		go func(item T) {
+			defer wg.Done()
+			if x.queue != nil {
+				defer func() { <-x.queue }()
+			}
+
+			// Don't start new transfers while the network monitor says to pause
+			if err := x.network.Wait(ctx); err != nil {
+				errChan <- err
+				return
+			}
+
+			// Acquire semaphore
+			select {
+			case x.semaphore <- struct{}{}:
+				defer func() { <-x.semaphore }()
+			case <-ctx.Done():
+				errChan <- ctx.Err()
+				return
+			}
+
+			err := x.withOutageRecovery(ctx, name(item), func() error {
+				return Safely(name(item), func() error { return fn(ctx, item) })
+			})
+
+			mu.Lock()
+			done++
+			x.reportProgress(done, len(items), name(item), err)
+			mu.Unlock()
+
+			if err != nil {
+				errChan <- err
+			}
+		}(item)
+	}
+
+	// Wait for all transfers to complete
+	go func() {
+		wg.Wait()
+		close(errChan)
+	}()
+
+	// Collect the first error, draining the rest
+	var firstErr error
+	for err := range errChan {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// withOutageRecovery runs a transfer, waiting out network outages and
+// retrying in place instead of failing the run
+func (x *Executor) withOutageRecovery(ctx context.Context, name string, transfer func() error) error {
+	for {
+		if err := x.outage.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := transfer()
+		if err == nil || !x.outage.Report(ctx, err) {
+			return err
+		}
+
+		slog.Debug("Retrying transfer after network outage", slog.String("path", name))
+	}
+}
+
+// Open file descriptors budgeted by capConcurrency: a transfer may hold a
+// connection, the file being written and a file being verified, plus its
+// copy in each mirror, and the rest of the process (logs, manifest, idle
+// connections) needs some headroom
+const (
+	filesPerTransfer  = 4
+	reservedOpenFiles = 64
+)
+
+// capConcurrency limits the concurrency of transfers, and of the lane of
+// large files running next to them (0 for none), so that with mirrors
+// written alongside each transfer they all stay within limit open files. It
+// warns when it has to; a zero limit means unknown.
+func capConcurrency(concurrency, lane, mirrors int, limit uint64) (int, int) {
+	if limit == 0 || concurrency < 1 {
+		return concurrency, lane
+	}
+
+	perTransfer := uint64(filesPerTransfer + mirrors)
+	allowed := 1
+	if limit > reservedOpenFiles+perTransfer {
+		allowed = int((limit - reservedOpenFiles) / perTransfer)
+	}
+	if concurrency+lane <= allowed {
+		return concurrency, lane
+	}
+
+	// The lane keeps at most half of what is allowed, and both at least one
+	cappedLane := lane
+	if lane > 0 {
+		cappedLane = max(min(lane, allowed/2), 1)
+	}
+	capped := max(min(concurrency, allowed-cappedLane), 1)
+	slog.Warn("Reducing concurrency to stay within the open file limit",
+		slog.Int("requested", concurrency),
+		slog.Int("concurrency", capped),
+		slog.Int("requested_large_file_concurrency", lane),
+		slog.Int("large_file_concurrency", cappedLane),
+		slog.Int("mirrors", mirrors),
+		slog.Uint64("open_file_limit", limit),
+	)
+	return capped, cappedLane
+}
+
+// capMemoryConcurrency limits concurrency to the transfers the memory plan
+// has room for, warning when it has to
+func capMemoryConcurrency(concurrency int, memory config.MemoryPlan) int {
+	if memory.Concurrency == 0 || concurrency <= memory.Concurrency {
+		return concurrency
+	}
+
+	slog.Warn("Reducing concurrency to stay within --max-memory",
+		slog.Int("requested", concurrency),
+		slog.Int("concurrency", memory.Concurrency),
+	)
+	return memory.Concurrency
+}
+
+// NewFromConfig creates an executor with the transfer settings of a
+// configuration, writing progress to the given writer if enabled
+func NewFromConfig(cfg *config.Config, progress io.Writer) (*Executor, error) {
+	bandwidth, err := cfg.Bandwidth()
+	if err != nil {
+		return nil, err
+	}
+	writeBuffer, err := cfg.WriteBufferSize()
+	if err != nil {
+		return nil, err
+	}
+	memory, err := cfg.MemoryPlan()
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := cfg.LargeFileThresholdSize()
+	if err != nil {
+		return nil, err
+	}
+
+	// Large files only get a lane of their own above the threshold
+	lane := 0
+	if threshold > 0 {
+		lane = cfg.LargeFileConcurrency
+	}
+	concurrency, lane := capConcurrency(cfg.MaxConcurrency, lane, len(cfg.Mirrors), openFileLimit())
+
+	opts := Options{
+		Concurrency:     capMemoryConcurrency(concurrency, memory),
+		LaneConcurrency: lane,
+		MaxQueued:       memory.QueuedTransfers,
+		Bandwidth:       bandwidth,
+		Network: netwatch.Options{
+			PauseOnMetered: cfg.PauseOnMetered,
+			Interface:      cfg.RequireInterface,
+		},
+		OutageTimeout:   cfg.OutageTimeout,
+		WriteBuffer:     writeBuffer,
+		SerializeWrites: cfg.SerializeWrites,
+	}
+	if cfg.Progress {
+		opts.Progress = progress
+	}
+
+	return New(opts), nil
+}
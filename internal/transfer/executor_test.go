@@ -0,0 +1,278 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/config"
+)
+
+func TestRunLimitsConcurrency(t *testing.T) {
+	x := New(Options{Concurrency: 2})
+
+	var running, peak atomic.Int32
+	items := []int{1, 2, 3, 4, 5, 6}
+	err := Run(context.Background(), x, items, func(i int) string { return fmt.Sprint(i) }, func(ctx context.Context, i int) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", peak.Load())
+	}
+}
+
+func TestRunBoundsQueue(t *testing.T) {
+	x := New(Options{Concurrency: 2, MaxQueued: 4})
+	baseline := runtime.NumGoroutine()
+
+	var peak atomic.Int32
+	items := make([]int, 200)
+	err := Run(context.Background(), x, items, func(i int) string { return fmt.Sprint(i) }, func(ctx context.Context, i int) error {
+		n := int32(runtime.NumGoroutine() - baseline)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// The queued transfers and the goroutine waiting for them
+	if peak.Load() > 5 {
+		t.Errorf("peak goroutines = %d, want at most 5", peak.Load())
+	}
+}
+
+func TestRunReturnsErrorAfterAllItems(t *testing.T) {
+	x := New(Options{Concurrency: 3})
+
+	var calls atomic.Int32
+	failure := errors.New("boom")
+	err := Run(context.Background(), x, []string{"a", "b", "c", "d"}, func(s string) string { return s }, func(ctx context.Context, s string) error {
+		calls.Add(1)
+		if s == "b" {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Run() error = %v, want %v", err, failure)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("calls = %d, want 4", calls.Load())
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	var out bytes.Buffer
+	x := New(Options{Concurrency: 1, Progress: &out})
+
+	err := Run(context.Background(), x, []string{"/a.txt", "/b.txt"}, func(s string) string { return s }, func(ctx context.Context, s string) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Transfers finish in any order, but the count goes up line by line
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	slices.Sort(lines)
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "[1/2] ") || !strings.HasPrefix(lines[1], "[2/2] ") ||
+		!strings.Contains(out.String(), "/a.txt") || !strings.Contains(out.String(), "/b.txt") {
+		t.Errorf("progress output = %q", out.String())
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	tests := []struct {
+		done, total int
+		name        string
+		err         error
+		want        string
+	}{
+		{3, 12, "/a.txt", nil, "[ 3/12] /a.txt"},
+		{1, 1, "/b.txt", errors.New("denied"), "[1/1] /b.txt: failed: denied"},
+	}
+
+	for _, tt := range tests {
+		if got := ProgressLine(tt.done, tt.total, tt.name, tt.err); got != tt.want {
+			t.Errorf("ProgressLine() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	var out strings.Builder
+	x := New(Options{Progress: &out})
+	x.Track(NewEstimate(1000, 1, 100))
+
+	x.reportProgress(1, 2, "/a.txt", nil)
+	x.reportProgress(2, 2, "/b.txt", nil)
+	if want := "[1/2] /a.txt (ETA 10s)\n[2/2] /b.txt\n"; out.String() != want {
+		t.Errorf("progress output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestNewFromConfigProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress bool
+		want     string
+	}{
+		{name: "enabled", progress: true, want: "[1/1] /a.txt\n"},
+		{name: "disabled", progress: false, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			x, err := NewFromConfig(&config.Config{MaxConcurrency: 1, Progress: tt.progress}, &out)
+			if err != nil {
+				t.Fatalf("NewFromConfig() error = %v", err)
+			}
+			x.reportProgress(1, 1, "/a.txt", nil)
+			if out.String() != tt.want {
+				t.Errorf("progress output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCapConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		lane        int
+		mirrors     int
+		limit       uint64
+		want        int
+		wantLane    int
+	}{
+		{name: "unknown limit", concurrency: 50, lane: 2, limit: 0, want: 50, wantLane: 2},
+		{name: "within limit", concurrency: 5, limit: 1024, want: 5},
+		{name: "capped", concurrency: 500, limit: 256, want: 48},
+		{name: "tiny limit", concurrency: 8, limit: 32, want: 1},
+		{name: "lane within limit", concurrency: 46, lane: 2, limit: 256, want: 46, wantLane: 2},
+		{name: "lane counted", concurrency: 48, lane: 2, limit: 256, want: 46, wantLane: 2},
+		{name: "lane capped", concurrency: 500, lane: 40, limit: 256, want: 24, wantLane: 24},
+		{name: "mirrors counted", concurrency: 48, mirrors: 2, limit: 256, want: 32},
+		{name: "lane and mirrors", concurrency: 48, lane: 2, mirrors: 4, limit: 256, want: 22, wantLane: 2},
+		{name: "tiny limit with lane", concurrency: 8, lane: 2, limit: 32, want: 1, wantLane: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotLane := capConcurrency(tt.concurrency, tt.lane, tt.mirrors, tt.limit)
+			if got != tt.want || gotLane != tt.wantLane {
+				t.Errorf("capConcurrency(%d, %d, %d, %d) = %d, %d; want %d, %d", tt.concurrency, tt.lane, tt.mirrors, tt.limit, got, gotLane, tt.want, tt.wantLane)
+			}
+		})
+	}
+}
+
+func TestLaneLimit(t *testing.T) {
+	x := New(Options{Concurrency: 4, LaneConcurrency: 2})
+	if got := x.Lane(8).Concurrency(); got != 2 {
+		t.Errorf("Lane(8).Concurrency() = %d, want 2", got)
+	}
+	if got := x.Lane(1).Concurrency(); got != 1 {
+		t.Errorf("Lane(1).Concurrency() = %d, want 1", got)
+	}
+}
+
+func TestCapMemoryConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		memory      config.MemoryPlan
+		want        int
+	}{
+		{name: "no budget", concurrency: 50, want: 50},
+		{name: "within budget", concurrency: 5, memory: config.MemoryPlan{Concurrency: 8}, want: 5},
+		{name: "capped", concurrency: 50, memory: config.MemoryPlan{Concurrency: 8}, want: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := capMemoryConcurrency(tt.concurrency, tt.memory); got != tt.want {
+				t.Errorf("capMemoryConcurrency(%d) = %d, want %d", tt.concurrency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunRecoversPanics(t *testing.T) {
+	x := New(Options{Concurrency: 1})
+
+	var calls atomic.Int32
+	err := Run(context.Background(), x, []string{"a", "b", "c"}, func(s string) string { return s }, func(ctx context.Context, s string) error {
+		calls.Add(1)
+		if s == "b" {
+			var m map[string]int
+			m[s] = 1
+		}
+		return nil
+	})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Run() error = %v, want a PanicError", err)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Error("PanicError has no stack")
+	}
+	// The panic released its slot, so the other items still ran
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestLaneHasOwnConcurrency(t *testing.T) {
+	x := New(Options{Concurrency: 3})
+	lane := x.Lane(1)
+
+	var running, peak atomic.Int32
+	err := Run(context.Background(), lane, []int{1, 2, 3, 4}, func(i int) string { return fmt.Sprint(i) }, func(ctx context.Context, i int) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if peak.Load() != 1 {
+		t.Errorf("peak concurrency in lane = %d, want 1", peak.Load())
+	}
+	if cap(x.semaphore) != 3 || x.devices != lane.devices {
+		t.Error("lane changed the executor or doesn't share its device locks")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package transfer
+
+// openFileLimit returns 0: there is no per-process open file limit to
+// detect on this platform
+func openFileLimit() uint64 {
+	return 0
+}
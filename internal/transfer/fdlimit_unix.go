@@ -0,0 +1,22 @@
+//go:build unix
+
+package transfer
+
+import "syscall"
+
+// openFileLimit raises the soft limit on open files to the hard limit where
+// allowed and returns the limit in effect; 0 if it is unknown
+func openFileLimit() uint64 {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0
+	}
+	if limit.Cur < limit.Max {
+		raised := limit
+		raised.Cur = limit.Max
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &raised); err == nil {
+			return uint64(raised.Cur)
+		}
+	}
+	return uint64(limit.Cur)
+}
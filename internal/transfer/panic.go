@@ -0,0 +1,34 @@
+package transfer
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// PanicError is the error of a transfer that panicked
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Safely calls fn and turns a panic into a *PanicError, so a bug hit by one
+// file fails that file instead of the whole run. The stack is logged.
+func Safely(name string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			slog.Error("Transfer panicked",
+				slog.String("path", name),
+				slog.Any("panic", r),
+				slog.String("stack", string(stack)),
+			)
+			err = &PanicError{Value: r, Stack: stack}
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,29 @@
+package transfer
+
+import (
+	"regexp"
+)
+
+// PartSuffix ends the name of a file while it is being downloaded
+const PartSuffix = ".part"
+
+// partPattern matches the revision and suffix PartPath appends; Dropbox
+// revisions are at least 9 hex digits
+var partPattern = regexp.MustCompile(`\.[0-9a-f]{9,}\` + PartSuffix + `$`)
+
+// PartPath returns where the download of a revision of a file is written
+// until it completes. The revision in the name keeps an interrupted download
+// from being resumed with the content of another revision.
+func PartPath(localPath, rev string) string {
+	return localPath + "." + rev + PartSuffix
+}
+
+// IsPart reports whether a local path is an incomplete download
+func IsPart(path string) bool {
+	return partPattern.MatchString(path)
+}
+
+// PartTarget returns the path of the file a part is the download of
+func PartTarget(path string) string {
+	return partPattern.ReplaceAllString(path, "")
+}
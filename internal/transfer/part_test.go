@@ -0,0 +1,23 @@
+package transfer
+
+import "testing"
+
+func TestPartPath(t *testing.T) {
+	part := PartPath("/backup/docs/report.pdf", "015f3b2c1a0000000")
+	if part != "/backup/docs/report.pdf.015f3b2c1a0000000.part" {
+		t.Errorf("PartPath() = %q", part)
+	}
+	if !IsPart(part) {
+		t.Errorf("IsPart(%q) = false", part)
+	}
+	if got := PartTarget(part); got != "/backup/docs/report.pdf" {
+		t.Errorf("PartTarget() = %q, want /backup/docs/report.pdf", got)
+	}
+
+	// Files of users that happen to end in .part aren't parts
+	for _, path := range []string{"/backup/video.part", "/backup/chapter.1.part", "/backup/a.015f3b2c1a.partial"} {
+		if IsPart(path) {
+			t.Errorf("IsPart(%q) = true", path)
+		}
+	}
+}
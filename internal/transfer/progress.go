@@ -0,0 +1,35 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+)
+
+// ProgressLine formats the progress line for the done-th of total transfers
+func ProgressLine(done, total int, name string, err error) string {
+	width := len(fmt.Sprint(total))
+	if err != nil {
+		return fmt.Sprintf("[%*d/%d] %s: failed: %v", width, done, total, name, err)
+	}
+	return fmt.Sprintf("[%*d/%d] %s", width, done, total, name)
+}
+
+// reportProgress writes a progress line if progress output is enabled
+func (x *Executor) reportProgress(done, total int, name string, err error) {
+	if x.progress == nil {
+		return
+	}
+	line := ProgressLine(done, total, name, err)
+	if x.estimate != nil && done < total {
+		if remaining, ok := x.estimate.Remaining(); ok {
+			line += fmt.Sprintf(" (ETA %s)", remaining)
+		}
+	}
+	io.WriteString(x.progress, line+"\n")
+}
+
+// Track adds the remaining time of an estimate to progress lines; nil stops
+// it. Lanes created afterwards share the estimate.
+func (x *Executor) Track(estimate *Estimate) {
+	x.estimate = estimate
+}
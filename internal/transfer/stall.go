@@ -0,0 +1,81 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StallError is the cause of a transfer cancelled because its body delivered
+// no data for the stall timeout
+type StallError struct {
+	After time.Duration
+}
+
+func (e *StallError) Error() string {
+	return fmt.Sprintf("transfer stalled: no data received for %s", e.After)
+}
+
+// StallWatch cancels a transfer whose body stops delivering data. Only the
+// time spent waiting for the body counts, not pauses for the bandwidth
+// limiter or the network monitor.
+type StallWatch struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// WatchStall returns a context for a transfer that is cancelled with a
+// *StallError when a read through Reader waits longer than timeout for data.
+// A zero timeout disables the watch. Stop releases the context.
+func WatchStall(ctx context.Context, timeout time.Duration) (context.Context, *StallWatch) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &StallWatch{ctx: ctx, cancel: cancel, timeout: timeout}
+	if timeout > 0 {
+		w.timer = time.AfterFunc(timeout, func() { cancel(&StallError{After: timeout}) })
+		w.timer.Stop()
+	}
+	return ctx, w
+}
+
+// Reader wraps the body of the transfer. The body must be tied to the
+// watch's context, so a read blocked on it returns once the watch fires.
+func (w *StallWatch) Reader(r io.Reader) io.Reader {
+	if w.timer == nil {
+		return r
+	}
+	return &stallReader{r: r, w: w}
+}
+
+// Err returns the *StallError if the watch cancelled the transfer
+func (w *StallWatch) Err() error {
+	var stall *StallError
+	if errors.As(context.Cause(w.ctx), &stall) {
+		return stall
+	}
+	return nil
+}
+
+// Stop stops watching and releases the context
+func (w *StallWatch) Stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.cancel(nil)
+}
+
+// stallReader arms the watch's timer while it waits for the body
+type stallReader struct {
+	r io.Reader
+	w *StallWatch
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	s.w.timer.Reset(s.w.timeout)
+	n, err := s.r.Read(p)
+	s.w.timer.Stop()
+	return n, err
+}
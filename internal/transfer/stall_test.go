@@ -0,0 +1,65 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingReader blocks until its context is done, like a stalled body tied
+// to a context
+type blockingReader struct {
+	ctx context.Context
+}
+
+func (b blockingReader) Read(p []byte) (int, error) {
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+func TestStallWatchFires(t *testing.T) {
+	ctx, watch := WatchStall(context.Background(), 20*time.Millisecond)
+	defer watch.Stop()
+
+	_, err := io.ReadAll(watch.Reader(blockingReader{ctx}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadAll() error = %v, want cancellation", err)
+	}
+	var stall *StallError
+	if !errors.As(watch.Err(), &stall) || stall.After != 20*time.Millisecond {
+		t.Errorf("Err() = %v, want a stall after 20ms", watch.Err())
+	}
+}
+
+func TestStallWatchIgnoresTimeOutsideReads(t *testing.T) {
+	ctx, watch := WatchStall(context.Background(), 20*time.Millisecond)
+	defer watch.Stop()
+
+	r := watch.Reader(strings.NewReader("abc"))
+	buf := make([]byte, 1)
+	for range 3 {
+		// Pauses between reads, e.g. for the bandwidth limiter, don't count
+		time.Sleep(30 * time.Millisecond)
+		if _, err := r.Read(buf); err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+	}
+	if ctx.Err() != nil || watch.Err() != nil {
+		t.Errorf("watch fired: %v", watch.Err())
+	}
+}
+
+func TestStallWatchDisabled(t *testing.T) {
+	_, watch := WatchStall(context.Background(), 0)
+	r := strings.NewReader("abc")
+	if watch.Reader(r) != io.Reader(r) {
+		t.Error("Reader() wrapped the body with the watch disabled")
+	}
+	watch.Stop()
+	if watch.Err() != nil {
+		t.Errorf("Err() = %v after Stop, want nil", watch.Err())
+	}
+}
@@ -0,0 +1,89 @@
+package transfer
+
+import (
+	"fmt"
+	"sync"
+
+	"create-dropbox-backup-folder/internal/localfs"
+)
+
+// DefaultWriteBuffer is the write buffer size used when none is configured,
+// the same as io.Copy's
+const DefaultWriteBuffer = 32 << 10
+
+// devices hands out one lock per storage device for serialized writes
+type devices struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock returns the write lock of the device a file is on
+func (d *devices) lock(f localfs.File) *sync.Mutex {
+	key := deviceKey(f)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.locks == nil {
+		d.locks = make(map[string]*sync.Mutex)
+	}
+	if d.locks[key] == nil {
+		d.locks[key] = &sync.Mutex{}
+	}
+	return d.locks[key]
+}
+
+// FileWriter coalesces small writes to a local file into writes of the
+// configured buffer size. Files smaller than the buffer are written in a
+// single call once complete. When writes are serialized, only one buffer at
+// a time is written to each device, so parallel downloads don't make a
+// spinning disk seek between files.
+type FileWriter struct {
+	f    localfs.File
+	buf  []byte
+	lock *sync.Mutex
+}
+
+// Writer returns a FileWriter for a local file. Flush must be called once
+// all data was written.
+func (x *Executor) Writer(f localfs.File) *FileWriter {
+	w := &FileWriter{f: f, buf: make([]byte, 0, x.writeBuffer)}
+	if x.serializeWrites {
+		w.lock = x.devices.lock(f)
+	}
+	return w
+}
+
+// Write buffers p, writing out every full buffer
+func (w *FileWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+
+		if len(w.buf) == cap(w.buf) {
+			if err := w.Flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush writes out the buffered data
+func (w *FileWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if w.lock != nil {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+	}
+
+	_, err := w.f.Write(w.buf)
+	w.buf = w.buf[:0]
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.f.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFileWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		buffer int
+		chunks []string
+	}{
+		{name: "smaller than buffer", buffer: 64, chunks: []string{"small ", "file"}},
+		{name: "exact buffer", buffer: 4, chunks: []string{"ab", "cd"}},
+		{name: "spans buffers", buffer: 4, chunks: []string{"abcdefghij", "k", "lmnopq"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := New(Options{WriteBuffer: tt.buffer, SerializeWrites: true})
+			path := filepath.Join(t.TempDir(), "out")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			w := x.Writer(f)
+			var want bytes.Buffer
+			for _, chunk := range tt.chunks {
+				if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+				want.WriteString(chunk)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("file content = %q, want %q", got, want.Bytes())
+			}
+		})
+	}
+}
+
+func TestSerializedWritesShareDeviceLock(t *testing.T) {
+	dir := t.TempDir()
+	x := New(Options{WriteBuffer: 8, SerializeWrites: true})
+
+	var wg sync.WaitGroup
+	var locks sync.Map
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := os.Create(filepath.Join(dir, fmt.Sprint(i)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer f.Close()
+
+			w := x.Writer(f)
+			locks.Store(w.lock, true)
+			if _, err := w.Write(bytes.Repeat([]byte{'x'}, 100)); err != nil {
+				t.Error(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	locks.Range(func(key, value any) bool {
+		if key.(*sync.Mutex) == nil {
+			t.Error("serialized writer has no device lock")
+		}
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Errorf("files on one device used %d locks, want 1", count)
+	}
+
+	if w := New(Options{}).Writer(nil); w.lock != nil || cap(w.buf) != DefaultWriteBuffer {
+		t.Errorf("default writer = lock %v, buffer %d; want no lock, %d", w.lock, cap(w.buf), DefaultWriteBuffer)
+	}
+}
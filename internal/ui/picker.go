@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PickerItem is a single entry in an interactive selection list
+type PickerItem struct {
+	Label    string
+	Detail   string
+	Selected bool
+}
+
+// Pick shows a toggle list on out and reads commands from in until the user
+// confirms. Entering numbers or ranges (e.g. "1 3-5") toggles items, "a"
+// selects all, "n" clears the selection and an empty line confirms.
+func Pick(in io.Reader, out io.Writer, title string, items []PickerItem) ([]PickerItem, error) {
+	reader := bufio.NewReader(in)
+
+	for {
+		renderPicker(out, title, items)
+		fmt.Fprint(out, "Toggle numbers (e.g. 1 3-5), 'a' all, 'n' none, Enter to confirm: ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read selection: %w", err)
+		}
+		if err == io.EOF && line == "" {
+			return nil, fmt.Errorf("failed to read selection: input closed")
+		}
+
+		// A final line without a newline is applied and then confirmed
+		confirm := err == io.EOF
+
+		line = strings.TrimSpace(line)
+		switch strings.ToLower(line) {
+		case "":
+			return items, nil
+		case "a", "all":
+			setAll(items, true)
+		case "n", "none":
+			setAll(items, false)
+		default:
+			if err := toggle(items, line); err != nil {
+				fmt.Fprintf(out, "⚠️  %v\n", err)
+			}
+		}
+
+		if confirm {
+			return items, nil
+		}
+	}
+}
+
+func renderPicker(out io.Writer, title string, items []PickerItem) {
+	fmt.Fprintf(out, "\n%s\n", title)
+	for i, item := range items {
+		mark := " "
+		if item.Selected {
+			mark = "x"
+		}
+		if item.Detail != "" {
+			fmt.Fprintf(out, "  %2d) [%s] %s (%s)\n", i+1, mark, item.Label, item.Detail)
+		} else {
+			fmt.Fprintf(out, "  %2d) [%s] %s\n", i+1, mark, item.Label)
+		}
+	}
+}
+
+func setAll(items []PickerItem, selected bool) {
+	for i := range items {
+		items[i].Selected = selected
+	}
+}
+
+// toggle flips the selection of every item referenced in the input
+func toggle(items []PickerItem, input string) error {
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+
+	var indexes []int
+	for _, field := range fields {
+		start, end, err := parseRange(field)
+		if err != nil {
+			return err
+		}
+		if start < 1 || end > len(items) || start > end {
+			return fmt.Errorf("selection %q is out of range (1-%d)", field, len(items))
+		}
+		for i := start; i <= end; i++ {
+			indexes = append(indexes, i-1)
+		}
+	}
+
+	for _, i := range indexes {
+		items[i].Selected = !items[i].Selected
+	}
+	return nil
+}
+
+func parseRange(field string) (int, int, error) {
+	if before, after, found := strings.Cut(field, "-"); found {
+		start, err := strconv.Atoi(before)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid selection %q", field)
+		}
+		end, err := strconv.Atoi(after)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid selection %q", field)
+		}
+		return start, end, nil
+	}
+
+	n, err := strconv.Atoi(field)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid selection %q", field)
+	}
+	return n, n, nil
+}
@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func newItems() []PickerItem {
+	return []PickerItem{
+		{Label: "/documents"},
+		{Label: "/photos", Detail: "1.0 GB, 10 files"},
+		{Label: "/music"},
+		{Label: "/videos"},
+	}
+}
+
+func selectedLabels(items []PickerItem) []string {
+	var labels []string
+	for _, item := range items {
+		if item.Selected {
+			labels = append(labels, item.Label)
+		}
+	}
+	return labels
+}
+
+func TestPick(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "confirm without changes",
+			input: "\n",
+			want:  nil,
+		},
+		{
+			name:  "toggle single items",
+			input: "1 3\n\n",
+			want:  []string{"/documents", "/music"},
+		},
+		{
+			name:  "toggle range",
+			input: "2-4\n\n",
+			want:  []string{"/photos", "/music", "/videos"},
+		},
+		{
+			name:  "toggle twice deselects",
+			input: "1\n1\n\n",
+			want:  nil,
+		},
+		{
+			name:  "all then deselect one",
+			input: "a\n2\n\n",
+			want:  []string{"/documents", "/music", "/videos"},
+		},
+		{
+			name:  "none clears selection",
+			input: "a\nn\n\n",
+			want:  nil,
+		},
+		{
+			name:  "invalid input is ignored",
+			input: "9\nfoo\n2\n\n",
+			want:  []string{"/photos"},
+		},
+		{
+			name:  "end of input confirms pending line",
+			input: "4",
+			want:  []string{"/videos"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := Pick(strings.NewReader(tt.input), &out, "Choose folders", newItems())
+			if err != nil {
+				t.Fatalf("Pick() error = %v", err)
+			}
+
+			labels := selectedLabels(got)
+			if strings.Join(labels, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Pick() selected = %v, want %v", labels, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickEndOfInput(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := Pick(strings.NewReader(""), &out, "Choose folders", newItems()); err == nil {
+		t.Error("Pick() expected error when input is closed")
+	}
+}
+
+func TestPickRendersDetail(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := Pick(strings.NewReader("\n"), &out, "Choose folders", newItems()); err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+
+	if !strings.Contains(out.String(), "[ ] /photos (1.0 GB, 10 files)") {
+		t.Errorf("Pick() output missing folder detail:\n%s", out.String())
+	}
+}
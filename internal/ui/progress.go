@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+// Layout of the live progress display
+const (
+	barWidth = 20
+	// largeTransfer is the size from which a running transfer gets a line of
+	// its own, and maxTransferLines how many are shown, largest first
+	largeTransfer    = 32 << 20
+	maxTransferLines = 5
+	maxNameWidth     = 48
+)
+
+// rateSmoothing weighs the latest transfer rate against the earlier ones,
+// so the rate shown doesn't jump with every update
+const rateSmoothing = 0.3
+
+// ProgressView shows the progress of a download phase: redrawn in place on a
+// terminal, with a line of its own for every large running transfer, or as
+// a line per update otherwise, e.g. in logs
+type ProgressView struct {
+	w     io.Writer
+	live  bool
+	drawn int
+
+	lastBytes uint64
+	lastTime  time.Time
+	rate      float64
+	now       func() time.Time
+}
+
+// NewProgressView creates a view writing to w, redrawing in place if live
+func NewProgressView(w io.Writer, live bool) *ProgressView {
+	return &ProgressView{w: w, live: live, now: time.Now}
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or a pipe
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Update shows a new status of the download phase
+func (v *ProgressView) Update(status transfer.EstimateStatus) {
+	v.measureRate(status)
+	if !v.live {
+		io.WriteString(v.w, "Downloading: "+v.summary(status, ", ")+"\n")
+		return
+	}
+
+	lines := []string{fmt.Sprintf("Downloading %s %s", bar(status.DoneBytes, status.TotalBytes), v.summary(status, "  "))}
+	for _, t := range status.Active {
+		if t.Size < largeTransfer || len(lines) > maxTransferLines {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s of %s  %s", bar(t.Received, t.Size), output.Bytes(t.Received), output.Bytes(t.Size), shorten(t.Name, maxNameWidth)))
+	}
+	v.erase()
+	for _, line := range lines {
+		io.WriteString(v.w, line+"\n")
+	}
+	v.drawn = len(lines)
+}
+
+// Close removes a live display, so the output following it starts on a
+// clean line
+func (v *ProgressView) Close() {
+	v.erase()
+	v.drawn = 0
+}
+
+// erase moves the cursor back to the first line drawn and clears the rest
+// of the screen
+func (v *ProgressView) erase() {
+	if v.drawn > 0 {
+		fmt.Fprintf(v.w, "\r\x1b[%dA\x1b[J", v.drawn)
+	}
+}
+
+// measureRate updates the current transfer rate from the bytes done since
+// the last update, starting at the throughput of the estimate. Bytes of a
+// finished transfer are briefly not counted, so the done bytes may drop.
+func (v *ProgressView) measureRate(status transfer.EstimateStatus) {
+	now := v.now()
+	if v.lastTime.IsZero() {
+		v.rate = status.BytesPerSecond
+	} else if elapsed := now.Sub(v.lastTime).Seconds(); elapsed > 0 {
+		var delta uint64
+		if status.DoneBytes > v.lastBytes {
+			delta = status.DoneBytes - v.lastBytes
+		}
+		v.rate = rateSmoothing*float64(delta)/elapsed + (1-rateSmoothing)*v.rate
+	}
+	v.lastBytes, v.lastTime = status.DoneBytes, now
+}
+
+// summary describes the files and bytes done, the transfer rate and the time
+// left, separated by sep
+func (v *ProgressView) summary(status transfer.EstimateStatus, sep string) string {
+	parts := []string{
+		fmt.Sprintf("%s/%s files", output.Count(status.DoneFiles), output.Count(status.TotalFiles)),
+		fmt.Sprintf("%s of %s (%d%%)", output.Bytes(status.DoneBytes), output.Bytes(status.TotalBytes), percent(status.DoneBytes, status.TotalBytes)),
+		output.Bytes(uint64(v.rate)) + "/s",
+	}
+	if status.BytesPerSecond > 0 {
+		parts = append(parts, "ETA "+status.Remaining.Round(time.Second).String())
+	}
+	return strings.Join(parts, sep)
+}
+
+// bar draws the share of done in total
+func bar(done, total uint64) string {
+	filled := percent(done, total) * barWidth / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]"
+}
+
+// percent returns done as a percentage of total, 100 if nothing is to do
+func percent(done, total uint64) int {
+	if total == 0 {
+		return 100
+	}
+	return int(min(done, total) * 100 / total)
+}
+
+// shorten keeps the end of a path, which names the file, within width
+// characters
+func shorten(name string, width int) string {
+	runes := []rune(name)
+	if len(runes) <= width {
+		return name
+	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/transfer"
+)
+
+func TestProgressViewLines(t *testing.T) {
+	var buf bytes.Buffer
+	view := NewProgressView(&buf, false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	view.now = func() time.Time { return now }
+
+	view.Update(transfer.EstimateStatus{TotalBytes: 4 << 20, DoneBytes: 1 << 20, TotalFiles: 10, DoneFiles: 2})
+	now = now.Add(10 * time.Second)
+	view.Update(transfer.EstimateStatus{TotalBytes: 4 << 20, DoneBytes: 2 << 20, TotalFiles: 10, DoneFiles: 5, BytesPerSecond: 1 << 20, Remaining: 2 * time.Second})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want a line per update: %q", len(lines), buf.String())
+	}
+	if want := "Downloading: 2/10 files, 1.0 MB of 4.0 MB (25%), 0 B/s"; lines[0] != want {
+		t.Errorf("first line = %q, want %q", lines[0], want)
+	}
+	if want := "Downloading: 5/10 files, 2.0 MB of 4.0 MB (50%), 30.7 KB/s, ETA 2s"; lines[1] != want {
+		t.Errorf("second line = %q, want %q", lines[1], want)
+	}
+	if strings.Contains(buf.String(), "\x1b") {
+		t.Errorf("lines contain escape sequences: %q", buf.String())
+	}
+}
+
+func TestProgressViewLive(t *testing.T) {
+	var buf bytes.Buffer
+	view := NewProgressView(&buf, true)
+
+	status := transfer.EstimateStatus{
+		TotalBytes: 200 << 20,
+		DoneBytes:  100 << 20,
+		TotalFiles: 4,
+		DoneFiles:  1,
+		Active: []transfer.TransferStatus{
+			{Name: "/Videos/trip.mov", Size: 100 << 20, Received: 25 << 20},
+			{Name: "/notes.txt", Size: 1 << 10},
+		},
+	}
+	view.Update(status)
+	first := buf.String()
+	if !strings.HasPrefix(first, "Downloading [##########----------] 1/4 files") {
+		t.Errorf("display = %q, want the overall bar and files first", first)
+	}
+	if !strings.Contains(first, "\n  [#####---------------] 25.0 MB of 100.0 MB  /Videos/trip.mov\n") {
+		t.Errorf("display = %q, want a line for the large transfer", first)
+	}
+	if strings.Contains(first, "notes.txt") {
+		t.Errorf("display = %q, want no line for the small transfer", first)
+	}
+
+	buf.Reset()
+	view.Update(status)
+	if !strings.HasPrefix(buf.String(), "\r\x1b[2A\x1b[J") {
+		t.Errorf("redraw = %q, want the 2 lines drawn erased first", buf.String())
+	}
+
+	buf.Reset()
+	view.Close()
+	if buf.String() != "\r\x1b[2A\x1b[J" {
+		t.Errorf("Close() wrote %q, want the display erased", buf.String())
+	}
+}
+
+func TestShorten(t *testing.T) {
+	if got := shorten("/a/b.txt", 10); got != "/a/b.txt" {
+		t.Errorf("shorten() = %q, want the short name unchanged", got)
+	}
+	if got := shorten("/Photos/2019/iceland/IMG_0001.jpg", 12); got != "…MG_0001.jpg" {
+		t.Errorf("shorten() = %q, want the end of the path", got)
+	}
+}
@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Choice is one answer to a Prompt question, selected by typing its key
+type Choice struct {
+	Key   string
+	Label string
+}
+
+// ErrNoInput is returned by a prompt that may not ask, see NoInput
+var ErrNoInput = errors.New("interactive input is disabled (--no-input)")
+
+// Prompt asks a series of multiple-choice questions on the same input
+type Prompt struct {
+	// in is nil if the prompt may not ask
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewPrompt creates a prompt reading answers from in and writing to out
+func NewPrompt(in io.Reader, out io.Writer) *Prompt {
+	return &Prompt{in: bufio.NewReader(in), out: out}
+}
+
+// NoInput creates a prompt failing every question with ErrNoInput, for
+// unattended runs that must not wait for an answer
+func NoInput(out io.Writer) *Prompt {
+	return &Prompt{out: out}
+}
+
+// refuse fails a question when the prompt may not ask
+func (p *Prompt) refuse(question string) error {
+	if p.in != nil {
+		return nil
+	}
+	return fmt.Errorf("can't ask %q: %w", strings.TrimSpace(question), ErrNoInput)
+}
+
+// Ask shows the question and choices and returns the key of the selected
+// choice. Keys are matched case-insensitively; invalid answers ask again.
+func (p *Prompt) Ask(question string, choices []Choice) (string, error) {
+	if err := p.refuse(question); err != nil {
+		return "", err
+	}
+	options := make([]string, len(choices))
+	for i, choice := range choices {
+		options[i] = fmt.Sprintf("[%s] %s", choice.Key, choice.Label)
+	}
+
+	for {
+		fmt.Fprintf(p.out, "%s\n  %s: ", question, strings.Join(options, ", "))
+
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return "", fmt.Errorf("failed to read answer: input closed")
+			}
+			return "", fmt.Errorf("failed to read answer: %w", err)
+		}
+
+		answer := strings.TrimSpace(line)
+		for _, choice := range choices {
+			if strings.EqualFold(answer, choice.Key) {
+				return choice.Key, nil
+			}
+		}
+
+		if err == io.EOF {
+			return "", fmt.Errorf("invalid answer %q", answer)
+		}
+		fmt.Fprintf(p.out, "⚠️  Please answer one of the listed keys\n")
+	}
+}
+
+// Input asks for a line of text. An empty answer keeps current, which is
+// shown in brackets if set.
+func (p *Prompt) Input(question, current string) (string, error) {
+	if err := p.refuse(question); err != nil {
+		return "", err
+	}
+	if current != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, current)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", fmt.Errorf("failed to read answer: input closed")
+		}
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return current, nil
+}
+
+// Pick shows a toggle list reading from the prompt's input, see Pick
+func (p *Prompt) Pick(title string, items []PickerItem) ([]PickerItem, error) {
+	if err := p.refuse(title); err != nil {
+		return nil, err
+	}
+	return Pick(p.in, p.out, title, items)
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPromptAsk(t *testing.T) {
+	choices := []Choice{{Key: "s", Label: "skip"}, {Key: "o", Label: "overwrite"}}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "single answer", input: "o\n", want: []string{"o"}},
+		{name: "case insensitive", input: "S\n", want: []string{"s"}},
+		{name: "retry after invalid", input: "x\ns\n", want: []string{"s"}},
+		{name: "several questions", input: "s\no\n", want: []string{"s", "o"}},
+		{name: "final line without newline", input: "o", want: []string{"o"}},
+		{name: "input closed", input: "", wantErr: true},
+		{name: "invalid final line", input: "x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			prompt := NewPrompt(strings.NewReader(tt.input), &out)
+
+			if tt.wantErr {
+				if _, err := prompt.Ask("Conflict?", choices); err == nil {
+					t.Error("Ask() expected error")
+				}
+				return
+			}
+
+			for i, want := range tt.want {
+				got, err := prompt.Ask("Conflict?", choices)
+				if err != nil {
+					t.Fatalf("Ask() #%d error = %v", i, err)
+				}
+				if got != want {
+					t.Errorf("Ask() #%d = %q, want %q", i, got, want)
+				}
+			}
+			if !strings.Contains(out.String(), "[o] overwrite") {
+				t.Errorf("output %q does not list choices", out.String())
+			}
+		})
+	}
+}
+
+func TestPromptInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		current string
+		want    string
+		wantErr bool
+	}{
+		{name: "answer", input: "app-key\n", want: "app-key"},
+		{name: "spaces trimmed", input: "  app-key \n", want: "app-key"},
+		{name: "empty keeps current", input: "\n", current: "old", want: "old"},
+		{name: "answer replaces current", input: "new\n", current: "old", want: "new"},
+		{name: "final line without newline", input: "app-key", want: "app-key"},
+		{name: "input closed", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := NewPrompt(strings.NewReader(tt.input), &out).Input("App key", tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Input() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Input() = %q, want %q", got, tt.want)
+			}
+			if tt.current != "" && !strings.Contains(out.String(), "["+tt.current+"]") {
+				t.Errorf("output %q does not show the current value", out.String())
+			}
+		})
+	}
+}
+
+func TestPromptPickSharesInput(t *testing.T) {
+	var out bytes.Buffer
+	prompt := NewPrompt(strings.NewReader("dir\n2\n\nyes\n"), &out)
+
+	if got, err := prompt.Input("Backup folder", ""); err != nil || got != "dir" {
+		t.Fatalf("Input() = %q, %v; want dir", got, err)
+	}
+	items, err := prompt.Pick("Folders:", []PickerItem{{Label: "/a"}, {Label: "/b"}})
+	if err != nil || items[0].Selected || !items[1].Selected {
+		t.Fatalf("Pick() = %+v, %v; want /b selected", items, err)
+	}
+	if got, err := prompt.Input("Continue", ""); err != nil || got != "yes" {
+		t.Errorf("Input() after Pick() = %q, %v; want yes", got, err)
+	}
+}
+
+func TestNoInput(t *testing.T) {
+	var out bytes.Buffer
+	prompt := NoInput(&out)
+
+	if _, err := prompt.Ask("Conflict?", []Choice{{Key: "s", Label: "skip"}}); !errors.Is(err, ErrNoInput) {
+		t.Errorf("Ask() error = %v, want ErrNoInput", err)
+	}
+	if _, err := prompt.Input("App key", "current"); !errors.Is(err, ErrNoInput) {
+		t.Errorf("Input() error = %v, want ErrNoInput", err)
+	}
+	if _, err := prompt.Pick("Folders", []PickerItem{{Label: "a"}}); !errors.Is(err, ErrNoInput) {
+		t.Errorf("Pick() error = %v, want ErrNoInput", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("NoInput prompt wrote %q", out.String())
+	}
+}
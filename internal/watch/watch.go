@@ -0,0 +1,110 @@
+// Package watch waits for changes to Dropbox folders with longpolls on their
+// listing cursors, so a backup can follow changes as they happen without a
+// webhook endpoint reachable from the internet.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+)
+
+// Poller waits up to timeout for changes below the folder of a cursor, see
+// dropbox.Client.Longpoll
+type Poller func(ctx context.Context, cursor string, timeout time.Duration) (dropbox.LongpollResult, error)
+
+// Options configure Wait
+type Options struct {
+	// Poll waits for changes of one cursor
+	Poll Poller
+	// Timeout is how long each longpoll waits before it is repeated
+	Timeout time.Duration
+	// Debounce is how long to wait after the first change, so a burst of
+	// changes is picked up by one backup
+	Debounce time.Duration
+	// RetryDelay is how long to wait after a failed longpoll
+	RetryDelay time.Duration
+}
+
+// Wait returns once any of the folders of cursors changed and the debounce
+// interval has passed, or with the context's error once it is done. A cursor
+// Dropbox no longer accepts counts as a change, as the next backup lists the
+// folder again and takes a new one. Failed longpolls are retried.
+func Wait(ctx context.Context, opts Options, cursors []string) error {
+	if len(cursors) == 0 {
+		return fmt.Errorf("no listing cursors to watch")
+	}
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changed := make(chan struct{}, len(cursors))
+	for _, cursor := range cursors {
+		go func(cursor string) {
+			if poll(pollCtx, opts, cursor) {
+				changed <- struct{}{}
+			}
+		}(cursor)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-changed:
+		cancel()
+	}
+
+	slog.Info("Dropbox reported changes", slog.Duration("debounce", opts.Debounce))
+	return sleep(ctx, opts.Debounce)
+}
+
+// poll repeats longpolls for a cursor until its folder changes, returning
+// true, or the context is done
+func poll(ctx context.Context, opts Options, cursor string) bool {
+	for {
+		res, err := opts.Poll(ctx, cursor, opts.Timeout)
+		if ctx.Err() != nil {
+			return false
+		}
+		if dropbox.IsCursorReset(err) {
+			slog.Warn("Dropbox no longer accepts the listing cursor, backing up to take a new one")
+			return true
+		}
+		if err != nil {
+			slog.Warn("Failed to wait for Dropbox changes, retrying",
+				slog.String("error", err.Error()),
+				slog.Duration("retry_in", opts.RetryDelay),
+			)
+			if sleep(ctx, opts.RetryDelay) != nil {
+				return false
+			}
+			continue
+		}
+		if res.Changes {
+			return true
+		}
+		if res.Backoff > 0 {
+			slog.Debug("Dropbox asked to back off", slog.Duration("backoff", res.Backoff))
+			if sleep(ctx, res.Backoff) != nil {
+				return false
+			}
+		}
+	}
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"create-dropbox-backup-folder/internal/dropbox"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+// script answers the longpolls of each cursor in turn, then blocks until the
+// context is done
+type script struct {
+	mu      sync.Mutex
+	answers map[string][]answer
+	calls   map[string]int
+}
+
+type answer struct {
+	result dropbox.LongpollResult
+	err    error
+}
+
+func (s *script) poll(ctx context.Context, cursor string, timeout time.Duration) (dropbox.LongpollResult, error) {
+	s.mu.Lock()
+	s.calls[cursor]++
+	var next *answer
+	if len(s.answers[cursor]) > 0 {
+		next = &s.answers[cursor][0]
+		s.answers[cursor] = s.answers[cursor][1:]
+	}
+	s.mu.Unlock()
+
+	if next == nil {
+		<-ctx.Done()
+		return dropbox.LongpollResult{}, ctx.Err()
+	}
+	return next.result, next.err
+}
+
+func TestWait(t *testing.T) {
+	reset := files.ListFolderContinueAPIError{EndpointError: &files.ListFolderContinueError{}}
+	reset.EndpointError.Tag = files.ListFolderContinueErrorReset
+
+	tests := []struct {
+		name    string
+		answers map[string][]answer
+		want    map[string]int
+	}{
+		{
+			name: "change after quiet polls",
+			answers: map[string][]answer{
+				"a": {{}, {}, {result: dropbox.LongpollResult{Changes: true}}},
+			},
+			want: map[string]int{"a": 3},
+		},
+		{
+			name: "backoff and failures are retried",
+			answers: map[string][]answer{
+				"a": {{result: dropbox.LongpollResult{Backoff: time.Millisecond}}, {err: errors.New("network down")}, {result: dropbox.LongpollResult{Changes: true}}},
+			},
+			want: map[string]int{"a": 3},
+		},
+		{
+			name: "any of several folders",
+			answers: map[string][]answer{
+				"b": {{result: dropbox.LongpollResult{Changes: true}}},
+			},
+			want: map[string]int{"b": 1},
+		},
+		{
+			name: "reset cursor counts as a change",
+			answers: map[string][]answer{
+				"a": {{err: reset}},
+			},
+			want: map[string]int{"a": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &script{answers: tt.answers, calls: make(map[string]int)}
+			opts := Options{Poll: s.poll, Timeout: time.Minute, Debounce: time.Millisecond, RetryDelay: time.Millisecond}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := Wait(ctx, opts, []string{"a", "b"}); err != nil {
+				t.Fatalf("Wait() error = %v", err)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for cursor, want := range tt.want {
+				if got := s.calls[cursor]; got != want {
+					t.Errorf("polled %s %d times, want %d", cursor, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitStops(t *testing.T) {
+	s := &script{calls: make(map[string]int)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Wait(ctx, Options{Poll: s.poll, Timeout: time.Minute}, []string{"a"})
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Wait() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() didn't return after the context was cancelled")
+	}
+}
+
+func TestWaitWithoutCursors(t *testing.T) {
+	if err := Wait(context.Background(), Options{}, nil); err == nil {
+		t.Error("Wait() without cursors succeeded")
+	}
+}
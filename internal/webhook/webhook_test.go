@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChallenge(t *testing.T) {
+	h := NewHandler("secret", "")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhook?challenge=abc123", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc123" {
+		t.Errorf("challenge response = %d %q, want 200 abc123", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing challenge status = %d, want 400", rec.Code)
+	}
+}
+
+func TestNotification(t *testing.T) {
+	body := `{"list_folder": {"accounts": ["dbid:mine"]}, "delta": {"users": [1]}}`
+	valid := hex.EncodeToString(sign([]byte("secret"), []byte(body)))
+
+	tests := []struct {
+		name       string
+		accountID  string
+		signature  string
+		wantStatus int
+		wantNotify bool
+	}{
+		{name: "valid", signature: valid, wantStatus: http.StatusOK, wantNotify: true},
+		{name: "own account", accountID: "dbid:mine", signature: valid, wantStatus: http.StatusOK, wantNotify: true},
+		{name: "other account", accountID: "dbid:other", signature: valid, wantStatus: http.StatusOK},
+		{name: "wrong signature", signature: hex.EncodeToString(sign([]byte("other"), []byte(body))), wantStatus: http.StatusForbidden},
+		{name: "missing signature", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler("secret", tt.accountID)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+			req.Header.Set(SignatureHeader, tt.signature)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			select {
+			case <-h.Notifications():
+				if !tt.wantNotify {
+					t.Error("unexpected notification")
+				}
+			default:
+				if tt.wantNotify {
+					t.Error("expected notification")
+				}
+			}
+		})
+	}
+}
+
+func TestNotificationsCoalesce(t *testing.T) {
+	body := `{"list_folder": {"accounts": []}}`
+	h := NewHandler("secret", "")
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		req.Header.Set(SignatureHeader, hex.EncodeToString(sign([]byte("secret"), []byte(body))))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	<-h.Notifications()
+	select {
+	case <-h.Notifications():
+		t.Error("expected pending notifications to be coalesced")
+	default:
+	}
+}
@@ -0,0 +1,53 @@
+// Package xattr tags backed-up files with extended attributes holding the
+// Dropbox revision and content hash they were downloaded at, so other tools
+// and Finder-based workflows can tell where a file came from.
+package xattr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Attribute names. On Linux they are stored in the "user." namespace.
+const (
+	RevName  = "com.behrconsulting.dbxbackup.rev"
+	HashName = "com.behrconsulting.dbxbackup.content_hash"
+)
+
+// ErrUnsupported is returned where extended attributes can't be written,
+// either on this platform or on the file system of the backup directory
+var ErrUnsupported = errors.New("extended attributes are not supported")
+
+// Tag records the Dropbox revision and content hash of a file. The hash is
+// left out if empty, e.g. for exported files.
+func Tag(path, rev, hash string) error {
+	if err := set(path, RevName, rev); err != nil {
+		return fmt.Errorf("failed to tag %s: %w", path, err)
+	}
+	if hash == "" {
+		return nil
+	}
+	if err := set(path, HashName, hash); err != nil {
+		return fmt.Errorf("failed to tag %s: %w", path, err)
+	}
+	return nil
+}
+
+// Rev returns the Dropbox revision a file was tagged with, or "" if it has none
+func Rev(path string) (string, error) {
+	rev, err := get(path, RevName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tag of %s: %w", path, err)
+	}
+	return rev, nil
+}
+
+// Get returns the value of an extended attribute of a file, e.g. one set by
+// another program, or "" if it has none
+func Get(path, name string) (string, error) {
+	value, err := get(path, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read attribute %s of %s: %w", name, path, err)
+	}
+	return value, nil
+}
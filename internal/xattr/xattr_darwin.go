@@ -0,0 +1,37 @@
+package xattr
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// xattrCommand is the macOS tool for extended attributes; package syscall
+// has no xattr calls on macOS
+var xattrCommand = "/usr/bin/xattr"
+
+func set(path, name, value string) error {
+	out, err := exec.Command(xattrCommand, "-w", name, value, path).CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), "Operation not supported") {
+			return ErrUnsupported
+		}
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func get(path, name string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(xattrCommand, "-p", name, path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "No such xattr") {
+			return "", nil
+		}
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
@@ -0,0 +1,34 @@
+package xattr
+
+import (
+	"errors"
+	"syscall"
+)
+
+// namespace is required for attributes set by unprivileged users
+const namespace = "user."
+
+func set(path, name, value string) error {
+	err := syscall.Setxattr(path, namespace+name, []byte(value), 0)
+	if errors.Is(err, syscall.ENOTSUP) {
+		return ErrUnsupported
+	}
+	return err
+}
+
+func get(path, name string) (string, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := syscall.Getxattr(path, namespace+name, buf)
+		switch {
+		case errors.Is(err, syscall.ENODATA), errors.Is(err, syscall.ENOTSUP):
+			return "", nil
+		case errors.Is(err, syscall.ERANGE):
+			buf = make([]byte, 2*len(buf))
+			continue
+		case err != nil:
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+}
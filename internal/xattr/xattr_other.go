@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package xattr
+
+func set(path, name, value string) error {
+	return ErrUnsupported
+}
+
+func get(path, name string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,39 @@
+package xattr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTagAndRev(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rev, err := Rev(path)
+	if err != nil {
+		t.Fatalf("Rev() of untagged file error = %v", err)
+	}
+	if rev != "" {
+		t.Errorf("Rev() of untagged file = %q, want empty", rev)
+	}
+
+	err = Tag(path, "015f2a3b4c5d", "abc123")
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("extended attributes not supported here")
+	}
+	if err != nil {
+		t.Fatalf("Tag() error = %v", err)
+	}
+
+	rev, err = Rev(path)
+	if err != nil {
+		t.Fatalf("Rev() error = %v", err)
+	}
+	if rev != "015f2a3b4c5d" {
+		t.Errorf("Rev() = %q, want %q", rev, "015f2a3b4c5d")
+	}
+}
@@ -2,13 +2,42 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"create-dropbox-backup-folder/internal/alert"
+	"create-dropbox-backup-folder/internal/archive"
 	"create-dropbox-backup-folder/internal/backup"
+	"create-dropbox-backup-folder/internal/budget"
 	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/desktop"
 	"create-dropbox-backup-folder/internal/dropbox"
+	"create-dropbox-backup-folder/internal/filetypes"
+	"create-dropbox-backup-folder/internal/filter"
+	"create-dropbox-backup-folder/internal/install"
+	"create-dropbox-backup-folder/internal/listing"
+	"create-dropbox-backup-folder/internal/logbatch"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/restore"
+	"create-dropbox-backup-folder/internal/signing"
+	"create-dropbox-backup-folder/internal/syncimport"
+	"create-dropbox-backup-folder/internal/transfer"
+	"create-dropbox-backup-folder/internal/ui"
+	"create-dropbox-backup-folder/internal/watch"
+	"create-dropbox-backup-folder/internal/webhook"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/oauth2"
@@ -20,110 +49,2045 @@ var (
 	date    = "unknown"
 )
 
+// exitDiskFull is the exit code of a backup stopped because the backup disk
+// is full, exitBudget of one stopped by --api-call-budget, exitUnhealthy and
+// exitNoRuns those of status for a backup that needs attention or never ran;
+// other failures exit with 1
+const (
+	exitDiskFull  = 3
+	exitBudget    = 4
+	exitUnhealthy = 5
+	exitNoRuns    = 6
+)
+
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	closeLogging()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode returns the process exit code for a failed command
+func exitCode(err error) int {
+	var diskFull *backup.DiskFullError
+	if errors.As(err, &diskFull) {
+		return exitDiskFull
+	}
+	var spent *backup.BudgetError
+	if errors.As(err, &spent) {
+		return exitBudget
+	}
+	switch {
+	case errors.Is(err, errUnhealthy):
+		return exitUnhealthy
+	case errors.Is(err, errNoRuns):
+		return exitNoRuns
+	}
+	return 1
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "create-dropbox-backup-folder",
+	Short: "A tool to backup Dropbox files to a local directory",
+	Long: `create-dropbox-backup-folder is a command-line tool that authenticates with
+Dropbox using OAuth2 and downloads all files and folders to a specified
+local directory, preserving the folder structure.
+
+The tool supports incremental backups, exclusion patterns, and configurable
+logging levels. It handles authentication securely and efficiently manages
+API calls to avoid rate limits.`,
+	PersistentPreRunE: setupOutput,
+	RunE:              runBackup,
+}
+
+// out formats what commands print, as selected with --output; set before
+// any command runs
+var out output.Formatter
+
+var (
+	flagDelete      bool
+	flagMirrors     []string
+	flagPostRun     string
+	flagColdDir     string
+	flagColdAfter   int
+	flagExclude     []string
+	flagLogLevel    string
+	flagBackupDir   string
+	flagConfigFile  string
+	flagCount       bool
+	flagSize        bool
+	flagExplain     bool
+	flagUnsupported bool
+	flagFolderStats bool
+	flagForceSwitch bool
+	flagReadOnly    bool
+	flagTitle       bool
+	flagNotify      bool
+	flagNoProgress  bool
+	flagAlertHook   string
+	flagAlertFails  int
+	flagReportDir   string
+	flagReportURL   string
+	flagChoose      bool
+	flagOutput      string
+	flagUnits       string
+	flagNoInput     bool
+	flagProfile     string
+	flagBwLimit     string
+	flagBwSchedule  string
+	flagMetered     bool
+	flagInterface   string
+	flagOutage      time.Duration
+	flagAPITimeout  time.Duration
+	flagSidecar     bool
+	flagChaos       string
+	flagMetrics     string
+	flagCompare     string
+	flagExport      []string
+
+	flagTeamSpace    bool
+	flagLayout       string
+	flagAccountMeta  bool
+	flagArchive      bool
+	flagSignKey      string
+	flagVerifyAfter  bool
+	flagSpotCheck    int
+	flagAPIBudget    int
+	flagVerify       bool
+	flagRcloneSum    bool
+	flagSearchExt    bool
+	flagFullListing  bool
+	flagPreset       string
+	flagWriteBuffer  string
+	flagSerialWrite  bool
+	flagFileTimeout  time.Duration
+	flagStallTimeout time.Duration
+	flagClockSkew    time.Duration
+	flagLargeFiles   string
+	flagMaxFileSize  string
+	flagMaxMemory    string
+	flagHardware     string
+	flagHashWorkers  int
+	flagProgressInt  time.Duration
+	flagLogBatch     time.Duration
+	flagOnOversize   string
+	flagLargeConc    int
+	flagHideDotFiles bool
+	flagTagXattr     bool
+	flagStateBackend string
+	flagInstanceID   string
+
+	flagInclude    []string
+	flagRemotePath []string
+	flagDryRun     bool
+	flagProgress   bool
+	flagConcurrent int
+
+	flagAuthWrite  bool
+	flagAuthShare  bool
+	flagAuthMeta   bool
+	flagAuthPrint  bool
+	flagOnConflict string
+	flagSince      string
+
+	flagVerifyPercent float64
+	flagVerifyRecent  int
+
+	flagWebhookListen string
+	flagWebhookPath   string
+	flagInterval      time.Duration
+	flagDebounce      time.Duration
+	flagStatusRuns    int
+
+	flagBinDir   string
+	flagSchedule string
+	flagPurge    bool
+
+	flagDropboxDir string
+	flagSyncSave   bool
+
+	flagTypesBy      string
+	flagTypesTop     int
+	flagTypesLargest int
+	flagLargestTop   int
+	flagRecentSince  string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&flagOutput, "output", output.Text, "Output format of command results: text, table, json, json-stream, or quiet")
+	rootCmd.PersistentFlags().StringVar(&flagUnits, "units", output.UnitsJEDEC, "Units of byte sizes: jedec (KB, MB, multiples of 1024), iec (KiB, MiB, multiples of 1024) or si (kB, MB, multiples of 1000)")
+	rootCmd.PersistentFlags().BoolVar(&flagNoInput, "no-input", false, "Fail instead of prompting, for unattended runs without a terminal")
+	rootCmd.PersistentFlags().StringVar(&flagProfile, "profile", "", "Profile to use, e.g. an account with its own settings in the config file (overrides DROPBOX_PROFILE)")
+	addBackupFlags(rootCmd)
+	rootCmd.Flags().BoolVar(&flagChoose, "choose", false, "Interactively choose which top-level folders to back up and save the selection")
+
+	// Add version command
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "version",
+		Short: "Print version information",
+		RunE:  runVersion,
+	})
+
+	// Add auth command for interactive authentication
+	authCmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Authenticate with Dropbox using OAuth2",
+		Long: `Start an interactive OAuth2 authentication flow with Dropbox.
+This will open your web browser and guide you through the authentication process.
+After successful authentication, the tokens are saved in the keychain of
+the OS, or in a file readable only by you where none is available
+(DROPBOX_TOKEN_STORE). The backup command loads them from there and keeps
+them refreshed.`,
+		RunE: runAuth,
+	}
+	authCmd.Flags().BoolVar(&flagAuthWrite, "write", false, "Also request write access, needed by the restore command")
+	authCmd.Flags().BoolVar(&flagAuthShare, "sharing", false, "Also request sharing read access, needed by --layout shared")
+	authCmd.Flags().BoolVar(&flagAuthMeta, "file-requests", false, "Also request file request read access, needed by --account-metadata")
+	authCmd.Flags().BoolVar(&flagAuthPrint, "print", false, "Print the tokens to add to your .env file instead of storing them")
+	authCmd.Flags().StringVar(&flagConfigFile, "config", "", "YAML or TOML configuration file holding the credentials of --profile")
+	rootCmd.AddCommand(authCmd)
+
+	// Add setup command guiding new users through the configuration
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "setup",
+		Short: "Set up backups step by step: app credentials, authentication, backup folder and folders to back up",
+		Long: `Walk through the first-time configuration interactively: enter the Dropbox
+app key and secret, authenticate in the browser, choose the backup directory
+and the top-level folders to back up. The answers are saved to the
+environment file in the configuration directory, which every command loads,
+and can be changed by running setup again.`,
+		Args: cobra.NoArgs,
+		RunE: runSetup,
+	})
+
+	// Add restore command to upload a backup back to Dropbox
+	restoreCmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Upload a local backup back to Dropbox",
+		Long: `Upload the files in the backup directory back to Dropbox.
+Files that changed on Dropbox since they were backed up are conflicts and are
+handled according to --on-conflict. Requires tokens from 'auth --write'.`,
+		RunE: runRestore,
+	}
+	restoreCmd.Flags().StringVar(&flagBackupDir, "backup-dir", "", "Backup directory to restore from (overrides DROPBOX_BACKUP_FOLDER)")
+	addTransferFlags(restoreCmd)
+	restoreCmd.Flags().StringVar(&flagSince, "since", "", "Only restore files that differ on Dropbox from this snapshot directory or date (e.g. 2024-03-01)")
+	restoreCmd.Flags().StringVar(&flagOnConflict, "on-conflict", "skip", "What to do with files changed on Dropbox since the backup (skip, overwrite, rename, interactive)")
+	rootCmd.AddCommand(restoreCmd)
+
+	// Add verify-archive command to check an archive for tampering
+	verifyCmd := &cobra.Command{
+		Use:   "verify-archive",
+		Short: "Check an archive-mode backup for changes since it was written",
+		Long: `Check the hash chain written by --archive runs, that the manifest is the one
+recorded by the last run, and that every backed-up file still has the content
+recorded in the manifest. Needs no Dropbox access.`,
+		RunE: runVerifyArchive,
+	}
+	verifyCmd.Flags().StringVar(&flagBackupDir, "backup-dir", "", "Backup directory to verify (overrides DROPBOX_BACKUP_FOLDER)")
+	verifyCmd.Flags().Float64Var(&flagVerifyPercent, "verify-percent", 100, "Only check this percentage of the files, chosen at random")
+	verifyCmd.Flags().IntVar(&flagVerifyRecent, "verify-recent-days", 0, "Also check every file modified on Dropbox in this many days, when sampling")
+	verifyCmd.Flags().IntVar(&flagConcurrent, "concurrency", 0, "Number of files checked in parallel (default 5)")
+	verifyCmd.Flags().BoolVar(&flagProgress, "progress", false, "Print a line for every checked file")
+	verifyCmd.Flags().StringVar(&flagCompare, "compare", "", "How to decide a file is unchanged: hash (default), mtime,size, or rev, as a backup would")
+	rootCmd.AddCommand(verifyCmd)
+
+	// Add filter command to debug include and exclude patterns
+	filterCmd := &cobra.Command{
+		Use:   "filter",
+		Short: "Inspect the include and exclude patterns",
+	}
+	filterTestCmd := &cobra.Command{
+		Use:   "test <path>...",
+		Short: "Show whether Dropbox paths are backed up and which pattern decided it",
+		Long: `Evaluate the --include and --exclude patterns against the given Dropbox paths
+(e.g. /Documents/report.pdf) the way a backup does, and print for each path
+whether it is backed up and which pattern matched. Needs no Dropbox access.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runFilterTest,
+	}
+	filterTestCmd.Flags().StringSliceVar(&flagExclude, "exclude", []string{}, "Exclude patterns (e.g., '*.tmp', 'temp/', '@filename')")
+	filterTestCmd.Flags().StringSliceVar(&flagInclude, "include", []string{}, "Only transfer paths matching these patterns (same syntax as --exclude)")
+	filterCmd.AddCommand(filterTestCmd)
+	rootCmd.AddCommand(filterCmd)
+
+	// Add config command to check a config file before scheduling backups
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	configValidateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file, environment and flags without backing up",
+		Long: `Load the configuration the way a backup with the same flags does, from the
+--config file (or DROPBOX_CONFIG_FILE), the environment and the flags, and
+report the first problem or a summary of the settings. Needs no Dropbox access.`,
+		Args: cobra.NoArgs,
+		RunE: runConfigValidate,
+	}
+	addBackupFlags(configValidateCmd)
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+
+	// Add report command to break down what a backup holds
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize what a backup holds",
+	}
+	reportTypesCmd := &cobra.Command{
+		Use:   "types",
+		Short: "Show the files of a backup by extension or class of content",
+		Long: `Group the files recorded in the manifest of a backup directory by extension
+(e.g. .jpg) or class of content (e.g. image, video), with their count, total
+size and largest files, to see what takes up the space and which --exclude
+patterns are worth adding. Needs no Dropbox access.`,
+		RunE: runReportTypes,
+	}
+	reportTypesCmd.Flags().StringVar(&flagBackupDir, "backup-dir", "", "Backup directory to report on (overrides DROPBOX_BACKUP_FOLDER)")
+	reportTypesCmd.Flags().StringVar(&flagTypesBy, "by", filetypes.ByExtension, "Group files by extension or class")
+	reportTypesCmd.Flags().IntVar(&flagTypesTop, "top", 20, "Number of groups to show, largest first (0 for all)")
+	reportTypesCmd.Flags().IntVar(&flagTypesLargest, "largest", 3, "Number of largest files to show per group")
+	reportCmd.AddCommand(reportTypesCmd)
+	reportLargestCmd := &cobra.Command{
+		Use:   "largest",
+		Short: "Show the largest files of a backup",
+		Long: `List the largest files recorded in the manifest of a backup directory, to see
+what dominates the backup. Needs no Dropbox access.`,
+		RunE: runReportLargest,
+	}
+	reportLargestCmd.Flags().StringVar(&flagBackupDir, "backup-dir", "", "Backup directory to report on (overrides DROPBOX_BACKUP_FOLDER)")
+	reportLargestCmd.Flags().IntVar(&flagLargestTop, "top", 50, "Number of files to show (0 for all)")
+	reportCmd.AddCommand(reportLargestCmd)
+	reportRecentCmd := &cobra.Command{
+		Use:   "recent",
+		Short: "Show the files of a backup changed lately",
+		Long: `List the files recorded in the manifest of a backup directory that were
+modified on Dropbox recently, most recent first. Needs no Dropbox access.`,
+		RunE: runReportRecent,
+	}
+	reportRecentCmd.Flags().StringVar(&flagBackupDir, "backup-dir", "", "Backup directory to report on (overrides DROPBOX_BACKUP_FOLDER)")
+	reportRecentCmd.Flags().StringVar(&flagRecentSince, "since", "7d", "Show files modified within this time (e.g. 7d, 2w, 36h) or since this date (YYYY-MM-DD)")
+	reportCmd.AddCommand(reportRecentCmd)
+	rootCmd.AddCommand(reportCmd)
+
+	// Add status command to check on recent and scheduled backups
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the outcome of recent backups and when the next one is due",
+		Long: `Show the outcome of the latest backup runs and, while a daemon backs up on an
+--interval, when the next run is due and whether the last run finished within
+its interval. Exits with code 5 if the backup needs attention and 6 if it
+never ran, so it can be used as a simple monitor. Needs no Dropbox access.`,
+		RunE: runHealth,
+		// The report already tells what is wrong
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	statusCmd.Flags().IntVar(&flagStatusRuns, "runs", 5, "Number of recent runs to show")
+	rootCmd.AddCommand(statusCmd)
+
+	// Add daemon command to back up whenever Dropbox reports changes
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Keep running and back up whenever Dropbox reports changes",
+		Long: `Run a backup, then keep running and back up again whenever Dropbox sends a
+change notification to the webhook endpoint, or when --interval elapses.
+Register https://<your-host><webhook-path> as the webhook URI of your Dropbox app.`,
+		RunE: runDaemon,
+	}
+	addBackupFlags(daemonCmd)
+	daemonCmd.Flags().StringVar(&flagWebhookListen, "webhook-listen", "", "Address to receive Dropbox webhook notifications on (e.g. :8080)")
+	daemonCmd.Flags().StringVar(&flagWebhookPath, "webhook-path", "/webhook", "URL path of the webhook endpoint")
+	daemonCmd.Flags().DurationVar(&flagInterval, "interval", time.Hour, "Also back up on this interval in case notifications are missed (0 disables)")
+	rootCmd.AddCommand(daemonCmd)
+
+	// Add watch command to back up changes as Dropbox reports them
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Keep running and back up changes as they happen",
+		Long: `Run a backup, then keep running: wait for changes with Dropbox longpolls on
+the listing cursors of the backup and back up again once they arrive. Unlike
+daemon, this needs no webhook endpoint reachable from the internet. Stops
+gracefully on SIGTERM or Ctrl+C.`,
+		RunE: runWatch,
+	}
+	addBackupFlags(watchCmd)
+	watchCmd.Flags().DurationVar(&flagDebounce, "debounce", 10*time.Second, "How long to wait after a change before backing up, so a burst of changes is backed up by one run")
+	rootCmd.AddCommand(watchCmd)
+
+	// Add import-sync command to adopt the desktop client's exclusions
+	importSyncCmd := &cobra.Command{
+		Use:   "import-sync",
+		Short: "Exclude the folders the Dropbox desktop client doesn't sync",
+		Long: `Read which folders the Dropbox desktop client on this computer leaves out of
+the local Dropbox folder, excluded by selective sync (as listed by the dropbox
+command line tool on Linux) or marked as ignored, and print them as exclude
+patterns. With --save they are stored in the settings file and apply to later
+backups in addition to --exclude; importing again replaces them.`,
+		RunE: runImportSync,
+	}
+	importSyncCmd.Flags().StringVar(&flagDropboxDir, "dropbox-dir", "", "Local Dropbox folder (default: that of the personal account linked to the desktop client)")
+	importSyncCmd.Flags().BoolVar(&flagSyncSave, "save", false, "Save the exclusions for later backups")
+	rootCmd.AddCommand(importSyncCmd)
+
+	// Add install and uninstall commands for scheduled backups
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the program for all users and optionally schedule a daily backup",
+		Long: `Copy the program to a standard location (/usr/local/bin, or Program Files on
+Windows), create the configuration directory with an environment file holding
+the Dropbox credentials of scheduled backups, and with --schedule register a
+daily backup with cron, launchd or the Windows Task Scheduler. Installing to
+the default location usually needs administrator rights.`,
+		RunE: runInstall,
+	}
+	installCmd.Flags().StringVar(&flagBinDir, "bin-dir", "", "Directory to install the program to (default: "+install.DefaultBinDir()+")")
+	installCmd.Flags().StringVar(&flagSchedule, "schedule", "", "Daily time to back up at, e.g. 02:30 (default: no schedule)")
+	rootCmd.AddCommand(installCmd)
+
+	uninstallCmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the scheduled backup and the installed program",
+		Long: `Remove the daily backup from the scheduler and the program installed by
+install. The configuration directory, with the credentials and the log of
+scheduled backups, is kept unless --purge is given; backups are never removed.`,
+		RunE: runUninstall,
+	}
+	uninstallCmd.Flags().StringVar(&flagBinDir, "bin-dir", "", "Directory the program was installed to (default: "+install.DefaultBinDir()+")")
+	uninstallCmd.Flags().BoolVar(&flagPurge, "purge", false, "Also remove the configuration directory, including saved settings and credentials")
+	rootCmd.AddCommand(uninstallCmd)
+}
+
+// addBackupFlags registers the flags of a backup run
+func addBackupFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&flagDelete, "delete", false, "Delete local files that don't exist in Dropbox")
+	cmd.Flags().StringSliceVar(&flagMirrors, "mirror", []string{}, "Also write the backup to this directory, e.g. a NAS share (can be used multiple times)")
+	cmd.Flags().StringVar(&flagColdDir, "cold-dir", "", "Move files not modified for --cold-after-days to this directory, e.g. on cheaper storage")
+	cmd.Flags().IntVar(&flagColdAfter, "cold-after-days", 0, "Age in days after which files are moved to --cold-dir")
+	cmd.Flags().StringVar(&flagPostRun, "post-run-command", "", "Shell command run after a successful backup with the path of a file listing the changed files, e.g. to replicate them with rclone")
+	cmd.Flags().BoolVar(&flagReadOnly, "read-only", false, "Refuse to run if the token can change the Dropbox account")
+	cmd.Flags().BoolVar(&flagForceSwitch, "force-account-switch", false, "Back up into a directory holding another Dropbox account's files")
+	cmd.Flags().StringVar(&flagBackupDir, "backup-dir", "", "Custom backup directory (overrides DROPBOX_BACKUP_FOLDER, supports {date}, {time}, {account_email}, {profile})")
+	cmd.Flags().StringVar(&flagConfigFile, "config", "", "YAML or TOML configuration file (overridden by the environment and flags)")
+	cmd.Flags().BoolVar(&flagCount, "count", false, "Display total number of files and directories processed")
+	cmd.Flags().BoolVar(&flagSize, "size", false, "Display total size of files processed")
+	cmd.Flags().StringVar(&flagPreset, "preset", "", "Back up a named bundle of folders and filters, e.g. documents or photos (see the settings file for your own)")
+	cmd.Flags().BoolVar(&flagExplain, "explain-filters", false, "List how many files and bytes each include/exclude rule excluded")
+	cmd.Flags().BoolVar(&flagTitle, "title", false, "Show the percent complete and time left in the terminal title")
+	cmd.Flags().BoolVar(&flagNotify, "notify", false, "Show a desktop notification when the backup ends")
+	cmd.Flags().BoolVar(&flagNoProgress, "no-progress", false, "Report download progress in a line per --progress-interval instead of a live display on the terminal")
+	cmd.Flags().StringVar(&flagAlertHook, "alert-webhook", "", "URL to post the outcome of every run to as JSON, with the run ID, report link and failed paths")
+	cmd.Flags().IntVar(&flagAlertFails, "alert-failures", 0, "Number of failed paths listed in alerts (default 10)")
+	cmd.Flags().StringVar(&flagReportDir, "report-dir", "", "Directory to keep the full JSON report of every run in, named by run ID")
+	cmd.Flags().StringVar(&flagReportURL, "report-url", "", "URL the --report-dir is served at, for report links in alerts")
+	cmd.Flags().BoolVar(&flagUnsupported, "list-unsupported", false, "List the files Dropbox can't serve, e.g. some cloud docs, and why")
+	cmd.Flags().BoolVar(&flagFolderStats, "folder-stats", false, "List the files, bytes and time downloaded for each top-level folder")
+	addTransferFlags(cmd)
+	cmd.Flags().StringVar(&flagMetrics, "metrics-file", "", "Write run results and API latency metrics in Prometheus text format to this file")
+	cmd.Flags().StringSliceVar(&flagExport, "export-format", []string{}, "Export format for export-only files by extension, e.g. paper=markdown (can be used multiple times)")
+	cmd.Flags().BoolVar(&flagTeamSpace, "team-space", false, "Also back up the team space of team accounts, below a separate ns-<id> folder")
+	cmd.Flags().StringVar(&flagLayout, "layout", "", "Where to store shared folders locally: mounted (default) or shared (grouped by owner under /shared)")
+	cmd.Flags().BoolVar(&flagArchive, "archive", false, "Archive mode: make backed-up files read-only, never overwrite or delete them, and chain run manifests")
+	cmd.Flags().StringVar(&flagWriteBuffer, "write-buffer", "", "Size of the buffer local files are written in, e.g. 4M; files up to this size are written in one go (default 32K)")
+	cmd.Flags().DurationVar(&flagFileTimeout, "file-timeout", 0, "Timeout for downloading a single file, e.g. 2h (0 disables)")
+	cmd.Flags().DurationVar(&flagStallTimeout, "stall-timeout", 5*time.Minute, "Abort and retry a download that receives no data for this long (0 disables)")
+	cmd.Flags().DurationVar(&flagClockSkew, "max-clock-skew", time.Minute, "Warn and correct modification time comparisons if the local clock is off from Dropbox's by more than this (0 disables)")
+	cmd.Flags().StringVar(&flagLargeFiles, "large-file-threshold", "", "Download files of at least this size (e.g. 1G) in a separate, smaller lane so they don't occupy every worker")
+	cmd.Flags().IntVar(&flagLargeConc, "large-file-concurrency", 0, "Number of parallel downloads in the large file lane (default 2)")
+	cmd.Flags().StringVar(&flagMaxFileSize, "max-file-size", "", "Largest file the backup file system can store, e.g. 4G (default: detected, e.g. 4G on FAT32)")
+	cmd.Flags().IntVar(&flagHashWorkers, "hash-workers", 0, "Number of files hashed at once by --verify-after (default: as many as --concurrency)")
+	cmd.Flags().DurationVar(&flagProgressInt, "progress-interval", 10*time.Second, "How often progress is reported while listing, downloading or deleting")
+	cmd.Flags().DurationVar(&flagLogBatch, "log-batch", 0, "Summarize per-file info logs once per interval, e.g. 30s, instead of a line per file")
+	cmd.Flags().StringVar(&flagOnOversize, "on-oversize", "", "What to do with files larger than that: skip (default), fail or download")
+	cmd.Flags().BoolVar(&flagSerialWrite, "serialize-writes", false, "Write one buffer at a time per disk while downloads stay parallel, for spinning disks")
+	cmd.Flags().BoolVar(&flagHideDotFiles, "hide-dotfiles", false, "On Windows, mark downloaded files whose name starts with a dot hidden")
+	cmd.Flags().BoolVar(&flagTagXattr, "xattr", false, "Tag downloaded files with extended attributes holding their Dropbox revision and content hash (macOS, Linux)")
+	cmd.Flags().BoolVar(&flagVerifyAfter, "verify-after", false, "Re-read the files downloaded in this run from disk and check them against the Dropbox content hash")
+	cmd.Flags().IntVar(&flagAPIBudget, "api-call-budget", 0, "Most Dropbox API calls to make with the app key in any 24 hours, counting earlier runs; downloading stops when it is reached (0 disables)")
+	cmd.Flags().IntVar(&flagSpotCheck, "spot-check", 0, "Check this many randomly chosen skipped files against fresh Dropbox metadata and their content hash after the run")
+	cmd.Flags().BoolVar(&flagVerify, "verify", false, "Hash existing local files and download those that don't match the Dropbox content hash again")
+	cmd.Flags().BoolVar(&flagRcloneSum, "rclone-sum", false, "Write the content hashes of backed-up files to a sum file rclone can check the backup or a mirror against")
+	cmd.Flags().BoolVar(&flagSearchExt, "search-extensions", false, "Find the files of --include patterns like '*.pdf' with the Dropbox search index instead of listing every folder")
+	cmd.Flags().BoolVar(&flagFullListing, "full-listing", false, "List the whole account instead of only the changes since the last run")
+	cmd.Flags().StringVar(&flagSignKey, "sign-key", "", "GPG key to sign the manifest with after every run")
+	cmd.Flags().BoolVar(&flagAccountMeta, "account-metadata", false, "Also export file requests, connected apps and sharing policies to a JSON bundle in the backup root")
+	cmd.Flags().StringVar(&flagStateBackend, "state-backend", "", "Directory or http(s) URL shared with other instances backing up the same account")
+	cmd.Flags().StringVar(&flagInstanceID, "instance-id", "", "Name of this instance in the shared state (default hostname)")
+}
+
+// backupOptions returns the configuration options set by addBackupFlags
+func backupOptions(cmd *cobra.Command) config.Options {
+	opts := transferOptions(cmd)
+	opts.ConfigFile = flagConfigFile
+	opts.Profile = flagProfile
+	opts.Delete = flagDelete
+	opts.Mirrors = flagMirrors
+	opts.PostRunCommand = flagPostRun
+	opts.ColdDir = flagColdDir
+	opts.ColdAfterDays = flagColdAfter
+	opts.ShowCount = flagCount
+	opts.ShowSize = flagSize
+	opts.ExplainFilters = flagExplain
+	opts.ListUnsupported = flagUnsupported
+	opts.FolderStats = flagFolderStats
+	opts.ForceAccount = flagForceSwitch
+	opts.ReadOnly = flagReadOnly
+	opts.TerminalTitle = flagTitle
+	opts.Notify = flagNotify
+	opts.NoProgress = flagNoProgress
+	opts.AlertWebhook = flagAlertHook
+	opts.AlertFailures = flagAlertFails
+	opts.ReportDir = flagReportDir
+	opts.ReportURL = flagReportURL
+	opts.Preset = flagPreset
+	opts.MetricsFile = flagMetrics
+	opts.ExportFormats = flagExport
+	opts.TeamSpace = flagTeamSpace
+	opts.Layout = flagLayout
+	opts.AccountMetadata = flagAccountMeta
+	opts.Archive = flagArchive
+	opts.SignKey = flagSignKey
+	opts.VerifyAfter = flagVerifyAfter
+	opts.SpotCheck = flagSpotCheck
+	opts.APICallBudget = flagAPIBudget
+	opts.Verify = flagVerify
+	opts.RcloneSum = flagRcloneSum
+	opts.SearchExtensions = flagSearchExt
+	opts.FullListing = flagFullListing
+	opts.WriteBuffer = flagWriteBuffer
+	opts.SerializeWrites = flagSerialWrite
+	opts.LargeFileThreshold = flagLargeFiles
+	opts.LargeFileConcurrency = flagLargeConc
+	opts.MaxFileSize = flagMaxFileSize
+	opts.OnOversize = flagOnOversize
+	opts.HashWorkers = flagHashWorkers
+	if cmd.Flags().Changed("progress-interval") {
+		opts.ProgressInterval = &flagProgressInt
+	}
+	if cmd.Flags().Changed("log-batch") {
+		opts.LogBatch = &flagLogBatch
+	}
+	opts.HideDotFiles = flagHideDotFiles
+	opts.TagXattr = flagTagXattr
+	if cmd.Flags().Changed("file-timeout") {
+		opts.FileTimeout = &flagFileTimeout
+	}
+	if cmd.Flags().Changed("stall-timeout") {
+		opts.StallTimeout = &flagStallTimeout
+	}
+	if cmd.Flags().Changed("max-clock-skew") {
+		opts.MaxClockSkew = &flagClockSkew
+	}
+	opts.StateBackend = flagStateBackend
+	opts.InstanceID = flagInstanceID
+	return opts
+}
+
+// addTransferFlags registers the flags shared by backup and restore
+func addTransferFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&flagExclude, "exclude", []string{}, "Exclude patterns (e.g., '*.tmp', 'temp/', '@filename')")
+	cmd.Flags().StringSliceVar(&flagInclude, "include", []string{}, "Only transfer paths matching these patterns (same syntax as --exclude)")
+	cmd.Flags().StringArrayVar(&flagRemotePath, "remote-path", []string{}, "Only back up this Dropbox folder, e.g. /Photos (can be used multiple times)")
+	cmd.Flags().StringVar(&flagLogLevel, "loglevel", "error", "Log level (debug, info, warn, error)")
+	cmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Show what would be transferred or deleted without changing anything")
+	cmd.Flags().BoolVar(&flagProgress, "progress", false, "Print a line for every finished transfer")
+	cmd.Flags().IntVar(&flagConcurrent, "concurrency", 0, "Number of parallel transfers (default 5)")
+	cmd.Flags().StringVar(&flagBwLimit, "bwlimit", "", "Bandwidth limit for transfers (e.g. 512K, 2M, 1G)")
+	cmd.Flags().StringVar(&flagBwSchedule, "bwlimit-schedule", "", "Time-of-day bandwidth windows (e.g. '09:00-18:00=2M,22:00-06:00=0')")
+	cmd.Flags().BoolVar(&flagMetered, "pause-on-metered", false, "Pause transfers while the system reports a metered connection")
+	cmd.Flags().StringVar(&flagInterface, "require-interface", "", "Pause transfers while this network interface is down (e.g. eth0)")
+	cmd.Flags().DurationVar(&flagOutage, "outage-timeout", 30*time.Minute, "How long to wait for the network to return after an outage before failing (0 disables)")
+	cmd.Flags().DurationVar(&flagAPITimeout, "api-timeout", time.Minute, "Timeout for each metadata or listing API call (0 disables)")
+	cmd.Flags().StringVar(&flagHardware, "profile-hardware", "", "Tune the settings left at their defaults for a class of device: low (small ARM NAS)")
+	cmd.Flags().StringVar(&flagMaxMemory, "max-memory", "", "Memory budget, e.g. 512M: bounds parallel transfers, queues, hash workers and listing pages to stay within it")
+	cmd.Flags().BoolVar(&flagSidecar, "sidecar", false, "Keep file modification times, hashes and revisions in a metadata file per directory, for targets that lose mtimes")
+	cmd.Flags().StringVar(&flagCompare, "compare", "", "How to decide a local file is up to date: mtime,size (default), hash, or rev")
+	// Failure injection is for rehearsals, not everyday use
+	cmd.Flags().StringVar(&flagChaos, "chaos", "", "Inject failures into Dropbox requests, e.g. server_errors=0.05,rate_limits=0.02,download_errors=0.1,slow=0.1,slow_delay=5s")
+	cmd.Flags().MarkHidden("chaos")
+}
+
+// transferOptions returns the configuration options set by addTransferFlags
+func transferOptions(cmd *cobra.Command) config.Options {
+	opts := config.Options{
+		BackupDir:   flagBackupDir,
+		LogLevel:    flagLogLevel,
+		Exclude:     flagExclude,
+		Include:     flagInclude,
+		RemotePaths: flagRemotePath,
+		DryRun:      flagDryRun,
+		Progress:    flagProgress,
+		Concurrency: flagConcurrent,
+
+		BandwidthLimit:    flagBwLimit,
+		BandwidthSchedule: flagBwSchedule,
+		PauseOnMetered:    flagMetered,
+		RequireInterface:  flagInterface,
+		Sidecar:           flagSidecar,
+		Compare:           flagCompare,
+		MaxMemory:         flagMaxMemory,
+		HardwareProfile:   flagHardware,
+		Chaos:             flagChaos,
+	}
+	if cmd.Flags().Changed("outage-timeout") {
+		opts.OutageTimeout = &flagOutage
+	}
+	if cmd.Flags().Changed("api-timeout") {
+		opts.APITimeout = &flagAPITimeout
+	}
+	return opts
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	if flagChoose {
+		if err := requireInput("--choose"); err != nil {
+			return err
+		}
+	}
+
+	// Parse and validate configuration
+	cfg, err := config.Load(backupOptions(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Setup logging
+	setupLogging(cfg.LogLevel, cfg.LogBatch)
+	applyMemoryLimit(cfg)
+	if err := dropbox.SetEndpoints(cfg.Endpoints()); err != nil {
+		return err
+	}
+
+	slog.Info("Starting Dropbox backup",
+		slog.String("backup_dir", cfg.BackupDir),
+		slog.String("log_level", cfg.LogLevel),
+		slog.Bool("delete_enabled", cfg.Delete),
+		slog.Int("exclude_patterns", len(cfg.Exclude)),
+	)
+	if cfg.Chaos != "" {
+		out.Message("🧪 Chaos mode: injecting failures into Dropbox requests (%s)", cfg.Chaos)
+	}
+
+	// Create backup engine
+	sharedBudget(cfg)
+	backupEngine, err := backup.New(cfg, out)
+	if err != nil {
+		return fmt.Errorf("failed to create backup engine: %w", err)
+	}
+	streamResults(backupEngine)
+
+	// Cancel the run on Ctrl+C or SIGTERM, stopping transfers where they
+	// are, so interrupted downloads resume next run
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// Let the user pick the folders to include before the run starts
+	if flagChoose {
+		if err := chooseFolders(ctx, backupEngine, cfg, newPrompt()); err != nil {
+			return fmt.Errorf("folder selection failed: %w", err)
+		}
+	}
+
+	// Run backup
+	stopTitle := func() {}
+	if cfg.TerminalTitle {
+		stopTitle = showProgressTitle(ctx, backupEngine)
+	}
+	stopProgress := showProgress(ctx, cfg, backupEngine)
+	stats, err := backupEngine.Run(ctx)
+	stopProgress()
+	stopTitle()
+	saveRun(cfg, stats, err)
+	saveToken(cfg, backupEngine)
+	alertRun(ctx, cfg, stats, err)
+	announceEnd(ctx, cfg, stats, err)
+	if err == nil {
+		slog.Info("Backup completed successfully")
+	}
+	if reportErr := reportBackup(cfg, stats, err); reportErr != nil && err == nil {
+		err = reportErr
+	}
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	policy, err := restore.ParsePolicy(flagOnConflict)
+	if err != nil {
+		return err
+	}
+	if policy == restore.PolicyInteractive {
+		if err := requireInput("--on-conflict interactive"); err != nil {
+			return err
+		}
+	}
+
+	var since *restore.Since
+	if flagSince != "" {
+		if since, err = restore.ParseSince(flagSince); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.Load(transferOptions(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if strings.Contains(cfg.BackupDir, "{") {
+		return fmt.Errorf("backup directory %s contains placeholders; pass the snapshot to restore with --backup-dir", cfg.BackupDir)
+	}
+	if cfg.ReadOnly {
+		return fmt.Errorf("restore needs write access to Dropbox, but read-only mode is set (DROPBOX_READ_ONLY)")
+	}
+
+	setupLogging(cfg.LogLevel, cfg.LogBatch)
+	applyMemoryLimit(cfg)
+	if err := dropbox.SetEndpoints(cfg.Endpoints()); err != nil {
+		return err
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer saveAPIUsage(cfg)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	out.Message("⬆️  Restoring %s to Dropbox...", cfg.BackupDir)
+	if since != nil {
+		out.Message("   Only files changed since %s", since)
+	}
+
+	restorer, err := restore.New(cfg, client, policy, newPrompt(), since, out)
+	if err != nil {
+		return err
+	}
+
+	stats, err := restorer.Run(ctx)
+	if stats != nil {
+		if reportErr := out.Report(restoreReport(stats)); reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	out.Message("✅ Restore completed")
+	return nil
+}
+
+// interruptContext returns a context cancelled by Ctrl+C or SIGTERM, so a
+// command can stop cleanly. Once cancelled, a second signal terminates the
+// process right away, e.g. while it waits for an answer to a prompt.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// restoreReport returns the result of a restore for the output formatters
+func restoreReport(stats *restore.Stats) output.Report {
+	fields := []output.Field{
+		output.F("Files uploaded", fmt.Sprintf("%s (%s)", output.Count(stats.Uploaded), output.Bytes(stats.Bytes))),
+		output.F("Files already up to date", output.Count(stats.Unchanged)),
+	}
+	if stats.Conflicts > 0 {
+		fields = append(fields, output.F("Conflicts", fmt.Sprintf("%s (%s skipped)", output.Count(stats.Conflicts), output.Count(stats.Skipped))))
+	}
+	if stats.Failed > 0 {
+		fields = append(fields, output.F("Files failed", output.Count(stats.Failed)))
+	}
+	return output.Report{Sections: []output.Section{{Fields: fields}}, Data: stats}
+}
+
+func runVerifyArchive(cmd *cobra.Command, args []string) error {
+	backupDir := flagBackupDir
+	if backupDir == "" {
+		backupDir = os.Getenv("DROPBOX_BACKUP_FOLDER")
+	}
+	if backupDir == "" || strings.Contains(backupDir, "{") {
+		return fmt.Errorf("pass the backup directory to verify with --backup-dir")
+	}
+
+	if flagVerifyPercent <= 0 || flagVerifyPercent > 100 {
+		return fmt.Errorf("invalid --verify-percent: %g (must be above 0 and at most 100)", flagVerifyPercent)
+	}
+	switch flagCompare {
+	case "", config.CompareMtimeSize, config.CompareHash, config.CompareRev:
+	default:
+		return fmt.Errorf("invalid compare mode: %s (must be %s, %s, or %s)",
+			flagCompare, config.CompareMtimeSize, config.CompareHash, config.CompareRev)
+	}
+	opts := transfer.Options{Concurrency: flagConcurrent}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 5
+	}
+	if flagProgress {
+		opts.Progress = output.MessageWriter(out)
+	}
+
+	report, err := archive.Verify(cmd.Context(), backupDir, archive.VerifyOptions{
+		Percent:    flagVerifyPercent,
+		RecentDays: flagVerifyRecent,
+		Compare:    flagCompare,
+		Executor:   transfer.New(opts),
+	})
+	if err != nil {
+		return fmt.Errorf("archive verification failed: %w", err)
+	}
+
+	signer, err := signing.Verify(cmd.Context(), manifest.Path(backupDir))
+	if err != nil && !errors.Is(err, signing.ErrNotSigned) {
+		return fmt.Errorf("archive verification failed: %w", err)
+	}
+	if err := out.Report(verifyReport(report, signer)); err != nil {
+		return err
+	}
+	if !report.OK() {
+		return fmt.Errorf("archive verification failed: %d files modified, %d missing", len(report.Modified), len(report.Missing))
+	}
+
+	out.Message("✅ Archive verified")
+	return nil
+}
+
+// verifyReport returns the result of verify-archive for the output
+// formatters; signer is empty if the manifest isn't signed
+func verifyReport(report archive.Report, signer string) output.Report {
+	section := output.Section{
+		Title: fmt.Sprintf("🔏 Archive chain intact (%s runs), %s files verified", output.Count(report.Runs), output.Count(report.Checked)),
+	}
+	if report.Skipped > 0 {
+		section.Fields = append(section.Fields, output.F("Not sampled", output.Count(report.Skipped)+" files"))
+	}
+	if signer == "" {
+		section.Fields = append(section.Fields, output.F("Manifest", "not signed"))
+	} else {
+		section.Fields = append(section.Fields, output.F("Manifest signed by", signer))
+	}
+	for _, path := range report.Modified {
+		section.Fields = append(section.Fields, output.F("Modified", path))
+	}
+	for _, path := range report.Missing {
+		section.Fields = append(section.Fields, output.F("Missing", path))
+	}
+
+	data := struct {
+		archive.Report
+		Signer string `json:"signer,omitempty"`
+	}{report, signer}
+	return output.Report{Sections: []output.Section{section}, Data: data}
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(backupOptions(cmd))
+	if err != nil {
+		return err
+	}
+	if err := cfg.Check(); err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+	return out.Report(configReport(cfg))
+}
+
+// configSummary is the JSON form of a valid configuration
+type configSummary struct {
+	Valid          bool     `json:"valid"`
+	ConfigFile     string   `json:"config_file,omitempty"`
+	BackupDir      string   `json:"backup_dir"`
+	Profile        string   `json:"profile"`
+	RemotePaths    []string `json:"remote_paths,omitempty"`
+	Exclude        []string `json:"exclude,omitempty"`
+	Include        []string `json:"include,omitempty"`
+	MaxConcurrency int      `json:"max_concurrency"`
+	BandwidthLimit string   `json:"bwlimit,omitempty"`
+	LogLevel       string   `json:"log_level"`
+	Delete         bool     `json:"delete"`
+}
+
+// configReport summarizes a valid configuration
+func configReport(cfg *config.Config) output.Report {
+	summary := configSummary{
+		Valid:          true,
+		ConfigFile:     cfg.ConfigFile,
+		BackupDir:      cfg.BackupDir,
+		Profile:        cfg.Profile,
+		RemotePaths:    cfg.RemotePaths,
+		Exclude:        cfg.Exclude,
+		Include:        cfg.Include,
+		MaxConcurrency: cfg.MaxConcurrency,
+		BandwidthLimit: cfg.BandwidthLimit,
+		LogLevel:       cfg.LogLevel,
+		Delete:         cfg.Delete,
+	}
+
+	configFile := cfg.ConfigFile
+	if configFile == "" {
+		configFile = "none"
+	}
+	remotePaths := "whole Dropbox"
+	if len(cfg.RemotePaths) > 0 {
+		remotePaths = strings.Join(cfg.RemotePaths, ", ")
+	}
+	bandwidth := "unlimited"
+	if cfg.BandwidthLimit != "" {
+		bandwidth = cfg.BandwidthLimit
+	}
+	section := output.Section{Title: "✅ Configuration is valid:", Fields: []output.Field{
+		output.F("Config file", configFile),
+		output.F("Backup directory", cfg.BackupDir),
+		output.F("Profile", cfg.Profile),
+		output.F("Back up", remotePaths),
+		output.F("Exclude patterns", len(cfg.Exclude)),
+		output.F("Include patterns", len(cfg.Include)),
+		output.F("Concurrency", cfg.MaxConcurrency),
+		output.F("Bandwidth limit", bandwidth),
+		output.F("Log level", cfg.LogLevel),
+		output.F("Delete", cfg.Delete),
+	}}
+	return output.Report{Sections: []output.Section{section}, Data: summary}
+}
+
+func runFilterTest(cmd *cobra.Command, args []string) error {
+	files, err := filter.ReadPatternFiles(flagInclude, flagExclude)
+	if err != nil {
+		return err
+	}
+	f := filter.Filter{Include: flagInclude, Exclude: flagExclude, Files: files}
+	return out.Report(filterTestReport(f, args))
+}
+
+// filterTestResult is the decision of the filter about one path
+type filterTestResult struct {
+	Path     string `json:"path"`
+	Included bool   `json:"included"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// filterTestReport returns whether the filter covers each of the paths for
+// the output formatters
+func filterTestReport(f filter.Filter, paths []string) output.Report {
+	section := output.Section{Title: "🔍 Filter Test:"}
+	results := make([]filterTestResult, 0, len(paths))
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		decision := f.Explain(path)
+		results = append(results, filterTestResult{Path: path, Included: decision.Allowed, Rule: decision.Rule})
+
+		var verdict string
+		switch {
+		case decision.Allowed && decision.Rule == "":
+			verdict = "included"
+		case decision.Allowed:
+			verdict = "included by " + decision.Rule
+		case decision.Rule == filter.NotIncluded:
+			verdict = "excluded, matches no include pattern"
+		default:
+			verdict = "excluded by " + decision.Rule
+		}
+		section.Fields = append(section.Fields, output.F(path, verdict))
+	}
+	return output.Report{Sections: []output.Section{section}, Data: results}
+}
+
+// reportManifest loads the manifest of the backup directory a report
+// command is run on
+func reportManifest() (*manifest.Manifest, error) {
+	backupDir := flagBackupDir
+	if backupDir == "" {
+		backupDir = os.Getenv("DROPBOX_BACKUP_FOLDER")
+	}
+	if backupDir == "" || strings.Contains(backupDir, "{") {
+		return nil, fmt.Errorf("pass the backup directory to report on with --backup-dir")
+	}
+
+	m, err := manifest.Load(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Len() == 0 {
+		return nil, fmt.Errorf("no backed-up files recorded in %s", backupDir)
+	}
+	return m, nil
+}
+
+func runReportTypes(cmd *cobra.Command, args []string) error {
+	if flagTypesBy != filetypes.ByExtension && flagTypesBy != filetypes.ByClass {
+		return fmt.Errorf("invalid --by: %s (must be %s or %s)", flagTypesBy, filetypes.ByExtension, filetypes.ByClass)
+	}
+	if flagTypesTop < 0 || flagTypesLargest < 0 {
+		return fmt.Errorf("--top and --largest must not be negative")
+	}
+
+	m, err := reportManifest()
+	if err != nil {
+		return err
+	}
+	groups := filetypes.Summarize(m.Entries(), flagTypesBy, flagTypesLargest)
+	return out.Report(typesReport(groups, flagTypesBy, flagTypesTop))
+}
+
+// typesReport returns the files of a backup by type for the output
+// formatters, showing the top largest groups (all if top is 0)
+func typesReport(groups []filetypes.Group, by string, top int) output.Report {
+	var files int
+	var bytes uint64
+	for _, g := range groups {
+		files += g.Files
+		bytes += g.Bytes
+	}
+
+	sections := []output.Section{{
+		Title: fmt.Sprintf("📊 %s files, %s, by %s", output.Count(files), output.Bytes(bytes), by),
+	}}
+	shown := groups
+	if top > 0 && len(shown) > top {
+		shown = shown[:top]
+		sections[0].Fields = append(sections[0].Fields, output.F("Not shown", fmt.Sprintf("%s smaller groups", output.Count(len(groups)-top))))
+	}
+	for _, g := range shown {
+		share := 0.0
+		if bytes > 0 {
+			share = float64(g.Bytes) / float64(bytes) * 100
+		}
+		section := output.Section{
+			Title: fmt.Sprintf("%s: %s files, %s (%.1f%%)", g.Name, output.Count(g.Files), output.Bytes(g.Bytes), share),
+		}
+		for _, f := range g.Largest {
+			section.Fields = append(section.Fields, output.F(f.Path, output.Bytes(f.Size)))
+		}
+		sections = append(sections, section)
+	}
+
+	data := struct {
+		By     string            `json:"by"`
+		Files  int               `json:"files"`
+		Bytes  uint64            `json:"bytes"`
+		Groups []filetypes.Group `json:"groups"`
+	}{by, files, bytes, groups}
+	return output.Report{Sections: sections, Data: data}
+}
+
+func runReportLargest(cmd *cobra.Command, args []string) error {
+	if flagLargestTop < 0 {
+		return fmt.Errorf("--top must not be negative")
+	}
+	m, err := reportManifest()
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("📦 Largest %s of %s files", output.Count(min(flagLargestTop, m.Len())), output.Count(m.Len()))
+	if flagLargestTop == 0 {
+		title = fmt.Sprintf("📦 All %s files by size", output.Count(m.Len()))
+	}
+	return out.Report(filesReport(title, m.Largest(flagLargestTop)))
+}
+
+func runReportRecent(cmd *cobra.Command, args []string) error {
+	since, err := parseSince(flagRecentSince, time.Now())
+	if err != nil {
+		return err
+	}
+	m, err := reportManifest()
+	if err != nil {
+		return err
+	}
+	files := m.ModifiedSince(since)
+	title := fmt.Sprintf("🕘 %s files modified since %s", output.Count(len(files)), since.Format("2006-01-02 15:04"))
+	return out.Report(filesReport(title, files))
+}
+
+// ageUnits are the units of ages parseSince accepts on top of those of
+// time.ParseDuration
+var ageUnits = map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+
+// parseSince reads a --since value: an age such as 7d, 2w or 36h before now,
+// or a date (YYYY-MM-DD) in local time
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if len(value) > 1 {
+		if unit, ok := ageUnits[value[len(value)-1]]; ok {
+			if n, err := strconv.Atoi(value[:len(value)-1]); err == nil && n >= 0 {
+				return now.Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value: %s (must be an age like 7d, 2w or 36h, or a date like 2024-03-01)", value)
+}
+
+// filesReport returns a list of recorded files for the output formatters
+func filesReport(title string, files []manifest.File) output.Report {
+	section := output.Section{Title: title}
+	for _, f := range files {
+		section.Fields = append(section.Fields, output.F(f.Key,
+			fmt.Sprintf("%s, modified %s", output.Bytes(f.Size), f.ModTime.Local().Format("2006-01-02 15:04"))))
+	}
+	if files == nil {
+		files = []manifest.File{}
+	}
+	return output.Report{Sections: []output.Section{section}, Data: files}
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	opts := backupOptions(cmd)
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	setupLogging(cfg.LogLevel, cfg.LogBatch)
+	applyMemoryLimit(cfg)
+	if err := dropbox.SetEndpoints(cfg.Endpoints()); err != nil {
+		return err
+	}
+
+	if flagWebhookListen == "" {
+		return fmt.Errorf("--webhook-listen is required to receive change notifications")
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// Only react to notifications for the account being backed up
+	var accountID string
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer saveAPIUsage(cfg)
+	if account, err := client.GetAccountInfo(ctx); err != nil {
+		slog.Warn("Failed to get account info; reacting to notifications for any account", slog.String("error", err.Error()))
+	} else {
+		accountID = account.AccountID
+	}
+
+	handler := webhook.NewHandler(cfg.ClientSecret, accountID)
+	status := &runStatus{}
+	mux := http.NewServeMux()
+	mux.Handle(flagWebhookPath, handler)
+	mux.Handle(statusPath, status)
+	server := &http.Server{
+		Addr:              flagWebhookListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	var interval <-chan time.Time
+	if flagInterval > 0 {
+		ticker := time.NewTicker(flagInterval)
+		defer ticker.Stop()
+		interval = ticker.C
+
+		// Let the status command tell when the next run is due
+		saveSchedule(cfg.Profile, &config.Schedule{Interval: flagInterval, Start: time.Now()})
+		defer saveSchedule(cfg.Profile, nil)
+	}
+
+	out.Message("👀 Watching for Dropbox changes on %s%s", flagWebhookListen, flagWebhookPath)
+
+	for {
+		if err := backupOnce(ctx, opts, status); err != nil {
+			// Keep running; the next notification or interval retries
+			slog.Error("Backup failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Daemon stopped")
+			return nil
+		case err := <-serverErr:
+			return fmt.Errorf("webhook server failed: %w", err)
+		case <-handler.Notifications():
+			slog.Info("Starting backup after change notification")
+		case <-interval:
+			slog.Info("Starting scheduled backup")
+		}
+	}
+}
+
+// Longpolls of the watch command wait this long before they are repeated,
+// well below Dropbox's maximum, as some proxies close idle connections
+// earlier; failed ones are retried after watchRetryDelay
+const (
+	watchPollTimeout = 2 * time.Minute
+	watchRetryDelay  = time.Minute
+)
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	opts := backupOptions(cmd)
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	setupLogging(cfg.LogLevel, cfg.LogBatch)
+	applyMemoryLimit(cfg)
+	if err := dropbox.SetEndpoints(cfg.Endpoints()); err != nil {
+		return err
+	}
+
+	if strings.Contains(cfg.BackupDir, "{") {
+		return fmt.Errorf("backup directory %s contains placeholders; watch keeps a single backup directory up to date", cfg.BackupDir)
+	}
+	if flagDebounce < 0 {
+		return fmt.Errorf("invalid debounce: %s (must not be negative)", flagDebounce)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer saveAPIUsage(cfg)
+
+	watchOpts := watch.Options{
+		Poll:       client.Longpoll,
+		Timeout:    watchPollTimeout,
+		Debounce:   flagDebounce,
+		RetryDelay: watchRetryDelay,
+	}
+	status := &runStatus{}
+
+	out.Message("👀 Watching Dropbox for changes to back up to %s", cfg.BackupDir)
+
+	for {
+		if err := backupOnce(ctx, opts, status); err != nil && ctx.Err() == nil {
+			// Keep running; the next change retries
+			slog.Error("Backup failed", slog.String("error", err.Error()))
+		}
+
+		cursors, err := watchCursors(ctx, client, cfg.BackupDir)
+		if err == nil {
+			err = watch.Wait(ctx, watchOpts, cursors)
+		} else if ctx.Err() == nil {
+			slog.Error("Failed to get the listing cursors to watch, backing up again",
+				slog.String("error", err.Error()),
+				slog.Duration("retry_in", watchRetryDelay),
+			)
+			err = nil
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryDelay):
+			}
+		}
+		if ctx.Err() != nil {
+			slog.Info("Watch stopped")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		slog.Info("Starting backup after changes")
+	}
+}
+
+// watchCursors returns the cursors of the listing the last backup saved, so
+// changes since its listing are reported, or a new cursor for the whole
+// account if it saved none, e.g. in a dry run
+func watchCursors(ctx context.Context, client *dropbox.Client, backupDir string) ([]string, error) {
+	state, err := listing.Load(backupDir)
+	if err != nil {
+		slog.Warn("Ignoring listing of the last run", slog.String("error", err.Error()))
+	}
+	if state != nil && len(state.Cursors) > 0 {
+		return state.Cursors, nil
+	}
+	cursor, err := client.LatestCursor(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return []string{cursor}, nil
+}
+
+// backupOnce runs a single backup with a freshly loaded configuration, so
+// backup directory placeholders expand for every run, and serves its progress
+// on the status endpoint
+func backupOnce(ctx context.Context, opts config.Options, status *runStatus) error {
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	sharedBudget(cfg)
+	backupEngine, err := backup.New(cfg, out)
+	if err != nil {
+		return fmt.Errorf("failed to create backup engine: %w", err)
+	}
+	streamResults(backupEngine)
+	status.engine.Store(backupEngine)
+	stopProgress := showProgress(ctx, cfg, backupEngine)
+	stats, err := backupEngine.Run(ctx)
+	stopProgress()
+	status.engine.Store(nil)
+	saveRun(cfg, stats, err)
+	saveToken(cfg, backupEngine)
+	alertRun(ctx, cfg, stats, err)
+	if reportErr := reportBackup(cfg, stats, err); reportErr != nil && err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+// titleInterval is how often the terminal title shows the progress of a run
+const titleInterval = 2 * time.Second
+
+// showProgressTitle keeps the terminal title at the progress of a backup
+// until the returned function is called
+func showProgressTitle(ctx context.Context, engine *backup.Engine) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(titleInterval)
+		defer ticker.Stop()
+
+		desktop.SetTitle(os.Stderr, desktop.StatusTitle("listing"))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if estimate, ok := engine.Estimate(); ok {
+					desktop.SetTitle(os.Stderr, desktop.ProgressTitle(estimate))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// liveProgressInterval is how often the live progress display is redrawn
+const liveProgressInterval = 500 * time.Millisecond
+
+// showProgress reports the progress of the downloads of a backup until the
+// returned function is called: redrawn in place while stdout is a terminal
+// showing text, otherwise, or with --no-progress, as a line on stderr per
+// progress interval
+func showProgress(ctx context.Context, cfg *config.Config, engine *backup.Engine) (stop func()) {
+	// Lines for every file (--progress) and dry-run downloads print on
+	// stdout themselves, so they get the periodic lines too
+	live := !cfg.NoProgress && !cfg.Progress && !cfg.DryRun && ui.IsTerminal(os.Stdout) &&
+		(flagOutput == output.Text || flagOutput == output.Table)
+	view, interval := ui.NewProgressView(os.Stderr, false), cfg.ProgressInterval
+	if live {
+		view, interval = ui.NewProgressView(os.Stdout, true), liveProgressInterval
+	}
+	if interval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer view.Close()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if status, ok := engine.Estimate(); ok {
+					view.Update(status)
+				} else {
+					view.Close()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// announceEnd shows the outcome of a backup in the terminal title and as a
+// desktop notification, as configured
+func announceEnd(ctx context.Context, cfg *config.Config, stats *backup.Stats, runErr error) {
+	outcome, message := "done", endMessage(stats, runErr)
+	if runErr != nil {
+		outcome = "failed"
+	}
+	if cfg.TerminalTitle {
+		desktop.SetTitle(os.Stderr, desktop.StatusTitle(outcome))
+	}
+	if cfg.Notify {
+		// Interrupted runs are announced too
+		if err := desktop.Notify(context.WithoutCancel(ctx), desktop.StatusTitle(outcome), message); err != nil {
+			slog.Warn("Failed to show desktop notification", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// endMessage summarizes a backup for a desktop notification
+func endMessage(stats *backup.Stats, runErr error) string {
+	if runErr != nil {
+		return runErr.Error()
+	}
+	message := fmt.Sprintf("%s files downloaded (%s)", output.Count(stats.DownloadedFiles), output.Bytes(stats.TotalBytes))
+	if stats.FailedFiles > 0 {
+		message += fmt.Sprintf(", %s failed", output.Count(stats.FailedFiles))
+	}
+	return message + " in " + stats.Duration().Round(time.Second).String()
+}
+
+// statusPath is where the daemon serves the progress of the running backup
+const statusPath = "/status"
+
+// runStatus serves the progress of the running backup as JSON, including
+// the estimated time until its downloads finish
+type runStatus struct {
+	engine atomic.Pointer[backup.Engine]
+}
+
+func (s *runStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var status struct {
+		Running  bool                     `json:"running"`
+		Download *transfer.EstimateStatus `json:"download,omitempty"`
+	}
+	if engine := s.engine.Load(); engine != nil {
+		status.Running = true
+		if estimate, ok := engine.Estimate(); ok {
+			status.Download = &estimate
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// saveRun stores the outcome of a run in the profile settings for the status
+// command, and its download throughput, so the next run can estimate its
+// remaining time from the start
+func saveRun(cfg *config.Config, stats *backup.Stats, runErr error) {
+	if cfg.DryRun && stats.APIUsage == nil {
+		return
+	}
+
+	settings, err := config.LoadSettings()
+	if err == nil {
+		// Dry runs aren't part of the history, but their calls count
+		if !cfg.DryRun {
+			settings.RecordRun(cfg.Profile, runRecord(stats, runErr))
+			if throughput, ok := stats.Throughput(); ok {
+				settings.RecordThroughput(cfg.Profile, throughput)
+			}
+		}
+		if stats.APIUsage != nil {
+			settings.RecordAPIUsage(cfg.ClientID, stats.APIUsage)
+		}
+		err = settings.Save()
+	}
+	if err != nil {
+		slog.Warn("Failed to save the run history", slog.String("error", err.Error()))
+	}
+}
+
+// apiBudget counts the API calls of every Dropbox client of the process
+// against --api-call-budget; nil without a budget, see sharedBudget
+var apiBudget *budget.Budget
+
+// sharedBudget returns the API call budget shared by every Dropbox client of
+// the process, started from the usage of earlier runs, and sets it on cfg for
+// the backup engine; nil without a budget
+func sharedBudget(cfg *config.Config) *budget.Budget {
+	if cfg.APICallBudget <= 0 {
+		return nil
 	}
+	if apiBudget == nil {
+		apiBudget = budget.New(cfg.APICallBudget, cfg.APIUsage)
+	}
+	cfg.APIBudget = apiBudget
+	return apiBudget
 }
 
-var rootCmd = &cobra.Command{
-	Use:   "create-dropbox-backup-folder",
-	Short: "A tool to backup Dropbox files to a local directory",
-	Long: `create-dropbox-backup-folder is a command-line tool that authenticates with
-Dropbox using OAuth2 and downloads all files and folders to a specified
-local directory, preserving the folder structure.
+// saveAPIUsage keeps the calls counted by the shared API call budget for
+// later runs, e.g. those a restore or the longpolls of watch made
+func saveAPIUsage(cfg *config.Config) {
+	if apiBudget == nil {
+		return
+	}
+	settings, err := config.LoadSettings()
+	if err == nil {
+		settings.RecordAPIUsage(cfg.ClientID, apiBudget.Usage())
+		err = settings.Save()
+	}
+	if err != nil {
+		slog.Warn("Failed to save the API call usage", slog.String("error", err.Error()))
+	}
+}
 
-The tool supports incremental backups, exclusion patterns, and configurable
-logging levels. It handles authentication securely and efficiently manages
-API calls to avoid rate limits.`,
-	RunE: runBackup,
+// newClient creates a Dropbox client for a command other than the backup
+// itself with the API settings of cfg, counting its calls against the shared
+// API call budget
+func newClient(cfg *config.Config) (*dropbox.Client, error) {
+	client, err := dropbox.NewWithToken(dropbox.NewAuthConfig(cfg.ClientID, cfg.ClientSecret, ""), cfg.Token())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Dropbox client: %w", err)
+	}
+	client.SetCallTimeout(cfg.APITimeout)
+	client.SetRetryPolicy(cfg.Retry)
+	chaos, err := cfg.ChaosSettings()
+	if err != nil {
+		return nil, err
+	}
+	if chaos.Enabled() {
+		client.SetChaos(chaos)
+	}
+	if calls := sharedBudget(cfg); calls != nil {
+		client.SetBudget(calls)
+	}
+	return client, nil
+}
+
+// saveToken keeps tokens refreshed during a run in the token store
+func saveToken(cfg *config.Config, engine *backup.Engine) {
+	if err := cfg.SaveToken(engine.Token()); err != nil {
+		slog.Warn("Failed to save refreshed tokens", slog.String("error", err.Error()))
+	}
+}
+
+// alertRun keeps the report of a backup in the report directory and posts
+// its outcome to the alert webhook, linking that report
+func alertRun(ctx context.Context, cfg *config.Config, stats *backup.Stats, runErr error) {
+	var report string
+	if cfg.ReportDir != "" {
+		path, err := alert.WriteReport(cfg.ReportDir, stats.RunID, stats)
+		if err != nil {
+			slog.Warn("Failed to write the run report", slog.String("error", err.Error()))
+		} else {
+			report = alert.ReportLink(cfg.ReportURL, path)
+			slog.Info("Wrote the run report", slog.String("report", report))
+		}
+	}
+	if cfg.AlertWebhook == "" {
+		return
+	}
+
+	// Interrupted runs are reported too
+	if err := alert.Send(context.WithoutCancel(ctx), cfg.AlertWebhook, runAlert(cfg, stats, runErr, report)); err != nil {
+		slog.Warn("Failed to send the run alert", slog.String("error", err.Error()))
+	}
 }
 
+// runAlert returns the alert describing a backup, listing its first
+// failed paths
+func runAlert(cfg *config.Config, stats *backup.Stats, runErr error, report string) alert.Alert {
+	host, _ := os.Hostname()
+	a := alert.Alert{
+		RunID:      stats.RunID,
+		Status:     alert.StatusOK,
+		Host:       host,
+		Profile:    cfg.Profile,
+		Start:      stats.StartTime,
+		End:        stats.EndTime,
+		Downloaded: stats.DownloadedFiles,
+		Failed:     stats.FailedFiles,
+		Report:     report,
+		Summary:    stats.SummaryLine(runErr),
+	}
+	if runErr != nil {
+		a.Status = alert.StatusFailed
+		a.Error = runErr.Error()
+	}
+
+	failures := make([]alert.Failure, 0, len(stats.Errors))
+	for _, failure := range stats.Errors {
+		failures = append(failures, alert.Failure{Path: failure.Path, Class: failure.Class, Error: failure.Error})
+	}
+	a.Limit(failures, stats.FailedFiles, cfg.AlertFailures)
+	return a
+}
+
+// runRecord returns the outcome of a run as kept in the run history
+func runRecord(stats *backup.Stats, runErr error) config.RunRecord {
+	run := config.RunRecord{
+		ID:         stats.RunID,
+		Start:      stats.StartTime,
+		End:        stats.EndTime,
+		Success:    runErr == nil,
+		Downloaded: stats.DownloadedFiles,
+		Failed:     stats.FailedFiles,
+		Bytes:      stats.TotalBytes,
+	}
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+	return run
+}
+
+// saveSchedule stores the schedule of the daemon in the profile settings,
+// nil when it stops
+func saveSchedule(profile string, schedule *config.Schedule) {
+	settings, err := config.LoadSettings()
+	if err == nil {
+		settings.SetSchedule(profile, schedule)
+		err = settings.Save()
+	}
+	if err != nil {
+		slog.Warn("Failed to save the daemon schedule", slog.String("error", err.Error()))
+	}
+}
+
+// errUnhealthy and errNoRuns are returned by the status command when the
+// backup needs attention or never ran
 var (
-	flagDelete     bool
-	flagExclude    []string
-	flagLogLevel   string
-	flagBackupDir  string
-	flagConfigFile string
-	flagCount      bool
-	flagSize       bool
+	errUnhealthy = errors.New("backup needs attention")
+	errNoRuns    = errors.New("no backup has run yet")
 )
 
-func init() {
-	rootCmd.Flags().BoolVar(&flagDelete, "delete", false, "Delete local files that don't exist in Dropbox")
-	rootCmd.Flags().StringSliceVar(&flagExclude, "exclude", []string{}, "Exclude patterns (e.g., '*.tmp', 'temp/', '@filename')")
-	rootCmd.Flags().StringVar(&flagLogLevel, "loglevel", "error", "Log level (debug, info, warn, error)")
-	rootCmd.Flags().StringVar(&flagBackupDir, "backup-dir", "", "Custom backup directory (overrides DROPBOX_BACKUP_FOLDER)")
-	rootCmd.Flags().StringVar(&flagConfigFile, "config", "", "Path to configuration file")
-	rootCmd.Flags().BoolVar(&flagCount, "count", false, "Display total number of files and directories processed")
-	rootCmd.Flags().BoolVar(&flagSize, "size", false, "Display total size of files processed")
+func runHealth(cmd *cobra.Command, args []string) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
 
-	// Add version command
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "version",
-		Short: "Print version information",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("create-dropbox-backup-folder %s\nCommit: %s\nBuilt: %s\n", version, commit, date)
-		},
-	})
+	report, status := healthReport(settings.Profile(config.ProfileName(flagProfile)), time.Now(), flagStatusRuns)
+	if err := out.Report(report); err != nil {
+		return err
+	}
+	switch status {
+	case healthAttention:
+		return errUnhealthy
+	case healthNoRuns:
+		return errNoRuns
+	}
+	return nil
+}
 
-	// Add auth command for interactive authentication
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "auth",
-		Short: "Authenticate with Dropbox using OAuth2",
-		Long: `Start an interactive OAuth2 authentication flow with Dropbox.
-This will open your web browser and guide you through the authentication process.
-After successful authentication, save the tokens to your .env file.`,
-		RunE: runAuth,
+func runImportSync(cmd *cobra.Command, args []string) error {
+	dir := flagDropboxDir
+	if dir == "" {
+		folders, err := syncimport.Folders()
+		if err != nil {
+			return err
+		}
+		if len(folders) == 0 {
+			return errors.New("the Dropbox desktop client has no linked account; pass the Dropbox folder with --dropbox-dir")
+		}
+		dir = folders[0].Path
+	}
+
+	exclusions, err := syncimport.Import(cmd.Context(), dir)
+	if err != nil {
+		return err
+	}
+
+	if flagSyncSave {
+		settings, err := config.LoadSettings()
+		if err != nil {
+			return err
+		}
+		name := config.ProfileName(flagProfile)
+		profile := settings.Profile(name)
+		profile.SyncExclude = syncPatterns(exclusions)
+		settings.SetProfile(name, profile)
+		if err := settings.Save(); err != nil {
+			return err
+		}
+	}
+
+	return out.Report(importSyncReport(dir, exclusions, flagSyncSave))
+}
+
+// syncPatterns returns the exclude patterns of imported exclusions
+func syncPatterns(exclusions []syncimport.Exclusion) []string {
+	patterns := make([]string, 0, len(exclusions))
+	for _, exclusion := range exclusions {
+		patterns = append(patterns, exclusion.Pattern())
+	}
+	return patterns
+}
+
+// importSyncReport lists the exclusions imported from a Dropbox folder and
+// whether they were saved
+func importSyncReport(dir string, exclusions []syncimport.Exclusion, saved bool) output.Report {
+	fields := []output.Field{output.F("Dropbox folder", dir)}
+	for _, exclusion := range exclusions {
+		fields = append(fields, output.F("Exclude", fmt.Sprintf("%s (%s)", exclusion.Pattern(), exclusion.Source)))
+	}
+	if len(exclusions) == 0 {
+		fields = append(fields, output.F("Exclude", "nothing, every folder is synced"))
+	}
+	if saved {
+		fields = append(fields, output.F("Saved", "yes, later backups leave these folders out"))
+	} else {
+		fields = append(fields, output.F("Saved", "no, pass --save to leave these folders out of backups"))
+	}
+
+	return output.Report{
+		Sections: []output.Section{{Title: "📥 Dropbox Client Exclusions:", Fields: fields}},
+		Data: struct {
+			DropboxDir string                 `json:"dropbox_dir"`
+			Exclusions []syncimport.Exclusion `json:"exclusions"`
+			Patterns   []string               `json:"patterns"`
+			Saved      bool                   `json:"saved"`
+		}{dir, exclusions, syncPatterns(exclusions), saved},
+	}
+}
+
+// installOptions returns the installation set by the install and uninstall
+// flags, configured in the directory of the settings file
+func installOptions() (install.Options, error) {
+	settings, err := config.SettingsPath()
+	if err != nil {
+		return install.Options{}, err
+	}
+	return install.Options{
+		BinDir:    flagBinDir,
+		ConfigDir: filepath.Dir(settings),
+		Schedule:  flagSchedule,
+	}, nil
+}
+
+func runInstall(cmd *cobra.Command, args []string) error {
+	opts, err := installOptions()
+	if err != nil {
+		return err
+	}
+	result, err := install.Install(opts)
+	if err != nil {
+		return fmt.Errorf("install failed: %w", err)
+	}
+	return out.Report(installReport(result, opts))
+}
+
+// installReport tells what install did and what is left to do
+func installReport(result install.Result, opts install.Options) output.Report {
+	envFile := result.EnvFile + " (kept)"
+	if result.EnvCreated {
+		envFile = result.EnvFile + " (created)"
+	}
+	schedule := "none (pass --schedule to back up daily)"
+	if result.Scheduler != "" {
+		schedule = fmt.Sprintf("daily at %s with %s, logging to %s", opts.Schedule, result.Scheduler, filepath.Join(opts.ConfigDir, install.LogFileName))
+	}
+
+	return output.Report{
+		Sections: []output.Section{{
+			Title: "📦 Installed:",
+			Fields: []output.Field{
+				output.F("Program", result.Binary),
+				output.F("Environment", envFile),
+				output.F("Schedule", schedule),
+				output.F("Next step", "run setup to fill in the credentials and backup folder, or edit the environment file"),
+			},
+		}},
+		Data: result,
+	}
+}
+
+func runUninstall(cmd *cobra.Command, args []string) error {
+	opts, err := installOptions()
+	if err != nil {
+		return err
+	}
+	if err := install.Uninstall(opts, flagPurge); err != nil {
+		return fmt.Errorf("uninstall failed: %w", err)
+	}
+
+	out.Message("🗑️  Removed the scheduled backup and the installed program")
+	if flagPurge {
+		out.Message("   Removed %s", opts.ConfigDir)
+	} else {
+		out.Message("   Kept %s; remove it with --purge", opts.ConfigDir)
+	}
+	return nil
+}
+
+// Status values of the status command
+const (
+	healthOK        = "healthy"
+	healthAttention = "needs attention"
+	healthNoRuns    = "no runs yet"
+)
+
+// backupHealth is the data of the status command. The backup is healthy when
+// the last run succeeded and, on a schedule, finished within its interval
+// and the next run isn't overdue. Without runs it has no runs yet, unless a
+// scheduled one is overdue.
+type backupHealth struct {
+	Healthy      bool               `json:"healthy"`
+	Status       string             `json:"status"`
+	Schedule     *config.Schedule   `json:"schedule,omitempty"`
+	NextRun      *time.Time         `json:"next_run,omitempty"`
+	Overdue      bool               `json:"overdue,omitempty"`
+	WithinWindow *bool              `json:"within_window,omitempty"`
+	Runs         []config.RunRecord `json:"runs"`
+}
+
+// healthReport describes the latest runs of a profile, newest first, and
+// while a daemon backs it up on a schedule, the next run and whether the
+// last one finished within its interval. A run is overdue once a whole
+// interval passed after it was due, as the daemon may have stopped. It
+// returns the report and the status.
+func healthReport(profile config.ProfileSettings, now time.Time, runs int) (output.Report, string) {
+	health := backupHealth{Schedule: profile.Schedule, Runs: []config.RunRecord{}}
+	for i := len(profile.Runs) - 1; i >= 0 && len(health.Runs) < runs; i-- {
+		health.Runs = append(health.Runs, profile.Runs[i])
+	}
+
+	var last *config.RunRecord
+	if len(profile.Runs) > 0 {
+		last = &profile.Runs[len(profile.Runs)-1]
+	}
+	health.Healthy = last != nil && last.Success
+
+	section := output.Section{Title: "🩺 Backup Health:"}
+	if schedule := profile.Schedule; schedule == nil {
+		section.Fields = append(section.Fields, output.F("Schedule", "none (no daemon with --interval running)"))
+	} else {
+		section.Fields = append(section.Fields, output.F("Schedule", fmt.Sprintf("every %s (daemon since %s)", schedule.Interval, schedule.Start.Local().Format(time.DateTime))))
+
+		since := schedule.Start
+		if last != nil && last.End.After(since) {
+			since = last.End
+		}
+		next := schedule.Next(since)
+		health.NextRun = &next
+		health.Overdue = now.After(next.Add(schedule.Interval))
+		if health.Overdue {
+			section.Fields = append(section.Fields, output.F("Next run", fmt.Sprintf("%s (overdue by %s; is the daemon still running?)", next.Local().Format(time.DateTime), now.Sub(next).Round(time.Second))))
+		} else {
+			section.Fields = append(section.Fields, output.F("Next run", next.Local().Format(time.DateTime)))
+		}
+
+		if last != nil {
+			within := last.Duration() <= schedule.Interval
+			health.WithinWindow = &within
+			answer := "yes"
+			if !within {
+				answer = "no"
+			}
+			section.Fields = append(section.Fields, output.F("Last run within its window", fmt.Sprintf("%s (%s of %s)", answer, last.Duration().Round(time.Second), schedule.Interval)))
+			health.Healthy = health.Healthy && within
+		}
+		health.Healthy = health.Healthy && !health.Overdue
+	}
+	switch {
+	case health.Healthy:
+		health.Status = healthOK
+	case last == nil && !health.Overdue:
+		health.Status = healthNoRuns
+	default:
+		health.Status = healthAttention
+	}
+	section.Fields = append(section.Fields, output.F("Status", health.Status))
+
+	history := output.Section{Title: "🕘 Recent Runs:"}
+	for _, run := range health.Runs {
+		outcome := fmt.Sprintf("ok, %s files (%s) in %s", output.Count(run.Downloaded), output.Bytes(run.Bytes), run.Duration().Round(time.Second))
+		if !run.Success {
+			outcome = "failed: " + run.Error
+		}
+		history.Fields = append(history.Fields, output.F(run.Start.Local().Format(time.DateTime), outcome))
+	}
+	if len(health.Runs) == 0 {
+		history.Fields = append(history.Fields, output.F("Runs", "none recorded yet"))
+	}
+
+	return output.Report{Sections: []output.Section{section, history}, Data: health}, health.Status
+}
+
+// reportBackup prints the result of a backup run in the --output format (for
+// text, the summaries requested with --count and --size) and always a single
+// machine-readable result line on stderr, after the log records of the run
+func reportBackup(cfg *config.Config, stats *backup.Stats, err error) error {
+	flushLogging()
+	fmt.Fprintln(os.Stderr, stats.SummaryLine(err))
+
+	report := stats.Report(cfg.ShowCount, cfg.ShowSize, cfg.ExplainFilters, cfg.ListUnsupported, cfg.FolderStats)
+	if err != nil {
+		report.Sections = nil // Only the data of failed runs
+	}
+	return out.Report(report)
+}
+
+// eventFile is the event type of file results in the JSON stream
+const eventFile = "file"
+
+// streamResults writes the result of every file of a backup as a file event
+// with --output json-stream, before the summary of the run
+func streamResults(engine *backup.Engine) {
+	streamer, ok := out.(output.Streamer)
+	if !ok {
+		return
+	}
+	engine.OnResult(func(result backup.Result) {
+		if err := streamer.Event(eventFile, result); err != nil {
+			slog.Warn("Failed to write file event", slog.String("path", result.Path), slog.String("error", err.Error()))
+		}
 	})
 }
 
-func runBackup(cmd *cobra.Command, args []string) error {
-	// Parse and validate configuration
-	cfg, err := config.Load(config.Options{
-		ConfigFile: flagConfigFile,
-		BackupDir:  flagBackupDir,
-		LogLevel:   flagLogLevel,
-		Delete:     flagDelete,
-		Exclude:    flagExclude,
-		ShowCount:  flagCount,
-		ShowSize:   flagSize,
+// setupOutput selects the formatter of --output, writing to stdout, and
+// formats numbers in the --units and the locale of the environment
+func setupOutput(cmd *cobra.Command, args []string) error {
+	formatter, err := output.New(flagOutput, os.Stdout)
+	if err != nil {
+		return err
+	}
+	units, err := output.ParseUnits(flagUnits)
+	if err != nil {
+		return err
+	}
+	out = formatter
+	output.SetNumberFormat(output.LocaleNumberFormat(output.EnvLocale(), units))
+	return loadEnvFile()
+}
+
+// loadEnvFile sets the variables of the environment file written by setup or
+// install that aren't set already. Without a user config directory there is
+// no such file.
+func loadEnvFile() error {
+	settings, err := config.SettingsPath()
+	if err != nil {
+		return nil
+	}
+	return install.LoadEnvFile(filepath.Dir(settings))
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	return out.Report(output.Report{
+		Sections: []output.Section{{
+			Title:  "create-dropbox-backup-folder " + version,
+			Fields: []output.Field{output.F("Commit", commit), output.F("Built", date)},
+		}},
+		Data: map[string]string{"version": version, "commit": commit, "date": date},
 	})
+}
+
+// chooseFolders lets the user toggle which top-level folders to back up and
+// saves the selection to the active profile for future runs
+func chooseFolders(ctx context.Context, engine *backup.Engine, cfg *config.Config, prompt *ui.Prompt) error {
+	out.Message("📂 Measuring top-level Dropbox folders...")
+
+	folders, err := engine.TopLevelFolders(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return err
+	}
+	if len(folders) == 0 {
+		out.Message("No folders found in your Dropbox; backing up everything.")
+		return nil
 	}
 
-	// Setup logging
-	setupLogging(cfg.LogLevel)
+	current := make(map[string]bool)
+	for _, path := range cfg.RemotePaths {
+		current[strings.ToLower(path)] = true
+	}
 
-	slog.Info("Starting Dropbox backup",
-		slog.String("backup_dir", cfg.BackupDir),
-		slog.String("log_level", cfg.LogLevel),
-		slog.Bool("delete_enabled", cfg.Delete),
-		slog.Int("exclude_patterns", len(cfg.Exclude)),
-	)
+	items := make([]ui.PickerItem, len(folders))
+	for i, folder := range folders {
+		items[i] = ui.PickerItem{
+			Label:    folder.Path,
+			Detail:   folder.Detail(),
+			Selected: current[folder.Path],
+		}
+	}
 
-	// Create backup engine
-	backupEngine, err := backup.New(cfg)
+	picked, err := prompt.Pick("Select the folders to back up:", items)
 	if err != nil {
-		return fmt.Errorf("failed to create backup engine: %w", err)
+		return err
+	}
+
+	var paths []string
+	for _, item := range picked {
+		if item.Selected {
+			paths = append(paths, item.Label)
+		}
 	}
 
-	// Create context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	if err := saveFolderSelection(cfg.Profile, paths); err != nil {
+		return err
+	}
+	cfg.RemotePaths = paths
 
-	// Run backup
-	if err := backupEngine.Run(ctx); err != nil {
-		return fmt.Errorf("backup failed: %w", err)
+	if len(paths) == 0 {
+		out.Message("✅ No folders selected; the whole account will be backed up.")
+	} else {
+		out.Message("✅ Saved selection of %d folder(s) for profile %q.", len(paths), cfg.Profile)
 	}
+	out.Message("")
 
-	slog.Info("Backup completed successfully")
 	return nil
 }
 
-func setupLogging(level string) {
+// saveFolderSelection stores the folders to back up for a profile; none
+// backs up the whole account
+func saveFolderSelection(name string, paths []string) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	profile := settings.Profile(name)
+	profile.RemotePaths = paths
+	settings.SetProfile(name, profile)
+	return settings.Save()
+}
+
+// applyMemoryLimit sets the soft memory limit of the Go runtime to the
+// --max-memory budget, so it collects garbage more often instead of growing
+func applyMemoryLimit(cfg *config.Config) {
+	memory, err := cfg.MemoryPlan()
+	if err != nil || memory.Limit == 0 {
+		return
+	}
+	debug.SetMemoryLimit(memory.Limit)
+	slog.Info("Limiting memory use",
+		slog.Int64("max_memory", memory.Limit),
+		slog.Int("max_concurrency", memory.Concurrency),
+		slog.Int("max_queued_transfers", memory.QueuedTransfers),
+		slog.Int("max_hash_workers", memory.HashWorkers),
+		slog.Int("list_page_size", memory.ListPageSize),
+	)
+}
+
+// logHandler writes the log records of the command, see setupLogging
+var logHandler *logbatch.Handler
+
+// setupLogging logs at a level to stderr through a single writer, summarizing
+// per-file records once per batch interval if set
+func setupLogging(level string, batch time.Duration) {
 	var logLevel slog.Level
 	switch level {
 	case "debug":
@@ -142,22 +2106,54 @@ func setupLogging(level string) {
 		Level: logLevel,
 	}
 
-	handler := slog.NewTextHandler(os.Stderr, opts)
-	logger := slog.New(handler)
-	slog.SetDefault(logger)
+	closeLogging()
+	logHandler = logbatch.New(slog.NewTextHandler(os.Stderr, opts), batch)
+	slog.SetDefault(slog.New(logHandler))
+}
+
+// flushLogging writes the log records still queued and keeps logging
+func flushLogging() {
+	if logHandler != nil {
+		logHandler.Flush()
+	}
+}
+
+// closeLogging writes the log records still queued
+func closeLogging() {
+	if logHandler != nil {
+		logHandler.Close()
+	}
 }
 
 func runAuth(cmd *cobra.Command, args []string) error {
+	// Signing in waits for the browser, which nobody answers in unattended runs
+	if err := requireInput("auth"); err != nil {
+		return err
+	}
+
 	// Setup basic logging
-	setupLogging("info")
+	setupLogging("info", 0)
 
 	// Check for required environment variables
 	clientID := os.Getenv("DROPBOX_CLIENT_ID")
 	clientSecret := os.Getenv("DROPBOX_CLIENT_SECRET")
+	tokenStore := os.Getenv("DROPBOX_TOKEN_STORE")
+	endpoints := endpointsFromEnv()
+	profile := config.ProfileName(flagProfile)
+
+	// A config file may hold the credentials of each profile
+	if flagConfigFile != "" || os.Getenv("DROPBOX_CONFIG_FILE") != "" {
+		cfg, err := config.Load(config.Options{ConfigFile: flagConfigFile, Profile: flagProfile})
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		clientID, clientSecret, tokenStore, endpoints = cfg.ClientID, cfg.ClientSecret, cfg.TokenStore, cfg.Endpoints()
+	}
 
 	if clientID == "" || clientSecret == "" {
 		return fmt.Errorf(`missing required environment variables:
-Please set DROPBOX_CLIENT_ID and DROPBOX_CLIENT_SECRET in your .env file.
+Please set DROPBOX_CLIENT_ID and DROPBOX_CLIENT_SECRET in your .env file,
+or run setup to be guided through the configuration.
 
 Get these credentials from: https://www.dropbox.com/developers/apps
 
@@ -166,13 +2162,28 @@ DROPBOX_CLIENT_ID="your_app_key_here"
 DROPBOX_CLIENT_SECRET="your_app_secret_here"`)
 	}
 
+	if err := dropbox.SetEndpoints(endpoints); err != nil {
+		return err
+	}
+
 	fmt.Println("🔐 Starting Dropbox OAuth2 authentication...")
 	fmt.Println("📱 This will open your web browser for authentication.")
 	fmt.Println("")
 
 	// Import the dropbox package
 	// Note: We need to add the import at the top of the file
-	token, err := authenticateInteractively(clientID, clientSecret)
+	var extraScopes []string
+	if flagAuthWrite {
+		extraScopes = append(extraScopes, dropbox.ScopeContentWrite)
+	}
+	if flagAuthShare {
+		extraScopes = append(extraScopes, dropbox.ScopeSharingRead)
+	}
+	if flagAuthMeta {
+		extraScopes = append(extraScopes, dropbox.ScopeFileRequestsRead)
+	}
+
+	token, err := authenticateInteractively(clientID, clientSecret, extraScopes...)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
@@ -180,11 +2191,23 @@ DROPBOX_CLIENT_SECRET="your_app_secret_here"`)
 	fmt.Println("")
 	fmt.Println("✅ Authentication successful!")
 	fmt.Println("")
-	fmt.Println("🔑 Add these tokens to your .env file:")
-	fmt.Println("")
-	fmt.Printf("DROPBOX_ACCESS_TOKEN=\"%s\"\n", token.AccessToken)
-	if token.RefreshToken != "" {
-		fmt.Printf("DROPBOX_REFRESH_TOKEN=\"%s\"\n", token.RefreshToken)
+	if flagAuthPrint {
+		fmt.Println("🔑 Add these tokens to your .env file:")
+		fmt.Println("")
+		fmt.Printf("DROPBOX_ACCESS_TOKEN=\"%s\"\n", token.AccessToken)
+		if token.RefreshToken != "" {
+			fmt.Printf("DROPBOX_REFRESH_TOKEN=\"%s\"\n", token.RefreshToken)
+		}
+	} else {
+		where, err := storeToken(tokenStore, config.TokenKey(clientID, profile), token)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔑 Tokens of profile %q saved to %s.\n", profile, where)
+		if os.Getenv("DROPBOX_ACCESS_TOKEN") != "" || os.Getenv("DROPBOX_REFRESH_TOKEN") != "" {
+			fmt.Println("⚠️  Remove DROPBOX_ACCESS_TOKEN and DROPBOX_REFRESH_TOKEN from your .env file,")
+			fmt.Println("   as tokens in the environment take priority over stored ones.")
+		}
 	}
 	fmt.Println("")
 	fmt.Println("💡 You can now run the backup command:")
@@ -193,8 +2216,208 @@ DROPBOX_CLIENT_SECRET="your_app_secret_here"`)
 	return nil
 }
 
+// storeToken saves tokens in an entry of the token store of a mode and
+// returns where they went
+func storeToken(mode, key string, token *oauth2.Token) (string, error) {
+	store, err := config.OpenTokenStore(mode)
+	if err != nil {
+		return "", err
+	}
+	where, err := store.Save(key, token)
+	if err != nil {
+		return "", fmt.Errorf("failed to save tokens: %w", err)
+	}
+	return where, nil
+}
+
+// runSetup walks a new user through the configuration and saves it to the
+// environment file of the configuration directory
+func runSetup(cmd *cobra.Command, args []string) error {
+	if err := requireInput("setup"); err != nil {
+		return err
+	}
+	if profile := config.ProfileName(flagProfile); profile != config.DefaultProfile {
+		return fmt.Errorf("setup configures the default profile; configure profile %q in a config file (see --config)", profile)
+	}
+	setupLogging("error", 0)
+
+	settingsPath, err := config.SettingsPath()
+	if err != nil {
+		return err
+	}
+	configDir := filepath.Dir(settingsPath)
+	prompt := newPrompt()
+
+	fmt.Println("👋 Let's set up your Dropbox backup.")
+	fmt.Printf("   The answers are saved to %s.\n", filepath.Join(configDir, install.EnvFileName))
+	fmt.Println("   Press Enter to keep a value shown in brackets.")
+	fmt.Println("")
+
+	fmt.Println("1️⃣  Dropbox app")
+	fmt.Println("   Create an app with \"Full Dropbox\" access at https://www.dropbox.com/developers/apps,")
+	fmt.Println("   add http://localhost:8080/callback as a redirect URI and copy its key and secret.")
+	values := make(map[string]string)
+	for _, v := range []struct{ name, question string }{
+		{"DROPBOX_CLIENT_ID", "   App key"},
+		{"DROPBOX_CLIENT_SECRET", "   App secret"},
+	} {
+		if values[v.name], err = promptRequired(prompt, v.question, os.Getenv(v.name)); err != nil {
+			return err
+		}
+	}
+	fmt.Println("")
+
+	fmt.Println("2️⃣  Authentication")
+	values["DROPBOX_ACCESS_TOKEN"] = os.Getenv("DROPBOX_ACCESS_TOKEN")
+	values["DROPBOX_REFRESH_TOKEN"] = os.Getenv("DROPBOX_REFRESH_TOKEN")
+	values["DROPBOX_TOKEN_STORE"] = os.Getenv("DROPBOX_TOKEN_STORE")
+	store, err := config.OpenTokenStore(values["DROPBOX_TOKEN_STORE"])
+	if err != nil {
+		return err
+	}
+	authenticated := values["DROPBOX_ACCESS_TOKEN"] != "" && values["DROPBOX_CLIENT_ID"] == os.Getenv("DROPBOX_CLIENT_ID")
+	if _, err := store.Load(values["DROPBOX_CLIENT_ID"]); err == nil {
+		authenticated = true
+	}
+	authenticate := "a"
+	if authenticated {
+		authenticate, err = prompt.Ask("   This app is already authenticated.", []ui.Choice{
+			{Key: "k", Label: "keep the tokens"},
+			{Key: "a", Label: "authenticate again"},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if authenticate == "a" {
+		if err := dropbox.SetEndpoints(endpointsFromEnv()); err != nil {
+			return err
+		}
+		fmt.Println("   Your browser opens to let the app read your Dropbox.")
+		token, err := authenticateInteractively(values["DROPBOX_CLIENT_ID"], values["DROPBOX_CLIENT_SECRET"])
+		if err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+		where, err := store.Save(values["DROPBOX_CLIENT_ID"], token)
+		if err != nil {
+			return fmt.Errorf("failed to save tokens: %w", err)
+		}
+		// The stored tokens replace any in the environment file
+		values["DROPBOX_ACCESS_TOKEN"] = ""
+		values["DROPBOX_REFRESH_TOKEN"] = ""
+		fmt.Printf("   ✅ Authenticated; tokens saved to %s\n", where)
+	}
+	fmt.Println("")
+
+	fmt.Println("3️⃣  Backup directory")
+	backupDir := os.Getenv("DROPBOX_BACKUP_FOLDER")
+	if backupDir == "" {
+		backupDir = defaultSetupBackupDir()
+	}
+	if backupDir, err = promptRequired(prompt, "   Back up to", backupDir); err != nil {
+		return err
+	}
+	if values["DROPBOX_BACKUP_FOLDER"], err = filepath.Abs(backupDir); err != nil {
+		return fmt.Errorf("failed to get absolute path for backup directory: %w", err)
+	}
+	fmt.Println("")
+
+	envFile, err := install.SaveEnvFile(configDir, values)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		os.Setenv(name, value)
+	}
+
+	fmt.Println("4️⃣  Folders to back up")
+	cfg, err := config.Load(config.Options{BackupDir: values["DROPBOX_BACKUP_FOLDER"]})
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	scope, err := prompt.Ask("   Back up the whole Dropbox or only some top-level folders?", []ui.Choice{
+		{Key: "w", Label: "whole Dropbox"},
+		{Key: "c", Label: "choose folders"},
+	})
+	if err != nil {
+		return err
+	}
+	if scope == "c" {
+		if err := dropbox.SetEndpoints(cfg.Endpoints()); err != nil {
+			return err
+		}
+		sharedBudget(cfg)
+		defer saveAPIUsage(cfg)
+		engine, err := backup.New(cfg, out)
+		if err != nil {
+			return fmt.Errorf("failed to connect to Dropbox: %w", err)
+		}
+		if err := chooseFolders(cmd.Context(), engine, cfg, prompt); err != nil {
+			return fmt.Errorf("folder selection failed: %w", err)
+		}
+	} else if err := saveFolderSelection(cfg.Profile, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Setup complete; the configuration is saved in %s.\n", envFile)
+	fmt.Println("")
+	fmt.Println("💡 Next steps:")
+	fmt.Println("   Run a backup now:   create-dropbox-backup-folder --loglevel info")
+	fmt.Println("   Back up every day:  create-dropbox-backup-folder install --schedule 02:30")
+	return nil
+}
+
+// newPrompt returns the prompt of interactive questions, which fails
+// them all with --no-input. Questions go to stderr so they never mix with
+// the --output of stdout.
+func newPrompt() *ui.Prompt {
+	if flagNoInput {
+		return ui.NoInput(os.Stderr)
+	}
+	return ui.NewPrompt(os.Stdin, os.Stderr)
+}
+
+// requireInput fails with --no-input for what can't run without a user
+func requireInput(what string) error {
+	if flagNoInput {
+		return fmt.Errorf("%s needs interactive input: %w", what, ui.ErrNoInput)
+	}
+	return nil
+}
+
+// promptRequired asks for a value until one is given
+func promptRequired(prompt *ui.Prompt, question, current string) (string, error) {
+	for {
+		answer, err := prompt.Input(question, current)
+		if err != nil || answer != "" {
+			return answer, err
+		}
+		fmt.Println("   ⚠️  A value is required")
+	}
+}
+
+// defaultSetupBackupDir is the backup directory setup suggests
+func defaultSetupBackupDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "DropboxBackup"
+	}
+	return filepath.Join(home, "DropboxBackup")
+}
+
+// endpointsFromEnv returns the Dropbox endpoints set in the environment,
+// for commands that don't load the configuration
+func endpointsFromEnv() dropbox.Endpoints {
+	return dropbox.Endpoints{
+		API:     os.Getenv("DROPBOX_API_URL"),
+		Content: os.Getenv("DROPBOX_CONTENT_URL"),
+		Notify:  os.Getenv("DROPBOX_NOTIFY_URL"),
+		Auth:    os.Getenv("DROPBOX_AUTH_URL"),
+	}
+}
+
 // authenticateInteractively handles the interactive OAuth flow
-func authenticateInteractively(clientID, clientSecret string) (*oauth2.Token, error) {
+func authenticateInteractively(clientID, clientSecret string, extraScopes ...string) (*oauth2.Token, error) {
 	// Use the interactive authentication from our dropbox package
-	return dropbox.AuthenticateWithStoredToken(clientID, clientSecret, "", "")
+	return dropbox.AuthenticateWithStoredToken(clientID, clientSecret, "", "", extraScopes...)
 }
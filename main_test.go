@@ -1,10 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"create-dropbox-backup-folder/internal/archive"
+	"create-dropbox-backup-folder/internal/backup"
+	"create-dropbox-backup-folder/internal/budget"
 	"create-dropbox-backup-folder/internal/config"
+	"create-dropbox-backup-folder/internal/filetypes"
+	"create-dropbox-backup-folder/internal/filter"
+	"create-dropbox-backup-folder/internal/install"
+	"create-dropbox-backup-folder/internal/logbatch"
+	"create-dropbox-backup-folder/internal/manifest"
+	"create-dropbox-backup-folder/internal/output"
+	"create-dropbox-backup-folder/internal/syncimport"
+	"create-dropbox-backup-folder/internal/ui"
 )
 
 func TestMain(m *testing.M) {
@@ -70,7 +95,525 @@ func TestDefaultValues(t *testing.T) {
 	if cfg.MaxConcurrency != 5 {
 		t.Errorf("Default MaxConcurrency = %v, want 5", cfg.MaxConcurrency)
 	}
-	if cfg.RetryAttempts != 3 {
-		t.Errorf("Default RetryAttempts = %v, want 3", cfg.RetryAttempts)
+	if cfg.Retry.MaxAttempts != 3 {
+		t.Errorf("Default Retry.MaxAttempts = %v, want 3", cfg.Retry.MaxAttempts)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "failure", err: errors.New("backup failed"), want: 1},
+		{name: "disk full", err: fmt.Errorf("backup failed: %w", &backup.DiskFullError{Err: errors.New("no space left on device")}), want: exitDiskFull},
+		{name: "budget spent", err: fmt.Errorf("backup failed: %w", &backup.BudgetError{RemainingFiles: 3}), want: exitBudget},
+		{name: "needs attention", err: errUnhealthy, want: exitUnhealthy},
+		{name: "no runs yet", err: errNoRuns, want: exitNoRuns},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyReport(t *testing.T) {
+	report := archive.Report{Runs: 3, Checked: 10, Missing: []string{"/a.txt"}}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(verifyReport(report, "")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Archive chain intact (3 runs), 10 files verified", "Manifest: not signed", "Missing: /a.txt"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text output = %q, missing %q", buf.String(), want)
+		}
+	}
+
+	buf.Reset()
+	jsonOut, _ := output.New(output.JSON, &buf)
+	if err := jsonOut.Report(verifyReport(report, "ops@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output isn't JSON: %v", err)
+	}
+	if decoded["runs"] != float64(3) || decoded["signer"] != "ops@example.com" {
+		t.Errorf("JSON output = %v", decoded)
+	}
+}
+
+func TestFilterTestReport(t *testing.T) {
+	f := filter.Filter{Include: []string{"Documents/", "*.pdf"}, Exclude: []string{"*.tmp"}}
+	report := filterTestReport(f, []string{"/Documents/a.txt", "Photos/b.pdf", "/Documents/c.tmp", "/Photos/d.jpg"})
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(report); err != nil {
+		t.Fatal(err)
+	}
+	want := "🔍 Filter Test:\n" +
+		"   /Documents/a.txt: included by Documents/\n" +
+		"   /Photos/b.pdf: included by *.pdf\n" +
+		"   /Documents/c.tmp: excluded by *.tmp\n" +
+		"   /Photos/d.jpg: excluded, matches no include pattern\n"
+	if buf.String() != want {
+		t.Errorf("filter test output =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	results := report.Data.([]filterTestResult)
+	if results[1].Path != "/Photos/b.pdf" || !results[1].Included || results[3].Rule != filter.NotIncluded {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestRunStatus(t *testing.T) {
+	status := &runStatus{}
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		status.ServeHTTP(rec, httptest.NewRequest("GET", statusPath, nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		return strings.TrimSpace(rec.Body.String())
+	}
+
+	if got := get(); got != `{"running":false}` {
+		t.Errorf("idle status = %s", got)
+	}
+	status.engine.Store(&backup.Engine{})
+	if got := get(); got != `{"running":true}` {
+		t.Errorf("status before downloads = %s", got)
+	}
+}
+
+func TestHealthReport(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hourly := &config.Schedule{Interval: time.Hour, Start: start}
+	run := func(at, took time.Duration, success bool) config.RunRecord {
+		record := config.RunRecord{Start: start.Add(at), End: start.Add(at + took), Success: success, Downloaded: 3, Bytes: 2048}
+		if !success {
+			record.Error = "disk full"
+		}
+		return record
+	}
+
+	tests := []struct {
+		name       string
+		profile    config.ProfileSettings
+		now        time.Duration
+		wantStatus string
+		want       []string
+	}{
+		{
+			name:       "no runs",
+			profile:    config.ProfileSettings{},
+			wantStatus: healthNoRuns,
+			want:       []string{"Schedule: none", "Status: no runs yet", "Runs: none recorded yet"},
+		},
+		{
+			name:       "no runs of a new daemon",
+			profile:    config.ProfileSettings{Schedule: hourly},
+			now:        30 * time.Minute,
+			wantStatus: healthNoRuns,
+			want:       []string{"Next run: ", "Status: no runs yet"},
+		},
+		{
+			name:       "no runs of a stopped daemon",
+			profile:    config.ProfileSettings{Schedule: hourly},
+			now:        3 * time.Hour,
+			wantStatus: healthAttention,
+			want:       []string{"overdue by 2h0m0s", "Status: needs attention"},
+		},
+		{
+			name:       "manual runs",
+			profile:    config.ProfileSettings{Runs: []config.RunRecord{run(0, time.Minute, false), run(time.Hour, time.Minute, true)}},
+			wantStatus: healthOK,
+			want:       []string{"Status: healthy", "ok, 3 files (2.0 KB) in 1m0s", "failed: disk full"},
+		},
+		{
+			name:       "on schedule",
+			profile:    config.ProfileSettings{Schedule: hourly, Runs: []config.RunRecord{run(time.Hour, 10*time.Minute, true)}},
+			now:        90 * time.Minute,
+			wantStatus: healthOK,
+			want:       []string{"every 1h0m0s", "Last run within its window: yes (10m0s of 1h0m0s)", "Status: healthy"},
+		},
+		{
+			name:       "overran its window",
+			profile:    config.ProfileSettings{Schedule: hourly, Runs: []config.RunRecord{run(time.Hour, 90*time.Minute, true)}},
+			now:        3 * time.Hour,
+			wantStatus: healthAttention,
+			want:       []string{"Last run within its window: no (1h30m0s of 1h0m0s)", "Status: needs attention"},
+		},
+		{
+			name:       "overdue",
+			profile:    config.ProfileSettings{Schedule: hourly, Runs: []config.RunRecord{run(time.Hour, time.Minute, true)}},
+			now:        5 * time.Hour,
+			wantStatus: healthAttention,
+			want:       []string{"overdue by 3h0m0s", "Status: needs attention"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, status := healthReport(tt.profile, start.Add(tt.now), 5)
+			if status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status, tt.wantStatus)
+			}
+
+			var buf bytes.Buffer
+			text, _ := output.New(output.Text, &buf)
+			if err := text.Report(report); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("report = %q, missing %q", buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestEndMessage(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := &backup.Stats{
+		DownloadedFiles: 12,
+		FailedFiles:     2,
+		TotalBytes:      2 << 20,
+		StartTime:       start,
+		EndTime:         start.Add(90 * time.Second),
+	}
+
+	if got, want := endMessage(stats, nil), "12 files downloaded (2.0 MB), 2 failed in 1m30s"; got != want {
+		t.Errorf("endMessage() = %q, want %q", got, want)
+	}
+	if got := endMessage(stats, errors.New("disk full")); got != "disk full" {
+		t.Errorf("endMessage() of a failed run = %q, want the error", got)
+	}
+}
+
+func TestRunAlert(t *testing.T) {
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	stats := &backup.Stats{
+		RunID:           "20240101T020000Z-0a1b2c",
+		DownloadedFiles: 12,
+		FailedFiles:     5,
+		StartTime:       start,
+		EndTime:         start.Add(time.Minute),
+		Errors: []backup.FileError{
+			{Path: "/a.txt", Class: "permission", Error: "denied"},
+			{Path: "/b.txt", Class: "permission", Error: "denied"},
+			{Path: "/c.txt", Class: "path_too_long", Error: "too long"},
+		},
+	}
+	cfg := &config.Config{Profile: "work", AlertFailures: 2}
+
+	a := runAlert(cfg, stats, errors.New("disk full"), "https://nas/reports/20240101T020000Z-0a1b2c.json")
+	if a.RunID != stats.RunID || a.Status != "failed" || a.Error != "disk full" || a.Profile != "work" {
+		t.Errorf("runAlert() = %+v, want the failed run of profile work", a)
+	}
+	if len(a.Failures) != 2 || a.Failures[1].Path != "/b.txt" || a.MoreFailures != 3 {
+		t.Errorf("runAlert() failures = %+v and %d more, want the first 2 and 3 more", a.Failures, a.MoreFailures)
+	}
+	if !strings.HasSuffix(a.Summary, "run=20240101T020000Z-0a1b2c") {
+		t.Errorf("runAlert() summary = %q, want the result line", a.Summary)
+	}
+
+	if a := runAlert(cfg, &backup.Stats{RunID: "id"}, nil, ""); a.Status != "ok" || len(a.Failures) != 0 || a.MoreFailures != 0 {
+		t.Errorf("runAlert() of a successful run = %+v", a)
+	}
+}
+
+func TestInstallReport(t *testing.T) {
+	opts := install.Options{ConfigDir: "/home/me/.config/create-dropbox-backup-folder", Schedule: "02:30"}
+	result := install.Result{
+		Binary:     "/usr/local/bin/create-dropbox-backup-folder",
+		EnvFile:    "/home/me/.config/create-dropbox-backup-folder/backup.env",
+		EnvCreated: true,
+		Scheduler:  "cron",
+	}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(installReport(result, opts)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"backup.env (created)", "daily at 02:30 with cron, logging to /home/me/.config/create-dropbox-backup-folder/backup.log"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("install report = %q, missing %q", buf.String(), want)
+		}
+	}
+
+	buf.Reset()
+	result.EnvCreated, result.Scheduler = false, ""
+	if err := text.Report(installReport(result, opts)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "(kept)") || !strings.Contains(buf.String(), "none (pass --schedule") {
+		t.Errorf("install report without schedule = %q", buf.String())
+	}
+}
+
+func TestImportSyncReport(t *testing.T) {
+	exclusions := []syncimport.Exclusion{
+		{Path: "/Photos/Old", Source: syncimport.SourceSelectiveSync},
+		{Path: "/code/app/node_modules", Source: syncimport.SourceIgnored},
+	}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(importSyncReport("/home/me/Dropbox", exclusions, false)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/photos/old/ (selective sync)", "/code/app/node_modules/ (ignored)", "pass --save"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("import-sync report = %q, missing %q", buf.String(), want)
+		}
+	}
+	if got := syncPatterns(exclusions); !slices.Equal(got, []string{"/photos/old/", "/code/app/node_modules/"}) {
+		t.Errorf("syncPatterns() = %v", got)
+	}
+}
+
+func TestTypesReport(t *testing.T) {
+	groups := []filetypes.Group{
+		{Name: ".mp4", Files: 1, Bytes: 300, Largest: []filetypes.File{{Path: "/v.mp4", Size: 300}}},
+		{Name: ".jpg", Files: 2, Bytes: 100},
+		{Name: ".txt", Files: 1, Bytes: 0},
+	}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(typesReport(groups, filetypes.ByExtension, 2)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"4 files, 400 B, by extension", "Not shown: 1 smaller groups", ".mp4: 1 files, 300 B (75.0%)", "/v.mp4: 300 B", ".jpg: 2 files, 100 B (25.0%)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text output = %q, missing %q", buf.String(), want)
+		}
+	}
+	if strings.Contains(buf.String(), ".txt") {
+		t.Errorf("text output = %q, shows more than the top 2 groups", buf.String())
+	}
+
+	buf.Reset()
+	jsonOut, _ := output.New(output.JSON, &buf)
+	if err := jsonOut.Report(typesReport(groups, filetypes.ByExtension, 2)); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Files  int               `json:"files"`
+		Groups []filetypes.Group `json:"groups"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output isn't JSON: %v", err)
+	}
+	if decoded.Files != 4 || len(decoded.Groups) != 3 {
+		t.Errorf("JSON output = %+v, want all 3 groups of 4 files", decoded)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "7d", want: now.Add(-7 * 24 * time.Hour)},
+		{value: "2w", want: now.Add(-14 * 24 * time.Hour)},
+		{value: "36h", want: now.Add(-36 * time.Hour)},
+		{value: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{value: "d", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "last week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSince(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoInput(t *testing.T) {
+	defer func(noInput bool) { flagNoInput = noInput }(flagNoInput)
+
+	flagNoInput = false
+	if err := requireInput("setup"); err != nil {
+		t.Errorf("requireInput() error = %v without --no-input", err)
+	}
+
+	flagNoInput = true
+	if err := requireInput("setup"); !errors.Is(err, ui.ErrNoInput) || !strings.Contains(err.Error(), "setup") {
+		t.Errorf("requireInput() error = %v, want ErrNoInput naming setup", err)
+	}
+	if _, err := newPrompt().Ask("Conflict?", []ui.Choice{{Key: "s", Label: "skip"}}); !errors.Is(err, ui.ErrNoInput) {
+		t.Errorf("prompt error = %v, want ErrNoInput", err)
+	}
+}
+
+func TestConfigReport(t *testing.T) {
+	cfg := &config.Config{
+		ConfigFile:     "/etc/backup.yaml",
+		BackupDir:      "/backups",
+		Profile:        config.DefaultProfile,
+		RemotePaths:    []string{"/Photos"},
+		Exclude:        []string{"*.tmp"},
+		MaxConcurrency: 8,
+		LogLevel:       "info",
+	}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(configReport(cfg)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Configuration is valid", "Config file: /etc/backup.yaml", "Back up: /Photos", "Concurrency: 8", "Bandwidth limit: unlimited"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	jsonOut, _ := output.New(output.JSON, &buf)
+	if err := jsonOut.Report(configReport(cfg)); err != nil {
+		t.Fatal(err)
+	}
+	var decoded configSummary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if !decoded.Valid || decoded.ConfigFile != "/etc/backup.yaml" || decoded.MaxConcurrency != 8 {
+		t.Errorf("JSON output = %+v", decoded)
+	}
+}
+
+func TestFilesReport(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 9, 30, 0, 0, time.Local)
+	files := []manifest.File{{Key: "/big.mov", Entry: manifest.Entry{Size: 2048, ModTime: modified}}}
+
+	var buf bytes.Buffer
+	text, _ := output.New(output.Text, &buf)
+	if err := text.Report(filesReport("📦 Largest 1 of 3 files", files)); err != nil {
+		t.Fatal(err)
+	}
+	want := "📦 Largest 1 of 3 files\n   /big.mov: 2.0 KB, modified 2024-03-01 09:30\n"
+	if buf.String() != want {
+		t.Errorf("text output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	jsonOut, _ := output.New(output.JSON, &buf)
+	if err := jsonOut.Report(filesReport("none", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("JSON output of no files = %q, want []", buf.String())
+	}
+}
+
+func TestInterruptContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts can't be sent to the own process on Windows")
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not cancelled by the interrupt")
+	}
+}
+
+func TestReportBackupResultLast(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, formatter, logger := os.Stderr, out, slog.Default()
+	os.Stderr, out = w, output.Discard
+	defer func() {
+		closeLogging()
+		os.Stderr, out, logHandler = stderr, formatter, nil
+		slog.SetDefault(logger)
+	}()
+
+	// --log-batch holds per-file records back until the end of the interval
+	setupLogging("info", time.Hour)
+	ctx := logbatch.PerFile(context.Background())
+	for _, path := range []string{"/a", "/b", "/c"} {
+		slog.InfoContext(ctx, "Downloaded file", slog.String("path", path))
+	}
+	slog.Info("Backup completed successfully")
+	if err := reportBackup(&config.Config{}, &backup.Stats{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	closeLogging()
+	w.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[len(lines)-1], "RESULT ok ") {
+		t.Errorf("stderr = %q, want the log records and the RESULT line last", lines)
+	}
+}
+
+func TestSaveRunDryRunRecordsAPIUsage(t *testing.T) {
+	t.Setenv("DROPBOX_BACKUP_SETTINGS", filepath.Join(t.TempDir(), "settings.json"))
+
+	usage := budget.Usage{time.Now().Truncate(time.Hour).Unix(): 7}
+	cfg := &config.Config{ClientID: "app", Profile: "default", DryRun: true}
+	saveRun(cfg, &backup.Stats{APIUsage: usage}, nil)
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(settings.APIUsage["app"], usage) {
+		t.Errorf("API usage = %v, want the calls of the dry run %v", settings.APIUsage["app"], usage)
+	}
+	if runs := settings.Profile("default").Runs; len(runs) != 0 {
+		t.Errorf("runs = %v, want dry runs left out of the history", runs)
+	}
+}
+
+func TestSharedBudget(t *testing.T) {
+	defer func() { apiBudget = nil }()
+
+	if sharedBudget(&config.Config{}) != nil {
+		t.Error("sharedBudget() without --api-call-budget != nil")
+	}
+	first := &config.Config{APICallBudget: 100}
+	second := &config.Config{APICallBudget: 100}
+	calls := sharedBudget(first)
+	if calls == nil || sharedBudget(second) != calls || first.APIBudget != calls || second.APIBudget != calls {
+		t.Error("sharedBudget() didn't share one budget between the clients of the process")
 	}
 }